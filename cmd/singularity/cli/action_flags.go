@@ -31,7 +31,10 @@ var (
 	DNS             string
 	Security        []string
 	CgroupsPath     string
+	KeyFile         string
 	ContainLibsPath []string
+	EnvFilter       []string
+	EnvExclude      []string
 
 	IsBoot          bool
 	IsFakeroot      bool
@@ -147,6 +150,11 @@ func initPathVars() {
 	actionFlags.SetAnnotation("apply-cgroups", "argtag", []string{"<path>"})
 	actionFlags.SetAnnotation("apply-cgroups", "envkey", []string{"APPLY_CGROUPS"})
 
+	// --keyfile
+	actionFlags.StringVar(&KeyFile, "keyfile", "", "path to the key file decrypting encrypted image partitions")
+	actionFlags.SetAnnotation("keyfile", "argtag", []string{"<path>"})
+	actionFlags.SetAnnotation("keyfile", "envkey", []string{"KEYFILE"})
+
 	// hidden flag to handle SINGULARITY_CONTAINLIBS environment variable
 	actionFlags.StringSliceVar(&ContainLibsPath, "containlibs", []string{}, "")
 	actionFlags.Lookup("containlibs").Hidden = true
@@ -173,6 +181,16 @@ func initBoolVars() {
 	actionFlags.BoolVarP(&IsCleanEnv, "cleanenv", "e", false, "clean environment before running container")
 	actionFlags.SetAnnotation("cleanenv", "envkey", []string{"CLEANENV"})
 
+	// --env-filter
+	actionFlags.StringSliceVar(&EnvFilter, "env-filter", []string{}, "only pass host environment variables matching these patterns (e.g. 'SLURM_*,PMI_*')")
+	actionFlags.SetAnnotation("env-filter", "argtag", []string{"<patterns>"})
+	actionFlags.SetAnnotation("env-filter", "envkey", []string{"ENV_FILTER"})
+
+	// --env-exclude
+	actionFlags.StringSliceVar(&EnvExclude, "env-exclude", []string{}, "never pass host environment variables matching these patterns")
+	actionFlags.SetAnnotation("env-exclude", "argtag", []string{"<patterns>"})
+	actionFlags.SetAnnotation("env-exclude", "envkey", []string{"ENV_EXCLUDE"})
+
 	// -c|--contain
 	actionFlags.BoolVarP(&IsContained, "contain", "c", false, "use minimal /dev and empty other directories (e.g. /tmp and $HOME) instead of sharing filesystems from your host")
 	actionFlags.SetAnnotation("contain", "envkey", []string{"CONTAIN"})
@@ -8,6 +8,7 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -34,6 +35,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
 	"github.com/sylabs/singularity/internal/pkg/security"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/telemetry"
 	"github.com/sylabs/singularity/internal/pkg/util/env"
 	"github.com/sylabs/singularity/internal/pkg/util/exec"
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
@@ -57,6 +59,8 @@ func init() {
 		cmd.Flags().AddFlag(actionFlags.Lookup("contain"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("containall"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("cleanenv"))
+		cmd.Flags().AddFlag(actionFlags.Lookup("env-filter"))
+		cmd.Flags().AddFlag(actionFlags.Lookup("env-exclude"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("home"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("ipc"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("net"))
@@ -84,6 +88,7 @@ func init() {
 		cmd.Flags().AddFlag(actionFlags.Lookup("no-init"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("security"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("apply-cgroups"))
+		cmd.Flags().AddFlag(actionFlags.Lookup("keyfile"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("app"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("containlibs"))
 		cmd.Flags().AddFlag(actionFlags.Lookup("no-nv"))
@@ -118,20 +123,22 @@ func handleOCI(u string) (string, error) {
 	name := uri.GetName(u)
 	imgabs := cache.OciTempImage(sum, name)
 
-	if exists, err := cache.OciTempExists(sum, name); err != nil {
-		return "", fmt.Errorf("unable to check if %v exists: %v", imgabs, err)
-	} else if !exists {
+	err = cache.Fetch(imgabs, func(tmp string) error {
 		sylog.Infof("Converting OCI blobs to SIF format")
-		b, err := build.NewBuild(u, imgabs, "sif", "", "", types.Options{TmpDir: tmpDir, NoTest: true, NoHTTPS: noHTTPS})
+		b, err := build.NewBuild(u, tmp, "sif", "", "", types.Options{TmpDir: tmpDir, NoTest: true, NoHTTPS: noHTTPS})
 		if err != nil {
-			return "", fmt.Errorf("unable to create new build: %v", err)
+			return fmt.Errorf("unable to create new build: %v", err)
 		}
 
-		if err := b.Full(); err != nil {
-			return "", fmt.Errorf("unable to build: %v", err)
+		if err := b.Full(context.Background()); err != nil {
+			return fmt.Errorf("unable to build: %v", err)
 		}
 
 		sylog.Infof("Image cached as SIF at %s", imgabs)
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
 
 	return imgabs, nil
@@ -146,11 +153,13 @@ func handleLibrary(u string) (string, error) {
 	imageName := uri.GetName(u)
 	imagePath := cache.LibraryImage(libraryImage.Hash, imageName)
 
-	if exists, err := cache.LibraryImageExists(libraryImage.Hash, imageName); err != nil {
-		return "", fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
-	} else if !exists {
+	err = cache.Fetch(imagePath, func(tmp string) error {
 		sylog.Infof("Downloading library image")
-		libexec.PullLibraryImage(imagePath, u, "https://library.sylabs.io", false, authToken)
+		libexec.PullLibraryImage(tmp, u, "https://library.sylabs.io", false, authToken)
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
 
 	return imagePath, nil
@@ -174,11 +183,18 @@ func handleNet(u string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
 	}
-	if !exists {
-		sylog.Infof("Downloading network image")
-		libexec.PullNetImage(imagePath, u, true)
-	} else {
+	if exists {
 		sylog.Infof("Use image from cache")
+		return imagePath, nil
+	}
+
+	err = cache.Fetch(imagePath, func(tmp string) error {
+		sylog.Infof("Downloading network image")
+		libexec.PullNetImage(tmp, u, true)
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
 
 	return imagePath, nil
@@ -432,6 +448,14 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		engineConfig.SetCgroupsPath(CgroupsPath)
 	}
 
+	if KeyFile != "" {
+		abspath, err := filepath.Abs(KeyFile)
+		if err != nil {
+			sylog.Fatalf("Failed to determine key file absolute path for %s: %s", KeyFile, err)
+		}
+		engineConfig.SetEncryptionKeyFile(abspath)
+	}
+
 	if IsWritable && IsWritableTmpfs {
 		sylog.Warningf("Disabling --writable-tmpfs flag, mutually exclusive with --writable")
 		engineConfig.SetWritableTmpfs(false)
@@ -563,7 +587,11 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	environment := os.Environ()
 
 	// Clean environment
-	env.SetContainerEnv(&generator, environment, IsCleanEnv, engineConfig.GetHomeDest())
+	envFilter := env.Filter{Include: EnvFilter, Exclude: EnvExclude}
+	if err := envFilter.Validate(); err != nil {
+		sylog.Fatalf("%s", err)
+	}
+	env.SetContainerEnv(&generator, environment, IsCleanEnv, envFilter, engineConfig.GetHomeDest())
 
 	// force to use getwd syscall
 	os.Unsetenv("PWD")
@@ -638,6 +666,8 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 			sylog.Infof("instance started successfully")
 		}
 	} else {
+		// the CLI process is replaced by the container
+		telemetry.Flush(nil)
 		if err := exec.Pipe(starter, []string{procname}, Env, configData); err != nil {
 			sylog.Fatalf("%s", err)
 		}
@@ -7,12 +7,16 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/sylabs/singularity/internal/pkg/build/remotebuilder"
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -20,19 +24,40 @@ import (
 )
 
 var (
-	remote     bool
-	builderURL string
-	detached   bool
-	libraryURL string
-	isJSON     bool
-	sandbox    bool
-	writable   bool
-	force      bool
-	update     bool
-	noTest     bool
-	sections   []string
-	tmpDir     string
-	noHTTPS    bool
+	remote       bool
+	builderURL   string
+	detached     bool
+	libraryURL   string
+	isJSON       bool
+	sandbox      bool
+	writable     bool
+	force        bool
+	update       bool
+	noTest       bool
+	sections     []string
+	tmpDir       string
+	tmpDirMax    string
+	noHTTPS      bool
+	jsonReport   string
+	jsonProgress bool
+	testReport   string
+	timings      bool
+	dryRun       bool
+	buildNetwork string
+	buildArgs    []string
+	compression  string
+	noCache      bool
+	scanner      string
+	scanSeverity string
+	scanURL      string
+	signImage    bool
+	signKey      string
+	platform     string
+	whiteout     string
+	requireGPG   bool
+	resume       bool
+	annotations  bool
+	format       string
 )
 
 var buildflags = pflag.NewFlagSet("BuildFlags", pflag.ExitOnError)
@@ -43,6 +68,14 @@ func init() {
 	BuildCmd.Flags().BoolVarP(&sandbox, "sandbox", "s", false, "build image as sandbox format (chroot directory structure)")
 	BuildCmd.Flags().SetAnnotation("sandbox", "envkey", []string{"SANDBOX"})
 
+	BuildCmd.Flags().StringVar(&format, "format", "sif", "format of the built image (sif, sandbox, oci, oci-archive, docker-archive, docker-daemon)")
+	BuildCmd.Flags().SetAnnotation("format", "argtag", []string{"<format>"})
+	BuildCmd.Flags().SetAnnotation("format", "envkey", []string{"FORMAT"})
+
+	BuildCmd.Flags().StringVar(&compression, "compression", "gzip", "compression of the squashfs partition of SIF images (gzip, xz, lz4, zstd)")
+	BuildCmd.Flags().SetAnnotation("compression", "argtag", []string{"<algorithm>"})
+	BuildCmd.Flags().SetAnnotation("compression", "envkey", []string{"COMPRESSION"})
+
 	BuildCmd.Flags().StringSliceVar(&sections, "section", []string{"all"}, "only run specific section(s) of deffile (setup, post, files, environment, test, labels, none)")
 	BuildCmd.Flags().SetAnnotation("section", "envkey", []string{"SECTION"})
 
@@ -64,8 +97,8 @@ func init() {
 	BuildCmd.Flags().BoolVarP(&detached, "detached", "d", false, "submit build job and print nuild ID (no real-time logs and requires --remote)")
 	BuildCmd.Flags().SetAnnotation("detached", "envkey", []string{"DETACHED"})
 
-	BuildCmd.Flags().StringVar(&builderURL, "builder", "https://build.sylabs.io", "remote Build Service URL")
-	BuildCmd.Flags().SetAnnotation("builder", "envkey", []string{"BUILDER"})
+	BuildCmd.PersistentFlags().StringVar(&builderURL, "builder", "https://build.sylabs.io", "remote Build Service URL")
+	BuildCmd.PersistentFlags().SetAnnotation("builder", "envkey", []string{"BUILDER"})
 
 	BuildCmd.Flags().StringVar(&libraryURL, "library", "https://library.sylabs.io", "container Library URL")
 	BuildCmd.Flags().SetAnnotation("library", "envkey", []string{"LIBRARY"})
@@ -73,9 +106,78 @@ func init() {
 	BuildCmd.Flags().StringVar(&tmpDir, "tmpdir", "", "specify a temporary directory to use for build")
 	BuildCmd.Flags().SetAnnotation("tmpdir", "envkey", []string{"TMPDIR"})
 
+	BuildCmd.Flags().StringVar(&tmpDirMax, "tmpdir-max-size", "", "fail the build if its temporary directory grows over this size (e.g. 20G)")
+	BuildCmd.Flags().SetAnnotation("tmpdir-max-size", "argtag", []string{"<size>"})
+	BuildCmd.Flags().SetAnnotation("tmpdir-max-size", "envkey", []string{"TMPDIR_MAX_SIZE"})
+
+	BuildCmd.Flags().StringVar(&jsonReport, "json-report", "", "write a JSON report describing the built image to this file")
+	BuildCmd.Flags().SetAnnotation("json-report", "argtag", []string{"<path>"})
+	BuildCmd.Flags().SetAnnotation("json-report", "envkey", []string{"JSON_REPORT"})
+
+	BuildCmd.Flags().StringVar(&testReport, "test-report", "", "write the results of the %test blocks run at build time to this file in JUnit XML format")
+	BuildCmd.Flags().SetAnnotation("test-report", "argtag", []string{"<path>"})
+	BuildCmd.Flags().SetAnnotation("test-report", "envkey", []string{"TEST_REPORT"})
+
+	BuildCmd.Flags().BoolVar(&jsonProgress, "json-progress", false, "stream build progress events to stdout as JSON lines")
+	BuildCmd.Flags().SetAnnotation("json-progress", "envkey", []string{"JSON_PROGRESS"})
+
+	BuildCmd.Flags().BoolVar(&timings, "timings", false, "print the duration of each build phase once the build is complete")
+	BuildCmd.Flags().SetAnnotation("timings", "envkey", []string{"TIMINGS"})
+
+	BuildCmd.Flags().BoolVar(&dryRun, "dry-run", false, "check the definition without building it")
+	BuildCmd.Flags().SetAnnotation("dry-run", "envkey", []string{"DRY_RUN"})
+
 	BuildCmd.Flags().BoolVar(&noHTTPS, "nohttps", false, "do NOT use HTTPS, for communicating with local docker registry")
 	BuildCmd.Flags().SetAnnotation("nohttps", "envkey", []string{"NOHTTPS"})
 
+	BuildCmd.Flags().StringVar(&platform, "platform", "", "select the image for this os/arch[/variant] from docker and OCI manifest lists (default: host platform)")
+	BuildCmd.Flags().SetAnnotation("platform", "argtag", []string{"<os/arch[/variant]>"})
+	BuildCmd.Flags().SetAnnotation("platform", "envkey", []string{"PLATFORM"})
+
+	BuildCmd.Flags().StringVar(&whiteout, "whiteout", "remove", "how to handle whiteouts of docker and OCI layers (remove, overlay, error)")
+	BuildCmd.Flags().SetAnnotation("whiteout", "argtag", []string{"<mode>"})
+	BuildCmd.Flags().SetAnnotation("whiteout", "envkey", []string{"WHITEOUT"})
+
+	BuildCmd.Flags().BoolVar(&requireGPG, "require-gpg", false, "require GPG verification of packages fetched by yum, zypper and debootstrap bootstraps")
+	BuildCmd.Flags().SetAnnotation("require-gpg", "envkey", []string{"REQUIRE_GPG"})
+
+	BuildCmd.Flags().StringVar(&buildNetwork, "network", "host", "network namespace in which %post and %test run (host, none, bridge)")
+	BuildCmd.Flags().SetAnnotation("network", "argtag", []string{"<mode>"})
+	BuildCmd.Flags().SetAnnotation("network", "envkey", []string{"BUILD_NETWORK"})
+
+	BuildCmd.Flags().StringSliceVar(&buildArgs, "build-arg", []string{}, "set the value of a {{ .NAME }} build argument of the definition file")
+	BuildCmd.Flags().SetAnnotation("build-arg", "argtag", []string{"<KEY=VAL>"})
+	BuildCmd.Flags().SetAnnotation("build-arg", "envkey", []string{"BUILD_ARG"})
+
+	BuildCmd.Flags().BoolVar(&noCache, "no-cache", false, "do not restore or save the container filesystem after %post in the build cache")
+	BuildCmd.Flags().SetAnnotation("no-cache", "envkey", []string{"NO_CACHE"})
+
+	BuildCmd.Flags().StringVar(&scanner, "scan", "", "scan the container for vulnerabilities before assembling the image (default scanner: cve)")
+	BuildCmd.Flags().Lookup("scan").NoOptDefVal = "cve"
+	BuildCmd.Flags().SetAnnotation("scan", "argtag", []string{"[scanner]"})
+	BuildCmd.Flags().SetAnnotation("scan", "envkey", []string{"SCAN"})
+
+	BuildCmd.Flags().StringVar(&scanSeverity, "scan-severity", "high", "fail the build on vulnerabilities of this severity or higher (low, medium, high, critical)")
+	BuildCmd.Flags().SetAnnotation("scan-severity", "argtag", []string{"<severity>"})
+	BuildCmd.Flags().SetAnnotation("scan-severity", "envkey", []string{"SCAN_SEVERITY"})
+
+	BuildCmd.Flags().StringVar(&scanURL, "scan-url", "", "URL of the vulnerability database queried by the cve scanner")
+	BuildCmd.Flags().SetAnnotation("scan-url", "argtag", []string{"<url>"})
+	BuildCmd.Flags().SetAnnotation("scan-url", "envkey", []string{"SCAN_URL"})
+
+	BuildCmd.Flags().BoolVar(&signImage, "sign", false, "sign the SIF image with a private key of the local keyring once built")
+	BuildCmd.Flags().SetAnnotation("sign", "envkey", []string{"SIGN"})
+
+	BuildCmd.Flags().StringVar(&signKey, "sign-key", "", "fingerprint or key ID of the private key signing the image (implies --sign)")
+	BuildCmd.Flags().SetAnnotation("sign-key", "argtag", []string{"<fingerprint>"})
+	BuildCmd.Flags().SetAnnotation("sign-key", "envkey", []string{"SIGN_KEY"})
+
+	BuildCmd.Flags().BoolVar(&resume, "resume", false, "checkpoint the bootstrapped container and resume a failed build from the checkpoint")
+	BuildCmd.Flags().SetAnnotation("resume", "envkey", []string{"RESUME"})
+
+	BuildCmd.Flags().BoolVar(&annotations, "oci-annotations", false, "add org.opencontainers.image.* annotations derived from the definition labels and the git repository of the build context")
+	BuildCmd.Flags().SetAnnotation("oci-annotations", "envkey", []string{"OCI_ANNOTATIONS"})
+
 	SingularityCmd.AddCommand(BuildCmd)
 }
 
@@ -98,6 +200,16 @@ func checkBuildTarget(path string, update bool) bool {
 	if sandbox && remote {
 		sylog.Fatalf("Unable to create build: Can't remote build a sandbox container.")
 	}
+	if format != "sif" && remote {
+		sylog.Fatalf("Unable to create build: Can't remote build a %s container.", format)
+	}
+	switch format {
+	case "docker-daemon":
+		// the target is an image reference in the docker daemon
+		return true
+	case "docker-archive":
+		path = strings.SplitN(path, ":", 2)[0]
+	}
 	if f, err := os.Stat(path); err == nil {
 		if update && !f.IsDir() {
 			sylog.Fatalf("Only sandbox updating is supported.")
@@ -141,7 +253,70 @@ func checkSections() error {
 	return nil
 }
 
-func definitionFromSpec(spec string) (def types.Definition, err error) {
+// remoteBuild submits the build of spec to the remote build service and
+// retrieves the resulting image at dest
+func remoteBuild(dest, spec string) {
+	if jsonProgress && detached {
+		sylog.Fatalf("JSON build progress is not supported with detached builds")
+	}
+
+	// Submiting a remote build requires a valid authToken
+	if authToken == "" {
+		sylog.Fatalf("Unable to submit build job: %v", authWarning)
+	}
+
+	def, err := definitionFromSpec(spec, parseBuildArgs())
+	if err != nil {
+		sylog.Fatalf("Unable to build from %s: %v", spec, err)
+	}
+
+	b, err := remotebuilder.New(dest, libraryURL, def, detached, force, builderURL, authToken)
+	if err != nil {
+		sylog.Fatalf("Failed to create builder: %v", err)
+	}
+	b.JobsFile = remoteJobsFile()
+
+	var progressDone chan struct{}
+	if jsonProgress {
+		progressDone = make(chan struct{})
+		go writeBuildProgress(b.Progress(), progressDone)
+	}
+
+	err = b.Build(context.TODO())
+	if progressDone != nil {
+		<-progressDone
+	}
+	if err != nil {
+		sylog.Fatalf("While performing build: %v", err)
+	}
+}
+
+// writeBuildProgress writes the build events to stdout, one JSON object per
+// line, and closes done once all events are written
+func writeBuildProgress(events <-chan types.Event, done chan<- struct{}) {
+	enc := json.NewEncoder(os.Stdout)
+	for e := range events {
+		if err := enc.Encode(e); err != nil {
+			sylog.Warningf("Unable to write build progress: %v", err)
+		}
+	}
+	close(done)
+}
+
+// parseBuildArgs returns the KEY=VAL build arguments passed with --build-arg
+func parseBuildArgs() map[string]string {
+	args := make(map[string]string)
+	for _, a := range buildArgs {
+		split := strings.SplitN(a, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			sylog.Fatalf("Invalid build argument %q, expected KEY=VAL", a)
+		}
+		args[split[0]] = split[1]
+	}
+	return args
+}
+
+func definitionFromSpec(spec string, args map[string]string) (def types.Definition, err error) {
 
 	// Try spec as URI first
 	def, err = types.NewDefinitionFromURI(spec)
@@ -159,14 +334,13 @@ func definitionFromSpec(spec string) (def types.Definition, err error) {
 	if isValid {
 		sylog.Debugf("Found valid definition: %s\n", spec)
 		// File exists and contains valid definition
-		var defFile *os.File
-		defFile, err = os.Open(spec)
+		var data []byte
+		data, err = parser.ReadDefinitionFile(spec)
 		if err != nil {
 			return
 		}
 
-		defer defFile.Close()
-		def, err = parser.ParseDefinitionFile(defFile)
+		def, err = parser.ParseDefinitionFileWithArgs(bytes.NewReader(data), args)
 
 		return
 	}
@@ -6,11 +6,9 @@
 package cli
 
 import (
-	"context"
 	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/sylabs/singularity/internal/pkg/build/remotebuilder"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
@@ -31,23 +29,5 @@ func run(cmd *cobra.Command, args []string) {
 		sylog.Fatalf("Only remote builds are supported on this platform")
 	}
 
-	// Submiting a remote build requires a valid authToken
-	if authToken == "" {
-		sylog.Fatalf("Unable to submit build job: %v", authWarning)
-	}
-
-	def, err := definitionFromSpec(spec)
-	if err != nil {
-		sylog.Fatalf("Unable to build from %s: %v", spec, err)
-	}
-
-	b, err := remotebuilder.New(dest, libraryURL, def, detached, force, builderURL, authToken)
-	if err != nil {
-		sylog.Fatalf("Failed to create builder: %v", err)
-	}
-
-	err = b.Build(context.TODO())
-	if err != nil {
-		sylog.Fatalf("While performing build: %v", err)
-	}
+	remoteBuild(dest, spec)
 }
@@ -0,0 +1,149 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/build/remotebuilder"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// remoteJobsFileName is the name of the file recording remote builds
+const remoteJobsFileName = "remote-builds.json"
+
+// build jobs logs options
+var followLogs bool
+
+func init() {
+	// -f|--follow
+	BuildJobsLogsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "keep streaming logs until the build completes, reconnecting if needed")
+	BuildJobsLogsCmd.Flags().SetAnnotation("follow", "envkey", []string{"FOLLOW"})
+
+	BuildCmd.AddCommand(BuildJobsCmd)
+	BuildJobsCmd.AddCommand(BuildJobsListCmd)
+	BuildJobsCmd.AddCommand(BuildJobsLogsCmd)
+	BuildJobsCmd.AddCommand(BuildJobsCancelCmd)
+}
+
+// remoteJobsFile returns the path of the file recording remote builds
+func remoteJobsFile() string {
+	return filepath.Join(filepath.Dir(defaultTokenFile), remoteJobsFileName)
+}
+
+// newJobsClient returns a remote builder client for build jobs commands
+func newJobsClient() *remotebuilder.RemoteBuilder {
+	if authToken == "" {
+		sylog.Fatalf("Unable to contact the remote build service: %v", authWarning)
+	}
+	b, err := remotebuilder.New("", libraryURL, types.Definition{}, false, false, builderURL, authToken)
+	if err != nil {
+		sylog.Fatalf("Failed to create builder: %v", err)
+	}
+	return b
+}
+
+// BuildJobsCmd singularity build jobs
+var BuildJobsCmd = &cobra.Command{
+	Run:                   nil,
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.BuildJobsUse,
+	Short:   docs.BuildJobsShort,
+	Long:    docs.BuildJobsLong,
+	Example: docs.BuildJobsExample,
+}
+
+// BuildJobsListCmd singularity build jobs list
+var BuildJobsListCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(0),
+	PreRun:                sylabsToken,
+	Run: func(cmd *cobra.Command, args []string) {
+		jobs, err := remotebuilder.ReadJobs(remoteJobsFile())
+		if err != nil {
+			sylog.Fatalf("Unable to read remote builds: %v", err)
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No remote build submitted")
+			return
+		}
+
+		b := newJobsClient()
+		fmt.Printf("%-24s  %-19s  %-8s  %s\n", "ID", "SUBMITTED", "STATUS", "IMAGE")
+		for _, job := range jobs {
+			status := "unknown"
+			if job.BuilderURL == b.BuilderURL.String() {
+				rd, err := b.Status(context.TODO(), job.ID)
+				if err != nil {
+					sylog.Debugf("Unable to get status of build %s: %v", job.ID, err)
+				} else {
+					status = buildStatus(rd)
+				}
+			}
+			fmt.Printf("%-24s  %-19s  %-8s  %s\n", job.ID, job.SubmitTime.Format("2006-01-02 15:04:05"), status, job.ImagePath)
+		}
+	},
+
+	Use:     docs.BuildJobsListUse,
+	Short:   docs.BuildJobsListShort,
+	Long:    docs.BuildJobsListLong,
+	Example: docs.BuildJobsListExample,
+}
+
+// BuildJobsLogsCmd singularity build jobs logs
+var BuildJobsLogsCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	PreRun:                sylabsToken,
+	Run: func(cmd *cobra.Command, args []string) {
+		b := newJobsClient()
+		if err := b.Logs(context.TODO(), args[0], followLogs); err != nil {
+			sylog.Fatalf("While streaming build logs: %v", err)
+		}
+	},
+
+	Use:     docs.BuildJobsLogsUse,
+	Short:   docs.BuildJobsLogsShort,
+	Long:    docs.BuildJobsLogsLong,
+	Example: docs.BuildJobsLogsExample,
+}
+
+// BuildJobsCancelCmd singularity build jobs cancel
+var BuildJobsCancelCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	PreRun:                sylabsToken,
+	Run: func(cmd *cobra.Command, args []string) {
+		b := newJobsClient()
+		if err := b.Cancel(context.TODO(), args[0]); err != nil {
+			sylog.Fatalf("Unable to cancel build %s: %v", args[0], err)
+		}
+		fmt.Printf("Build %s canceled\n", args[0])
+	},
+
+	Use:     docs.BuildJobsCancelUse,
+	Short:   docs.BuildJobsCancelShort,
+	Long:    docs.BuildJobsCancelLong,
+	Example: docs.BuildJobsCancelExample,
+}
+
+// buildStatus returns a short description of a remote build status
+func buildStatus(rd types.ResponseData) string {
+	switch {
+	case rd.IsComplete:
+		return "complete"
+	case rd.StartTime != nil:
+		return "running"
+	default:
+		return "queued"
+	}
+}
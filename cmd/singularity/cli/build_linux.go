@@ -7,12 +7,19 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/internal/pkg/build"
-	"github.com/sylabs/singularity/internal/pkg/build/remotebuilder"
 	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
+	"github.com/sylabs/singularity/internal/pkg/overlay"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/syplugin"
 )
@@ -23,38 +30,74 @@ func preRun(cmd *cobra.Command, args []string) {
 }
 
 func run(cmd *cobra.Command, args []string) {
-	buildFormat := "sif"
+	buildFormat := format
 	if sandbox {
+		if format != "sif" && format != "sandbox" {
+			sylog.Fatalf("Unable to create build: --sandbox conflicts with --format %s", format)
+		}
 		buildFormat = "sandbox"
 	}
 
 	dest := args[0]
 	spec := args[1]
 
+	if dryRun {
+		if errors := validateSpec(spec); errors > 0 {
+			sylog.Fatalf("Definition %s has %d error(s)", spec, errors)
+		}
+		sylog.Infof("Definition %s is valid", spec)
+		return
+	}
+
 	// check if target collides with existing file
 	if ok := checkBuildTarget(dest, update); !ok {
 		os.Exit(1)
 	}
 
-	if remote {
-		// Submiting a remote build requires a valid authToken
-		if authToken == "" {
-			sylog.Fatalf("Unable to submit build job: %v", authWarning)
-		}
+	if remote && jsonReport != "" {
+		sylog.Fatalf("JSON build report is not supported with remote builds")
+	}
+	if remote && timings {
+		sylog.Fatalf("Build timings are not supported with remote builds")
+	}
+	if remote && platform != "" {
+		sylog.Fatalf("Platform selection is not supported with remote builds")
+	}
+	if remote && resume {
+		sylog.Fatalf("Resuming builds is not supported with remote builds")
+	}
+	if remote && buildNetwork != types.NetworkHost {
+		sylog.Fatalf("Network selection is not supported with remote builds")
+	}
+	if remote && compression != "gzip" {
+		sylog.Fatalf("Compression selection is not supported with remote builds")
+	}
+	if remote && scanner != "" {
+		sylog.Fatalf("Vulnerability scans are not supported with remote builds")
+	}
+	if remote && (signImage || signKey != "") {
+		sylog.Fatalf("Signing images is not supported with remote builds, sign them with the sign command")
+	}
+	if remote && testReport != "" {
+		sylog.Fatalf("Test reports are not supported with remote builds")
+	}
+	if remote && tmpDirMax != "" {
+		sylog.Fatalf("Temporary directory size limits are not supported with remote builds")
+	}
+	if remote && annotations {
+		sylog.Fatalf("OCI annotations are not supported with remote builds")
+	}
 
-		def, err := definitionFromSpec(spec)
-		if err != nil {
-			sylog.Fatalf("Unable to build from %s: %v", spec, err)
+	var tmpDirMaxSize int64
+	if tmpDirMax != "" {
+		var err error
+		if tmpDirMaxSize, err = overlay.ParseSize(tmpDirMax); err != nil {
+			sylog.Fatalf("Invalid --tmpdir-max-size: %v", err)
 		}
+	}
 
-		b, err := remotebuilder.New(dest, libraryURL, def, detached, force, builderURL, authToken)
-		if err != nil {
-			sylog.Fatalf("Failed to create builder: %v", err)
-		}
-		err = b.Build(context.TODO())
-		if err != nil {
-			sylog.Fatalf("While performing build: %v", err)
-		}
+	if remote {
+		remoteBuild(dest, spec)
 	} else {
 
 		err := checkSections()
@@ -69,19 +112,187 @@ func run(cmd *cobra.Command, args []string) {
 			libraryURL,
 			authToken,
 			types.Options{
-				TmpDir:   tmpDir,
-				Update:   update,
-				Force:    force,
-				Sections: sections,
-				NoTest:   noTest,
-				NoHTTPS:  noHTTPS,
+				TmpDir:         tmpDir,
+				TmpDirMaxSize:  tmpDirMaxSize,
+				Update:         update,
+				Force:          force,
+				Sections:       sections,
+				NoTest:         noTest,
+				NoHTTPS:        noHTTPS,
+				Platform:       platform,
+				Whiteout:       whiteout,
+				RequireGPG:     requireGPG,
+				Resume:         resume,
+				Network:        buildNetwork,
+				BuildArgs:      parseBuildArgs(),
+				Compression:    compression,
+				NoCache:        noCache,
+				Scan:           scanner,
+				ScanSeverity:   scanSeverity,
+				ScanURL:        scanURL,
+				Sign:           signImage || signKey != "",
+				SignKey:        signKey,
+				OCIAnnotations: annotations,
+				Context:        buildContext(spec),
 			})
 		if err != nil {
 			sylog.Fatalf("Unable to create build: %v", err)
 		}
 
-		if err = b.Full(); err != nil {
+		var warnings []string
+		if jsonReport != "" {
+			sylog.SetWarningHook(func(msg string) {
+				warnings = append(warnings, msg)
+			})
+		}
+
+		var progressDone chan struct{}
+		if jsonProgress {
+			progressDone = make(chan struct{})
+			go writeBuildProgress(b.Progress(), progressDone)
+		}
+
+		ctx, cancel := interruptContext()
+		err = b.Full(ctx)
+		cancel()
+		if progressDone != nil {
+			<-progressDone
+		}
+		// test results are written even if tests made the build fail
+		if testReport != "" {
+			if werr := writeTestReport(b, testReport); werr != nil {
+				sylog.Errorf("While writing test report: %v", werr)
+			}
+		}
+		if err != nil {
 			sylog.Fatalf("While performing build: %v", err)
 		}
+
+		if jsonReport != "" {
+			if err := writeBuildReport(b, warnings); err != nil {
+				sylog.Fatalf("While writing build report: %v", err)
+			}
+		}
+
+		if timings {
+			printBuildTimings(b.Timings())
+		}
+	}
+}
+
+// interruptContext returns a context canceled when SIGINT or SIGTERM is
+// received, so that the build is stopped and cleaned up instead of the
+// process being killed
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-sigs:
+			sylog.Warningf("Received %s, stopping build...", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// buildContext returns the directory of the definition file spec, or the
+// current directory if spec is not a file
+func buildContext(spec string) string {
+	if fi, err := os.Stat(spec); err == nil && !fi.IsDir() {
+		if abs, err := filepath.Abs(spec); err == nil {
+			return filepath.Dir(abs)
+		}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// validateSpec prints the diagnostics of the definition of spec without
+// building it, and returns the number of errors found
+func validateSpec(spec string) int {
+	def, err := definitionFromSpec(spec, parseBuildArgs())
+	if err != nil {
+		sylog.Fatalf("Unable to parse %s: %v", spec, err)
+	}
+
+	var locations *types.Locations
+	if ok, _ := parser.IsValidDefinition(spec); ok {
+		f, err := os.Open(spec)
+		if err != nil {
+			sylog.Fatalf("Unable to open %s: %v", spec, err)
+		}
+		locations, err = parser.LocateDefinitionFile(f)
+		f.Close()
+		if err != nil {
+			sylog.Fatalf("Unable to read %s: %v", spec, err)
+		}
+	}
+
+	errors := 0
+	for _, d := range build.Validate([]types.Definition{def}, locations) {
+		if d.Line > 0 {
+			fmt.Printf("%s:%d: %s: %s\n", spec, d.Line, d.Severity, d.Message)
+		} else {
+			fmt.Printf("%s: %s: %s\n", spec, d.Severity, d.Message)
+		}
+		if d.Severity == build.SeverityError {
+			errors++
+		}
+	}
+	return errors
+}
+
+// writeTestReport writes the results of the tests run by build b to path in
+// JUnit XML format
+func writeTestReport(b *build.Build, path string) error {
+	report := b.TestReport()
+	if report == nil {
+		sylog.Warningf("No test was run, not writing test report %s", path)
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJUnit(f, "singularity-build"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printBuildTimings prints the duration of each build phase as a table
+func printBuildTimings(r types.TimingReport) {
+	fmt.Printf("%-20s %10s\n", "PHASE", "DURATION")
+	for _, p := range r.Phases {
+		fmt.Printf("%-20s %9.1fs\n", p.Phase, p.Duration)
+	}
+	fmt.Printf("%-20s %9.1fs\n", "total", r.Total)
+}
+
+// writeBuildReport writes the JSON report of build b to the --json-report file
+func writeBuildReport(b *build.Build, warnings []string) error {
+	report, err := b.Report()
+	if err != nil {
+		return err
+	}
+	report.Warnings = append(report.Warnings, warnings...)
+
+	data, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
 	}
+	return ioutil.WriteFile(jsonReport, append(data, '\n'), 0644)
 }
@@ -0,0 +1,27 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	SingularityCmd.AddCommand(CacheCmd)
+	CacheCmd.AddCommand(CacheVerifyCmd)
+}
+
+// CacheCmd is the cache command
+var CacheCmd = &cobra.Command{
+	Run:                   nil,
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.CacheUse,
+	Short:   docs.CacheShort,
+	Long:    docs.CacheLong,
+	Example: docs.CacheExample,
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// cache verify options
+var cacheDryRun bool
+
+func init() {
+	// -n|--dry-run
+	CacheVerifyCmd.Flags().BoolVarP(&cacheDryRun, "dry-run", "n", false, "only report damaged entries, do not remove them")
+	CacheVerifyCmd.Flags().SetAnnotation("dry-run", "envkey", []string{"DRY_RUN"})
+}
+
+// CacheVerifyCmd singularity cache verify
+var CacheVerifyCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		problems, err := cache.Verify(!cacheDryRun)
+		if err != nil {
+			sylog.Fatalf("Unable to verify cache %s: %v", cache.Root(), err)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("No damaged entry found in %s\n", cache.Root())
+			return
+		}
+
+		damaged := 0
+		for _, p := range problems {
+			status := "DAMAGED"
+			if p.Repaired {
+				status = "REMOVED"
+			} else {
+				damaged++
+			}
+			fmt.Printf("%-8s %s: %s\n", status, p.Path, p.Reason)
+		}
+		if damaged > 0 {
+			os.Exit(1)
+		}
+	},
+
+	Use:     docs.CacheVerifyUse,
+	Short:   docs.CacheVerifyShort,
+	Long:    docs.CacheVerifyLong,
+	Example: docs.CacheVerifyExample,
+}
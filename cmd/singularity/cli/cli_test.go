@@ -78,3 +78,26 @@ func TestEnvStringNSlice(t *testing.T) {
 	v = cmd.Flag("stringSlice").Value.String()
 	assert.Equal(t, v, "[sliceval]", "Once set, the flag should not be appended or overwritten.")
 }
+
+func TestApplyDefaults(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+	var defaultBool bool
+	var defaultSlice []string
+	var defaultString string
+
+	cmd.Flags().BoolVar(&defaultBool, "defaultBool", false, "")
+	cmd.Flags().StringSliceVar(&defaultSlice, "defaultSlice", []string{}, "")
+	cmd.Flags().StringVar(&defaultString, "defaultString", "", "")
+	cmd.Flags().Set("defaultString", "cmdline")
+
+	applyDefaults(cmd.Flags(), map[string]interface{}{
+		"defaultBool":   true,
+		"defaultSlice":  []interface{}{"a", "b"},
+		"defaultString": "default",
+		"unknownFlag":   1,
+	})
+	assert.Equal(t, defaultBool, true, "The flag should be set to the default value.")
+	assert.Equal(t, cmd.Flag("defaultSlice").Value.String(), "[a,b]", "The flag should be set to the default values.")
+	assert.Equal(t, defaultString, "cmdline", "The flag set on the command line should be kept.")
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// contains flag variables for completion command
+var (
+	completionList string
+)
+
+// bashCompletionFunc is called by the generated bash completion when no
+// command or flag matches, it completes instance names and cached images
+// by calling back singularity completion --list
+const bashCompletionFunc = `
+__singularity_list()
+{
+    singularity completion --list "$1" 2>/dev/null
+}
+
+__singularity_complete_instance()
+{
+    local prefix=""
+    case ${cur} in
+    instance://*)
+        prefix="instance://"
+        ;;
+    //*)
+        # ':' is part of COMP_WORDBREAKS, instance:// is split in 3 words
+        prefix="//"
+        ;;
+    esac
+    COMPREPLY=( $(compgen -P "${prefix}" -W "$(__singularity_list instances)" -- "${cur#${prefix}}") )
+}
+
+__custom_func()
+{
+    case ${last_command} in
+    singularity_instance_stop)
+        COMPREPLY=( $(compgen -W "$(__singularity_list instances)" -- "${cur}") )
+        ;;
+    singularity_exec | singularity_run | singularity_shell)
+        if [[ ${cur} == instance://* || ${cur} == //* ]]; then
+            __singularity_complete_instance
+            return
+        fi
+        COMPREPLY=( $(compgen -W "$(__singularity_list images)" -- "${cur}") )
+        _filedir
+        ;;
+    singularity_inspect | singularity_test | singularity_instance_start)
+        COMPREPLY=( $(compgen -W "$(__singularity_list images)" -- "${cur}") )
+        _filedir
+        ;;
+    esac
+}
+`
+
+func init() {
+	SingularityCmd.AddCommand(CompletionCmd)
+	SingularityCmd.BashCompletionFunction = bashCompletionFunc
+
+	// --list
+	CompletionCmd.Flags().StringVar(&completionList, "list", "", "list instances or cached images for dynamic completion")
+	CompletionCmd.Flags().Lookup("list").Hidden = true
+}
+
+// CompletionCmd singularity completion
+var CompletionCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if completionList != "" {
+			if err := listCompletion(os.Stdout, completionList); err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			return
+		}
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		if err := genCompletion(os.Stdout, args[0]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.CompletionUse,
+	Short:   docs.CompletionShort,
+	Long:    docs.CompletionLong,
+	Example: docs.CompletionExample,
+}
+
+// genCompletion writes the completion script of shell to w
+func genCompletion(w io.Writer, shell string) error {
+	var err error
+	switch shell {
+	case "bash":
+		err = SingularityCmd.GenBashCompletion(w)
+	case "zsh":
+		err = SingularityCmd.GenZshCompletion(w)
+	default:
+		return fmt.Errorf("unsupported shell %s, must be one of bash or zsh", shell)
+	}
+	if err != nil {
+		return fmt.Errorf("while generating %s completion: %s", shell, err)
+	}
+	return nil
+}
+
+// listCompletion writes the candidates of a dynamic completion to w, one
+// per line
+func listCompletion(w io.Writer, kind string) error {
+	switch kind {
+	case "instances":
+		files, err := instance.List("", "*")
+		if err != nil {
+			return fmt.Errorf("failed to retrieve instance list: %s", err)
+		}
+		for _, file := range files {
+			fmt.Fprintln(w, file.Name)
+		}
+	case "images":
+		for _, dir := range []string{cache.Library(), cache.Net(), cache.Shub()} {
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() && !cache.IsTemporary(info.Name()) {
+					fmt.Fprintln(w, path)
+				}
+				return nil
+			})
+		}
+	default:
+		return fmt.Errorf("unknown completion list %s", kind)
+	}
+	return nil
+}
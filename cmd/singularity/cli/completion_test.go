@@ -0,0 +1,93 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+)
+
+func TestGenCompletion(t *testing.T) {
+	tests := []struct {
+		shell    string
+		contains []string
+		ok       bool
+	}{
+		{"bash", []string{"__singularity_list", "_singularity_instance_stop", "_singularity_completion"}, true},
+		{"zsh", []string{"#compdef singularity"}, true},
+		{"fish", nil, false},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		err := genCompletion(&b, tt.shell)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: unexpected success", tt.shell)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.shell, err)
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%s: completion doesn't contain %q", tt.shell, s)
+			}
+		}
+	}
+}
+
+func TestListCompletion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "completion-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := os.Getenv(cache.DirEnv)
+	defer os.Setenv(cache.DirEnv, env)
+	os.Setenv(cache.DirEnv, dir)
+
+	images := []string{
+		filepath.Join(cache.Library(), "sum", "alpine_latest.sif"),
+		filepath.Join(cache.Net(), "sum", "image.sif"),
+	}
+	temporary := []string{
+		filepath.Join(cache.Library(), "sum", ".tmp-alpine"),
+		filepath.Join(cache.Net(), "sum", "image.sif.lock"),
+	}
+	for _, path := range append(images, temporary...) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := listCompletion(&b, "images"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := strings.Fields(b.String())
+	sort.Strings(got)
+	sort.Strings(images)
+	if strings.Join(got, " ") != strings.Join(images, " ") {
+		t.Errorf("got images %v, want %v", got, images)
+	}
+
+	if err := listCompletion(&b, "tags"); err == nil {
+		t.Errorf("unexpected success listing unknown candidates")
+	}
+}
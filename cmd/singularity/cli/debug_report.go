@@ -0,0 +1,43 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/debugreport"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	SingularityCmd.AddCommand(DebugReportCmd)
+}
+
+// DebugReportCmd singularity debug-report
+var DebugReportCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := fmt.Sprintf("singularity-debug-%s.tar.gz", time.Now().Format("20060102-150405"))
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if err := debugreport.Generate(path); err != nil {
+			sylog.Fatalf("Failed to generate debug report: %s", err)
+		}
+		sylog.Infof("Debug report written to %s", path)
+	},
+
+	Use:     docs.DebugReportUse,
+	Short:   docs.DebugReportShort,
+	Long:    docs.DebugReportLong,
+	Example: docs.DebugReportExample,
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultsFile is the name of the files holding default flag values
+const defaultsFile = "defaults.yaml"
+
+// noDefaults disables defaults files when set
+var noDefaults bool
+
+// flagDefaults maps a command path like "instance start" to the default
+// values of its flags
+type flagDefaults map[string]map[string]interface{}
+
+// defaultsFiles returns the defaults files by order of precedence, the user
+// defaults file comes before the system-wide one
+func defaultsFiles() []string {
+	return []string{
+		filepath.Join(filepath.Dir(defaultTokenFile), defaultsFile),
+		filepath.Join(buildcfg.SYSCONFDIR, "singularity", defaultsFile),
+	}
+}
+
+// readDefaults reads the defaults file at path, a missing file is not an error
+func readDefaults(path string) (flagDefaults, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	d := make(flagDefaults)
+	if err := yaml.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("while parsing %s: %s", path, err)
+	}
+	return d, nil
+}
+
+// commandPath returns the command path without the root command name
+func commandPath(cmd *cobra.Command) string {
+	path := strings.Fields(cmd.CommandPath())
+	return strings.Join(path[1:], " ")
+}
+
+// updateFlagsFromDefaults sets flags not set on the command line or by an
+// environment variable from the defaults files.
+// priority system file < user file < env < command line
+func updateFlagsFromDefaults(cmd *cobra.Command) {
+	if noDefaults {
+		return
+	}
+	for _, path := range defaultsFiles() {
+		d, err := readDefaults(path)
+		if err != nil {
+			sylog.Warningf("Ignoring defaults file: %s", err)
+			continue
+		}
+		if values, ok := d[commandPath(cmd)]; ok {
+			sylog.Debugf("Applying defaults from %s", path)
+			applyDefaults(cmd.Flags(), values)
+		}
+	}
+}
+
+// applyDefaults sets unchanged flags of flags to values
+func applyDefaults(flags *pflag.FlagSet, values map[string]interface{}) {
+	for name, value := range values {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			sylog.Warningf("Ignoring default value for unknown flag %s", name)
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+
+		var err error
+		if list, ok := value.([]interface{}); ok {
+			for _, v := range list {
+				if err = flag.Value.Set(fmt.Sprint(v)); err != nil {
+					break
+				}
+			}
+		} else {
+			err = flag.Value.Set(fmt.Sprint(value))
+		}
+		if err != nil {
+			sylog.Warningf("Unable to set %s to default value %v: %s", name, value, err)
+			continue
+		}
+		flag.Changed = true
+		sylog.Debugf("Update flag %s Value to: %s", name, flag.Value)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/imgdiff"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+var (
+	diffFiles bool
+	diffJSON  bool
+)
+
+func init() {
+	DiffCmd.Flags().SetInterspersed(false)
+
+	DiffCmd.Flags().BoolVar(&diffFiles, "files", false, "also compare the files of the images (requires unsquashfs)")
+	DiffCmd.Flags().SetAnnotation("files", "envkey", []string{"FILES"})
+
+	DiffCmd.Flags().BoolVarP(&diffJSON, "json", "j", false, "print the differences in JSON format")
+	DiffCmd.Flags().SetAnnotation("json", "envkey", []string{"JSON"})
+
+	SingularityCmd.AddCommand(DiffCmd)
+}
+
+// DiffCmd singularity diff
+var DiffCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := imgdiff.Compare(args[0], args[1], imgdiff.Options{Files: diffFiles})
+		if err != nil {
+			sylog.Fatalf("While comparing images: %s", err)
+		}
+
+		if diffJSON {
+			b, err := json.MarshalIndent(r, "", "\t")
+			if err != nil {
+				sylog.Fatalf("While encoding differences: %s", err)
+			}
+			fmt.Println(string(b))
+		} else {
+			printDiffReport(r)
+		}
+
+		// exit like diff(1) when images differ
+		if !r.Empty() {
+			os.Exit(1)
+		}
+	},
+
+	Use:     docs.DiffUse,
+	Short:   docs.DiffShort,
+	Long:    docs.DiffLong,
+	Example: docs.DiffExample,
+}
+
+// diffSymbols prefixes changes by kind
+var diffSymbols = map[string]string{
+	imgdiff.Added:    "+",
+	imgdiff.Removed:  "-",
+	imgdiff.Modified: "~",
+}
+
+// printChanges prints the changes of a section of the report
+func printChanges(title string, changes []imgdiff.Change) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, c := range changes {
+		switch c.Kind {
+		case imgdiff.Added:
+			fmt.Printf("  %s %s: %s\n", diffSymbols[c.Kind], c.Name, c.New)
+		case imgdiff.Removed:
+			fmt.Printf("  %s %s: %s\n", diffSymbols[c.Kind], c.Name, c.Old)
+		default:
+			fmt.Printf("  %s %s: %s -> %s\n", diffSymbols[c.Kind], c.Name, c.Old, c.New)
+		}
+	}
+}
+
+// printDiffReport prints the differences by section, followed by a summary
+func printDiffReport(r *imgdiff.Report) {
+	if r.Empty() {
+		fmt.Println("No differences found")
+		return
+	}
+
+	printChanges("Labels", r.Labels)
+	if len(r.Deffile) != 0 {
+		fmt.Println("Definition file:")
+		for _, l := range r.Deffile {
+			fmt.Printf("  %s\n", l)
+		}
+	}
+	printChanges("Data objects", r.Objects)
+	printChanges("Files", r.Files)
+
+	fmt.Printf("\n%d label(s), %d definition line(s), %d data object(s)", len(r.Labels), len(r.Deffile), len(r.Objects))
+	if diffFiles {
+		fmt.Printf(", %d file(s)", len(r.Files))
+	}
+	fmt.Println(" changed")
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// contains flag variables for image commands
+var (
+	ImageWritable bool
+)
+
+func init() {
+	SingularityCmd.AddCommand(ImageCmd)
+	ImageCmd.AddCommand(ImageMountCmd)
+	ImageCmd.AddCommand(ImageUmountCmd)
+}
+
+// ImageCmd is the image command
+var ImageCmd = &cobra.Command{
+	Run:                   nil,
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.ImageUse,
+	Short:   docs.ImageShort,
+	Long:    docs.ImageLong,
+	Example: docs.ImageExample,
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/imgmount"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	// -w|--writable
+	ImageMountCmd.Flags().BoolVarP(&ImageWritable, "writable", "w", false, "mount the image in read-write mode (ext3 images only)")
+	ImageMountCmd.Flags().SetAnnotation("writable", "envkey", []string{"WRITABLE"})
+
+	ImageMountCmd.Flags().SetInterspersed(false)
+}
+
+// ImageMountCmd singularity image mount
+var ImageMountCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := imgmount.Mount(args[0], args[1], ImageWritable, nil); err != nil {
+			sylog.Fatalf("failed to mount %s: %s", args[0], err)
+		}
+		sylog.Infof("Image %s mounted on %s", args[0], args[1])
+	},
+
+	Use:     docs.ImageMountUse,
+	Short:   docs.ImageMountShort,
+	Long:    docs.ImageMountLong,
+	Example: docs.ImageMountExample,
+}
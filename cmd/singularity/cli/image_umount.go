@@ -0,0 +1,31 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/imgmount"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// ImageUmountCmd singularity image umount
+var ImageUmountCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := imgmount.Umount(args[0]); err != nil {
+			sylog.Fatalf("failed to unmount %s: %s", args[0], err)
+		}
+	},
+
+	Use:     docs.ImageUmountUse,
+	Short:   docs.ImageUmountShort,
+	Long:    docs.ImageUmountLong,
+	Example: docs.ImageUmountExample,
+}
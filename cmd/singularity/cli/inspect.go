@@ -8,18 +8,31 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
 
+	ocitypes "github.com/containers/image/types"
+	units "github.com/docker/go-units"
 	"github.com/opencontainers/runtime-tools/generate"
 	"github.com/spf13/cobra"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
+	"github.com/sylabs/singularity/internal/pkg/image"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/exec"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/internal/pkg/util/shell"
+	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/src/docs"
 
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
@@ -32,9 +45,17 @@ var (
 	deffile     bool
 	runscript   bool
 	testfile    bool
+	testresults bool
 	environment bool
 	helpfile    bool
 	jsonfmt     bool
+	inspectApp  string
+	inspectAll  bool
+	listData    bool
+	listApps    bool
+	inspectFmt  string
+	setLabels   []string
+	delLabels   []string
 )
 
 func init() {
@@ -52,12 +73,40 @@ func init() {
 	InspectCmd.Flags().BoolVarP(&testfile, "test", "t", false, "show the test script for the image")
 	InspectCmd.Flags().SetAnnotation("test", "envkey", []string{"TEST"})
 
+	InspectCmd.Flags().BoolVar(&testresults, "test-results", false, "show the results of the test script run at build time")
+	InspectCmd.Flags().SetAnnotation("test-results", "envkey", []string{"TEST_RESULTS"})
+
 	InspectCmd.Flags().BoolVarP(&environment, "environment", "e", false, "show the environment settings for the image")
 	InspectCmd.Flags().SetAnnotation("environment", "envkey", []string{"ENVIRONMENT"})
 
 	InspectCmd.Flags().BoolVarP(&helpfile, "helpfile", "H", false, "inspect the runscript helpfile, if it exists")
 	InspectCmd.Flags().SetAnnotation("helpfile", "envkey", []string{"HELPFILE"})
 
+	InspectCmd.Flags().StringVar(&inspectApp, "app", "", "inspect the metadata of this SCIF app instead of those of the container")
+	InspectCmd.Flags().SetAnnotation("app", "argtag", []string{"<name>"})
+	InspectCmd.Flags().SetAnnotation("app", "envkey", []string{"APP", "APPNAME"})
+
+	InspectCmd.Flags().BoolVar(&inspectAll, "all", false, "show all the metadata of the image and of its SCIF apps, in JSON format")
+	InspectCmd.Flags().SetAnnotation("all", "envkey", []string{"ALL"})
+
+	InspectCmd.Flags().BoolVar(&listApps, "list-apps", false, "show the labels, runscript, helpfile, environment and test of every SCIF app, in JSON format")
+	InspectCmd.Flags().SetAnnotation("list-apps", "envkey", []string{"LIST_APPS"})
+
+	InspectCmd.Flags().BoolVar(&listData, "list-data", false, "list the data objects of a SIF image, with their type, size and signature status")
+	InspectCmd.Flags().SetAnnotation("list-data", "envkey", []string{"LIST_DATA"})
+
+	InspectCmd.Flags().StringVar(&inspectFmt, "format", "", "format the metadata of the image and of its SCIF apps with a Go template, e.g. '{{ .Labels.foo }}'")
+	InspectCmd.Flags().SetAnnotation("format", "argtag", []string{"<template>"})
+	InspectCmd.Flags().SetAnnotation("format", "envkey", []string{"FORMAT"})
+
+	InspectCmd.Flags().StringSliceVar(&setLabels, "set-label", []string{}, "set a label of a SIF image, without rebuilding it (may be specified multiple times)")
+	InspectCmd.Flags().SetAnnotation("set-label", "argtag", []string{"<key=value>"})
+	InspectCmd.Flags().SetAnnotation("set-label", "envkey", []string{"SET_LABEL"})
+
+	InspectCmd.Flags().StringSliceVar(&delLabels, "delete-label", []string{}, "delete a label of a SIF image, without rebuilding it (may be specified multiple times)")
+	InspectCmd.Flags().SetAnnotation("delete-label", "argtag", []string{"<key>"})
+	InspectCmd.Flags().SetAnnotation("delete-label", "envkey", []string{"DELETE_LABEL"})
+
 	InspectCmd.Flags().BoolVarP(&jsonfmt, "json", "j", false, "print structured json instead of sections")
 	InspectCmd.Flags().SetAnnotation("json", "envkey", []string{"JSON"})
 
@@ -76,6 +125,39 @@ var InspectCmd = &cobra.Command{
 
 	Run: func(cmd *cobra.Command, args []string) {
 
+		var tmpl *template.Template
+		if inspectFmt != "" {
+			if listData {
+				sylog.Fatalf("--format can't be used with --list-data")
+			}
+			var err error
+			if tmpl, err = parseInspectTemplate(inspectFmt); err != nil {
+				sylog.Fatalf("While parsing format: %v", err)
+			}
+			// templates can use any attribute, gather them all
+			inspectAll = true
+		}
+
+		if err := checkInspectFlags(); err != nil {
+			sylog.Fatalf("%v", err)
+		}
+		if inspectAll {
+			labels, deffile, runscript, testfile, testresults, environment, helpfile = true, true, true, true, true, true, true
+		}
+
+		if t, _ := uri.Split(args[0]); t != "" {
+			if len(setLabels) != 0 || len(delLabels) != 0 {
+				sylog.Fatalf("Labels can only be edited in local SIF images, not in %s", args[0])
+			}
+			// registry metadata are used when they hold the requested
+			// attributes, the image is pulled to the cache otherwise
+			if attributes, ok := inspectRemote(args[0]); ok {
+				printAttributes(attributes)
+				return
+			}
+			replaceURIWithImage(cmd, args)
+		}
+
 		// Sanity check
 		if _, err := os.Stat(args[0]); err != nil {
 			sylog.Fatalf("container not found: %s", err)
@@ -87,115 +169,589 @@ var InspectCmd = &cobra.Command{
 		}
 		name := filepath.Base(abspath)
 
+		if len(setLabels) != 0 || len(delLabels) != 0 {
+			if err := editLabels(abspath, name); err != nil {
+				sylog.Fatalf("While editing labels of %s: %v", abspath, err)
+			}
+			sylog.Infof("Labels of %s updated", abspath)
+			return
+		}
+
+		if listData {
+			objects, err := image.SIFObjects(abspath)
+			if err != nil {
+				sylog.Fatalf("While reading data objects of %s: %v", abspath, err)
+			}
+			printSIFObjects(objects)
+			return
+		}
+
 		attributes := make(map[string]string)
 
-		a := []string{"/bin/sh", "-c", ""}
-		prefix := "@@@start"
-		delimiter := "@@@end"
+		// metadata files requested, in order of display
+		var files []inspectFile
 
 		if helpfile {
 			sylog.Debugf("Inspection of helpfile selected.")
-
-			// append to a[2] to run commands in container
-			a[2] += fmt.Sprintf(" echo '%v\nhelpfile';", prefix)
-			a[2] += " cat .singularity.d/runscript.help;"
-			a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+			files = append(files, inspectFile{"helpfile", image.MetadataPath("runscript.help", inspectApp)})
 		}
 
 		if deffile {
 			sylog.Debugf("Inspection of deffile selected.")
-
-			// append to a[2] to run commands in container
-			a[2] += fmt.Sprintf(" echo '%v\ndeffile';", prefix)
-			a[2] += " cat .singularity.d/Singularity;"
-			a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+			files = append(files, inspectFile{"deffile", image.MetadataPath("Singularity", "")})
 		}
 
 		if runscript {
 			sylog.Debugf("Inspection of runscript selected.")
-
-			// append to a[2] to run commands in container
-			a[2] += fmt.Sprintf(" echo '%v\nrunscript';", prefix)
-			a[2] += " cat .singularity.d/runscript;"
-			a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+			files = append(files, inspectFile{"runscript", image.MetadataPath("runscript", inspectApp)})
 		}
 
 		if testfile {
 			sylog.Debugf("Inspection of test selected.")
+			files = append(files, inspectFile{"test", image.MetadataPath("test", inspectApp)})
+		}
+
+		if testresults {
+			sylog.Debugf("Inspection of test results selected.")
 
-			// append to a[2] to run commands in container
-			a[2] += fmt.Sprintf(" echo '%v\ntest';", prefix)
-			a[2] += " cat .singularity.d/test;"
-			a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+			// SIF images carry the test report as a data object, no
+			// need to start a container to read it
+			if report, err := sifTestReport(abspath); err == nil {
+				attributes["test-results"] = report
+			} else {
+				sylog.Debugf("Reading test report from container: %s", err)
+				files = append(files, inspectFile{"test-results", types.TestReportPath})
+			}
 		}
 
 		if environment {
 			sylog.Debugf("Inspection of environment selected.")
-
-			// append to a[2] to run commands in container
-			a[2] += fmt.Sprintf(" echo '%v\nenvironment';", prefix)
-			a[2] += " cat .singularity.d/env/90-environment.sh;"
-			a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+			files = append(files, inspectFile{"environment", image.MetadataPath("env/90-environment.sh", inspectApp)})
 		}
 
-		// default to labels if nothing was appended
-		if labels || len(a[2]) == 0 {
+		// default to labels if nothing was requested
+		if labels || (len(files) == 0 && len(attributes) == 0 && !listApps) {
 			sylog.Debugf("Inspection of labels as default.")
 
-			// append to a[2] to run commands in container
-			a[2] += fmt.Sprintf(" echo '%v\nlabels';", prefix)
-			a[2] += " cat .singularity.d/labels.json;"
-			a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+			// labels edited after the build are stored in a SIF data
+			// object and replace those of the container
+			if l, found, _ := metadata.LabelPartition(abspath); found && inspectApp == "" {
+				b, err := json.MarshalIndent(l, "", "\t")
+				if err != nil {
+					sylog.Fatalf("While encoding labels: %v", err)
+				}
+				attributes["labels"] = string(b)
+			} else {
+				files = append(files, inspectFile{"labels", image.MetadataPath("labels.json", inspectApp)})
+			}
 		}
 
-		fileContents, err := getFileContent(abspath, name, a)
-		if err != nil {
-			sylog.Fatalf("While getting helpfile: %v", err)
-		}
-
-		contentSlice := strings.Split(fileContents, delimiter)
-		for _, s := range contentSlice {
-			s = strings.TrimSpace(s)
-			if strings.HasPrefix(s, prefix) {
-				split := strings.SplitN(s, "\n", 3)
-				if len(split) == 3 {
-					attributes[split[1]] = split[2]
-				} else if len(split) == 2 {
-					sylog.Warningf("%v metadata was not found.", split[1])
+		// scripts stored in SIF images at build time are read without
+		// starting a container, along with the apps if they are requested
+		sifApps := false
+		if !fs.IsDir(abspath) {
+			if md, err := metadata.SIFMetadata(abspath); err != nil {
+				sylog.Debugf("Reading metadata from container: %s", err)
+			} else if md != nil {
+				if files, err = inspectSIF(abspath, md, files, attributes); err != nil {
+					sylog.Fatalf("While inspecting %s: %v", abspath, err)
 				}
+				sifApps = true
 			}
 		}
 
-		// format that data based on --json flag
-		if jsonfmt {
-			// store this in a struct, then marshal the struct to json
-			type result struct {
-				Data map[string]string `json:"attributes"`
-				T    string            `json:"type"`
+		if fs.IsDir(abspath) {
+			// sandbox metadata are read directly, without starting a
+			// container
+			if inspectAll || listApps {
+				apps, err := image.SandboxApps(abspath)
+				if err != nil {
+					sylog.Fatalf("While inspecting %s: %v", abspath, err)
+				}
+				for _, app := range apps {
+					attributes[appPrefix+app] = app
+					files = append(files, appFiles(app, app)...)
+				}
+			}
+			if err := inspectSandbox(abspath, files, attributes); err != nil {
+				sylog.Fatalf("While inspecting %s: %v", abspath, err)
+			}
+		} else if len(files) != 0 || (listApps && !sifApps) {
+			a := []string{"/bin/sh", "-c", ""}
+			prefix := "@@@start"
+			delimiter := "@@@end"
+
+			for _, f := range files {
+				// append to a[2] to run commands in container
+				a[2] += fmt.Sprintf(" echo '%v\n%v';", prefix, f.attribute)
+				a[2] += " cat " + strings.TrimPrefix(f.path, "/") + ";"
+				a[2] += fmt.Sprintf(" echo '%v';", delimiter)
 			}
 
-			d := result{
-				Data: attributes,
-				T:    "container",
+			if (inspectAll || listApps) && !sifApps {
+				// apps are only known once in the container, list them
+				// with their metadata in the same invocation
+				a[2] += " for app in " + strings.TrimPrefix(image.AppsDir, "/") + "/*; do"
+				a[2] += ` test -d "$app/scif" || continue; name="${app##*/}";`
+				a[2] += fmt.Sprintf(` echo "%v\n%v$name\n$name"; echo '%v';`, prefix, appPrefix, delimiter)
+				for _, f := range appFiles("$name", "$name") {
+					a[2] += fmt.Sprintf(` echo "%v\n%v";`, prefix, f.attribute)
+					a[2] += ` cat "` + strings.TrimPrefix(f.path, "/") + `" 2>/dev/null;`
+					a[2] += fmt.Sprintf(" echo '%v';", delimiter)
+				}
+				a[2] += " done;"
 			}
 
-			b, err := json.MarshalIndent(d, "", "\t")
+			fileContents, err := getFileContent(abspath, name, a)
 			if err != nil {
-				log.Fatal(err)
+				sylog.Fatalf("While getting helpfile: %v", err)
 			}
 
-			fmt.Println(string(b))
-		} else {
-			// iterate through sections of struct and print them
-			for _, value := range attributes {
-				fmt.Println("\n" + value + "\n")
+			contentSlice := strings.Split(fileContents, delimiter)
+			for _, s := range contentSlice {
+				s = strings.TrimSpace(s)
+				if strings.HasPrefix(s, prefix) {
+					split := strings.SplitN(s, "\n", 3)
+					if len(split) == 3 {
+						attributes[split[1]] = split[2]
+					} else if len(split) == 2 {
+						warnMissing(split[1])
+					}
+				}
 			}
 		}
 
+		if tmpl != nil {
+			if err := printTemplate(os.Stdout, tmpl, attributes); err != nil {
+				sylog.Fatalf("While formatting metadata: %v", err)
+			}
+			return
+		}
+		if inspectAll || listApps {
+			printAll(attributes)
+			return
+		}
+		printAttributes(attributes)
 	},
 	TraverseChildren: true,
 }
 
+// printAttributes prints the inspected attributes, in JSON format if
+// requested
+func printAttributes(attributes map[string]string) {
+	// format that data based on --json flag
+	if jsonfmt {
+		// store this in a struct, then marshal the struct to json
+		type result struct {
+			Data       map[string]string `json:"attributes"`
+			T          string            `json:"type"`
+			APIVersion string            `json:"apiVersion"`
+		}
+
+		d := result{
+			Data:       attributes,
+			T:          "container",
+			APIVersion: inspectAPIVersion,
+		}
+
+		b, err := json.MarshalIndent(d, "", "\t")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(string(b))
+	} else {
+		// iterate through sections of struct and print them
+		for _, value := range attributes {
+			fmt.Println("\n" + value + "\n")
+		}
+	}
+}
+
+// printSIFObjects prints the data objects of a SIF image as a table, or in
+// JSON format if requested
+func printSIFObjects(objects []image.SIFObject) {
+	if jsonfmt {
+		b, err := json.MarshalIndent(objects, "", "\t")
+		if err != nil {
+			sylog.Fatalf("While encoding data objects: %v", err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	fmt.Printf("%-4s %-6s %-8s %-12s %-10s %-19s %-6s %s\n", "ID", "GROUP", "LINK", "OFFSET", "SIZE", "CREATED", "SIGNED", "TYPE")
+	for _, o := range objects {
+		group, link := "-", "-"
+		if o.Group != 0 {
+			group = fmt.Sprint(o.Group)
+		}
+		if o.Link != 0 {
+			link = fmt.Sprint(o.Link)
+			if o.LinkGroup {
+				link += " (G)"
+			}
+		}
+		signed := "no"
+		if o.Signed {
+			signed = "yes"
+		}
+
+		typ := o.Type
+		switch {
+		case o.FsType != "":
+			typ += fmt.Sprintf(" (%s/%s/%s)", o.FsType, o.PartType, o.Arch)
+		case o.HashType != "":
+			typ += fmt.Sprintf(" (%s)", o.HashType)
+		}
+		if o.Name != "" {
+			typ += " " + o.Name
+		}
+
+		fmt.Printf("%-4d %-6s %-8s %-12d %-10s %-19s %-6s %s\n", o.ID, group, link, o.Offset,
+			units.BytesSize(float64(o.Size)), o.Created.Format("2006-01-02 15:04:05"), signed, typ)
+	}
+}
+
+// checkInspectFlags returns an error if --app is used with --all, --format
+// or --list-apps, which show the metadata of all apps
+func checkInspectFlags() error {
+	if inspectApp == "" {
+		return nil
+	}
+	if inspectAll {
+		return fmt.Errorf("--all, --format and --app can't be used together, the metadata of all apps are shown")
+	}
+	if listApps {
+		return fmt.Errorf("--list-apps and --app can't be used together, --list-apps shows the metadata of all apps")
+	}
+	return nil
+}
+
+// appPrefix prefixes the attributes of SCIF apps, e.g. apps/foo/labels for
+// the labels of the foo app, and apps/foo for the app itself
+const appPrefix = "apps/"
+
+// appFiles returns the metadata files of the SCIF app, with their attributes
+// prefixed by apps/name
+func appFiles(name, app string) []inspectFile {
+	files := []inspectFile{
+		{"helpfile", image.MetadataPath("runscript.help", app)},
+		{"runscript", image.MetadataPath("runscript", app)},
+		{"test", image.MetadataPath("test", app)},
+		{"environment", image.MetadataPath("env/90-environment.sh", app)},
+		{"labels", image.MetadataPath("labels.json", app)},
+	}
+	for i := range files {
+		files[i].attribute = appPrefix + name + "/" + files[i].attribute
+	}
+	return files
+}
+
+// warnMissing warns that the metadata of attribute were not found, unless
+// it is the metadata of an app or all metadata are shown as many are often
+// missing
+func warnMissing(attribute string) {
+	if !inspectAll && !strings.HasPrefix(attribute, appPrefix) {
+		sylog.Warningf("%v metadata was not found.", attribute)
+	}
+}
+
+// inspectAllResult is the JSON document printed by inspect --all and
+// --list-apps
+type inspectAllResult struct {
+	// Type is always container
+	Type string `json:"type"`
+	// APIVersion is the version of this document format
+	APIVersion string `json:"apiVersion"`
+	// Attributes holds the metadata of the container, by attribute
+	Attributes map[string]string `json:"attributes"`
+	// Apps holds the metadata of the SCIF apps, by app and attribute
+	Apps map[string]map[string]string `json:"apps"`
+}
+
+// printAll prints the attributes gathered by inspect --all or --list-apps,
+// with those of SCIF apps grouped by app
+func printAll(attributes map[string]string) {
+	b, err := json.MarshalIndent(newInspectAllResult(attributes), "", "\t")
+	if err != nil {
+		sylog.Fatalf("While encoding metadata: %v", err)
+	}
+	fmt.Println(string(b))
+}
+
+// newInspectAllResult groups the attributes of SCIF apps by app
+func newInspectAllResult(attributes map[string]string) inspectAllResult {
+	d := inspectAllResult{
+		Type:       "container",
+		APIVersion: inspectAPIVersion,
+		Attributes: make(map[string]string),
+		Apps:       make(map[string]map[string]string),
+	}
+
+	for k, v := range attributes {
+		if !strings.HasPrefix(k, appPrefix) {
+			d.Attributes[k] = v
+			continue
+		}
+		split := strings.SplitN(strings.TrimPrefix(k, appPrefix), "/", 2)
+		if d.Apps[split[0]] == nil {
+			d.Apps[split[0]] = make(map[string]string)
+		}
+		if len(split) == 2 {
+			d.Apps[split[0]][split[1]] = v
+		}
+	}
+	return d
+}
+
+// inspectRemote returns the labels and environment of the image at u, an
+// OCI or docker reference, from its configuration without downloading its
+// layers. ok is false if other attributes or the data objects are
+// requested, if labels are edited, or if the configuration could not be
+// fetched.
+func inspectRemote(u string) (attributes map[string]string, ok bool) {
+	t, _ := uri.Split(u)
+	if ociclient.IsSupported(t) == "" || inspectApp != "" || listApps || listData {
+		return nil, false
+	}
+	if len(setLabels) != 0 || len(delLabels) != 0 {
+		return nil, false
+	}
+	if helpfile || deffile || runscript || testfile || testresults {
+		return nil, false
+	}
+
+	var sysCtx *ocitypes.SystemContext
+	if noHTTPS {
+		sysCtx = &ocitypes.SystemContext{
+			OCIInsecureSkipTLSVerify:    true,
+			DockerInsecureSkipTLSVerify: true,
+		}
+	}
+
+	config, err := ociclient.ImageConfig(context.TODO(), u, sysCtx, ociclient.DefaultPlatform())
+	if err != nil {
+		sylog.Debugf("Could not get configuration of %s: %v", u, err)
+		return nil, false
+	}
+
+	attributes = make(map[string]string)
+
+	if environment {
+		var env []string
+		for _, e := range config.Config.Env {
+			if parts := strings.SplitN(e, "=", 2); len(parts) == 2 {
+				env = append(env, "export "+parts[0]+"=\""+shell.Escape(parts[1])+"\"")
+			} else {
+				env = append(env, "export "+shell.Escape(e))
+			}
+		}
+		if len(env) != 0 {
+			attributes["environment"] = strings.Join(env, "\n")
+		} else {
+			sylog.Warningf("environment metadata was not found.")
+		}
+	}
+
+	if labels || !environment {
+		l := config.Config.Labels
+		if l == nil {
+			l = map[string]string{}
+		}
+		b, err := json.MarshalIndent(l, "", "\t")
+		if err != nil {
+			sylog.Fatalf("While encoding labels: %v", err)
+		}
+		attributes["labels"] = string(b)
+	}
+
+	return attributes, true
+}
+
+// inspectFile is a metadata file shown by inspect as attribute
+type inspectFile struct {
+	attribute string
+	path      string
+}
+
+// inspectSandbox reads the metadata files of the sandbox image at root into
+// attributes
+func inspectSandbox(root string, files []inspectFile, attributes map[string]string) error {
+	if inspectApp != "" {
+		apps, err := image.SandboxApps(root)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, app := range apps {
+			found = found || app == inspectApp
+		}
+		if !found {
+			return fmt.Errorf("no app %s found, available apps: %s", inspectApp, strings.Join(apps, ", "))
+		}
+	}
+
+	for _, f := range files {
+		content, err := image.ReadSandboxFile(root, f.path)
+		if os.IsNotExist(err) {
+			warnMissing(f.attribute)
+			continue
+		} else if err != nil {
+			return err
+		}
+		setAttribute(attributes, f.attribute, string(content))
+	}
+	return nil
+}
+
+// setAttribute sets attribute to content, empty content is skipped like the
+// starter output does
+func setAttribute(attributes map[string]string, attribute, content string) {
+	if c := strings.TrimSpace(content); c != "" {
+		attributes[attribute] = c
+	} else {
+		warnMissing(attribute)
+	}
+}
+
+// scriptAttribute returns the content of attribute from the scripts stored
+// in a SIF image, ok is false if they don't hold attribute
+func scriptAttribute(s metadata.Scripts, attribute string) (content string, ok bool) {
+	switch attribute {
+	case "helpfile":
+		return s.Helpfile, true
+	case "runscript":
+		return s.Runscript, true
+	case "test":
+		return s.Test, true
+	case "environment":
+		return s.Environment, true
+	case "labels":
+		// labels of the container are stored in the labels data object,
+		// already read if present
+		if len(s.Labels) == 0 {
+			return "", true
+		}
+		b, err := json.MarshalIndent(s.Labels, "", "\t")
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+	return "", false
+}
+
+// inspectSIF reads the metadata stored at build time in the SIF image at
+// path into attributes, with those of all apps if requested, and returns the
+// files that must still be read from the container
+func inspectSIF(path string, md *metadata.Metadata, files []inspectFile, attributes map[string]string) ([]inspectFile, error) {
+	scripts := md.Scripts
+	if inspectApp != "" {
+		var ok bool
+		if scripts, ok = md.Apps[inspectApp]; !ok {
+			var apps []string
+			for app := range md.Apps {
+				apps = append(apps, app)
+			}
+			sort.Strings(apps)
+			return nil, fmt.Errorf("no app %s found, available apps: %s", inspectApp, strings.Join(apps, ", "))
+		}
+	}
+
+	var remaining []inspectFile
+	for _, f := range files {
+		if f.attribute == "deffile" {
+			def, found, err := metadata.SIFDefinition(path)
+			if err != nil {
+				return nil, err
+			} else if !found {
+				remaining = append(remaining, f)
+				continue
+			}
+			setAttribute(attributes, f.attribute, def)
+			continue
+		}
+
+		content, ok := scriptAttribute(scripts, f.attribute)
+		if !ok {
+			remaining = append(remaining, f)
+			continue
+		}
+		setAttribute(attributes, f.attribute, content)
+	}
+
+	if inspectAll || listApps {
+		for app, s := range md.Apps {
+			attributes[appPrefix+app] = app
+			for _, f := range appFiles(app, app) {
+				content, _ := scriptAttribute(s, strings.TrimPrefix(f.attribute, appPrefix+app+"/"))
+				setAttribute(attributes, f.attribute, content)
+			}
+		}
+	}
+	return remaining, nil
+}
+
+// editLabels applies the --set-label and --delete-label options to the
+// labels of the SIF image at path, stored in a SIF data object as the
+// container file system is read-only
+func editLabels(path, name string) error {
+	if inspectApp != "" {
+		return fmt.Errorf("labels of apps can't be edited")
+	}
+
+	labels, found, err := metadata.LabelPartition(path)
+	if err != nil {
+		return fmt.Errorf("labels can only be edited in SIF images: %v", err)
+	}
+	if !found {
+		// start from the labels set at build time
+		content, err := getFileContent(path, name, []string{"/bin/sh", "-c", "cat .singularity.d/labels.json 2>/dev/null"})
+		if err != nil {
+			return err
+		}
+		labels = make(map[string]string)
+		if content = strings.TrimSpace(content); content != "" {
+			if err := json.Unmarshal([]byte(content), &labels); err != nil {
+				return fmt.Errorf("while decoding labels: %v", err)
+			}
+		}
+	}
+
+	for _, l := range setLabels {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid label %q, expected key=value", l)
+		}
+		labels[kv[0]] = kv[1]
+	}
+	for _, k := range delLabels {
+		if _, ok := labels[k]; !ok {
+			sylog.Warningf("Label %s not found", k)
+		}
+		delete(labels, k)
+	}
+
+	return metadata.AddLabelPartition(path, labels)
+}
+
+// sifTestReport returns the test report stored in a SIF image
+func sifTestReport(path string) (string, error) {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return "", err
+	}
+	defer fimg.UnloadContainer()
+
+	for _, desc := range fimg.DescrArr {
+		if desc.Used && desc.Datatype == sif.DataGenericJSON && desc.GetName() == types.TestReportObject {
+			return string(desc.GetData(&fimg)), nil
+		}
+	}
+	return "", fmt.Errorf("no test report found in %s", path)
+}
+
 func getFileContent(abspath, name string, args []string) (string, error) {
 	starter := buildcfg.LIBEXECDIR + "/singularity/bin/starter-suid"
 	procname := "Singularity inspect"
@@ -0,0 +1,125 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"text/template"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// inspectAPIVersion is the version of the JSON documents printed by inspect,
+// and of the data given to --format templates. It is increased when
+// attributes are renamed or removed.
+const inspectAPIVersion = "v1"
+
+// inspectAppData holds the metadata of a SCIF app given to --format
+// templates
+type inspectAppData struct {
+	Labels      map[string]string
+	Runscript   string
+	Test        string
+	Environment string
+	Helpfile    string
+}
+
+// inspectData is the data given to --format templates, e.g. '{{ .Labels.foo }}'
+// or '{{ range $name, $app := .Apps }}{{ $name }} {{ end }}'
+type inspectData struct {
+	Type       string
+	APIVersion string
+	// Labels are the decoded labels of the container
+	Labels      map[string]string
+	Deffile     string
+	Runscript   string
+	Test        string
+	Environment string
+	Helpfile    string
+	// TestResults is the report of the tests run at build time, nil if
+	// none is stored in the image
+	TestResults *types.TestReport
+	// Attributes holds the raw metadata of the container, by attribute, as
+	// shown by --json
+	Attributes map[string]string
+	Apps       map[string]inspectAppData
+}
+
+// parseInspectTemplate parses a --format template, the json function
+// formats its argument in JSON like with docker inspect
+func parseInspectTemplate(format string) (*template.Template, error) {
+	return template.New("format").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Option("missingkey=zero").Parse(format)
+}
+
+// decodeLabels decodes the labels.json content of an image, it returns nil
+// if there are no labels or if they can't be decoded
+func decodeLabels(content string) map[string]string {
+	if content == "" {
+		return nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(content), &labels); err != nil {
+		sylog.Warningf("Could not decode labels: %v", err)
+		return nil
+	}
+	return labels
+}
+
+// newInspectData returns the template data of the attributes gathered by
+// inspect
+func newInspectData(attributes map[string]string) inspectData {
+	r := newInspectAllResult(attributes)
+	a := r.Attributes
+
+	d := inspectData{
+		Type:        r.Type,
+		APIVersion:  r.APIVersion,
+		Labels:      decodeLabels(a["labels"]),
+		Deffile:     a["deffile"],
+		Runscript:   a["runscript"],
+		Test:        a["test"],
+		Environment: a["environment"],
+		Helpfile:    a["helpfile"],
+		Attributes:  a,
+		Apps:        make(map[string]inspectAppData),
+	}
+	if report := a["test-results"]; report != "" {
+		d.TestResults = &types.TestReport{}
+		if err := json.Unmarshal([]byte(report), d.TestResults); err != nil {
+			sylog.Warningf("Could not decode test results: %v", err)
+			d.TestResults = nil
+		}
+	}
+	for name, app := range r.Apps {
+		d.Apps[name] = inspectAppData{
+			Labels:      decodeLabels(app["labels"]),
+			Runscript:   app["runscript"],
+			Test:        app["test"],
+			Environment: app["environment"],
+			Helpfile:    app["helpfile"],
+		}
+	}
+	return d
+}
+
+// printTemplate executes tmpl with the attributes gathered by inspect and
+// writes the result to w
+func printTemplate(w io.Writer, tmpl *template.Template, attributes map[string]string) error {
+	if err := tmpl.Execute(w, newInspectData(attributes)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
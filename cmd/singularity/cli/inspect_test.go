@@ -0,0 +1,290 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+)
+
+func TestInspectRemoteSkipped(t *testing.T) {
+	defer func() {
+		listData, listApps, inspectApp = false, false, ""
+		setLabels, delLabels = nil, nil
+	}()
+
+	tests := []struct {
+		name  string
+		uri   string
+		setup func()
+	}{
+		{"library", "library://alpine", func() {}},
+		{"data objects", "docker://alpine", func() { listData = true }},
+		{"apps", "docker://alpine", func() { listApps = true }},
+		{"app", "oci://alpine", func() { inspectApp = "foo" }},
+		{"set label", "docker://alpine", func() { setLabels = []string{"a=b"} }},
+		{"delete label", "oci://alpine", func() { delLabels = []string{"a"} }},
+	}
+	for _, tt := range tests {
+		listData, listApps, inspectApp = false, false, ""
+		setLabels, delLabels = nil, nil
+		tt.setup()
+		// skipped requests return before contacting the registry
+		if _, ok := inspectRemote(tt.uri); ok {
+			t.Errorf("%s: registry metadata used", tt.name)
+		}
+	}
+}
+
+func TestInspectTemplate(t *testing.T) {
+	attributes := map[string]string{
+		"labels":               `{"maintainer":"me"}`,
+		"runscript":            "#!/bin/sh",
+		"test-results":         `{"passed":true}`,
+		"apps/foo/labels":      `{"app":"foo"}`,
+		"apps/foo/runscript":   "foo",
+		"apps/bar/helpfile":    "bar help",
+		"apps/bar/labels":      "not json",
+		"apps/foo/environment": "FOO=1",
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		output string
+		ok     bool
+	}{
+		{"label", "{{ .Labels.maintainer }}", "me\n", true},
+		{"missing label", "{{ .Labels.missing }}", "\n", true},
+		{"missing attribute", `{{ index .Attributes "missing" }}`, "\n", true},
+		{"test results", "{{ .TestResults.Passed }}", "true\n", true},
+		{"apps", "{{ range $name, $app := .Apps }}{{ $name }}:{{ $app.Runscript }}{{ $app.Helpfile }} {{ end }}", "bar:bar help foo:foo \n", true},
+		{"app label", "{{ (index .Apps \"foo\").Labels.app }}", "foo\n", true},
+		{"undecodable app labels", "{{ len (index .Apps \"bar\").Labels }}", "0\n", true},
+		{"json", "{{ json .Labels }}", "{\"maintainer\":\"me\"}\n", true},
+		{"version", "{{ .Type }} {{ .APIVersion }}", "container " + inspectAPIVersion + "\n", true},
+		{"unclosed action", "{{ .Labels", "", false},
+		{"unknown function", "{{ yaml .Labels }}", "", false},
+		{"unknown field", "{{ .Missing }}", "", false},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseInspectTemplate(tt.format)
+		if err == nil {
+			var b bytes.Buffer
+			err = printTemplate(&b, tmpl, attributes)
+			if err == nil && b.String() != tt.output {
+				t.Errorf("%s: got %q, want %q", tt.name, b.String(), tt.output)
+			}
+		}
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		}
+	}
+}
+
+func TestNewInspectAllResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		global     map[string]string
+		apps       map[string]map[string]string
+	}{
+		{
+			"no apps",
+			map[string]string{"runscript": "run", "labels": "{}"},
+			map[string]string{"runscript": "run", "labels": "{}"},
+			map[string]map[string]string{},
+		},
+		{
+			"apps",
+			map[string]string{
+				"runscript":          "run",
+				"apps/foo/runscript": "foo",
+				"apps/foo/labels":    "{}",
+				"apps/bar/helpfile":  "bar",
+			},
+			map[string]string{"runscript": "run"},
+			map[string]map[string]string{
+				"foo": {"runscript": "foo", "labels": "{}"},
+				"bar": {"helpfile": "bar"},
+			},
+		},
+		{
+			"app without attribute",
+			map[string]string{"apps/foo": ""},
+			map[string]string{},
+			map[string]map[string]string{"foo": {}},
+		},
+		{
+			"nested attribute",
+			map[string]string{"apps/foo/scif/env": "x"},
+			map[string]string{},
+			map[string]map[string]string{"foo": {"scif/env": "x"}},
+		},
+	}
+	for _, tt := range tests {
+		r := newInspectAllResult(tt.attributes)
+		if r.Type != "container" || r.APIVersion != inspectAPIVersion {
+			t.Errorf("%s: unexpected type %q or version %q", tt.name, r.Type, r.APIVersion)
+		}
+		if !reflect.DeepEqual(r.Attributes, tt.global) {
+			t.Errorf("%s: got attributes %v, want %v", tt.name, r.Attributes, tt.global)
+		}
+		if !reflect.DeepEqual(r.Apps, tt.apps) {
+			t.Errorf("%s: got apps %v, want %v", tt.name, r.Apps, tt.apps)
+		}
+	}
+}
+
+func TestCheckInspectFlags(t *testing.T) {
+	defer func() {
+		inspectAll, listApps, inspectApp = false, false, ""
+	}()
+
+	tests := []struct {
+		name string
+		all  bool
+		list bool
+		app  string
+		err  string
+	}{
+		{"no flags", false, false, "", ""},
+		{"all", true, false, "", ""},
+		{"list apps", false, true, "", ""},
+		{"app", false, false, "foo", ""},
+		{"all and app", true, false, "foo", "--all, --format and --app"},
+		{"list apps and app", false, true, "foo", "--list-apps and --app"},
+	}
+	for _, tt := range tests {
+		inspectAll, listApps, inspectApp = tt.all, tt.list, tt.app
+		err := checkInspectFlags()
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got error %v, want error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestAppFiles(t *testing.T) {
+	files := appFiles("foo", "foo")
+	want := map[string]string{
+		"apps/foo/helpfile":    "/scif/apps/foo/scif/runscript.help",
+		"apps/foo/runscript":   "/scif/apps/foo/scif/runscript",
+		"apps/foo/test":        "/scif/apps/foo/scif/test",
+		"apps/foo/environment": "/scif/apps/foo/scif/env/90-environment.sh",
+		"apps/foo/labels":      "/scif/apps/foo/scif/labels.json",
+	}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	for _, f := range files {
+		if path, ok := want[f.attribute]; !ok || path != f.path {
+			t.Errorf("unexpected file %s for attribute %s", f.path, f.attribute)
+		}
+	}
+}
+
+func TestInspectSIF(t *testing.T) {
+	defer func() {
+		inspectAll, listApps, inspectApp = false, false, ""
+	}()
+
+	md := &metadata.Metadata{
+		Scripts: metadata.Scripts{Runscript: "run", Helpfile: "help"},
+		Apps: map[string]metadata.Scripts{
+			"foo": {Runscript: "foo", Labels: map[string]string{"app": "foo"}},
+			"bar": {Test: "bar test"},
+		},
+	}
+	files := []inspectFile{
+		{"runscript", "/.singularity.d/runscript"},
+		{"helpfile", "/.singularity.d/runscript.help"},
+		{"test", "/.singularity.d/test"},
+		{"other", "/.singularity.d/other"},
+	}
+
+	tests := []struct {
+		name       string
+		all        bool
+		list       bool
+		app        string
+		attributes map[string]string
+		ok         bool
+	}{
+		{
+			name:       "container",
+			attributes: map[string]string{"runscript": "run", "helpfile": "help"},
+			ok:         true,
+		},
+		{
+			name:       "app",
+			app:        "bar",
+			attributes: map[string]string{"test": "bar test"},
+			ok:         true,
+		},
+		{
+			name: "missing app",
+			app:  "baz",
+		},
+		{
+			name: "all",
+			all:  true,
+			attributes: map[string]string{
+				"runscript":          "run",
+				"helpfile":           "help",
+				"apps/foo":           "foo",
+				"apps/foo/runscript": "foo",
+				"apps/foo/labels":    "{\n\t\"app\": \"foo\"\n}",
+				"apps/bar":           "bar",
+				"apps/bar/test":      "bar test",
+			},
+			ok: true,
+		},
+		{
+			name: "list apps",
+			list: true,
+			attributes: map[string]string{
+				"runscript":          "run",
+				"helpfile":           "help",
+				"apps/foo":           "foo",
+				"apps/foo/runscript": "foo",
+				"apps/foo/labels":    "{\n\t\"app\": \"foo\"\n}",
+				"apps/bar":           "bar",
+				"apps/bar/test":      "bar test",
+			},
+			ok: true,
+		},
+	}
+	for _, tt := range tests {
+		inspectAll, listApps, inspectApp = tt.all, tt.list, tt.app
+		attributes := make(map[string]string)
+		remaining, err := inspectSIF("", md, files, attributes)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: unexpected success", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(attributes, tt.attributes) {
+			t.Errorf("%s: got attributes %v, want %v", tt.name, attributes, tt.attributes)
+		}
+		// attributes not stored in the image are read from the container
+		if len(remaining) != 1 || remaining[0].attribute != "other" {
+			t.Errorf("%s: unexpected remaining files %v", tt.name, remaining)
+		}
+	}
+}
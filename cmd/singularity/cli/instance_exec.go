@@ -0,0 +1,89 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	// only options applying to a process joining an instance, the others
+	// are set when the instance is started
+	options := []string{
+		"add-caps",
+		"app",
+		"cleanenv",
+		"drop-caps",
+		"env-exclude",
+		"env-filter",
+		"keep-privs",
+		"no-privs",
+		"pwd",
+		"security",
+	}
+
+	for _, cmd := range []*cobra.Command{InstanceExecCmd, InstanceShellCmd} {
+		for _, opt := range options {
+			cmd.Flags().AddFlag(actionFlags.Lookup(opt))
+		}
+		cmd.Flags().SetInterspersed(false)
+	}
+	InstanceShellCmd.Flags().AddFlag(actionFlags.Lookup("shell"))
+
+	InstanceCmd.AddCommand(InstanceExecCmd)
+	InstanceCmd.AddCommand(InstanceShellCmd)
+}
+
+// instanceImage returns the instance:// image of the running instance name
+func instanceImage(name string) (string, error) {
+	if _, err := instance.Get(name); err != nil {
+		return "", err
+	}
+	return "instance://" + name, nil
+}
+
+// InstanceExecCmd singularity instance exec
+var InstanceExecCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		image, err := instanceImage(args[0])
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		a := append([]string{"/.singularity.d/actions/exec"}, args[1:]...)
+		execStarter(cmd, image, a, "")
+	},
+
+	Use:     docs.InstanceExecUse,
+	Short:   docs.InstanceExecShort,
+	Long:    docs.InstanceExecLong,
+	Example: docs.InstanceExecExample,
+}
+
+// InstanceShellCmd singularity instance shell
+var InstanceShellCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		image, err := instanceImage(args[0])
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		a := []string{"/.singularity.d/actions/shell"}
+		execStarter(cmd, image, a, "")
+	},
+
+	Use:     docs.InstanceShellUse,
+	Short:   docs.InstanceShellShort,
+	Long:    docs.InstanceShellLong,
+	Example: docs.InstanceShellExample,
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstanceImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance string
+	}{
+		{"missing instance", "missing-instance"},
+		{"invalid name", "../instance"},
+		{"empty name", ""},
+	}
+	for _, tt := range tests {
+		if image, err := instanceImage(tt.instance); err == nil {
+			t.Errorf("%s: unexpected success with image %s", tt.name, image)
+		}
+	}
+}
+
+func TestInstanceExecFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		exec  bool
+		shell bool
+	}{
+		{"app", "app", true, true},
+		{"pwd", "pwd", true, true},
+		{"capabilities", "add-caps", true, true},
+		{"shell", "shell", false, true},
+		// set when the instance is started
+		{"bind", "bind", false, false},
+		{"contain", "contain", false, false},
+		{"overlay", "overlay", false, false},
+	}
+	for _, tt := range tests {
+		if found := InstanceExecCmd.Flags().Lookup(tt.flag) != nil; found != tt.exec {
+			t.Errorf("%s: instance exec has flag %s: %v, want %v", tt.name, tt.flag, found, tt.exec)
+		}
+		if found := InstanceShellCmd.Flags().Lookup(tt.flag) != nil; found != tt.shell {
+			t.Errorf("%s: instance shell has flag %s: %v, want %v", tt.name, tt.flag, found, tt.shell)
+		}
+	}
+}
+
+func TestInstanceExecArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		exec  bool
+		shell bool
+	}{
+		{"no arguments", nil, false, false},
+		{"instance", []string{"foo"}, false, true},
+		{"command", []string{"foo", "ls"}, true, false},
+		{"command arguments", []string{"foo", "ls", "-l"}, true, false},
+	}
+	for _, tt := range tests {
+		if err := InstanceExecCmd.Args(InstanceExecCmd, tt.args); (err == nil) != tt.exec {
+			t.Errorf("%s: instance exec arguments accepted: %v, want %v", tt.name, err == nil, tt.exec)
+		}
+		if err := InstanceShellCmd.Args(InstanceShellCmd, tt.args); (err == nil) != tt.shell {
+			t.Errorf("%s: instance shell arguments accepted: %v, want %v", tt.name, err == nil, tt.shell)
+		}
+	}
+
+	// options following the instance name belong to the command
+	args := []string{"--pwd", "/tmp", "foo", "ls", "--pwd", "-l"}
+	flags := InstanceExecCmd.Flags()
+	defer flags.Set("pwd", "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"foo", "ls", "--pwd", "-l"}; !reflect.DeepEqual(flags.Args(), want) {
+		t.Errorf("got arguments %v, want %v", flags.Args(), want)
+	}
+	if pwd := flags.Lookup("pwd").Value.String(); pwd != "/tmp" {
+		t.Errorf("got working directory %q, want /tmp", pwd)
+	}
+}
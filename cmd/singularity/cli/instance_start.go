@@ -25,10 +25,13 @@ func init() {
 		"cleanenv",
 		"dns",
 		"drop-caps",
+		"env-exclude",
+		"env-filter",
 		"fakeroot",
 		"home",
 		"hostname",
 		"keep-privs",
+		"keyfile",
 		"net",
 		"network",
 		"network-args",
@@ -0,0 +1,37 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// contains flag variables for overlay commands
+var (
+	OverlaySize string
+	OverlayDirs []string
+	OverlaySIF  bool
+)
+
+func init() {
+	SingularityCmd.AddCommand(OverlayCmd)
+	OverlayCmd.AddCommand(OverlayCreateCmd)
+	OverlayCmd.AddCommand(OverlayResizeCmd)
+}
+
+// OverlayCmd is the overlay command
+var OverlayCmd = &cobra.Command{
+	Run:                   nil,
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.OverlayUse,
+	Short:   docs.OverlayShort,
+	Long:    docs.OverlayLong,
+	Example: docs.OverlayExample,
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/overlay"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	// -s|--size
+	OverlayCreateCmd.Flags().StringVarP(&OverlaySize, "size", "s", "", "size of the overlay (e.g. 512M, 4G)")
+	OverlayCreateCmd.Flags().SetAnnotation("size", "argtag", []string{"<size>"})
+	OverlayCreateCmd.Flags().SetAnnotation("size", "envkey", []string{"SIZE"})
+
+	// --dirs
+	OverlayCreateCmd.Flags().StringSliceVar(&OverlayDirs, "dirs", []string{}, "directories to create in the overlay (e.g. /data,/scratch)")
+	OverlayCreateCmd.Flags().SetAnnotation("dirs", "argtag", []string{"<path>"})
+
+	// --sif
+	OverlayCreateCmd.Flags().BoolVar(&OverlaySIF, "sif", false, "add the overlay as a partition of an existing SIF image")
+
+	OverlayCreateCmd.Flags().SetInterspersed(false)
+}
+
+// OverlayCreateCmd singularity overlay create
+var OverlayCreateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if OverlaySize == "" {
+			sylog.Fatalf("you must specify the overlay size with --size")
+		}
+		size, err := overlay.ParseSize(OverlaySize)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		if OverlaySIF {
+			if err := overlay.AddToSIF(args[0], size, OverlayDirs); err != nil {
+				sylog.Fatalf("failed to add overlay: %s", err)
+			}
+			sylog.Infof("Overlay partition of %s added to %s", OverlaySize, args[0])
+			return
+		}
+		if err := overlay.Create(args[0], size, OverlayDirs); err != nil {
+			sylog.Fatalf("failed to create overlay: %s", err)
+		}
+		sylog.Infof("Overlay %s of %s created", args[0], OverlaySize)
+	},
+
+	Use:     docs.OverlayCreateUse,
+	Short:   docs.OverlayCreateShort,
+	Long:    docs.OverlayCreateLong,
+	Example: docs.OverlayCreateExample,
+}
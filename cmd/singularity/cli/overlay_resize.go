@@ -0,0 +1,48 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/overlay"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	// -s|--size
+	OverlayResizeCmd.Flags().StringVarP(&OverlaySize, "size", "s", "", "new size of the overlay (e.g. 512M, 4G)")
+	OverlayResizeCmd.Flags().SetAnnotation("size", "argtag", []string{"<size>"})
+	OverlayResizeCmd.Flags().SetAnnotation("size", "envkey", []string{"SIZE"})
+
+	OverlayResizeCmd.Flags().SetInterspersed(false)
+}
+
+// OverlayResizeCmd singularity overlay resize
+var OverlayResizeCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if OverlaySize == "" {
+			sylog.Fatalf("you must specify the new overlay size with --size")
+		}
+		size, err := overlay.ParseSize(OverlaySize)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		if err := overlay.Resize(args[0], size); err != nil {
+			sylog.Fatalf("failed to resize overlay: %s", err)
+		}
+		sylog.Infof("Overlay of %s resized to %s", args[0], OverlaySize)
+	},
+
+	Use:     docs.OverlayResizeUse,
+	Short:   docs.OverlayResizeShort,
+	Long:    docs.OverlayResizeLong,
+	Example: docs.OverlayResizeExample,
+}
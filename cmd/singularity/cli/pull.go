@@ -50,6 +50,14 @@ func init() {
 	PullCmd.Flags().BoolVar(&noHTTPS, "nohttps", false, "do NOT use HTTPS, for communicating with local docker registry")
 	PullCmd.Flags().SetAnnotation("nohttps", "envkey", []string{"NOHTTPS"})
 
+	PullCmd.Flags().StringVar(&platform, "platform", "", "select the image for this os/arch[/variant] from docker and OCI manifest lists (default: host platform)")
+	PullCmd.Flags().SetAnnotation("platform", "argtag", []string{"<os/arch[/variant]>"})
+	PullCmd.Flags().SetAnnotation("platform", "envkey", []string{"PLATFORM"})
+
+	PullCmd.Flags().StringVar(&whiteout, "whiteout", "remove", "how to handle whiteouts of docker and OCI layers (remove, overlay, error)")
+	PullCmd.Flags().SetAnnotation("whiteout", "argtag", []string{"<mode>"})
+	PullCmd.Flags().SetAnnotation("whiteout", "envkey", []string{"WHITEOUT"})
+
 	SingularityCmd.AddCommand(PullCmd)
 }
 
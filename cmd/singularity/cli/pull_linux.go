@@ -39,9 +39,11 @@ func pullRun(cmd *cobra.Command, args []string) {
 		libexec.PullNetImage(name, args[i], force)
 	default:
 		libexec.PullOciImage(name, args[i], types.Options{
-			TmpDir:  tmpDir,
-			Force:   force,
-			NoHTTPS: noHTTPS,
+			TmpDir:   tmpDir,
+			Force:    force,
+			NoHTTPS:  noHTTPS,
+			Platform: platform,
+			Whiteout: whiteout,
 		})
 	}
 }
@@ -15,6 +15,8 @@ import (
 var (
 	// PushLibraryURI holds the base URI to a Sylabs library API instance
 	PushLibraryURI string
+	// PushWorkers holds the number of parts uploaded concurrently
+	PushWorkers int
 )
 
 func init() {
@@ -23,6 +25,9 @@ func init() {
 	PushCmd.Flags().StringVar(&PushLibraryURI, "library", "https://library.sylabs.io", "the library to push to")
 	PushCmd.Flags().SetAnnotation("library", "envkey", []string{"LIBRARY"})
 
+	PushCmd.Flags().IntVar(&PushWorkers, "parallel", client.DefaultUploadWorkers, "number of image parts uploaded concurrently")
+	PushCmd.Flags().SetAnnotation("parallel", "envkey", []string{"PUSH_PARALLEL"})
+
 	SingularityCmd.AddCommand(PushCmd)
 }
 
@@ -34,7 +39,7 @@ var PushCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		// Push to library requires a valid authToken
 		if authToken != "" {
-			err := client.UploadImage(args[0], args[1], PushLibraryURI, authToken, "No Description")
+			err := client.UploadImage(args[0], args[1], PushLibraryURI, authToken, "No Description", PushWorkers)
 			if err != nil {
 				sylog.Fatalf("%v\n", err)
 			}
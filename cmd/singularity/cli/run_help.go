@@ -19,6 +19,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/oci"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/telemetry"
 	"github.com/sylabs/singularity/internal/pkg/util/exec"
 	"github.com/sylabs/singularity/src/docs"
 )
@@ -67,6 +68,8 @@ var RunHelpCmd = &cobra.Command{
 			sylog.Fatalf("CLI Failed to marshal CommonEngineConfig: %s\n", err)
 		}
 
+		// the CLI process is replaced by the container
+		telemetry.Flush(nil)
 		if err := exec.Pipe(starter, []string{procname}, Env, configData); err != nil {
 			sylog.Fatalf("%s", err)
 		}
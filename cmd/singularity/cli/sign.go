@@ -16,7 +16,10 @@ import (
 )
 
 var (
-	privKey int // -k encryption key (index from 'keys list') specification
+	privKey     int    // -k encryption key (index from 'keys list') specification
+	pkcs11URI   string // --pkcs11-uri hardware token key specification
+	sigstore    bool   // --sigstore use cosign-compatible signatures
+	sigstoreKey string // --sigstore-key PEM key for cosign-compatible signatures
 )
 
 func init() {
@@ -27,6 +30,14 @@ func init() {
 	SignCmd.Flags().Uint32VarP(&sifGroupID, "groupid", "g", 0, "group ID to be signed")
 	SignCmd.Flags().Uint32VarP(&sifDescID, "id", "i", 0, "descriptor ID to be signed")
 	SignCmd.Flags().IntVarP(&privKey, "keyidx", "k", -1, "private key to use (index from 'keys list')")
+	SignCmd.Flags().StringVar(&pkcs11URI, "pkcs11-uri", "", "sign with a key held on a PKCS#11 token")
+	SignCmd.Flags().SetAnnotation("pkcs11-uri", "argtag", []string{"<uri>"})
+	SignCmd.Flags().SetAnnotation("pkcs11-uri", "envkey", []string{"PKCS11_URI"})
+	SignCmd.Flags().BoolVar(&sigstore, "sigstore", false, "create a cosign-compatible signature instead of an OpenPGP one")
+	SignCmd.Flags().SetAnnotation("sigstore", "envkey", []string{"SIGSTORE"})
+	SignCmd.Flags().StringVar(&sigstoreKey, "sigstore-key", "", "PEM private key used with --sigstore")
+	SignCmd.Flags().SetAnnotation("sigstore-key", "argtag", []string{"<path>"})
+	SignCmd.Flags().SetAnnotation("sigstore-key", "envkey", []string{"SIGSTORE_KEY"})
 
 	SingularityCmd.AddCommand(SignCmd)
 }
@@ -67,5 +78,17 @@ func doSignCmd(cpath, url string) error {
 		id = sifDescID
 	}
 
+	if sigstore {
+		if sigstoreKey == "" {
+			return fmt.Errorf("keyless signing is not supported, --sigstore requires --sigstore-key")
+		}
+		return signing.SignSigstore(cpath, sigstoreKey, id, isGroup)
+	}
+	if pkcs11URI != "" {
+		if privKey != -1 {
+			return fmt.Errorf("only one of -k or --pkcs11-uri may be set")
+		}
+		return signing.SignWithToken(cpath, pkcs11URI, id, isGroup)
+	}
 	return signing.Sign(cpath, url, id, isGroup, privKey, authToken)
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/telemetry"
 	"github.com/sylabs/singularity/internal/pkg/util/auth"
 	"github.com/sylabs/singularity/src/docs"
 )
@@ -27,6 +28,10 @@ var (
 	silent  bool
 	verbose bool
 	quiet   bool
+
+	logFormat string
+
+	versionJSON bool
 )
 
 var (
@@ -62,9 +67,13 @@ func init() {
 	SingularityCmd.Flags().BoolVarP(&silent, "silent", "s", false, "only print errors")
 	SingularityCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "suppress normal output")
 	SingularityCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print additional information")
+	SingularityCmd.Flags().StringVar(&logFormat, "log-format", sylog.FormatText, "format of log messages (text or json)")
+	SingularityCmd.Flags().BoolVar(&noDefaults, "no-defaults", false, "ignore flag values set in defaults files")
 	SingularityCmd.Flags().StringVarP(&tokenFile, "tokenfile", "t", defaultTokenFile, "path to the file holding your sylabs authentication token")
 
 	VersionCmd.Flags().SetInterspersed(false)
+	VersionCmd.Flags().BoolVarP(&versionJSON, "json", "j", false, "print version and available features in json")
+	VersionCmd.Flags().SetAnnotation("json", "envkey", []string{"JSON"})
 	SingularityCmd.AddCommand(VersionCmd)
 }
 
@@ -84,6 +93,10 @@ func setSylogMessageLevel(cmd *cobra.Command, args []string) {
 	}
 
 	sylog.SetLevel(level)
+
+	if err := sylog.SetFormat(logFormat); err != nil {
+		sylog.Fatalf("%s", err)
+	}
 }
 
 // SingularityCmd is the base command when called without any subcommands
@@ -111,7 +124,9 @@ func ExecuteSingularity() {
 	os.Setenv("USER_PATH", userEnv)
 
 	os.Setenv("PATH", defaultEnv)
-	if err := SingularityCmd.Execute(); err != nil {
+	err := SingularityCmd.Execute()
+	telemetry.Flush(err)
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -129,6 +144,10 @@ func TraverseParentsUses(cmd *cobra.Command) string {
 var VersionCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
+		if versionJSON {
+			printVersionJSON()
+			return
+		}
 		fmt.Println(buildcfg.PACKAGE_VERSION)
 	},
 
@@ -161,6 +180,27 @@ func handleEnv(flag *pflag.Flag) {
 func persistentPreRun(cmd *cobra.Command, args []string) {
 	setSylogMessageLevel(cmd, args)
 	updateFlagsFromEnv(cmd)
+	updateFlagsFromDefaults(cmd)
+	sendTelemetry(cmd, args)
+}
+
+// sendTelemetry records the command invocation when telemetry is enabled,
+// it is sent once with its failure, if any, when the command exits
+func sendTelemetry(cmd *cobra.Command, args []string) {
+	if telemetry.Endpoint() == "" {
+		return
+	}
+	telemetry.Record(telemetry.Event{
+		Command: commandPath(cmd),
+		Source:  telemetry.SourceType(args),
+	})
+
+	sylog.SetFatalHook(func(err error) {
+		if err == nil {
+			err = fmt.Errorf("fatal error")
+		}
+		telemetry.Flush(err)
+	})
 }
 
 // sylabsToken process the authentication Token
@@ -234,7 +274,10 @@ var flagEnvFuncs = map[string]envHandle{
 	"containlibs":   envStringNSlice,
 	"security":      envStringNSlice,
 	"apply-cgroups": envStringNSlice,
+	"keyfile":       envStringNSlice,
 	"app":           envStringNSlice,
+	"env-filter":    envStringNSlice,
+	"env-exclude":   envStringNSlice,
 
 	"boot":           envBool,
 	"fakeroot":       envBool,
@@ -276,6 +319,40 @@ var flagEnvFuncs = map[string]envHandle{
 	"tmpdir":   envStringNSlice,
 	"nohttps":  envBool,
 
+	"tmpdir-max-size": envStringNSlice,
+
+	"require-gpg": envBool,
+	"resume":      envBool,
+	"no-cache":    envBool,
+	"format":      envStringNSlice,
+	"compression": envStringNSlice,
+
+	"scan":          envStringNSlice,
+	"scan-severity": envStringNSlice,
+	"scan-url":      envStringNSlice,
+
+	"sign":     envBool,
+	"sign-key": envStringNSlice,
+
+	"json-report":   envStringNSlice,
+	"json-progress": envBool,
+	"test-report":   envStringNSlice,
+	"timings":       envBool,
+	"platform":      envStringNSlice,
+	"whiteout":      envStringNSlice,
+	"build-arg":     envStringNSlice,
+
+	"oci-annotations": envBool,
+
+	// build jobs flags
+	"follow": envBool,
+
+	// cache verify and build flags
+	"dry-run": envBool,
+
+	// push flags
+	"parallel": envStringNSlice,
+
 	// capability flags (and others)
 	"user":  envStringNSlice,
 	"group": envStringNSlice,
@@ -285,15 +362,35 @@ var flagEnvFuncs = map[string]envHandle{
 	// instance flags
 	"signal": envStringNSlice,
 
+	// overlay flags
+	"size": envStringNSlice,
+
+	// store flags
+	"dir": envStringNSlice,
+
 	// keys flags
 	"secret": envBool,
 	"url":    envStringNSlice,
 
+	// sign/verify flags
+	"pkcs11-uri":   envStringNSlice,
+	"sigstore":     envBool,
+	"sigstore-key": envStringNSlice,
+	"content":      envBool,
+
 	// inspect flags
-	"labels":      envBool,
-	"deffile":     envBool,
-	"runscript":   envBool,
-	"test":        envBool,
-	"environment": envBool,
-	"helpfile":    envBool,
+	"labels":       envBool,
+	"deffile":      envBool,
+	"runscript":    envBool,
+	"test":         envBool,
+	"test-results": envBool,
+	"environment":  envBool,
+	"helpfile":     envBool,
+	"list-apps":    envBool,
+	"list-data":    envBool,
+	"set-label":    envAppend,
+	"delete-label": envAppend,
+
+	// diff flags
+	"files": envBool,
 }
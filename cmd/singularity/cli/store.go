@@ -0,0 +1,45 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// contains flag variables for store commands
+var (
+	StoreDir string
+)
+
+func init() {
+	SingularityCmd.AddCommand(StoreCmd)
+	StoreCmd.AddCommand(StoreAddCmd)
+	StoreCmd.AddCommand(StoreExportCmd)
+}
+
+// StoreCmd is the store command
+var StoreCmd = &cobra.Command{
+	Run:                   nil,
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.StoreUse,
+	Short:   docs.StoreShort,
+	Long:    docs.StoreLong,
+	Example: docs.StoreExample,
+}
+
+// storeRoot returns the store directory to use, the default store is
+// located in the cache directory
+func storeRoot() string {
+	if StoreDir != "" {
+		return StoreDir
+	}
+	return cache.Store()
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/store"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	// --dir
+	StoreAddCmd.Flags().StringVar(&StoreDir, "dir", "", "path of the image store (default in the cache directory)")
+	StoreAddCmd.Flags().SetAnnotation("dir", "argtag", []string{"<path>"})
+	StoreAddCmd.Flags().SetAnnotation("dir", "envkey", []string{"STOREDIR"})
+
+	StoreAddCmd.Flags().SetInterspersed(false)
+}
+
+// StoreAddCmd singularity store add
+var StoreAddCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := store.New(storeRoot())
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		for _, path := range args {
+			m, err := s.Add(path)
+			if err != nil {
+				sylog.Fatalf("failed to add %s to store: %s", path, err)
+			}
+			if err := store.WriteManifest(m, path); err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			sylog.Infof("Image %s added to store %s", path, s.Root)
+		}
+	},
+
+	Use:     docs.StoreAddUse,
+	Short:   docs.StoreAddShort,
+	Long:    docs.StoreAddLong,
+	Example: docs.StoreAddExample,
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/store"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+// StoreExportCmd singularity store export
+var StoreExportCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := store.ReadManifest(args[0])
+		if err != nil {
+			sylog.Fatalf("failed to read manifest %s: %s", args[0], err)
+		}
+		if err := store.Export(m, args[1]); err != nil {
+			sylog.Fatalf("failed to export %s: %s", args[0], err)
+		}
+	},
+
+	Use:     docs.StoreExportUse,
+	Short:   docs.StoreExportShort,
+	Long:    docs.StoreExportLong,
+	Example: docs.StoreExportExample,
+}
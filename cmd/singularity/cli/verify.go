@@ -16,8 +16,9 @@ import (
 )
 
 var (
-	sifGroupID uint32 // -g groupid specification
-	sifDescID  uint32 // -i id specification
+	sifGroupID   uint32 // -g groupid specification
+	sifDescID    uint32 // -i id specification
+	checkContent bool   // --content verification of the files
 )
 
 func init() {
@@ -27,6 +28,16 @@ func init() {
 	VerifyCmd.Flags().SetAnnotation("url", "envkey", []string{"URL"})
 	VerifyCmd.Flags().Uint32VarP(&sifGroupID, "groupid", "g", 0, "group ID to be verified")
 	VerifyCmd.Flags().Uint32VarP(&sifDescID, "id", "i", 0, "descriptor ID to be verified")
+	VerifyCmd.Flags().StringVar(&pkcs11URI, "pkcs11-uri", "", "verify against the public key held on a PKCS#11 token")
+	VerifyCmd.Flags().SetAnnotation("pkcs11-uri", "argtag", []string{"<uri>"})
+	VerifyCmd.Flags().SetAnnotation("pkcs11-uri", "envkey", []string{"PKCS11_URI"})
+	VerifyCmd.Flags().BoolVar(&sigstore, "sigstore", false, "verify cosign-compatible signatures instead of OpenPGP ones")
+	VerifyCmd.Flags().SetAnnotation("sigstore", "envkey", []string{"SIGSTORE"})
+	VerifyCmd.Flags().StringVar(&sigstoreKey, "sigstore-key", "", "PEM public key used with --sigstore")
+	VerifyCmd.Flags().SetAnnotation("sigstore-key", "argtag", []string{"<path>"})
+	VerifyCmd.Flags().SetAnnotation("sigstore-key", "envkey", []string{"SIGSTORE_KEY"})
+	VerifyCmd.Flags().BoolVar(&checkContent, "content", false, "verify the files of the image against the content manifest recorded at build time")
+	VerifyCmd.Flags().SetAnnotation("content", "envkey", []string{"CONTENT"})
 	SingularityCmd.AddCommand(VerifyCmd)
 }
 
@@ -39,6 +50,13 @@ var VerifyCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		// args[0] contains image path
 		fmt.Printf("Verifying image: %s\n", args[0])
+		if checkContent {
+			if err := doVerifyContent(args[0]); err != nil {
+				sylog.Errorf("content verification failed: %s", err)
+				os.Exit(2)
+			}
+			return
+		}
 		if err := doVerifyCmd(args[0], keyServerURL); err != nil {
 			sylog.Errorf("verification failed: %s", err)
 			os.Exit(2)
@@ -65,5 +83,14 @@ func doVerifyCmd(cpath, url string) error {
 		id = sifDescID
 	}
 
+	if sigstore {
+		if sigstoreKey == "" {
+			return fmt.Errorf("keyless verification is not supported, --sigstore requires --sigstore-key")
+		}
+		return signing.VerifySigstore(cpath, sigstoreKey, id, isGroup)
+	}
+	if pkcs11URI != "" {
+		return signing.VerifyWithToken(cpath, pkcs11URI, id, isGroup)
+	}
 	return signing.Verify(cpath, url, id, isGroup, authToken)
 }
@@ -0,0 +1,15 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+)
+
+// doVerifyContent is not supported as images can't be mounted on darwin
+func doVerifyContent(cpath string) error {
+	return fmt.Errorf("content verification is only supported on linux")
+}
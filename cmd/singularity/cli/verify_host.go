@@ -0,0 +1,61 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/hostcheck"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/src/docs"
+)
+
+func init() {
+	SingularityCmd.AddCommand(VerifyHostCmd)
+}
+
+// VerifyHostCmd singularity verify-host
+var VerifyHostCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		engineConfig := singularity.NewConfig()
+		configurationFile := buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
+		if err := config.Parser(configurationFile, engineConfig.File); err != nil {
+			sylog.Warningf("Unable to parse singularity.conf file, using defaults: %s", err)
+			config.Parser("", engineConfig.File)
+		}
+
+		failed := false
+		for _, r := range hostcheck.Run(hostcheck.Config{
+			MaxLoopDevices: engineConfig.File.MaxLoopDevices,
+			AllowSetuid:    engineConfig.File.AllowSetuid,
+		}) {
+			fmt.Printf("%-4s %-20s %s\n", r.Status, r.Name, r.Detail)
+			if r.Hint != "" {
+				fmt.Printf("     %-20s hint: %s\n", "", r.Hint)
+			}
+			if r.Status == hostcheck.Fail {
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+
+	Use:     docs.VerifyHostUse,
+	Short:   docs.VerifyHostShort,
+	Long:    docs.VerifyHostLong,
+	Example: docs.VerifyHostExample,
+}
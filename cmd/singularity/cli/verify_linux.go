@@ -0,0 +1,65 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/imgmount"
+)
+
+// doVerifyContent mounts the SIF image at cpath and checks its files
+// against the content manifest recorded when it was built
+func doVerifyContent(cpath string) error {
+	entries, found, err := metadata.SIFContent(cpath)
+	if err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("%s has no content manifest, it must be rebuilt to verify its content", cpath)
+	}
+
+	dir, err := ioutil.TempDir("", "verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := imgmount.Mount(cpath, dir, false, nil); err != nil {
+		return fmt.Errorf("failed to mount %s: %s", cpath, err)
+	}
+	defer func() {
+		if err := imgmount.Umount(dir); err != nil {
+			sylog.Warningf("failed to unmount %s: %s", dir, err)
+		}
+	}()
+
+	mismatches, err := metadata.VerifyContent(dir, entries)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, m := range mismatches {
+		if m.Reason == "unreadable" {
+			sylog.Warningf("%s can't be read by the current user and was not verified", m.Path)
+			continue
+		}
+		fmt.Printf("%-10s %s\n", m.Reason, m.Path)
+		failed++
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d file(s) don't match the content manifest", failed)
+	}
+
+	fmt.Printf("Content of %d file(s) verified\n", len(entries))
+	return nil
+}
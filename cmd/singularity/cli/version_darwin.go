@@ -0,0 +1,18 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+)
+
+func printVersionJSON() {
+	b, _ := json.MarshalIndent(map[string]string{"version": buildcfg.PACKAGE_VERSION}, "", "\t")
+	fmt.Println(string(b))
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/network"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+	"github.com/sylabs/singularity/internal/pkg/security/seccomp"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// feature reports if a feature is compiled in and available on this host
+type feature struct {
+	Compiled  bool   `json:"compiled"`
+	Available bool   `json:"available"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+type versionInfo struct {
+	Version  string             `json:"version"`
+	Features map[string]feature `json:"features"`
+}
+
+func printVersionJSON() {
+	info := versionInfo{
+		Version:  buildcfg.PACKAGE_VERSION,
+		Features: versionFeatures(),
+	}
+	b, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		sylog.Fatalf("Could not encode version information: %s", err)
+	}
+	fmt.Println(string(b))
+}
+
+func versionFeatures() map[string]feature {
+	engineConfig := singularity.NewConfig()
+	configurationFile := buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
+	if err := config.Parser(configurationFile, engineConfig.File); err != nil {
+		sylog.Debugf("Using default configuration: %s", err)
+		config.Parser("", engineConfig.File)
+	}
+	c := engineConfig.File
+
+	starter := filepath.Join(buildcfg.LIBEXECDIR, "singularity", "bin", "starter-suid")
+
+	return map[string]feature{
+		"setuid":     setuidFeature(starter, c),
+		"seccomp":    seccompFeature(),
+		"network":    networkFeature(c),
+		"userns":     usernsFeature(),
+		"squashfs":   squashfsFeature(c),
+		"encryption": {Detail: "not supported by this version"},
+	}
+}
+
+// setuidFeature checks that starter, the starter-suid binary, is installed
+// with the setuid bit set and owned by root, and that setuid is allowed by
+// the configuration
+func setuidFeature(starter string, c *singularity.FileConfig) feature {
+	fi, err := os.Stat(starter)
+	if err != nil {
+		return feature{Detail: "starter-suid not installed"}
+	}
+	f := feature{Compiled: true}
+	if st := fi.Sys().(*syscall.Stat_t); st.Uid != 0 || fi.Mode()&os.ModeSetuid == 0 {
+		f.Detail = "starter-suid is not setuid root"
+	} else if !c.AllowSetuid {
+		f.Detail = "disabled by configuration"
+	} else {
+		f.Available = true
+	}
+	return f
+}
+
+func seccompFeature() feature {
+	if !seccomp.Enabled() {
+		return feature{}
+	}
+	b, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil || !strings.Contains(string(b), "\nSeccomp:") {
+		return feature{Compiled: true, Detail: "not supported by kernel"}
+	}
+	return feature{Compiled: true, Available: true}
+}
+
+// networkFeature checks that the CNI bridge plugin is installed
+func networkFeature(c *singularity.FileConfig) feature {
+	path := c.CniPluginPath
+	if path == "" {
+		path = network.DefaultCNIPluginPath
+	}
+	if _, err := os.Stat(filepath.Join(path, "bridge")); err != nil {
+		return feature{Compiled: true, Detail: fmt.Sprintf("CNI plugins not found in %s", path)}
+	}
+	return feature{Compiled: true, Available: true, Detail: path}
+}
+
+func usernsFeature() feature {
+	b, err := ioutil.ReadFile("/proc/sys/user/max_user_namespaces")
+	if err != nil {
+		return feature{Compiled: true, Detail: "not supported by kernel"}
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || n == 0 {
+		return feature{Compiled: true, Detail: "disabled by kernel"}
+	}
+	return feature{Compiled: true, Available: true}
+}
+
+// squashfsFeature checks that mksquashfs is found, the same way build does
+func squashfsFeature(c *singularity.FileConfig) feature {
+	p := c.MksquashfsPath
+	if p != "" && !strings.HasSuffix(p, "mksquashfs") {
+		p = filepath.Join(p, "mksquashfs")
+	}
+	if p == "" {
+		p = "mksquashfs"
+	}
+	path, err := exec.LookPath(p)
+	if err != nil {
+		return feature{Compiled: true, Detail: "mksquashfs not found"}
+	}
+	return feature{Compiled: true, Available: true, Detail: path}
+}
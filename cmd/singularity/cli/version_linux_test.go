@@ -0,0 +1,147 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+)
+
+func TestVersionFeatures(t *testing.T) {
+	features := versionFeatures()
+	for _, name := range []string{"setuid", "seccomp", "network", "userns", "squashfs", "encryption"} {
+		f, ok := features[name]
+		if !ok {
+			t.Errorf("feature %s not reported", name)
+			continue
+		}
+		if f.Available && !f.Compiled {
+			t.Errorf("feature %s available but not compiled in", name)
+		}
+	}
+	if f := features["encryption"]; f.Compiled || f.Available {
+		t.Errorf("encryption reported as supported")
+	}
+
+	b, err := json.Marshal(versionInfo{Version: "3.0.0", Features: features})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var doc struct {
+		Version  string                            `json:"version"`
+		Features map[string]map[string]interface{} `json:"features"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if doc.Version != "3.0.0" {
+		t.Errorf("got version %q, want 3.0.0", doc.Version)
+	}
+	for name, f := range doc.Features {
+		if _, ok := f["compiled"]; !ok {
+			t.Errorf("compiled field of feature %s missing", name)
+		}
+		if _, ok := f["available"]; !ok {
+			t.Errorf("available field of feature %s missing", name)
+		}
+	}
+}
+
+func TestSetuidFeature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	starter := filepath.Join(dir, "starter-suid")
+	if err := ioutil.WriteFile(starter, nil, 04755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(starter, 04755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		starter  string
+		allow    bool
+		compiled bool
+	}{
+		{"not installed", filepath.Join(dir, "missing"), true, false},
+		// the test runs unprivileged, the starter isn't owned by root
+		{"not setuid root", starter, true, true},
+	}
+	for _, tt := range tests {
+		f := setuidFeature(tt.starter, &singularity.FileConfig{AllowSetuid: tt.allow})
+		if f.Compiled != tt.compiled || f.Available || f.Detail == "" {
+			t.Errorf("%s: unexpected feature %+v", tt.name, f)
+		}
+	}
+}
+
+func TestNetworkFeature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := networkFeature(&singularity.FileConfig{CniPluginPath: dir})
+	if !f.Compiled || f.Available {
+		t.Errorf("network available without bridge plugin: %+v", f)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bridge"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	f = networkFeature(&singularity.FileConfig{CniPluginPath: dir})
+	if !f.Available || f.Detail != dir {
+		t.Errorf("network not available with bridge plugin: %+v", f)
+	}
+}
+
+func TestSquashfsFeature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mksquashfs := filepath.Join(dir, "mksquashfs")
+	if err := ioutil.WriteFile(mksquashfs, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", filepath.Join(dir, "empty"))
+
+	tests := []struct {
+		name      string
+		path      string
+		available bool
+	}{
+		{"binary", mksquashfs, true},
+		{"directory", dir, true},
+		{"missing", filepath.Join(dir, "missing", "mksquashfs"), false},
+		{"not in PATH", "", false},
+	}
+	for _, tt := range tests {
+		f := squashfsFeature(&singularity.FileConfig{MksquashfsPath: tt.path})
+		if !f.Compiled || f.Available != tt.available {
+			t.Errorf("%s: unexpected feature %+v", tt.name, f)
+		}
+		if tt.available && f.Detail != mksquashfs {
+			t.Errorf("%s: got path %s, want %s", tt.name, f.Detail, mksquashfs)
+		}
+	}
+}
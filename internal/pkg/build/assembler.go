@@ -6,6 +6,7 @@
 package build
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
@@ -21,9 +22,9 @@ var validAssemblers = map[string]bool{
 // For example a bundle may be holding multiple file systems indended
 // to be separate partitions within a SIF image. The assembler would need
 // to detect these directories and make sure it properly assembles the SIF
-// with them as partitions
+// with them as partitions. Assembling is stopped when the context is canceled
 type Assembler interface {
-	Assemble(*types.Bundle, string) error
+	Assemble(context.Context, *types.Bundle, string) error
 }
 
 // IsValidAssembler returns whether or not the given Assembler is valid
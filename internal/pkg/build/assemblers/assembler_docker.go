@@ -0,0 +1,79 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package assemblers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/copy"
+	dockerarchive "github.com/containers/image/docker/archive"
+	dockerdaemon "github.com/containers/image/docker/daemon"
+	oci "github.com/containers/image/oci/layout"
+	"github.com/containers/image/signature"
+	imagetypes "github.com/containers/image/types"
+	"github.com/sylabs/singularity/internal/pkg/build/ocilayout"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// DockerAssembler stores the rootfs of a Bundle as a docker-archive tarball
+// which can be loaded with docker load, or loads it directly in the local
+// docker daemon if Daemon is set. The docker-archive path may be followed by
+// the reference of the image in the archive (path/to/image.tar:name:tag),
+// the docker-daemon path is the reference of the image (name:tag).
+type DockerAssembler struct {
+	Daemon bool
+}
+
+// Assemble creates a docker image from a Bundle
+func (a *DockerAssembler) Assemble(ctx context.Context, b *types.Bundle, path string) (err error) {
+	defer os.RemoveAll(b.Path)
+
+	var destRef imagetypes.ImageReference
+	if a.Daemon {
+		buildLog.Infof("Loading image in docker daemon...")
+		destRef, err = dockerdaemon.ParseReference(path)
+	} else {
+		buildLog.Infof("Creating docker archive...")
+		destRef, err = dockerarchive.ParseReference(path)
+		if err == nil {
+			// docker-archive refuses to write to an existing file
+			os.RemoveAll(strings.SplitN(path, ":", 2)[0])
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Docker Assemble Failed: invalid destination %s: %s", path, err)
+	}
+
+	layout := filepath.Join(b.Path, "oci")
+	if err := ocilayout.Write(ctx, b.Rootfs(), layout, "singularity build"); err != nil {
+		return fmt.Errorf("Docker Assemble Failed: %s", err)
+	}
+	srcRef, err := oci.NewReference(layout, ocilayout.RefName)
+	if err != nil {
+		return fmt.Errorf("Docker Assemble Failed: %s", err)
+	}
+
+	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
+	policyCtx, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return fmt.Errorf("Docker Assemble Failed: %s", err)
+	}
+	defer policyCtx.Destroy()
+
+	err = copy.Image(ctx, policyCtx, destRef, srcRef, &copy.Options{
+		ReportWriter: ioutil.Discard,
+	})
+	if err != nil {
+		return fmt.Errorf("Docker Assemble Failed: while copying image: %s", err)
+	}
+
+	return nil
+}
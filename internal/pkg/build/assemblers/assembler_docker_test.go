@@ -0,0 +1,64 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package assemblers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
+)
+
+func TestDockerAssembler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-assemble-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "image.tar")
+	a := &assemblers.DockerAssembler{}
+	if err := a.Assemble(context.Background(), makeOCITestBundle(t), archive+":test/image:v1"); err != nil {
+		t.Fatalf("failed to assemble docker archive: %v", err)
+	}
+
+	extracted := filepath.Join(dir, "extracted")
+	os.Mkdir(extracted, 0755)
+	if out, err := exec.Command("tar", "-C", extracted, "-xf", archive).CombinedOutput(); err != nil {
+		t.Fatalf("failed to extract docker archive: %v: %s", err, out)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(extracted, "manifest.json"))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest []struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to decode manifest: %v", err)
+	}
+	if len(manifest) != 1 {
+		t.Fatalf("manifest has %d images, expected 1", len(manifest))
+	}
+	if len(manifest[0].RepoTags) != 1 || manifest[0].RepoTags[0] != "docker.io/test/image:v1" {
+		t.Errorf("unexpected tags %v", manifest[0].RepoTags)
+	}
+	if len(manifest[0].Layers) != 1 {
+		t.Errorf("image has %d layers, expected 1", len(manifest[0].Layers))
+	}
+
+	if err := a.Assemble(context.Background(), makeOCITestBundle(t), archive+":Invalid:Reference"); err == nil {
+		t.Errorf("unexpected success with an invalid reference")
+	}
+}
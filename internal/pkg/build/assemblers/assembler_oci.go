@@ -0,0 +1,55 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package assemblers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/build/ocilayout"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// OCIAssembler stores the rootfs of a Bundle as an OCI image layout
+// directory, or as an oci-archive tarball of the layout if Archive is set
+type OCIAssembler struct {
+	Archive bool
+}
+
+// Assemble creates an OCI image from a Bundle
+func (a *OCIAssembler) Assemble(ctx context.Context, b *types.Bundle, path string) (err error) {
+	defer os.RemoveAll(b.Path)
+
+	if _, err := os.Stat(path); err == nil {
+		os.RemoveAll(path)
+	}
+
+	layout := path
+	if a.Archive {
+		buildLog.Infof("Creating OCI archive...")
+		layout = filepath.Join(b.Path, "oci")
+	} else {
+		buildLog.Infof("Creating OCI image layout...")
+	}
+
+	if err := ocilayout.Write(ctx, b.Rootfs(), layout, "singularity build"); err != nil {
+		os.RemoveAll(layout)
+		return fmt.Errorf("OCI Assemble Failed: %s", err)
+	}
+
+	if a.Archive {
+		tar := exec.CommandContext(ctx, "tar", "-C", layout, "-cf", path, ".")
+		if out, err := tar.CombinedOutput(); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("OCI Assemble Failed: while creating archive: %s: %s", err, out)
+		}
+	}
+
+	return nil
+}
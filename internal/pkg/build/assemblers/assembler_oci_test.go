@@ -0,0 +1,161 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package assemblers_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// readOCIBlob decodes the JSON blob of desc from the layout at path in v
+// after checking its digest
+func readOCIBlob(t *testing.T, path string, desc imagespec.Descriptor, v interface{}) {
+	data, err := ioutil.ReadFile(filepath.Join(path, "blobs", "sha256", desc.Digest.Hex()))
+	if err != nil {
+		t.Fatalf("failed to read blob %s: %v", desc.Digest, err)
+	}
+	if digest.FromBytes(data) != desc.Digest || int64(len(data)) != desc.Size {
+		t.Fatalf("blob %s doesn't match its descriptor", desc.Digest)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to decode blob %s: %v", desc.Digest, err)
+	}
+}
+
+// checkOCILayout checks that the layout at path holds the rootfs of the
+// test bundle
+func checkOCILayout(t *testing.T, path string) {
+	var index imagespec.Index
+	data, err := ioutil.ReadFile(filepath.Join(path, "index.json"))
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("failed to decode index: %v", err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Fatalf("index has %d manifests, expected 1", len(index.Manifests))
+	}
+	if _, err := os.Stat(filepath.Join(path, imagespec.ImageLayoutFile)); err != nil {
+		t.Errorf("missing %s file: %v", imagespec.ImageLayoutFile, err)
+	}
+
+	var manifest imagespec.Manifest
+	readOCIBlob(t, path, index.Manifests[0], &manifest)
+	if len(manifest.Layers) != 1 {
+		t.Fatalf("manifest has %d layers, expected 1", len(manifest.Layers))
+	}
+	if len(manifest.Annotations) != 1 || manifest.Annotations[imagespec.AnnotationRevision] != "abc" {
+		t.Errorf("unexpected manifest annotations %v", manifest.Annotations)
+	}
+
+	var config imagespec.Image
+	readOCIBlob(t, path, manifest.Config, &config)
+	if config.Config.Labels["org.label-schema.test"] != "oci" {
+		t.Errorf("unexpected labels %v", config.Config.Labels)
+	}
+	if len(config.Config.Entrypoint) != 1 || config.Config.Entrypoint[0] != "/.singularity.d/actions/run" {
+		t.Errorf("unexpected entrypoint %v", config.Config.Entrypoint)
+	}
+
+	layer := manifest.Layers[0]
+	f, err := os.Open(filepath.Join(path, "blobs", "sha256", layer.Digest.Hex()))
+	if err != nil {
+		t.Fatalf("failed to open layer: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to uncompress layer: %v", err)
+	}
+	diffID := digest.SHA256.Digester()
+	tee := io.TeeReader(gz, diffID.Hash())
+	tr := tar.NewReader(tee)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read layer: %v", err)
+		}
+		if filepath.Clean(hdr.Name) == "etc/hostname" {
+			found = true
+		}
+	}
+	// hash the end of archive padding
+	io.Copy(ioutil.Discard, tee)
+	if !found {
+		t.Errorf("rootfs file missing from layer")
+	}
+	if len(config.RootFS.DiffIDs) != 1 || config.RootFS.DiffIDs[0] != diffID.Digest() {
+		t.Errorf("layer doesn't match the diff ID of the image configuration")
+	}
+}
+
+func makeOCITestBundle(t *testing.T) *types.Bundle {
+	b, err := types.NewBundle("", "sbuild-ociAssembler")
+	if err != nil {
+		t.Fatalf("failed to create bundle: %v", err)
+	}
+
+	files := map[string]string{
+		"etc/hostname":                  "oci\n",
+		".singularity.d/actions/run":    "#!/bin/sh\n",
+		".singularity.d/labels.json":    `{"org.label-schema.test": "oci", "org.opencontainers.image.revision": "abc"}`,
+		".singularity.d/env/01-base.sh": "#!/bin/sh\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(b.Rootfs(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	return b
+}
+
+func TestOCIAssembler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-assemble-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	layout := filepath.Join(dir, "layout")
+	a := &assemblers.OCIAssembler{}
+	if err := a.Assemble(context.Background(), makeOCITestBundle(t), layout); err != nil {
+		t.Fatalf("failed to assemble OCI layout: %v", err)
+	}
+	checkOCILayout(t, layout)
+
+	archive := filepath.Join(dir, "image.tar")
+	a = &assemblers.OCIAssembler{Archive: true}
+	if err := a.Assemble(context.Background(), makeOCITestBundle(t), archive); err != nil {
+		t.Fatalf("failed to assemble OCI archive: %v", err)
+	}
+	extracted := filepath.Join(dir, "extracted")
+	os.Mkdir(extracted, 0755)
+	if out, err := exec.Command("tar", "-C", extracted, "-xf", archive).CombinedOutput(); err != nil {
+		t.Fatalf("failed to extract OCI archive: %v: %s", err, out)
+	}
+	checkOCILayout(t, extracted)
+}
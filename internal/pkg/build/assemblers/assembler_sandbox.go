@@ -6,6 +6,7 @@
 package assemblers
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -14,18 +15,21 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
+var buildLog = sylog.Subsystem("build")
+
 // SandboxAssembler doesnt store anything
 type SandboxAssembler struct {
 }
 
-// Assemble creates a Sandbox image from a Bundle
-func (a *SandboxAssembler) Assemble(b *types.Bundle, path string) (err error) {
+// Assemble creates a Sandbox image from a Bundle, the move of the root
+// filesystem to path is not interrupted by the cancellation of ctx
+func (a *SandboxAssembler) Assemble(ctx context.Context, b *types.Bundle, path string) (err error) {
 	defer os.RemoveAll(b.Path)
 
-	sylog.Infof("Creating sandbox directory...")
+	buildLog.Infof("Creating sandbox directory...")
 
 	// move bundle rootfs to sandboxdir as final sandbox
-	sylog.Debugf("Moving sandbox from %v to %v", b.Rootfs(), path)
+	buildLog.Debugf("Moving sandbox from %v to %v", b.Rootfs(), path)
 	if _, err := os.Stat(path); err == nil {
 		os.RemoveAll(path)
 	}
@@ -6,6 +6,7 @@
 package assemblers_test
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -37,7 +38,7 @@ func TestSandboxAssemblerDocker(t *testing.T) {
 
 	ocp := &sources.OCIConveyorPacker{}
 
-	if err := ocp.Get(b); err != nil {
+	if err := ocp.Get(context.Background(), b); err != nil {
 		t.Fatalf("failed to Get from %s: %v\n", assemblerDockerURI, err)
 	}
 
@@ -48,7 +49,7 @@ func TestSandboxAssemblerDocker(t *testing.T) {
 
 	a := &assemblers.SandboxAssembler{}
 
-	err = a.Assemble(b, assemblerDockerDestDir)
+	err = a.Assemble(context.Background(), b, assemblerDockerDestDir)
 	if err != nil {
 		t.Fatalf("failed to assemble from %s: %v\n", assemblerDockerURI, err)
 	}
@@ -71,7 +72,7 @@ func TestSandboxAssemblerShub(t *testing.T) {
 
 	scp := &sources.ShubConveyorPacker{}
 
-	if err := scp.Get(b); err != nil {
+	if err := scp.Get(context.Background(), b); err != nil {
 		t.Fatalf("failed to Get from %s: %v\n", assemblerShubURI, err)
 	}
 
@@ -82,7 +83,7 @@ func TestSandboxAssemblerShub(t *testing.T) {
 
 	a := &assemblers.SIFAssembler{}
 
-	err = a.Assemble(b, assemblerShubDestDir)
+	err = a.Assemble(context.Background(), b, assemblerShubDestDir)
 	if err != nil {
 		t.Fatalf("failed to assemble from %s: %v\n", assemblerShubURI, err)
 	}
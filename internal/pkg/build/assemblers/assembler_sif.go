@@ -7,7 +7,9 @@ package assemblers
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -15,25 +17,41 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/satori/go.uuid"
 	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/image"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/signing"
+	"golang.org/x/crypto/openpgp"
 )
 
-// SIFAssembler doesnt store anything
+// SIFAssembler creates SIF images from bundles
 type SIFAssembler struct {
+	// Compression is the compression algorithm of the squashfs partition,
+	// mksquashfs uses gzip if empty
+	Compression string
+	// SignEntity is the private key signing the image once created, the
+	// image is left unsigned if nil
+	SignEntity *openpgp.Entity
 }
 
-func createSIF(path string, definition []byte, squashfile string) (err error) {
+// SquashfsCompressions are the compression algorithms which can be selected
+// for the squashfs partition of SIF images
+var SquashfsCompressions = []string{"gzip", "xz", "lz4", "zstd"}
+
+func createSIF(path string, definition []byte, labels []byte, squashfile string, objects map[string][]byte) (err error) {
 	// general info for the new SIF file creation
 	cinfo := sif.CreateInfo{
 		Pathname:   path,
@@ -54,6 +72,20 @@ func createSIF(path string, definition []byte, squashfile string) (err error) {
 	// add this descriptor input element to creation descriptor slice
 	cinfo.InputDescr = append(cinfo.InputDescr, definput)
 
+	// labels are read by inspect without starting a container
+	if labels != nil {
+		labelinput := sif.DescriptorInput{
+			Datatype: sif.DataLabels,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    metadata.LabelObject,
+			Data:     labels,
+		}
+		labelinput.Size = int64(len(labels))
+
+		cinfo.InputDescr = append(cinfo.InputDescr, labelinput)
+	}
+
 	// data we need to create a system partition descriptor
 	parinput := sif.DescriptorInput{
 		Datatype: sif.DataPartition,
@@ -80,6 +112,27 @@ func createSIF(path string, definition []byte, squashfile string) (err error) {
 	// add this descriptor input element to the list
 	cinfo.InputDescr = append(cinfo.InputDescr, parinput)
 
+	// add JSON objects collected during the build, named after their key,
+	// in a stable order so that identical builds give identical images
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := objects[name]
+		jsoninput := sif.DescriptorInput{
+			Datatype: sif.DataGenericJSON,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    name,
+			Data:     data,
+		}
+		jsoninput.Size = int64(len(data))
+
+		cinfo.InputDescr = append(cinfo.InputDescr, jsoninput)
+	}
+
 	// remove anything that may exist at the build destination at last moment
 	os.RemoveAll(path)
 
@@ -117,11 +170,62 @@ func getMksquashfsPath() (string, error) {
 	return exec.LookPath(p)
 }
 
+// SquashfsCompressors returns the compressors supported by mksquashfs, as
+// listed in its usage message
+func SquashfsCompressors(mksquashfs string) (map[string]bool, error) {
+	// mksquashfs prints its usage and exits with an error without arguments
+	out, err := exec.Command(mksquashfs).CombinedOutput()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, err
+	}
+
+	compressors := make(map[string]bool)
+	list := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "Compressors available") {
+			list = true
+			continue
+		}
+		// compressors are indented by a tab, their options are further
+		// indented
+		if !list || !strings.HasPrefix(line, "\t") || len(line) < 2 || unicode.IsSpace(rune(line[1])) {
+			continue
+		}
+		compressors[strings.Fields(line)[0]] = true
+	}
+	return compressors, nil
+}
+
+// CheckCompression returns an error if comp is not a squashfs compression
+// algorithm supported by the local mksquashfs
+func CheckCompression(comp string) error {
+	known := false
+	for _, c := range SquashfsCompressions {
+		known = known || c == comp
+	}
+	if !known {
+		return fmt.Errorf("unknown compression %s, expected one of %s", comp, strings.Join(SquashfsCompressions, ", "))
+	}
+
+	mksquashfs, err := getMksquashfsPath()
+	if err != nil {
+		return fmt.Errorf("while searching for mksquashfs: %v", err)
+	}
+	compressors, err := SquashfsCompressors(mksquashfs)
+	if err != nil {
+		return fmt.Errorf("while listing mksquashfs compressors: %v", err)
+	}
+	if !compressors[comp] {
+		return fmt.Errorf("%s does not support %s compression", mksquashfs, comp)
+	}
+	return nil
+}
+
 // Assemble creates a SIF image from a Bundle
-func (a *SIFAssembler) Assemble(b *types.Bundle, path string) (err error) {
+func (a *SIFAssembler) Assemble(ctx context.Context, b *types.Bundle, path string) (err error) {
 	defer os.RemoveAll(b.Path)
 
-	sylog.Infof("Creating SIF file...")
+	buildLog.Infof("Creating SIF file...")
 
 	// convert definition to plain text
 	var buf bytes.Buffer
@@ -141,13 +245,20 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) (err error) {
 	defer os.Remove(squashfsPath)
 
 	args := []string{b.Rootfs(), squashfsPath, "-noappend"}
+	if a.Compression != "" {
+		args = append(args, "-comp", a.Compression)
+		if err := image.CheckSquashfsCompSupport(a.Compression); err != nil {
+			buildLog.Warningf("Image won't run on this host: %s", err)
+		}
+	}
 
 	// build squashfs with all-root flag when building as a user
 	if syscall.Getuid() != 0 {
 		args = append(args, "-all-root")
 	}
 
-	mksquashfsCmd := exec.Command(mksquashfs, args...)
+	start := time.Now()
+	mksquashfsCmd := exec.CommandContext(ctx, mksquashfs, args...)
 	stderr, err := mksquashfsCmd.StderrPipe()
 	if err != nil {
 		return fmt.Errorf("While setting up stderr pipe: %v", err)
@@ -165,12 +276,70 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) (err error) {
 	if err := mksquashfsCmd.Wait(); err != nil {
 		return fmt.Errorf("While running mksquashfs: %v: %s", err, strings.Replace(string(errOut), "\n", " ", -1))
 	}
+	b.Timings = append(b.Timings, types.NewPhaseTiming("assemble-squashfs", start))
 
-	err = createSIF(path, def, squashfsPath)
+	// store the timings of the build so far alongside the image
+	timings, err := json.Marshal(types.NewTimingReport(b.Timings))
 	if err != nil {
+		return fmt.Errorf("While encoding timing report: %v", err)
+	}
+	if b.JSONObjects == nil {
+		b.JSONObjects = make(map[string][]byte)
+	}
+	b.JSONObjects[types.TimingReportObject] = timings
+
+	// store the scripts and labels of the container and its apps so they
+	// are inspected without starting a container
+	md, labels, err := metadata.Read(b.Rootfs())
+	if err != nil {
+		return fmt.Errorf("While reading metadata: %v", err)
+	}
+	if b.JSONObjects[metadata.MetadataObject], err = json.Marshal(md); err != nil {
+		return fmt.Errorf("While encoding metadata: %v", err)
+	}
+
+	// record the size and hash of every file so the content of the mounted
+	// image can be verified file by file
+	content, err := metadata.ContentManifest(b.Rootfs())
+	if err != nil {
+		return fmt.Errorf("While creating content manifest: %v", err)
+	}
+	if b.JSONObjects[metadata.ContentObject], err = metadata.EncodeContent(content); err != nil {
+		return fmt.Errorf("While encoding content manifest: %v", err)
+	}
+
+	start = time.Now()
+	defer func() {
+		if err == nil {
+			b.Timings = append(b.Timings, types.NewPhaseTiming("assemble-sif", start))
+		}
+	}()
+
+	if a.SignEntity == nil {
+		if err := createSIF(path, def, labels, squashfsPath, b.JSONObjects); err != nil {
+			return fmt.Errorf("While creating SIF: %v", err)
+		}
+		return
+	}
+
+	// create and sign the image next to the build destination, so that an
+	// unsigned image never shows up at the destination
+	unsigned := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".unsigned")
+	defer os.Remove(unsigned)
+	if err := createSIF(unsigned, def, labels, squashfsPath, b.JSONObjects); err != nil {
 		return fmt.Errorf("While creating SIF: %v", err)
 	}
 
+	buildLog.Infof("Signing image with key %X...", a.SignEntity.PrimaryKey.Fingerprint)
+	if err := signing.SignWithEntity(unsigned, 0, false, a.SignEntity); err != nil {
+		return fmt.Errorf("While signing SIF: %v", err)
+	}
+
+	os.RemoveAll(path)
+	if err := os.Rename(unsigned, path); err != nil {
+		return fmt.Errorf("While moving signed SIF to %s: %v", path, err)
+	}
+
 	return
 }
 
@@ -190,20 +359,20 @@ func changeOwner() (int, int, bool) {
 	_uid := os.Getenv("SUDO_UID")
 	_gid := os.Getenv("SUDO_GID")
 	if _uid == "" || _gid == "" {
-		sylog.Warningf("Env vars SUDO_UID or SUDO_GID are not set, won't call chown over built SIF")
+		buildLog.Warningf("Env vars SUDO_UID or SUDO_GID are not set, won't call chown over built SIF")
 
 		return 0, 0, false
 	}
 
 	uid, err := strconv.Atoi(_uid)
 	if err != nil {
-		sylog.Warningf("Error while calling strconv: %v", err)
+		buildLog.Warningf("Error while calling strconv: %v", err)
 
 		return 0, 0, false
 	}
 	gid, err := strconv.Atoi(_gid)
 	if err != nil {
-		sylog.Warningf("Error while calling strconv : %v", err)
+		buildLog.Warningf("Error while calling strconv : %v", err)
 
 		return 0, 0, false
 	}
@@ -6,7 +6,11 @@
 package assemblers_test
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
@@ -46,7 +50,7 @@ func TestSIFAssemblerDocker(t *testing.T) {
 
 	ocp := &sources.OCIConveyorPacker{}
 
-	if err := ocp.Get(b); err != nil {
+	if err := ocp.Get(context.Background(), b); err != nil {
 		t.Fatalf("failed to Get from %s: %v\n", assemblerDockerURI, err)
 	}
 
@@ -57,7 +61,7 @@ func TestSIFAssemblerDocker(t *testing.T) {
 
 	a := &assemblers.SIFAssembler{}
 
-	err = a.Assemble(b, assemblerDockerDest)
+	err = a.Assemble(context.Background(), b, assemblerDockerDest)
 	if err != nil {
 		t.Fatalf("failed to assemble from %s: %v\n", assemblerDockerURI, err)
 	}
@@ -80,7 +84,7 @@ func TestSIFAssemblerShub(t *testing.T) {
 
 	scp := &sources.ShubConveyorPacker{}
 
-	if err := scp.Get(b); err != nil {
+	if err := scp.Get(context.Background(), b); err != nil {
 		t.Fatalf("failed to Get from %s: %v\n", assemblerShubURI, err)
 	}
 
@@ -91,10 +95,49 @@ func TestSIFAssemblerShub(t *testing.T) {
 
 	a := &assemblers.SIFAssembler{}
 
-	err = a.Assemble(b, assemblerShubDest)
+	err = a.Assemble(context.Background(), b, assemblerShubDest)
 	if err != nil {
 		t.Fatalf("failed to assemble from %s: %v\n", assemblerShubURI, err)
 	}
 
 	defer os.Remove(assemblerShubDest)
 }
+
+// mksquashfsUsage is the end of the usage message of mksquashfs
+const mksquashfsUsage = `Compressors available and compressor specific options:
+	gzip (default)
+	  -Xcompression-level <compression-level>
+		<compression-level> should be 1 .. 9 (default 9)
+	lzo
+	lz4
+	  -Xhc
+	xz
+	  -Xbcj filter1,filter2,...,filterN
+`
+
+func TestSquashfsCompressors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mksquashfs-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mksquashfs := filepath.Join(dir, "mksquashfs")
+	script := "#!/bin/sh\ncat >&2 <<'EOF'\n" + mksquashfsUsage + "EOF\nexit 1\n"
+	if err := ioutil.WriteFile(mksquashfs, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write mksquashfs: %v", err)
+	}
+
+	compressors, err := assemblers.SquashfsCompressors(mksquashfs)
+	if err != nil {
+		t.Fatalf("failed to list compressors: %v", err)
+	}
+	expected := map[string]bool{"gzip": true, "lzo": true, "lz4": true, "xz": true}
+	if !reflect.DeepEqual(compressors, expected) {
+		t.Errorf("unexpected compressors %v, expected %v", compressors, expected)
+	}
+
+	if err := assemblers.CheckCompression("bzip2"); err == nil {
+		t.Errorf("unexpected success checking unknown compression")
+	}
+}
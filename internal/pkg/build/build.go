@@ -6,6 +6,8 @@
 package build
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,25 +16,36 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-tools/generate"
 	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/internal/pkg/build/scan"
 	"github.com/sylabs/singularity/internal/pkg/build/sources"
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/image"
+	"github.com/sylabs/singularity/internal/pkg/network"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/oci"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/imgbuild"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/syplugin"
 	syexec "github.com/sylabs/singularity/internal/pkg/util/exec"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
+	"github.com/sylabs/singularity/pkg/signing"
+	"golang.org/x/crypto/openpgp"
 )
 
+var buildLog = sylog.Subsystem("build")
+
 // Build is an abstracted way to look at the entire build process.
 // For example calling NewBuild() will return this object.
 // From there we can call Full() on this build object, which will:
@@ -52,11 +65,17 @@ type Build struct {
 	b *types.Bundle
 	// d describes how a container is to be built, including actions to be run in the container to reach its final state
 	d types.Definition
+	// duration is the time taken by the last full build
+	duration time.Duration
+	// progress receives the events of the build, if requested with Progress()
+	progress chan types.Event
+	// testReport holds the results of the tests run at build time, if any
+	testReport *types.TestReport
 }
 
 // NewBuild creates a new Build struct from a spec (URI, definition file, etc...)
 func NewBuild(spec, dest, format string, libraryURL, authToken string, opts types.Options) (*Build, error) {
-	def, err := makeDef(spec, false)
+	def, err := makeDef(spec, false, opts.BuildArgs)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse spec %v: %v", spec, err)
 	}
@@ -79,11 +98,46 @@ func newBuild(d types.Definition, dest, format string, libraryURL, authToken str
 
 	syscall.Umask(0002)
 
+	if err := checkNetwork(opts.Network); err != nil {
+		return nil, err
+	}
+
+	if opts.Scan != "" {
+		if !scan.IsRegistered(opts.Scan) {
+			return nil, fmt.Errorf("unknown scanner %s", opts.Scan)
+		}
+		if scan.SeverityLevel(opts.ScanSeverity) < 0 {
+			return nil, fmt.Errorf("unknown severity %q, expected one of %s", opts.ScanSeverity, strings.Join(types.Severities, ", "))
+		}
+	}
+
 	// always build a sandbox if updating an existing sandbox
 	if opts.Update {
 		format = "sandbox"
 	}
 
+	// gzip is the default compression of mksquashfs
+	if opts.Compression != "" && opts.Compression != "gzip" {
+		if format != "sif" {
+			return nil, fmt.Errorf("compression %s is only supported by the sif format", opts.Compression)
+		}
+		if err := assemblers.CheckCompression(opts.Compression); err != nil {
+			return nil, err
+		}
+	}
+
+	// decrypt the signing key before building, not to prompt for its
+	// passphrase once the build is done
+	var signEntity *openpgp.Entity
+	if opts.Sign {
+		if format != "sif" {
+			return nil, fmt.Errorf("signing is only supported by the sif format")
+		}
+		if signEntity, err = signing.PrivKey(opts.SignKey); err != nil {
+			return nil, fmt.Errorf("while loading signing key: %v", err)
+		}
+	}
+
 	b := &Build{
 		format: format,
 		dest:   dest,
@@ -111,7 +165,18 @@ func newBuild(d types.Definition, dest, format string, libraryURL, authToken str
 	case "sandbox":
 		b.a = &assemblers.SandboxAssembler{}
 	case "sif":
-		b.a = &assemblers.SIFAssembler{}
+		b.a = &assemblers.SIFAssembler{
+			Compression: opts.Compression,
+			SignEntity:  signEntity,
+		}
+	case "oci":
+		b.a = &assemblers.OCIAssembler{}
+	case "oci-archive":
+		b.a = &assemblers.OCIAssembler{Archive: true}
+	case "docker-archive":
+		b.a = &assemblers.DockerAssembler{}
+	case "docker-daemon":
+		b.a = &assemblers.DockerAssembler{Daemon: true}
 	default:
 		return nil, fmt.Errorf("unrecognized output format %s", format)
 	}
@@ -119,17 +184,89 @@ func newBuild(d types.Definition, dest, format string, libraryURL, authToken str
 	return b, nil
 }
 
-// Full runs a standard build from start to finish
-func (b *Build) Full() error {
-	sylog.Infof("Starting build...")
+// engineStopTimeout is the time given to the build engine to stop its
+// scripts once the build is canceled, before it is killed
+const engineStopTimeout = 10 * time.Second
+
+// Full runs a standard build from start to finish. The build is stopped when
+// ctx is canceled, or when the temporary bundle exceeds its maximum size, the
+// bundle is then removed and the returned error wraps the cause.
+func (b *Build) Full(ctx context.Context) (err error) {
+	defer func() { b.endProgress(err) }()
+
+	var watch *sizeWatch
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			os.RemoveAll(b.b.Path)
+			cause := ctx.Err()
+			if watch != nil && watch.Err() != nil {
+				cause = watch.Err()
+			}
+			err = fmt.Errorf("build canceled: %v", cause)
+		}
+		if watch != nil {
+			watch.Stop()
+		}
+	}()
+
+	buildLog.Infof("Starting build...")
+
+	if err := b.checkSpace(); err != nil {
+		return err
+	}
+	if max := b.b.Opts.TmpDirMaxSize; max > 0 {
+		ctx, watch = watchBundleSize(ctx, b.b.Path, max)
+	}
+
+	start := time.Now()
+
+	cacheKey := ""
+	cached := false
+	if b.useBuildCache() {
+		phaseStart := time.Now()
+		if key, err := b.buildCacheKey(); err != nil {
+			buildLog.Warningf("Not using build cache: %v", err)
+		} else if cached, err = b.restoreBuildCache(key); err != nil {
+			return err
+		} else {
+			cacheKey = key
+		}
+		b.recordPhase("build-cache", phaseStart)
+	}
+
+	resumed := false
+	if !cached && b.b.Opts.Resume && (!b.b.Opts.Update || b.b.Opts.Force) {
+		var err error
+		phaseStart := time.Now()
+		if resumed, err = b.restoreCheckpoint(); err != nil {
+			return err
+		}
+		if resumed {
+			b.recordPhase("checkpoint", phaseStart)
+		}
+	}
+
+	if !resumed && !cached {
+		if err := b.runPreScript(ctx); err != nil {
+			return err
+		}
+	}
 
-	if err := b.runPreScript(); err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	if b.b.Opts.Update && !b.b.Opts.Force {
+	if cached {
+		b.emit(types.EventStageStarted, types.StageBootstrap, "restored from build cache")
+		buildLog.Infof("Skipping %%pre, bootstrap and scripts, restored from build cache")
+	} else if resumed {
+		b.emit(types.EventStageStarted, types.StageBootstrap, "restored from checkpoint")
+		buildLog.Infof("Skipping %%pre and bootstrap, restored from checkpoint")
+	} else if b.b.Opts.Update && !b.b.Opts.Force {
 		//if updating, extract dest container to bundle
-		sylog.Infof("Building into existing container: %s", b.dest)
+		b.emit(types.EventStageStarted, types.StageBootstrap, "existing container "+b.dest)
+		buildLog.Infof("Building into existing container: %s", b.dest)
+		phaseStart := time.Now()
 		p, err := sources.GetLocalPacker(b.dest, b.b)
 		if err != nil {
 			return err
@@ -139,44 +276,260 @@ func (b *Build) Full() error {
 		if err != nil {
 			return err
 		}
+		b.recordPhase("conveyor-pack", phaseStart)
 	} else {
 		//if force, start build from scratch
-		if err := b.c.Get(b.b); err != nil {
+		b.emit(types.EventStageStarted, types.StageBootstrap, "")
+		phaseStart := time.Now()
+		if err := b.c.Get(ctx, b.b); err != nil {
 			return fmt.Errorf("conveyor failed to get: %v", err)
 		}
+		b.recordPhase("conveyor-get", phaseStart)
 
+		phaseStart = time.Now()
 		_, err := b.c.Pack()
 		if err != nil {
 			return fmt.Errorf("packer failed to pack: %v", err)
 		}
+		b.recordPhase("conveyor-pack", phaseStart)
+		b.emit(types.EventConveyorDone, types.StageBootstrap, "")
+
+		if b.b.Opts.Resume {
+			if err := b.saveCheckpoint(); err != nil {
+				return err
+			}
+		}
 	}
 
 	syplugin.BuildHandleBundles(b.b)
+	if err := syplugin.BuildHandleCustomSections(b.b); err != nil {
+		return err
+	}
 	b.b.Recipe.BuildData.Post += syplugin.BuildHandlePosts()
 
-	if engineRequired(b.d) {
-		if err := b.runBuildEngine(); err != nil {
-			return fmt.Errorf("while running engine: %v", err)
+	if cached {
+		if err := b.loadTestReport(); err != nil {
+			return fmt.Errorf("while loading test report: %v", err)
+		}
+	} else {
+		// drop any test report and timings left by a previous build of this
+		// container
+		os.Remove(filepath.Join(b.b.Rootfs(), types.TestReportPath))
+		os.Remove(filepath.Join(b.b.Rootfs(), types.TimingsPath))
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if engineRequired(b.d) {
+			b.emit(types.EventStageStarted, types.StageEngine, "")
+			if err := b.runBuildEngine(ctx, false); err != nil {
+				return fmt.Errorf("while running engine: %v", err)
+			}
+			if err := b.loadEngineTimings(); err != nil {
+				buildLog.Warningf("Could not load timings of the build scripts: %v", err)
+			}
+		}
+
+		if b.testsRequired() {
+			if err := b.runTests(ctx); err != nil {
+				return err
+			}
+		}
+
+		if cacheKey != "" {
+			phaseStart := time.Now()
+			if err := b.saveBuildCache(cacheKey); err != nil {
+				buildLog.Warningf("Could not save build cache: %v", err)
+			}
+			b.recordPhase("build-cache-save", phaseStart)
 		}
 	}
 
-	sylog.Debugf("Inserting Metadata")
+	b.emit(types.EventStageStarted, types.StageMetadata, "")
+	buildLog.Debugf("Inserting Metadata")
+	phaseStart := time.Now()
 	if err := b.insertMetadata(); err != nil {
 		return fmt.Errorf("While inserting metadata to bundle: %v", err)
 	}
+	b.recordPhase("metadata", phaseStart)
+
+	if b.b.Opts.Scan != "" {
+		b.emit(types.EventStageStarted, types.StageScan, b.b.Opts.Scan)
+		phaseStart = time.Now()
+		if err := b.runScan(); err != nil {
+			return err
+		}
+		b.recordPhase("scan", phaseStart)
+	}
+
+	if err := b.runAssembleHook(ctx, types.StagePreAssemble, b.d.BuildData.PreAssemble); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.emit(types.EventStageStarted, types.StageAssemble, "")
+	buildLog.Debugf("Calling assembler")
+	phaseStart = time.Now()
+	phases := len(b.b.Timings)
+	if err := b.Assemble(ctx, b.dest); err != nil {
+		return err
+	}
+	// assemblers may record the timings of their own phases
+	if len(b.b.Timings) == phases {
+		b.recordPhase("assemble", phaseStart)
+	}
+	b.emit(types.EventAssembleDone, types.StageAssemble, b.dest)
+
+	if err := b.runAssembleHook(ctx, types.StagePostAssemble, b.d.BuildData.PostAssemble); err != nil {
+		return err
+	}
 
-	sylog.Debugf("Calling assembler")
-	if err := b.Assemble(b.dest); err != nil {
+	if b.b.Opts.Resume {
+		b.removeCheckpoint()
+	}
+
+	b.duration = time.Since(start)
+	buildLog.Infof("Build complete: %s", b.dest)
+	return nil
+}
+
+// checkNetwork returns an error if network is not a network mode of the
+// build scripts
+func checkNetwork(network string) error {
+	switch network {
+	case "", types.NetworkHost, types.NetworkNone, types.NetworkBridge:
+		return nil
+	}
+	return fmt.Errorf("unknown network %q, expected %s, %s or %s", network, types.NetworkHost, types.NetworkNone, types.NetworkBridge)
+}
+
+// setupNetwork runs the build scripts in a new network namespace unless mode
+// is host, the CNI paths of the bridge network are read from the
+// singularity.conf file conf
+func setupNetwork(mode, conf string, engineConfig *imgbuild.EngineConfig) error {
+	switch mode {
+	case types.NetworkNone, types.NetworkBridge:
+		generator := generate.Generator{Config: &engineConfig.OciConfig.Spec}
+		generator.AddOrReplaceLinuxNamespace(specs.NetworkNamespace, "")
+
+		if mode == types.NetworkBridge {
+			file := singularity.NewConfig().File
+			if err := config.Parser(conf, file); err != nil {
+				return fmt.Errorf("unable to parse singularity.conf file: %s", err)
+			}
+			engineConfig.CNIPath = &network.CNIPath{
+				Conf:   file.CniConfPath,
+				Plugin: file.CniPluginPath,
+			}
+		}
+	}
+	return nil
+}
+
+// recordPhase records the duration of phase, started at start
+func (b *Build) recordPhase(phase string, start time.Time) {
+	b.b.Timings = append(b.b.Timings, types.NewPhaseTiming(phase, start))
+}
+
+// loadEngineTimings records the timings of the scripts run by the build
+// engine, and removes them from the container
+func (b *Build) loadEngineTimings() error {
+	path := filepath.Join(b.b.Rootfs(), types.TimingsPath)
+	timings, err := types.ReadPhaseTimings(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
 		return err
 	}
+	b.b.Timings = append(b.b.Timings, timings...)
+	return os.Remove(path)
+}
+
+// Timings returns the durations of the phases of the last full build
+func (b *Build) Timings() types.TimingReport {
+	r := types.NewTimingReport(b.b.Timings)
+	r.Total = b.duration.Seconds()
+	return r
+}
 
-	sylog.Infof("Build complete: %s", b.dest)
+// runTests runs the %test blocks of the definition in their own build
+// engine invocation. Every test is run even if some fail, the build fails
+// once their results are recorded.
+func (b *Build) runTests(ctx context.Context) error {
+	b.emit(types.EventStageStarted, types.StageTest, "")
+	engineErr := b.runBuildEngine(ctx, true)
+
+	if err := b.loadTestReport(); err != nil {
+		return fmt.Errorf("while loading test report: %v", err)
+	}
+	if err := b.loadEngineTimings(); err != nil {
+		buildLog.Warningf("Could not load timings of the tests: %v", err)
+	}
+
+	if b.testReport != nil && !b.testReport.Passed {
+		failed := b.testReport.Failed()
+		return fmt.Errorf("%d of %d tests failed: %s", len(failed), len(b.testReport.Tests), strings.Join(failed, ", "))
+	}
+	if engineErr != nil {
+		return fmt.Errorf("while running tests: %v", engineErr)
+	}
+	return nil
+}
+
+// TestReport returns the results of the tests run by the last build, it is
+// nil if no test was run
+func (b *Build) TestReport() *types.TestReport {
+	return b.testReport
+}
+
+// loadTestReport records the report of the %test blocks run by the build
+// engine so it can be stored by the assembler alongside the image
+func (b *Build) loadTestReport() error {
+	report, err := ioutil.ReadFile(filepath.Join(b.b.Rootfs(), types.TestReportPath))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	b.testReport = &types.TestReport{}
+	if err := json.Unmarshal(report, b.testReport); err != nil {
+		b.testReport = nil
+		return err
+	}
+
+	if b.b.JSONObjects == nil {
+		b.b.JSONObjects = make(map[string][]byte)
+	}
+	b.b.JSONObjects[types.TestReportObject] = report
 	return nil
 }
 
 // engineRequired returns true if build definition is requesting to run scripts or copy files
 func engineRequired(def types.Definition) bool {
-	return def.BuildData.Post != "" || def.BuildData.Setup != "" || def.BuildData.Test != "" || len(def.BuildData.Files) != 0
+	return def.BuildData.Post != "" || def.BuildData.Setup != "" || len(def.BuildData.Files) != 0
+}
+
+// testsRequired returns true if the definition has %test blocks to run at
+// build time
+func (b *Build) testsRequired() bool {
+	return !b.b.Opts.NoTest && b.b.RunSection("test") && len(b.d.BuildData.BuildTests()) > 0
+}
+
+// engineScripts returns the names of the definition scripts run by the build
+// engine
+func engineScripts(def types.Definition) (scripts []string) {
+	if def.BuildData.Setup != "" {
+		scripts = append(scripts, "setup")
+	}
+	if def.BuildData.Post != "" {
+		scripts = append(scripts, "post")
+	}
+	return scripts
 }
 
 func (b *Build) copyFiles() error {
@@ -185,18 +538,21 @@ func (b *Build) copyFiles() error {
 	for _, transfer := range b.d.BuildData.Files {
 		// sanity
 		if transfer.Src == "" {
-			sylog.Warningf("Attempt to copy file with no name...")
+			buildLog.Warningf("Attempt to copy file with no name...")
 			continue
 		}
 		// dest = source if not specified
 		if transfer.Dst == "" {
 			transfer.Dst = transfer.Src
 		}
-		sylog.Infof("Copying %v to %v", transfer.Src, transfer.Dst)
+		buildLog.Infof("Copying %v to %v", transfer.Src, transfer.Dst)
 		// copy each file into bundle rootfs
 		transfer.Dst = filepath.Join(b.b.Rootfs(), transfer.Dst)
-		copy := exec.Command("/bin/cp", "-fLr", transfer.Src, transfer.Dst)
-		if err := copy.Run(); err != nil {
+		if err := files.Copy(transfer.Src, transfer.Dst, files.CopyOptions{
+			Exclude:        transfer.Exclude,
+			PreserveOwner:  transfer.PreserveOwner,
+			PreserveXattrs: transfer.PreserveXattrs,
+		}); err != nil {
 			return fmt.Errorf("While copying %v to %v: %v", transfer.Src, transfer.Dst, err)
 		}
 	}
@@ -250,35 +606,69 @@ func (b *Build) insertMetadata() (err error) {
 	return
 }
 
-func (b *Build) runPreScript() error {
+func (b *Build) runPreScript(ctx context.Context) error {
 	if b.runPre() && b.d.BuildData.Pre != "" {
 		if syscall.Getuid() != 0 {
 			return fmt.Errorf("Attempted to build with scripts as non-root user")
 		}
 
 		// Run %pre script here
-		pre := exec.Command("/bin/sh", "-cex", b.d.BuildData.Pre)
+		pre := exec.CommandContext(ctx, "/bin/sh", "-cex", b.d.BuildData.Pre)
 		pre.Stdout = os.Stdout
 		pre.Stderr = os.Stderr
 
-		sylog.Infof("Running pre scriptlet\n")
+		b.emit(types.EventStageStarted, types.StagePre, "")
+		b.emit(types.EventScriptRunning, types.StagePre, "pre")
+		buildLog.Infof("Running pre scriptlet\n")
+		start := time.Now()
 		if err := pre.Start(); err != nil {
 			return fmt.Errorf("failed to start %%pre proc: %v", err)
 		}
 		if err := pre.Wait(); err != nil {
 			return fmt.Errorf("pre proc: %v", err)
 		}
+		b.recordPhase("pre", start)
+	}
+	return nil
+}
+
+// runAssembleHook runs the %pre-assemble or %post-assemble script of the
+// definition on the host, with the build destination and format exported in
+// SINGULARITY_IMAGE and SINGULARITY_IMAGE_FORMAT. The root filesystem is
+// exported in SINGULARITY_ROOTFS before the image is assembled, it is gone
+// afterwards.
+func (b *Build) runAssembleHook(ctx context.Context, section, script string) error {
+	if script == "" || !b.b.RunSection(section) {
+		return nil
+	}
+
+	hook := exec.CommandContext(ctx, "/bin/sh", "-cex", script)
+	hook.Env = append(os.Environ(), "SINGULARITY_IMAGE="+b.dest, "SINGULARITY_IMAGE_FORMAT="+b.format)
+	if section == types.StagePreAssemble {
+		hook.Env = append(hook.Env, "SINGULARITY_ROOTFS="+b.b.Rootfs())
+	}
+	hook.Stdout = os.Stdout
+	hook.Stderr = os.Stderr
+
+	b.emit(types.EventStageStarted, section, "")
+	b.emit(types.EventScriptRunning, section, section)
+	buildLog.Infof("Running %s scriptlet", section)
+	start := time.Now()
+	if err := hook.Run(); err != nil {
+		return fmt.Errorf("%s proc: %v", section, err)
 	}
+	b.recordPhase(section, start)
 	return nil
 }
 
-// runBuildEngine creates an imgbuild engine and creates a container out of our bundle in order to execute %post %setup scripts in the bundle
-func (b *Build) runBuildEngine() error {
+// runBuildEngine creates an imgbuild engine and creates a container out of our bundle in order to execute %post %setup scripts in the bundle,
+// or only the %test blocks if tests is set
+func (b *Build) runBuildEngine(ctx context.Context, tests bool) error {
 	if syscall.Getuid() != 0 {
 		return fmt.Errorf("Attempted to build with scripts as non-root user")
 	}
 
-	sylog.Debugf("Starting build engine")
+	buildLog.Debugf("Starting build engine")
 	env := []string{sylog.GetEnvVar(), "SRUNTIME=" + imgbuild.Name}
 	starter := filepath.Join(buildcfg.LIBEXECDIR, "/singularity/bin/starter")
 	progname := []string{"singularity image-build"}
@@ -288,6 +678,11 @@ func (b *Build) runBuildEngine() error {
 		Bundle:    *b.b,
 		OciConfig: ociConfig,
 	}
+	if tests {
+		engineConfig.Opts.Sections = []string{"test"}
+	} else {
+		engineConfig.Opts.NoTest = true
+	}
 
 	// surface build specific environment variables for scripts
 	sRootfs := "SINGULARITY_ROOTFS=" + b.b.Rootfs()
@@ -296,6 +691,11 @@ func (b *Build) runBuildEngine() error {
 	ociConfig.Process = &specs.Process{}
 	ociConfig.Process.Env = append(os.Environ(), sRootfs, sEnvironment)
 
+	conf := buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
+	if err := setupNetwork(b.b.Opts.Network, conf, engineConfig); err != nil {
+		return err
+	}
+
 	config := &config.Common{
 		EngineName:   imgbuild.Name,
 		ContainerID:  "image-build",
@@ -315,43 +715,65 @@ func (b *Build) runBuildEngine() error {
 	starterCmd.Stdout = os.Stdout
 	starterCmd.Stderr = os.Stderr
 
-	return starterCmd.Run()
+	if tests {
+		var names []string
+		for _, t := range b.d.BuildData.BuildTests() {
+			names = append(names, t.Name)
+		}
+		b.emit(types.EventScriptRunning, types.StageTest, strings.Join(names, ","))
+	} else if scripts := engineScripts(b.d); len(scripts) > 0 {
+		b.emit(types.EventScriptRunning, types.StageEngine, strings.Join(scripts, ","))
+	}
+	return runEngine(ctx, starterCmd)
 }
 
-func getcp(def types.Definition, libraryURL, authToken string) (ConveyorPacker, error) {
-	switch def.Header["bootstrap"] {
-	case "library":
-		return &sources.LibraryConveyorPacker{
-			LibraryURL: libraryURL,
-			AuthToken:  authToken,
-		}, nil
-	case "shub":
-		return &sources.ShubConveyorPacker{}, nil
-	case "docker", "docker-archive", "docker-daemon", "oci", "oci-archive":
-		return &sources.OCIConveyorPacker{}, nil
-	case "busybox":
-		return &sources.BusyBoxConveyorPacker{}, nil
-	case "debootstrap":
-		return &sources.DebootstrapConveyorPacker{}, nil
-	case "arch":
-		return &sources.ArchConveyorPacker{}, nil
-	case "localimage":
-		return &sources.LocalConveyorPacker{}, nil
-	case "yum":
-		return &sources.YumConveyorPacker{}, nil
-	case "":
-		return nil, fmt.Errorf("no bootstrap specification found")
-	default:
-		return nil, fmt.Errorf("invalid build source %s", def.Header["bootstrap"])
+// runEngine runs the starter command of the build engine until it exits or
+// ctx is canceled. On cancellation the engine is asked to stop with SIGTERM,
+// which it forwards to the build scripts, and is killed if it is still
+// running after engineStopTimeout.
+func runEngine(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
 	}
+
+	buildLog.Infof("Stopping build engine")
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-done:
+	case <-time.After(engineStopTimeout):
+		cmd.Process.Kill()
+		<-done
+	}
+	return ctx.Err()
 }
 
-// makeDef gets a definition object from a spec
-func makeDef(spec string, remote bool) (types.Definition, error) {
+func getcp(def types.Definition, libraryURL, authToken string) (ConveyorPacker, error) {
+	return sources.New(def.Header["bootstrap"], sources.Config{
+		LibraryURL: libraryURL,
+		AuthToken:  authToken,
+	})
+}
+
+// makeDef gets a definition object from a spec, replacing the build
+// arguments of definition files with args
+func makeDef(spec string, remote bool, args map[string]string) (types.Definition, error) {
 	if ok, err := uri.IsValid(spec); ok && err == nil {
 		// URI passed as spec
 		return types.NewDefinitionFromURI(spec)
 	}
+	if transport, _ := uri.Split(spec); sources.IsRegisteredURI(transport) {
+		// URI of a registered bootstrap agent passed as spec
+		return types.NewDefinitionFromURI(spec)
+	}
 
 	// Check if spec is an image/sandbox
 	if _, err := image.Init(spec, false); err == nil {
@@ -364,18 +786,17 @@ func makeDef(spec string, remote bool) (types.Definition, error) {
 	}
 
 	// default to reading file as definition
-	defFile, err := os.Open(spec)
+	data, err := parser.ReadDefinitionFile(spec)
 	if err != nil {
-		return types.Definition{}, fmt.Errorf("unable to open file %s: %v", spec, err)
+		return types.Definition{}, fmt.Errorf("unable to read file %s: %v", spec, err)
 	}
-	defer defFile.Close()
 
 	// must be root to build from a definition
 	if os.Getuid() != 0 && !remote {
-		sylog.Fatalf("You must be the root user to build from a Singularity recipe file")
+		buildLog.Fatalf("You must be the root user to build from a Singularity recipe file")
 	}
 
-	d, err := parser.ParseDefinitionFile(defFile)
+	d, err := parser.ParseDefinitionFileWithArgs(bytes.NewReader(data), args)
 	if err != nil {
 		return types.Definition{}, fmt.Errorf("While parsing definition: %s: %v", spec, err)
 	}
@@ -398,17 +819,17 @@ func (b Build) runPre() bool {
 
 // MakeDef gets a definition object from a spec
 func MakeDef(spec string, remote bool) (types.Definition, error) {
-	return makeDef(spec, remote)
+	return makeDef(spec, remote, nil)
 }
 
 // Assemble assembles the bundle to the specified path
-func (b *Build) Assemble(path string) error {
-	return b.a.Assemble(b.b, path)
+func (b *Build) Assemble(ctx context.Context, path string) error {
+	return b.a.Assemble(ctx, b.b, path)
 }
 
 func insertEnvScript(b *types.Bundle) error {
 	if b.RunSection("environment") && b.Recipe.ImageData.Environment != "" {
-		sylog.Infof("Adding environment to container")
+		buildLog.Infof("Adding environment to container")
 		err := ioutil.WriteFile(filepath.Join(b.Rootfs(), "/.singularity.d/env/90-environment.sh"), []byte("#!/bin/sh\n\n"+b.Recipe.ImageData.Environment+"\n"), 0775)
 		if err != nil {
 			return err
@@ -419,7 +840,7 @@ func insertEnvScript(b *types.Bundle) error {
 
 func insertRunScript(b *types.Bundle) error {
 	if b.RunSection("runscript") && b.Recipe.ImageData.Runscript != "" {
-		sylog.Infof("Adding runscript")
+		buildLog.Infof("Adding runscript")
 		err := ioutil.WriteFile(filepath.Join(b.Rootfs(), "/.singularity.d/runscript"), []byte("#!/bin/sh\n\n"+b.Recipe.ImageData.Runscript+"\n"), 0775)
 		if err != nil {
 			return err
@@ -430,7 +851,7 @@ func insertRunScript(b *types.Bundle) error {
 
 func insertStartScript(b *types.Bundle) error {
 	if b.RunSection("startscript") && b.Recipe.ImageData.Startscript != "" {
-		sylog.Infof("Adding startscript")
+		buildLog.Infof("Adding startscript")
 		err := ioutil.WriteFile(filepath.Join(b.Rootfs(), "/.singularity.d/startscript"), []byte("#!/bin/sh\n\n"+b.Recipe.ImageData.Startscript+"\n"), 0775)
 		if err != nil {
 			return err
@@ -441,7 +862,7 @@ func insertStartScript(b *types.Bundle) error {
 
 func insertTestScript(b *types.Bundle) error {
 	if b.RunSection("test") && b.Recipe.ImageData.Test != "" {
-		sylog.Infof("Adding testscript")
+		buildLog.Infof("Adding testscript")
 		err := ioutil.WriteFile(filepath.Join(b.Rootfs(), "/.singularity.d/test"), []byte("#!/bin/sh\n\n"+b.Recipe.ImageData.Test+"\n"), 0775)
 		if err != nil {
 			return err
@@ -454,13 +875,13 @@ func insertHelpScript(b *types.Bundle) error {
 	if b.RunSection("help") && b.Recipe.ImageData.Help != "" {
 		_, err := os.Stat(filepath.Join(b.Rootfs(), "/.singularity.d/runscript.help"))
 		if err != nil || b.Opts.Force {
-			sylog.Infof("Adding help info")
+			buildLog.Infof("Adding help info")
 			err := ioutil.WriteFile(filepath.Join(b.Rootfs(), "/.singularity.d/runscript.help"), []byte(b.Recipe.ImageData.Help+"\n"), 0664)
 			if err != nil {
 				return err
 			}
 		} else {
-			sylog.Warningf("Help message already exists and force option is false, not overwriting")
+			buildLog.Warningf("Help message already exists and force option is false, not overwriting")
 		}
 	}
 	return nil
@@ -523,7 +944,7 @@ func insertLabelsJSON(b *types.Bundle) (err error) {
 	}
 
 	if b.RunSection("labels") && len(b.Recipe.ImageData.Labels) > 0 {
-		sylog.Infof("Adding labels")
+		buildLog.Infof("Adding labels")
 
 		// add new labels to new map and check for collisions
 		for key, value := range b.Recipe.ImageData.Labels {
@@ -533,7 +954,7 @@ func insertLabelsJSON(b *types.Bundle) (err error) {
 				if b.Opts.Force {
 					labels[key] = value
 				} else {
-					sylog.Warningf("Label: %s already exists and force option is false, not overwriting", key)
+					buildLog.Warningf("Label: %s already exists and force option is false, not overwriting", key)
 				}
 			} else {
 				// set if it doesnt
@@ -542,6 +963,17 @@ func insertLabelsJSON(b *types.Bundle) (err error) {
 		}
 	}
 
+	// OCI annotations replace those of the base image, unless they are set
+	// in the definition
+	if b.Opts.OCIAnnotations {
+		annotations := metadata.OCIAnnotations(b.Recipe.ImageData.Labels, time.Now(), b.Opts.Context)
+		for key, value := range annotations {
+			if _, ok := b.Recipe.ImageData.Labels[key]; !ok {
+				labels[key] = value
+			}
+		}
+	}
+
 	// make new map into json
 	text, err = json.MarshalIndent(labels, "", "\t")
 	if err != nil {
@@ -0,0 +1,252 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/network"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/oci"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/imgbuild"
+)
+
+func TestRunAssembleHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-hook-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	b := &Build{
+		format: "sif",
+		dest:   filepath.Join(dir, "image.sif"),
+		b:      &types.Bundle{Path: dir, Opts: types.Options{Sections: []string{"all"}}},
+	}
+
+	script := `echo "$SINGULARITY_IMAGE $SINGULARITY_IMAGE_FORMAT $SINGULARITY_ROOTFS" > ` + out
+	if err := b.runAssembleHook(context.Background(), types.StagePreAssemble, script); err != nil {
+		t.Fatalf("failed to run %%pre-assemble: %v", err)
+	}
+	content, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read hook output: %v", err)
+	}
+	if expected := b.dest + " sif " + b.b.Rootfs() + "\n"; string(content) != expected {
+		t.Errorf("unexpected %%pre-assemble environment %q, expected %q", content, expected)
+	}
+
+	if err := b.runAssembleHook(context.Background(), types.StagePostAssemble, script); err != nil {
+		t.Fatalf("failed to run %%post-assemble: %v", err)
+	}
+	content, _ = ioutil.ReadFile(out)
+	if expected := b.dest + " sif \n"; string(content) != expected {
+		t.Errorf("unexpected %%post-assemble environment %q, expected %q", content, expected)
+	}
+
+	if err := b.runAssembleHook(context.Background(), types.StagePostAssemble, "false"); err == nil {
+		t.Errorf("unexpected success of a failing hook")
+	}
+
+	b.b.Opts.Sections = []string{"post"}
+	if err := b.runAssembleHook(context.Background(), types.StagePostAssemble, "false"); err != nil {
+		t.Errorf("unexpected %%post-assemble run outside of selected sections: %v", err)
+	}
+}
+
+func TestBuildTimings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-timings-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &Build{b: &types.Bundle{Path: dir}}
+	path := filepath.Join(b.b.Rootfs(), types.TimingsPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create timings directory: %v", err)
+	}
+
+	start := time.Now().Add(-2 * time.Second)
+	b.recordPhase("conveyor-get", start)
+	for _, phase := range []string{"setup", "post"} {
+		if err := types.RecordPhaseTiming(path, phase, start); err != nil {
+			t.Fatalf("failed to record %s timing: %v", phase, err)
+		}
+	}
+	if err := b.loadEngineTimings(); err != nil {
+		t.Fatalf("failed to load engine timings: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("engine timings left in the container")
+	}
+
+	b.duration = 10 * time.Second
+	r := b.Timings()
+	var phases []string
+	for _, p := range r.Phases {
+		phases = append(phases, p.Phase)
+		if p.Duration < 2 {
+			t.Errorf("unexpected duration %f of phase %s", p.Duration, p.Phase)
+		}
+	}
+	if expected := []string{"conveyor-get", "setup", "post"}; !reflect.DeepEqual(phases, expected) {
+		t.Errorf("unexpected phases %v, expected %v", phases, expected)
+	}
+	if r.Total != 10 {
+		t.Errorf("unexpected total duration %f", r.Total)
+	}
+}
+
+func TestRunEngineCancel(t *testing.T) {
+	if err := runEngine(context.Background(), exec.Command("true")); err != nil {
+		t.Errorf("unexpected error running command: %v", err)
+	}
+	if err := runEngine(context.Background(), exec.Command("false")); err == nil {
+		t.Errorf("unexpected success running failing command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := runEngine(ctx, exec.Command("sleep", "30")); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if time.Since(start) > engineStopTimeout {
+		t.Errorf("command not stopped on cancellation")
+	}
+}
+
+func TestLoadTestReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-report-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &Build{b: &types.Bundle{Path: dir, FSObjects: map[string]string{"rootfs": "fs"}}}
+	path := filepath.Join(b.b.Rootfs(), types.TestReportPath)
+
+	// no test was run
+	if err := b.loadTestReport(); err != nil {
+		t.Fatalf("unexpected error without report: %v", err)
+	}
+	if b.TestReport() != nil || b.b.JSONObjects != nil {
+		t.Errorf("unexpected report recorded without report file")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.loadTestReport(); err == nil {
+		t.Errorf("unexpected success with a corrupted report")
+	}
+	if b.TestReport() != nil {
+		t.Errorf("unexpected report recorded from a corrupted report")
+	}
+
+	report := []byte(`{"passed":false,"tests":[{"name":"test","exitCode":1,"passed":false}]}`)
+	if err := ioutil.WriteFile(path, report, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.loadTestReport(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := b.TestReport(); r == nil || r.Passed || !reflect.DeepEqual(r.Failed(), []string{"test"}) {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if !reflect.DeepEqual(b.b.JSONObjects[types.TestReportObject], report) {
+		t.Errorf("report not recorded as SIF object")
+	}
+}
+
+func TestCheckNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		ok      bool
+	}{
+		{"", true},
+		{types.NetworkHost, true},
+		{types.NetworkNone, true},
+		{types.NetworkBridge, true},
+		{"ptp", false},
+		{"Host", false},
+	}
+	for _, tt := range tests {
+		if err := checkNetwork(tt.network); (err == nil) != tt.ok {
+			t.Errorf("network %q accepted: %v, want %v", tt.network, err == nil, tt.ok)
+		}
+	}
+}
+
+func TestSetupNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-network-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "singularity.conf")
+	content := "cni configuration path = /cni/conf\ncni plugin path = /cni/bin\n"
+	if err := ioutil.WriteFile(conf, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write configuration: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		network   string
+		conf      string
+		namespace bool
+		cni       *network.CNIPath
+		ok        bool
+	}{
+		{"default", "", conf, false, nil, true},
+		{"host", types.NetworkHost, conf, false, nil, true},
+		{"none", types.NetworkNone, conf, true, nil, true},
+		{"bridge", types.NetworkBridge, conf, true, &network.CNIPath{Conf: "/cni/conf", Plugin: "/cni/bin"}, true},
+		{"bridge without configuration", types.NetworkBridge, filepath.Join(dir, "missing"), true, nil, false},
+	}
+	for _, tt := range tests {
+		engineConfig := &imgbuild.EngineConfig{OciConfig: &oci.Config{}}
+		err := setupNetwork(tt.network, tt.conf, engineConfig)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: unexpected success", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		namespace := false
+		if linux := engineConfig.OciConfig.Linux; linux != nil {
+			for _, ns := range linux.Namespaces {
+				namespace = namespace || ns.Type == specs.NetworkNamespace
+			}
+		}
+		if namespace != tt.namespace {
+			t.Errorf("%s: network namespace requested: %v, want %v", tt.name, namespace, tt.namespace)
+		}
+		if !reflect.DeepEqual(engineConfig.CNIPath, tt.cni) {
+			t.Errorf("%s: got CNI paths %+v, want %+v", tt.name, engineConfig.CNIPath, tt.cni)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	imagetypes "github.com/containers/image/types"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
+)
+
+// useBuildCache returns whether the root filesystem of this build can be
+// restored from, and saved to, the build cache
+func (b *Build) useBuildCache() bool {
+	return !b.b.Opts.NoCache && (!b.b.Opts.Update || b.b.Opts.Force) && engineRequired(b.d)
+}
+
+// buildCacheKey returns the key of the root filesystem of this build in the
+// build cache. It is the hash of the base image digest, of the definition
+// parts run by the build engine, of the content of the %files sources and of
+// the options changing the result of the build.
+func (b *Build) buildCacheKey() (string, error) {
+	base, err := b.baseDigest()
+	if err != nil {
+		return "", fmt.Errorf("while resolving base image digest: %v", err)
+	}
+	filesSum, err := filesDigest(b.d.BuildData.Files)
+	if err != nil {
+		return "", fmt.Errorf("while hashing %%files: %v", err)
+	}
+
+	key, err := json.Marshal(struct {
+		Base       string
+		Header     map[string]string
+		Setup      string
+		Post       string
+		Test       string
+		Sections   map[string]string
+		Files      string
+		Options    []string
+		NoTest     bool
+		Platform   string
+		Whiteout   string
+		RequireGPG bool
+	}{
+		Base:       base,
+		Header:     b.d.Header,
+		Setup:      b.d.BuildData.Setup,
+		Post:       b.d.BuildData.Post,
+		Test:       b.d.BuildData.Test,
+		Sections:   b.d.BuildData.Sections,
+		Files:      filesSum,
+		Options:    b.b.Opts.Sections,
+		NoTest:     b.b.Opts.NoTest,
+		Platform:   b.b.Opts.Platform,
+		Whiteout:   b.b.Opts.Whiteout,
+		RequireGPG: b.b.Opts.RequireGPG,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// baseDigest returns the digest of the image bootstrapped by the build: the
+// digest of the manifest of OCI sources, and the size and modification time
+// of local images. Other sources are only identified by the definition
+// header, an empty digest is returned for them.
+func (b *Build) baseDigest() (string, error) {
+	h := b.d.Header
+	switch h["bootstrap"] {
+	case "docker", "docker-archive", "docker-daemon", "oci", "oci-archive":
+		ref := h["from"]
+		if h["namespace"] != "" {
+			ref = h["namespace"] + "/" + ref
+		}
+		if h["registry"] != "" {
+			ref = h["registry"] + "/" + ref
+		}
+		uri := h["bootstrap"] + ":" + ref
+		if h["bootstrap"] == "docker" {
+			uri = "docker://" + ref
+		}
+
+		var sys *imagetypes.SystemContext
+		if b.b.Opts.NoHTTPS {
+			sys = &imagetypes.SystemContext{
+				OCIInsecureSkipTLSVerify:    true,
+				DockerInsecureSkipTLSVerify: true,
+			}
+		}
+		return ociclient.ImageSHA(uri, sys)
+	case "localimage":
+		fi, err := os.Stat(h["from"])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano()), nil
+	}
+	return "", nil
+}
+
+// filesDigest returns the hash of the %files transfers, including the path,
+// mode and content of the copied files
+func filesDigest(transfers []types.FileTransport) (string, error) {
+	h := sha256.New()
+	for _, t := range transfers {
+		if err := json.NewEncoder(h).Encode(t); err != nil {
+			return "", err
+		}
+		matches, err := files.Glob(t.Src)
+		if err != nil {
+			return "", err
+		}
+		for _, m := range matches {
+			if err := filepath.Walk(m, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if files.Excluded(t.Exclude, path) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				return hashFile(h, path, info)
+			}); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the path, mode and content of a file to h, symbolic links
+// are followed like %files copies do
+func hashFile(h hash.Hash, path string, info os.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		info = fi
+	}
+	fmt.Fprintf(h, "%s %v\n", path, info.Mode())
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// restoreBuildCache extracts the cached root filesystem with key in the
+// bundle, it returns false if the build cache has no entry for key
+func (b *Build) restoreBuildCache(key string) (bool, error) {
+	exists, err := cache.BuildLayerExists(key)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	path := cache.BuildLayer(key)
+	l, err := cache.Lock(path)
+	if err != nil {
+		return false, err
+	}
+	defer l.Unlock()
+
+	buildLog.Infof("Restoring %%post result from build cache %s", path)
+	if err := b.extractRootfs(path); err != nil {
+		return false, fmt.Errorf("while extracting build cache %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// saveBuildCache stores the root filesystem of the bundle in the build cache
+// with key
+func (b *Build) saveBuildCache(key string) error {
+	path := cache.BuildLayer(key)
+	buildLog.Infof("Saving %%post result to build cache %s", path)
+	return cache.Fetch(path, b.archiveRootfs)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+func TestFilesDigest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-cache-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	write("file", "content")
+	write("excluded", "content")
+
+	transfers := []types.FileTransport{{Src: dir, Dst: "/opt", Exclude: []string{"excluded"}}}
+	digest := func() string {
+		sum, err := filesDigest(transfers)
+		if err != nil {
+			t.Fatalf("failed to hash files: %v", err)
+		}
+		return sum
+	}
+
+	orig := digest()
+	write("excluded", "changed")
+	if sum := digest(); sum != orig {
+		t.Errorf("digest changed with the content of an excluded file")
+	}
+	write("file", "changed")
+	if sum := digest(); sum == orig {
+		t.Errorf("digest unchanged with the content of a copied file")
+	}
+	orig = digest()
+	transfers[0].Dst = "/srv"
+	if sum := digest(); sum == orig {
+		t.Errorf("digest unchanged with the destination of a copy")
+	}
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	key := func(def types.Definition, opts types.Options) string {
+		b := &Build{
+			d: def,
+			b: &types.Bundle{Opts: opts},
+		}
+		k, err := b.buildCacheKey()
+		if err != nil {
+			t.Fatalf("failed to get build cache key: %v", err)
+		}
+		return k
+	}
+
+	def := types.Definition{Header: map[string]string{"bootstrap": "busybox"}}
+	def.BuildData.Post = "true"
+
+	orig := key(def, types.Options{})
+	if k := key(def, types.Options{}); k != orig {
+		t.Errorf("build cache key is not stable")
+	}
+	if k := key(def, types.Options{NoTest: true}); k == orig {
+		t.Errorf("build cache key unchanged with --notest")
+	}
+	def.BuildData.Post = "false"
+	if k := key(def, types.Options{}); k == orig {
+		t.Errorf("build cache key unchanged with %%post")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// checkpointPath returns the path of the checkpoint of the bootstrapped
+// rootfs. It is keyed on the definition header and the options changing the
+// bootstrap result, so that a checkpoint is only reused by the same build.
+func (b *Build) checkpointPath() (string, error) {
+	key, err := json.Marshal(struct {
+		Header     map[string]string
+		Platform   string
+		Whiteout   string
+		RequireGPG bool
+	}{
+		Header:     b.d.Header,
+		Platform:   b.b.Opts.Platform,
+		Whiteout:   b.b.Opts.Whiteout,
+		RequireGPG: b.b.Opts.RequireGPG,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key)
+
+	dir := b.b.Opts.TmpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sbuild-checkpoint-"+hex.EncodeToString(sum[:12])+".tar"), nil
+}
+
+// saveCheckpoint snapshots the bootstrapped rootfs so that a failed build
+// can be resumed without bootstrapping again
+func (b *Build) saveCheckpoint() error {
+	path, err := b.checkpointPath()
+	if err != nil {
+		return err
+	}
+
+	buildLog.Infof("Saving bootstrap checkpoint to %s", path)
+	tmp := path + ".tmp"
+	if err := b.archiveRootfs(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("while creating checkpoint: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// restoreCheckpoint extracts the checkpoint of the bootstrapped rootfs in the
+// bundle, it returns false if there is no checkpoint for this build
+func (b *Build) restoreCheckpoint() (bool, error) {
+	path, err := b.checkpointPath()
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		buildLog.Infof("No checkpoint found, starting build from scratch")
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	buildLog.Infof("Resuming build from bootstrap checkpoint %s", path)
+	if err := b.extractRootfs(path); err != nil {
+		return false, fmt.Errorf("while extracting checkpoint: %v", err)
+	}
+	return true, nil
+}
+
+// removeCheckpoint deletes the checkpoint of a build which completed
+func (b *Build) removeCheckpoint() {
+	path, err := b.checkpointPath()
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		buildLog.Warningf("Could not remove checkpoint %s: %v", path, err)
+	}
+}
+
+// archiveRootfs writes the bundle rootfs to the tar archive path, keeping
+// ownership, permissions and extended attributes
+func (b *Build) archiveRootfs(path string) error {
+	tar := exec.Command("tar", "--numeric-owner", "--xattrs", "-C", b.b.Rootfs(), "-cpf", path, ".")
+	if out, err := tar.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// extractRootfs extracts the tar archive path, written by archiveRootfs, in
+// the bundle rootfs
+func (b *Build) extractRootfs(path string) error {
+	tar := exec.Command("tar", "--numeric-owner", "--xattrs", "-C", b.b.Rootfs(), "-xpf", path)
+	if out, err := tar.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
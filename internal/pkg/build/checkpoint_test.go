@@ -0,0 +1,128 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// newCheckpointBuild returns a build of the definition header in a bundle
+// created in dir
+func newCheckpointBuild(t *testing.T, dir string, header map[string]string) *Build {
+	bundle := filepath.Join(dir, "bundle")
+	if err := os.MkdirAll(filepath.Join(bundle, "rootfs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Build{
+		b: &types.Bundle{
+			Path:      bundle,
+			FSObjects: map[string]string{"rootfs": "rootfs"},
+			Opts:      types.Options{TmpDir: dir, Resume: true},
+		},
+		d: types.Definition{Header: header},
+	}
+}
+
+func TestCheckpointPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := map[string]string{"bootstrap": "docker", "from": "alpine"}
+	path, err := newCheckpointBuild(t, dir, header).checkpointPath()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("checkpoint %s not in the temporary directory %s", path, dir)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(b *Build)
+		same   bool
+	}{
+		{"same build", func(b *Build) {}, true},
+		{"sections", func(b *Build) { b.b.Opts.Sections = []string{"post"} }, true},
+		{"other image", func(b *Build) { b.d.Header = map[string]string{"bootstrap": "docker", "from": "busybox"} }, false},
+		{"platform", func(b *Build) { b.b.Opts.Platform = "arm64" }, false},
+		{"whiteout", func(b *Build) { b.b.Opts.Whiteout = "keep" }, false},
+		{"gpg", func(b *Build) { b.b.Opts.RequireGPG = true }, false},
+	}
+	for _, tt := range tests {
+		b := newCheckpointBuild(t, dir, header)
+		tt.modify(b)
+		p, err := b.checkpointPath()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if (p == path) != tt.same {
+			t.Errorf("%s: checkpoint %s reused: %v, want %v", tt.name, p, p == path, tt.same)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := map[string]string{"bootstrap": "docker", "from": "alpine"}
+	b := newCheckpointBuild(t, dir, header)
+
+	if resumed, err := b.restoreCheckpoint(); err != nil || resumed {
+		t.Fatalf("resumed %v without checkpoint, error %v", resumed, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(b.b.Rootfs(), "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join("etc", "bootstrapped")
+	if err := ioutil.WriteFile(filepath.Join(b.b.Rootfs(), file), []byte("alpine"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.saveCheckpoint(); err != nil {
+		t.Fatalf("unexpected error while saving checkpoint: %s", err)
+	}
+
+	// a new build restores the bootstrapped rootfs
+	resume := newCheckpointBuild(t, filepath.Join(dir, "resume"), header)
+	resume.b.Opts.TmpDir = dir
+	resumed, err := resume.restoreCheckpoint()
+	if err != nil || !resumed {
+		t.Fatalf("checkpoint not restored: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(resume.b.Rootfs(), file))
+	if err != nil {
+		t.Fatalf("bootstrapped file not restored: %s", err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("got permissions %o, want 640", fi.Mode().Perm())
+	}
+
+	// builds of other images don't use it
+	other := newCheckpointBuild(t, filepath.Join(dir, "other"), map[string]string{"bootstrap": "docker", "from": "busybox"})
+	other.b.Opts.TmpDir = dir
+	if resumed, err := other.restoreCheckpoint(); err != nil || resumed {
+		t.Errorf("checkpoint of another build used, error %v", err)
+	}
+
+	resume.removeCheckpoint()
+	path, _ := b.checkpointPath()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint %s not removed: %v", path, err)
+	}
+}
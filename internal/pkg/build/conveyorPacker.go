@@ -6,12 +6,15 @@
 package build
 
 import (
+	"context"
+
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 )
 
-// Conveyor is responsible for downloading from remote sources (library, shub, docker...)
+// Conveyor is responsible for downloading from remote sources (library, shub, docker...),
+// it stops downloading when its context is canceled
 type Conveyor interface {
-	Get(*types.Bundle) error
+	Get(context.Context, *types.Bundle) error
 }
 
 // Packer is the type which is responsible for installing the chroot directory,
@@ -0,0 +1,81 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// AnnotationPrefix prefixes the OCI image-spec annotations
+const AnnotationPrefix = "org.opencontainers.image."
+
+// annotationLabels are the definition labels, matched regardless of their
+// case, from which annotations are derived, in order of precedence
+var annotationLabels = map[string][]string{
+	imagespec.AnnotationAuthors:  {"authors", "author", "maintainer"},
+	imagespec.AnnotationSource:   {"source"},
+	imagespec.AnnotationRevision: {"revision"},
+	imagespec.AnnotationLicenses: {"licenses", "license"},
+}
+
+// labelValue returns the value of the first of keys found in labels,
+// regardless of the case of the label
+func labelValue(labels map[string]string, keys []string) string {
+	for _, key := range keys {
+		for k, v := range labels {
+			if strings.EqualFold(k, key) && v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// git runs git with args in the directory dir and returns its output, empty
+// if git is not installed or dir is not in a git repository
+func git(dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// OCIAnnotations returns the OCI image-spec annotations of an image created
+// at created from a definition with labels. Authors, source, revision and
+// licenses are derived from the labels of the same name, the source and the
+// revision default to the origin remote and the commit of the git repository
+// holding the build context directory, if any.
+func OCIAnnotations(labels map[string]string, created time.Time, context string) map[string]string {
+	annotations := map[string]string{
+		imagespec.AnnotationCreated: created.UTC().Format(time.RFC3339),
+	}
+	for annotation, keys := range annotationLabels {
+		if v := labelValue(labels, keys); v != "" {
+			annotations[annotation] = v
+		}
+	}
+
+	if context == "" {
+		return annotations
+	}
+	if _, ok := annotations[imagespec.AnnotationRevision]; !ok {
+		if commit := git(context, "rev-parse", "HEAD"); commit != "" {
+			annotations[imagespec.AnnotationRevision] = commit
+		}
+	}
+	if _, ok := annotations[imagespec.AnnotationSource]; !ok {
+		if remote := git(context, "config", "--get", "remote.origin.url"); remote != "" {
+			annotations[imagespec.AnnotationSource] = remote
+		}
+	}
+	return annotations
+}
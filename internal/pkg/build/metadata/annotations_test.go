@@ -0,0 +1,68 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestOCIAnnotations(t *testing.T) {
+	created := time.Date(2018, 11, 20, 10, 30, 0, 0, time.UTC)
+
+	labels := map[string]string{
+		"Maintainer": "site <site@example.com>",
+		"License":    "BSD-3-Clause",
+		"Version":    "1.0",
+	}
+	expected := map[string]string{
+		imagespec.AnnotationCreated:  "2018-11-20T10:30:00Z",
+		imagespec.AnnotationAuthors:  "site <site@example.com>",
+		imagespec.AnnotationLicenses: "BSD-3-Clause",
+	}
+	if a := OCIAnnotations(labels, created, ""); !reflect.DeepEqual(a, expected) {
+		t.Errorf("unexpected annotations %v, expected %v", a, expected)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := ioutil.TempDir("", "annotations-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "origin", "https://example.com/site/image.git"},
+		{"-c", "user.name=site", "-c", "user.email=site@example.com", "commit", "-q", "--allow-empty", "-m", "image"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("failed to run git %v: %v: %s", args, err, out)
+		}
+	}
+
+	a := OCIAnnotations(labels, created, dir)
+	if a[imagespec.AnnotationSource] != "https://example.com/site/image.git" {
+		t.Errorf("unexpected source annotation %q", a[imagespec.AnnotationSource])
+	}
+	if commit := git(dir, "rev-parse", "HEAD"); commit == "" || a[imagespec.AnnotationRevision] != commit {
+		t.Errorf("unexpected revision annotation %q, expected %q", a[imagespec.AnnotationRevision], commit)
+	}
+
+	// labels take precedence over the repository
+	labels["revision"] = "v1.0"
+	if a := OCIAnnotations(labels, created, dir); a[imagespec.AnnotationRevision] != "v1.0" {
+		t.Errorf("unexpected revision annotation %q, expected v1.0", a[imagespec.AnnotationRevision])
+	}
+}
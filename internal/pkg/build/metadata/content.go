@@ -0,0 +1,171 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// ContentObject is the name of the SIF data object holding the gzipped
+// content manifest of the container file system
+const ContentObject = "content.json.gz"
+
+// ContentEntry describes a regular file of the container file system
+type ContentEntry struct {
+	// Path is the absolute path of the file in the container
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ContentMismatch is a file of the container file system which doesn't
+// match the content manifest
+type ContentMismatch struct {
+	Path string
+	// Reason is missing, added, modified or unreadable
+	Reason string
+}
+
+// ContentManifest returns the entries of the regular files of the root
+// filesystem rootfs, sorted by path
+func ContentManifest(rootfs string) ([]ContentEntry, error) {
+	return walkContent(rootfs, nil)
+}
+
+// walkContent returns the entries of the regular files of the root
+// filesystem rootfs, sorted by path. Files which can't be read are passed
+// to unreadable and skipped if it is not nil.
+func walkContent(rootfs string, unreadable func(path string)) ([]ContentEntry, error) {
+	var entries []ContentEntry
+	err := filepath.Walk(rootfs, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(rootfs, path)
+		if err != nil {
+			return err
+		}
+		rel = "/" + filepath.ToSlash(rel)
+
+		f, err := os.Open(path)
+		if os.IsPermission(err) && unreadable != nil {
+			unreadable(rel)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		size, err := io.Copy(h, f)
+		if err != nil {
+			return fmt.Errorf("while hashing %s: %s", path, err)
+		}
+
+		entries = append(entries, ContentEntry{
+			Path:   rel,
+			Size:   size,
+			SHA256: fmt.Sprintf("%x", h.Sum(nil)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// EncodeContent returns the gzipped JSON encoding of the content manifest
+// entries, as stored in SIF images
+func EncodeContent(entries []ContentEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(entries); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SIFContent returns the content manifest stored in the SIF image at path,
+// found is false if the image was built without it
+func SIFContent(path string) (entries []ContentEntry, found bool, err error) {
+	data, err := sifObject(path, sif.DataGenericJSON, ContentObject)
+	if err != nil || data == nil {
+		return nil, false, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("while uncompressing content manifest of %s: %s", path, err)
+	}
+	defer gz.Close()
+	data, err = ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, false, fmt.Errorf("while uncompressing content manifest of %s: %s", path, err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, false, fmt.Errorf("while decoding content manifest of %s: %s", path, err)
+	}
+	return entries, true, nil
+}
+
+// VerifyContent checks the regular files of the root filesystem rootfs
+// against the content manifest entries, and returns the files which don't
+// match, sorted by path. Files which can't be read by the current user are
+// returned as unreadable rather than failing the verification.
+func VerifyContent(rootfs string, entries []ContentEntry) ([]ContentMismatch, error) {
+	unreadable := make(map[string]bool)
+	current, err := walkContent(rootfs, func(path string) {
+		unreadable[path] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[string]ContentEntry, len(entries))
+	for _, e := range entries {
+		expected[e.Path] = e
+	}
+
+	var mismatches []ContentMismatch
+	for _, c := range current {
+		e, ok := expected[c.Path]
+		if !ok {
+			mismatches = append(mismatches, ContentMismatch{c.Path, "added"})
+		} else if c != e {
+			mismatches = append(mismatches, ContentMismatch{c.Path, "modified"})
+		}
+		delete(expected, c.Path)
+	}
+	for path := range expected {
+		if unreadable[path] {
+			mismatches = append(mismatches, ContentMismatch{path, "unreadable"})
+			delete(unreadable, path)
+		} else {
+			mismatches = append(mismatches, ContentMismatch{path, "missing"})
+		}
+	}
+	// unreadable files left are not in the manifest
+	for path := range unreadable {
+		mismatches = append(mismatches, ContentMismatch{path, "added"})
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Path < mismatches[j].Path })
+	return mismatches, nil
+}
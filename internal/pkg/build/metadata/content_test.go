@@ -0,0 +1,94 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestContentManifest(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "rootfs-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	write := func(path, content string) {
+		path = filepath.Join(rootfs, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write("etc/hostname", "container\n")
+	write("bin/sh", "")
+	if err := os.Symlink("sh", filepath.Join(rootfs, "bin", "bash")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	entries, err := ContentManifest(rootfs)
+	if err != nil {
+		t.Fatalf("unexpected error creating content manifest: %v", err)
+	}
+	expected := []ContentEntry{
+		{"/bin/sh", 0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"/etc/hostname", 10, "22eba11afa61e1a5c3ff63b99cf17f52d660b77059bf9ee858b8f153db44f152"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("unexpected content manifest %+v", entries)
+	}
+
+	data, err := EncodeContent(entries)
+	if err != nil {
+		t.Fatalf("unexpected error encoding content manifest: %v", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("content manifest is not gzipped: %v", err)
+	}
+	var decoded []ContentEntry
+	if err := json.NewDecoder(gz).Decode(&decoded); err != nil {
+		t.Fatalf("failed to decode content manifest: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, entries) {
+		t.Errorf("unexpected decoded content manifest %+v, expected %+v", decoded, entries)
+	}
+
+	mismatches, err := VerifyContent(rootfs, entries)
+	if err != nil || len(mismatches) != 0 {
+		t.Errorf("unexpected mismatches %+v or error %v of unchanged files", mismatches, err)
+	}
+
+	write("etc/hostname", "tampered\n")
+	write("usr/bin/added", "")
+	os.Remove(filepath.Join(rootfs, "bin", "sh"))
+	mismatches, err = VerifyContent(rootfs, entries)
+	if err != nil {
+		t.Fatalf("unexpected error verifying content: %v", err)
+	}
+	expectedMismatches := []ContentMismatch{
+		{"/bin/sh", "missing"},
+		{"/etc/hostname", "modified"},
+		{"/usr/bin/added", "added"},
+	}
+	if !reflect.DeepEqual(mismatches, expectedMismatches) {
+		t.Errorf("unexpected mismatches %+v, expected %+v", mismatches, expectedMismatches)
+	}
+
+	// images built without a content manifest have none
+	if _, found, err := SIFContent("../../syecl/testdata/container1.sif"); err != nil || found {
+		t.Errorf("unexpected content manifest found %v or error %v", found, err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package metadata stores the metadata of SIF images in data objects, at
+// build time or after the images are built, so they can be read without
+// starting a container
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// LabelObject is the name of the SIF data object holding the labels edited
+// after the build
+const LabelObject = "labels.json"
+
+// LabelPartition returns the labels stored in the labels data object of the
+// SIF image at path, found is false if the image has no such object
+func LabelPartition(path string) (labels map[string]string, found bool, err error) {
+	data, err := sifObject(path, sif.DataLabels, "")
+	if err != nil || data == nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, false, fmt.Errorf("while decoding labels of %s: %s", path, err)
+	}
+	return labels, true, nil
+}
+
+// AddLabelPartition stores labels in the labels data object of the SIF image
+// at path, replacing the existing one. Those labels take precedence over the
+// labels.json file of the container file system, which can't be modified
+// without rebuilding the image. The labels data object is not covered by the
+// signatures of the image partitions.
+func AddLabelPartition(path string, labels map[string]string) error {
+	data, err := json.MarshalIndent(labels, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := deleteLabelPartition(path); err != nil {
+		return err
+	}
+
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return err
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataLabels,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    LabelObject,
+		Data:     data,
+		Size:     int64(len(data)),
+	}
+	if err := fimg.AddObject(input); err != nil {
+		fimg.UnloadContainer()
+		return fmt.Errorf("while adding labels to %s: %s", path, err)
+	}
+	return fimg.UnloadContainer()
+}
+
+// deleteLabelPartition removes the labels data objects of the SIF image at
+// path
+func deleteLabelPartition(path string) error {
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return err
+	}
+
+	var ids []uint32
+	for _, desc := range fimg.DescrArr {
+		if desc.Used && desc.Datatype == sif.DataLabels {
+			ids = append(ids, desc.ID)
+		}
+	}
+	// the space of the object is reclaimed when it is the last of the
+	// image, which is the case once labels were edited
+	for _, id := range ids {
+		if err := fimg.DeleteObject(id, 0); err != nil {
+			fimg.UnloadContainer()
+			return fmt.Errorf("while removing labels of %s: %s", path, err)
+		}
+	}
+	return fimg.UnloadContainer()
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestAddLabelPartition(t *testing.T) {
+	f, err := ioutil.TempFile("", "labels-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	src, err := os.Open("../../syecl/testdata/container1.sif")
+	if err != nil {
+		t.Fatalf("failed to open test image: %v", err)
+	}
+	defer src.Close()
+	if _, err := io.Copy(f, src); err != nil {
+		t.Fatalf("failed to copy test image: %v", err)
+	}
+	f.Close()
+
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatalf("failed to get size of test image: %v", err)
+	}
+
+	if _, found, err := LabelPartition(f.Name()); err != nil || found {
+		t.Fatalf("unexpected labels found %v or error %v", found, err)
+	}
+
+	for _, labels := range []map[string]string{
+		{"maintainer": "site", "version": "1.0"},
+		{"maintainer": "site"},
+	} {
+		if err := AddLabelPartition(f.Name(), labels); err != nil {
+			t.Fatalf("unexpected error adding labels: %v", err)
+		}
+		stored, found, err := LabelPartition(f.Name())
+		if err != nil || !found {
+			t.Fatalf("labels not found: %v", err)
+		}
+		if !reflect.DeepEqual(stored, labels) {
+			t.Errorf("unexpected labels %v, expected %v", stored, labels)
+		}
+	}
+
+	// replaced labels objects don't grow the image
+	if err := AddLabelPartition(f.Name(), map[string]string{}); err != nil {
+		t.Fatalf("unexpected error adding labels: %v", err)
+	}
+	if err := deleteLabelPartition(f.Name()); err != nil {
+		t.Fatalf("unexpected error removing labels: %v", err)
+	}
+	after, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatalf("failed to get size of test image: %v", err)
+	}
+	if after.Size() != info.Size() {
+		t.Errorf("unexpected image size %d after removing labels, expected %d", after.Size(), info.Size())
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/image"
+)
+
+// MetadataObject is the name of the SIF data object holding the scripts of
+// the container and of its SCIF apps
+const MetadataObject = "metadata.json"
+
+// Scripts holds the scripts and help of a container or of a SCIF app, as
+// found in their metadata directory
+type Scripts struct {
+	Runscript   string `json:"runscript,omitempty"`
+	Test        string `json:"test,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Helpfile    string `json:"helpfile,omitempty"`
+	// Labels are only set for apps, the labels of the container are stored
+	// in the labels data object
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Metadata holds the scripts of a container and of its SCIF apps, so they
+// can be read from SIF images without starting a container
+type Metadata struct {
+	Scripts
+	Apps map[string]Scripts `json:"apps,omitempty"`
+}
+
+// scriptFiles are the metadata files read into Scripts, relative to the
+// metadata directory of the container or app
+var scriptFiles = []struct {
+	name string
+	dest func(*Scripts) *string
+}{
+	{"runscript", func(s *Scripts) *string { return &s.Runscript }},
+	{"test", func(s *Scripts) *string { return &s.Test }},
+	{"env/90-environment.sh", func(s *Scripts) *string { return &s.Environment }},
+	{"runscript.help", func(s *Scripts) *string { return &s.Helpfile }},
+}
+
+// readScripts reads the metadata files of app, or of the container if app
+// is empty, from the root filesystem rootfs
+func readScripts(rootfs, app string) (s Scripts, err error) {
+	for _, f := range scriptFiles {
+		data, err := image.ReadSandboxFile(rootfs, image.MetadataPath(f.name, app))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return s, err
+		}
+		*f.dest(&s) = string(data)
+	}
+
+	if app == "" {
+		return s, nil
+	}
+	data, err := image.ReadSandboxFile(rootfs, image.MetadataPath("labels.json", app))
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s.Labels); err != nil {
+		return s, fmt.Errorf("while decoding labels of app %s: %s", app, err)
+	}
+	return s, nil
+}
+
+// Read reads the metadata of the container and of its SCIF apps from the
+// root filesystem rootfs, and returns them along with the labels.json file
+// of the container, nil if it doesn't exist
+func Read(rootfs string) (md *Metadata, labels []byte, err error) {
+	md = &Metadata{}
+	if md.Scripts, err = readScripts(rootfs, ""); err != nil {
+		return nil, nil, err
+	}
+
+	apps, err := image.SandboxApps(rootfs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, app := range apps {
+		s, err := readScripts(rootfs, app)
+		if err != nil {
+			return nil, nil, err
+		}
+		if md.Apps == nil {
+			md.Apps = make(map[string]Scripts)
+		}
+		md.Apps[app] = s
+	}
+
+	labels, err = image.ReadSandboxFile(rootfs, image.MetadataPath("labels.json", ""))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	return md, labels, nil
+}
+
+// sifObject returns the data of the first data object of type datatype,
+// named name if not empty, of the SIF image at path, or nil if there is
+// none
+func sifObject(path string, datatype sif.Datatype, name string) ([]byte, error) {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer fimg.UnloadContainer()
+
+	for _, desc := range fimg.DescrArr {
+		if desc.Used && desc.Datatype == datatype && (name == "" || desc.GetName() == name) {
+			// the data is mapped from the image, copy it before unloading
+			return append([]byte(nil), desc.GetData(&fimg)...), nil
+		}
+	}
+	return nil, nil
+}
+
+// SIFMetadata returns the metadata stored in the SIF image at path, or nil
+// if the image was built without them
+func SIFMetadata(path string) (*Metadata, error) {
+	data, err := sifObject(path, sif.DataGenericJSON, MetadataObject)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	md := &Metadata{}
+	if err := json.Unmarshal(data, md); err != nil {
+		return nil, fmt.Errorf("while decoding metadata of %s: %s", path, err)
+	}
+	return md, nil
+}
+
+// SIFDefinition returns the definition file stored in the SIF image at path,
+// found is false if there is none
+func SIFDefinition(path string) (def string, found bool, err error) {
+	data, err := sifObject(path, sif.DataDeffile, "")
+	if err != nil || data == nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "rootfs-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	write := func(path, content string) {
+		path = filepath.Join(rootfs, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	write(".singularity.d/runscript", "#!/bin/sh\necho run")
+	write(".singularity.d/env/90-environment.sh", "export FOO=bar")
+	write(".singularity.d/labels.json", `{"maintainer": "site"}`)
+	write("scif/apps/foo/scif/runscript", "#!/bin/sh\necho foo")
+	write("scif/apps/foo/scif/runscript.help", "foo help")
+	write("scif/apps/foo/scif/labels.json", `{"APPNAME": "foo"}`)
+
+	md, labels, err := Read(rootfs)
+	if err != nil {
+		t.Fatalf("unexpected error reading metadata: %v", err)
+	}
+
+	expected := &Metadata{
+		Scripts: Scripts{
+			Runscript:   "#!/bin/sh\necho run",
+			Environment: "export FOO=bar",
+		},
+		Apps: map[string]Scripts{
+			"foo": {
+				Runscript: "#!/bin/sh\necho foo",
+				Helpfile:  "foo help",
+				Labels:    map[string]string{"APPNAME": "foo"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(md, expected) {
+		t.Errorf("unexpected metadata %+v, expected %+v", md, expected)
+	}
+	if string(labels) != `{"maintainer": "site"}` {
+		t.Errorf("unexpected labels %q", labels)
+	}
+
+	// malformed app labels are reported
+	write("scif/apps/foo/scif/labels.json", "{")
+	if _, _, err := Read(rootfs); err == nil {
+		t.Errorf("unexpected success reading malformed app labels")
+	}
+}
+
+func TestSIFMetadata(t *testing.T) {
+	// images built before the metadata were stored have none
+	md, err := SIFMetadata("../../syecl/testdata/container1.sif")
+	if err != nil {
+		t.Fatalf("unexpected error reading metadata: %v", err)
+	}
+	if md != nil {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+
+	if _, err := SIFMetadata("metadata_test.go"); err == nil {
+		t.Errorf("unexpected success reading metadata of a non SIF file")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package ocilayout writes root filesystems of containers as single layer
+// images in OCI image layouts, for build targets and bundle exports.
+package ocilayout
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// RefName is the reference name of the image in the OCI layout index
+const RefName = "latest"
+
+// Write writes rootfs as a single layer image in an OCI image layout at
+// path, createdBy is recorded in the history of the image
+func Write(ctx context.Context, rootfs, path, createdBy string) error {
+	blobs := filepath.Join(path, "blobs", string(digest.SHA256))
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		return err
+	}
+
+	layer, diffID, err := writeLayer(ctx, rootfs, blobs)
+	if err != nil {
+		return fmt.Errorf("while creating layer: %s", err)
+	}
+
+	imageConfig := imageConfig(rootfs, diffID, createdBy)
+	config, err := writeBlob(blobs, imagespec.MediaTypeImageConfig, imageConfig)
+	if err != nil {
+		return err
+	}
+
+	// OCI annotations added to the labels at build time are also set on
+	// the manifest, where the image-spec defines them
+	var annotations map[string]string
+	for k, v := range imageConfig.Config.Labels {
+		if strings.HasPrefix(k, metadata.AnnotationPrefix) {
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[k] = v
+		}
+	}
+
+	manifest, err := writeBlob(blobs, imagespec.MediaTypeImageManifest, imagespec.Manifest{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		Config:      config,
+		Layers:      []imagespec.Descriptor{layer},
+		Annotations: annotations,
+	})
+	if err != nil {
+		return err
+	}
+	manifest.Platform = &imagespec.Platform{
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+	}
+	manifest.Annotations = map[string]string{
+		imagespec.AnnotationRefName: RefName,
+	}
+
+	index, err := json.Marshal(imagespec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: []imagespec.Descriptor{manifest},
+	})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "index.json"), index, 0644); err != nil {
+		return err
+	}
+
+	ociLayout, err := json.Marshal(imagespec.ImageLayout{Version: imagespec.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(path, imagespec.ImageLayoutFile), ociLayout, 0644)
+}
+
+// writeLayer writes the gzipped tarball of rootfs in the blobs
+// directory, it returns its descriptor and the digest of the uncompressed
+// tarball
+func writeLayer(ctx context.Context, rootfs, blobs string) (desc imagespec.Descriptor, diffID digest.Digest, err error) {
+	tmp, err := ioutil.TempFile(blobs, ".layer-")
+	if err != nil {
+		return desc, diffID, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	tar := exec.CommandContext(ctx, "tar", "--numeric-owner", "--xattrs", "-C", rootfs, "-cpf", "-", ".")
+	stdout, err := tar.StdoutPipe()
+	if err != nil {
+		return desc, diffID, err
+	}
+	stderr := &limitedBuffer{max: 4096}
+	tar.Stderr = stderr
+	if err := tar.Start(); err != nil {
+		return desc, diffID, err
+	}
+
+	diffDigester := digest.SHA256.Digester()
+	blobDigester := digest.SHA256.Digester()
+	counter := &countWriter{w: io.MultiWriter(tmp, blobDigester.Hash())}
+	gz := gzip.NewWriter(counter)
+
+	_, copyErr := io.Copy(io.MultiWriter(gz, diffDigester.Hash()), stdout)
+	if err := tar.Wait(); err != nil {
+		return desc, diffID, fmt.Errorf("%s: %s", err, stderr.buf)
+	}
+	if copyErr != nil {
+		return desc, diffID, copyErr
+	}
+	if err := gz.Close(); err != nil {
+		return desc, diffID, err
+	}
+	if err := tmp.Close(); err != nil {
+		return desc, diffID, err
+	}
+
+	desc = imagespec.Descriptor{
+		MediaType: imagespec.MediaTypeImageLayerGzip,
+		Digest:    blobDigester.Digest(),
+		Size:      counter.n,
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(blobs, desc.Digest.Hex())); err != nil {
+		return desc, diffID, err
+	}
+	return desc, diffDigester.Digest(), nil
+}
+
+// writeBlob writes the JSON encoding of v in the blobs directory and
+// returns its descriptor
+func writeBlob(blobs, mediaType string, v interface{}) (desc imagespec.Descriptor, err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return desc, err
+	}
+
+	desc = imagespec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	return desc, ioutil.WriteFile(filepath.Join(blobs, desc.Digest.Hex()), data, 0644)
+}
+
+// imageConfig returns the OCI image configuration of rootfs. The labels of
+// the container are kept and the run action, which sources the container
+// environment before executing the runscript, is set as entrypoint.
+func imageConfig(rootfs string, diffID digest.Digest, createdBy string) imagespec.Image {
+	created := time.Now().UTC()
+
+	config := imagespec.ImageConfig{
+		Env: []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+	}
+	if _, err := os.Stat(filepath.Join(rootfs, ".singularity.d/actions/run")); err == nil {
+		config.Entrypoint = []string{"/.singularity.d/actions/run"}
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(rootfs, ".singularity.d/labels.json")); err == nil {
+		if err := json.Unmarshal(data, &config.Labels); err != nil {
+			sylog.Warningf("Unable to read container labels: %s", err)
+		}
+	}
+
+	return imagespec.Image{
+		Created:      &created,
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		Config:       config,
+		RootFS: imagespec.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{diffID},
+		},
+		History: []imagespec.History{
+			{
+				Created:   &created,
+				CreatedBy: createdBy,
+			},
+		},
+	}
+}
+
+// countWriter counts the bytes written to w
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	buf []byte
+	max int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if room := l.max - len(l.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		l.buf = append(l.buf, p[:room]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// progressBuffer is the number of events buffered in the progress channel
+const progressBuffer = 16
+
+// Progress returns the channel on which the events of the next call to Full
+// are sent. The channel is closed when Full returns, and must be drained by
+// the caller for the build to progress.
+func (b *Build) Progress() <-chan types.Event {
+	if b.progress == nil {
+		b.progress = make(chan types.Event, progressBuffer)
+	}
+	return b.progress
+}
+
+// emit sends an event on the progress channel, if any
+func (b *Build) emit(t types.EventType, stage, message string) {
+	if b.progress == nil {
+		return
+	}
+	b.progress <- types.Event{
+		Type:    t,
+		Time:    time.Now(),
+		Stage:   stage,
+		Message: message,
+	}
+}
+
+// endProgress sends the error of a failed build and closes the progress
+// channel
+func (b *Build) endProgress(err error) {
+	if b.progress == nil {
+		return
+	}
+	if err != nil {
+		b.emit(types.EventError, "", err.Error())
+	}
+	close(b.progress)
+	b.progress = nil
+}
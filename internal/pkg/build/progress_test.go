@@ -0,0 +1,125 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// progressPacker packs an empty container, or fails with err
+type progressPacker struct {
+	b   *types.Bundle
+	err error
+}
+
+func (p *progressPacker) Get(ctx context.Context, b *types.Bundle) error {
+	p.b = b
+	return nil
+}
+
+func (p *progressPacker) Pack() (*types.Bundle, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.b, os.MkdirAll(filepath.Join(p.b.Rootfs(), ".singularity.d", "env"), 0755)
+}
+
+// progressAssembler assembles nothing
+type progressAssembler struct{}
+
+func (a progressAssembler) Assemble(ctx context.Context, b *types.Bundle, path string) error {
+	return nil
+}
+
+func TestProgressWithoutChannel(t *testing.T) {
+	b := &Build{}
+	// events are dropped when nobody asked for them
+	b.emit(types.EventStageStarted, types.StagePre, "")
+	b.endProgress(fmt.Errorf("failed"))
+}
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-progress-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	type event struct {
+		Type  types.EventType
+		Stage string
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		events []event
+	}{
+		{
+			name: "success",
+			events: []event{
+				{types.EventStageStarted, types.StageBootstrap},
+				{types.EventConveyorDone, types.StageBootstrap},
+				{types.EventStageStarted, types.StageMetadata},
+				{types.EventStageStarted, types.StageAssemble},
+				{types.EventAssembleDone, types.StageAssemble},
+			},
+		},
+		{
+			name: "failure",
+			err:  fmt.Errorf("pack failed"),
+			events: []event{
+				{types.EventStageStarted, types.StageBootstrap},
+				{types.EventError, ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		bundle, err := types.NewBundle(dir, "sbuild")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle.Opts.NoCache = true
+		b := &Build{
+			dest:   filepath.Join(dir, "image"),
+			format: "sandbox",
+			b:      bundle,
+			c:      &progressPacker{err: tt.err},
+			a:      progressAssembler{},
+		}
+
+		var events []event
+		done := make(chan struct{})
+		progress := b.Progress()
+		go func() {
+			for e := range progress {
+				events = append(events, event{e.Type, e.Stage})
+			}
+			close(done)
+		}()
+
+		err = b.Full(context.Background())
+		// the channel is closed once the build returns
+		<-done
+
+		if tt.err == nil && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		} else if tt.err != nil && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		}
+		if !reflect.DeepEqual(events, tt.events) {
+			t.Errorf("%s: got events %v, want %v", tt.name, events, tt.events)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remotebuilder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/pkg/errors"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/jsonresp"
+	"github.com/sylabs/singularity/pkg/util/user-agent"
+)
+
+const (
+	// maxJobs is the number of submitted builds kept in the jobs file
+	maxJobs = 100
+	// maxReconnect is the number of reconnections attempted while
+	// following build logs
+	maxReconnect = 10
+)
+
+// reconnectDelay is the delay between two reconnections while following
+// build logs
+var reconnectDelay = 2 * time.Second
+
+// Job is a remote build submitted from this host
+type Job struct {
+	ID         string    `json:"id"`
+	ImagePath  string    `json:"imagePath"`
+	BuilderURL string    `json:"builderURL"`
+	SubmitTime time.Time `json:"submitTime"`
+}
+
+// ReadJobs returns the remote builds recorded in the jobs file at path
+func ReadJobs(path string) ([]Job, error) {
+	var jobs []Job
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return jobs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, fmt.Errorf("while decoding %s: %v", path, err)
+	}
+	return jobs, nil
+}
+
+// recordJob adds job to the jobs file at path, only the last maxJobs
+// builds are kept
+func recordJob(path string, job Job) error {
+	jobs, err := ReadJobs(path)
+	if err != nil {
+		return err
+	}
+	jobs = append(jobs, job)
+	if len(jobs) > maxJobs {
+		jobs = jobs[len(jobs)-maxJobs:]
+	}
+
+	b, err := json.MarshalIndent(jobs, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// parseID checks and converts a build ID
+func parseID(id string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(id) {
+		return "", fmt.Errorf("invalid build ID %s", id)
+	}
+	return bson.ObjectIdHex(id), nil
+}
+
+// Status returns the status of the build with the given ID
+func (rb *RemoteBuilder) Status(ctx context.Context, id string) (types.ResponseData, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return types.ResponseData{}, err
+	}
+	return rb.doStatusRequest(ctx, oid)
+}
+
+// Cancel cancels the build with the given ID
+func (rb *RemoteBuilder) Cancel(ctx context.Context, id string) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return rb.doCancelRequest(ctx, oid)
+}
+
+// Logs streams the output of the build with the given ID to the console.
+// When follow is set, the connection is reestablished if it's lost before
+// the build completes.
+func (rb *RemoteBuilder) Logs(ctx context.Context, id string, follow bool) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		rd, err := rb.doStatusRequest(ctx, oid)
+		if err != nil {
+			return errors.Wrap(err, "failed to get status from remote build service")
+		}
+
+		err = rb.streamOutput(ctx, rd.WSURL)
+		if err == nil || !follow || rd.IsComplete || ctx.Err() != nil {
+			return err
+		}
+		if attempt == maxReconnect {
+			return errors.Wrap(err, "failed to stream output from remote build service")
+		}
+
+		sylog.Warningf("Lost connection to remote build service, reconnecting: %v", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// doCancelRequest cancels a build on the Remote Build Service
+func (rb *RemoteBuilder) doCancelRequest(ctx context.Context, id bson.ObjectId) error {
+	req, err := http.NewRequest(http.MethodPut, rb.BuilderURL.String()+"/v1/build/"+id.Hex()+"/_cancel", nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	rb.setAuthHeader(req.Header)
+	req.Header.Set("User-Agent", useragent.Value())
+	sylog.Debugf("Sending cancel request to %s", req.URL.String())
+
+	res, err := rb.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var rd types.ResponseData
+	return jsonresp.ReadResponse(res.Body, &rd)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remotebuilder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remotebuilder-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sub", "remote-builds.json")
+
+	jobs, err := ReadJobs(path)
+	if err != nil {
+		t.Fatalf("unexpected failure reading missing jobs file: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("unexpected jobs: %v", jobs)
+	}
+
+	for i := 0; i < maxJobs+2; i++ {
+		job := Job{
+			ID:         fmt.Sprintf("%024x", i),
+			ImagePath:  "image.sif",
+			SubmitTime: time.Now(),
+		}
+		if err := recordJob(path, job); err != nil {
+			t.Fatalf("failed to record job: %v", err)
+		}
+	}
+
+	jobs, err = ReadJobs(path)
+	if err != nil {
+		t.Fatalf("failed to read jobs: %v", err)
+	}
+	if len(jobs) != maxJobs {
+		t.Fatalf("unexpected number of jobs: %d instead of %d", len(jobs), maxJobs)
+	}
+	if jobs[0].ID != fmt.Sprintf("%024x", 2) {
+		t.Errorf("oldest jobs were not dropped, first job is %s", jobs[0].ID)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		id            string
+		expectSuccess bool
+	}{
+		{"5bd1c0d0b3f2c80001f0b0a5", true},
+		{"5bd1c0d0b3f2c80001f0b0a", false},
+		{"not-an-id", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		_, err := parseID(tt.id)
+		if tt.expectSuccess && err != nil {
+			t.Errorf("unexpected failure for %q: %v", tt.id, err)
+		} else if !tt.expectSuccess && err == nil {
+			t.Errorf("unexpected success for %q", tt.id)
+		}
+	}
+}
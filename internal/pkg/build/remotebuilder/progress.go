@@ -0,0 +1,52 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remotebuilder
+
+import (
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// progressBuffer is the number of events buffered in the progress channel
+const progressBuffer = 16
+
+// Progress returns the channel on which the events of the next call to Build
+// are sent, the output of the remote build is then sent as EventOutput events
+// instead of being printed. The channel is closed when Build returns, and
+// must be drained by the caller for the build to progress.
+func (rb *RemoteBuilder) Progress() <-chan types.Event {
+	if rb.progress == nil {
+		rb.progress = make(chan types.Event, progressBuffer)
+	}
+	return rb.progress
+}
+
+// emit sends an event on the progress channel, if any
+func (rb *RemoteBuilder) emit(t types.EventType, message string) {
+	if rb.progress == nil {
+		return
+	}
+	rb.progress <- types.Event{
+		Type:    t,
+		Time:    time.Now(),
+		Stage:   types.StageRemote,
+		Message: message,
+	}
+}
+
+// endProgress sends the error of a failed build and closes the progress
+// channel
+func (rb *RemoteBuilder) endProgress(err error) {
+	if rb.progress == nil {
+		return
+	}
+	if err != nil {
+		rb.emit(types.EventError, err.Error())
+	}
+	close(rb.progress)
+	rb.progress = nil
+}
@@ -38,6 +38,10 @@ type RemoteBuilder struct {
 	IsDetached bool
 	BuilderURL *url.URL
 	AuthToken  string
+	// JobsFile records submitted builds when set
+	JobsFile string
+	// progress receives the events of the build, if requested with Progress()
+	progress chan types.Event
 }
 
 func (rb *RemoteBuilder) setAuthHeader(h http.Header) {
@@ -71,6 +75,8 @@ func New(imagePath, libraryURL string, d types.Definition, isDetached, force boo
 
 // Build is responsible for making the request via the REST API to the remote builder
 func (rb *RemoteBuilder) Build(ctx context.Context) (err error) {
+	defer func() { rb.endProgress(err) }()
+
 	var libraryRef string
 
 	if strings.HasPrefix(rb.ImagePath, "library://") {
@@ -85,6 +91,19 @@ func (rb *RemoteBuilder) Build(ctx context.Context) (err error) {
 		sylog.Warningf("%v", err)
 		return err
 	}
+	rb.emit(types.EventStageStarted, rd.ID.Hex())
+
+	if rb.JobsFile != "" {
+		job := Job{
+			ID:         rd.ID.Hex(),
+			ImagePath:  rb.ImagePath,
+			BuilderURL: rb.BuilderURL.String(),
+			SubmitTime: time.Now(),
+		}
+		if err := recordJob(rb.JobsFile, job); err != nil {
+			sylog.Warningf("Unable to record build %s: %v", job.ID, err)
+		}
+	}
 
 	// If we're doing an detached build, print help on how to download the image
 	libraryRefRaw := strings.TrimPrefix(rd.LibraryRef, "library://")
@@ -128,6 +147,7 @@ func (rb *RemoteBuilder) Build(ctx context.Context) (err error) {
 				return err
 			}
 		}
+		rb.emit(types.EventAssembleDone, rb.ImagePath)
 	}
 
 	return nil
@@ -167,7 +187,11 @@ func (rb *RemoteBuilder) streamOutput(ctx context.Context, url string) (err erro
 		// Print to terminal
 		switch mt {
 		case websocket.TextMessage:
-			fmt.Printf("%s", msg)
+			if rb.progress != nil {
+				rb.emit(types.EventOutput, string(msg))
+			} else {
+				fmt.Printf("%s", msg)
+			}
 		case websocket.BinaryMessage:
 			fmt.Print("Ignoring binary message")
 		}
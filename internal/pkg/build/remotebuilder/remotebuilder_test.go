@@ -41,6 +41,7 @@ type mockService struct {
 	wsResponseCode     int
 	wsCloseCode        int
 	statusResponseCode int
+	cancelResponseCode int
 	imageResponseCode  int
 	httpAddr           string
 }
@@ -92,6 +93,17 @@ func (m *mockService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			jsonresp.WriteError(w, "", m.buildResponseCode)
 		}
+	} else if r.Method == http.MethodPut && strings.HasPrefix(r.RequestURI, buildPath) && strings.HasSuffix(r.RequestURI, "/_cancel") {
+		// Mock cancel endpoint
+		id := strings.TrimSuffix(strings.TrimPrefix(r.RequestURI, buildPath+"/"), "/_cancel")
+		if !bson.IsObjectIdHex(id) {
+			m.t.Fatalf("failed to parse ID '%v'", id)
+		}
+		if m.cancelResponseCode == http.StatusOK {
+			jsonresp.WriteResponse(w, newResponse(m, bson.ObjectIdHex(id), types.Definition{}, ""), m.cancelResponseCode)
+		} else {
+			jsonresp.WriteError(w, "", m.cancelResponseCode)
+		}
 	} else if r.Method == http.MethodGet && strings.HasPrefix(r.RequestURI, buildPath) {
 		// Mock status endpoint
 		id := r.RequestURI[strings.LastIndexByte(r.RequestURI, '/')+1:]
@@ -222,6 +234,68 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+func TestBuildProgress(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	f, err := ioutil.TempFile("/tmp", "TestBuildProgress")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	m := mockService{
+		t:                  t,
+		buildResponseCode:  http.StatusCreated,
+		wsResponseCode:     http.StatusOK,
+		wsCloseCode:        websocket.CloseNormalClosure,
+		statusResponseCode: http.StatusOK,
+		imageResponseCode:  http.StatusOK,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", m.ServeHTTP)
+	mux.HandleFunc(wsPath, m.ServeWebsocket)
+	s := httptest.NewServer(mux)
+	defer s.Close()
+	m.httpAddr = s.Listener.Addr().String()
+
+	rb, err := New(f.Name(), "", types.Definition{}, false, true, s.URL, authToken)
+	if err != nil {
+		t.Fatalf("failed to get new remote builder: %v", err)
+	}
+
+	var events []types.Event
+	done := make(chan struct{})
+	go func() {
+		for e := range rb.Progress() {
+			events = append(events, e)
+		}
+		close(done)
+	}()
+
+	if err := rb.Build(context.Background()); err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	<-done
+
+	expected := []types.EventType{types.EventStageStarted, types.EventOutput, types.EventAssembleDone}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	for i, e := range events {
+		if e.Type != expected[i] || e.Stage != types.StageRemote {
+			t.Errorf("unexpected event %v, expected %s", e, expected[i])
+		}
+	}
+	if events[1].Message != stdoutContents {
+		t.Errorf("unexpected output %q, expected %q", events[1].Message, stdoutContents)
+	}
+	if events[2].Message != f.Name() {
+		t.Errorf("unexpected image path %q, expected %q", events[2].Message, f.Name())
+	}
+}
+
 func TestDoBuildRequest(t *testing.T) {
 	// Craft an expired context
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
@@ -359,3 +433,55 @@ func TestDoStatusRequest(t *testing.T) {
 		}))
 	}
 }
+
+func TestDoCancelRequest(t *testing.T) {
+	// Craft an expired context
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	// Table of tests to run
+	tests := []struct {
+		description   string
+		expectSuccess bool
+		responseCode  int
+		ctx           context.Context
+	}{
+		{"Success", true, http.StatusOK, context.Background()},
+		{"NotFound", false, http.StatusNotFound, context.Background()},
+		{"Conflict", false, http.StatusConflict, context.Background()},
+		{"ContextExpired", false, http.StatusOK, ctx},
+	}
+
+	// Start a mock server
+	m := mockService{t: t}
+	s := httptest.NewServer(&m)
+	defer s.Close()
+
+	// Enough of a struct to test with
+	url, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+	rb := RemoteBuilder{
+		BuilderURL: url,
+	}
+
+	// ID to test with
+	id := bson.NewObjectId()
+
+	// Loop over test cases
+	for _, tt := range tests {
+		t.Run(tt.description, test.WithoutPrivilege(func(t *testing.T) {
+			m.cancelResponseCode = tt.responseCode
+
+			// Call the handler
+			err := rb.doCancelRequest(tt.ctx, id)
+
+			if tt.expectSuccess && err != nil {
+				t.Fatalf("unexpected failure: %v", err)
+			} else if !tt.expectSuccess && err == nil {
+				t.Fatalf("unexpected success")
+			}
+		}))
+	}
+}
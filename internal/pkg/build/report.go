@@ -0,0 +1,95 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
+)
+
+// Report describes the image produced by a build
+type Report struct {
+	Image        string               `json:"image"`
+	Format       string               `json:"format"`
+	Size         int64                `json:"size"`
+	Digest       string               `json:"digest,omitempty"`
+	Labels       map[string]string    `json:"labels"`
+	Architecture string               `json:"architecture"`
+	Duration     float64              `json:"duration"`
+	Timings      types.TimingReport   `json:"timings"`
+	Cache        ociclient.CacheStats `json:"cache"`
+	Warnings     []string             `json:"warnings"`
+}
+
+// Report returns the report of a completed build, the content digest is
+// only computed for image files
+func (b *Build) Report() (*Report, error) {
+	r := &Report{
+		Image:        b.dest,
+		Format:       b.format,
+		Labels:       make(map[string]string),
+		Architecture: runtime.GOARCH,
+		Duration:     b.duration.Seconds(),
+		Timings:      b.Timings(),
+		Cache:        ociclient.GetCacheStats(),
+		Warnings:     []string{},
+	}
+
+	fi, err := os.Stat(b.dest)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sandbox assembler moves the bundle root filesystem to dest
+	rootfs := b.b.Rootfs()
+	if fi.IsDir() {
+		rootfs = b.dest
+	}
+	labels, err := ioutil.ReadFile(filepath.Join(rootfs, "/.singularity.d/labels.json"))
+	if err == nil {
+		if err := json.Unmarshal(labels, &r.Labels); err != nil {
+			return nil, fmt.Errorf("while decoding labels: %v", err)
+		}
+	}
+
+	if !fi.IsDir() {
+		r.Size = fi.Size()
+		r.Digest, err = fileDigest(b.dest)
+		return r, err
+	}
+
+	err = filepath.Walk(b.dest, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			r.Size += info.Size()
+		}
+		return nil
+	})
+	return r, err
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("while computing digest of %s: %v", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
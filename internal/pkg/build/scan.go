@@ -0,0 +1,61 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/build/scan"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// runScan scans the bundle rootfs for vulnerabilities and records the report
+// so it can be stored by the assembler alongside the image. It fails if
+// vulnerabilities reach the severity threshold of the build.
+func (b *Build) runScan() error {
+	opts := b.b.Opts
+	s, err := scan.New(opts.Scan, scan.Config{URL: opts.ScanURL, NoHTTPS: opts.NoHTTPS})
+	if err != nil {
+		return err
+	}
+
+	buildLog.Infof("Scanning container for vulnerabilities with %s scanner", opts.Scan)
+	started := time.Now()
+	report, err := s.Scan(b.b.Rootfs())
+	if err != nil {
+		return fmt.Errorf("while scanning container: %v", err)
+	}
+	report.Scanner = opts.Scan
+	report.Started = started
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if b.b.JSONObjects == nil {
+		b.b.JSONObjects = make(map[string][]byte)
+	}
+	b.b.JSONObjects[types.ScanReportObject] = data
+
+	threshold := scan.SeverityLevel(opts.ScanSeverity)
+	failed := 0
+	for _, v := range report.Vulnerabilities {
+		if scan.SeverityLevel(v.Severity) >= threshold {
+			failed++
+			buildLog.Errorf("%s: %s %s has a %s severity vulnerability", v.ID, v.Package, v.Version, v.Severity)
+		} else {
+			buildLog.Warningf("%s: %s %s has a %s severity vulnerability", v.ID, v.Package, v.Version, v.Severity)
+		}
+	}
+	buildLog.Infof("Scanned %d packages, %d vulnerabilities found", report.Packages, len(report.Vulnerabilities))
+
+	if failed > 0 {
+		return fmt.Errorf("%d vulnerabilities of %s severity or higher found", failed, opts.ScanSeverity)
+	}
+	return nil
+}
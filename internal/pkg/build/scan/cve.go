@@ -0,0 +1,98 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package scan
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/user-agent"
+)
+
+// cveTimeout is the timeout of requests to the CVE database
+const cveTimeout = 60 * time.Second
+
+// cveScanner looks up the installed packages of the container in a CVE
+// database. The database is queried with a POST request to <URL>/v1/scan
+// with a JSON body {"packages": [{"name", "version", "ecosystem"}]}, and
+// answers with {"vulnerabilities": [{"id", "package", "version",
+// "severity", "description"}]}.
+type cveScanner struct {
+	cfg    Config
+	client *http.Client
+}
+
+type cveRequest struct {
+	Packages []types.Package `json:"packages"`
+}
+
+type cveResponse struct {
+	Vulnerabilities []types.Vulnerability `json:"vulnerabilities"`
+}
+
+func newCVEScanner(cfg Config) Scanner {
+	client := &http.Client{Timeout: cveTimeout}
+	if cfg.NoHTTPS {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return &cveScanner{cfg: cfg, client: client}
+}
+
+// Scan queries the CVE database for the installed packages of rootfs
+func (s *cveScanner) Scan(rootfs string) (report types.ScanReport, err error) {
+	if s.cfg.URL == "" {
+		return report, fmt.Errorf("no CVE database URL set")
+	}
+
+	pkgs, err := InstalledPackages(rootfs)
+	if err != nil {
+		return report, err
+	}
+	report.Packages = len(pkgs)
+	if len(pkgs) == 0 {
+		sylog.Warningf("No dpkg, apk or rpm packages found to scan")
+		return report, nil
+	}
+
+	body, err := json.Marshal(cveRequest{Packages: pkgs})
+	if err != nil {
+		return report, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(s.cfg.URL, "/")+"/v1/scan", bytes.NewReader(body))
+	if err != nil {
+		return report, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", useragent.Value())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return report, fmt.Errorf("while querying CVE database: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("CVE database returned %s", res.Status)
+	}
+
+	var r cveResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return report, fmt.Errorf("while decoding CVE database response: %v", err)
+	}
+	for i, v := range r.Vulnerabilities {
+		r.Vulnerabilities[i].Severity = strings.ToLower(v.Severity)
+	}
+	report.Vulnerabilities = r.Vulnerabilities
+	return report, nil
+}
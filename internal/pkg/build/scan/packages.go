@@ -0,0 +1,124 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+const (
+	dpkgStatus   = "var/lib/dpkg/status"
+	apkInstalled = "lib/apk/db/installed"
+	rpmDatabase  = "var/lib/rpm"
+)
+
+// InstalledPackages returns the packages installed in rootfs by dpkg, apk
+// and, if rpm is available on the host, rpm
+func InstalledPackages(rootfs string) ([]types.Package, error) {
+	var pkgs []types.Package
+
+	for _, db := range []struct {
+		path  string
+		parse func([]byte) []types.Package
+	}{
+		{dpkgStatus, parseDpkgStatus},
+		{apkInstalled, parseApkInstalled},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(rootfs, db.path))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, db.parse(data)...)
+	}
+
+	if _, err := os.Stat(filepath.Join(rootfs, rpmDatabase)); err == nil {
+		rpm, err := exec.LookPath("rpm")
+		if err != nil {
+			return nil, fmt.Errorf("rpm is required to list the packages of rpm based containers")
+		}
+		out, err := exec.Command(rpm, "--root", rootfs, "-qa", "--qf", `%{NAME} %|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\n`).Output()
+		if err != nil {
+			return nil, fmt.Errorf("while listing rpm packages: %v", err)
+		}
+		pkgs = append(pkgs, parseRpmList(out)...)
+	}
+
+	return pkgs, nil
+}
+
+// parseDpkgStatus returns the installed packages of a dpkg status file
+func parseDpkgStatus(data []byte) []types.Package {
+	var pkgs []types.Package
+
+	for _, entry := range bytes.Split(data, []byte("\n\n")) {
+		var p types.Package
+		installed := false
+		s := bufio.NewScanner(bytes.NewReader(entry))
+		for s.Scan() {
+			line := s.Text()
+			switch {
+			case strings.HasPrefix(line, "Package: "):
+				p.Name = strings.TrimPrefix(line, "Package: ")
+			case strings.HasPrefix(line, "Version: "):
+				p.Version = strings.TrimPrefix(line, "Version: ")
+			case strings.HasPrefix(line, "Status: "):
+				installed = strings.HasSuffix(line, " installed")
+			}
+		}
+		if installed && p.Name != "" {
+			p.Ecosystem = "deb"
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+// parseApkInstalled returns the packages of an apk installed database
+func parseApkInstalled(data []byte) []types.Package {
+	var pkgs []types.Package
+
+	for _, entry := range bytes.Split(data, []byte("\n\n")) {
+		p := types.Package{Ecosystem: "apk"}
+		s := bufio.NewScanner(bytes.NewReader(entry))
+		for s.Scan() {
+			line := s.Text()
+			switch {
+			case strings.HasPrefix(line, "P:"):
+				p.Name = strings.TrimPrefix(line, "P:")
+			case strings.HasPrefix(line, "V:"):
+				p.Version = strings.TrimPrefix(line, "V:")
+			}
+		}
+		if p.Name != "" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+// parseRpmList returns the packages listed by rpm -qa as name version lines
+func parseRpmList(data []byte) []types.Package {
+	var pkgs []types.Package
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			pkgs = append(pkgs, types.Package{Name: fields[0], Version: fields[1], Ecosystem: "rpm"})
+		}
+	}
+	return pkgs
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package scan provides the vulnerability scanners run on the container
+// filesystem at the end of a build
+package scan
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// Scanner finds the known vulnerabilities of a container filesystem, it
+// returns a report with the number of packages scanned and the
+// vulnerabilities found
+type Scanner interface {
+	Scan(rootfs string) (types.ScanReport, error)
+}
+
+// Config holds the settings passed to Scanner factories
+type Config struct {
+	// URL is the address of the vulnerability database
+	URL string
+	// NoHTTPS allows insecure connections to the vulnerability database
+	NoHTTPS bool
+}
+
+// ScannerFactory creates the Scanner of a build
+type ScannerFactory func(cfg Config) Scanner
+
+var registry = struct {
+	sync.Mutex
+	factories map[string]ScannerFactory
+}{
+	factories: map[string]ScannerFactory{
+		"cve": newCVEScanner,
+	},
+}
+
+// Register adds a scanner which can be selected with build --scan <name>
+func Register(name string, factory ScannerFactory) error {
+	if name == "" || factory == nil {
+		return fmt.Errorf("scanner name and factory are required")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.factories[name]; ok {
+		return fmt.Errorf("scanner already registered: %s", name)
+	}
+	registry.factories[name] = factory
+	return nil
+}
+
+// New returns the Scanner name
+func New(name string, cfg Config) (Scanner, error) {
+	registry.Lock()
+	factory, ok := registry.factories[name]
+	registry.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown scanner %s", name)
+	}
+	return factory(cfg), nil
+}
+
+// IsRegistered returns whether name is a known scanner
+func IsRegistered(name string) bool {
+	registry.Lock()
+	defer registry.Unlock()
+
+	_, ok := registry.factories[name]
+	return ok
+}
+
+// SeverityLevel returns the rank of severity in types.Severities, or -1 if
+// severity is unknown
+func SeverityLevel(severity string) int {
+	for i, s := range types.Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
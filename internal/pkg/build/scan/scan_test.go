@@ -0,0 +1,145 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package scan
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
+)
+
+const testDpkgStatus = `Package: libc6
+Status: install ok installed
+Version: 2.27-3ubuntu1
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+
+Package: openssl
+Status: install ok installed
+Architecture: amd64
+Version: 1.1.0g-2ubuntu4.1
+`
+
+const testApkInstalled = `C:Q1abc=
+P:musl
+V:1.1.19-r10
+
+P:busybox
+V:1.28.4-r1
+`
+
+func TestMain(m *testing.M) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	os.Exit(m.Run())
+}
+
+func TestParsePackages(t *testing.T) {
+	expected := []types.Package{
+		{Name: "libc6", Version: "2.27-3ubuntu1", Ecosystem: "deb"},
+		{Name: "openssl", Version: "1.1.0g-2ubuntu4.1", Ecosystem: "deb"},
+	}
+	if pkgs := parseDpkgStatus([]byte(testDpkgStatus)); !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("unexpected dpkg packages %v, expected %v", pkgs, expected)
+	}
+
+	expected = []types.Package{
+		{Name: "musl", Version: "1.1.19-r10", Ecosystem: "apk"},
+		{Name: "busybox", Version: "1.28.4-r1", Ecosystem: "apk"},
+	}
+	if pkgs := parseApkInstalled([]byte(testApkInstalled)); !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("unexpected apk packages %v, expected %v", pkgs, expected)
+	}
+
+	expected = []types.Package{
+		{Name: "bash", Version: "4.2.46-30.el7", Ecosystem: "rpm"},
+	}
+	if pkgs := parseRpmList([]byte("bash 4.2.46-30.el7\n")); !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("unexpected rpm packages %v, expected %v", pkgs, expected)
+	}
+}
+
+func TestCVEScanner(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "scan-rootfs-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	status := filepath.Join(rootfs, dpkgStatus)
+	if err := os.MkdirAll(filepath.Dir(status), 0755); err != nil {
+		t.Fatalf("failed to create dpkg directory: %v", err)
+	}
+	if err := ioutil.WriteFile(status, []byte(testDpkgStatus), 0644); err != nil {
+		t.Fatalf("failed to write dpkg status: %v", err)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/scan" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req cveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		var res cveResponse
+		for _, p := range req.Packages {
+			if p.Name == "openssl" {
+				res.Vulnerabilities = append(res.Vulnerabilities, types.Vulnerability{
+					ID:       "CVE-2018-0732",
+					Package:  p.Name,
+					Version:  p.Version,
+					Severity: "HIGH",
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer s.Close()
+
+	scanner, err := New("cve", Config{URL: s.URL})
+	if err != nil {
+		t.Fatalf("failed to get scanner: %v", err)
+	}
+	report, err := scanner.Scan(rootfs)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	if report.Packages != 2 {
+		t.Errorf("unexpected number of scanned packages %d", report.Packages)
+	}
+	expected := []types.Vulnerability{
+		{ID: "CVE-2018-0732", Package: "openssl", Version: "1.1.0g-2ubuntu4.1", Severity: types.SeverityHigh},
+	}
+	if !reflect.DeepEqual(report.Vulnerabilities, expected) {
+		t.Errorf("unexpected vulnerabilities %v, expected %v", report.Vulnerabilities, expected)
+	}
+
+	scanner, _ = New("cve", Config{})
+	if _, err := scanner.Scan(rootfs); err == nil {
+		t.Errorf("unexpected success scanning without database URL")
+	}
+}
+
+func TestSeverityLevel(t *testing.T) {
+	if SeverityLevel(types.SeverityCritical) <= SeverityLevel(types.SeverityHigh) {
+		t.Errorf("critical severity is not higher than high severity")
+	}
+	if SeverityLevel("unknown") != -1 {
+		t.Errorf("unexpected level of unknown severity")
+	}
+}
@@ -9,8 +9,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
+var buildLog = sylog.Subsystem("build")
+
 const (
 	// Contents of /.singularity.d/actions/exec
 	execFileContent = `#!/bin/sh
@@ -0,0 +1,29 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"fmt"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+)
+
+// gpgRequired returns whether GPG verification of bootstrapped packages is
+// required, either by the build options or by singularity.conf
+func gpgRequired(b *types.Bundle) (bool, error) {
+	if b.Opts.RequireGPG {
+		return true, nil
+	}
+
+	c := &singularity.FileConfig{}
+	if err := config.Parser(buildcfg.SYSCONFDIR+"/singularity/singularity.conf", c); err != nil {
+		return false, fmt.Errorf("Unable to parse singularity.conf file: %s", err)
+	}
+	return c.RequireBootstrapGPG, nil
+}
@@ -8,17 +8,17 @@ package sources
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 const (
@@ -55,13 +55,28 @@ var baseToSkip = map[string]bool{
 	"xfsprogs":           true,
 }
 
+// sigLevels are the pacman signature verification levels, they may be
+// prefixed by Package or Database
+var sigLevels = map[string]bool{
+	"Never":       true,
+	"Optional":    true,
+	"Required":    true,
+	"TrustedOnly": true,
+	"TrustAll":    true,
+}
+
 // ArchConveyorPacker only needs to hold the conveyor to have the needed data to pack
 type ArchConveyorPacker struct {
-	b *types.Bundle
+	b          *types.Bundle
+	mirrors    []string
+	sigLevel   string
+	packages   []string
+	include    []string
+	pacmanConf string
 }
 
 // Get just stores the source
-func (cp *ArchConveyorPacker) Get(b *types.Bundle) (err error) {
+func (cp *ArchConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
 	cp.b = b
 
 	//check for pacstrap on system
@@ -75,12 +90,20 @@ func (cp *ArchConveyorPacker) Get(b *types.Bundle) (err error) {
 		return fmt.Errorf("%v architecture is not supported", arch)
 	}
 
-	instList, err := getPacmanBaseList()
-	if err != nil {
-		return fmt.Errorf("While generating the installation list: %v", err)
+	if err = cp.getBootstrapOptions(); err != nil {
+		return err
 	}
 
-	pacConf, err := cp.getPacConf(pacmanConfURL)
+	instList := cp.packages
+	if len(instList) == 0 {
+		instList, err = getPacmanBaseList()
+		if err != nil {
+			return fmt.Errorf("While generating the installation list: %v", err)
+		}
+	}
+	instList = append(instList, cp.include...)
+
+	pacConf, err := cp.getPacConf(cp.pacmanConf)
 	if err != nil {
 		return fmt.Errorf("While getting pacman config: %v", err)
 	}
@@ -88,25 +111,38 @@ func (cp *ArchConveyorPacker) Get(b *types.Bundle) (err error) {
 	args := []string{"-C", pacConf, "-c", "-d", "-G", "-M", cp.b.Rootfs(), "haveged"}
 	args = append(args, instList...)
 
-	pacCmd := exec.Command(pacstrapPath, args...)
+	pacCmd := exec.CommandContext(ctx, pacstrapPath, args...)
 	pacCmd.Stdout = os.Stdout
 	pacCmd.Stderr = os.Stderr
-	sylog.Debugf("\n\tPacstrap Path: %s\n\tPac Conf: %s\n\tRootfs: %s\n\tInstall List: %s\n", pacstrapPath, pacConf, cp.b.Rootfs(), instList)
+	buildLog.Debugf("\n\tPacstrap Path: %s\n\tPac Conf: %s\n\tRootfs: %s\n\tInstall List: %s\n", pacstrapPath, pacConf, cp.b.Rootfs(), instList)
 
 	if err = pacCmd.Run(); err != nil {
 		return fmt.Errorf("While pacstrapping: %v", err)
 	}
 
+	// the container uses the same mirrors for later package installs
+	if len(cp.mirrors) > 0 {
+		mirrorList := filepath.Join(cp.b.Rootfs(), "/etc/pacman.d/mirrorlist")
+		if err = ioutil.WriteFile(mirrorList, makeMirrorList(cp.mirrors), 0644); err != nil {
+			return fmt.Errorf("While writing mirror list: %v", err)
+		}
+	}
+
 	//Pacman package signing setup
-	cmd := exec.Command("arch-chroot", cp.b.Rootfs(), "/bin/sh", "-c", "haveged -w 1024; pacman-key --init; pacman-key --populate archlinux")
+	cmd := exec.CommandContext(ctx, "arch-chroot", cp.b.Rootfs(), "/bin/sh", "-c", "haveged -w 1024; pacman-key --init; pacman-key --populate archlinux")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("While setting up package signing: %v", err)
 	}
 
-	//Clean up haveged
-	cmd = exec.Command("arch-chroot", cp.b.Rootfs(), "pacman", "-Rs", "--noconfirm", "haveged")
+	//Clean up haveged unless it was requested
+	for _, pkg := range instList {
+		if pkg == "haveged" {
+			return nil
+		}
+	}
+	cmd = exec.CommandContext(ctx, "arch-chroot", cp.b.Rootfs(), "pacman", "-Rs", "--noconfirm", "haveged")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err = cmd.Run(); err != nil {
@@ -131,6 +167,91 @@ func (cp *ArchConveyorPacker) Pack() (b *types.Bundle, err error) {
 	return cp.b, nil
 }
 
+func (cp *ArchConveyorPacker) getBootstrapOptions() error {
+	h := cp.b.Recipe.Header
+
+	// MirrorURL may list several mirrors, tried in order by pacman
+	cp.mirrors = strings.Fields(h["mirrorurl"])
+
+	cp.sigLevel = strings.TrimSpace(h["siglevel"])
+	if err := checkSigLevel(cp.sigLevel); err != nil {
+		return fmt.Errorf("Invalid arch header: %v", err)
+	}
+
+	// Packages replaces the default package set, Include extends it
+	cp.packages = strings.Fields(h["packages"])
+	cp.include = strings.Fields(h["include"] + ` ` + os.Getenv("INCLUDE"))
+
+	cp.pacmanConf = h["pacmanconf"]
+	if cp.pacmanConf == "" {
+		cp.pacmanConf = pacmanConfURL
+	}
+
+	return nil
+}
+
+// checkSigLevel returns an error if level is not a valid pacman SigLevel
+// value, an empty level keeps the pacman.conf default
+func checkSigLevel(level string) error {
+	for _, field := range strings.Fields(level) {
+		l := strings.TrimPrefix(strings.TrimPrefix(field, "Package"), "Database")
+		if !sigLevels[l] {
+			return fmt.Errorf("unknown SigLevel %s", field)
+		}
+	}
+	return nil
+}
+
+// makeMirrorList returns a pacman mirror list using mirrors
+func makeMirrorList(mirrors []string) []byte {
+	var b bytes.Buffer
+	for _, m := range mirrors {
+		fmt.Fprintf(&b, "Server = %s\n", m)
+	}
+	return b.Bytes()
+}
+
+// customizePacConf returns conf with repositories using the mirror list
+// at mirrorList and the global SigLevel set to sigLevel, empty values keep
+// the original configuration
+func customizePacConf(conf []byte, mirrorList, sigLevel string) []byte {
+	var b bytes.Buffer
+	section := ""
+	sigLevelSet := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(conf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if section == "options" && sigLevel != "" && !sigLevelSet {
+				fmt.Fprintf(&b, "SigLevel = %s\n", sigLevel)
+				sigLevelSet = true
+			}
+			section = strings.Trim(trimmed, "[]")
+		} else if kv := strings.SplitN(trimmed, "=", 2); len(kv) == 2 {
+			key := strings.TrimSpace(kv[0])
+			switch {
+			case key == "SigLevel" && section == "options" && sigLevel != "":
+				if !sigLevelSet {
+					fmt.Fprintf(&b, "SigLevel = %s\n", sigLevel)
+					sigLevelSet = true
+				}
+				continue
+			case key == "Include" && section != "options" && mirrorList != "":
+				fmt.Fprintf(&b, "Include = %s\n", mirrorList)
+				continue
+			}
+		}
+		fmt.Fprintln(&b, line)
+	}
+	if section == "options" && sigLevel != "" && !sigLevelSet {
+		fmt.Fprintf(&b, "SigLevel = %s\n", sigLevel)
+	}
+	return b.Bytes()
+}
+
 func getPacmanBaseList() (instList []string, err error) {
 
 	output := &bytes.Buffer{}
@@ -154,25 +275,54 @@ func getPacmanBaseList() (instList []string, err error) {
 }
 
 func (cp *ArchConveyorPacker) getPacConf(pacmanConfURL string) (pacConf string, err error) {
-	pacConfFile, err := ioutil.TempFile(cp.b.Rootfs(), "pac-conf-")
-	if err != nil {
-		return
+	var conf []byte
+
+	if strings.HasPrefix(pacmanConfURL, "http://") || strings.HasPrefix(pacmanConfURL, "https://") {
+		resp, err := http.Get(pacmanConfURL)
+		if err != nil {
+			return "", fmt.Errorf("While performing http request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		conf, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+
+		//Simple check to make sure file received is the correct size
+		if resp.ContentLength >= 0 && int64(len(conf)) != resp.ContentLength {
+			return "", fmt.Errorf("File received is not the right size. Supposed to be: %v  Actually: %v", resp.ContentLength, len(conf))
+		}
+	} else {
+		conf, err = ioutil.ReadFile(pacmanConfURL)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	resp, err := http.Get(pacmanConfURL)
-	if err != nil {
-		return "", fmt.Errorf("While performing http request: %v", err)
+	// configuration files are kept out of the rootfs
+	mirrorList := ""
+	if len(cp.mirrors) > 0 {
+		f, err := ioutil.TempFile(cp.b.Path, "pac-mirrorlist-")
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		if _, err := f.Write(makeMirrorList(cp.mirrors)); err != nil {
+			return "", err
+		}
+		mirrorList = f.Name()
 	}
-	defer resp.Body.Close()
 
-	bytesWritten, err := io.Copy(pacConfFile, resp.Body)
+	pacConfFile, err := ioutil.TempFile(cp.b.Path, "pac-conf-")
 	if err != nil {
 		return
 	}
+	defer pacConfFile.Close()
 
-	//Simple check to make sure file received is the correct size
-	if bytesWritten != resp.ContentLength {
-		return "", fmt.Errorf("File received is not the right size. Supposed to be: %v  Actually: %v", resp.ContentLength, bytesWritten)
+	if _, err = pacConfFile.Write(customizePacConf(conf, mirrorList, cp.sigLevel)); err != nil {
+		return
 	}
 
 	return pacConfFile.Name(), nil
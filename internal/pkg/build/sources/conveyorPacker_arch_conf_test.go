@@ -0,0 +1,95 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"testing"
+)
+
+const testPacConf = `[options]
+HoldPkg     = pacman glibc
+Architecture = auto
+SigLevel    = Required DatabaseOptional
+LocalFileSigLevel = Optional
+
+#[testing]
+#Include = /etc/pacman.d/mirrorlist
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+
+[extra]
+SigLevel = Optional
+Include = /etc/pacman.d/mirrorlist
+`
+
+func TestCheckSigLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		shouldErr bool
+	}{
+		{"empty", "", false},
+		{"required", "Required", false},
+		{"default", "Required DatabaseOptional", false},
+		{"package trust", "PackageRequired PackageTrustedOnly", false},
+		{"unknown", "Always", true},
+		{"unknown prefix", "RepoRequired", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSigLevel(tt.level)
+			if tt.shouldErr && err == nil {
+				t.Errorf("unexpected success for SigLevel %q", tt.level)
+			} else if !tt.shouldErr && err != nil {
+				t.Errorf("unexpected error for SigLevel %q: %v", tt.level, err)
+			}
+		})
+	}
+}
+
+func TestCustomizePacConf(t *testing.T) {
+	tests := []struct {
+		name       string
+		mirrorList string
+		sigLevel   string
+		expected   string
+	}{
+		{"unchanged", "", "", testPacConf},
+		{"mirrors and siglevel", "/tmp/mirrorlist", "Never", `[options]
+HoldPkg     = pacman glibc
+Architecture = auto
+SigLevel = Never
+LocalFileSigLevel = Optional
+
+#[testing]
+#Include = /etc/pacman.d/mirrorlist
+
+[core]
+Include = /tmp/mirrorlist
+
+[extra]
+SigLevel = Optional
+Include = /tmp/mirrorlist
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := string(customizePacConf([]byte(testPacConf), tt.mirrorList, tt.sigLevel))
+			if conf != tt.expected {
+				t.Errorf("unexpected pacman.conf:\n%s\nexpected:\n%s", conf, tt.expected)
+			}
+		})
+	}
+
+	conf := string(customizePacConf([]byte("[options]\nArchitecture = auto\n[core]\n"), "", "Required"))
+	expected := "[options]\nArchitecture = auto\nSigLevel = Required\n[core]\n"
+	if conf != expected {
+		t.Errorf("SigLevel not added to options: got\n%s\nexpected:\n%s", conf, expected)
+	}
+}
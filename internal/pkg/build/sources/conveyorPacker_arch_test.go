@@ -6,6 +6,7 @@
 package sources_test
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"testing"
@@ -49,7 +50,7 @@ func TestArchConveyor(t *testing.T) {
 
 	cp := &sources.ArchConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -83,7 +84,7 @@ func TestArchPacker(t *testing.T) {
 
 	cp := &sources.ArchConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
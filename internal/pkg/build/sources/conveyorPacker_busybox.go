@@ -6,6 +6,7 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,7 +16,6 @@ import (
 	"path/filepath"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 // BusyBoxConveyor only needs to hold the conveyor to have the needed data to pack
@@ -29,7 +29,7 @@ type BusyBoxConveyorPacker struct {
 }
 
 // Get just stores the source
-func (c *BusyBoxConveyor) Get(b *types.Bundle) (err error) {
+func (c *BusyBoxConveyor) Get(ctx context.Context, b *types.Bundle) (err error) {
 	c.b = b
 
 	// get mirrorURL, OSVerison, and Includes components to definition
@@ -48,14 +48,14 @@ func (c *BusyBoxConveyor) Get(b *types.Bundle) (err error) {
 		return fmt.Errorf("While inserting files: %v", err)
 	}
 
-	busyBoxPath, err := c.insertBusyBox(mirrorurl)
+	busyBoxPath, err := c.insertBusyBox(ctx, mirrorurl)
 	if err != nil {
 		return fmt.Errorf("While inserting busybox: %v", err)
 	}
 
-	cmd := exec.Command(busyBoxPath, `--install`, filepath.Join(c.b.Rootfs(), "/bin"))
+	cmd := exec.CommandContext(ctx, busyBoxPath, `--install`, filepath.Join(c.b.Rootfs(), "/bin"))
 
-	sylog.Debugf("\n\tBusyBox Path: %s\n\tMirrorURL: %s\n", busyBoxPath, mirrorurl)
+	buildLog.Debugf("\n\tBusyBox Path: %s\n\tMirrorURL: %s\n", busyBoxPath, mirrorurl)
 
 	err = cmd.Run()
 	if err != nil {
@@ -94,10 +94,14 @@ func (c *BusyBoxConveyor) insertBaseFiles() (err error) {
 	return
 }
 
-func (c *BusyBoxConveyor) insertBusyBox(mirrorurl string) (busyBoxPath string, err error) {
+func (c *BusyBoxConveyor) insertBusyBox(ctx context.Context, mirrorurl string) (busyBoxPath string, err error) {
 	os.Mkdir(filepath.Join(c.b.Rootfs(), "/bin"), 0755)
 
-	resp, err := http.Get(mirrorurl)
+	req, err := http.NewRequest(http.MethodGet, mirrorurl, nil)
+	if err != nil {
+		return "", fmt.Errorf("While creating http request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", fmt.Errorf("While performing http request: %v", err)
 	}
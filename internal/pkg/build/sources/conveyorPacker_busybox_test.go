@@ -6,6 +6,7 @@
 package sources_test
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -44,7 +45,7 @@ func TestBusyBoxConveyor(t *testing.T) {
 
 	c := &sources.BusyBoxConveyor{}
 
-	err = c.Get(b)
+	err = c.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer c.CleanUp()
 	if err != nil {
@@ -74,7 +75,7 @@ func TestBusyBoxPacker(t *testing.T) {
 
 	cp := &sources.BusyBoxConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -6,6 +6,7 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,7 +14,6 @@ import (
 	"strings"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 // DebootstrapConveyorPacker holds stuff that needs to be packed into the bundle
@@ -22,10 +22,12 @@ type DebootstrapConveyorPacker struct {
 	mirrorurl string
 	osversion string
 	include   string
+	keyring   string
+	gpgReq    bool
 }
 
 // Get downloads container information from the specified source
-func (cp *DebootstrapConveyorPacker) Get(b *types.Bundle) (err error) {
+func (cp *DebootstrapConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
 	cp.b = b
 
 	// check for debootstrap on system(script using "singularity_which" not sure about its importance)
@@ -42,15 +44,32 @@ func (cp *DebootstrapConveyorPacker) Get(b *types.Bundle) (err error) {
 		return fmt.Errorf("You must be root to build with debootstrap")
 	}
 
+	args := []string{`--variant=minbase`, `--exclude=openssl,udev,debconf-i18n,e2fsprogs`, `--include=apt,` + cp.include, `--arch=` + runtime.GOARCH}
+	if cp.keyring != "" {
+		args = append(args, `--keyring=`+cp.keyring)
+	}
+	if cp.gpgReq {
+		args = append(args, `--force-check-gpg`)
+	} else if cp.keyring == "" {
+		buildLog.Warningf("No GPGKey keyring specified, Release files are only verified if debootstrap finds a default keyring")
+	}
+	args = append(args, cp.osversion, cp.b.Rootfs(), cp.mirrorurl)
+
 	// run debootstrap command
-	cmd := exec.Command(debootstrapPath, `--variant=minbase`, `--exclude=openssl,udev,debconf-i18n,e2fsprogs`, `--include=apt,`+cp.include, `--arch=`+runtime.GOARCH, cp.osversion, cp.b.Rootfs(), cp.mirrorurl)
+	cmd := exec.CommandContext(ctx, debootstrapPath, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	sylog.Debugf("\n\tDebootstrap Path: %s\n\tIncludes: apt(default),%s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n", debootstrapPath, cp.include, runtime.GOARCH, cp.osversion, cp.mirrorurl)
+	buildLog.Debugf("\n\tDebootstrap Path: %s\n\tIncludes: apt(default),%s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tKeyring: %s\n", debootstrapPath, cp.include, runtime.GOARCH, cp.osversion, cp.mirrorurl, cp.keyring)
 
 	// run debootstrap
 	if err = cmd.Run(); err != nil {
+		if cp.gpgReq {
+			return fmt.Errorf("While debootstrapping with required GPG verification: %v\n"+
+				"Make sure the archive keyring of %s is installed, e.g. with\n"+
+				"'apt-get install debian-archive-keyring' or 'apt-get install ubuntu-keyring',\n"+
+				"or point to it with the GPGKey header of the definition file", err, cp.osversion)
+		}
 		return fmt.Errorf("While debootstrapping: %v", err)
 	}
 
@@ -92,6 +111,18 @@ func (cp *DebootstrapConveyorPacker) getRecipeHeaderInfo() (err error) {
 		return fmt.Errorf("Invalid debootstrap header, no OSVersion specified")
 	}
 
+	cp.keyring = cp.b.Recipe.Header["gpgkey"]
+	if cp.keyring != "" {
+		if _, err := os.Stat(cp.keyring); err != nil {
+			return fmt.Errorf("Invalid debootstrap header, GPGKey keyring %s: %v", cp.keyring, err)
+		}
+	}
+
+	cp.gpgReq, err = gpgRequired(cp.b)
+	if err != nil {
+		return err
+	}
+
 	include, _ := cp.b.Recipe.Header["include"]
 
 	//check for include environment variable and add it to requires string
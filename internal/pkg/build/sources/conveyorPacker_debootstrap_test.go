@@ -6,6 +6,7 @@
 package sources_test
 
 import (
+	"context"
 	"os/exec"
 	"testing"
 
@@ -40,7 +41,7 @@ func TestDebootstrapConveyor(t *testing.T) {
 
 	cp := sources.DebootstrapConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -70,7 +71,7 @@ func TestDebootstrapPacker(t *testing.T) {
 
 	cp := sources.DebootstrapConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
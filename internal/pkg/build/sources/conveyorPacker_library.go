@@ -6,11 +6,12 @@
 package sources
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 
 	sytypes "github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/client/library"
 )
 
@@ -24,15 +25,15 @@ type LibraryConveyorPacker struct {
 }
 
 // Get downloads container from Singularityhub
-func (cp *LibraryConveyorPacker) Get(b *sytypes.Bundle) (err error) {
-	sylog.Debugf("Getting container from Library")
+func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *sytypes.Bundle) (err error) {
+	buildLog.Debugf("Getting container from Library")
 
 	cp.b = b
 
 	// check for custom library from definition
 	customLib, ok := b.Recipe.Header["library"]
 	if ok {
-		sylog.Debugf("Using custom library: %v", customLib)
+		buildLog.Debugf("Using custom library: %v", customLib)
 		cp.LibraryURL = customLib
 	}
 
@@ -45,13 +46,13 @@ func (cp *LibraryConveyorPacker) Get(b *sytypes.Bundle) (err error) {
 
 	cp.b.FSObjects["libraryImg"] = f.Name()
 
-	sylog.Debugf("Download file: %v", cp.b.FSObjects["libraryImg"])
-	sylog.Debugf("LibraryURL: %v", cp.LibraryURL)
-	sylog.Debugf("LibraryRef: %v", b.Recipe.Header["from"])
+	buildLog.Debugf("Download file: %v", cp.b.FSObjects["libraryImg"])
+	buildLog.Debugf("LibraryURL: %v", cp.LibraryURL)
+	buildLog.Debugf("LibraryRef: %v", b.Recipe.Header["from"])
 
 	// get image from library
-	if err = client.DownloadImage(cp.b.FSObjects["libraryImg"], b.Recipe.Header["from"], cp.LibraryURL, true, cp.AuthToken); err != nil {
-		sylog.Fatalf("failed to Get from %s://%s: %v\n", cp.LibraryURL, cp.b.Recipe.Header["from"], err)
+	if err = client.DownloadImageContext(ctx, cp.b.FSObjects["libraryImg"], b.Recipe.Header["from"], cp.LibraryURL, true, cp.AuthToken); err != nil {
+		return fmt.Errorf("failed to Get from %s://%s: %v", cp.LibraryURL, cp.b.Recipe.Header["from"], err)
 	}
 
 	cp.LocalPacker, err = GetLocalPacker(cp.b.FSObjects["libraryImg"], cp.b)
@@ -6,6 +6,7 @@
 package sources_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/build/sources"
@@ -40,7 +41,7 @@ func TestLibraryConveyor(t *testing.T) {
 		LibraryURL: libraryURL,
 	}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -66,7 +67,7 @@ func TestLibraryPacker(t *testing.T) {
 		LibraryURL: libraryURL,
 	}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
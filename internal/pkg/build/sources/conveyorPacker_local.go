@@ -6,12 +6,12 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/image"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
@@ -44,36 +44,36 @@ func GetLocalPacker(src string, b *types.Bundle) (LocalPacker, error) {
 
 	switch imageObject.Type {
 	case image.SIF:
-		sylog.Debugf("Packing from SIF")
+		buildLog.Debugf("Packing from SIF")
 
 		return &SIFPacker{
 			srcfile: src,
 			b:       b,
 		}, nil
 	case image.SQUASHFS:
-		sylog.Debugf("Packing from Squashfs")
+		buildLog.Debugf("Packing from Squashfs")
 
 		info.Offset = imageObject.Offset
 		info.SizeLimit = imageObject.Size
 
 		return &SquashfsPacker{
-			srcfile: src,
+			srcfile: imageObject.DataPath(),
 			b:       b,
 			info:    info,
 		}, nil
 	case image.EXT3:
-		sylog.Debugf("Packing from Ext3")
+		buildLog.Debugf("Packing from Ext3")
 
 		info.Offset = imageObject.Offset
 		info.SizeLimit = imageObject.Size
 
 		return &Ext3Packer{
-			srcfile: src,
+			srcfile: imageObject.DataPath(),
 			b:       b,
 			info:    info,
 		}, nil
 	case image.SANDBOX:
-		sylog.Debugf("Packing from Sandbox")
+		buildLog.Debugf("Packing from Sandbox")
 
 		return &SandboxPacker{
 			srcdir: src,
@@ -85,7 +85,7 @@ func GetLocalPacker(src string, b *types.Bundle) (LocalPacker, error) {
 }
 
 // Get just stores the source
-func (cp *LocalConveyorPacker) Get(b *types.Bundle) (err error) {
+func (cp *LocalConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
 
 	cp.src = filepath.Clean(b.Recipe.Header["from"])
 
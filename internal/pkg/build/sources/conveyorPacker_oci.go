@@ -27,10 +27,8 @@ import (
 	"github.com/containers/image/signature"
 	"github.com/containers/image/types"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	imagetools "github.com/opencontainers/image-tools/image"
 	sytypes "github.com/sylabs/singularity/internal/pkg/build/types"
 	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/shell"
 )
 
@@ -45,10 +43,16 @@ type OCIConveyorPacker struct {
 }
 
 // Get downloads container information from the specified source
-func (cp *OCIConveyorPacker) Get(b *sytypes.Bundle) (err error) {
+func (cp *OCIConveyorPacker) Get(ctx context.Context, b *sytypes.Bundle) (err error) {
 
 	cp.b = b
 
+	if b.Opts.Whiteout != "" {
+		if err := checkWhiteoutMode(b.Opts.Whiteout); err != nil {
+			return err
+		}
+	}
+
 	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
 	cp.policyCtx, err = signature.NewPolicyContext(policy)
 	if err != nil {
@@ -70,7 +74,7 @@ func (cp *OCIConveyorPacker) Get(b *sytypes.Bundle) (err error) {
 	if b.Recipe.Header["registry"] != "" {
 		ref = b.Recipe.Header["registry"] + "/" + ref
 	}
-	sylog.Debugf("Reference: %v", ref)
+	buildLog.Debugf("Reference: %v", ref)
 
 	switch b.Recipe.Header["bootstrap"] {
 	case "docker":
@@ -115,8 +119,16 @@ func (cp *OCIConveyorPacker) Get(b *sytypes.Bundle) (err error) {
 		return fmt.Errorf("Invalid image source: %v", err)
 	}
 
+	platform := ociclient.DefaultPlatform()
+	if b.Opts.Platform != "" {
+		platform, err = ociclient.ParsePlatform(b.Opts.Platform)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Grab the modified source ref from the cache
-	cp.srcRef, err = ociclient.ConvertReference(cp.srcRef, cp.sysCtx)
+	cp.srcRef, err = ociclient.ConvertReference(cp.srcRef, cp.sysCtx, platform)
 	if err != nil {
 		return err
 	}
@@ -125,12 +137,12 @@ func (cp *OCIConveyorPacker) Get(b *sytypes.Bundle) (err error) {
 	// contains *only* this image
 	cp.tmpfsRef, err = oci.ParseReference(cp.b.Path + ":" + "tmp")
 
-	err = cp.fetch()
+	err = cp.fetch(ctx)
 	if err != nil {
 		return err
 	}
 
-	cp.imgConfig, err = cp.getConfig()
+	cp.imgConfig, err = cp.getConfig(ctx)
 	if err != nil {
 		return err
 	}
@@ -163,9 +175,9 @@ func (cp *OCIConveyorPacker) Pack() (*sytypes.Bundle, error) {
 	return cp.b, nil
 }
 
-func (cp *OCIConveyorPacker) fetch() (err error) {
+func (cp *OCIConveyorPacker) fetch(ctx context.Context) (err error) {
 	// cp.srcRef contains the cache source reference
-	err = copy.Image(context.Background(), cp.policyCtx, cp.tmpfsRef, cp.srcRef, &copy.Options{
+	err = copy.Image(ctx, cp.policyCtx, cp.tmpfsRef, cp.srcRef, &copy.Options{
 		ReportWriter: ioutil.Discard,
 		SourceCtx:    cp.sysCtx,
 	})
@@ -176,14 +188,14 @@ func (cp *OCIConveyorPacker) fetch() (err error) {
 	return nil
 }
 
-func (cp *OCIConveyorPacker) getConfig() (imgspecv1.ImageConfig, error) {
-	img, err := cp.srcRef.NewImage(context.Background(), cp.sysCtx)
+func (cp *OCIConveyorPacker) getConfig(ctx context.Context) (imgspecv1.ImageConfig, error) {
+	img, err := cp.srcRef.NewImage(ctx, cp.sysCtx)
 	if err != nil {
 		return imgspecv1.ImageConfig{}, err
 	}
 	defer img.Close()
 
-	imgSpec, err := img.OCIConfig(context.Background())
+	imgSpec, err := img.OCIConfig(ctx)
 	if err != nil {
 		return imgspecv1.ImageConfig{}, err
 	}
@@ -269,14 +281,16 @@ func (cp *OCIConveyorPacker) extractArchive(src string, dst string) error {
 }
 
 func (cp *OCIConveyorPacker) unpackTmpfs() (err error) {
-	refs := []string{"name=tmp"}
-	err = imagetools.UnpackLayout(cp.b.Path, cp.b.Rootfs(), "amd64", refs)
-	return err
+	mode := cp.b.Opts.Whiteout
+	if mode == "" {
+		mode = sytypes.WhiteoutRemove
+	}
+	return UnpackImage(context.Background(), cp.tmpfsRef, cp.sysCtx, cp.b.Rootfs(), mode)
 }
 
 func (cp *OCIConveyorPacker) insertBaseEnv() (err error) {
 	if err = makeBaseEnv(cp.b.Rootfs()); err != nil {
-		sylog.Errorf("%v", err)
+		buildLog.Errorf("%v", err)
 	}
 	return
 }
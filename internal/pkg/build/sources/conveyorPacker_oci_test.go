@@ -6,6 +6,7 @@
 package sources_test
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"log"
@@ -50,7 +51,7 @@ func TestOCIConveyorDocker(t *testing.T) {
 
 	cp := &sources.OCIConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -83,7 +84,7 @@ func TestOCIConveyorDockerArchive(t *testing.T) {
 
 	cp := &sources.OCIConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -124,7 +125,7 @@ func TestOCIConveyorDockerDaemon(t *testing.T) {
 
 	cp := &sources.OCIConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -157,7 +158,7 @@ func TestOCIConveyorOCIArchive(t *testing.T) {
 
 	cp := &sources.OCIConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -203,7 +204,7 @@ func TestOCIConveyorOCILayout(t *testing.T) {
 
 	cp := &sources.OCIConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -228,7 +229,7 @@ func TestOCIPacker(t *testing.T) {
 
 	ocp := &sources.OCIConveyorPacker{}
 
-	err = ocp.Get(b)
+	err = ocp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer ocp.CleanUp()
 	if err != nil {
@@ -6,11 +6,12 @@
 package sources
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 
 	sytypes "github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/client/shub"
 )
 
@@ -22,8 +23,8 @@ type ShubConveyorPacker struct {
 }
 
 // Get downloads container from Singularityhub
-func (cp *ShubConveyorPacker) Get(b *sytypes.Bundle) (err error) {
-	sylog.Debugf("Getting container from Shub")
+func (cp *ShubConveyorPacker) Get(ctx context.Context, b *sytypes.Bundle) (err error) {
+	buildLog.Debugf("Getting container from Shub")
 
 	cp.b = b
 
@@ -39,8 +40,8 @@ func (cp *ShubConveyorPacker) Get(b *sytypes.Bundle) (err error) {
 	cp.b.FSObjects["shubImg"] = f.Name()
 
 	// get image from singularity hub
-	if err = client.DownloadImage(cp.b.FSObjects["shubImg"], src, true, cp.b.Opts.NoHTTPS); err != nil {
-		sylog.Fatalf("failed to Get from %s: %v\n", src, err)
+	if err = client.DownloadImageContext(ctx, cp.b.FSObjects["shubImg"], src, true, cp.b.Opts.NoHTTPS); err != nil {
+		return fmt.Errorf("failed to Get from %s: %v", src, err)
 	}
 
 	cp.LocalPacker, err = GetLocalPacker(cp.b.FSObjects["shubImg"], cp.b)
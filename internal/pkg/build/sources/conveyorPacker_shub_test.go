@@ -6,6 +6,7 @@
 package sources_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/build/sources"
@@ -39,7 +40,7 @@ func TestShubConveyor(t *testing.T) {
 
 	cp := &sources.ShubConveyorPacker{}
 
-	err = cp.Get(b)
+	err = cp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer cp.CleanUp()
 	if err != nil {
@@ -64,7 +65,7 @@ func TestShubPacker(t *testing.T) {
 
 	scp := &sources.ShubConveyorPacker{}
 
-	err = scp.Get(b)
+	err = scp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer scp.CleanUp()
 	if err != nil {
@@ -8,6 +8,7 @@ package sources
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -17,7 +18,6 @@ import (
 	"strings"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 const (
@@ -34,6 +34,7 @@ type YumConveyor struct {
 	include   string
 	gpg       string
 	httpProxy string
+	gpgReq    bool
 }
 
 // YumConveyorPacker only needs to hold the conveyor to have the needed data to pack
@@ -42,15 +43,15 @@ type YumConveyorPacker struct {
 }
 
 // Get downloads container information from the specified source
-func (c *YumConveyor) Get(b *types.Bundle) (err error) {
+func (c *YumConveyor) Get(ctx context.Context, b *types.Bundle) (err error) {
 	c.b = b
 
 	// check for dnf or yum on system
 	var installCommandPath string
 	if installCommandPath, err = exec.LookPath("dnf"); err == nil {
-		sylog.Debugf("Found dnf at: %v", installCommandPath)
+		buildLog.Debugf("Found dnf at: %v", installCommandPath)
 	} else if installCommandPath, err = exec.LookPath("yum"); err == nil {
-		sylog.Debugf("Found yum at: %v", installCommandPath)
+		buildLog.Debugf("Found yum at: %v", installCommandPath)
 	} else {
 		return fmt.Errorf("Neither yum nor dnf in PATH")
 	}
@@ -80,8 +81,8 @@ func (c *YumConveyor) Get(b *types.Bundle) (err error) {
 	args = append(args, strings.Fields(c.include)...)
 
 	// Do the install
-	sylog.Debugf("\n\tInstall Command Path: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tUpdateURL: %s\n\tIncludes: %s\n", installCommandPath, runtime.GOARCH, c.osversion, c.mirrorurl, c.updateurl, c.include)
-	cmd := exec.Command(installCommandPath, args...)
+	buildLog.Debugf("\n\tInstall Command Path: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tUpdateURL: %s\n\tIncludes: %s\n", installCommandPath, runtime.GOARCH, c.osversion, c.mirrorurl, c.updateurl, c.include)
+	cmd := exec.CommandContext(ctx, installCommandPath, args...)
 	// cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err = cmd.Run(); err != nil {
@@ -110,9 +111,16 @@ func (cp *YumConveyorPacker) Pack() (b *types.Bundle, err error) {
 }
 
 func (c *YumConveyor) getRPMPath() (err error) {
-	c.rpmPath, err = exec.LookPath("rpm")
+	c.rpmPath, err = findRPM()
+	return err
+}
+
+// findRPM returns the path of rpm and checks that its database is in the
+// expected location
+func findRPM() (rpmPath string, err error) {
+	rpmPath, err = exec.LookPath("rpm")
 	if err != nil {
-		return fmt.Errorf("RPM is not in PATH: %v", err)
+		return "", fmt.Errorf("RPM is not in PATH: %v", err)
 	}
 
 	output := &bytes.Buffer{}
@@ -120,7 +128,7 @@ func (c *YumConveyor) getRPMPath() (err error) {
 	cmd.Stdout = output
 
 	if err = cmd.Run(); err != nil {
-		return
+		return "", err
 	}
 
 	rpmDBPath := ""
@@ -136,9 +144,9 @@ func (c *YumConveyor) getRPMPath() (err error) {
 	}
 
 	if rpmDBPath == "" {
-		return fmt.Errorf("Could find dbpath")
+		return "", fmt.Errorf("Could find dbpath")
 	} else if rpmDBPath != `%{_var}/lib/rpm` {
-		return fmt.Errorf("RPM database is using a weird path: %s\n"+
+		return "", fmt.Errorf("RPM database is using a weird path: %s\n"+
 			"You are probably running this bootstrap on Debian or Ubuntu.\n"+
 			"There is a way to work around this problem:\n"+
 			"Create a file at path %s/.rpmmacros.\n"+
@@ -150,7 +158,7 @@ func (c *YumConveyor) getRPMPath() (err error) {
 			rpmDBPath, os.Getenv("HOME"), `%_var /var`, `%_dbpath %{_var}/lib/rpm`)
 	}
 
-	return nil
+	return rpmPath, nil
 }
 
 func (c *YumConveyor) getBootstrapOptions() (err error) {
@@ -160,6 +168,22 @@ func (c *YumConveyor) getBootstrapOptions() (err error) {
 	c.gpg = os.Getenv("GPG")
 	c.httpProxy = os.Getenv("http_proxy")
 
+	// a GPGKey header takes precedence over the environment
+	if key, ok := c.b.Recipe.Header["gpgkey"]; ok {
+		c.gpg = key
+	}
+
+	c.gpgReq, err = gpgRequired(c.b)
+	if err != nil {
+		return err
+	}
+	if c.gpgReq && c.gpg == "" {
+		return fmt.Errorf("GPG verification of packages is required but no GPG key is specified.\n" +
+			"Add the https URL of the distribution signing key to the definition header, e.g.:\n" +
+			"    GPGKey: https://www.centos.org/keys/RPM-GPG-KEY-CentOS-7\n" +
+			"or set it with the GPG environment variable")
+	}
+
 	// get mirrorURL, updateURL, OSVerison, and Includes components to definition
 	c.mirrorurl, ok = c.b.Recipe.Header["mirrorurl"]
 	if !ok {
@@ -264,14 +288,14 @@ func (c *YumConveyor) genYumConfig() (err error) {
 			return fmt.Errorf("While importing GPG key: %v", err)
 		}
 	} else {
-		sylog.Infof("Skipping GPG Key Import")
+		buildLog.Warningf("Skipping GPG Key Import, packages will not be verified")
 	}
 
 	return nil
 }
 
 func (c *YumConveyor) importGPGKey() (err error) {
-	sylog.Infof("We have a GPG key!  Preparing RPM database.")
+	buildLog.Infof("We have a GPG key!  Preparing RPM database.")
 
 	// make sure gpg is being imported over https
 	if strings.HasPrefix(c.gpg, "https://") == false {
@@ -283,41 +307,57 @@ func (c *YumConveyor) importGPGKey() (err error) {
 		return fmt.Errorf("Neither yum nor dnf in PATH")
 	}
 
-	cmd := exec.Command(c.rpmPath, "--root", c.b.Rootfs(), "--initdb")
+	if err = importRPMKey(c.rpmPath, c.b.Rootfs(), c.gpg); err != nil {
+		return err
+	}
+
+	buildLog.Infof("GPG key import complete!")
+
+	return nil
+}
+
+// importRPMKey initializes the rpm database of rootfs and imports the GPG
+// key at url in it
+func importRPMKey(rpmPath, rootfs, url string) (err error) {
+	cmd := exec.Command(rpmPath, "--root", rootfs, "--initdb")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("While initializing new rpm db: %v", err)
 	}
 
-	cmd = exec.Command(c.rpmPath, "--root", c.b.Rootfs(), "--import", c.gpg)
+	cmd = exec.Command(rpmPath, "--root", rootfs, "--import", url)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("While importing GPG key with rpm: %v", err)
 	}
 
-	sylog.Infof("GPG key import complete!")
-
 	return nil
 }
 
 func (c *YumConveyor) copyPseudoDevices() (err error) {
-	err = os.Mkdir(filepath.Join(c.b.Rootfs(), "/dev"), 0775)
+	return copyPseudoDevices(c.b.Rootfs())
+}
+
+// copyPseudoDevices copies the pseudo devices needed by package managers to
+// the /dev directory of rootfs
+func copyPseudoDevices(rootfs string) (err error) {
+	err = os.Mkdir(filepath.Join(rootfs, "/dev"), 0775)
 	if err != nil {
-		return fmt.Errorf("While creating %v: %v", filepath.Join(c.b.Rootfs(), "/dev"), err)
+		return fmt.Errorf("While creating %v: %v", filepath.Join(rootfs, "/dev"), err)
 	}
 
 	devs := []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
 
 	for _, dev := range devs {
-		cmd := exec.Command("cp", "-a", dev, filepath.Join(c.b.Rootfs(), "/dev"))
+		cmd := exec.Command("cp", "-a", dev, filepath.Join(rootfs, "/dev"))
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		if err = cmd.Run(); err != nil {
-			f, err := os.Create(c.b.Rootfs() + "/.singularity.d/runscript")
+			f, err := os.Create(rootfs + "/.singularity.d/runscript")
 			if err != nil {
-				return fmt.Errorf("While creating %v: %v", filepath.Join(c.b.Rootfs(), dev), err)
+				return fmt.Errorf("While creating %v: %v", filepath.Join(rootfs, dev), err)
 			}
 
 			defer f.Close()
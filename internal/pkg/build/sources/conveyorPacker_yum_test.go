@@ -6,6 +6,7 @@
 package sources
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"testing"
@@ -49,7 +50,7 @@ func TestYumConveyor(t *testing.T) {
 
 	yc := &YumConveyor{}
 
-	err = yc.Get(b)
+	err = yc.Get(context.Background(), b)
 	// clean up bundle since assembler isnt called
 	defer os.RemoveAll(yc.b.Path)
 	if err != nil {
@@ -85,7 +86,7 @@ func TestYumPacker(t *testing.T) {
 
 	ycp := &YumConveyorPacker{}
 
-	err = ycp.Get(b)
+	err = ycp.Get(context.Background(), b)
 	// clean up tmpfs since assembler isnt called
 	defer os.RemoveAll(ycp.b.Path)
 	if err != nil {
@@ -97,3 +98,33 @@ func TestYumPacker(t *testing.T) {
 		t.Fatalf("failed to Pack from %s: %v\n", yumDef, err)
 	}
 }
+
+func TestYumRequireGPG(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	os.Unsetenv("GPG")
+
+	b := &types.Bundle{
+		Opts: types.Options{RequireGPG: true},
+		Recipe: types.Definition{
+			Header: map[string]string{
+				"bootstrap": "yum",
+				"mirrorurl": "http://mirror.centos.org/centos-7/7/os/x86_64/",
+			},
+		},
+	}
+
+	yc := &YumConveyor{b: b}
+	if err := yc.getBootstrapOptions(); err == nil {
+		t.Fatalf("unexpected success without GPG key while GPG verification is required")
+	}
+
+	b.Recipe.Header["gpgkey"] = "https://www.centos.org/keys/RPM-GPG-KEY-CentOS-7"
+	if err := yc.getBootstrapOptions(); err != nil {
+		t.Fatalf("unexpected failure with GPG key: %v", err)
+	}
+	if yc.gpg != b.Recipe.Header["gpgkey"] || !yc.gpgReq {
+		t.Errorf("GPG key %q not used or verification not required", b.Recipe.Header["gpgkey"])
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// ZypperConveyor holds stuff that needs to be packed into the bundle
+type ZypperConveyor struct {
+	b          *types.Bundle
+	zypperPath string
+	rpmPath    string
+	mirrorurl  string
+	updateurl  string
+	osversion  string
+	include    string
+	gpg        string
+	gpgReq     bool
+}
+
+// ZypperConveyorPacker only needs to hold the conveyor to have the needed data to pack
+type ZypperConveyorPacker struct {
+	ZypperConveyor
+}
+
+// Get downloads container information from the specified source
+func (c *ZypperConveyor) Get(ctx context.Context, b *types.Bundle) (err error) {
+	c.b = b
+
+	// check for zypper on system
+	c.zypperPath, err = exec.LookPath("zypper")
+	if err != nil {
+		return fmt.Errorf("zypper is not in PATH: %v", err)
+	}
+	buildLog.Debugf("Found zypper at: %v", c.zypperPath)
+
+	// check for rpm on system
+	c.rpmPath, err = findRPM()
+	if err != nil {
+		return fmt.Errorf("While checking rpm path: %v", err)
+	}
+
+	err = c.getBootstrapOptions()
+	if err != nil {
+		return fmt.Errorf("While getting bootstrap options: %v", err)
+	}
+
+	err = copyPseudoDevices(c.b.Rootfs())
+	if err != nil {
+		return fmt.Errorf("While copying pseudo devices: %v", err)
+	}
+
+	// if gpg key is specified, import it
+	if c.gpg != "" {
+		buildLog.Infof("We have a GPG key!  Preparing RPM database.")
+		if !strings.HasPrefix(c.gpg, "https://") {
+			return fmt.Errorf("GPG key must be fetched with https")
+		}
+		if err = importRPMKey(c.rpmPath, c.b.Rootfs(), c.gpg); err != nil {
+			return fmt.Errorf("While importing GPG key: %v", err)
+		}
+		buildLog.Infof("GPG key import complete!")
+	} else {
+		buildLog.Warningf("Skipping GPG Key Import, packages will not be verified")
+	}
+
+	// add the repositories of the mirror and update URLs
+	if err = c.addRepo(ctx, c.mirrorurl, "repo-oss"); err != nil {
+		return err
+	}
+	if c.updateurl != "" {
+		if err = c.addRepo(ctx, c.updateurl, "repo-update"); err != nil {
+			return err
+		}
+	}
+
+	args := []string{`install`, `--auto-agree-with-licenses`, `--download-in-advance`}
+	args = append(args, strings.Fields(c.include)...)
+
+	// Do the install
+	buildLog.Debugf("\n\tInstall Command Path: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tUpdateURL: %s\n\tIncludes: %s\n", c.zypperPath, c.osversion, c.mirrorurl, c.updateurl, c.include)
+	if err = c.zypper(ctx, args...); err != nil {
+		return fmt.Errorf("While bootstrapping: %v", err)
+	}
+
+	// clean up bootstrap packages
+	if err = c.zypper(ctx, `clean`, `--all`); err != nil {
+		return fmt.Errorf("While cleaning zypper cache: %v", err)
+	}
+
+	return nil
+}
+
+// Pack puts relevant objects in a Bundle!
+func (cp *ZypperConveyorPacker) Pack() (b *types.Bundle, err error) {
+	err = makeBaseEnv(cp.b.Rootfs())
+	if err != nil {
+		return nil, fmt.Errorf("While inserting base environment: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(cp.b.Rootfs(), "/.singularity.d/runscript"), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("While inserting runscript: %v", err)
+	}
+
+	return cp.b, nil
+}
+
+// zypper runs zypper non interactively on the container root filesystem
+func (c *ZypperConveyor) zypper(ctx context.Context, args ...string) error {
+	global := []string{`--non-interactive`, `--root`, c.b.Rootfs()}
+	if c.osversion != "" {
+		global = append(global, `--releasever`, c.osversion)
+	}
+
+	cmd := exec.CommandContext(ctx, c.zypperPath, append(global, args...)...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// addRepo adds the repository at url to the container, GPG checks of its
+// packages are disabled if no GPG key is specified
+func (c *ZypperConveyor) addRepo(ctx context.Context, url, alias string) error {
+	args := []string{`addrepo`}
+	if c.gpg == "" {
+		args = append(args, `--no-gpgcheck`)
+	}
+	args = append(args, url, alias)
+
+	if err := c.zypper(ctx, args...); err != nil {
+		return fmt.Errorf("While adding repository %s: %v", url, err)
+	}
+	return nil
+}
+
+func (c *ZypperConveyor) getBootstrapOptions() (err error) {
+	var ok bool
+
+	// look for gpg environment var
+	c.gpg = os.Getenv("GPG")
+
+	// a GPGKey header takes precedence over the environment
+	if key, ok := c.b.Recipe.Header["gpgkey"]; ok {
+		c.gpg = key
+	}
+
+	c.gpgReq, err = gpgRequired(c.b)
+	if err != nil {
+		return err
+	}
+	if c.gpgReq && c.gpg == "" {
+		return fmt.Errorf("GPG verification of packages is required but no GPG key is specified.\n" +
+			"Add the https URL of the distribution signing key to the definition header, e.g.:\n" +
+			"    GPGKey: https://download.opensuse.org/distribution/leap/15.0/repo/oss/repodata/repomd.xml.key\n" +
+			"or set it with the GPG environment variable")
+	}
+
+	// get mirrorURL, updateURL, OSVerison, and Includes components to definition
+	c.mirrorurl, ok = c.b.Recipe.Header["mirrorurl"]
+	if !ok {
+		return fmt.Errorf("Invalid zypper header, no MirrorURL specified")
+	}
+
+	c.updateurl, _ = c.b.Recipe.Header["updateurl"]
+
+	// look for an OS version if a mirror specifies it
+	c.osversion = ""
+	if strings.Contains(c.mirrorurl, `%{OSVERSION}`) || strings.Contains(c.updateurl, `%{OSVERSION}`) {
+		c.osversion, ok = c.b.Recipe.Header["osversion"]
+		if !ok {
+			return fmt.Errorf("Invalid zypper header, OSVersion referenced in mirror but no OSVersion specified")
+		}
+		c.mirrorurl = strings.Replace(c.mirrorurl, `%{OSVERSION}`, c.osversion, -1)
+		c.updateurl = strings.Replace(c.updateurl, `%{OSVERSION}`, c.osversion, -1)
+	}
+
+	include, _ := c.b.Recipe.Header["include"]
+
+	// check for include environment variable and add it to requires string
+	include += ` ` + os.Getenv("INCLUDE")
+
+	// trim leading and trailing whitespace
+	include = strings.TrimSpace(include)
+
+	// add aaa_base to start of include list by default
+	include = `aaa_base ` + include
+
+	c.include = include
+
+	return nil
+}
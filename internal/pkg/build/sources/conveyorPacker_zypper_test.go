@@ -0,0 +1,101 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+const zypperDef = "../testdata_good/zypper/zypper"
+
+func TestZypperConveyorPacker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	if _, err := exec.LookPath("zypper"); err != nil {
+		t.Skip("skipping test, zypper not found")
+	}
+
+	test.EnsurePrivilege(t)
+
+	defFile, err := os.Open(zypperDef)
+	if err != nil {
+		t.Fatalf("unable to open file %s: %v\n", zypperDef, err)
+	}
+	defer defFile.Close()
+
+	// create bundle to build into
+	b, err := types.NewBundle("", "sbuild-zypper")
+	if err != nil {
+		return
+	}
+
+	b.Recipe, err = parser.ParseDefinitionFile(defFile)
+	if err != nil {
+		t.Fatalf("failed to parse definition file %s: %v\n", zypperDef, err)
+	}
+
+	zcp := &ZypperConveyorPacker{}
+
+	err = zcp.Get(context.Background(), b)
+	// clean up tmpfs since assembler isnt called
+	defer os.RemoveAll(zcp.b.Path)
+	if err != nil {
+		t.Fatalf("failed to Get from %s: %v\n", zypperDef, err)
+	}
+
+	_, err = zcp.Pack()
+	if err != nil {
+		t.Fatalf("failed to Pack from %s: %v\n", zypperDef, err)
+	}
+}
+
+func TestZypperBootstrapOptions(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	os.Unsetenv("GPG")
+	os.Unsetenv("INCLUDE")
+
+	b := &types.Bundle{
+		Opts: types.Options{RequireGPG: true},
+		Recipe: types.Definition{
+			Header: map[string]string{
+				"bootstrap": "zypper",
+				"osversion": "15.0",
+				"mirrorurl": "http://download.opensuse.org/distribution/leap/%{OSVERSION}/repo/oss/",
+				"include":   "zypper",
+			},
+		},
+	}
+
+	zc := &ZypperConveyor{b: b}
+	if err := zc.getBootstrapOptions(); err == nil {
+		t.Fatalf("unexpected success without GPG key while GPG verification is required")
+	}
+
+	b.Recipe.Header["gpgkey"] = "https://download.opensuse.org/distribution/leap/15.0/repo/oss/repodata/repomd.xml.key"
+	if err := zc.getBootstrapOptions(); err != nil {
+		t.Fatalf("unexpected failure with GPG key: %v", err)
+	}
+	if zc.gpg != b.Recipe.Header["gpgkey"] || !zc.gpgReq {
+		t.Errorf("GPG key %q not used or verification not required", b.Recipe.Header["gpgkey"])
+	}
+	if expected := "http://download.opensuse.org/distribution/leap/15.0/repo/oss/"; zc.mirrorurl != expected {
+		t.Errorf("unexpected mirror URL %s, expected %s", zc.mirrorurl, expected)
+	}
+	if expected := "aaa_base zypper"; zc.include != expected {
+		t.Errorf("unexpected included packages %q, expected %q", zc.include, expected)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/containers/image/image"
+	"github.com/containers/image/pkg/compression"
+	"github.com/containers/image/types"
+	sytypes "github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+const (
+	// whiteoutPrefix marks an AUFS whiteout hiding the path without prefix
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque marks a directory whose lower layers content is hidden
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+	// overlayOpaqueXattr marks an overlayfs opaque directory
+	overlayOpaqueXattr = "trusted.overlay.opaque"
+)
+
+// checkWhiteoutMode returns an error if mode is not a known whiteout
+// handling mode
+func checkWhiteoutMode(mode string) error {
+	switch mode {
+	case sytypes.WhiteoutRemove, sytypes.WhiteoutOverlay, sytypes.WhiteoutError:
+		return nil
+	}
+	return fmt.Errorf("unknown whiteout mode %q, expected %s, %s or %s", mode, sytypes.WhiteoutRemove, sytypes.WhiteoutOverlay, sytypes.WhiteoutError)
+}
+
+// UnpackImage flattens the layers of the image referenced by ref into dest,
+// whiteouts are handled according to mode
+func UnpackImage(ctx context.Context, ref types.ImageReference, sys *types.SystemContext, dest, mode string) error {
+	if err := checkWhiteoutMode(mode); err != nil {
+		return err
+	}
+
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	img, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	for i, info := range img.LayerInfos() {
+		r, _, err := src.GetBlob(ctx, info)
+		if err != nil {
+			return fmt.Errorf("could not read layer %s: %v", info.Digest, err)
+		}
+		err = unpackLayer(r, dest, mode)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("while extracting layer %d (%s): %v", i, info.Digest, err)
+		}
+	}
+	return nil
+}
+
+// unpackLayer extracts the possibly compressed layer tarball read from r
+// over the content of dest
+func unpackLayer(r io.Reader, dest, mode string) error {
+	decompress, r, err := compression.DetectCompression(r)
+	if err != nil {
+		return err
+	}
+	if decompress != nil {
+		if r, err = decompress(r); err != nil {
+			return err
+		}
+	}
+
+	// paths extracted from this layer, opaque directories only hide the
+	// content of lower layers
+	entries := make(map[string]bool)
+	var dirs []*tar.Header
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error advancing tar stream: %v", err)
+		}
+
+		// joining to / first keeps the path inside dest
+		name := filepath.Clean(string(os.PathSeparator) + hdr.Name)
+		path := filepath.Join(dest, name)
+		base := filepath.Base(name)
+
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			if err := applyWhiteout(dest, name, mode, entries); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if entries[path] {
+			return fmt.Errorf("duplicate entry for %s", name)
+		}
+		entries[path] = true
+
+		if err := unpackEntry(dest, path, hdr, tr); err != nil {
+			return err
+		}
+
+		// Directory mtimes must be handled at the end to avoid further
+		// file creation in them to modify the directory mtime
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+	}
+
+	for _, hdr := range dirs {
+		path := filepath.Join(dest, filepath.Clean(string(os.PathSeparator)+hdr.Name))
+		if err := os.Chtimes(path, time.Now().UTC(), hdr.FileInfo().ModTime()); err != nil {
+			return fmt.Errorf("error changing time: %v", err)
+		}
+	}
+	return nil
+}
+
+// applyWhiteout handles the whiteout entry name of a layer according to mode
+func applyWhiteout(dest, name, mode string, entries map[string]bool) error {
+	if mode == sytypes.WhiteoutError {
+		return fmt.Errorf("layer contains whiteout %s and whiteout mode is %s", name, mode)
+	}
+
+	dir := filepath.Join(dest, filepath.Dir(name))
+	base := filepath.Base(name)
+
+	if base == whiteoutOpaque {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name())
+			if entries[path] {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("unable to remove %s hidden by opaque directory: %v", path, err)
+			}
+		}
+		if mode == sytypes.WhiteoutOverlay {
+			if err := syscall.Setxattr(dir, overlayOpaqueXattr, []byte("y"), 0); err != nil {
+				return fmt.Errorf("unable to mark %s as opaque directory (requires root): %v", dir, err)
+			}
+		}
+		return nil
+	}
+
+	path := filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("unable to delete whiteout path %s: %v", path, err)
+	}
+	if mode == sytypes.WhiteoutOverlay {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := syscall.Mknod(path, syscall.S_IFCHR, 0); err != nil {
+			return fmt.Errorf("unable to create overlay whiteout %s (requires root): %v", path, err)
+		}
+		entries[path] = true
+	}
+	return nil
+}
+
+// unpackEntry extracts a single layer entry at path
+func unpackEntry(dest, path string, hdr *tar.Header, r io.Reader) error {
+	// Ensure that the parent directory exists
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if hdr.Typeflag != tar.TypeDir {
+		if err := os.RemoveAll(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// Add u+w if we aren't root to allow extractions
+	extraPerms := os.FileMode(0000)
+	if os.Getuid() != 0 {
+		extraPerms = 0600
+	}
+	info := hdr.FileInfo()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		fi, err := os.Lstat(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if os.IsNotExist(err) || !fi.IsDir() {
+			if err := os.RemoveAll(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.MkdirAll(path, info.Mode()|extraPerms); err != nil {
+				return err
+			}
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, info.Mode()|extraPerms)
+		if err != nil {
+			return fmt.Errorf("unable to open file: %v", err)
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return fmt.Errorf("unable to copy: %v", err)
+		}
+		f.Close()
+	case tar.TypeLink:
+		target := filepath.Join(dest, filepath.Clean(string(os.PathSeparator)+hdr.Linkname))
+		if err := os.Link(target, path); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.Symlink(hdr.Linkname, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	sytypes "github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+type layerEntry struct {
+	name     string
+	typeflag byte
+	content  string
+}
+
+// makeLayer returns a gzipped layer tarball holding entries
+func makeLayer(t *testing.T, entries []layerEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: 0644}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		} else {
+			hdr.Size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("Failed to write tar content: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	return buf
+}
+
+var baseLayer = []layerEntry{
+	{"a/", tar.TypeDir, ""},
+	{"a/removed", tar.TypeReg, "removed"},
+	{"a/kept", tar.TypeReg, "kept"},
+	{"b/", tar.TypeDir, ""},
+	{"b/old", tar.TypeReg, "old"},
+	{"c.wh.d/", tar.TypeDir, ""},
+	{"c.wh.d/file", tar.TypeReg, "file"},
+}
+
+var whiteoutLayer = []layerEntry{
+	{"a/.wh.removed", tar.TypeReg, ""},
+	{"b/", tar.TypeDir, ""},
+	{"b/new", tar.TypeReg, "new"},
+	{"b/.wh..wh..opq", tar.TypeReg, ""},
+	{"c.wh.d/other", tar.TypeReg, "other"},
+}
+
+func unpackTestLayers(t *testing.T, mode string) (string, error) {
+	d, err := ioutil.TempDir("", "unpack-")
+	if err != nil {
+		t.Fatalf("Failed to make temporary directory: %v", err)
+	}
+
+	for _, layer := range [][]layerEntry{baseLayer, whiteoutLayer} {
+		if err := unpackLayer(makeLayer(t, layer), d, mode); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+func TestUnpackLayerWhiteoutRemove(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	d, err := unpackTestLayers(t, sytypes.WhiteoutRemove)
+	defer os.RemoveAll(d)
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	for _, path := range []string{"a/kept", "b/new", "c.wh.d/file", "c.wh.d/other"} {
+		if _, err := os.Stat(filepath.Join(d, path)); err != nil {
+			t.Errorf("Expected %s to exist: %v", path, err)
+		}
+	}
+	for _, path := range []string{"a/removed", "a/.wh.removed", "b/old", "b/.wh..wh..opq"} {
+		if _, err := os.Lstat(filepath.Join(d, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to not exist", path)
+		}
+	}
+}
+
+func TestUnpackLayerWhiteoutError(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	d, err := unpackTestLayers(t, sytypes.WhiteoutError)
+	defer os.RemoveAll(d)
+	if err == nil {
+		t.Fatalf("Unexpected success with whiteouts in %s mode", sytypes.WhiteoutError)
+	}
+}
+
+func TestUnpackLayerWhiteoutOverlay(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	d, err := ioutil.TempDir("", "unpack-")
+	if err != nil {
+		t.Fatalf("Failed to make temporary directory: %v", err)
+	}
+	defer os.RemoveAll(d)
+
+	for _, layer := range [][]layerEntry{baseLayer, whiteoutLayer[:1]} {
+		if err := unpackLayer(makeLayer(t, layer), d, sytypes.WhiteoutOverlay); err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Lstat(filepath.Join(d, "a/removed"), &st); err != nil {
+		t.Fatalf("Expected overlay whiteout: %v", err)
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFCHR || st.Rdev != 0 {
+		t.Errorf("a/removed is not an overlay whiteout")
+	}
+}
+
+func TestCheckWhiteoutMode(t *testing.T) {
+	for _, mode := range []string{sytypes.WhiteoutRemove, sytypes.WhiteoutOverlay, sytypes.WhiteoutError} {
+		if err := checkWhiteoutMode(mode); err != nil {
+			t.Errorf("Unexpected failure for mode %s: %v", mode, err)
+		}
+	}
+	if err := checkWhiteoutMode("ignore"); err == nil {
+		t.Errorf("Unexpected success for unknown mode")
+	}
+}
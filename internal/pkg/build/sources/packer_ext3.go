@@ -14,7 +14,6 @@ import (
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
@@ -31,7 +30,7 @@ func (p *Ext3Packer) Pack() (*types.Bundle, error) {
 
 	err := p.unpackExt3(p.b, p.info, rootfs)
 	if err != nil {
-		sylog.Errorf("unpackExt3 Failed: %s", err)
+		buildLog.Errorf("unpackExt3 Failed: %s", err)
 		return nil, err
 	}
 
@@ -42,49 +41,54 @@ func (p *Ext3Packer) Pack() (*types.Bundle, error) {
 func (p *Ext3Packer) unpackExt3(b *types.Bundle, info *loop.Info64, rootfs string) (err error) {
 	tmpmnt, err := ioutil.TempDir(p.b.Path, "mnt")
 
-	var number int
 	info.Flags = loop.FlagsAutoClear
 	arguments := &args.LoopArgs{
 		Image: rootfs,
 		Mode:  os.O_RDONLY,
 		Info:  *info,
 	}
-	err = getLoopDevice(arguments)
+	loopdev, number, err := getLoopDevice(arguments)
 	if err != nil {
 		return err
 	}
+	defer loopdev.Close()
 
 	path := fmt.Sprintf("/dev/loop%d", number)
-	sylog.Debugf("Mounting loop device %s to %s\n", path, tmpmnt)
+	buildLog.Debugf("Mounting loop device %s to %s\n", path, tmpmnt)
 	err = syscall.Mount(path, tmpmnt, "ext3", syscall.MS_NOSUID|syscall.MS_RDONLY|syscall.MS_NODEV, "errors=remount-ro")
 	if err != nil {
-		sylog.Errorf("Mount Failed: %s", err)
+		buildLog.Errorf("Mount Failed: %s", err)
+		loopdev.Detach()
 		return err
 	}
 	defer syscall.Unmount(tmpmnt, 0)
 
 	//copy filesystem into bundle rootfs
-	sylog.Debugf("Copying filesystem from %s to %s in Bundle\n", tmpmnt, b.Rootfs())
+	buildLog.Debugf("Copying filesystem from %s to %s in Bundle\n", tmpmnt, b.Rootfs())
 	cmd := exec.Command("cp", "-r", tmpmnt+`/.`, b.Rootfs())
 	err = cmd.Run()
 	if err != nil {
-		sylog.Errorf("cp Failed: %s", err)
+		buildLog.Errorf("cp Failed: %s", err)
 		return err
 	}
 
 	return err
 }
 
-// getLoopDevice attaches a loop device with the specified arguments
-func getLoopDevice(arguments *args.LoopArgs) error {
-	var reply int
-	reply = 1
+// getLoopDevice attaches a loop device with the specified arguments and
+// returns it along with its number
+func getLoopDevice(arguments *args.LoopArgs) (*loop.Device, int, error) {
+	var number int
 	loopdev := new(loop.Device)
 	loopdev.MaxLoopDevices = 256
 
-	if err := loopdev.AttachFromPath(arguments.Image, arguments.Mode, &reply); err != nil {
-		return err
+	if err := loopdev.AttachFromPath(arguments.Image, arguments.Mode, &number); err != nil {
+		return nil, 0, err
 	}
 
-	return loopdev.SetStatus(&arguments.Info)
+	if err := loopdev.SetStatus(&arguments.Info); err != nil {
+		loopdev.Detach()
+		return nil, 0, err
+	}
+	return loopdev, number, nil
 }
@@ -9,7 +9,6 @@ import (
 	"os/exec"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 // SandboxPacker holds the locations of where to pack from and to
@@ -24,11 +23,11 @@ func (p *SandboxPacker) Pack() (*types.Bundle, error) {
 	rootfs := p.srcdir
 
 	//copy filesystem into bundle rootfs
-	sylog.Debugf("Copying file system from %s to %s in Bundle\n", rootfs, p.b.Rootfs())
+	buildLog.Debugf("Copying file system from %s to %s in Bundle\n", rootfs, p.b.Rootfs())
 	cmd := exec.Command("cp", "-r", rootfs+`/.`, p.b.Rootfs())
 	err := cmd.Run()
 	if err != nil {
-		sylog.Errorf("cp Failed: %s", err)
+		buildLog.Errorf("cp Failed: %s", err)
 		return nil, err
 	}
 
@@ -14,7 +14,6 @@ import (
 
 	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
@@ -29,7 +28,7 @@ func (p *SIFPacker) Pack() (*types.Bundle, error) {
 
 	err := p.unpackSIF(p.b, p.srcfile)
 	if err != nil {
-		sylog.Errorf("unpackSIF Failed: %s", err)
+		buildLog.Errorf("unpackSIF Failed: %s", err)
 		return nil, err
 	}
 
@@ -43,7 +42,7 @@ func (p *SIFPacker) unpackSIF(b *types.Bundle, rootfs string) (err error) {
 	// load the container
 	fimg, err := sif.LoadContainer(rootfs, true)
 	if err != nil {
-		sylog.Errorf("error loading sif file %s: %s\n", rootfs, err)
+		buildLog.Errorf("error loading sif file %s: %s\n", rootfs, err)
 		return err
 	}
 	defer fimg.UnloadContainer()
@@ -94,8 +93,10 @@ func unpackImagePartion(src, dest, mountType string, info *loop.Info64) (err err
 	if err := loopdev.AttachFromPath(src, os.O_RDONLY, &number); err != nil {
 		return err
 	}
+	defer loopdev.Close()
 
 	if err := loopdev.SetStatus(info); err != nil {
+		loopdev.Detach()
 		return err
 	}
 
@@ -106,20 +107,21 @@ func unpackImagePartion(src, dest, mountType string, info *loop.Info64) (err err
 	defer os.RemoveAll(tmpmnt)
 
 	path := fmt.Sprintf("/dev/loop%d", number)
-	sylog.Debugf("Mounting loop device %s to %s\n", path, tmpmnt)
+	buildLog.Debugf("Mounting loop device %s to %s\n", path, tmpmnt)
 	err = syscall.Mount(path, tmpmnt, mountType, syscall.MS_NOSUID|syscall.MS_RDONLY|syscall.MS_NODEV, "errors=remount-ro")
 	if err != nil {
-		sylog.Errorf("Mount Failed: %s", err)
+		buildLog.Errorf("Mount Failed: %s", err)
+		loopdev.Detach()
 		return err
 	}
 	defer syscall.Unmount(tmpmnt, 0)
 
 	//copy filesystem into dest
-	sylog.Debugf("Copying filesystem from %s to %s\n", tmpmnt, dest)
+	buildLog.Debugf("Copying filesystem from %s to %s\n", tmpmnt, dest)
 	cmd := exec.Command("cp", "-r", tmpmnt+`/.`, dest)
 	err = cmd.Run()
 	if err != nil {
-		sylog.Errorf("cp Failed: %s", err)
+		buildLog.Errorf("cp Failed: %s", err)
 		return err
 	}
 
@@ -11,7 +11,6 @@ import (
 	"strconv"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
@@ -28,7 +27,7 @@ func (p *SquashfsPacker) Pack() (*types.Bundle, error) {
 
 	err := p.unpackSquashfs(p.b, p.info, rootfs)
 	if err != nil {
-		sylog.Errorf("unpackSquashfs Failed: %s", err)
+		buildLog.Errorf("unpackSquashfs Failed: %s", err)
 		return nil, err
 	}
 
@@ -40,20 +39,20 @@ func (p *SquashfsPacker) unpackSquashfs(b *types.Bundle, info *loop.Info64, root
 	trimfile, err := ioutil.TempFile(p.b.Path, "trim.squashfs")
 
 	//trim header
-	sylog.Debugf("Creating copy of %s without header at %s\n", rootfs, trimfile.Name())
+	buildLog.Debugf("Creating copy of %s without header at %s\n", rootfs, trimfile.Name())
 	cmd := exec.Command("dd", "bs="+strconv.Itoa(int(info.Offset)), "skip=1", "if="+rootfs, "of="+trimfile.Name())
 	err = cmd.Run()
 	if err != nil {
-		sylog.Errorf("Trimming header Failed: %s", err)
+		buildLog.Errorf("Trimming header Failed: %s", err)
 		return err
 	}
 
 	//copy filesystem into bundle rootfs
-	sylog.Debugf("Unsquashing %s to %s in Bundle\n", trimfile.Name(), b.Rootfs())
+	buildLog.Debugf("Unsquashing %s to %s in Bundle\n", trimfile.Name(), b.Rootfs())
 	cmd = exec.Command("unsquashfs", "-f", "-d", b.Rootfs(), trimfile.Name())
 	err = cmd.Run()
 	if err != nil {
-		sylog.Errorf("unsquashfs Failed: %s", err)
+		buildLog.Errorf("unsquashfs Failed: %s", err)
 		return err
 	}
 
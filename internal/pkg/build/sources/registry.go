@@ -0,0 +1,116 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// ConveyorPacker gets the data of a bootstrap agent and packs it into a
+// Bundle, Get stops when its context is canceled
+type ConveyorPacker interface {
+	Get(context.Context, *types.Bundle) error
+	Pack() (*types.Bundle, error)
+}
+
+// Config holds the build settings passed to ConveyorPacker factories
+type Config struct {
+	LibraryURL string
+	AuthToken  string
+}
+
+// ConveyorPackerFactory creates the ConveyorPacker of a build
+type ConveyorPackerFactory func(cfg Config) ConveyorPacker
+
+var registry = struct {
+	sync.Mutex
+	factories map[string]ConveyorPackerFactory
+	// uris holds the agents registered with Register, which can also be
+	// used as build URIs (e.g. nix://<ref>)
+	uris map[string]bool
+}{
+	factories: map[string]ConveyorPackerFactory{
+		"library": func(cfg Config) ConveyorPacker {
+			return &LibraryConveyorPacker{
+				LibraryURL: cfg.LibraryURL,
+				AuthToken:  cfg.AuthToken,
+			}
+		},
+		"shub":           func(Config) ConveyorPacker { return &ShubConveyorPacker{} },
+		"docker":         newOCIConveyorPacker,
+		"docker-archive": newOCIConveyorPacker,
+		"docker-daemon":  newOCIConveyorPacker,
+		"oci":            newOCIConveyorPacker,
+		"oci-archive":    newOCIConveyorPacker,
+		"busybox":        func(Config) ConveyorPacker { return &BusyBoxConveyorPacker{} },
+		"debootstrap":    func(Config) ConveyorPacker { return &DebootstrapConveyorPacker{} },
+		"arch":           func(Config) ConveyorPacker { return &ArchConveyorPacker{} },
+		"localimage":     func(Config) ConveyorPacker { return &LocalConveyorPacker{} },
+		"yum":            func(Config) ConveyorPacker { return &YumConveyorPacker{} },
+		"zypper":         func(Config) ConveyorPacker { return &ZypperConveyorPacker{} },
+	},
+	uris: make(map[string]bool),
+}
+
+func newOCIConveyorPacker(Config) ConveyorPacker {
+	return &OCIConveyorPacker{}
+}
+
+// Register adds a bootstrap agent handled by the ConveyorPackers created by
+// factory. The agent can be used in the Bootstrap header of definition files
+// and as a build URI.
+func Register(name string, factory ConveyorPackerFactory) error {
+	if name == "" || factory == nil {
+		return fmt.Errorf("bootstrap agent name and factory are required")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.factories[name]; ok {
+		return fmt.Errorf("bootstrap agent already registered: %s", name)
+	}
+	registry.factories[name] = factory
+	registry.uris[name] = true
+	return nil
+}
+
+// New returns a ConveyorPacker for the bootstrap agent name
+func New(name string, cfg Config) (ConveyorPacker, error) {
+	registry.Lock()
+	factory, ok := registry.factories[name]
+	registry.Unlock()
+
+	if name == "" {
+		return nil, fmt.Errorf("no bootstrap specification found")
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid build source %s", name)
+	}
+	return factory(cfg), nil
+}
+
+// IsRegistered returns whether name is a known bootstrap agent
+func IsRegistered(name string) bool {
+	registry.Lock()
+	defer registry.Unlock()
+
+	_, ok := registry.factories[name]
+	return ok
+}
+
+// IsRegisteredURI returns whether transport is a bootstrap agent added with
+// Register
+func IsRegisteredURI(transport string) bool {
+	registry.Lock()
+	defer registry.Unlock()
+
+	return registry.uris[transport]
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+type testConveyorPacker struct {
+	cfg sources.Config
+}
+
+func (cp *testConveyorPacker) Get(ctx context.Context, b *types.Bundle) error {
+	return nil
+}
+
+func (cp *testConveyorPacker) Pack() (*types.Bundle, error) {
+	return nil, nil
+}
+
+func TestRegister(t *testing.T) {
+	factory := func(cfg sources.Config) sources.ConveyorPacker {
+		return &testConveyorPacker{cfg: cfg}
+	}
+
+	if err := sources.Register("testagent", factory); err != nil {
+		t.Fatalf("failed to register bootstrap agent: %v", err)
+	}
+	if err := sources.Register("testagent", factory); err == nil {
+		t.Errorf("unexpected success registering an agent twice")
+	}
+	if err := sources.Register("docker", factory); err == nil {
+		t.Errorf("unexpected success registering a builtin agent")
+	}
+
+	cp, err := sources.New("testagent", sources.Config{LibraryURL: "https://library"})
+	if err != nil {
+		t.Fatalf("failed to create conveyor packer: %v", err)
+	}
+	if tcp, ok := cp.(*testConveyorPacker); !ok || tcp.cfg.LibraryURL != "https://library" {
+		t.Errorf("unexpected conveyor packer %#v", cp)
+	}
+
+	if !sources.IsRegisteredURI("testagent") {
+		t.Errorf("registered agent not accepted as URI")
+	}
+	if sources.IsRegisteredURI("debootstrap") {
+		t.Errorf("builtin agent accepted as URI")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		agent     string
+		expectErr bool
+	}{
+		{"Library", "library", false},
+		{"Docker", "docker", false},
+		{"Yum", "yum", false},
+		{"Empty", "", true},
+		{"Unknown", "unknown", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp, err := sources.New(tt.agent, sources.Config{AuthToken: "token"})
+			if err != nil && !tt.expectErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err == nil && tt.expectErr {
+				t.Errorf("unexpected success for agent %q", tt.agent)
+			}
+			if lcp, ok := cp.(*sources.LibraryConveyorPacker); ok && lcp.AuthToken != "token" {
+				t.Errorf("library conveyor packer not configured")
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	units "github.com/docker/go-units"
+)
+
+const (
+	// expansionFactor is the estimated ratio between the size of an
+	// unpacked root filesystem and the size of its compressed image
+	expansionFactor = 3
+	// sizeCheckInterval is the interval between two measures of the bundle
+	// size when its size is capped
+	sizeCheckInterval = 2 * time.Second
+)
+
+// pathSize returns the size of the file at path, or the total size of the
+// files under path if it is a directory. Files removed while walking the
+// directory are ignored.
+func pathSize(path string) (int64, error) {
+	if _, err := os.Lstat(path); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// baseImageSize returns the size of the local image or archive the build
+// bootstraps from, or 0 if it is unknown, e.g. for images pulled from a
+// registry
+func (b *Build) baseImageSize() int64 {
+	from := b.d.Header["from"]
+
+	switch b.d.Header["bootstrap"] {
+	case "localimage":
+	case "docker-archive", "oci-archive", "oci":
+		// drop the reference of the image in the archive or layout
+		from = strings.SplitN(from, ":", 2)[0]
+	default:
+		return 0
+	}
+
+	size, err := pathSize(from)
+	if err != nil {
+		buildLog.Debugf("Could not get size of %s: %v", from, err)
+		return 0
+	}
+	return size
+}
+
+// freeSpace returns the space available to unprivileged users on the
+// filesystem holding path, and the ID of the filesystem
+func freeSpace(path string) (int64, syscall.Fsid, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, syscall.Fsid{}, err
+	}
+	return int64(st.Bavail) * st.Bsize, st.Fsid, nil
+}
+
+// checkSpace verifies, before building, that the temporary directory and
+// the destination have room for the build. The root filesystem is estimated
+// to take expansionFactor times the size of the base image, and the image
+// created from it about the size of the base image. The check is skipped if
+// the size of the base image is unknown.
+func (b *Build) checkSpace() error {
+	base := b.baseImageSize()
+	if base == 0 {
+		buildLog.Debugf("Unknown size of base image, skipping disk space check")
+		return nil
+	}
+
+	rootfs := base * expansionFactor
+
+	// the squashfs partition of SIF images is created in the bundle
+	bundle := rootfs
+	if b.format == "sif" {
+		bundle += base
+	}
+	if max := b.b.Opts.TmpDirMaxSize; max > 0 && bundle > max {
+		return fmt.Errorf("the build needs about %s of temporary space, more than the maximum size of %s",
+			units.BytesSize(float64(bundle)), units.BytesSize(float64(max)))
+	}
+
+	tmpFree, tmpFS, err := freeSpace(filepath.Dir(b.b.Path))
+	if err != nil {
+		return fmt.Errorf("while checking space of %s: %v", filepath.Dir(b.b.Path), err)
+	}
+
+	var dest int64
+	destDir, _ := filepath.Abs(filepath.Dir(b.dest))
+	destFree, destFS := tmpFree, tmpFS
+	switch b.format {
+	case "docker-daemon":
+		// the image is stored by the docker daemon
+		destDir = ""
+	default:
+		if destFree, destFS, err = freeSpace(destDir); err != nil {
+			return fmt.Errorf("while checking space of %s: %v", destDir, err)
+		}
+		// sandboxes are moved from the bundle, they only take space if
+		// the destination is on another filesystem
+		if b.format != "sandbox" {
+			dest = base
+		} else if destFS != tmpFS {
+			dest = rootfs
+		}
+	}
+
+	if destDir != "" && destFS == tmpFS {
+		if need := bundle + dest; need > tmpFree {
+			return notEnoughSpace(filepath.Dir(b.b.Path), need, tmpFree)
+		}
+		return nil
+	}
+	if bundle > tmpFree {
+		return notEnoughSpace(filepath.Dir(b.b.Path), bundle, tmpFree)
+	}
+	if destDir != "" && dest > destFree {
+		return notEnoughSpace(destDir, dest, destFree)
+	}
+	return nil
+}
+
+func notEnoughSpace(dir string, need, free int64) error {
+	return fmt.Errorf("not enough space in %s: the build needs about %s, %s available",
+		dir, units.BytesSize(float64(need)), units.BytesSize(float64(free)))
+}
+
+// sizeWatch watches the size of a build directory, see watchBundleSize
+type sizeWatch struct {
+	mu     sync.Mutex
+	err    error
+	cancel context.CancelFunc
+}
+
+// watchBundleSize returns a context derived from ctx which is canceled once
+// the files under path exceed max bytes, the error of the returned watch
+// then reports it. The watch must be stopped once the build is done.
+func watchBundleSize(ctx context.Context, path string, max int64) (context.Context, *sizeWatch) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &sizeWatch{cancel: cancel}
+
+	go func() {
+		ticker := time.NewTicker(sizeCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			size, err := pathSize(path)
+			if err != nil {
+				buildLog.Debugf("Could not get size of %s: %v", path, err)
+				continue
+			}
+			if size > max {
+				w.mu.Lock()
+				w.err = fmt.Errorf("build directory %s exceeds the maximum size of %s", path, units.BytesSize(float64(max)))
+				w.mu.Unlock()
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return ctx, w
+}
+
+// Err returns the error reporting that the directory exceeded its maximum
+// size, or nil
+func (w *sizeWatch) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Stop stops watching the directory and releases the context
+func (w *sizeWatch) Stop() {
+	w.cancel()
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+func TestCheckSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-space-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "base.tar")
+	if err := ioutil.WriteFile(image, make([]byte, 1<<20), 0644); err != nil {
+		t.Fatalf("failed to write base image: %v", err)
+	}
+
+	b := &Build{
+		format: "sif",
+		dest:   filepath.Join(dir, "image.sif"),
+		d: types.Definition{
+			Header: map[string]string{"bootstrap": "docker-archive", "from": image + ":app:latest"},
+		},
+		b: &types.Bundle{Path: filepath.Join(dir, "bundle")},
+	}
+
+	if size := b.baseImageSize(); size != 1<<20 {
+		t.Errorf("unexpected base image size %d", size)
+	}
+	if err := b.checkSpace(); err != nil {
+		t.Errorf("unexpected error checking space: %v", err)
+	}
+
+	b.b.Opts.TmpDirMaxSize = 2 << 20
+	if err := b.checkSpace(); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("unexpected error %v with a too small maximum size", err)
+	}
+
+	b.d.Header = map[string]string{"bootstrap": "docker", "from": "alpine"}
+	if err := b.checkSpace(); err != nil {
+		t.Errorf("unexpected error without base image size: %v", err)
+	}
+}
+
+func TestWatchBundleSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-watch-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, watch := watchBundleSize(context.Background(), dir, 1024)
+	defer watch.Stop()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("failed to write data: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(3 * sizeCheckInterval):
+		t.Fatalf("context not canceled once the maximum size is exceeded")
+	}
+	if err := watch.Err(); err == nil || !strings.Contains(err.Error(), "exceeds the maximum size") {
+		t.Errorf("unexpected cancellation cause %v", err)
+	}
+}
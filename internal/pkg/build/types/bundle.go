@@ -36,12 +36,17 @@ type Bundle struct {
 	BindPath    []string          `json:"bindPath"`
 	Path        string            `json:"bundlePath"`
 	Opts        Options           `json:"opts"`
+	// Timings are the durations of the build phases completed so far
+	Timings []PhaseTiming `json:"timings,omitempty"`
 }
 
 // Options ...
 type Options struct {
 	// TmpDir specifies a non-standard temporary location to perform a build
 	TmpDir string
+	// tmpDirMaxSize caps the size in bytes of the temporary build
+	// directory, the build fails once it is exceeded. It is unlimited if 0
+	TmpDirMaxSize int64 `json:"tmpDirMaxSize"`
 	// sections are the parts of the definition to run during the build
 	Sections []string `json:"sections"`
 	// noTest indicates if build should skip running the test script
@@ -52,8 +57,69 @@ type Options struct {
 	Update bool `json:"update"`
 	// noHTTPS
 	NoHTTPS bool `json:"noHTTPS"`
+	// platform selects the os/arch[/variant] image of docker and OCI manifest lists
+	Platform string `json:"platform"`
+	// whiteout selects how whiteouts are handled when flattening OCI layers
+	Whiteout string `json:"whiteout"`
+	// requireGPG makes GPG verification of bootstrapped packages mandatory
+	RequireGPG bool `json:"requireGPG"`
+	// resume checkpoints the bootstrapped rootfs and restarts a failed build
+	// from the checkpoint
+	Resume bool `json:"resume"`
+	// network selects the network namespace in which %post and %test run
+	Network string `json:"network"`
+	// buildArgs holds the values of the {{ .NAME }} build arguments of
+	// definition files
+	BuildArgs map[string]string `json:"buildArgs"`
+	// compression selects the compression algorithm of the squashfs
+	// partition of SIF images
+	Compression string `json:"compression"`
+	// noCache disables the build cache of the root filesystem after %post
+	NoCache bool `json:"noCache"`
+	// scan is the name of the vulnerability scanner run before assembling
+	// the image, the scan is skipped if empty
+	Scan string `json:"scan"`
+	// scanSeverity is the severity from which vulnerabilities fail the
+	// build, less severe ones are reported as warnings
+	ScanSeverity string `json:"scanSeverity"`
+	// scanURL is the address of the vulnerability database of the scanner
+	ScanURL string `json:"scanURL"`
+	// sign signs SIF images with a private key of the local keyring once
+	// created
+	Sign bool `json:"sign"`
+	// signKey is the fingerprint, or key ID, of the private key signing the
+	// image, it may be empty if the keyring holds a single key
+	SignKey string `json:"signKey"`
+	// ociAnnotations adds org.opencontainers.image.* annotations to the
+	// labels of the image
+	OCIAnnotations bool `json:"ociAnnotations"`
+	// context is the directory of the build context, the git repository
+	// holding it provides the source and revision annotations
+	Context string `json:"context"`
 }
 
+// Whiteout handling modes used when flattening OCI layers into the bundle
+const (
+	// WhiteoutRemove applies whiteouts by removing the hidden paths
+	WhiteoutRemove = "remove"
+	// WhiteoutOverlay applies whiteouts and leaves overlayfs whiteouts in place
+	WhiteoutOverlay = "overlay"
+	// WhiteoutError aborts the build if a layer contains whiteouts
+	WhiteoutError = "error"
+)
+
+// Network modes of the %post and %test scripts
+const (
+	// NetworkHost runs the scripts in the host network namespace
+	NetworkHost = "host"
+	// NetworkNone runs the scripts in a network namespace with only a
+	// loopback interface
+	NetworkNone = "none"
+	// NetworkBridge runs the scripts in a network namespace connected to
+	// the host with the bridge CNI network
+	NetworkBridge = "bridge"
+)
+
 // NewBundle creates a Bundle environment
 func NewBundle(bundleDir, bundlePrefix string) (b *Bundle, err error) {
 	b = &Bundle{}
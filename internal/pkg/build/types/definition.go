@@ -41,12 +41,20 @@ type ImageScripts struct {
 type Data struct {
 	Files   []FileTransport `json:"files"`
 	Scripts `json:"buildScripts"`
+	// Sections holds the custom sections handled by build plugins
+	Sections map[string]string `json:"sections,omitempty"`
 }
 
 // FileTransport holds source and destination information of files to copy into the container
 type FileTransport struct {
 	Src string `json:"source"`
 	Dst string `json:"destination"`
+	// Exclude holds patterns of files not to copy
+	Exclude []string `json:"exclude,omitempty"`
+	// PreserveOwner and PreserveXattrs keep the ownership and the extended
+	// attributes of the copied files
+	PreserveOwner  bool `json:"preserveOwner,omitempty"`
+	PreserveXattrs bool `json:"preserveXattrs,omitempty"`
 }
 
 // Scripts defines scripts that are used at build time.
@@ -55,6 +63,44 @@ type Scripts struct {
 	Setup string `json:"setup"`
 	Post  string `json:"post"`
 	Test  string `json:"test"`
+	// PreAssemble and PostAssemble are run on the host before and after
+	// the image is assembled
+	PreAssemble  string `json:"preAssemble,omitempty"`
+	PostAssemble string `json:"postAssemble,omitempty"`
+	// Tests are the named %test blocks, e.g. %test smoke, in order of
+	// definition
+	Tests []TestScript `json:"tests,omitempty"`
+}
+
+// TestScript is a named %test block of a definition
+type TestScript struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+}
+
+// BuildTests returns the tests run at build time: the unnamed %test
+// section, named DefaultTestName, followed by the named %test blocks
+func (s Scripts) BuildTests() []TestScript {
+	var tests []TestScript
+	if s.Test != "" {
+		tests = append(tests, TestScript{Name: DefaultTestName, Script: s.Test})
+	}
+	return append(tests, s.Tests...)
+}
+
+// Locations holds the line numbers of the header keywords and sections of a
+// definition file
+type Locations struct {
+	Header   map[string]int
+	Sections []SectionLocation
+}
+
+// SectionLocation is a section of a definition file, Args holds the words
+// following the section name, e.g. "from <stage>" in %files from <stage>
+type SectionLocation struct {
+	Name string
+	Args string
+	Line int
 }
 
 // NewDefinitionFromURI crafts a new Definition given a URI
@@ -0,0 +1,111 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// argumentRegexp matches the {{ .NAME }} placeholders of build arguments
+var argumentRegexp = regexp.MustCompile(`{{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// argumentNameRegexp matches the valid names of build arguments
+var argumentNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseDefinitionFileWithArgs parses a definition file like
+// ParseDefinitionFile after replacing its {{ .NAME }} placeholders with the
+// build arguments. args take precedence over the KEY=VAL defaults of the
+// %arguments section, and placeholders of unknown arguments are left as is.
+func ParseDefinitionFileWithArgs(r io.Reader, args map[string]string) (d types.Definition, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return d, err
+	}
+
+	data, err = applyArguments(data, args)
+	if err != nil {
+		return d, err
+	}
+
+	return ParseDefinitionFile(bytes.NewReader(data))
+}
+
+// parseArguments returns the arguments declared in the %arguments section
+// of a definition file, arguments declared without a default value are
+// mapped to nil
+func parseArguments(data []byte) (map[string]*string, error) {
+	declared := make(map[string]*string)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	inArguments := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && line[0] == '%' {
+			inArguments = getSectionName(line) == "arguments"
+			continue
+		}
+		if !inArguments || line == "" || line[0] == '#' {
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(split[0])
+		if !argumentNameRegexp.MatchString(key) {
+			return nil, fmt.Errorf("invalid build argument name %q", key)
+		}
+		if len(split) == 1 {
+			declared[key] = nil
+			continue
+		}
+		val := strings.TrimSpace(split[1])
+		if len(val) > 1 && val[0] == '"' && val[len(val)-1] == '"' {
+			val = val[1 : len(val)-1]
+		}
+		declared[key] = &val
+	}
+
+	return declared, s.Err()
+}
+
+// applyArguments replaces the placeholders of the declared and passed build
+// arguments in data
+func applyArguments(data []byte, args map[string]string) ([]byte, error) {
+	declared, err := parseArguments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for key, val := range declared {
+		if val != nil {
+			values[key] = *val
+		}
+	}
+	for key, val := range args {
+		values[key] = val
+	}
+	for key := range declared {
+		if _, ok := values[key]; !ok {
+			return nil, fmt.Errorf("build argument %s has no value", key)
+		}
+	}
+
+	return argumentRegexp.ReplaceAllFunc(data, func(m []byte) []byte {
+		key := string(argumentRegexp.FindSubmatch(m)[1])
+		if val, ok := values[key]; ok {
+			return []byte(val)
+		}
+		return m
+	}), nil
+}
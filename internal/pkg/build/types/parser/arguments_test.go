@@ -0,0 +1,96 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDefinitionFileWithArgs(t *testing.T) {
+	def := `Bootstrap: docker
+From: ubuntu:{{ .TAG }}
+
+%arguments
+    # defaults
+    TAG=18.04
+    VERSION="1.2"
+    NAME
+
+%labels
+    Version {{.VERSION}}
+
+%post
+    echo {{ .NAME }} {{ .UNKNOWN }}`
+
+	tests := []struct {
+		name    string
+		args    map[string]string
+		from    string
+		version string
+		post    string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			args:    map[string]string{"NAME": "test"},
+			from:    "ubuntu:18.04",
+			version: "1.2",
+			post:    "echo test {{ .UNKNOWN }}",
+		},
+		{
+			name:    "overrides",
+			args:    map[string]string{"NAME": "test", "TAG": "16.04", "VERSION": "2.0"},
+			from:    "ubuntu:16.04",
+			version: "2.0",
+			post:    "echo test {{ .UNKNOWN }}",
+		},
+		{
+			name:    "undeclared",
+			args:    map[string]string{"NAME": "test", "UNKNOWN": "value"},
+			from:    "ubuntu:18.04",
+			version: "1.2",
+			post:    "echo test value",
+		},
+		{
+			name:    "missing",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDefinitionFileWithArgs(strings.NewReader(def), tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success parsing definition")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse definition: %v", err)
+			}
+			if d.Header["from"] != tt.from {
+				t.Errorf("unexpected from %q, expected %q", d.Header["from"], tt.from)
+			}
+			if d.Labels["Version"] != tt.version {
+				t.Errorf("unexpected version label %q, expected %q", d.Labels["Version"], tt.version)
+			}
+			if strings.TrimSpace(d.BuildData.Post) != tt.post {
+				t.Errorf("unexpected post %q, expected %q", d.BuildData.Post, tt.post)
+			}
+			if _, ok := d.BuildData.Sections["arguments"]; ok {
+				t.Errorf("arguments section stored as a custom section")
+			}
+		})
+	}
+}
+
+func TestParseArgumentsInvalidName(t *testing.T) {
+	if _, err := parseArguments([]byte("%arguments\n    1TAG=1\n")); err == nil {
+		t.Errorf("unexpected success parsing invalid argument name")
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"unicode"
@@ -101,7 +103,7 @@ func scanDefinitionFile(data []byte, atEOF bool) (advance int, token []byte, err
 
 func isValidSection(key string) bool {
 	if _, ok := validSections[key]; !ok {
-		return false
+		return syplugin.IsBuildSection(key)
 	}
 
 	return true
@@ -129,6 +131,36 @@ func splitToken(tok string) (ident string, content string) {
 	return strings.ToLower(tokSplit[0]), content
 }
 
+// validTestName matches the names of %test blocks
+var validTestName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// testName returns the name of a %test block from its section line, e.g.
+// smoke for "%test smoke", or an empty string if the line is not a named
+// %test block
+func testName(line string) string {
+	fields := strings.Fields(strings.TrimLeft(line, "%"))
+	if len(fields) < 2 || strings.ToLower(fields[0]) != "test" {
+		return ""
+	}
+	return fields[1]
+}
+
+// testScript returns the named %test block of tok, ok is false if tok is
+// not a named %test block
+func testScript(tok string) (t types.TestScript, ok bool, err error) {
+	split := strings.SplitN(tok, "\n", 2)
+	if t.Name = testName(split[0]); t.Name == "" {
+		return t, false, nil
+	}
+	if !validTestName.MatchString(t.Name) {
+		return t, true, fmt.Errorf("invalid test name %q", t.Name)
+	}
+	if len(split) == 2 {
+		t.Script = strings.TrimRightFunc(split[1], unicode.IsSpace)
+	}
+	return t, true, nil
+}
+
 var sectionsMutex = &sync.Mutex{}
 
 // parseTokenSection splits the token into maximum 2 strings separated by a newline,
@@ -142,7 +174,7 @@ func parseTokenSection(tok string, sections map[string]string) {
 	}
 
 	key := getSectionName(split[0])
-	if !isValidSection(key) {
+	if !isValidSection(key) || testName(split[0]) != "" {
 		return
 	}
 
@@ -154,6 +186,22 @@ func parseTokenSection(tok string, sections map[string]string) {
 func doSections(s *bufio.Scanner, d *types.Definition) error {
 	sectionsMap := make(map[string]string)
 
+	// named %test blocks are kept in order of definition
+	var tests []types.TestScript
+	seenTests := make(map[string]bool)
+	addTest := func(tok string) error {
+		t, ok, err := testScript(tok)
+		if !ok || err != nil {
+			return err
+		}
+		if seenTests[t.Name] {
+			return fmt.Errorf("test %s is defined more than once", t.Name)
+		}
+		seenTests[t.Name] = true
+		tests = append(tests, t)
+		return nil
+	}
+
 	var wg sync.WaitGroup
 
 	tok := strings.TrimSpace(s.Text())
@@ -167,6 +215,9 @@ func doSections(s *bufio.Scanner, d *types.Definition) error {
 			}
 		} else {
 			//this is a section
+			if err := addTest(tok); err != nil {
+				return err
+			}
 			parseTokenSection(tok, sectionsMap)
 			syplugin.BuildHandleSections(splitToken(tok))
 		}
@@ -180,6 +231,10 @@ func doSections(s *bufio.Scanner, d *types.Definition) error {
 
 		tok := s.Text()
 
+		if err := addTest(tok); err != nil {
+			return err
+		}
+
 		// Parse each token -> section
 		wg.Add(1)
 		go func() {
@@ -200,7 +255,11 @@ func doSections(s *bufio.Scanner, d *types.Definition) error {
 	}
 
 	wg.Wait()
-	return populateDefinition(sectionsMap, d)
+	if err := populateDefinition(sectionsMap, d); err != nil {
+		return err
+	}
+	d.BuildData.Tests = tests
+	return nil
 }
 
 func populateDefinition(sections map[string]string, d *types.Definition) error {
@@ -214,17 +273,11 @@ func populateDefinition(sections map[string]string, d *types.Definition) error {
 		if line = strings.TrimSpace(line); line == "" || strings.Index(line, "#") == 0 {
 			continue
 		}
-		var src, dst string
-		lineSubs := strings.SplitN(line, " ", 2)
-		if len(lineSubs) < 2 {
-			src = strings.TrimSpace(lineSubs[0])
-			dst = ""
-		} else {
-			src = strings.TrimSpace(lineSubs[0])
-			dst = strings.TrimSpace(lineSubs[1])
+		transport, err := parseFileTransport(line)
+		if err != nil {
+			return err
 		}
-
-		files = append(files, types.FileTransport{Src: src, Dst: dst})
+		files = append(files, transport)
 	}
 
 	// labels are parsed as a map[string]string
@@ -260,11 +313,23 @@ func populateDefinition(sections map[string]string, d *types.Definition) error {
 		Labels: labels,
 	}
 	d.BuildData.Files = files
+	for key, section := range sections {
+		if _, ok := validSections[key]; ok {
+			continue
+		}
+		if d.BuildData.Sections == nil {
+			d.BuildData.Sections = make(map[string]string)
+		}
+		d.BuildData.Sections[key] = section
+	}
 	d.BuildData.Scripts = types.Scripts{
 		Pre:   sections["pre"],
 		Setup: sections["setup"],
 		Post:  sections["post"],
 		Test:  sections["test"],
+
+		PreAssemble:  sections["pre-assemble"],
+		PostAssemble: sections["post-assemble"],
 	}
 
 	// make sure information was valid by checking if definition is not equal to an empty one
@@ -278,6 +343,46 @@ func populateDefinition(sections map[string]string, d *types.Definition) error {
 	return nil
 }
 
+// parseFileTransport parses a %files line: a source, which may be a glob,
+// an optional destination, then !<pattern> exclusions and a
+// --preserve=owner,xattrs flag
+func parseFileTransport(line string) (t types.FileTransport, err error) {
+	fields := strings.Fields(line)
+	t.Src = fields[0]
+
+	var dst []string
+	options := false
+	for _, f := range fields[1:] {
+		switch {
+		case len(f) > 1 && f[0] == '!':
+			t.Exclude = append(t.Exclude, f[1:])
+			options = true
+		case strings.HasPrefix(f, "--preserve="):
+			for _, p := range strings.Split(strings.TrimPrefix(f, "--preserve="), ",") {
+				switch p {
+				case "owner":
+					t.PreserveOwner = true
+				case "xattrs":
+					t.PreserveXattrs = true
+				default:
+					return t, fmt.Errorf("invalid %%files preserve flag %s in: %s", p, line)
+				}
+			}
+			options = true
+		default:
+			dst = append(dst, f)
+		}
+	}
+
+	if options {
+		t.Dst = strings.Join(dst, " ")
+	} else {
+		// keep spaces of destinations as is
+		t.Dst = strings.TrimSpace(line[len(t.Src):])
+	}
+	return t, nil
+}
+
 func doHeader(h string, d *types.Definition) (err error) {
 	h = strings.TrimSpace(h)
 	toks := strings.Split(h, "\n")
@@ -355,6 +460,19 @@ func writeFilesIfExists(w io.Writer, f []types.FileTransport) {
 			w.Write([]byte(ft.Src))
 			w.Write([]byte("\t"))
 			w.Write([]byte(ft.Dst))
+			for _, e := range ft.Exclude {
+				w.Write([]byte("\t!" + e))
+			}
+			var preserve []string
+			if ft.PreserveOwner {
+				preserve = append(preserve, "owner")
+			}
+			if ft.PreserveXattrs {
+				preserve = append(preserve, "xattrs")
+			}
+			if len(preserve) > 0 {
+				w.Write([]byte("\t--preserve=" + strings.Join(preserve, ",")))
+			}
 			w.Write([]byte("\n"))
 		}
 		w.Write([]byte("\n"))
@@ -398,10 +516,24 @@ func WriteDefinitionFile(d *types.Definition, w io.Writer) {
 	writeSectionIfExists(w, "environment", d.ImageData.Environment)
 	writeSectionIfExists(w, "runscript", d.ImageData.Runscript)
 	writeSectionIfExists(w, "test", d.ImageData.Test)
+	for _, t := range d.BuildData.Tests {
+		writeSectionIfExists(w, "test "+t.Name, t.Script)
+	}
 	writeSectionIfExists(w, "startscript", d.ImageData.Startscript)
 	writeSectionIfExists(w, "pre", d.BuildData.Pre)
 	writeSectionIfExists(w, "setup", d.BuildData.Setup)
 	writeSectionIfExists(w, "post", d.BuildData.Post)
+	writeSectionIfExists(w, "pre-assemble", d.BuildData.PreAssemble)
+	writeSectionIfExists(w, "post-assemble", d.BuildData.PostAssemble)
+
+	custom := make([]string, 0, len(d.BuildData.Sections))
+	for k := range d.BuildData.Sections {
+		custom = append(custom, k)
+	}
+	sort.Strings(custom)
+	for _, k := range custom {
+		writeSectionIfExists(w, k, d.BuildData.Sections[k])
+	}
 }
 
 // IsValidDefinition returns whether or not the given file is a valid definition
@@ -419,7 +551,12 @@ func IsValidDefinition(source string) (valid bool, err error) {
 
 	defer defFile.Close()
 
-	_, err = ParseDefinitionFile(defFile)
+	data, err := ReadDefinitionFile(source)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = ParseDefinitionFile(bytes.NewReader(data))
 	if err != nil {
 		return false, err
 	}
@@ -440,6 +577,11 @@ var validSections = map[string]bool{
 	"runscript":   true,
 	"test":        true,
 	"startscript": true,
+	"arguments":   true,
+
+	"pre-assemble":  true,
+	"post-assemble": true,
+	"include":       true,
 }
 
 // validHeaders just contains a list of all the valid headers a definition file
@@ -452,6 +594,10 @@ var validHeaders = map[string]bool{
 	"updateurl":  true,
 	"osversion":  true,
 	"include":    true,
+	"gpgkey":     true,
+	"siglevel":   true,
+	"packages":   true,
+	"pacmanconf": true,
 	"library":    true,
 	"registry":   true,
 	"namespace":  true,
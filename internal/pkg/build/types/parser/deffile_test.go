@@ -7,13 +7,16 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/syplugin"
 	"github.com/sylabs/singularity/internal/pkg/test"
 )
 
@@ -145,3 +148,144 @@ func TestParseDefinitionFileFailure(t *testing.T) {
 		}))
 	}
 }
+
+type testSectionPlugin struct{}
+
+func (testSectionPlugin) Name() string {
+	return "test_sections"
+}
+
+func (testSectionPlugin) Sections() []string {
+	return []string{"spack", "conda"}
+}
+
+func (testSectionPlugin) HandleBuildSection(section string, body string, b *types.Bundle) error {
+	return nil
+}
+
+func TestParseCustomSections(t *testing.T) {
+	if err := syplugin.RegisterSectionPlugin(testSectionPlugin{}); err != nil {
+		t.Fatalf("failed to register plugin: %v", err)
+	}
+
+	def := `bootstrap: docker
+from: ubuntu
+
+%post
+    apt-get install -y python
+
+%spack
+    spack install zlib
+
+%unknown
+    ignored
+`
+	d, err := ParseDefinitionFile(strings.NewReader(def))
+	if err != nil {
+		t.Fatalf("failed to parse definition file: %v", err)
+	}
+
+	expected := map[string]string{"spack": "    spack install zlib"}
+	if !reflect.DeepEqual(d.BuildData.Sections, expected) {
+		t.Errorf("unexpected custom sections %v, expected %v", d.BuildData.Sections, expected)
+	}
+	if d.BuildData.Post != "    apt-get install -y python" {
+		t.Errorf("unexpected %%post section %q", d.BuildData.Post)
+	}
+
+	var buf bytes.Buffer
+	WriteDefinitionFile(&d, &buf)
+	written, err := ParseDefinitionFile(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse written definition file: %v", err)
+	}
+	if !reflect.DeepEqual(written.BuildData.Sections, expected) {
+		t.Errorf("custom sections not written back: %v", written.BuildData.Sections)
+	}
+}
+
+func TestParseFilesOptions(t *testing.T) {
+	def := `bootstrap: docker
+from: ubuntu
+
+%files
+    /opt/app /opt/my app
+    src/** /src !**/*.o !build --preserve=owner,xattrs
+    /etc/hosts	/etc/hosts.orig
+`
+	d, err := ParseDefinitionFile(strings.NewReader(def))
+	if err != nil {
+		t.Fatalf("failed to parse definition file: %v", err)
+	}
+
+	expected := []types.FileTransport{
+		{Src: "/opt/app", Dst: "/opt/my app"},
+		{Src: "src/**", Dst: "/src", Exclude: []string{"**/*.o", "build"}, PreserveOwner: true, PreserveXattrs: true},
+		{Src: "/etc/hosts", Dst: "/etc/hosts.orig"},
+	}
+	if !reflect.DeepEqual(d.BuildData.Files, expected) {
+		t.Errorf("unexpected files %v, expected %v", d.BuildData.Files, expected)
+	}
+
+	var buf bytes.Buffer
+	WriteDefinitionFile(&d, &buf)
+	written, err := ParseDefinitionFile(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse written definition file: %v", err)
+	}
+	if !reflect.DeepEqual(written.BuildData.Files, expected) {
+		t.Errorf("files not written back: %v", written.BuildData.Files)
+	}
+
+	bad := "bootstrap: docker\n%files\n    src /src --preserve=mode\n"
+	if _, err := ParseDefinitionFile(strings.NewReader(bad)); err == nil {
+		t.Errorf("unexpected success with an invalid preserve flag")
+	}
+}
+
+func TestParseNamedTests(t *testing.T) {
+	def := `bootstrap: docker
+from: ubuntu
+
+%test
+    /bin/true
+
+%test smoke
+    app --version
+
+%test gpu
+    nvidia-smi
+`
+	d, err := ParseDefinitionFile(strings.NewReader(def))
+	if err != nil {
+		t.Fatalf("failed to parse definition file: %v", err)
+	}
+
+	expected := []types.TestScript{
+		{Name: types.DefaultTestName, Script: "    /bin/true"},
+		{Name: "smoke", Script: "    app --version"},
+		{Name: "gpu", Script: "    nvidia-smi"},
+	}
+	if tests := d.BuildData.BuildTests(); !reflect.DeepEqual(tests, expected) {
+		t.Errorf("unexpected tests %v, expected %v", tests, expected)
+	}
+
+	var buf bytes.Buffer
+	WriteDefinitionFile(&d, &buf)
+	written, err := ParseDefinitionFile(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse written definition file: %v", err)
+	}
+	if tests := written.BuildData.BuildTests(); !reflect.DeepEqual(tests, expected) {
+		t.Errorf("tests not written back: %v", tests)
+	}
+
+	for _, bad := range []string{
+		"bootstrap: docker\n%test smoke\n    true\n%test smoke\n    false\n",
+		"bootstrap: docker\n%test -smoke\n    true\n",
+	} {
+		if _, err := ParseDefinitionFile(strings.NewReader(bad)); err == nil {
+			t.Errorf("unexpected success parsing %q", bad)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// appendedSections are the sections of included definition files which are
+// extended, rather than replaced, by the sections of the including file
+var appendedSections = map[string]bool{
+	"files":       true,
+	"labels":      true,
+	"environment": true,
+	"arguments":   true,
+}
+
+// defSection is a section of a definition file, ident is the section line
+// without the % prefix, e.g. "files from builder"
+type defSection struct {
+	name  string
+	ident string
+	lines []string
+}
+
+// defParts holds the header lines and the sections of a definition file in
+// order of appearance
+type defParts struct {
+	header   []string
+	sections []*defSection
+}
+
+// ReadDefinitionFile returns the content of the definition file at path with
+// its %include sections expanded. A "%include base.def" section merges the
+// definition file base.def, relative to the including file, at its place:
+// the header keywords and the sections of the including file override the
+// included ones, except %files, %labels, %environment and %arguments which
+// are appended to.
+func ReadDefinitionFile(path string) ([]byte, error) {
+	p, err := readDefinitionParts(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.bytes(), nil
+}
+
+// readDefinitionParts reads the definition file at path and expands its
+// %include sections, stack holds the files including it to detect cycles
+func readDefinitionParts(path string, stack []string) (*defParts, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("%s is included recursively", path)
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	own := splitDefinition(data)
+
+	merged := &defParts{}
+	for _, s := range own.sections {
+		if s.name != "include" {
+			merged.mergeSection(s)
+			continue
+		}
+
+		included := strings.TrimSpace(strings.TrimPrefix(s.ident, strings.Fields(s.ident)[0]))
+		if included == "" {
+			return nil, fmt.Errorf("%s: %%include requires a definition file", path)
+		}
+		if !filepath.IsAbs(included) {
+			included = filepath.Join(filepath.Dir(abs), included)
+		}
+		p, err := readDefinitionParts(included, stack)
+		if err != nil {
+			return nil, fmt.Errorf("while including %s: %v", included, err)
+		}
+		merged.mergeHeader(p.header)
+		for _, s := range p.sections {
+			merged.mergeSection(s)
+		}
+	}
+	merged.mergeHeader(own.header)
+
+	return merged, nil
+}
+
+// splitDefinition splits a definition file into its header lines and its
+// sections
+func splitDefinition(data []byte) *defParts {
+	p := &defParts{}
+
+	var current *defSection
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && trimmed[0] == '%' {
+			ident := strings.TrimLeft(trimmed, "%")
+			current = &defSection{name: getSectionName(ident), ident: ident}
+			p.sections = append(p.sections, current)
+			continue
+		}
+		if current == nil {
+			p.header = append(p.header, line)
+		} else {
+			current.lines = append(current.lines, line)
+		}
+	}
+	return p
+}
+
+// headerKey returns the lower case keyword of a header line, or an empty
+// string for comments and blank lines
+func headerKey(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+}
+
+// mergeHeader adds the header lines to p, replacing the lines of p with the
+// same keywords
+func (p *defParts) mergeHeader(header []string) {
+	keys := make(map[string]bool)
+	for _, line := range header {
+		keys[headerKey(line)] = true
+	}
+
+	var lines []string
+	for _, line := range p.header {
+		if k := headerKey(line); k == "" || !keys[k] {
+			lines = append(lines, line)
+		}
+	}
+	p.header = append(lines, header...)
+}
+
+// key returns the key identifying s among the sections of a definition
+// file: its name, followed by the test name for named %test blocks
+func (s *defSection) key() string {
+	if name := testName(s.ident); name != "" {
+		return s.name + " " + name
+	}
+	return s.name
+}
+
+// mergeSection adds s to p, replacing or appending to the section of p with
+// the same key
+func (p *defParts) mergeSection(s *defSection) {
+	for i, existing := range p.sections {
+		if existing.key() != s.key() {
+			continue
+		}
+		if appendedSections[s.name] {
+			lines := append([]string{}, existing.lines...)
+			p.sections[i] = &defSection{
+				name:  existing.name,
+				ident: existing.ident,
+				lines: append(lines, s.lines...),
+			}
+		} else {
+			p.sections[i] = s
+		}
+		return
+	}
+	p.sections = append(p.sections, s)
+}
+
+// bytes returns the definition file made of the header and sections of p
+func (p *defParts) bytes() []byte {
+	var buf bytes.Buffer
+	for _, line := range p.header {
+		buf.WriteString(line + "\n")
+	}
+	for _, s := range p.sections {
+		buf.WriteString("%" + s.ident + "\n")
+		for _, line := range s.lines {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+func TestReadDefinitionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	write("base/base.def", `Bootstrap: docker
+From: ubuntu:18.04
+
+%files
+    /etc/hosts /opt/hosts
+
+%labels
+    Maintainer site
+
+%post
+    apt-get update
+
+%runscript
+    exec /bin/bash
+
+%test smoke
+    /bin/true
+
+%test unit
+    run-tests
+`)
+	path := write("app.def", `From: ubuntu:16.04
+
+%include base/base.def
+
+%labels
+    Application app
+
+%post
+    apt-get install -y app
+
+%test smoke
+    app --version
+`)
+
+	data, err := ReadDefinitionFile(path)
+	if err != nil {
+		t.Fatalf("failed to read definition: %v", err)
+	}
+	d, err := ParseDefinitionFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse definition: %v\n%s", err, data)
+	}
+
+	if d.Header["bootstrap"] != "docker" || d.Header["from"] != "ubuntu:16.04" {
+		t.Errorf("unexpected header %v", d.Header)
+	}
+	if expected := map[string]string{"Maintainer": "site", "Application": "app"}; !reflect.DeepEqual(d.Labels, expected) {
+		t.Errorf("unexpected labels %v, expected %v", d.Labels, expected)
+	}
+	if expected := []types.FileTransport{{Src: "/etc/hosts", Dst: "/opt/hosts"}}; !reflect.DeepEqual(d.BuildData.Files, expected) {
+		t.Errorf("unexpected files %v, expected %v", d.BuildData.Files, expected)
+	}
+	if expected := "    apt-get install -y app"; d.BuildData.Post != expected {
+		t.Errorf("unexpected %%post %q, expected %q", d.BuildData.Post, expected)
+	}
+	if expected := "    exec /bin/bash"; d.ImageData.Runscript != expected {
+		t.Errorf("unexpected %%runscript %q, expected %q", d.ImageData.Runscript, expected)
+	}
+	expectedTests := []types.TestScript{
+		{Name: "smoke", Script: "    app --version"},
+		{Name: "unit", Script: "    run-tests"},
+	}
+	if !reflect.DeepEqual(d.BuildData.Tests, expectedTests) {
+		t.Errorf("unexpected tests %v, expected %v", d.BuildData.Tests, expectedTests)
+	}
+
+	write("loop.def", "Bootstrap: docker\n%include loop.def\n")
+	if _, err := ReadDefinitionFile(filepath.Join(dir, "loop.def")); err == nil {
+		t.Errorf("unexpected success with a recursive include")
+	}
+	write("missing.def", "Bootstrap: docker\n%include missing/base.def\n")
+	if _, err := ReadDefinitionFile(filepath.Join(dir, "missing.def")); err == nil {
+		t.Errorf("unexpected success with a missing include")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+// LocateDefinitionFile returns the line numbers of the header keywords and
+// sections of a definition file, sections are listed in order of appearance
+// including the ones which are not handled by the parser
+func LocateDefinitionFile(r io.Reader) (*types.Locations, error) {
+	l := &types.Locations{
+		Header: make(map[string]int),
+	}
+
+	s := bufio.NewScanner(r)
+	inSections := false
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		if line[0] == '%' {
+			inSections = true
+			section := types.SectionLocation{Line: n}
+			if fields := strings.Fields(strings.TrimLeft(line, "%")); len(fields) > 0 {
+				section.Name = strings.ToLower(fields[0])
+				section.Args = strings.Join(fields[1:], " ")
+			}
+			l.Sections = append(l.Sections, section)
+			continue
+		}
+
+		if inSections || line[0] == '#' {
+			continue
+		}
+		if split := strings.SplitN(line, ":", 2); len(split) == 2 {
+			key := strings.ToLower(strings.TrimSpace(split[0]))
+			if _, ok := l.Header[key]; !ok {
+				l.Header[key] = n
+			}
+		}
+	}
+
+	return l, s.Err()
+}
+
+// IsValidSection returns whether a section is handled by the parser or a
+// build plugin
+func IsValidSection(name string) bool {
+	return isValidSection(name)
+}
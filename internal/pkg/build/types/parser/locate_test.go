@@ -0,0 +1,46 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+)
+
+func TestLocateDefinitionFile(t *testing.T) {
+	def := `# comment: not a header
+Bootstrap: docker
+From: alpine
+
+%files from builder
+    /etc/hostname
+
+%POST
+    echo %post
+%unknown`
+
+	l, err := LocateDefinitionFile(strings.NewReader(def))
+	if err != nil {
+		t.Fatalf("failed to locate definition: %v", err)
+	}
+
+	expectedHeader := map[string]int{"bootstrap": 2, "from": 3}
+	if !reflect.DeepEqual(l.Header, expectedHeader) {
+		t.Errorf("unexpected header locations %v, expected %v", l.Header, expectedHeader)
+	}
+
+	expectedSections := []types.SectionLocation{
+		{Name: "files", Args: "from builder", Line: 5},
+		{Name: "post", Line: 8},
+		{Name: "unknown", Line: 10},
+	}
+	if !reflect.DeepEqual(l.Sections, expectedSections) {
+		t.Errorf("unexpected section locations %v, expected %v", l.Sections, expectedSections)
+	}
+}
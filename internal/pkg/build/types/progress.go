@@ -0,0 +1,51 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package types
+
+import (
+	"time"
+)
+
+// EventType is the type of a build progress event
+type EventType string
+
+const (
+	// EventStageStarted is sent when a build stage starts
+	EventStageStarted EventType = "stage-started"
+	// EventConveyorDone is sent when the bootstrap source is packed in the bundle
+	EventConveyorDone EventType = "conveyor-done"
+	// EventScriptRunning is sent when definition scripts start running
+	EventScriptRunning EventType = "script-running"
+	// EventOutput is sent with the output of a remote build
+	EventOutput EventType = "output"
+	// EventAssembleDone is sent when the image has been assembled
+	EventAssembleDone EventType = "assemble-done"
+	// EventError is sent when the build fails
+	EventError EventType = "error"
+)
+
+// Build stages reported by EventStageStarted events
+const (
+	StagePre       = "pre"
+	StageBootstrap = "bootstrap"
+	StageEngine    = "engine"
+	StageTest      = "test"
+	StageMetadata  = "metadata"
+	StageScan      = "scan"
+	StageAssemble  = "assemble"
+	StageRemote    = "remote"
+
+	StagePreAssemble  = "pre-assemble"
+	StagePostAssemble = "post-assemble"
+)
+
+// Event describes the progress of a build
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Stage   string    `json:"stage,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package types
+
+import (
+	"time"
+)
+
+const (
+	// ScanReportObject is the name of the SIF data object holding the
+	// vulnerability scan report
+	ScanReportObject = "scan-report.json"
+)
+
+// Vulnerability severities, in increasing order
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Severities lists the vulnerability severities in increasing order
+var Severities = []string{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// Package is a package installed in the container
+type Package struct {
+	// Name is the name of the package
+	Name string `json:"name"`
+	// Version is the installed version of the package
+	Version string `json:"version"`
+	// Ecosystem is the package manager of the package (deb, apk, rpm)
+	Ecosystem string `json:"ecosystem"`
+}
+
+// Vulnerability is a known vulnerability of a package of the container
+type Vulnerability struct {
+	// ID is the identifier of the vulnerability, e.g. CVE-2018-1000001
+	ID string `json:"id"`
+	// Package is the name of the vulnerable package
+	Package string `json:"package"`
+	// Version is the installed version of the vulnerable package
+	Version string `json:"version"`
+	// Severity is one of Severities
+	Severity string `json:"severity"`
+	// Description is a short description of the vulnerability
+	Description string `json:"description,omitempty"`
+}
+
+// ScanReport records the result of the vulnerability scan run at build time
+type ScanReport struct {
+	// Scanner is the name of the scanner used
+	Scanner string `json:"scanner"`
+	// Started is the time at which the scan was started
+	Started time.Time `json:"started"`
+	// Packages is the number of packages scanned
+	Packages int `json:"packages"`
+	// Vulnerabilities are the vulnerabilities found
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
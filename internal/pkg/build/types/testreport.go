@@ -0,0 +1,129 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package types
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// TestReportPath is the path of the test report inside the container
+	TestReportPath = "/.singularity.d/test-report.json"
+	// TestReportObject is the name of the SIF data object holding the test report
+	TestReportObject = "test-report.json"
+	// TestReportOutputSize is the maximum size of the output excerpt kept in
+	// the test report
+	TestReportOutputSize = 4096
+	// DefaultTestName is the name of the unnamed %test section in test
+	// reports
+	DefaultTestName = "test"
+)
+
+// TestResult records the result of a %test block run at build time
+type TestResult struct {
+	// Name is the name of the test block, DefaultTestName for the unnamed
+	// %test section
+	Name string `json:"name"`
+	// Command is the test script executed
+	Command string `json:"command"`
+	// ExitCode is the exit code of the test script
+	ExitCode int `json:"exitCode"`
+	// Passed indicates whether the test script succeeded
+	Passed bool `json:"passed"`
+	// Started is the time at which the test script was started
+	Started time.Time `json:"started"`
+	// Duration is the test script execution time in seconds
+	Duration float64 `json:"duration"`
+	// Output is an excerpt of the last lines of the test script output
+	Output string `json:"output"`
+}
+
+// TestReport records the results of the %test blocks run at build time
+type TestReport struct {
+	// Passed indicates whether all the tests succeeded
+	Passed bool `json:"passed"`
+	// Started is the time at which the first test was started
+	Started time.Time `json:"started"`
+	// Duration is the execution time of all the tests in seconds
+	Duration float64 `json:"duration"`
+	// Tests are the results of the tests in order of execution
+	Tests []TestResult `json:"tests"`
+}
+
+// Failed returns the names of the failed tests
+func (r TestReport) Failed() (names []string) {
+	for _, t := range r.Tests {
+		if !t.Passed {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite named name, the
+// output excerpt of failed tests is kept in their failure element
+func (r TestReport) WriteJUnit(w io.Writer, name string) error {
+	suite := junitTestSuite{
+		Name:      name,
+		Tests:     len(r.Tests),
+		Failures:  len(r.Failed()),
+		Time:      fmt.Sprintf("%.3f", r.Duration),
+		Timestamp: r.Started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, t := range r.Tests {
+		c := junitTestCase{
+			Name:      t.Name,
+			Classname: name,
+			Time:      fmt.Sprintf("%.3f", t.Duration),
+		}
+		if t.Passed {
+			c.SystemOut = t.Output
+		} else {
+			c.Failure = &junitFailure{
+				Message: fmt.Sprintf("exit code %d", t.ExitCode),
+				Output:  t.Output,
+			}
+		}
+		suite.TestCases = append(suite.TestCases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package types
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const (
+	// TimingsPath is the path of the file, inside the container, in which
+	// the build engine records the timings of the definition scripts
+	TimingsPath = "/.singularity.d/build-timings.json"
+	// TimingReportObject is the name of the SIF data object holding the
+	// timing report
+	TimingReportObject = "timing-report.json"
+)
+
+// PhaseTiming is the duration of a phase of a build
+type PhaseTiming struct {
+	// Phase is the name of the phase, e.g. conveyor-get or post
+	Phase string `json:"phase"`
+	// Started is the time at which the phase was started
+	Started time.Time `json:"started"`
+	// Duration is the phase duration in seconds
+	Duration float64 `json:"duration"`
+}
+
+// TimingReport records the durations of the phases of a build
+type TimingReport struct {
+	// Phases are the build phases in order of execution
+	Phases []PhaseTiming `json:"phases"`
+	// Total is the build duration in seconds
+	Total float64 `json:"total"`
+}
+
+// NewPhaseTiming returns the timing of phase, started at start and ending
+// now
+func NewPhaseTiming(phase string, start time.Time) PhaseTiming {
+	return PhaseTiming{
+		Phase:    phase,
+		Started:  start,
+		Duration: time.Since(start).Seconds(),
+	}
+}
+
+// NewTimingReport returns the report of phases, with the sum of their
+// durations as total
+func NewTimingReport(phases []PhaseTiming) TimingReport {
+	r := TimingReport{Phases: phases}
+	for _, p := range phases {
+		r.Total += p.Duration
+	}
+	return r
+}
+
+// RecordPhaseTiming appends the timing of phase, started at start, to the
+// timings file at path as a JSON line
+func RecordPhaseTiming(path, phase string, start time.Time) error {
+	data, err := json.Marshal(NewPhaseTiming(phase, start))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// ReadPhaseTimings returns the timings recorded in the timings file at path
+func ReadPhaseTimings(path string) ([]PhaseTiming, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var timings []PhaseTiming
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var t PhaseTiming
+		if err := json.Unmarshal(s.Bytes(), &t); err != nil {
+			return nil, err
+		}
+		timings = append(timings, t)
+	}
+	return timings, s.Err()
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
+)
+
+// Severity is the severity of a definition diagnostic
+type Severity string
+
+const (
+	// SeverityError is used for problems failing the build
+	SeverityError Severity = "error"
+	// SeverityWarning is used for problems which may fail the build
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a problem found in a definition. Definition is the
+// index of the definition in the list passed to Validate, and Line is 0 when
+// the location is unknown.
+type Diagnostic struct {
+	Definition int      `json:"definition"`
+	Line       int      `json:"line,omitempty"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+}
+
+// fromRequired lists the bootstrap agents which need a From header
+var fromRequired = map[string]bool{
+	"library":        true,
+	"shub":           true,
+	"docker":         true,
+	"docker-archive": true,
+	"docker-daemon":  true,
+	"oci":            true,
+	"oci-archive":    true,
+	"localimage":     true,
+}
+
+// urlHeaders lists the header keywords holding URLs
+var urlHeaders = []string{"mirrorurl", "updateurl"}
+
+// urlTimeout is the time allowed to reach the URLs of a definition
+var urlTimeout = 10 * time.Second
+
+// Validate checks definitions without running anything. The optional
+// locations, as returned by parser.LocateDefinitionFile, give the line
+// numbers of the diagnostics of the definition at the same index and allow
+// to detect sections ignored by the parser.
+func Validate(defs []types.Definition, locations ...*types.Locations) (diags []Diagnostic) {
+	for i, d := range defs {
+		l := &types.Locations{}
+		if i < len(locations) && locations[i] != nil {
+			l = locations[i]
+		}
+
+		report := func(line int, severity Severity, format string, a ...interface{}) {
+			diags = append(diags, Diagnostic{
+				Definition: i,
+				Line:       line,
+				Severity:   severity,
+				Message:    fmt.Sprintf(format, a...),
+			})
+		}
+
+		bootstrap := d.Header["bootstrap"]
+		if bootstrap == "" {
+			report(0, SeverityError, "no bootstrap specification found")
+		} else if !sources.IsRegistered(bootstrap) {
+			report(l.Header["bootstrap"], SeverityError, "invalid build source %s", bootstrap)
+		} else if fromRequired[bootstrap] && d.Header["from"] == "" {
+			report(l.Header["bootstrap"], SeverityError, "bootstrap agent %s requires a From header", bootstrap)
+		}
+
+		for _, key := range urlHeaders {
+			if u := d.Header[key]; u != "" {
+				if err := checkURL(u, d.Header); err != nil {
+					report(l.Header[key], SeverityWarning, "%s %s: %v", key, u, err)
+				}
+			}
+		}
+
+		filesLine := 0
+		seen := make(map[string]bool)
+		for _, s := range l.Sections {
+			if !parser.IsValidSection(s.Name) {
+				report(s.Line, SeverityError, "unknown section %%%s", s.Name)
+				continue
+			}
+			key := s.Name
+			if s.Name == "test" && s.Args != "" {
+				key += " " + s.Args
+			}
+			if seen[key] && s.Name != "include" {
+				report(s.Line, SeverityWarning, "section %%%s is defined more than once, only one is used", key)
+			}
+			seen[key] = true
+
+			if s.Name != "files" {
+				continue
+			}
+			filesLine = s.Line
+			if args := strings.Fields(s.Args); len(args) > 0 && args[0] == "from" {
+				stage := strings.Join(args[1:], " ")
+				report(s.Line, SeverityError, "%%files from %s: no stage named %s, multi-stage builds are not supported", stage, stage)
+			}
+		}
+
+		for _, f := range d.BuildData.Files {
+			if matches, err := files.Glob(f.Src); err != nil || len(matches) == 0 {
+				report(filesLine, SeverityError, "%%files source %s not found", f.Src)
+			}
+		}
+	}
+
+	return diags
+}
+
+// checkURL returns an error if the URL u of a header is invalid or can't be
+// reached. URLs with variables substituted by the bootstrap agent, other than
+// %{OSVERSION}, are only parsed.
+func checkURL(u string, header map[string]string) error {
+	u = strings.Replace(u, "%{OSVERSION}", header["osversion"], -1)
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil
+	}
+	if strings.ContainsAny(u, "$%") {
+		return nil
+	}
+
+	client := &http.Client{Timeout: urlTimeout}
+	resp, err := client.Head(u)
+	if err != nil {
+		return fmt.Errorf("unreachable: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("unreachable: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/build/types/parser"
+)
+
+func TestValidate(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		def      string
+		expected []Diagnostic
+	}{
+		{
+			name: "Valid",
+			def: `Bootstrap: busybox
+MirrorURL: ` + s.URL + `/mirror
+
+%files
+    validate.go /opt
+%post
+    true`,
+		},
+		{
+			name: "NoBootstrap",
+			def: `%post
+    true`,
+			expected: []Diagnostic{
+				{Severity: SeverityError, Message: "no bootstrap specification found"},
+			},
+		},
+		{
+			name: "UnknownBootstrap",
+			def: `
+Bootstrap: nosuch`,
+			expected: []Diagnostic{
+				{Line: 2, Severity: SeverityError, Message: "invalid build source nosuch"},
+			},
+		},
+		{
+			name: "MissingFrom",
+			def:  `Bootstrap: docker`,
+			expected: []Diagnostic{
+				{Line: 1, Severity: SeverityError, Message: "bootstrap agent docker requires a From header"},
+			},
+		},
+		{
+			name: "UnreachableURL",
+			def: `Bootstrap: busybox
+MirrorURL: ` + s.URL + `/missing`,
+			expected: []Diagnostic{
+				{Line: 2, Severity: SeverityWarning, Message: "mirrorurl " + s.URL + "/missing: unreachable: 404 Not Found"},
+			},
+		},
+		{
+			name: "Sections",
+			def: `Bootstrap: docker
+From: alpine
+%files from builder
+    nonexistent /opt
+%post
+    true
+%unknown
+%post
+    true`,
+			expected: []Diagnostic{
+				{Line: 3, Severity: SeverityError, Message: "%files from builder: no stage named builder, multi-stage builds are not supported"},
+				{Line: 7, Severity: SeverityError, Message: "unknown section %unknown"},
+				{Line: 8, Severity: SeverityWarning, Message: "section %post is defined more than once, only one is used"},
+				{Line: 3, Severity: SeverityError, Message: "%files source nonexistent not found"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := parser.ParseDefinitionFile(strings.NewReader(tt.def))
+			if err != nil {
+				t.Fatalf("failed to parse definition: %v", err)
+			}
+			l, err := parser.LocateDefinitionFile(strings.NewReader(tt.def))
+			if err != nil {
+				t.Fatalf("failed to locate definition: %v", err)
+			}
+
+			diags := Validate([]types.Definition{d}, l)
+			if !reflect.DeepEqual(diags, tt.expected) {
+				t.Errorf("unexpected diagnostics %v, expected %v", diags, tt.expected)
+			}
+		})
+	}
+}
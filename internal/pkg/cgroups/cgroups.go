@@ -78,6 +78,22 @@ func (m *Manager) ApplyFromFile(path string) error {
 	return m.ApplyFromSpec(&spec)
 }
 
+// Join adds the managed process to the existing cgroup Name created by
+// ApplyFromSpec for another process, typically an instance
+func (m *Manager) Join() (err error) {
+	m.parentCgroup, err = cgroups.Load(cgroups.V1, cgroups.StaticPath(singularity))
+	if err != nil {
+		return err
+	}
+
+	m.childCgroup, err = cgroups.Load(cgroups.V1, cgroups.StaticPath(singularity+"/"+m.Name))
+	if err != nil {
+		return err
+	}
+
+	return m.childCgroup.Add(cgroups.Process{Pid: m.Pid})
+}
+
 // Remove removes ressources restriction for current managed process
 func (m *Manager) Remove() error {
 	// removes process from singularity root tasks
@@ -0,0 +1,42 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// BuildDir is the directory inside the cache.Dir where the root
+	// filesystems of builds are cached after running %post
+	BuildDir = "build"
+)
+
+// Build returns the directory inside the cache.Dir() where the root
+// filesystems of builds are cached
+func Build() string {
+	return updateCacheSubdir(BuildDir)
+}
+
+// BuildLayer returns the path of the cached root filesystem archive of the
+// build with the key sum
+func BuildLayer(sum string) string {
+	return filepath.Join(Build(), sum+".tar")
+}
+
+// BuildLayerExists returns whether the root filesystem of the build with the
+// key sum exists in the build cache
+func BuildLayerExists(sum string) (bool, error) {
+	_, err := os.Stat(BuildLayer(sum))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+const (
+	// lockSuffix is the suffix of the lock file of a cache entry
+	lockSuffix = ".lock"
+	// tmpPrefix is the prefix of the temporary files of entries being fetched
+	tmpPrefix = ".tmp-"
+)
+
+// EntryLock is an exclusive lock on a cache entry. It is an fcntl record
+// lock so that it also works for caches shared over NFS, it is released
+// by the kernel if the process dies. As any record lock it only excludes
+// other processes.
+type EntryLock struct {
+	f *os.File
+}
+
+// lockFile returns the path of the lock file of the entry at path
+func lockFile(path string) string {
+	return filepath.Clean(path) + lockSuffix
+}
+
+// Lock takes the lock of the cache entry at path, waiting for other
+// processes holding it
+func Lock(path string) (*EntryLock, error) {
+	l, err := TryLock(path)
+	if err != nil || l != nil {
+		return l, err
+	}
+
+	sylog.Infof("Waiting for another process to release %s", path)
+	f, err := os.OpenFile(lockFile(path), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file: %v", err)
+	}
+	if err := fcntlLock(f, syscall.F_SETLKW); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not lock %s: %v", path, err)
+	}
+	return &EntryLock{f: f}, nil
+}
+
+// TryLock takes the lock of the cache entry at path, a nil lock is
+// returned if it is held by another process
+func TryLock(path string) (*EntryLock, error) {
+	f, err := os.OpenFile(lockFile(path), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file: %v", err)
+	}
+	if err := fcntlLock(f, syscall.F_SETLK); err != nil {
+		f.Close()
+		if err == syscall.EAGAIN || err == syscall.EACCES {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not lock %s: %v", path, err)
+	}
+	return &EntryLock{f: f}, nil
+}
+
+// Unlock releases the lock, the lock file is kept as removing it would
+// race with processes waiting for it
+func (l *EntryLock) Unlock() error {
+	return l.f.Close()
+}
+
+func fcntlLock(f *os.File, cmd int) error {
+	lk := syscall.Flock_t{
+		Type:   syscall.F_WRLCK,
+		Whence: 0,
+	}
+	for {
+		err := syscall.FcntlFlock(f.Fd(), cmd, &lk)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// Fetch creates the cache entry at path with fetch if it doesn't exist.
+// Concurrent fetches of the same entry are serialized so that it is
+// downloaded once, fetch writes to the temporary path it receives which is
+// renamed in place on success so that a partial entry is never visible.
+func Fetch(path string, fetch func(tmp string) error) error {
+	l, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		sylog.Debugf("Cache entry %s already exists", path)
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf("%s%d-%s", tmpPrefix, os.Getpid(), filepath.Base(path)))
+	os.RemoveAll(tmp)
+
+	if err := fetch(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("could not move %s in place: %v", tmp, err)
+	}
+	return nil
+}
+
+// IsTemporary returns whether name is the name of a lock file or of a
+// temporary file of an entry being fetched
+func IsTemporary(name string) bool {
+	return strings.HasPrefix(name, tmpPrefix) || strings.HasSuffix(name, lockSuffix)
+}
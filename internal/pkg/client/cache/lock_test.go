@@ -0,0 +1,125 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-fetch-")
+	if err != nil {
+		t.Fatalf("Failed to make temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "image.sif")
+	calls := 0
+	fetch := func(tmp string) error {
+		calls++
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Entry visible before fetch completion")
+		}
+		return ioutil.WriteFile(tmp, []byte("image"), 0644)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Fetch(path, fetch); err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Entry fetched %d times", calls)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "image" {
+		t.Errorf("Unexpected entry content %q: %v", b, err)
+	}
+
+	failed := filepath.Join(dir, "failed.sif")
+	err = Fetch(failed, func(tmp string) error {
+		ioutil.WriteFile(tmp, []byte("partial"), 0644)
+		return fmt.Errorf("download failed")
+	})
+	if err == nil {
+		t.Fatalf("Unexpected success of failed fetch")
+	}
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		switch f.Name() {
+		case "image.sif", "image.sif.lock", "failed.sif.lock":
+		default:
+			t.Errorf("Unexpected file %s left by failed fetch", f.Name())
+		}
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-lock-")
+	if err != nil {
+		t.Fatalf("Failed to make temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := TryLock(filepath.Join(dir, "entry"))
+	if err != nil || l == nil {
+		t.Fatalf("Failed to lock free entry: %v", err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Errorf("Failed to unlock entry: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	defer Clean()
+	defer os.Unsetenv(DirEnv)
+	os.Setenv(DirEnv, cacheCustom)
+
+	sum := "sha256.0000000000000000000000000000000000000000000000000000000000000000"
+	bad := LibraryImage(sum, "bad.sif")
+	if err := ioutil.WriteFile(bad, []byte("not a SIF"), 0644); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	stale := filepath.Join(filepath.Dir(NetImage("hash", "net.sif")), tmpPrefix+"1-net.sif")
+	if err := ioutil.WriteFile(stale, []byte("partial"), 0644); err != nil {
+		t.Fatalf("Failed to create temporary file: %v", err)
+	}
+
+	problems, err := Verify(false)
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("Found %d problems, expected 2: %+v", len(problems), problems)
+	}
+	for _, p := range problems {
+		if p.Repaired {
+			t.Errorf("%s repaired while repair is disabled", p.Path)
+		}
+	}
+
+	problems, err = Verify(true)
+	if err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+	for _, p := range problems {
+		if !p.Repaired {
+			t.Errorf("%s not repaired", p.Path)
+		}
+	}
+	for _, path := range []string{bad, stale} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", path)
+		}
+	}
+
+	if problems, err := Verify(false); err != nil || len(problems) != 0 {
+		t.Errorf("Unexpected problems after repair: %+v (%v)", problems, err)
+	}
+}
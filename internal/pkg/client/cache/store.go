@@ -0,0 +1,18 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+const (
+	// StoreDir is the directory inside the cache.Dir where the default
+	// content-addressed image store is located
+	StoreDir = "store"
+)
+
+// Store returns the directory inside the cache.Dir() where the default
+// content-addressed image store is located
+func Store() string {
+	return updateCacheSubdir(StoreDir)
+}
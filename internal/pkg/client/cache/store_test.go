@@ -0,0 +1,36 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		expected string
+	}{
+		{"Default Store", "", filepath.Join(cacheDefault, "store")},
+		{"Custom Store", cacheCustom, filepath.Join(cacheCustom, "store")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer Clean()
+			defer os.Unsetenv(DirEnv)
+
+			os.Setenv(DirEnv, tt.env)
+
+			if r := Store(); r != tt.expected {
+				t.Errorf("Unexpected result: %s (expected %s)", r, tt.expected)
+			}
+		})
+	}
+}
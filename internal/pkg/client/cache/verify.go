@@ -0,0 +1,198 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+var blobNameRegexp = regexp.MustCompile("^[a-f0-9]{64}$")
+
+// Problem describes a damaged cache entry found by Verify
+type Problem struct {
+	Path     string
+	Reason   string
+	Repaired bool
+}
+
+// Verify checks the image and blob entries of the cache, damaged entries
+// and stale temporary files are removed if repair is true. Entries in use
+// by another process are skipped.
+func Verify(repair bool) ([]Problem, error) {
+	var problems []Problem
+
+	for _, dir := range []string{Library(), OciTemp(), Net(), Shub()} {
+		p, err := verifyImageDir(dir, repair)
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, p...)
+	}
+
+	p, err := verifyOciBlobs(OciBlob(), repair)
+	if err != nil {
+		return problems, err
+	}
+	return append(problems, p...), nil
+}
+
+// verifyImageDir checks the dir/<sum>/<name> image entries of dir
+func verifyImageDir(dir string, repair bool) ([]Problem, error) {
+	var problems []Problem
+
+	sums, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, sum := range sums {
+		if !sum.IsDir() {
+			continue
+		}
+		sumDir := filepath.Join(dir, sum.Name())
+		files, err := ioutil.ReadDir(sumDir)
+		if err != nil {
+			return problems, err
+		}
+
+		for _, f := range files {
+			path := filepath.Join(sumDir, f.Name())
+			if strings.HasSuffix(f.Name(), lockSuffix) {
+				continue
+			}
+
+			var reason string
+			if strings.HasPrefix(f.Name(), tmpPrefix) {
+				reason = "stale temporary file"
+				// the temporary file is named .tmp-<pid>-<name>
+				name := strings.SplitN(strings.TrimPrefix(f.Name(), tmpPrefix), "-", 2)
+				if len(name) == 2 {
+					path = filepath.Join(sumDir, name[1])
+				}
+			}
+
+			l, err := TryLock(path)
+			if err != nil {
+				return problems, err
+			}
+			if l == nil {
+				sylog.Infof("Skipping %s, in use by another process", path)
+				continue
+			}
+
+			if reason == "" {
+				reason = verifyImage(path, sum.Name())
+			}
+			if reason != "" {
+				target := filepath.Join(sumDir, f.Name())
+				problems = append(problems, repairEntry(target, reason, repair))
+			}
+			l.Unlock()
+		}
+	}
+	return problems, nil
+}
+
+// verifyImage returns why the SIF image at path is damaged, sum is the
+// name of its cache directory. An empty string is returned if it is valid.
+func verifyImage(path, sum string) string {
+	if strings.HasPrefix(sum, "sha256.") {
+		h, err := sha256File(path)
+		if err != nil {
+			return err.Error()
+		}
+		if "sha256."+h != sum {
+			return "checksum mismatch"
+		}
+	}
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Sprintf("invalid SIF image: %v", err)
+	}
+	defer fimg.UnloadContainer()
+
+	if strings.HasPrefix(sum, "sif.") && "sif."+fimg.Header.ID.String() != sum {
+		return "SIF image ID mismatch"
+	}
+	return ""
+}
+
+// verifyOciBlobs checks the blobs of the OCI layout at dir against their
+// digest
+func verifyOciBlobs(dir string, repair bool) ([]Problem, error) {
+	var problems []Problem
+
+	l, err := TryLock(dir)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		sylog.Infof("Skipping %s, in use by another process", dir)
+		return nil, nil
+	}
+	defer l.Unlock()
+
+	blobDir := filepath.Join(dir, "blobs", "sha256")
+	blobs, err := ioutil.ReadDir(blobDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, b := range blobs {
+		path := filepath.Join(blobDir, b.Name())
+		if !blobNameRegexp.MatchString(b.Name()) {
+			problems = append(problems, repairEntry(path, "stale temporary file", repair))
+			continue
+		}
+		h, err := sha256File(path)
+		if err != nil {
+			problems = append(problems, repairEntry(path, err.Error(), repair))
+		} else if h != b.Name() {
+			problems = append(problems, repairEntry(path, "checksum mismatch", repair))
+		}
+	}
+	return problems, nil
+}
+
+// repairEntry removes the damaged entry at path if repair is true
+func repairEntry(path, reason string, repair bool) Problem {
+	p := Problem{Path: path, Reason: reason}
+	if repair {
+		if err := os.RemoveAll(path); err != nil {
+			sylog.Warningf("Could not remove %s: %v", path, err)
+		} else {
+			p.Repaired = true
+		}
+	}
+	return p
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
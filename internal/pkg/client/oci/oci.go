@@ -18,6 +18,7 @@ import (
 	"github.com/containers/image/signature"
 	"github.com/containers/image/transports"
 	"github.com/containers/image/types"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sylabs/singularity/internal/pkg/client/cache"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
@@ -28,8 +29,14 @@ type ImageReference struct {
 	types.ImageReference
 }
 
-// ConvertReference converts a source reference into a cache.ImageReference to cache its blobs
-func ConvertReference(src types.ImageReference, sys *types.SystemContext) (types.ImageReference, error) {
+// ConvertReference converts a source reference into a cache.ImageReference to cache its blobs,
+// manifest lists are resolved against platform
+func ConvertReference(src types.ImageReference, sys *types.SystemContext, platform Platform) (types.ImageReference, error) {
+	src, err := ResolvePlatform(src, sys, platform)
+	if err != nil {
+		return nil, err
+	}
+
 	// Our cache dir is an OCI directory. We are using this as a 'blob pool'
 	// storing all incoming containers under unique tags, which are a hash of
 	// their source URI.
@@ -59,9 +66,17 @@ func (t *ImageReference) newImageSource(ctx context.Context, sys *types.SystemCo
 	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
 	policyCtx, err := signature.NewPolicyContext(policy)
 
+	// The cache layout index is rewritten by each fetch, they must not
+	// run concurrently
+	l, err := cache.Lock(cache.OciBlob())
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
 	// First we are fetching into the cache
 	err = copy.Image(context.Background(), policyCtx, t.ImageReference, t.source, &copy.Options{
-		ReportWriter: w,
+		ReportWriter: statsWriter{w},
 		SourceCtx: &types.SystemContext{
 			OCIInsecureSkipTLSVerify:    true,
 			DockerInsecureSkipTLSVerify: true,
@@ -81,7 +96,7 @@ func ParseImageName(uri string, sys *types.SystemContext) (types.ImageReference,
 		return nil, fmt.Errorf("Unable to parse image name %v: %v", uri, err)
 	}
 
-	return ConvertReference(ref, sys)
+	return ConvertReference(ref, sys, DefaultPlatform())
 }
 
 func parseURI(uri string) (types.ImageReference, error) {
@@ -100,6 +115,28 @@ func parseURI(uri string) (types.ImageReference, error) {
 	return transport.ParseReference(split[1])
 }
 
+// ImageConfig returns the configuration of the image at uri, resolved
+// against platform, fetching only its manifest and configuration rather than
+// its layers
+func ImageConfig(ctx context.Context, uri string, sys *types.SystemContext, platform Platform) (*imgspecv1.Image, error) {
+	ref, err := parseURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse image name %v: %v", uri, err)
+	}
+	ref, err = ResolvePlatform(ref, sys, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := ref.NewImage(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	return img.OCIConfig(ctx)
+}
+
 // TempImageExists returns whether or not the uri exists splatted out in the cache.OciTemp() directory
 func TempImageExists(uri string) (bool, string, error) {
 	sum, err := ImageSHA(uri, nil)
@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImageConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-config-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		t.Fatalf("failed to create blobs directory: %v", err)
+	}
+	blob := func(data string) string {
+		digest := fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+		if err := ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", digest), []byte(data), 0644); err != nil {
+			t.Fatalf("failed to write blob: %v", err)
+		}
+		return fmt.Sprintf(`"digest": "sha256:%s", "size": %d`, digest, len(data))
+	}
+
+	// an image without layers, only its configuration is fetched
+	config := blob(`{"architecture": "amd64", "os": "linux", "config": {"Env": ["PATH=/bin"], "Labels": {"maintainer": "site"}}, "rootfs": {"type": "layers", "diff_ids": []}}`)
+	manifest := blob(`{"schemaVersion": 2, "config": {"mediaType": "application/vnd.oci.image.config.v1+json", ` + config + `}, "layers": []}`)
+	index := `{"schemaVersion": 2, "manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", ` + manifest + `, "annotations": {"org.opencontainers.image.ref.name": "latest"}}]}`
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0644); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644); err != nil {
+		t.Fatalf("failed to write layout: %v", err)
+	}
+
+	c, err := ImageConfig(context.Background(), "oci:"+dir+":latest", nil, DefaultPlatform())
+	if err != nil {
+		t.Fatalf("unexpected error getting image configuration: %v", err)
+	}
+	if expected := map[string]string{"maintainer": "site"}; !reflect.DeepEqual(c.Config.Labels, expected) {
+		t.Errorf("unexpected labels %v, expected %v", c.Config.Labels, expected)
+	}
+	if expected := []string{"PATH=/bin"}; !reflect.DeepEqual(c.Config.Env, expected) {
+		t.Errorf("unexpected environment %v, expected %v", c.Config.Env, expected)
+	}
+
+	if _, err := ImageConfig(context.Background(), "oci:"+dir+":missing", nil, DefaultPlatform()); err == nil {
+		t.Errorf("unexpected success with a missing image")
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/containers/image/image"
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// Platform describes the os/architecture/variant an image is built for
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// String returns the os/arch[/variant] representation of p
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// ParsePlatform parses a platform like linux/amd64 or linux/arm/v7, the
+// variant is optional
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+		}
+	}
+
+	p := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// DefaultPlatform returns the platform of the running host
+func DefaultPlatform() Platform {
+	p := Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	if p.Architecture == "arm" {
+		p.Variant = armVariant()
+	}
+	return p
+}
+
+// armVariant returns the ARM variant of the host by reading the CPU
+// architecture from /proc/cpuinfo, an empty string is returned if unknown
+func armVariant() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "CPU architecture" {
+			continue
+		}
+		switch v := strings.TrimSpace(kv[1]); v {
+		case "5", "6", "7", "8":
+			return "v" + v
+		}
+		return ""
+	}
+	return ""
+}
+
+// normalizeVariant returns the variant used for comparison, arm64 images
+// are equally published without variant or with v8
+func normalizeVariant(arch, variant string) string {
+	if arch == "arm64" && variant == "v8" {
+		return ""
+	}
+	return variant
+}
+
+// platformManifest is an entry of a docker manifest list or OCI image index
+type platformManifest struct {
+	Digest   digest.Digest `json:"digest"`
+	Platform *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant,omitempty"`
+	} `json:"platform,omitempty"`
+}
+
+// isManifestList returns whether mt is a docker manifest list or an OCI
+// image index media type
+func isManifestList(mt string) bool {
+	return mt == manifest.DockerV2ListMediaType || mt == imgspecv1.MediaTypeImageIndex
+}
+
+// SelectInstance returns the digest of the manifest matching p in the docker
+// manifest list or OCI image index blob. An entry with the same variant is
+// preferred, an entry without variant is used if none matches exactly. If no
+// entry matches, the error lists the available platforms.
+func SelectInstance(blob []byte, p Platform) (digest.Digest, error) {
+	var list struct {
+		Manifests []platformManifest `json:"manifests"`
+	}
+	if err := json.Unmarshal(blob, &list); err != nil {
+		return "", fmt.Errorf("failed to parse manifest list: %s", err)
+	}
+
+	wanted := normalizeVariant(p.Architecture, p.Variant)
+	var fallback digest.Digest
+	var available []string
+	for _, m := range list.Manifests {
+		if m.Platform == nil {
+			continue
+		}
+		mp := Platform{OS: m.Platform.OS, Architecture: m.Platform.Architecture, Variant: m.Platform.Variant}
+		available = append(available, mp.String())
+
+		if mp.OS != p.OS || mp.Architecture != p.Architecture {
+			continue
+		}
+		variant := normalizeVariant(mp.Architecture, mp.Variant)
+		if variant == wanted {
+			return m.Digest, nil
+		}
+		if variant == "" && fallback == "" {
+			fallback = m.Digest
+		}
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+
+	if len(available) == 0 {
+		return "", fmt.Errorf("no image found for platform %s: manifest list does not describe any platform", p)
+	}
+	return "", fmt.Errorf("no image found for platform %s, available platforms: %s", p, strings.Join(available, ", "))
+}
+
+// instanceReference wraps an image reference to a manifest list so that the
+// selected instance is used as the image manifest
+type instanceReference struct {
+	types.ImageReference
+	instance digest.Digest
+}
+
+// instanceSource is the image source returned by instanceReference
+type instanceSource struct {
+	types.ImageSource
+	instance digest.Digest
+}
+
+// ResolvePlatform returns a reference to the image matching p if ref points
+// to a manifest list or an OCI image index, ref is returned unchanged
+// otherwise
+func ResolvePlatform(ref types.ImageReference, sys *types.SystemContext, p Platform) (types.ImageReference, error) {
+	source, err := ref.NewImageSource(context.TODO(), sys)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	man, mt, err := source.GetManifest(context.TODO(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if mt == "" {
+		mt = manifest.GuessMIMEType(man)
+	}
+	if !isManifestList(mt) {
+		return ref, nil
+	}
+
+	instance, err := SelectInstance(man, p)
+	if err != nil {
+		return nil, err
+	}
+	sylog.Debugf("Selected manifest %s for platform %s", instance, p)
+
+	return &instanceReference{ImageReference: ref, instance: instance}, nil
+}
+
+// NewImageSource returns an image source serving the selected instance
+func (r *instanceReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	src, err := r.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return &instanceSource{ImageSource: src, instance: r.instance}, nil
+}
+
+// NewImage returns the image of the selected instance
+func (r *instanceReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	src, err := r.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return image.FromSource(ctx, sys, src)
+}
+
+// GetManifest returns the manifest of the selected instance when
+// instanceDigest is nil
+func (s *instanceSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	if instanceDigest == nil {
+		instanceDigest = &s.instance
+	}
+	return s.ImageSource.GetManifest(ctx, instanceDigest)
+}
+
+// GetSignatures returns the signatures of the selected instance when
+// instanceDigest is nil
+func (s *instanceSource) GetSignatures(ctx context.Context, instanceDigest *digest.Digest) ([][]byte, error) {
+	if instanceDigest == nil {
+		instanceDigest = &s.instance
+	}
+	return s.ImageSource.GetSignatures(ctx, instanceDigest)
+}
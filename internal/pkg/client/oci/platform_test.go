@@ -0,0 +1,112 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"strings"
+	"testing"
+)
+
+const testManifestList = `{
+	"schemaVersion": 2,
+	"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+	"manifests": [
+		{"digest": "sha256:amd64", "platform": {"architecture": "amd64", "os": "linux"}},
+		{"digest": "sha256:armv6", "platform": {"architecture": "arm", "os": "linux", "variant": "v6"}},
+		{"digest": "sha256:armv7", "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}},
+		{"digest": "sha256:arm64", "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}},
+		{"digest": "sha256:windows", "platform": {"architecture": "amd64", "os": "windows"}}
+	]
+}`
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		platform  string
+		expected  Platform
+		shouldErr bool
+	}{
+		{"os/arch", "linux/amd64", Platform{OS: "linux", Architecture: "amd64"}, false},
+		{"os/arch/variant", "linux/arm/v7", Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{"arch only", "amd64", Platform{}, true},
+		{"empty variant", "linux/arm/", Platform{}, true},
+		{"too many parts", "linux/arm/v7/extra", Platform{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePlatform(tt.platform)
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("unexpected success parsing %q", tt.platform)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing %q: %s", tt.platform, err)
+			}
+			if p != tt.expected {
+				t.Errorf("got %+v, expected %+v", p, tt.expected)
+			}
+			if p.String() != tt.platform {
+				t.Errorf("got string %q, expected %q", p.String(), tt.platform)
+			}
+		})
+	}
+}
+
+func TestSelectInstance(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform Platform
+		expected string
+	}{
+		{"amd64", Platform{OS: "linux", Architecture: "amd64"}, "sha256:amd64"},
+		{"arm v7", Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, "sha256:armv7"},
+		{"arm v6", Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, "sha256:armv6"},
+		{"arm64 without variant", Platform{OS: "linux", Architecture: "arm64"}, "sha256:arm64"},
+		{"arm64 v8", Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, "sha256:arm64"},
+		{"windows", Platform{OS: "windows", Architecture: "amd64"}, "sha256:windows"},
+		{"variant fallback", Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}, "sha256:amd64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := SelectInstance([]byte(testManifestList), tt.platform)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(d) != tt.expected {
+				t.Errorf("got %s, expected %s", d, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSelectInstanceNoMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform Platform
+	}{
+		{"unknown arch", Platform{OS: "linux", Architecture: "ppc64le"}},
+		{"unknown arm variant", Platform{OS: "linux", Architecture: "arm", Variant: "v5"}},
+		{"arm without variant", Platform{OS: "linux", Architecture: "arm"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SelectInstance([]byte(testManifestList), tt.platform)
+			if err == nil {
+				t.Fatalf("unexpected success selecting %s", tt.platform)
+			}
+			for _, p := range []string{"linux/amd64", "linux/arm/v6", "linux/arm/v7", "linux/arm64/v8", "windows/amd64"} {
+				if !strings.Contains(err.Error(), p) {
+					t.Errorf("error %q does not list platform %s", err, p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// CacheStats counts the blobs found in the cache and the blobs fetched
+// while copying images into the cache
+type CacheStats struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+var (
+	statsMutex sync.Mutex
+	stats      CacheStats
+)
+
+// GetCacheStats returns the cache statistics of images copied so far
+func GetCacheStats() CacheStats {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	return stats
+}
+
+// statsWriter counts cache hits and misses from the copy progress lines
+// written by containers/image before passing them to w
+type statsWriter struct {
+	w io.Writer
+}
+
+func (s statsWriter) Write(p []byte) (int, error) {
+	statsMutex.Lock()
+	stats.Hits += bytes.Count(p, []byte("Skipping fetch of repeat blob "))
+	stats.Misses += bytes.Count(p, []byte("Copying blob "))
+	statsMutex.Unlock()
+
+	return s.w.Write(p)
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+package debugreport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/hostcheck"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// maxLogSize is the maximum size kept from each instance log file
+const maxLogSize = 64 * 1024
+
+// squashfsCompressors are the kernel options enabling squashfs decompressors
+var squashfsCompressors = []string{
+	"CONFIG_SQUASHFS_ZLIB",
+	"CONFIG_SQUASHFS_LZ4",
+	"CONFIG_SQUASHFS_LZO",
+	"CONFIG_SQUASHFS_XZ",
+	"CONFIG_SQUASHFS_ZSTD",
+}
+
+// collector adds a section to the report
+type collector struct {
+	name string
+	fn   func(r *Report) error
+}
+
+var collectors = []collector{
+	{"version", collectVersion},
+	{"config", collectConfig},
+	{"kernel", collectKernel},
+	{"loop", collectLoop},
+	{"cgroup", collectCgroup},
+	{"logs", collectLogs},
+}
+
+// Generate writes a debug report to the tarball at path. Sections which
+// can't be collected are listed in the errors.txt file of the report.
+func Generate(path string) error {
+	r, err := Create(path)
+	if err != nil {
+		return err
+	}
+
+	var errs bytes.Buffer
+	for _, c := range collectors {
+		sylog.Debugf("Collecting %s information", c.name)
+		if err := c.fn(r); err != nil {
+			sylog.Verbosef("Failed to collect %s information: %s", c.name, err)
+			fmt.Fprintf(&errs, "%s: %s\n", c.name, err)
+		}
+	}
+	if errs.Len() > 0 {
+		if err := r.AddData("errors.txt", errs.Bytes()); err != nil {
+			r.Close()
+			return err
+		}
+	}
+
+	return r.Close()
+}
+
+func collectVersion(r *Report) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "version: %s\n", buildcfg.PACKAGE_VERSION)
+	fmt.Fprintf(&b, "sysconfdir: %s\n", buildcfg.SYSCONFDIR)
+	fmt.Fprintf(&b, "libexecdir: %s\n", buildcfg.LIBEXECDIR)
+	fmt.Fprintf(&b, "sessiondir: %s\n", buildcfg.SESSIONDIR)
+
+	// starter-suid must be owned by root and have the setuid bit set
+	starter := filepath.Join(buildcfg.LIBEXECDIR, "singularity", "bin", "starter-suid")
+	if fi, err := os.Stat(starter); err != nil {
+		fmt.Fprintf(&b, "starter-suid: %s\n", err)
+	} else {
+		st := fi.Sys().(*syscall.Stat_t)
+		fmt.Fprintf(&b, "starter-suid: mode %s, uid %d\n", fi.Mode(), st.Uid)
+	}
+
+	return r.AddData("version.txt", b.Bytes())
+}
+
+func collectConfig(r *Report) error {
+	path := filepath.Join(buildcfg.SYSCONFDIR, "singularity", "singularity.conf")
+
+	engineConfig := singularity.NewConfig()
+	if err := config.Parser(path, engineConfig.File); err != nil {
+		return fmt.Errorf("unable to parse %s: %s", path, err)
+	}
+	b, err := json.MarshalIndent(engineConfig.File, "", "\t")
+	if err != nil {
+		return err
+	}
+	return r.AddData("config.json", b)
+}
+
+func collectKernel(r *Report) error {
+	var b bytes.Buffer
+
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return err
+	}
+	release := utsString(uts.Release[:])
+	fmt.Fprintf(&b, "release: %s\n", release)
+	fmt.Fprintf(&b, "version: %s\n", utsString(uts.Version[:]))
+	fmt.Fprintf(&b, "machine: %s\n", utsString(uts.Machine[:]))
+
+	for _, path := range []string{
+		"/proc/sys/user/max_user_namespaces",
+		"/proc/sys/kernel/unprivileged_userns_clone",
+	} {
+		fmt.Fprintf(&b, "%s: %s\n", path, readValue(path))
+	}
+
+	filesystems, _ := hostcheck.Filesystems()
+	for _, fs := range []string{"overlay", "squashfs", "ext3", "fuse"} {
+		fmt.Fprintf(&b, "filesystem %s: %t\n", fs, filesystems[fs])
+	}
+
+	options, err := kernelConfig(release)
+	if err != nil {
+		fmt.Fprintf(&b, "kernel config: %s\n", err)
+	} else {
+		for _, opt := range squashfsCompressors {
+			fmt.Fprintf(&b, "%s: %s\n", opt, options[opt])
+		}
+	}
+
+	return r.AddData("kernel.txt", b.Bytes())
+}
+
+func collectLoop(r *Report) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "max_loop: %s\n", readValue("/sys/module/loop/parameters/max_loop"))
+
+	devices, err := filepath.Glob("/sys/block/loop*")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&b, "devices: %d\n", len(devices))
+	for _, dev := range devices {
+		// backing_file only exists for attached loop devices
+		path := filepath.Join(dev, "loop", "backing_file")
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		backing := readValue(path)
+		offset := readValue(filepath.Join(dev, "loop", "offset"))
+		fmt.Fprintf(&b, "%s: %s (offset %s)\n", filepath.Base(dev), backing, offset)
+	}
+
+	return r.AddData("loop.txt", b.Bytes())
+}
+
+func collectCgroup(r *Report) error {
+	mode, err := hostcheck.CgroupMode()
+	if err != nil {
+		return err
+	}
+
+	if err := r.AddData("cgroup.txt", []byte(fmt.Sprintf("mode: %s\n", mode))); err != nil {
+		return err
+	}
+	return r.AddFile("mountinfo.txt", "/proc/self/mountinfo", 0)
+}
+
+func collectLogs(r *Report) error {
+	files, err := instance.List("", "*")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		stdout, stderr, err := instance.GetLogFilePaths(file.Name)
+		if err != nil {
+			return err
+		}
+		for _, path := range []string{stdout, stderr} {
+			name := filepath.Join("logs", filepath.Base(path))
+			if err := r.AddFile(name, path, maxLogSize); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// kernelConfig returns the options of the running kernel configuration
+func kernelConfig(release string) (map[string]string, error) {
+	var rd io.Reader
+
+	if f, err := os.Open("/proc/config.gz"); err == nil {
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		rd = gz
+	} else {
+		f, err := os.Open(filepath.Join("/boot", "config-"+release))
+		if err != nil {
+			return nil, fmt.Errorf("no kernel configuration found")
+		}
+		defer f.Close()
+		rd = f
+	}
+
+	options := make(map[string]string)
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) == 2 && strings.HasPrefix(kv[0], "CONFIG_") {
+			options[kv[0]] = kv[1]
+		}
+	}
+	return options, scanner.Err()
+}
+
+// readValue returns the trimmed content of a small file or the error
+// message if it can't be read
+func readValue(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+
+	b := make([]byte, 4096)
+	n, _ := f.Read(b)
+	return strings.TrimSpace(string(b[:n]))
+}
+
+func utsString(field []byte) string {
+	return string(bytes.TrimRight(field, "\x00"))
+}
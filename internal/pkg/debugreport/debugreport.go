@@ -0,0 +1,103 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package debugreport gathers information about the singularity installation
+// and the host into a gzipped tarball to be attached to bug reports.
+package debugreport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Report is a tarball being filled with report files
+type Report struct {
+	file  *os.File
+	gz    *gzip.Writer
+	tw    *tar.Writer
+	dir   string
+	mtime time.Time
+}
+
+// Create creates a report tarball at path, report files are stored in a
+// directory named after the tarball
+func Create(path string) (*Report, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("while creating report %s: %s", path, err)
+	}
+	gz := gzip.NewWriter(f)
+
+	return &Report{
+		file:  f,
+		gz:    gz,
+		tw:    tar.NewWriter(gz),
+		dir:   strings.TrimSuffix(filepath.Base(path), ".tar.gz"),
+		mtime: time.Now(),
+	}, nil
+}
+
+// AddData adds a report file named name holding data
+func (r *Report) AddData(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    filepath.Join(r.dir, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: r.mtime,
+	}
+	if err := r.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("while adding %s to report: %s", name, err)
+	}
+	if _, err := r.tw.Write(data); err != nil {
+		return fmt.Errorf("while adding %s to report: %s", name, err)
+	}
+	return nil
+}
+
+// AddFile adds the content of the file at path as a report file named name,
+// only the last max bytes are kept if max is greater than zero
+func (r *Report) AddFile(name, path string, max int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if max > 0 && fi.Size() > max {
+		if _, err := f.Seek(-max, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("while reading %s: %s", path, err)
+	}
+	return r.AddData(name, data)
+}
+
+// Close finalizes the report tarball
+func (r *Report) Close() error {
+	defer r.file.Close()
+
+	if err := r.tw.Close(); err != nil {
+		return err
+	}
+	if err := r.gz.Close(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
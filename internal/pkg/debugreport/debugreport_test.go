@@ -0,0 +1,77 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package debugreport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debugreport-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := filepath.Join(dir, "test.log")
+	if err := ioutil.WriteFile(log, []byte("first line\nlast line\n"), 0644); err != nil {
+		t.Fatalf("failed to write log file: %s", err)
+	}
+
+	path := filepath.Join(dir, "report.tar.gz")
+	r, err := Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.AddData("data.txt", []byte("data")); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := r.AddFile("log.txt", log, 10); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := Create(path); err == nil {
+		t.Errorf("unexpected success while overwriting report")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open report: %s", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to decompress report: %s", err)
+	}
+
+	expected := map[string]string{
+		"report/data.txt": "data",
+		"report/log.txt":  "last line\n",
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		b, _ := ioutil.ReadAll(tr)
+		if expected[hdr.Name] != string(b) {
+			t.Errorf("unexpected content %q for %s", b, hdr.Name)
+		}
+		delete(expected, hdr.Name)
+	}
+	for name := range expected {
+		t.Errorf("missing %s in report", name)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux
+
+// Package hostcheck verifies that a host provides the kernel features and
+// installation requirements needed to run singularity.
+package hostcheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"golang.org/x/sys/unix"
+)
+
+// Status is the result of a check
+type Status string
+
+// Check statuses
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+)
+
+// cgroup2SuperMagic is the filesystem magic of the cgroup v2 hierarchy
+const cgroup2SuperMagic = 0x63677270
+
+// minKernel is the minimal kernel version supported
+var minKernel = [2]int{3, 10}
+
+// Result holds the result of a check along with a remediation hint
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string
+}
+
+// Config holds the singularity.conf values used by checks
+type Config struct {
+	MaxLoopDevices uint
+	AllowSetuid    bool
+}
+
+// Run runs all checks
+func Run(c Config) []Result {
+	return []Result{
+		checkKernel(),
+		checkUserNamespace(),
+		checkFilesystem("overlay", "overlay support is required for --overlay and to create bind points in images", "load the overlay kernel module (modprobe overlay)"),
+		checkFilesystem("squashfs", "squashfs support is required to run SIF and squashfs images", "load the squashfs kernel module (modprobe squashfs)"),
+		checkLoop(c),
+		checkCgroup(),
+		checkSetuid(c),
+	}
+}
+
+// KernelVersion returns the major and minor version of the running kernel
+func KernelVersion() (int, int, error) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return 0, 0, err
+	}
+	return parseRelease(string(bytes.TrimRight(uts.Release[:], "\x00")))
+}
+
+// parseRelease returns the major and minor version of a kernel release
+// string like 4.15.0-36-generic
+func parseRelease(release string) (int, int, error) {
+	v := strings.SplitN(release, ".", 3)
+	if len(v) < 2 {
+		return 0, 0, fmt.Errorf("unexpected kernel release %s", release)
+	}
+	major, err := strconv.Atoi(v[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected kernel release %s", release)
+	}
+	// minor version may be followed by a suffix like in 5.0-rc1
+	if i := strings.IndexFunc(v[1], func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		v[1] = v[1][:i]
+	}
+	minor, err := strconv.Atoi(v[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected kernel release %s", release)
+	}
+	return major, minor, nil
+}
+
+// Filesystems returns the filesystems supported by the running kernel
+func Filesystems() (map[string]bool, error) {
+	f, err := os.Open("/proc/filesystems")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	filesystems := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			filesystems[fields[len(fields)-1]] = true
+		}
+	}
+	return filesystems, scanner.Err()
+}
+
+// CgroupMode returns the cgroup hierarchy mode, either legacy, hybrid or
+// unified
+func CgroupMode() (string, error) {
+	var st syscall.Statfs_t
+
+	if err := syscall.Statfs("/sys/fs/cgroup", &st); err != nil {
+		return "", err
+	}
+	if st.Type == cgroup2SuperMagic {
+		return "unified", nil
+	} else if _, err := os.Stat("/sys/fs/cgroup/unified"); err == nil {
+		return "hybrid", nil
+	}
+	return "legacy", nil
+}
+
+func checkKernel() Result {
+	r := Result{Name: "kernel"}
+
+	major, minor, err := KernelVersion()
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	r.Detail = fmt.Sprintf("version %d.%d", major, minor)
+	if major < minKernel[0] || (major == minKernel[0] && minor < minKernel[1]) {
+		r.Status = Fail
+		r.Hint = fmt.Sprintf("upgrade to a kernel version %d.%d or later", minKernel[0], minKernel[1])
+		return r
+	}
+	r.Status = Pass
+	return r
+}
+
+func checkUserNamespace() Result {
+	r := Result{Name: "user namespace"}
+
+	b, err := ioutil.ReadFile("/proc/sys/user/max_user_namespaces")
+	if err != nil {
+		r.Status, r.Detail = Warn, "not supported by the kernel"
+		r.Hint = "unprivileged users need the setuid installation to run containers"
+		return r
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err != nil || n == 0 {
+		r.Status, r.Detail = Warn, "disabled"
+		r.Hint = "set user.max_user_namespaces to a non zero value with sysctl to allow --userns"
+		return r
+	}
+	if b, err := ioutil.ReadFile("/proc/sys/kernel/unprivileged_userns_clone"); err == nil && strings.TrimSpace(string(b)) == "0" {
+		r.Status, r.Detail = Warn, "disabled for unprivileged users"
+		r.Hint = "set kernel.unprivileged_userns_clone to 1 with sysctl to allow --userns"
+		return r
+	}
+	r.Status, r.Detail = Pass, "enabled"
+	return r
+}
+
+func checkFilesystem(fs, detail, hint string) Result {
+	r := Result{Name: fs}
+
+	filesystems, err := Filesystems()
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	if !filesystems[fs] {
+		r.Status, r.Detail, r.Hint = Fail, detail, hint
+		return r
+	}
+	r.Status, r.Detail = Pass, "supported"
+	return r
+}
+
+func checkLoop(c Config) Result {
+	r := Result{Name: "loop devices"}
+
+	devices, _ := filepath.Glob("/dev/loop[0-9]*")
+	used := 0
+	for _, dev := range devices {
+		if _, err := os.Stat(filepath.Join("/sys/block", filepath.Base(dev), "loop", "backing_file")); err == nil {
+			used++
+		}
+	}
+	r.Detail = fmt.Sprintf("%d devices, %d in use, max loop devices %d", len(devices), used, c.MaxLoopDevices)
+
+	if _, err := os.Stat("/dev/loop-control"); err != nil && len(devices) == 0 {
+		r.Status = Fail
+		r.Hint = "load the loop kernel module (modprobe loop)"
+		return r
+	}
+	if c.MaxLoopDevices == 0 || uint(used) >= c.MaxLoopDevices {
+		r.Status = Fail
+		r.Hint = "increase max loop devices in singularity.conf"
+		return r
+	}
+	r.Status = Pass
+	return r
+}
+
+func checkCgroup() Result {
+	r := Result{Name: "cgroup"}
+
+	mode, err := CgroupMode()
+	if err != nil {
+		r.Status, r.Detail = Warn, err.Error()
+		r.Hint = "mount the cgroup hierarchy on /sys/fs/cgroup to use --apply-cgroups"
+		return r
+	}
+	r.Detail = mode
+	if mode == "unified" {
+		r.Status = Warn
+		r.Hint = "--apply-cgroups only supports cgroup v1, boot with systemd.unified_cgroup_hierarchy=0 to use it"
+		return r
+	}
+	r.Status = Pass
+	return r
+}
+
+func checkSetuid(c Config) Result {
+	r := Result{Name: "setuid installation"}
+
+	starter := filepath.Join(buildcfg.LIBEXECDIR, "singularity", "bin", "starter-suid")
+	fi, err := os.Stat(starter)
+	if err != nil {
+		r.Status, r.Detail = Warn, "starter-suid not installed"
+		r.Hint = "unprivileged users need user namespaces to run containers"
+		return r
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	if st.Uid != 0 || fi.Mode()&os.ModeSetuid == 0 {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s is not setuid root", starter)
+		r.Hint = fmt.Sprintf("run: chown root %s && chmod 4755 %s", starter, starter)
+		return r
+	}
+	if !c.AllowSetuid {
+		r.Status, r.Detail = Warn, "disabled by allow setuid in singularity.conf"
+		return r
+	}
+	r.Status, r.Detail = Pass, "enabled"
+	return r
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package hostcheck
+
+import (
+	"testing"
+)
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		release string
+		major   int
+		minor   int
+		fail    bool
+	}{
+		{"4.15.0-36-generic", 4, 15, false},
+		{"3.10.0-957.el7.x86_64", 3, 10, false},
+		{"5.0-rc1", 5, 0, false},
+		{"4.19+", 4, 19, false},
+		{"linux", 0, 0, true},
+	}
+	for _, tt := range tests {
+		major, minor, err := parseRelease(tt.release)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("unexpected success for %s", tt.release)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", tt.release, err)
+		} else if major != tt.major || minor != tt.minor {
+			t.Errorf("unexpected version %d.%d for %s", major, minor, tt.release)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	results := Run(Config{MaxLoopDevices: 256, AllowSetuid: true})
+	for _, r := range results {
+		if r.Name == "" || r.Status == "" {
+			t.Errorf("incomplete check result %+v", r)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package hwtoken
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// digestInfoPrefixes are the DER encoded DigestInfo headers prepended to
+// digests for RSA PKCS #1 v1.5 signatures
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// namedCurves maps the OID of supported curves to their implementation
+var namedCurves = []struct {
+	oid   asn1.ObjectIdentifier
+	curve elliptic.Curve
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}, elliptic.P256()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 34}, elliptic.P384()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 35}, elliptic.P521()},
+}
+
+// Key is a RSA or ECDSA key pair held on a PKCS#11 token. Key implements
+// crypto.Signer once opened with a PIN.
+type Key struct {
+	ctx      *pkcs11.Ctx
+	session  pkcs11.SessionHandle
+	loggedIn bool
+	priv     pkcs11.ObjectHandle
+	pub      crypto.PublicKey
+}
+
+// Open opens the key selected by u. The private key is only available for
+// signing if a PIN is provided, the public key doesn't require one.
+func Open(u *URI, pin string) (*Key, error) {
+	ctx := pkcs11.New(u.ModulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("unable to load PKCS#11 module %s", u.ModulePath)
+	}
+	if err := ctx.Initialize(); err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("while initializing PKCS#11 module: %s", err)
+	}
+
+	k := &Key{ctx: ctx}
+	if err := k.open(u, pin); err != nil {
+		k.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+// open looks up the key objects selected by u
+func (k *Key) open(u *URI, pin string) error {
+	slot, err := k.findSlot(u)
+	if err != nil {
+		return err
+	}
+	k.session, err = k.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("while opening token session: %s", err)
+	}
+
+	if pin != "" {
+		if err := k.ctx.Login(k.session, pkcs11.CKU_USER, pin); err != nil {
+			return fmt.Errorf("while logging in token: %s", err)
+		}
+		k.loggedIn = true
+
+		k.priv, err = k.findObject(u, pkcs11.CKO_PRIVATE_KEY)
+		if err != nil {
+			return err
+		}
+	}
+
+	pub, err := k.findObject(u, pkcs11.CKO_PUBLIC_KEY)
+	if err != nil {
+		return err
+	}
+	k.pub, err = k.publicKey(pub)
+	return err
+}
+
+// Close releases the token session
+func (k *Key) Close() error {
+	if k.loggedIn {
+		k.ctx.Logout(k.session)
+	}
+	if k.session != 0 {
+		k.ctx.CloseSession(k.session)
+	}
+	err := k.ctx.Finalize()
+	k.ctx.Destroy()
+	return err
+}
+
+// Public returns the public key as a rsa.PublicKey or ecdsa.PublicKey value
+// as expected by the openpgp package
+func (k *Key) Public() crypto.PublicKey {
+	switch pub := k.pub.(type) {
+	case *rsa.PublicKey:
+		return *pub
+	case *ecdsa.PublicKey:
+		return *pub
+	}
+	return nil
+}
+
+// Sign signs digest with the private key on the token. RSA signatures use
+// PKCS #1 v1.5 with the hash given by opts, ECDSA signatures are returned
+// ASN.1 encoded.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if !k.loggedIn {
+		return nil, fmt.Errorf("token key was opened without PIN")
+	}
+
+	var mechanism uint
+	data := digest
+	switch k.pub.(type) {
+	case *rsa.PublicKey:
+		var err error
+		mechanism = pkcs11.CKM_RSA_PKCS
+		if data, err = digestInfo(opts.HashFunc(), digest); err != nil {
+			return nil, err
+		}
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.CKM_ECDSA
+	}
+
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, k.priv); err != nil {
+		return nil, fmt.Errorf("while initializing token signature: %s", err)
+	}
+	sig, err := k.ctx.Sign(k.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("while signing with token: %s", err)
+	}
+
+	if mechanism == pkcs11.CKM_ECDSA {
+		return ecdsaSignature(sig)
+	}
+	return sig, nil
+}
+
+// findSlot returns the slot holding the token matching u
+func (k *Key) findSlot(u *URI) (uint, error) {
+	slots, err := k.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("while listing token slots: %s", err)
+	}
+	for _, slot := range slots {
+		info, err := k.ctx.GetTokenInfo(slot)
+		if err != nil {
+			sylog.Debugf("Ignoring slot %d: %s", slot, err)
+			continue
+		}
+		if match(u.Token, info.Label) &&
+			match(u.Manufacturer, info.ManufacturerID) &&
+			match(u.Model, info.Model) &&
+			match(u.Serial, info.SerialNumber) {
+			sylog.Debugf("Using token %q in slot %d", strings.TrimSpace(info.Label), slot)
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("no token matching the PKCS#11 URI found")
+}
+
+// findObject returns the single object of class matching u
+func (k *Key) findObject(u *URI, class uint) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, class)}
+	if u.Object != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, u.Object))
+	}
+	if len(u.ID) > 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, u.ID))
+	}
+
+	if err := k.ctx.FindObjectsInit(k.session, template); err != nil {
+		return 0, fmt.Errorf("while searching token keys: %s", err)
+	}
+	objects, _, err := k.ctx.FindObjects(k.session, 2)
+	k.ctx.FindObjectsFinal(k.session)
+	if err != nil {
+		return 0, fmt.Errorf("while searching token keys: %s", err)
+	}
+
+	kind := "public"
+	if class == pkcs11.CKO_PRIVATE_KEY {
+		kind = "private"
+	}
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("no %s key matching the PKCS#11 URI found", kind)
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("several %s keys match the PKCS#11 URI", kind)
+	}
+}
+
+// publicKey reads the public key object pub
+func (k *Key) publicKey(pub pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := k.ctx.GetAttributeValue(k.session, pub, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while reading public key type: %s", err)
+	}
+	keyType := attrs[0].Value
+
+	switch {
+	case isKeyType(keyType, pkcs11.CKK_RSA):
+		attrs, err := k.ctx.GetAttributeValue(k.session, pub, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("while reading RSA public key: %s", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	case isKeyType(keyType, pkcs11.CKK_EC):
+		attrs, err := k.ctx.GetAttributeValue(k.session, pub, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("while reading EC public key: %s", err)
+		}
+		return ecdsaPublicKey(attrs[0].Value, attrs[1].Value)
+	}
+	return nil, fmt.Errorf("unsupported token key type %x", keyType)
+}
+
+// match returns if a token information field matches the URI attribute
+// want, an empty attribute matches any value
+func match(want, field string) bool {
+	return want == "" || want == strings.TrimSpace(field)
+}
+
+// isKeyType returns if the CKA_KEY_TYPE attribute value, a CK_ULONG in host
+// byte order, is keyType
+func isKeyType(value []byte, keyType uint) bool {
+	return bytes.Equal(value, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType).Value)
+}
+
+// digestInfo returns digest prefixed by the DigestInfo header of hash
+func digestInfo(hash crypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := digestInfoPrefixes[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function %d", hash)
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("digest size %d doesn't match hash function", len(digest))
+	}
+	return append(append([]byte{}, prefix...), digest...), nil
+}
+
+// ecdsaSignature converts a PKCS#11 ECDSA signature, the concatenation of r
+// and s, to its ASN.1 encoding
+func ecdsaSignature(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, fmt.Errorf("malformed ECDSA signature of %d bytes", len(sig))
+	}
+	n := len(sig) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(sig[:n]),
+		new(big.Int).SetBytes(sig[n:]),
+	})
+}
+
+// ecdsaPublicKey decodes the CKA_EC_PARAMS and CKA_EC_POINT attributes
+func ecdsaPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("while decoding EC parameters: %s", err)
+	}
+	var curve elliptic.Curve
+	for _, c := range namedCurves {
+		if c.oid.Equal(oid) {
+			curve = c.curve
+		}
+	}
+	if curve == nil {
+		return nil, fmt.Errorf("unsupported elliptic curve %s", oid)
+	}
+
+	// the point should be wrapped in a DER octet string but some
+	// tokens return it raw
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, fmt.Errorf("malformed EC point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
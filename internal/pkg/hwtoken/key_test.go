@@ -0,0 +1,109 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package hwtoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestDigestInfo(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	digest := sha256.Sum256([]byte("data"))
+
+	// a raw RSA PKCS #1 v1.5 signature of the DigestInfo, as done by
+	// CKM_RSA_PKCS, must match the signature of the digest
+	data, err := digestInfo(crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("unexpected failure: %s", err)
+	}
+	raw, err := rsa.SignPKCS1v15(nil, key, 0, data)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], raw); err != nil {
+		t.Errorf("signature verification failed: %s", err)
+	}
+
+	if _, err := digestInfo(crypto.MD5, digest[:16]); err == nil {
+		t.Errorf("unexpected success with unsupported hash")
+	}
+	if _, err := digestInfo(crypto.SHA256, digest[:16]); err == nil {
+		t.Errorf("unexpected success with wrong digest size")
+	}
+}
+
+func TestECDSASignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	digest := sha256.Sum256([]byte("data"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+
+	// PKCS#11 returns r and s padded to the curve size
+	raw := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(raw[32-len(rb):], rb)
+	copy(raw[64-len(sb):], sb)
+
+	der, err := ecdsaSignature(raw)
+	if err != nil {
+		t.Fatalf("unexpected failure: %s", err)
+	}
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		t.Fatalf("failed to decode signature: %s", err)
+	}
+	if !ecdsa.Verify(&key.PublicKey, digest[:], sig.R, sig.S) {
+		t.Errorf("signature verification failed")
+	}
+
+	if _, err := ecdsaSignature(raw[:63]); err == nil {
+		t.Errorf("unexpected success with odd signature size")
+	}
+}
+
+func TestECDSAPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	params, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})
+	point := elliptic.Marshal(key.Curve, key.X, key.Y)
+	wrapped, _ := asn1.Marshal(point)
+
+	for _, p := range [][]byte{wrapped, point} {
+		pub, err := ecdsaPublicKey(params, p)
+		if err != nil {
+			t.Fatalf("unexpected failure: %s", err)
+		}
+		if pub.Curve != elliptic.P384() || pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+			t.Errorf("unexpected public key")
+		}
+	}
+
+	unknown, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	if _, err := ecdsaPublicKey(unknown, wrapped); err == nil {
+		t.Errorf("unexpected success with unsupported curve")
+	}
+	if _, err := ecdsaPublicKey(params, []byte{4, 1, 2}); err == nil {
+		t.Errorf("unexpected success with malformed point")
+	}
+}
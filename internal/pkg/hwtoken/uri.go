@@ -0,0 +1,137 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package hwtoken gives access to keys held on PKCS#11 hardware tokens like
+// Yubikeys or HSMs, private keys never leave the token.
+package hwtoken
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// URIScheme is the scheme of PKCS#11 URIs as defined by RFC 7512
+const URIScheme = "pkcs11:"
+
+// URI selects a key on a PKCS#11 token, empty attributes match any value
+type URI struct {
+	// path attributes
+	Token        string
+	Manufacturer string
+	Model        string
+	Serial       string
+	Object       string
+	ID           []byte
+
+	// query attributes
+	ModulePath string
+	PINValue   string
+	PINSource  string
+}
+
+// ParseURI parses a PKCS#11 URI like
+// pkcs11:token=mytoken;object=mykey?module-path=/usr/lib/libykcs11.so
+func ParseURI(s string) (*URI, error) {
+	if !strings.HasPrefix(s, URIScheme) {
+		return nil, fmt.Errorf("%s is not a PKCS#11 URI", s)
+	}
+	path := strings.TrimPrefix(s, URIScheme)
+	query := ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+
+	u := &URI{}
+	for _, attr := range splitAttributes(path, ";") {
+		name, value, err := parseAttribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "token":
+			u.Token = value
+		case "manufacturer":
+			u.Manufacturer = value
+		case "model":
+			u.Model = value
+		case "serial":
+			u.Serial = value
+		case "object":
+			u.Object = value
+		case "id":
+			u.ID = []byte(value)
+		case "type":
+			if value != "private" && value != "public" {
+				return nil, fmt.Errorf("unsupported object type %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported PKCS#11 URI attribute %s", name)
+		}
+	}
+	for _, attr := range splitAttributes(query, "&") {
+		name, value, err := parseAttribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "module-path":
+			u.ModulePath = value
+		case "pin-value":
+			u.PINValue = value
+		case "pin-source":
+			u.PINSource = value
+		default:
+			return nil, fmt.Errorf("unsupported PKCS#11 URI query attribute %s", name)
+		}
+	}
+
+	if u.ModulePath == "" {
+		return nil, fmt.Errorf("PKCS#11 URI requires a module-path query attribute")
+	}
+	if u.Object == "" && len(u.ID) == 0 {
+		return nil, fmt.Errorf("PKCS#11 URI requires an object or id attribute")
+	}
+	return u, nil
+}
+
+// PIN returns the PIN set by the pin-value or pin-source attributes, an
+// empty string is returned if there is none
+func (u *URI) PIN() (string, error) {
+	if u.PINValue != "" {
+		return u.PINValue, nil
+	}
+	if u.PINSource == "" {
+		return "", nil
+	}
+	path := strings.TrimPrefix(u.PINSource, "file:")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("while reading PIN: %s", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// splitAttributes splits s in attributes separated by sep
+func splitAttributes(s, sep string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, sep)
+}
+
+// parseAttribute returns the name and the unescaped value of attr
+func parseAttribute(attr string) (string, string, error) {
+	kv := strings.SplitN(attr, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return "", "", fmt.Errorf("malformed PKCS#11 URI attribute %q", attr)
+	}
+	value, err := url.PathUnescape(kv[1])
+	if err != nil {
+		return "", "", fmt.Errorf("malformed PKCS#11 URI attribute %q: %s", attr, err)
+	}
+	return kv[0], value, nil
+}
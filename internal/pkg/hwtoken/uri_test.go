@@ -0,0 +1,106 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package hwtoken
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name          string
+		uri           string
+		expectSuccess bool
+		expected      URI
+	}{
+		{
+			"token and object",
+			"pkcs11:token=mytoken;object=mykey?module-path=/usr/lib/libykcs11.so",
+			true,
+			URI{Token: "mytoken", Object: "mykey", ModulePath: "/usr/lib/libykcs11.so"},
+		},
+		{
+			"escaped values",
+			"pkcs11:token=my%20token;id=%01%02;serial=1234?module-path=/lib/p11.so&pin-value=12%2634",
+			true,
+			URI{Token: "my token", ID: []byte{1, 2}, Serial: "1234", ModulePath: "/lib/p11.so", PINValue: "12&34"},
+		},
+		{
+			"object type",
+			"pkcs11:object=mykey;type=private?module-path=/lib/p11.so&pin-source=file:/tmp/pin",
+			true,
+			URI{Object: "mykey", ModulePath: "/lib/p11.so", PINSource: "file:/tmp/pin"},
+		},
+		{"wrong scheme", "file:token=mytoken;object=mykey?module-path=/lib/p11.so", false, URI{}},
+		{"missing module", "pkcs11:token=mytoken;object=mykey", false, URI{}},
+		{"missing object", "pkcs11:token=mytoken?module-path=/lib/p11.so", false, URI{}},
+		{"unknown attribute", "pkcs11:object=mykey;color=red?module-path=/lib/p11.so", false, URI{}},
+		{"unknown query attribute", "pkcs11:object=mykey?module-path=/lib/p11.so&color=red", false, URI{}},
+		{"secret key type", "pkcs11:object=mykey;type=secret-key?module-path=/lib/p11.so", false, URI{}},
+		{"malformed attribute", "pkcs11:object?module-path=/lib/p11.so", false, URI{}},
+		{"malformed escape", "pkcs11:object=my%zzkey?module-path=/lib/p11.so", false, URI{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseURI(tt.uri)
+			if !tt.expectSuccess {
+				if err == nil {
+					t.Fatalf("unexpected success parsing %s", tt.uri)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected failure parsing %s: %s", tt.uri, err)
+			}
+			if u.Token != tt.expected.Token || u.Object != tt.expected.Object ||
+				u.Serial != tt.expected.Serial || !bytes.Equal(u.ID, tt.expected.ID) ||
+				u.ModulePath != tt.expected.ModulePath || u.PINValue != tt.expected.PINValue ||
+				u.PINSource != tt.expected.PINSource {
+				t.Errorf("unexpected result %+v instead of %+v", *u, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPIN(t *testing.T) {
+	f, err := ioutil.TempFile("", "pin-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("5678\n")
+	f.Close()
+
+	tests := []struct {
+		name     string
+		uri      URI
+		expected string
+	}{
+		{"none", URI{}, ""},
+		{"value", URI{PINValue: "1234"}, "1234"},
+		{"value first", URI{PINValue: "1234", PINSource: f.Name()}, "1234"},
+		{"source", URI{PINSource: f.Name()}, "5678"},
+		{"file source", URI{PINSource: "file:" + f.Name()}, "5678"},
+	}
+
+	for _, tt := range tests {
+		pin, err := tt.uri.PIN()
+		if err != nil {
+			t.Errorf("%s: unexpected failure: %s", tt.name, err)
+		} else if pin != tt.expected {
+			t.Errorf("%s: unexpected PIN %q instead of %q", tt.name, pin, tt.expected)
+		}
+	}
+
+	u := URI{PINSource: "/non/existent/pin"}
+	if _, err := u.PIN(); err == nil {
+		t.Errorf("unexpected success reading a non existent PIN file")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractSIFRootfs extracts the squashfs primary partition of the SIF image
+// at path in the directory rootfs with unsquashfs, which doesn't require
+// privileges. The partition is copied next to rootfs while extracted.
+func ExtractSIFRootfs(path, rootfs string) error {
+	objects, err := SIFObjects(path)
+	if err != nil {
+		return err
+	}
+	var part *SIFObject
+	for i, o := range objects {
+		if o.Type == "partition" && o.PartType == "primary system" {
+			part = &objects[i]
+			break
+		}
+	}
+	if part == nil || part.FsType != "squashfs" {
+		return fmt.Errorf("no squashfs primary partition found")
+	}
+
+	unsquashfs, err := exec.LookPath("unsquashfs")
+	if err != nil {
+		return err
+	}
+
+	// unsquashfs only reads standalone squashfs images, copy the partition
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := ioutil.TempFile(filepath.Dir(rootfs), "squashfs-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+	_, err = io.Copy(dst, io.NewSectionReader(src, part.Offset, part.Size))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(unsquashfs, "-no-xattrs", "-d", rootfs, dst.Name())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("unsquashfs failed: %s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -29,6 +29,7 @@ var registeredFormats = []struct {
 	{"sandbox", &sandboxFormat{}},
 	{"squashfs", &squashfsFormat{}},
 	{"ext3", &ext3Format{}},
+	{"manifest", &manifestFormat{}},
 }
 
 // format describes the interface that an image format type must implement.
@@ -49,6 +50,29 @@ type Image struct {
 	Size     uint64   `json:"size"`
 	Writable bool     `json:"writable"`
 	RootFS   bool     `json:"rootFS"`
+	// Blob is the path of the store blob opened for a SIF manifest
+	Blob string `json:"blob,omitempty"`
+
+	// manifest is the file information of a SIF manifest, authorization
+	// applies to it rather than to the blob
+	manifest os.FileInfo
+}
+
+// DataPath returns the path of the file holding the image data, which is
+// the store blob for a SIF manifest
+func (i *Image) DataPath() string {
+	if i.Blob != "" {
+		return i.Blob
+	}
+	return i.Path
+}
+
+// authStat returns the file information used for authorization
+func (i *Image) authStat() (os.FileInfo, error) {
+	if i.manifest != nil {
+		return i.manifest, nil
+	}
+	return i.File.Stat()
 }
 
 // AuthorizedPath checks if image is in a path supplied in paths
@@ -72,7 +96,7 @@ func (i *Image) AuthorizedPath(paths []string) (bool, error) {
 // AuthorizedOwner checks if image is owned by user supplied in users list
 func (i *Image) AuthorizedOwner(owners []string) (bool, error) {
 	authorized := false
-	fileinfo, err := i.File.Stat()
+	fileinfo, err := i.authStat()
 	if err != nil {
 		return authorized, fmt.Errorf("failed to get stat for %s", i.Path)
 	}
@@ -93,7 +117,7 @@ func (i *Image) AuthorizedOwner(owners []string) (bool, error) {
 // AuthorizedGroup checks if image is owned by group supplied in groups list
 func (i *Image) AuthorizedGroup(groups []string) (bool, error) {
 	authorized := false
-	fileinfo, err := i.File.Stat()
+	fileinfo, err := i.authStat()
 	if err != nil {
 		return authorized, fmt.Errorf("failed to get stat for %s", i.Path)
 	}
@@ -0,0 +1,88 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/store"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// manifestFormat handles thin SIF manifests referencing blobs of a local
+// content-addressed store, the image is mounted from the blob holding the
+// primary system partition. The image path stays the manifest path so that
+// authorization applies to the manifest, blobs are only accepted from a
+// store trusted by the user.
+type manifestFormat struct{}
+
+func (f *manifestFormat) initializer(img *Image, fileinfo os.FileInfo) error {
+	if fileinfo.IsDir() || fileinfo.Size() == 0 || fileinfo.Size() > 1<<20 {
+		return fmt.Errorf("not a SIF manifest")
+	}
+	m, err := store.DecodeManifest(img.File)
+	if err != nil {
+		return err
+	}
+	_, seg, err := m.Primary()
+	if err != nil {
+		return err
+	}
+
+	switch seg.Fstype {
+	case "squashfs":
+		img.Type = SQUASHFS
+	case "ext3":
+		img.Type = EXT3
+	default:
+		return fmt.Errorf("unsupported primary partition filesystem %s", seg.Fstype)
+	}
+
+	root, err := filepath.EvalSymlinks(m.Store)
+	if err != nil {
+		return fmt.Errorf("while resolving store %s: %s", m.Store, err)
+	}
+	if err := store.CheckTrusted(root, uint32(os.Getuid())); err != nil {
+		return fmt.Errorf("untrusted store %s: %s", m.Store, err)
+	}
+
+	path := store.BlobPath(root, seg.Digest)
+	blob, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return fmt.Errorf("while opening blob referenced by manifest: %s", err)
+	}
+	bi, err := blob.Stat()
+	if err != nil {
+		blob.Close()
+		return err
+	}
+	if !bi.Mode().IsRegular() || bi.Size() != seg.Size {
+		blob.Close()
+		return fmt.Errorf("blob %s doesn't match its manifest size %d", seg.Digest, seg.Size)
+	}
+	sylog.Debugf("Image %s resolved to store blob %s", img.Path, path)
+
+	img.File.Close()
+	img.File = blob
+	img.Blob = path
+	img.manifest = fileinfo
+	img.Offset = 0
+	img.Size = uint64(seg.Size)
+
+	if img.Writable {
+		sylog.Warningf("images from a store are not writable")
+		img.Writable = false
+	}
+
+	return nil
+}
+
+func (f *manifestFormat) openMode(writable bool) int {
+	return os.O_RDONLY
+}
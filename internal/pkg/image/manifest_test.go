@@ -0,0 +1,96 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/store"
+)
+
+func TestManifestFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.New(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := squashfsHeader(t, squashfsMagic, squashfsZlib)
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if err := ioutil.WriteFile(s.BlobPath(digest), data, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		digest string
+		size   int64
+		prep   func() error
+		ok     bool
+	}{
+		{"valid", digest, int64(len(data)), nil, true},
+		{"traversal", "../../../../../../etc/passwd", int64(len(data)), nil, false},
+		{"wrong size", digest, 1 << 30, nil, false},
+		{"missing blob", hex.EncodeToString(make([]byte, 32)), int64(len(data)), nil, false},
+		{"untrusted store", digest, int64(len(data)), func() error { return os.Chmod(s.Root, 0777) }, false},
+	}
+	for _, tt := range tests {
+		if tt.prep != nil {
+			if err := tt.prep(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		m := &store.Manifest{
+			MediaType: store.MediaType,
+			Store:     s.Root,
+			Segments:  []store.Segment{{Digest: tt.digest, Size: tt.size, Fstype: "squashfs"}},
+		}
+		path := filepath.Join(dir, "image.sif")
+		if err := store.WriteManifest(m, path); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := Init(path, true)
+		if !tt.ok {
+			if err == nil {
+				img.File.Close()
+				t.Errorf("%s: unexpected success", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if img.Type != SQUASHFS || img.Writable {
+			t.Errorf("%s: unexpected type %d or writable image", tt.name, img.Type)
+		}
+		if img.Path != path || img.DataPath() != s.BlobPath(digest) {
+			t.Errorf("%s: unexpected paths %s and %s", tt.name, img.Path, img.DataPath())
+		}
+		fi, err := img.authStat()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		} else if fi.Size() == int64(len(data)) {
+			t.Errorf("%s: authorization doesn't apply to the manifest", tt.name)
+		}
+		img.File.Close()
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+const (
+	// MetadataDir is the directory holding the container metadata
+	MetadataDir = "/.singularity.d"
+	// AppsDir is the directory holding the SCIF apps of the container
+	AppsDir = "/scif/apps"
+
+	// maxSymlinks is the maximum number of symbolic links followed while
+	// resolving a path in a sandbox
+	maxSymlinks = 255
+)
+
+// MetadataPath returns the path, relative to the container root filesystem,
+// of the metadata file name of app, or of the container if app is empty,
+// e.g. /scif/apps/app/scif/labels.json for labels.json of app
+func MetadataPath(name, app string) string {
+	if app == "" {
+		return filepath.Join(MetadataDir, name)
+	}
+	return filepath.Join(AppsDir, app, "scif", name)
+}
+
+// resolveInRoot returns the host path of path in the sandbox image root,
+// resolving symbolic links as if root was the root directory so the path
+// returned never leaves root
+func resolveInRoot(root, path string) (string, error) {
+	resolved := "/"
+	rest := strings.Split(path, "/")
+	links := 0
+
+	for len(rest) > 0 {
+		c := rest[0]
+		rest = rest[1:]
+
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, c)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if os.IsNotExist(err) {
+			// let the caller report the missing file
+			return filepath.Join(append([]string{root, next}, rest...)...), nil
+		} else if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+
+	return filepath.Join(root, resolved), nil
+}
+
+// ReadSandboxFile reads the file at path in the sandbox image root, without
+// starting a container. Symbolic links are resolved relative to root so the
+// file read is always inside the image.
+func ReadSandboxFile(root, path string) ([]byte, error) {
+	path, err := resolveInRoot(root, path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+// SandboxApps returns the names of the SCIF apps of the sandbox image root
+func SandboxApps(root string) ([]string, error) {
+	dir, err := resolveInRoot(root, AppsDir)
+	if err != nil {
+		return nil, err
+	}
+	if !fs.IsDir(dir) {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("while reading apps of %s: %s", root, err)
+	}
+
+	var apps []string
+	for _, e := range entries {
+		scif, err := resolveInRoot(root, filepath.Join(AppsDir, e.Name(), "scif"))
+		if err == nil && fs.IsDir(scif) {
+			apps = append(apps, e.Name())
+		}
+	}
+	return apps, nil
+}
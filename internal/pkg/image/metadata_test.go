@@ -0,0 +1,76 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadSandboxFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sandbox-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(path, content string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	root := filepath.Join(dir, "rootfs")
+	write("rootfs/.singularity.d/labels.json", "{}")
+	write("rootfs/scif/apps/foo/scif/runscript", "foo")
+	write("rootfs/scif/apps/bar/scif/runscript", "bar")
+	write("rootfs/scif/apps/notanapp", "")
+	write("host/runscript.help", "host")
+	write("rootfs/etc/help", "container")
+	// absolute links point inside the sandbox, not to the host
+	if err := os.Symlink(filepath.Join(dir, "host", "runscript.help"), filepath.Join(root, ".singularity.d", "runscript.help")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Symlink("/etc/help", filepath.Join(root, ".singularity.d", "test")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		content string
+	}{
+		{MetadataPath("labels.json", ""), "{}"},
+		{MetadataPath("runscript", "foo"), "foo"},
+		{MetadataPath("test", ""), "container"},
+	}
+	for _, tt := range tests {
+		content, err := ReadSandboxFile(root, tt.path)
+		if err != nil {
+			t.Errorf("unexpected error reading %s: %v", tt.path, err)
+		} else if string(content) != tt.content {
+			t.Errorf("unexpected content %q of %s, expected %q", content, tt.path, tt.content)
+		}
+	}
+
+	if _, err := ReadSandboxFile(root, MetadataPath("runscript.help", "")); !os.IsNotExist(err) {
+		t.Errorf("unexpected error %v reading a link to a host file", err)
+	}
+
+	apps, err := SandboxApps(root)
+	if err != nil {
+		t.Fatalf("unexpected error listing apps: %v", err)
+	}
+	if expected := []string{"bar", "foo"}; !reflect.DeepEqual(apps, expected) {
+		t.Errorf("unexpected apps %v, expected %v", apps, expected)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// SIFObject describes a data object of a SIF image, as found in its
+// descriptor
+type SIFObject struct {
+	ID   uint32 `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	// Group is the group of the object, 0 if it is not part of a group
+	Group uint32 `json:"group,omitempty"`
+	// Link is the ID of the object linked to, or of the group if LinkGroup
+	// is set, 0 if the object has no link
+	Link      uint32    `json:"link,omitempty"`
+	LinkGroup bool      `json:"linkGroup,omitempty"`
+	Offset    int64     `json:"offset"`
+	Size      int64     `json:"size"`
+	Created   time.Time `json:"created"`
+	// Arch, FsType and PartType are set for partitions
+	Arch     string `json:"arch,omitempty"`
+	FsType   string `json:"fsType,omitempty"`
+	PartType string `json:"partType,omitempty"`
+	// HashType is set for signatures
+	HashType string `json:"hashType,omitempty"`
+	// Signed indicates whether a signature covers the object, directly or
+	// through its group
+	Signed bool `json:"signed"`
+}
+
+var sifDatatypes = map[sif.Datatype]string{
+	sif.DataDeffile:     "deffile",
+	sif.DataEnvVar:      "envvars",
+	sif.DataLabels:      "labels",
+	sif.DataPartition:   "partition",
+	sif.DataSignature:   "signature",
+	sif.DataGenericJSON: "json",
+}
+
+var sifFstypes = map[sif.Fstype]string{
+	sif.FsSquash:  "squashfs",
+	sif.FsExt3:    "ext3",
+	sif.FsImmuObj: "archive",
+	sif.FsRaw:     "raw",
+}
+
+var sifParttypes = map[sif.Parttype]string{
+	sif.PartSystem:  "system",
+	sif.PartPrimSys: "primary system",
+	sif.PartData:    "data",
+	sif.PartOverlay: "overlay",
+}
+
+var sifHashtypes = map[sif.Hashtype]string{
+	sif.HashSHA256:  "sha256",
+	sif.HashSHA384:  "sha384",
+	sif.HashSHA512:  "sha512",
+	sif.HashBLAKE2S: "blake2s",
+	sif.HashBLAKE2B: "blake2b",
+}
+
+// typeName returns name, or a description of the unknown type t if name is
+// empty
+func typeName(name string, t int32) string {
+	if name == "" {
+		return fmt.Sprintf("unknown (%#x)", t)
+	}
+	return name
+}
+
+// SIFObjects returns the data objects of the SIF image at path, in order of
+// their descriptors
+func SIFObjects(path string) ([]SIFObject, error) {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer fimg.UnloadContainer()
+
+	// IDs and groups covered by a signature
+	signedIDs := make(map[uint32]bool)
+	signedGroups := make(map[uint32]bool)
+	for _, d := range fimg.DescrArr {
+		if !d.Used || d.Datatype != sif.DataSignature || d.Link == sif.DescrUnusedLink {
+			continue
+		}
+		if d.Link&sif.DescrGroupMask == sif.DescrGroupMask {
+			signedGroups[d.Link&^sif.DescrGroupMask] = true
+		} else {
+			signedIDs[d.Link] = true
+		}
+	}
+
+	var objects []SIFObject
+	for _, d := range fimg.DescrArr {
+		if !d.Used {
+			continue
+		}
+
+		o := SIFObject{
+			ID:      d.ID,
+			Type:    typeName(sifDatatypes[d.Datatype], int32(d.Datatype)),
+			Name:    d.GetName(),
+			Offset:  d.Fileoff,
+			Size:    d.Filelen,
+			Created: time.Unix(d.Ctime, 0),
+			Signed:  signedIDs[d.ID],
+		}
+		if d.Groupid != sif.DescrUnusedGroup {
+			o.Group = d.Groupid &^ sif.DescrGroupMask
+			o.Signed = o.Signed || signedGroups[o.Group]
+		}
+		if d.Link != sif.DescrUnusedLink {
+			o.LinkGroup = d.Link&sif.DescrGroupMask == sif.DescrGroupMask
+			o.Link = d.Link &^ sif.DescrGroupMask
+		}
+
+		switch d.Datatype {
+		case sif.DataPartition:
+			if f, err := d.GetFsType(); err == nil {
+				o.FsType = typeName(sifFstypes[f], int32(f))
+			}
+			if p, err := d.GetPartType(); err == nil {
+				o.PartType = typeName(sifParttypes[p], int32(p))
+			}
+			if a, err := d.GetArch(); err == nil {
+				o.Arch = sif.GetGoArch(string(a[:sif.HdrArchLen-1]))
+			}
+		case sif.DataSignature:
+			if h, err := d.GetHashType(); err == nil {
+				o.HashType = typeName(sifHashtypes[h], int32(h))
+			}
+		}
+
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
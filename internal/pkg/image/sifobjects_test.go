@@ -0,0 +1,38 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"testing"
+)
+
+func TestSIFObjects(t *testing.T) {
+	objects, err := SIFObjects("../syecl/testdata/container1.sif")
+	if err != nil {
+		t.Fatalf("unexpected error listing data objects: %v", err)
+	}
+	if len(objects) != 6 {
+		t.Fatalf("unexpected number of data objects %d, expected 6", len(objects))
+	}
+
+	part := objects[0]
+	if part.Type != "partition" || part.FsType != "squashfs" || part.PartType != "primary system" || part.Arch != "amd64" {
+		t.Errorf("unexpected partition %+v", part)
+	}
+	if !part.Signed {
+		t.Errorf("signed partition reported as unsigned")
+	}
+	if objects[1].Type != "deffile" || objects[1].Signed {
+		t.Errorf("unexpected definition file %+v", objects[1])
+	}
+	if sig := objects[2]; sig.Type != "signature" || sig.Link != part.ID || sig.LinkGroup || sig.HashType != "sha384" {
+		t.Errorf("unexpected signature %+v", sig)
+	}
+
+	if _, err := SIFObjects("sifobjects_test.go"); err == nil {
+		t.Errorf("unexpected success with a file which is not a SIF image")
+	}
+}
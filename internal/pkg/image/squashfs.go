@@ -6,13 +6,18 @@
 package image
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"unsafe"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"golang.org/x/sys/unix"
 )
 
 // SQUASHFS defines constant for squashfs format
@@ -25,8 +30,22 @@ const (
 	squashfsLzoComp  = 3
 	squashfsXzComp   = 4
 	squashfsLz4Comp  = 5
+	squashfsZstdComp = 6
 )
 
+var squashfsCompressions = map[uint16]string{
+	squashfsZlib:     "gzip",
+	squashfsLzmaComp: "lzma",
+	squashfsLzoComp:  "lzo",
+	squashfsXzComp:   "xz",
+	squashfsLz4Comp:  "lz4",
+	squashfsZstdComp: "zstd",
+}
+
+// kernelConfigs are the files searched for the configuration of the running
+// kernel, %s is replaced by the kernel release
+var kernelConfigs = []string{"/proc/config.gz", "/boot/config-%s"}
+
 type squashfsInfo struct {
 	Magic       [4]byte
 	Inodes      uint32
@@ -63,22 +82,96 @@ func CheckSquashfsHeader(b []byte) (uint64, error) {
 	}
 
 	if sinfo.Compression != squashfsZlib {
-		compressionType := ""
-		switch sinfo.Compression {
-		case squashfsLzmaComp:
-			compressionType = "lzma"
-		case squashfsLz4Comp:
-			compressionType = "lz4"
-		case squashfsLzoComp:
-			compressionType = "lzo"
-		case squashfsXzComp:
-			compressionType = "xz"
-		}
+		compressionType := squashfsCompressions[sinfo.Compression]
 		sylog.Infof("squashfs image was compressed with %s, if it failed to run, please contact image's author", compressionType)
 	}
 	return offset, nil
 }
 
+// GetSquashfsComp returns the compression algorithm of the squashfs
+// filesystem starting at the beginning of b
+func GetSquashfsComp(b []byte) (string, error) {
+	sinfo := &squashfsInfo{}
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, sinfo); err != nil {
+		return "", fmt.Errorf("can't read squashfs information header")
+	}
+	if bytes.Compare(sinfo.Magic[:], []byte(squashfsMagic)) != 0 {
+		return "", fmt.Errorf("not a valid squashfs image")
+	}
+	comp, ok := squashfsCompressions[sinfo.Compression]
+	if !ok {
+		return "", fmt.Errorf("unknown squashfs compression %d", sinfo.Compression)
+	}
+	return comp, nil
+}
+
+// CheckSquashfsCompSupport returns an error if the running kernel can't
+// mount squashfs filesystems compressed with comp. Only zstd is checked,
+// it requires kernel 4.14 built with CONFIG_SQUASHFS_ZSTD.
+func CheckSquashfsCompSupport(comp string) error {
+	if comp != "zstd" {
+		return nil
+	}
+
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return fmt.Errorf("while getting kernel release: %s", err)
+	}
+	release := string(bytes.TrimRight(uts.Release[:], "\x00"))
+
+	major, minor := 0, 0
+	fmt.Sscanf(release, "%d.%d", &major, &minor)
+	if major < 4 || major == 4 && minor < 14 {
+		return fmt.Errorf("kernel %s doesn't support zstd compressed squashfs images, kernel 4.14 or later is required", release)
+	}
+
+	enabled, err := kernelConfigEnabled(release, "CONFIG_SQUASHFS_ZSTD")
+	if err != nil {
+		// the kernel configuration isn't always installed, assume
+		// recent kernels have zstd support
+		sylog.Debugf("Could not check zstd support of squashfs: %s", err)
+		return nil
+	}
+	if !enabled {
+		return fmt.Errorf("kernel %s was built without zstd support for squashfs (CONFIG_SQUASHFS_ZSTD)", release)
+	}
+	return nil
+}
+
+// kernelConfigEnabled returns whether option is set in the configuration of
+// the kernel release
+func kernelConfigEnabled(release, option string) (bool, error) {
+	for _, config := range kernelConfigs {
+		if strings.Contains(config, "%s") {
+			config = fmt.Sprintf(config, release)
+		}
+		f, err := os.Open(config)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if strings.HasSuffix(config, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return false, fmt.Errorf("while reading %s: %s", config, err)
+			}
+			r = gz
+		}
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == option+"=y" || line == option+"=m" {
+				return true, nil
+			}
+		}
+		return false, scanner.Err()
+	}
+	return false, fmt.Errorf("kernel configuration not found")
+}
+
 func (f *squashfsFormat) initializer(img *Image, fileinfo os.FileInfo) error {
 	if fileinfo.IsDir() {
 		return fmt.Errorf("not a squashfs image")
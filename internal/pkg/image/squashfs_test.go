@@ -0,0 +1,82 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func squashfsHeader(t *testing.T, magic string, comp uint16) []byte {
+	sinfo := squashfsInfo{BlockSize: 131072, Compression: comp}
+	copy(sinfo.Magic[:], magic)
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, sinfo); err != nil {
+		t.Fatalf("failed to write squashfs header: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGetSquashfsComp(t *testing.T) {
+	tests := []struct {
+		header []byte
+		comp   string
+	}{
+		{squashfsHeader(t, squashfsMagic, squashfsZlib), "gzip"},
+		{squashfsHeader(t, squashfsMagic, squashfsXzComp), "xz"},
+		{squashfsHeader(t, squashfsMagic, squashfsZstdComp), "zstd"},
+		{squashfsHeader(t, squashfsMagic, 42), ""},
+		{squashfsHeader(t, "hsqt", squashfsZstdComp), ""},
+		{[]byte(squashfsMagic), ""},
+	}
+	for _, tt := range tests {
+		comp, err := GetSquashfsComp(tt.header)
+		if tt.comp == "" && err == nil {
+			t.Errorf("unexpected success reading compression of header %x", tt.header)
+		} else if comp != tt.comp {
+			t.Errorf("unexpected compression %q, expected %q: %v", comp, tt.comp, err)
+		}
+	}
+}
+
+func TestKernelConfigEnabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kernel-config-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(configs []string) { kernelConfigs = configs }(kernelConfigs)
+	kernelConfigs = []string{filepath.Join(dir, "config.gz"), filepath.Join(dir, "config-%s")}
+
+	if _, err := kernelConfigEnabled("4.18.0", "CONFIG_SQUASHFS_ZSTD"); err == nil {
+		t.Errorf("unexpected success without kernel configuration")
+	}
+
+	config := "CONFIG_SQUASHFS=y\n# CONFIG_SQUASHFS_ZSTD is not set\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "config-4.18.0"), []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write kernel configuration: %v", err)
+	}
+	if enabled, err := kernelConfigEnabled("4.18.0", "CONFIG_SQUASHFS_ZSTD"); err != nil || enabled {
+		t.Errorf("unexpected zstd support in kernel configuration: %v", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("CONFIG_SQUASHFS=m\nCONFIG_SQUASHFS_ZSTD=y\n"))
+	gz.Close()
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.gz"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write kernel configuration: %v", err)
+	}
+	if enabled, err := kernelConfigEnabled("4.18.0", "CONFIG_SQUASHFS_ZSTD"); err != nil || !enabled {
+		t.Errorf("unexpected missing zstd support in compressed kernel configuration: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imgdiff
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	units "github.com/docker/go-units"
+	"github.com/sylabs/singularity/internal/pkg/image"
+)
+
+// extract extracts the squashfs primary partition of the SIF image at path
+// in the directory dir/name, and returns the path of its root filesystem
+func extract(path, dir, name string) (string, error) {
+	rootfs := filepath.Join(dir, name)
+	if err := image.ExtractSIFRootfs(path, rootfs); err != nil {
+		return "", err
+	}
+	return rootfs, nil
+}
+
+// describeFiles returns the descriptions of the files of the root
+// filesystem rootfs, keyed by their absolute path in the container
+func describeFiles(rootfs string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.Walk(rootfs, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootfs, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		desc := fi.Mode().String()
+		switch {
+		case fi.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			h := sha256.New()
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			desc += fmt.Sprintf(" %s sha256:%.12x", units.BytesSize(float64(fi.Size())), h.Sum(nil))
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			desc += " -> " + target
+		}
+		files["/"+rel] = desc
+		return nil
+	})
+	return files, err
+}
+
+// compareFiles returns the files which differ between the root filesystems
+// a and b
+func compareFiles(a, b string) ([]Change, error) {
+	filesA, err := describeFiles(a)
+	if err != nil {
+		return nil, err
+	}
+	filesB, err := describeFiles(b)
+	if err != nil {
+		return nil, err
+	}
+	return compareMaps(filesA, filesB), nil
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package imgdiff compares the labels, definition files, data objects and
+// optionally the files of two SIF images, to review what changed between two
+// versions of an image.
+package imgdiff
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	units "github.com/docker/go-units"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/internal/pkg/image"
+)
+
+// Kinds of changes
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// Change is a label, data object or file which differs between the images
+type Change struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Old and New describe the value in the first and the second image,
+	// they are empty if it is absent from the image
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Report holds the differences between two images
+type Report struct {
+	Labels []Change `json:"labels"`
+	// Deffile holds the lines of the definition files which differ,
+	// prefixed by - for the first image and + for the second one
+	Deffile []string `json:"deffile"`
+	Objects []Change `json:"objects"`
+	// Files is only set if the files of the images were compared
+	Files []Change `json:"files,omitempty"`
+}
+
+// Empty returns whether the images are identical
+func (r *Report) Empty() bool {
+	return len(r.Labels) == 0 && len(r.Deffile) == 0 && len(r.Objects) == 0 && len(r.Files) == 0
+}
+
+// Options selects how images are compared
+type Options struct {
+	// Files compares the files of the squashfs partitions, they are
+	// extracted in a temporary directory created in TmpDir
+	Files  bool
+	TmpDir string
+}
+
+// Compare compares the SIF images at a and b
+func Compare(a, b string, opts Options) (*Report, error) {
+	r := &Report{}
+
+	var rootA, rootB string
+	if opts.Files {
+		dir, err := ioutil.TempDir(opts.TmpDir, "diff-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		if rootA, err = extract(a, dir, "a"); err != nil {
+			return nil, fmt.Errorf("while extracting %s: %s", a, err)
+		}
+		if rootB, err = extract(b, dir, "b"); err != nil {
+			return nil, fmt.Errorf("while extracting %s: %s", b, err)
+		}
+		if r.Files, err = compareFiles(rootA, rootB); err != nil {
+			return nil, err
+		}
+	}
+
+	labelsA, err := readLabels(a, rootA)
+	if err != nil {
+		return nil, err
+	}
+	labelsB, err := readLabels(b, rootB)
+	if err != nil {
+		return nil, err
+	}
+	r.Labels = compareMaps(labelsA, labelsB)
+
+	defA, _, err := metadata.SIFDefinition(a)
+	if err != nil {
+		return nil, err
+	}
+	defB, _, err := metadata.SIFDefinition(b)
+	if err != nil {
+		return nil, err
+	}
+	r.Deffile = diffLines(defA, defB)
+
+	objectsA, err := readObjects(a)
+	if err != nil {
+		return nil, err
+	}
+	objectsB, err := readObjects(b)
+	if err != nil {
+		return nil, err
+	}
+	r.Objects = compareMaps(objectsA, objectsB)
+
+	return r, nil
+}
+
+// readLabels returns the labels of the SIF image at path, from its labels
+// data object or from the labels.json file of its extracted root filesystem
+// rootfs, if not empty
+func readLabels(path, rootfs string) (map[string]string, error) {
+	labels, found, err := metadata.LabelPartition(path)
+	if err != nil {
+		return nil, fmt.Errorf("while reading labels of %s: %s", path, err)
+	} else if found || rootfs == "" {
+		return labels, nil
+	}
+
+	data, err := image.ReadSandboxFile(rootfs, image.MetadataPath("labels.json", ""))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("while decoding labels of %s: %s", path, err)
+	}
+	return labels, nil
+}
+
+// readObjects returns the descriptions of the data objects of the SIF image
+// at path, keyed by type and name. Objects sharing a type and a name are
+// numbered in order of their descriptors.
+func readObjects(path string) (map[string]string, error) {
+	objects, err := image.SIFObjects(path)
+	if err != nil {
+		return nil, fmt.Errorf("while reading data objects of %s: %s", path, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	descriptions := make(map[string]string)
+	for _, o := range objects {
+		key := o.Type
+		if o.PartType != "" {
+			key += " (" + o.PartType + ")"
+		}
+		if o.Name != "" {
+			key += " " + o.Name
+		}
+		base := key
+		for i := 2; descriptions[key] != ""; i++ {
+			key = fmt.Sprintf("%s #%d", base, i)
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(f, o.Offset, o.Size)); err != nil {
+			return nil, fmt.Errorf("while hashing data object %d of %s: %s", o.ID, path, err)
+		}
+		descriptions[key] = fmt.Sprintf("%s sha256:%.12x", units.BytesSize(float64(o.Size)), h.Sum(nil))
+	}
+	return descriptions, nil
+}
+
+// compareMaps returns the changes from a to b, sorted by name
+func compareMaps(a, b map[string]string) []Change {
+	var changes []Change
+	for k, v := range a {
+		if w, ok := b[k]; !ok {
+			changes = append(changes, Change{Kind: Removed, Name: k, Old: v})
+		} else if v != w {
+			changes = append(changes, Change{Kind: Modified, Name: k, Old: v, New: w})
+		}
+	}
+	for k, w := range b {
+		if _, ok := a[k]; !ok {
+			changes = append(changes, Change{Kind: Added, Name: k, New: w})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// diffLines returns the lines removed from a, prefixed by -, and added in b,
+// prefixed by +, in order of the longest common subsequence of their lines
+func diffLines(a, b string) []string {
+	if a == b {
+		return nil
+	}
+	x, y := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+x[i])
+			i++
+		default:
+			lines = append(lines, "+"+y[j])
+			j++
+		}
+	}
+	return lines
+}
+
+// splitLines returns the lines of s, without a trailing empty line
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
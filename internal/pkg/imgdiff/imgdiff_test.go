@@ -0,0 +1,133 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imgdiff
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+)
+
+// copyImage copies the test image src to a temporary file
+func copyImage(t *testing.T, src string) string {
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatalf("failed to open test image: %v", err)
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile("", "diff-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		os.Remove(out.Name())
+		t.Fatalf("failed to copy test image: %v", err)
+	}
+	return out.Name()
+}
+
+func TestCompare(t *testing.T) {
+	a := copyImage(t, "../syecl/testdata/container1.sif")
+	defer os.Remove(a)
+	b := copyImage(t, "../syecl/testdata/container1.sif")
+	defer os.Remove(b)
+
+	r, err := Compare(a, b, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error comparing images: %v", err)
+	}
+	if !r.Empty() {
+		t.Errorf("unexpected differences between identical images: %+v", r)
+	}
+
+	if err := metadata.AddLabelPartition(a, map[string]string{"maintainer": "site", "version": "1.0"}); err != nil {
+		t.Fatalf("failed to add labels: %v", err)
+	}
+	if err := metadata.AddLabelPartition(b, map[string]string{"version": "1.1", "license": "BSD"}); err != nil {
+		t.Fatalf("failed to add labels: %v", err)
+	}
+
+	r, err = Compare(a, b, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error comparing images: %v", err)
+	}
+	expected := []Change{
+		{Kind: Added, Name: "license", New: "BSD"},
+		{Kind: Removed, Name: "maintainer", Old: "site"},
+		{Kind: Modified, Name: "version", Old: "1.0", New: "1.1"},
+	}
+	if !reflect.DeepEqual(r.Labels, expected) {
+		t.Errorf("unexpected label changes %+v, expected %+v", r.Labels, expected)
+	}
+	if len(r.Objects) != 1 || r.Objects[0].Kind != Modified || r.Objects[0].Name != "labels "+metadata.LabelObject {
+		t.Errorf("unexpected data object changes %+v", r.Objects)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected []string
+	}{
+		{"a\nb\n", "a\nb\n", nil},
+		{"", "a\n", []string{"+a"}},
+		{"Bootstrap: docker\nFrom: ubuntu:16.04\n%post\n", "Bootstrap: docker\nFrom: ubuntu:18.04\n%post\napt-get update\n", []string{"-From: ubuntu:16.04", "+From: ubuntu:18.04", "+apt-get update"}},
+	}
+	for _, tt := range tests {
+		if lines := diffLines(tt.a, tt.b); !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("unexpected diff %q of %q and %q, expected %q", lines, tt.a, tt.b, tt.expected)
+		}
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(path, content string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write("a/etc/hostname", "a")
+	write("a/etc/removed", "")
+	write("a/bin/same", "same")
+	write("b/etc/hostname", "b")
+	write("b/bin/same", "same")
+	if err := os.Symlink("same", filepath.Join(dir, "b", "bin", "link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	changes, err := compareFiles(filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatalf("unexpected error comparing files: %v", err)
+	}
+	var names, kinds []string
+	for _, c := range changes {
+		names = append(names, c.Name)
+		kinds = append(kinds, c.Kind)
+	}
+	if expected := []string{"/bin/link", "/etc/hostname", "/etc/removed"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected changed files %v, expected %v", names, expected)
+	}
+	if expected := []string{Added, Modified, Removed}; !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("unexpected changes %v, expected %v", kinds, expected)
+	}
+}
@@ -210,15 +210,23 @@ func (i *File) Update() error {
 	return nil
 }
 
+// GetLogFilePaths returns the stdout and stderr log file paths of
+// instance name
+func GetLogFilePaths(name string) (string, string, error) {
+	path, err := getPath(false, "")
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(path, name+".out"), filepath.Join(path, name+".err"), nil
+}
+
 // SetLogFile replaces stdout/stderr streams and redirect content
 // to log file
 func SetLogFile(name string, uid int) (*os.File, *os.File, error) {
-	path, err := getPath(false, "")
+	stdoutPath, stderrPath, err := GetLogFilePaths(name)
 	if err != nil {
 		return nil, nil, err
 	}
-	stderrPath := filepath.Join(path, name+".err")
-	stdoutPath := filepath.Join(path, name+".out")
 
 	oldumask := syscall.Umask(0)
 	defer syscall.Umask(oldumask)
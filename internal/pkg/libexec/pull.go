@@ -6,6 +6,8 @@
 package libexec
 
 import (
+	"context"
+
 	"github.com/sylabs/singularity/internal/pkg/build"
 	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -45,7 +47,7 @@ func PullOciImage(path, uri string, opts types.Options) {
 		sylog.Fatalf("Unable to pull %v: %v", uri, err)
 	}
 
-	if err := b.Full(); err != nil {
+	if err := b.Full(context.Background()); err != nil {
 		sylog.Fatalf("Unable to pull %v: %v", uri, err)
 	}
 }
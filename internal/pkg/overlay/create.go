@@ -0,0 +1,119 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// minSize is the smallest overlay image mkfs.ext3 creates with a journal
+const minSize = 1 << 20
+
+// Create creates a sparse EXT3 overlay image of size bytes at path, holding
+// the upper and work directories used for persistent overlays. The dirs are
+// created in the upper directory, so they appear in the container, and the
+// filesystem is owned by the calling user, which can then write to it from
+// unprivileged containers.
+func Create(path string, size int64, dirs []string) error {
+	if size < minSize {
+		return fmt.Errorf("overlay size must be at least %d bytes", minSize)
+	}
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("directory %s must be an absolute path", dir)
+		}
+	}
+
+	tmpdir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	for _, dir := range append([]string{"/upper", "/work"}, dirs...) {
+		if dir != "/upper" && dir != "/work" {
+			dir = filepath.Join("/upper", dir)
+		}
+		if err := os.MkdirAll(filepath.Join(tmpdir, dir), 0755); err != nil {
+			return fmt.Errorf("while creating directory %s: %s", dir, err)
+		}
+	}
+
+	sylog.Debugf("Creating EXT3 overlay of %d bytes in %s with directories %s", size, path, strings.Join(dirs, ", "))
+	return mkfs(path, size, tmpdir)
+}
+
+// CreateFrom creates an EXT3 overlay image at path holding the upper and
+// work directories of layer, like the directory of a persistent overlay,
+// so its changes can be moved to an image. A size of 0 sizes the image for
+// the content of layer with as much room left for new changes.
+func CreateFrom(path string, size int64, layer string) error {
+	for _, dir := range []string{"upper", "work"} {
+		if fi, err := os.Stat(filepath.Join(layer, dir)); err != nil || !fi.IsDir() {
+			return fmt.Errorf("%s has no %s directory", layer, dir)
+		}
+	}
+	if size == 0 {
+		used, err := diskUsage(layer)
+		if err != nil {
+			return fmt.Errorf("while computing size of %s: %s", layer, err)
+		}
+		size = 2*used + minSize
+	}
+	if size < minSize {
+		return fmt.Errorf("overlay size must be at least %d bytes", minSize)
+	}
+
+	sylog.Debugf("Creating EXT3 overlay of %d bytes in %s from %s", size, path, layer)
+	return mkfs(path, size, layer)
+}
+
+// mkfs creates a sparse EXT3 filesystem of size bytes at path with the
+// content of the directory root, whose top directory is owned by the
+// calling user
+func mkfs(path string, size int64, root string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %s", path, err)
+	}
+	err = f.Truncate(size)
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("while allocating %s: %s", path, err)
+	}
+
+	owner := fmt.Sprintf("root_owner=%d:%d", os.Getuid(), os.Getgid())
+	if _, err := run("mkfs.ext3", "-F", "-q", "-E", owner, "-d", root, path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// diskUsage returns the bytes used by the files of dir, counting a block
+// for each file and directory
+func diskUsage(dir string) (int64, error) {
+	const block = 4096
+	var used int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		used += block
+		if fi.Mode().IsRegular() {
+			used += (fi.Size() + block - 1) / block * block
+		}
+		return nil
+	})
+	return used, err
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package overlay provides helpers to manage persistent EXT3 overlay images,
+// either standalone or embedded as an overlay partition inside a SIF image.
+package overlay
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ParseSize converts a human readable size like 512M or 4G into a number
+// of bytes. A size without suffix is interpreted as a number of bytes.
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(s, "B")
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	case 'T':
+		mult = 1 << 40
+	}
+	if mult != 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * mult, nil
+}
+
+// extPath returns the path given to e2fsprogs tools to access an EXT3
+// filesystem starting at offset in file path
+func extPath(path string, offset uint64) string {
+	if offset == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s?offset=%d", path, offset)
+}
+
+// run executes an e2fsprogs command and returns its exit code along with
+// its output on failure
+func run(name string, args ...string) (int, error) {
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return -1, fmt.Errorf("%s not found in PATH: %s", name, err)
+	}
+	out, err := exec.Command(p, args...).CombinedOutput()
+	if err != nil {
+		code := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				code = status.ExitStatus()
+			}
+		}
+		return code, fmt.Errorf("%s failed: %s: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return 0, nil
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected int64
+		fail     bool
+	}{
+		{"1024", 1024, false},
+		{"64K", 64 << 10, false},
+		{"512M", 512 << 20, false},
+		{"512mb", 512 << 20, false},
+		{"4G", 4 << 30, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"G", 0, true},
+		{"-1G", 0, true},
+		{"4X", 0, true},
+	}
+
+	for _, tt := range tests {
+		size, err := ParseSize(tt.size)
+		if tt.fail && err == nil {
+			t.Errorf("unexpected success for size %q", tt.size)
+		} else if !tt.fail && err != nil {
+			t.Errorf("unexpected failure for size %q: %s", tt.size, err)
+		} else if size != tt.expected {
+			t.Errorf("size %q: got %d instead of %d", tt.size, size, tt.expected)
+		}
+	}
+}
+
+func TestExtPath(t *testing.T) {
+	if p := extPath("/tmp/overlay.img", 0); p != "/tmp/overlay.img" {
+		t.Errorf("unexpected path %s", p)
+	}
+	if p := extPath("/tmp/image.sif", 4096); p != "/tmp/image.sif?offset=4096" {
+		t.Errorf("unexpected path %s", p)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	if _, err := exec.LookPath("mkfs.ext3"); err != nil {
+		t.Skip("mkfs.ext3 not found")
+	}
+	dir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "overlay.img")
+	if err := Create(path, 64<<20, []string{"/data", "/opt/app"}); err != nil {
+		t.Fatalf("unexpected error creating overlay: %s", err)
+	}
+	if err := Create(path, 64<<20, nil); err == nil {
+		t.Errorf("unexpected success overwriting overlay")
+	}
+	if err := Create(filepath.Join(dir, "relative.img"), 64<<20, []string{"data"}); err == nil {
+		t.Errorf("unexpected success with a relative directory")
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 64<<20 {
+		t.Errorf("unexpected overlay size %d", fi.Size())
+	}
+	if err := checkFs(path); err != nil {
+		t.Errorf("unexpected filesystem error: %s", err)
+	}
+
+	debugfs, err := exec.LookPath("debugfs")
+	if err != nil {
+		return
+	}
+	for _, d := range []string{"/upper/data", "/upper/opt/app", "/work"} {
+		out, err := exec.Command(debugfs, "-R", "stat "+d, path).CombinedOutput()
+		if err != nil || !strings.Contains(string(out), "Type: directory") {
+			t.Errorf("directory %s not found in overlay: %s", d, out)
+		}
+		if !strings.Contains(string(out), fmt.Sprintf("User: %5d", os.Getuid())) {
+			t.Errorf("unexpected owner of directory %s: %s", d, out)
+		}
+	}
+}
+
+func TestCreateFrom(t *testing.T) {
+	if _, err := exec.LookPath("mkfs.ext3"); err != nil {
+		t.Skip("mkfs.ext3 not found")
+	}
+	dir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	layer := filepath.Join(dir, "layer")
+	if err := CreateFrom(filepath.Join(dir, "missing.img"), 0, layer); err == nil {
+		t.Errorf("unexpected success without upper and work directories")
+	}
+	for _, d := range []string{"upper/data", "work"} {
+		if err := os.MkdirAll(filepath.Join(layer, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(layer, "upper", "data", "file"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "overlay.img")
+	if err := CreateFrom(path, 0, layer); err != nil {
+		t.Fatalf("unexpected error creating overlay: %s", err)
+	}
+	if err := CreateFrom(path, 0, layer); err == nil {
+		t.Errorf("unexpected success overwriting overlay")
+	}
+	if err := checkFs(path); err != nil {
+		t.Errorf("unexpected filesystem error: %s", err)
+	}
+
+	debugfs, err := exec.LookPath("debugfs")
+	if err != nil {
+		return
+	}
+	out, err := exec.Command(debugfs, "-R", "cat /upper/data/file", path).Output()
+	if err != nil || string(out) != "changed" {
+		t.Errorf("unexpected content of file in overlay: %q (%v)", out, err)
+	}
+}
+
+func TestAddToSIF(t *testing.T) {
+	if _, err := exec.LookPath("mkfs.ext3"); err != nil {
+		t.Skip("mkfs.ext3 not found")
+	}
+	dir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := ioutil.ReadFile("../syecl/testdata/container1.sif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "image.sif")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AddToSIF(path, 8<<20, []string{"/data"}); err != nil {
+		t.Fatalf("unexpected error adding overlay: %s", err)
+	}
+	if err := AddToSIF(path, 8<<20, nil); err == nil {
+		t.Errorf("unexpected success adding a second overlay")
+	}
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fimg.UnloadContainer()
+
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	descrs, _, err := fimg.GetPartFromGroup(part.Groupid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, desc := range descrs {
+		ptype, _ := desc.GetPartType()
+		fstype, _ := desc.GetFsType()
+		if ptype == sif.PartOverlay && fstype == sif.FsExt3 {
+			found = desc.Filelen == 8<<20
+			if err := checkFs(extPath(path, uint64(desc.Fileoff))); err != nil {
+				t.Errorf("unexpected filesystem error: %s", err)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("overlay partition not found in %s", path)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/image"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// Resize grows the EXT3 overlay found at path to size bytes. Path is either
+// a standalone EXT3 image or a SIF image containing an overlay partition,
+// in which case the overlay partition must be the last data object of the
+// image to be resized in place.
+func Resize(path string, size int64) error {
+	img, err := image.Init(path, true)
+	if err != nil {
+		return fmt.Errorf("could not open image %s: %s", path, err)
+	}
+	defer img.File.Close()
+
+	if !img.Writable {
+		return fmt.Errorf("image %s is not writable", path)
+	}
+
+	switch img.Type {
+	case image.EXT3:
+		return resizeExt3(img, size)
+	case image.SIF:
+		return resizeSIF(img, size)
+	}
+	return fmt.Errorf("image %s is neither an EXT3 overlay nor a SIF image", path)
+}
+
+func resizeExt3(img *image.Image, size int64) error {
+	if uint64(size) <= img.Size {
+		return fmt.Errorf("new size must be greater than current size (%d bytes)", img.Size)
+	}
+
+	sylog.Debugf("Growing %s from %d to %d bytes", img.Path, img.Size, size)
+	if err := img.File.Truncate(int64(img.Offset) + size); err != nil {
+		return fmt.Errorf("while growing %s: %s", img.Path, err)
+	}
+
+	return resizeFs(img.File, img.Path, img.Offset, size)
+}
+
+func resizeSIF(img *image.Image, size int64) error {
+	fimg, err := sif.LoadContainer(img.Path, true)
+	if err != nil {
+		return fmt.Errorf("while loading SIF image: %s", err)
+	}
+	header := fimg.Header
+	filesize := fimg.Filesize
+	descrs := fimg.DescrArr
+	fimg.UnloadContainer()
+
+	index := -1
+	for i, desc := range descrs {
+		if !desc.Used || desc.Datatype != sif.DataPartition {
+			continue
+		}
+		ptype, err := desc.GetPartType()
+		if err != nil || ptype != sif.PartOverlay {
+			continue
+		}
+		if fstype, err := desc.GetFsType(); err == nil && fstype == sif.FsExt3 {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return fmt.Errorf("no EXT3 overlay partition found in %s", img.Path)
+	}
+
+	desc := &descrs[index]
+	if size <= desc.Filelen {
+		return fmt.Errorf("new size must be greater than current size (%d bytes)", desc.Filelen)
+	}
+	if desc.Fileoff+desc.Filelen != filesize {
+		return fmt.Errorf("overlay partition is not the last data object of %s, it can't be resized in place", img.Path)
+	}
+
+	sylog.Debugf("Growing overlay partition %d from %d to %d bytes", desc.ID, desc.Filelen, size)
+	if err := img.File.Truncate(desc.Fileoff + size); err != nil {
+		return fmt.Errorf("while growing %s: %s", img.Path, err)
+	}
+
+	grow := size - desc.Filelen
+	desc.Filelen = size
+	desc.Storelen += grow
+	desc.Mtime = time.Now().Unix()
+	header.Datalen += grow
+	header.Mtime = desc.Mtime
+
+	if err := writeDescriptor(img.File, header.Descroff, index, desc); err != nil {
+		return err
+	}
+	if _, err := img.File.Seek(0, 0); err != nil {
+		return fmt.Errorf("while seeking to SIF header: %s", err)
+	}
+	if err := binary.Write(img.File, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("while writing SIF header: %s", err)
+	}
+	if err := img.File.Sync(); err != nil {
+		return fmt.Errorf("while syncing %s: %s", img.Path, err)
+	}
+
+	return resizeFs(img.File, img.Path, uint64(desc.Fileoff), size)
+}
+
+// writeDescriptor writes back the descriptor at index in the descriptor table
+func writeDescriptor(f *os.File, descroff int64, index int, desc *sif.Descriptor) error {
+	offset := descroff + int64(index*binary.Size(desc))
+	if _, err := f.Seek(offset, 0); err != nil {
+		return fmt.Errorf("while seeking to SIF descriptor: %s", err)
+	}
+	if err := binary.Write(f, binary.LittleEndian, desc); err != nil {
+		return fmt.Errorf("while writing SIF descriptor: %s", err)
+	}
+	return nil
+}
+
+// resizeFs checks and grows the EXT3 filesystem at offset to fill size bytes
+func resizeFs(f *os.File, path string, offset uint64, size int64) error {
+	fs := extPath(path, offset)
+
+	if err := checkFs(fs); err != nil {
+		return err
+	}
+	if _, err := run("resize2fs", fs, strconv.FormatInt(size/1024, 10)+"K"); err != nil {
+		return err
+	}
+
+	// resize2fs truncates regular files to the filesystem size without
+	// taking the offset into account, restore the expected file size
+	if offset > 0 {
+		if err := f.Truncate(int64(offset) + size); err != nil {
+			return fmt.Errorf("while restoring size of %s: %s", path, err)
+		}
+		return checkFs(fs)
+	}
+	return nil
+}
+
+// checkFs runs a forced filesystem check, e2fsck exit code 1 means that
+// errors were corrected and is not considered as a failure
+func checkFs(fs string) error {
+	if code, err := run("e2fsck", "-f", "-y", fs); err != nil && code != 1 {
+		return err
+	}
+	return nil
+}
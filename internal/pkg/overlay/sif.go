@@ -0,0 +1,88 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// AddToSIF adds an EXT3 overlay partition of size bytes to the SIF image at
+// path, in the group of its primary system partition so the runtime mounts
+// it with the image. The overlay is created like with Create.
+func AddToSIF(path string, size int64, dirs []string) error {
+	tmpdir, err := ioutil.TempDir(filepath.Dir(path), ".overlay-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	overlay := filepath.Join(tmpdir, "overlay.img")
+	if err := Create(overlay, size, dirs); err != nil {
+		return err
+	}
+	return AddImageToSIF(path, overlay)
+}
+
+// AddImageToSIF adds the EXT3 overlay image at overlay as the overlay
+// partition of the SIF image at path, in the group of its primary system
+// partition so the runtime mounts it with the image
+func AddImageToSIF(path, overlay string) error {
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return fmt.Errorf("while loading SIF image %s: %s", path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return fmt.Errorf("while searching for primary partition of %s: %s", path, err)
+	}
+	descrs, _, err := fimg.GetPartFromGroup(part.Groupid)
+	if err != nil {
+		return fmt.Errorf("while searching for partitions of %s: %s", path, err)
+	}
+	for _, desc := range descrs {
+		if ptype, err := desc.GetPartType(); err == nil && ptype == sif.PartOverlay {
+			return fmt.Errorf("%s already has an overlay partition", path)
+		}
+	}
+	arch, err := part.GetArch()
+	if err != nil {
+		return fmt.Errorf("while reading architecture of %s: %s", path, err)
+	}
+
+	fi, err := os.Stat(overlay)
+	if err != nil {
+		return err
+	}
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  part.Groupid,
+		Link:     sif.DescrUnusedLink,
+		Fname:    overlay,
+		Size:     fi.Size(),
+	}
+	if input.Fp, err = os.Open(overlay); err != nil {
+		return fmt.Errorf("while opening overlay: %s", err)
+	}
+	defer input.Fp.Close()
+
+	if err := input.SetPartExtra(sif.FsExt3, sif.PartOverlay, string(arch[:sif.HdrArchLen-1])); err != nil {
+		return err
+	}
+
+	sylog.Debugf("Adding EXT3 overlay partition of %d bytes to %s", input.Size, path)
+	if err := fimg.AddObject(input); err != nil {
+		return fmt.Errorf("while adding overlay partition to %s: %s", path, err)
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/imgbuild"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc/server"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 // Engine is the combination of an EngineOperations and a config.Common. The singularity
@@ -95,7 +96,11 @@ var (
 func ServeRuntimeEngineRequests(name string, conn net.Conn) {
 	methods := registeredEngineRPCMethods[name]
 	rpc.RegisterName(name, methods)
-	rpc.ServeConn(conn)
+	codec, err := server.NewServerCodec(conn)
+	if err != nil {
+		sylog.Fatalf("Failed to serve RPC requests: %s", err)
+	}
+	rpc.ServeCodec(codec)
 }
 
 // Init initializes registered runtime engines
@@ -7,6 +7,7 @@ package imgbuild
 
 import (
 	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/sylabs/singularity/internal/pkg/network"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/oci"
 )
 
@@ -18,4 +19,7 @@ const Name = "imgbuild"
 type EngineConfig struct {
 	types.Bundle `json:"bundle"`
 	OciConfig    *oci.Config `json:"ociConfig"`
+	// CNIPath locates the CNI configuration and plugins of the bridge network
+	CNIPath *network.CNIPath `json:"cniPath,omitempty"`
+	Network *network.Setup   `json:"-"`
 }
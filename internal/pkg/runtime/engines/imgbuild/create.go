@@ -12,11 +12,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/network"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc/client"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
 )
 
 // CreateContainer creates a container
@@ -49,55 +53,55 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 		return fmt.Errorf("failed to resolved session directory %s: %s", buildcfg.SESSIONDIR, err)
 	}
 
-	sylog.Debugf("Mounting image directory %s\n", rootfs)
+	engineLog.Debugf("Mounting image directory %s\n", rootfs)
 	_, err = rpcOps.Mount(rootfs, sessionPath, "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_NODEV, "errors=remount-ro")
 	if err != nil {
 		return fmt.Errorf("failed to mount directory filesystem %s: %s", rootfs, err)
 	}
 
-	sylog.Debugf("Mounting proc at %s\n", filepath.Join(sessionPath, "proc"))
+	engineLog.Debugf("Mounting proc at %s\n", filepath.Join(sessionPath, "proc"))
 	_, err = rpcOps.Mount("/proc", filepath.Join(sessionPath, "proc"), "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount proc failed: %s", err)
 	}
 
-	sylog.Debugf("Mounting sysfs at %s\n", filepath.Join(sessionPath, "sys"))
+	engineLog.Debugf("Mounting sysfs at %s\n", filepath.Join(sessionPath, "sys"))
 	_, err = rpcOps.Mount("sysfs", filepath.Join(sessionPath, "sys"), "sysfs", syscall.MS_NOSUID, "")
 	if err != nil {
 		return fmt.Errorf("mount sys failed: %s", err)
 	}
 
-	sylog.Debugf("Mounting dev at %s\n", filepath.Join(sessionPath, "dev"))
+	engineLog.Debugf("Mounting dev at %s\n", filepath.Join(sessionPath, "dev"))
 	_, err = rpcOps.Mount("/dev", filepath.Join(sessionPath, "dev"), "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount /dev failed: %s", err)
 	}
 
-	sylog.Debugf("Mounting tmp at %s\n", filepath.Join(sessionPath, "tmp"))
+	engineLog.Debugf("Mounting tmp at %s\n", filepath.Join(sessionPath, "tmp"))
 	_, err = rpcOps.Mount("/tmp", filepath.Join(sessionPath, "tmp"), "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount /tmp failed: %s", err)
 	}
 
-	sylog.Debugf("Mounting var/tmp at %s\n", filepath.Join(sessionPath, "var/tmp"))
+	engineLog.Debugf("Mounting var/tmp at %s\n", filepath.Join(sessionPath, "var/tmp"))
 	_, err = rpcOps.Mount("/var/tmp", filepath.Join(sessionPath, "var/tmp"), "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount /var/tmp failed: %s", err)
 	}
 
-	sylog.Debugf("Mounting /etc/resolv.conf at %s\n", filepath.Join(sessionPath, "etc/resolv.conf"))
+	engineLog.Debugf("Mounting /etc/resolv.conf at %s\n", filepath.Join(sessionPath, "etc/resolv.conf"))
 	_, err = rpcOps.Mount("/etc/resolv.conf", filepath.Join(sessionPath, "etc/resolv.conf"), "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount /etc/resolv.conf failed: %s", err)
 	}
 
-	sylog.Debugf("Mounting /etc/hosts at %s\n", filepath.Join(sessionPath, "etc/hosts"))
+	engineLog.Debugf("Mounting /etc/hosts at %s\n", filepath.Join(sessionPath, "etc/hosts"))
 	_, err = rpcOps.Mount("/etc/hosts", filepath.Join(sessionPath, "etc/hosts"), "", syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount /etc/hosts failed: %s", err)
 	}
 
-	sylog.Debugf("Set RPC mount propagation flag to SLAVE")
+	engineLog.Debugf("Set RPC mount propagation flag to SLAVE")
 	_, err = rpcOps.Mount("", "/", "", syscall.MS_SLAVE|syscall.MS_REC, "")
 	if err != nil {
 		return fmt.Errorf("mount /etc/hosts failed: %s", err)
@@ -110,39 +114,45 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 		setup.Stdout = os.Stdout
 		setup.Stderr = os.Stderr
 
-		sylog.Infof("Running setup scriptlet\n")
+		engineLog.Infof("Running setup scriptlet\n")
+		start := time.Now()
 		if err := setup.Start(); err != nil {
-			sylog.Fatalf("failed to start %%setup proc: %v\n", err)
+			engineLog.Fatalf("failed to start %%setup proc: %v\n", err)
 		}
 		if err := setup.Wait(); err != nil {
-			sylog.Fatalf("setup proc: %v\n", err)
+			engineLog.Fatalf("setup proc: %v\n", err)
 		}
+		engine.EngineConfig.recordTiming("setup", start)
 	}
 
 	if engine.EngineConfig.RunSection("files") {
-		sylog.Debugf("Copying files from host")
+		engineLog.Debugf("Copying files from host")
+		start := time.Now()
 		if err := engine.EngineConfig.copyFiles(); err != nil {
 			return fmt.Errorf("unable to copy files to container fs: %v", err)
 		}
+		if len(engine.EngineConfig.Recipe.BuildData.Files) > 0 {
+			engine.EngineConfig.recordTiming("files", start)
+		}
 	}
 
-	sylog.Debugf("Chdir into %s\n", sessionPath)
+	engineLog.Debugf("Chdir into %s\n", sessionPath)
 	err = syscall.Chdir(sessionPath)
 	if err != nil {
 		return fmt.Errorf("change directory failed: %s", err)
 	}
 
-	sylog.Debugf("Chroot into %s\n", sessionPath)
+	engineLog.Debugf("Chroot into %s\n", sessionPath)
 	_, err = rpcOps.Chroot(sessionPath, true)
 	if err != nil {
-		sylog.Debugf("Fallback to move/chroot")
+		engineLog.Debugf("Fallback to move/chroot")
 		_, err = rpcOps.Chroot(sessionPath, false)
 		if err != nil {
 			return fmt.Errorf("chroot failed: %s", err)
 		}
 	}
 
-	sylog.Debugf("Chdir into / to avoid errors\n")
+	engineLog.Debugf("Chdir into / to avoid errors\n")
 	err = syscall.Chdir("/")
 	if err != nil {
 		return fmt.Errorf("change directory failed: %s", err)
@@ -151,26 +161,66 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 		return fmt.Errorf("can't close connection with rpc server: %s", err)
 	}
 
+	if engine.EngineConfig.Opts.Network == types.NetworkBridge {
+		if err := engine.setupNetwork(pid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupNetwork connects the network namespace of the container process to
+// the bridge network
+func (engine *EngineOperations) setupNetwork(pid int) error {
+	/* hold a reference to container network namespace for cleanup */
+	f, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return fmt.Errorf("can't open network namespace: %s", err)
+	}
+	nspath := fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd())
+
+	setup, err := network.NewSetup([]string{types.NetworkBridge}, strconv.Itoa(pid), nspath, engine.EngineConfig.CNIPath)
+	if err != nil {
+		return err
+	}
+	engineLog.Debugf("Setting up %s network\n", types.NetworkBridge)
+	if err := setup.AddNetworks(); err != nil {
+		return fmt.Errorf("failed to set up %s network: %s", types.NetworkBridge, err)
+	}
+	engine.EngineConfig.Network = setup
 	return nil
 }
 
+// recordTiming records the timing of a phase run on the host in the timings
+// file of the container
+func (e *EngineConfig) recordTiming(phase string, start time.Time) {
+	path := filepath.Join(e.Rootfs(), types.TimingsPath)
+	if err := types.RecordPhaseTiming(path, phase, start); err != nil {
+		engineLog.Warningf("failed to record %%%s timing: %s", phase, err)
+	}
+}
+
 func (e *EngineConfig) copyFiles() error {
 	// iterate through filetransfers
 	for _, transfer := range e.Recipe.BuildData.Files {
 		// sanity
 		if transfer.Src == "" {
-			sylog.Warningf("Attempt to copy file with no name...")
+			engineLog.Warningf("Attempt to copy file with no name...")
 			continue
 		}
 		// dest = source if not specified
 		if transfer.Dst == "" {
 			transfer.Dst = transfer.Src
 		}
-		sylog.Infof("Copying %v to %v", transfer.Src, transfer.Dst)
+		engineLog.Infof("Copying %v to %v", transfer.Src, transfer.Dst)
 		// copy each file into bundle rootfs
 		transfer.Dst = filepath.Join(e.Rootfs(), transfer.Dst)
-		copy := exec.Command("/bin/cp", "-fLr", transfer.Src, transfer.Dst)
-		if err := copy.Run(); err != nil {
+		if err := files.Copy(transfer.Src, transfer.Dst, files.CopyOptions{
+			Exclude:        transfer.Exclude,
+			PreserveOwner:  transfer.PreserveOwner,
+			PreserveXattrs: transfer.PreserveXattrs,
+		}); err != nil {
 			return fmt.Errorf("While copying %v to %v: %v", transfer.Src, transfer.Dst, err)
 		}
 	}
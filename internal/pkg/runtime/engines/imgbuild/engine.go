@@ -12,9 +12,12 @@ import (
 
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config/starter"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/capabilities"
 )
 
+var engineLog = sylog.Subsystem("engine")
+
 // EngineOperations implements the engines.EngineOperations interface for
 // the image build process
 type EngineOperations struct {
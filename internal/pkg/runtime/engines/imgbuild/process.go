@@ -6,16 +6,22 @@
 package imgbuild
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/opencontainers/runtime-tools/generate"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/build/types"
 	"github.com/sylabs/singularity/internal/pkg/util/env"
+	"github.com/vishvananda/netlink"
 )
 
 // StartProcess runs the %post script
@@ -24,6 +30,13 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 	// clean environment in which %post and %test scripts are run in
 	e.EngineConfig.cleanEnv()
 
+	switch e.EngineConfig.Opts.Network {
+	case types.NetworkNone, types.NetworkBridge:
+		if err := setLoopbackUp(); err != nil {
+			return fmt.Errorf("failed to set up loopback interface: %s", err)
+		}
+	}
+
 	if e.EngineConfig.RunSection("post") && e.EngineConfig.Recipe.BuildData.Post != "" {
 		// Run %post script here
 		post := exec.Command("/bin/sh", "-cex", e.EngineConfig.Recipe.BuildData.Post)
@@ -31,28 +44,33 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 		post.Stdout = os.Stdout
 		post.Stderr = os.Stderr
 
-		sylog.Infof("Running post scriptlet\n")
+		engineLog.Infof("Running post scriptlet\n")
+		start := time.Now()
 		if err := post.Start(); err != nil {
-			sylog.Fatalf("failed to start %%post proc: %v\n", err)
+			engineLog.Fatalf("failed to start %%post proc: %v\n", err)
 		}
 		if err := post.Wait(); err != nil {
-			sylog.Fatalf("post proc: %v\n", err)
+			engineLog.Fatalf("post proc: %v\n", err)
+		}
+		if err := types.RecordPhaseTiming(types.TimingsPath, "post", start); err != nil {
+			engineLog.Warningf("failed to record %%post timing: %s", err)
 		}
 	}
 
-	if e.EngineConfig.RunSection("test") {
-		if !e.EngineConfig.Opts.NoTest && e.EngineConfig.Recipe.BuildData.Test != "" {
-			// Run %test script
-			test := exec.Command("/bin/sh", "-cex", e.EngineConfig.Recipe.BuildData.Test)
-			test.Stdout = os.Stdout
-			test.Stderr = os.Stderr
-
-			sylog.Infof("Running test scriptlet\n")
-			if err := test.Start(); err != nil {
-				sylog.Fatalf("failed to start %%test proc: %v\n", err)
+	if e.EngineConfig.RunSection("test") && !e.EngineConfig.Opts.NoTest {
+		if tests := e.EngineConfig.Recipe.BuildData.BuildTests(); len(tests) > 0 {
+			// the report is written before exiting so that the build can
+			// record it even if tests failed
+			report := runTests(tests)
+			if err := writeTestReport(types.TestReportPath, &report); err != nil {
+				engineLog.Warningf("failed to write test report: %s", err)
 			}
-			if err := test.Wait(); err != nil {
-				sylog.Fatalf("test proc: %v\n", err)
+			if err := types.RecordPhaseTiming(types.TimingsPath, "test", report.Started); err != nil {
+				engineLog.Warningf("failed to record %%test timing: %s", err)
+			}
+
+			if failed := report.Failed(); len(failed) > 0 {
+				engineLog.Fatalf("%d of %d tests failed: %s\n", len(failed), len(report.Tests), strings.Join(failed, ", "))
 			}
 		}
 	}
@@ -83,8 +101,13 @@ func (e *EngineOperations) MonitorContainer(pid int, signals chan os.Signal) (sy
 	}
 }
 
-// CleanupContainer _
+// CleanupContainer removes the bridge network of the container, if any
 func (e *EngineOperations) CleanupContainer() error {
+	if e.EngineConfig.Network != nil {
+		if err := e.EngineConfig.Network.DelNetworks(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -93,6 +116,88 @@ func (e *EngineOperations) PostStartProcess(pid int) error {
 	return nil
 }
 
+// setLoopbackUp brings up the loopback interface of the network namespace
+// created for the scripts
+func setLoopbackUp() error {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetUp(lo)
+}
+
+// runTests runs every test script, whatever the result of the previous
+// ones, and returns their results
+func runTests(tests []types.TestScript) types.TestReport {
+	report := types.TestReport{Passed: true, Started: time.Now()}
+
+	for _, t := range tests {
+		test := exec.Command("/bin/sh", "-cex", t.Script)
+		output := &tailBuffer{size: types.TestReportOutputSize}
+		test.Stdout = io.MultiWriter(os.Stdout, output)
+		test.Stderr = io.MultiWriter(os.Stderr, output)
+
+		result := types.TestResult{
+			Name:    t.Name,
+			Command: t.Script,
+			Started: time.Now(),
+		}
+
+		engineLog.Infof("Running test %s\n", t.Name)
+		err := test.Run()
+
+		result.Duration = time.Since(result.Started).Seconds()
+		result.Passed = err == nil
+		if test.ProcessState != nil {
+			result.ExitCode = test.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+		} else {
+			result.ExitCode = -1
+			fmt.Fprintf(output, "failed to start test: %v\n", err)
+		}
+		result.Output = output.String()
+		if err != nil {
+			engineLog.Warningf("test %s failed: %v", t.Name, err)
+		}
+
+		report.Passed = report.Passed && result.Passed
+		report.Tests = append(report.Tests, result)
+	}
+
+	report.Duration = time.Since(report.Started).Seconds()
+	return report
+}
+
+// writeTestReport writes the test report to path, types.TestReportPath in
+// the container metadata directory
+func writeTestReport(path string, report *types.TestReport) error {
+	b, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// tailBuffer keeps the last size bytes written to it
+type tailBuffer struct {
+	size int
+	buf  []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.size {
+		t.buf = t.buf[len(t.buf)-t.size:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
+
 func (e *EngineConfig) cleanEnv() {
 	generator := generate.Generator{Config: &e.OciConfig.Spec}
 
@@ -103,7 +208,7 @@ func (e *EngineConfig) cleanEnv() {
 	e.OciConfig.Spec.Process.Env = nil
 
 	// add relevant environment variables back
-	env.SetContainerEnv(&generator, environment, true, "")
+	env.SetContainerEnv(&generator, environment, true, env.Filter{}, "")
 
 	// expose build specific environment variables for scripts
 	for _, envVar := range environment {
@@ -0,0 +1,136 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imgbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"net"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/types"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		writes []string
+		want   string
+	}{
+		{"empty", 4, nil, ""},
+		{"under size", 8, []string{"abc", "de"}, "abcde"},
+		{"exact size", 5, []string{"abc", "de"}, "abcde"},
+		{"over size", 4, []string{"abc", "def"}, "cdef"},
+		{"single large write", 3, []string{"abcdefgh"}, "fgh"},
+	}
+	for _, tt := range tests {
+		buf := &tailBuffer{size: tt.size}
+		for _, w := range tt.writes {
+			if n, err := buf.Write([]byte(w)); err != nil || n != len(w) {
+				t.Errorf("%s: unexpected write result %d, %v", tt.name, n, err)
+			}
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	report := runTests([]types.TestScript{
+		{Name: "pass", Script: "echo passed"},
+		{Name: "fail", Script: "echo failed; exit 3"},
+		{Name: "long", Script: "head -c 8192 /dev/zero | tr '\\0' x"},
+	})
+
+	if report.Passed {
+		t.Errorf("report passed with a failing test")
+	}
+	if len(report.Tests) != 3 {
+		t.Fatalf("got %d results, want 3", len(report.Tests))
+	}
+	if !reflect.DeepEqual(report.Failed(), []string{"fail"}) {
+		t.Errorf("unexpected failed tests %v", report.Failed())
+	}
+	if r := report.Tests[1]; r.ExitCode != 3 || !strings.Contains(r.Output, "failed") {
+		t.Errorf("unexpected result for failing test: %+v", r)
+	}
+	if r := report.Tests[2]; len(r.Output) != types.TestReportOutputSize {
+		t.Errorf("output excerpt is %d bytes, want %d", len(r.Output), types.TestReportOutputSize)
+	}
+}
+
+func TestWriteTestReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-report-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := &types.TestReport{
+		Passed: false,
+		Tests:  []types.TestResult{{Name: "test", Command: "false", ExitCode: 1}},
+	}
+	path := filepath.Join(dir, types.TestReportPath)
+	if err := writeTestReport(path, report); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &types.TestReport{}
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatalf("unexpected error while decoding report: %s", err)
+	}
+	if !reflect.DeepEqual(got, report) {
+		t.Errorf("got report %+v, want %+v", got, report)
+	}
+}
+
+func TestSetLoopbackUp(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("creating a network namespace requires root")
+	}
+
+	errs := make(chan error)
+	go func() {
+		// the thread is left in the new namespace, it exits with the
+		// goroutine as it is never unlocked
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			errs <- err
+			return
+		}
+		if err := setLoopbackUp(); err != nil {
+			errs <- err
+			return
+		}
+		lo, err := netlink.LinkByName("lo")
+		if err != nil {
+			errs <- err
+			return
+		}
+		if lo.Attrs().Flags&net.FlagUp == 0 {
+			errs <- fmt.Errorf("loopback interface is down")
+			return
+		}
+		errs <- nil
+	}()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -8,12 +8,15 @@ package singularity
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
 
 	"github.com/sylabs/singularity/internal/pkg/instance"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/syplugin"
 )
 
 /*
@@ -23,7 +26,7 @@ import (
 
 // CleanupContainer cleans up the container
 func (engine *EngineOperations) CleanupContainer() error {
-	sylog.Debugf("Cleanup container")
+	engineLog.Debugf("Cleanup container")
 
 	if engine.EngineConfig.Network != nil {
 		if err := engine.EngineConfig.Network.DelNetworks(); err != nil {
@@ -31,9 +34,17 @@ func (engine *EngineOperations) CleanupContainer() error {
 		}
 	}
 
+	if err := engine.unmountImageDriver(); err != nil {
+		engineLog.Errorf("%s", err)
+	}
+
+	if err := engine.closeCryptDevices(); err != nil {
+		engineLog.Errorf("%s", err)
+	}
+
 	if engine.EngineConfig.Cgroups != nil {
 		if err := engine.EngineConfig.Cgroups.Remove(); err != nil {
-			sylog.Errorf("%s", err)
+			engineLog.Errorf("%s", err)
 		}
 	}
 
@@ -70,3 +81,72 @@ func (engine *EngineOperations) CleanupContainer() error {
 
 	return nil
 }
+
+// unmountImageDriver unmounts the filesystems mounted by the image driver in
+// reverse order, which lets drivers stop the processes serving them, like
+// FUSE daemons. All targets are attempted, the first error is returned.
+func (engine *EngineOperations) unmountImageDriver() error {
+	targets := engine.EngineConfig.ImageDriverMounts
+	if len(targets) == 0 {
+		return nil
+	}
+	name := engine.EngineConfig.File.ImageDriver
+	driver, err := syplugin.GetImageDriver(name)
+	if err != nil {
+		return err
+	}
+
+	var first error
+	for i := len(targets) - 1; i >= 0; i-- {
+		if err := driver.Unmount(targets[i]); err != nil {
+			engineLog.Debugf("Image driver %s failed to unmount %s: %s", name, targets[i], err)
+			if first == nil {
+				first = fmt.Errorf("image driver %s failed to unmount %s: %s", name, targets[i], err)
+			}
+		}
+	}
+	engine.EngineConfig.ImageDriverMounts = nil
+	return first
+}
+
+// closeCryptDevices closes the dm-crypt mappings opened for the container in
+// reverse order. Unlike loop devices they aren't released with the last
+// mount of the container, and the RPC server is gone, so privileges are
+// escalated to run cryptsetup. Mappings already closed when mounts were
+// unwound are skipped. All mappings are attempted, the first error is
+// returned.
+func (engine *EngineOperations) closeCryptDevices() error {
+	devices := engine.EngineConfig.CryptDevices
+	if len(devices) == 0 {
+		return nil
+	}
+	cryptsetup, err := CryptsetupPath(engine.EngineConfig.File)
+	if err != nil {
+		return fmt.Errorf("failed to close encrypted devices: %s", err)
+	}
+
+	var first error
+	uid := os.Getuid()
+	mainthread.Execute(func() {
+		if err := syscall.Setresuid(0, 0, uid); err != nil {
+			first = fmt.Errorf("failed to escalate privileges to close encrypted devices: %s", err)
+			return
+		}
+		defer syscall.Setresuid(uid, uid, 0)
+
+		for i := len(devices) - 1; i >= 0; i-- {
+			if _, err := os.Stat(devices[i]); os.IsNotExist(err) {
+				continue
+			}
+			out, err := exec.Command(cryptsetup, "close", filepath.Base(devices[i])).CombinedOutput()
+			if err != nil {
+				engineLog.Debugf("Failed to close encrypted device %s: %s", devices[i], err)
+				if first == nil {
+					first = fmt.Errorf("failed to close encrypted device %s: %s: %s", devices[i], err, strings.TrimSpace(string(out)))
+				}
+			}
+		}
+	})
+	engine.EngineConfig.CryptDevices = nil
+	return first
+}
@@ -19,6 +19,11 @@ const Name = "singularity"
 type FileConfig struct {
 	AllowSetuid             bool     `default:"yes" authorized:"yes,no" directive:"allow setuid"`
 	MaxLoopDevices          uint     `default:"256" directive:"max loop devices"`
+	LoopDeviceRange         string   `directive:"loop device range"`
+	LoopDirectIO            bool     `default:"no" authorized:"yes,no" directive:"loop direct io"`
+	LoopBlockSize           uint     `default:"0" directive:"loop block size"`
+	LoopReadAhead           uint     `default:"0" directive:"loop read ahead"`
+	ImageDriver             string   `directive:"image driver"`
 	AllowPidNs              bool     `default:"yes" authorized:"yes,no" directive:"allow pid ns"`
 	ConfigPasswd            bool     `default:"yes" authorized:"yes,no" directive:"config passwd"`
 	ConfigGroup             bool     `default:"yes" authorized:"yes,no" directive:"config group"`
@@ -49,6 +54,8 @@ type FileConfig struct {
 	CniConfPath             string   `directive:"cni configuration path"`
 	CniPluginPath           string   `directive:"cni plugin path"`
 	MksquashfsPath          string   `directive:"mksquashfs path"`
+	CryptsetupPath          string   `directive:"cryptsetup path"`
+	RequireBootstrapGPG     bool     `default:"no" authorized:"yes,no" directive:"require bootstrap gpg"`
 }
 
 // JSONConfig stores engine specific confguration that is allowed to be set by the user
@@ -91,6 +98,7 @@ type JSONConfig struct {
 	TargetUID     int           `json:"targetUID,omitempty"`
 	TargetGID     []int         `json:"targetGID,omitempty"`
 	LibrariesPath []string      `json:"librariesPath,omitempty"`
+	KeyFile       string        `json:"keyFile,omitempty"`
 }
 
 // EngineConfig stores both the JSONConfig and the FileConfig
@@ -100,6 +108,12 @@ type EngineConfig struct {
 	File      *FileConfig      `json:"-"`
 	Network   *network.Setup   `json:"-"`
 	Cgroups   *cgroups.Manager `json:"-"`
+	// ImageDriverMounts are the targets mounted by the image driver, in
+	// order
+	ImageDriverMounts []string `json:"-"`
+	// CryptDevices are the decrypted devices opened for the container, in
+	// order
+	CryptDevices []string `json:"-"`
 }
 
 // NewConfig returns singularity.EngineConfig with a parsed FileConfig
@@ -484,3 +498,15 @@ func (e *EngineConfig) SetLibrariesPath(libraries []string) {
 func (e *EngineConfig) GetLibrariesPath() []string {
 	return e.JSON.LibrariesPath
 }
+
+// SetEncryptionKeyFile sets the path of the key file decrypting encrypted
+// image partitions
+func (e *EngineConfig) SetEncryptionKeyFile(path string) {
+	e.JSON.KeyFile = path
+}
+
+// GetEncryptionKeyFile returns the path of the key file decrypting encrypted
+// image partitions
+func (e *EngineConfig) GetEncryptionKeyFile() string {
+	return e.JSON.KeyFile
+}
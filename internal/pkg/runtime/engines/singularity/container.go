@@ -6,12 +6,14 @@
 package singularity
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -21,7 +23,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/image"
 	"github.com/sylabs/singularity/internal/pkg/network"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc/client"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/syplugin"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
 	"github.com/sylabs/singularity/internal/pkg/util/fs/layout"
@@ -146,17 +148,19 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 		return err
 	}
 
-	sylog.Debugf("Mount all")
+	engineLog.Debugf("Mount all")
 	if err := system.MountAll(); err != nil {
+		c.unwindMounts()
 		return err
 	}
 
-	sylog.Debugf("Chroot into %s\n", c.session.FinalPath())
+	engineLog.Debugf("Chroot into %s\n", c.session.FinalPath())
 	_, err = c.rpcOps.Chroot(c.session.FinalPath(), true)
 	if err != nil {
-		sylog.Debugf("Fallback to move/chroot")
+		engineLog.Debugf("Fallback to move/chroot")
 		_, err = c.rpcOps.Chroot(c.session.FinalPath(), false)
 		if err != nil {
+			c.unwindMounts()
 			return fmt.Errorf("chroot failed: %s", err)
 		}
 	}
@@ -207,7 +211,7 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 		}
 	}
 
-	sylog.Debugf("Chdir into / to avoid errors\n")
+	engineLog.Debugf("Chdir into / to avoid errors\n")
 	err = syscall.Chdir("/")
 	if err != nil {
 		return fmt.Errorf("change directory failed: %s", err)
@@ -216,7 +220,30 @@ func create(engine *EngineOperations, rpcOps *client.RPC, pid int) error {
 	return nil
 }
 
-func (c *container) setupWritableSIFImage(img *image.Image, overlayEnabled bool) error {
+// unwindMounts tears down the mounts made for the container in reverse order
+// after a failed setup, along with the encrypted devices opened for it.
+// Mounts of the image driver are unmounted first. It must be called before
+// the chroot, mounts of a container set up successfully are released with
+// its mount namespace.
+func (c *container) unwindMounts() {
+	if err := c.engine.unmountImageDriver(); err != nil {
+		engineLog.Warningf("%s", err)
+	}
+	if entries, err := c.rpcOps.ListMounts(); err == nil {
+		for i := len(entries) - 1; i >= 0; i-- {
+			engineLog.Debugf("Unwinding mount of %s on %s", entries[i].Source, entries[i].Target)
+		}
+	}
+	n, err := c.rpcOps.UnwindMounts()
+	if err != nil {
+		engineLog.Warningf("Failed to unwind container mounts: %s", err)
+	}
+	engineLog.Debugf("Unwound %d mounts", n)
+}
+
+// setupSIFOverlay adds the EXT3 overlay partition of the SIF image img to the
+// overlay images, writable if the container image is writable
+func (c *container) setupSIFOverlay(img *image.Image, overlayEnabled bool) error {
 	fimg, err := sif.LoadContainerFp(img.File, !img.Writable)
 	if err != nil {
 		return err
@@ -258,7 +285,7 @@ func (c *container) setupWritableSIFImage(img *image.Image, overlayEnabled bool)
 					imgCopy.Offset = uint64(desc.Fileoff)
 					imgCopy.Size = uint64(desc.Filelen)
 					imgCopy.RootFS = false
-					imgCopy.Writable = true
+					imgCopy.Writable = img.Writable
 
 					imglist := c.engine.EngineConfig.GetImageList()
 					imglist = append(imglist, imgCopy)
@@ -275,7 +302,7 @@ func (c *container) setupWritableSIFImage(img *image.Image, overlayEnabled bool)
 	}
 
 	if overlayPart > 1 {
-		sylog.Warningf("more than one writable overlay partition found, taking the first")
+		engineLog.Warningf("more than one writable overlay partition found, taking the first")
 	} else if overlayPart == 0 {
 		return fmt.Errorf("no overlay partition found")
 	}
@@ -310,39 +337,48 @@ func (c *container) setupSessionLayout(system *mount.System) error {
 		}
 
 		if imgObject.Type == image.SIF {
-			err = c.setupWritableSIFImage(imgObject, overlayEnabled)
+			err = c.setupSIFOverlay(imgObject, overlayEnabled)
 			if err == nil {
 				return c.setupOverlayLayout(system, sessionPath)
 			}
-			sylog.Warningf("%s", err)
+			engineLog.Warningf("%s", err)
 		} else {
-			sylog.Debugf("Image is writable, not attempting to use overlay or underlay\n")
+			engineLog.Debugf("Image is writable, not attempting to use overlay or underlay\n")
 		}
 
 		return c.setupDefaultLayout(system, sessionPath)
 	}
 
 	if overlayEnabled {
-		sylog.Debugf("Attempting to use overlayfs (enable overlay = %v)\n", c.engine.EngineConfig.File.EnableOverlay)
+		// the overlay partition of SIF images is mounted read-only
+		// without --writable
+		imgObject, err := c.loadImage(c.engine.EngineConfig.GetImage(), true)
+		if err == nil && imgObject.Type == image.SIF {
+			if err := c.setupSIFOverlay(imgObject, overlayEnabled); err != nil {
+				engineLog.Debugf("Not using SIF overlay partition: %s", err)
+			}
+		}
+
+		engineLog.Debugf("Attempting to use overlayfs (enable overlay = %v)\n", c.engine.EngineConfig.File.EnableOverlay)
 		return c.setupOverlayLayout(system, sessionPath)
 	}
 
 	if writableTmpfs {
-		sylog.Warningf("Ignoring --writable-tmpfs as it requires overlay support")
+		engineLog.Warningf("Ignoring --writable-tmpfs as it requires overlay support")
 	}
 
 	if c.engine.EngineConfig.File.EnableUnderlay {
-		sylog.Debugf("Attempting to use underlay (enable underlay = yes)\n")
+		engineLog.Debugf("Attempting to use underlay (enable underlay = yes)\n")
 		return c.setupUnderlayLayout(system, sessionPath)
 	}
 
-	sylog.Debugf("Not attempting to use underlay or overlay\n")
+	engineLog.Debugf("Not attempting to use underlay or overlay\n")
 	return c.setupDefaultLayout(system, sessionPath)
 }
 
 // setupOverlayLayout sets up the session with overlay filesystem
 func (c *container) setupOverlayLayout(system *mount.System, sessionPath string) (err error) {
-	sylog.Debugf("Creating overlay SESSIONDIR layout\n")
+	engineLog.Debugf("Creating overlay SESSIONDIR layout\n")
 	if c.session, err = layout.NewSession(sessionPath, c.sessionFsType, c.sessionSize, system, overlay.New()); err != nil {
 		return err
 	}
@@ -357,7 +393,7 @@ func (c *container) setupOverlayLayout(system *mount.System, sessionPath string)
 
 // setupUnderlayLayout sets up the session with underlay "filesystem"
 func (c *container) setupUnderlayLayout(system *mount.System, sessionPath string) (err error) {
-	sylog.Debugf("Creating underlay SESSIONDIR layout\n")
+	engineLog.Debugf("Creating underlay SESSIONDIR layout\n")
 	if c.session, err = layout.NewSession(sessionPath, c.sessionFsType, c.sessionSize, system, underlay.New()); err != nil {
 		return err
 	}
@@ -368,7 +404,7 @@ func (c *container) setupUnderlayLayout(system *mount.System, sessionPath string
 
 // setupDefaultLayout sets up the session without overlay or underlay
 func (c *container) setupDefaultLayout(system *mount.System, sessionPath string) (err error) {
-	sylog.Debugf("Creating default SESSIONDIR layout\n")
+	engineLog.Debugf("Creating default SESSIONDIR layout\n")
 	if c.session, err = layout.NewSession(sessionPath, c.sessionFsType, c.sessionSize, system, nil); err != nil {
 		return err
 	}
@@ -380,7 +416,7 @@ func (c *container) setupDefaultLayout(system *mount.System, sessionPath string)
 // isLayerEnabled returns whether or not overlay or underlay system
 // is enabled
 func (c *container) isLayerEnabled() bool {
-	sylog.Debugf("Using Layer system: %v\n", c.sessionLayerType)
+	engineLog.Debugf("Using Layer system: %v\n", c.sessionLayerType)
 	if c.sessionLayerType == "none" {
 		return false
 	}
@@ -403,7 +439,7 @@ func (c *container) mount(point *mount.Point) error {
 			if point.Type != "" {
 				return fmt.Errorf("can't mount %s filesystem to %s: %s", point.Type, point.Destination, err)
 			}
-			sylog.Verbosef("can't mount %s: %s", point.Source, err)
+			engineLog.Verbosef("can't mount %s: %s", point.Source, err)
 			return nil
 		}
 	}
@@ -414,10 +450,10 @@ func (c *container) setSlaveMount(system *mount.System) error {
 	pflags := uintptr(syscall.MS_REC)
 
 	if c.engine.EngineConfig.File.MountSlave {
-		sylog.Debugf("Set RPC mount propagation flag to SLAVE")
+		engineLog.Debugf("Set RPC mount propagation flag to SLAVE")
 		pflags |= syscall.MS_SLAVE
 	} else {
-		sylog.Debugf("Set RPC mount propagation flag to PRIVATE")
+		engineLog.Debugf("Set RPC mount propagation flag to PRIVATE")
 		pflags |= syscall.MS_PRIVATE
 	}
 
@@ -478,7 +514,7 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 	if flags&syscall.MS_BIND != 0 && !remount {
 		if _, err := os.Stat(source); os.IsNotExist(err) {
 			c.skippedMount = append(c.skippedMount, mnt.Destination)
-			sylog.Debugf("Skipping mount, host source %s doesn't exist", source)
+			engineLog.Debugf("Skipping mount, host source %s doesn't exist", source)
 			return nil
 		}
 	}
@@ -490,7 +526,7 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 
 		if _, err := os.Stat(dest); os.IsNotExist(err) {
 			c.skippedMount = append(c.skippedMount, mnt.Destination)
-			sylog.Debugf("Skipping mount, %s doesn't exist in container", dest)
+			engineLog.Debugf("Skipping mount, %s doesn't exist in container", dest)
 			return nil
 		}
 	} else {
@@ -506,7 +542,12 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 				return nil
 			}
 		}
-		sylog.Debugf("Remounting %s\n", dest)
+		engineLog.Debugf("Remounting %s\n", dest)
+		if flags&^(syscall.MS_BIND|syscall.MS_REMOUNT) == syscall.MS_RDONLY {
+			// keep the flags and options dest was mounted with
+			_, err = c.rpcOps.Remount(dest)
+			return err
+		}
 	} else {
 		// detection of mounted points for underlay layer is not really a simple
 		// task, detection is disabled with this layer for the time being
@@ -514,11 +555,11 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 			mounted := c.checkMounted(dest)
 			if mounted != "" {
 				c.skippedMount = append(c.skippedMount, mnt.Destination)
-				sylog.Debugf("Skipping mount %s, %s already mounted", dest, mounted)
+				engineLog.Debugf("Skipping mount %s, %s already mounted", dest, mounted)
 				return nil
 			}
 		}
-		sylog.Debugf("Mounting %s to %s\n", source, dest)
+		engineLog.Debugf("Mounting %s to %s\n", source, dest)
 
 		// in scontainer stage 1 we changed current working directory to
 		// sandbox image directory, just pass "." as source argument to
@@ -527,20 +568,120 @@ func (c *container) mountGeneric(mnt *mount.Point) (err error) {
 			source = "."
 		}
 
-		// overlay requires root filesystem UID/GID since upper/work
-		// directories are owned by root
 		if mnt.Type == "overlay" {
-			c.rpcOps.SetFsID(0, 0)
-			defer c.rpcOps.SetFsID(os.Getuid(), os.Getgid())
+			driver, err := c.imageDriver(mnt.Type)
+			if err != nil {
+				return err
+			} else if driver != nil {
+				lowerDirs, upperDir, workDir := overlayDirs(opts)
+				paths := append([]string{dest}, lowerDirs...)
+				if upperDir != "" {
+					paths = append(paths, upperDir, workDir)
+				}
+				engineLog.Debugf("Mounting overlay to %s with image driver %s\n", dest, driver.Name())
+				return c.driverMount(driver, paths, &syplugin.ImageMountParams{
+					Target:     dest,
+					Filesystem: mnt.Type,
+					Readonly:   flags&syscall.MS_RDONLY != 0,
+					Data:       optsString,
+				})
+			}
+			return c.mountOverlay(dest, flags, opts)
+		}
+
+		if flags&syscall.MS_BIND != 0 && mnt.Type == "" {
+			// other flags are ignored by bind mounts and applied by the
+			// remount following them
+			recursive := flags&syscall.MS_REC != 0
+			readOnly := flags&syscall.MS_RDONLY != 0
+			propagation := flags & (syscall.MS_SHARED | syscall.MS_SLAVE | syscall.MS_PRIVATE | syscall.MS_UNBINDABLE)
+			if propagation != 0 && recursive {
+				propagation |= syscall.MS_REC
+			}
+			_, err = c.rpcOps.BindMount(source, dest, recursive, readOnly, propagation)
+			return err
 		}
 	}
 	_, err = c.rpcOps.Mount(source, dest, mnt.Type, flags, optsString)
 	return err
 }
 
-// mount image via loop
+// overlayDirs returns the directories of the lowerdir, upperdir and workdir
+// overlay options opts
+func overlayDirs(opts []string) (lowerDirs []string, upperDir, workDir string) {
+	for _, opt := range opts {
+		switch {
+		case strings.HasPrefix(opt, "lowerdir="):
+			lowerDirs = strings.Split(strings.TrimPrefix(opt, "lowerdir="), ":")
+		case strings.HasPrefix(opt, "upperdir="):
+			upperDir = strings.TrimPrefix(opt, "upperdir=")
+		case strings.HasPrefix(opt, "workdir="):
+			workDir = strings.TrimPrefix(opt, "workdir=")
+		}
+	}
+	return lowerDirs, upperDir, workDir
+}
+
+// mountOverlay mounts an overlay on dest with the lowerdir, upperdir and
+// workdir options opts in a single RPC call
+func (c *container) mountOverlay(dest string, flags uintptr, opts []string) error {
+	lowerDirs, upperDir, workDir := overlayDirs(opts)
+	_, err := c.rpcOps.MountOverlay(dest, lowerDirs, upperDir, workDir, flags)
+	return err
+}
+
+// driverMount mounts params with the image driver once paths are checked
+// to be within the session directory, like the RPC server does for its
+// own overlays. The target is recorded to be unmounted by the driver on
+// cleanup.
+func (c *container) driverMount(driver syplugin.ImageDriver, paths []string, params *syplugin.ImageMountParams) error {
+	sessionDir, err := filepath.EvalSymlinks(buildcfg.SESSIONDIR)
+	if err != nil {
+		return fmt.Errorf("failed to resolve session directory %s: %s", buildcfg.SESSIONDIR, err)
+	}
+	for _, path := range paths {
+		if err := layout.CheckSessionPath(sessionDir, path); err != nil {
+			return fmt.Errorf("image driver %s can't mount %s: %s", driver.Name(), params.Target, err)
+		}
+	}
+	if err := driver.Mount(params); err != nil {
+		return err
+	}
+	c.engine.EngineConfig.ImageDriverMounts = append(c.engine.EngineConfig.ImageDriverMounts, params.Target)
+	return nil
+}
+
+var loadImageDrivers sync.Once
+
+// imageDriver returns the image driver set in singularity.conf if it mounts
+// fstype filesystems, or nil if they are mounted with loop devices
+func (c *container) imageDriver(fstype string) (syplugin.ImageDriver, error) {
+	name := c.engine.EngineConfig.File.ImageDriver
+	if name == "" {
+		return nil, nil
+	}
+	loadImageDrivers.Do(syplugin.InitDynamic)
+
+	driver, err := syplugin.GetImageDriver(name)
+	if err != nil {
+		return nil, err
+	}
+	if !driver.Features().Supports(fstype) {
+		return nil, nil
+	}
+	return driver, nil
+}
+
+// mount image via loop, or with the configured image driver
 func (c *container) mountImage(mnt *mount.Point) error {
 	maxDevices := int(c.engine.EngineConfig.File.MaxLoopDevices)
+	var loopRange *loop.Range
+	if r := c.engine.EngineConfig.File.LoopDeviceRange; r != "" {
+		var err error
+		if loopRange, err = loop.ParseRange(r); err != nil {
+			return err
+		}
+	}
 	flags, opts := mount.ConvertOptions(mnt.Options)
 	optsString := strings.Join(opts, ",")
 
@@ -554,6 +695,26 @@ func (c *container) mountImage(mnt *mount.Point) error {
 		return err
 	}
 
+	driver, err := c.imageDriver(mnt.Type)
+	if err != nil {
+		return err
+	} else if driver != nil {
+		engineLog.Debugf("Mounting %s to %s with image driver %s\n", mnt.Source, mnt.Destination, driver.Name())
+		err := c.driverMount(driver, []string{mnt.Destination}, &syplugin.ImageMountParams{
+			Source:     mnt.Source,
+			Offset:     offset,
+			Size:       sizelimit,
+			Target:     mnt.Destination,
+			Filesystem: mnt.Type,
+			Readonly:   flags&syscall.MS_RDONLY != 0,
+			Data:       optsString,
+		})
+		if err != nil {
+			return fmt.Errorf("image driver %s failed to mount %s filesystem: %s", driver.Name(), mnt.Type, err)
+		}
+		return nil
+	}
+
 	attachFlag := os.O_RDWR
 	loopFlags := uint32(loop.FlagsAutoClear)
 
@@ -568,13 +729,29 @@ func (c *container) mountImage(mnt *mount.Point) error {
 		Flags:     loopFlags,
 	}
 
-	number, err := c.rpcOps.LoopDevice(mnt.Source, attachFlag, *info, maxDevices)
+	file := c.engine.EngineConfig.File
+	tuning := loop.Tuning{
+		DirectIO:  file.LoopDirectIO,
+		BlockSize: uint32(file.LoopBlockSize),
+		ReadAhead: uint32(file.LoopReadAhead),
+	}
+
+	number, err := c.rpcOps.LoopDevice(mnt.Source, attachFlag, *info, maxDevices, loopRange, tuning)
 	if err != nil {
 		return fmt.Errorf("failed to find loop device: %s", err)
 	}
 
 	path := fmt.Sprintf("/dev/loop%d", number)
-	sylog.Debugf("Mounting loop device %s to %s\n", path, mnt.Destination)
+	encrypted, err := isEncrypted(mnt.Source, offset)
+	if err != nil {
+		return err
+	} else if encrypted {
+		if path, err = c.openEncrypted(number); err != nil {
+			return err
+		}
+	}
+
+	engineLog.Debugf("Mounting loop device %s to %s\n", path, mnt.Destination)
 	_, err = c.rpcOps.Mount(path, mnt.Destination, mnt.Type, flags, optsString)
 	if err != nil {
 		return fmt.Errorf("failed to mount %s filesystem: %s", mnt.Type, err)
@@ -583,6 +760,57 @@ func (c *container) mountImage(mnt *mount.Point) error {
 	return nil
 }
 
+// openEncrypted opens the encrypted partition attached to the loop device
+// number with the key file set for the container, and returns the path of
+// the decrypted device. The key is sent to the RPC server as a file
+// descriptor, it is never read by the engine.
+func (c *container) openEncrypted(number int) (string, error) {
+	keyFile := c.engine.EngineConfig.GetEncryptionKeyFile()
+	if keyFile == "" {
+		return "", fmt.Errorf("image partition is encrypted, a key file must be specified with --keyfile")
+	}
+	key, err := os.Open(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("while opening key file: %s", err)
+	}
+	defer key.Close()
+
+	path, err := c.rpcOps.CryptOpen(number, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt image partition: %s", err)
+	}
+	c.engine.EngineConfig.CryptDevices = append(c.engine.EngineConfig.CryptDevices, path)
+	engineLog.Debugf("Decrypted loop device %d to %s", number, path)
+	return path, nil
+}
+
+// checkSquashfsComp returns an error if the kernel can't mount the squashfs
+// filesystem of img because of its compression, unless an image driver
+// mounts squashfs filesystems
+func (c *container) checkSquashfsComp(img *image.Image) error {
+	if driver, err := c.imageDriver("squashfs"); err != nil || driver != nil {
+		return err
+	}
+
+	b := make([]byte, 32)
+	if _, err := img.File.ReadAt(b, int64(img.Offset)); err != nil {
+		return fmt.Errorf("while reading squashfs header of %s: %s", img.Path, err)
+	}
+	// the header of encrypted partitions is only readable once decrypted,
+	// the kernel reports unsupported compressions when they are mounted
+	if bytes.HasPrefix(b, luksMagic) {
+		return nil
+	}
+	comp, err := image.GetSquashfsComp(b)
+	if err != nil {
+		return fmt.Errorf("while reading squashfs header of %s: %s", img.Path, err)
+	}
+	if err := image.CheckSquashfsCompSupport(comp); err != nil {
+		return fmt.Errorf("can't mount image %s: %s", img.Path, err)
+	}
+	return nil
+}
+
 func (c *container) loadImage(path string, rootfs bool) (*image.Image, error) {
 	list := c.engine.EngineConfig.GetImageList()
 
@@ -627,10 +855,10 @@ func (c *container) addRootfsMount(system *mount.System) error {
 	}
 
 	if !imageObject.Writable {
-		sylog.Debugf("Mount rootfs in read-only mode")
+		engineLog.Debugf("Mount rootfs in read-only mode")
 		flags |= syscall.MS_RDONLY
 	} else {
-		sylog.Debugf("Mount rootfs in read-write mode")
+		engineLog.Debugf("Mount rootfs in read-write mode")
 	}
 
 	mountType := ""
@@ -669,7 +897,7 @@ func (c *container) addRootfsMount(system *mount.System) error {
 	case image.EXT3:
 		mountType = "ext3"
 	case image.SANDBOX:
-		sylog.Debugf("Mounting directory rootfs: %v\n", rootfs)
+		engineLog.Debugf("Mounting directory rootfs: %v\n", rootfs)
 		flags |= syscall.MS_BIND
 		if err := system.Points.AddBind(mount.RootfsTag, rootfs, c.session.RootFsPath(), flags); err != nil {
 			return err
@@ -680,7 +908,13 @@ func (c *container) addRootfsMount(system *mount.System) error {
 		return nil
 	}
 
-	sylog.Debugf("Mounting block [%v] image: %v\n", mountType, rootfs)
+	if mountType == "squashfs" {
+		if err := c.checkSquashfsComp(imageObject); err != nil {
+			return err
+		}
+	}
+
+	engineLog.Debugf("Mounting block [%v] image: %v\n", mountType, rootfs)
 	return system.Points.AddImage(mount.RootfsTag, imageObject.Source, c.session.RootFsPath(), mountType, flags, imageObject.Offset, imageObject.Size)
 }
 
@@ -720,7 +954,7 @@ func (c *container) addOverlayMount(system *mount.System) error {
 	hasUpper := false
 
 	if c.engine.EngineConfig.GetWritableTmpfs() {
-		sylog.Debugf("Setup writable tmpfs overlay")
+		engineLog.Debugf("Setup writable tmpfs overlay")
 
 		if err := c.session.AddDir("/upper"); err != nil {
 			return err
@@ -831,9 +1065,9 @@ func (c *container) addKernelMount(system *mount.System) error {
 	var err error
 	bindFlags := uintptr(syscall.MS_BIND | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_REC)
 
-	sylog.Debugf("Checking configuration file for 'mount proc'")
+	engineLog.Debugf("Checking configuration file for 'mount proc'")
 	if c.engine.EngineConfig.File.MountProc {
-		sylog.Debugf("Adding proc to mount list\n")
+		engineLog.Debugf("Adding proc to mount list\n")
 		if c.pidNS {
 			err = system.Points.AddFS(mount.KernelTag, "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV, "")
 		} else {
@@ -848,12 +1082,12 @@ func (c *container) addKernelMount(system *mount.System) error {
 			return fmt.Errorf("unable to add proc to mount list: %s", err)
 		}
 	} else {
-		sylog.Verbosef("Skipping /proc mount")
+		engineLog.Verbosef("Skipping /proc mount")
 	}
 
-	sylog.Debugf("Checking configuration file for 'mount sys'")
+	engineLog.Debugf("Checking configuration file for 'mount sys'")
 	if c.engine.EngineConfig.File.MountSys {
-		sylog.Debugf("Adding sysfs to mount list\n")
+		engineLog.Debugf("Adding sysfs to mount list\n")
 		if !c.userNS {
 			err = system.Points.AddFS(mount.KernelTag, "/sys", "sysfs", syscall.MS_NOSUID|syscall.MS_NODEV, "")
 		} else {
@@ -868,7 +1102,7 @@ func (c *container) addKernelMount(system *mount.System) error {
 			return fmt.Errorf("unable to add sys to mount list: %s", err)
 		}
 	} else {
-		sylog.Verbosef("Skipping /sys mount")
+		engineLog.Verbosef("Skipping /sys mount")
 	}
 	return nil
 }
@@ -891,7 +1125,7 @@ func (c *container) addSessionDev(devpath string, system *mount.System) error {
 
 		dst, _ := c.session.GetPath(devpath)
 
-		sylog.Debugf("Adding symlink device %s at %s", devpath, dst)
+		engineLog.Debugf("Adding symlink device %s at %s", devpath, dst)
 
 		return nil
 	case mode.IsDir():
@@ -906,7 +1140,7 @@ func (c *container) addSessionDev(devpath string, system *mount.System) error {
 
 	dst, _ := c.session.GetPath(devpath)
 
-	sylog.Debugf("Mounting device %s at %s", devpath, dst)
+	engineLog.Debugf("Mounting device %s at %s", devpath, dst)
 
 	if err := system.Points.AddBind(mount.DevTag, devpath, dst, syscall.MS_BIND); err != nil {
 		return fmt.Errorf("failed to add %s mount: %s", devpath, err)
@@ -926,14 +1160,14 @@ func (c *container) addSessionDevMount(system *mount.System) error {
 }
 
 func (c *container) addDevMount(system *mount.System) error {
-	sylog.Debugf("Checking configuration file for 'mount dev'")
+	engineLog.Debugf("Checking configuration file for 'mount dev'")
 
 	if c.engine.EngineConfig.File.MountDev == "minimal" || c.engine.EngineConfig.GetContain() {
-		sylog.Debugf("Creating temporary staged /dev")
+		engineLog.Debugf("Creating temporary staged /dev")
 		if err := c.session.AddDir("/dev"); err != nil {
 			return fmt.Errorf("failed to add /dev session directory: %s", err)
 		}
-		sylog.Debugf("Creating temporary staged /dev/shm")
+		engineLog.Debugf("Creating temporary staged /dev/shm")
 		if err := c.session.AddDir("/dev/shm"); err != nil {
 			return fmt.Errorf("failed to add /dev/shm session directory: %s", err)
 		}
@@ -945,7 +1179,7 @@ func (c *container) addDevMount(system *mount.System) error {
 		}
 
 		if c.ipcNS {
-			sylog.Debugf("Creating temporary staged /dev/mqueue")
+			engineLog.Debugf("Creating temporary staged /dev/mqueue")
 			if err := c.session.AddDir("/dev/mqueue"); err != nil {
 				return fmt.Errorf("failed to add /dev/mqueue session directory: %s", err)
 			}
@@ -962,7 +1196,7 @@ func (c *container) addDevMount(system *mount.System) error {
 				return fmt.Errorf("Multiple devpts instances unsupported and /dev/pts configured")
 			}
 
-			sylog.Debugf("Creating temporary staged /dev/pts")
+			engineLog.Debugf("Creating temporary staged /dev/pts")
 			if err := c.session.AddDir("/dev/pts"); err != nil {
 				return fmt.Errorf("failed to /dev/pts session directory: %s", err)
 			}
@@ -976,13 +1210,13 @@ func (c *container) addDevMount(system *mount.System) error {
 				options = fmt.Sprintf("%s,gid=%d", options, group.GID)
 
 			} else {
-				sylog.Debugf("Not setting /dev/pts filesystem gid: user namespace enabled")
+				engineLog.Debugf("Not setting /dev/pts filesystem gid: user namespace enabled")
 			}
-			sylog.Debugf("Mounting devpts for staged /dev/pts")
+			engineLog.Debugf("Mounting devpts for staged /dev/pts")
 			devptsPath, _ := c.session.GetPath("/dev/pts")
 			err = system.Points.AddFS(mount.DevTag, devptsPath, "devpts", syscall.MS_NOSUID|syscall.MS_NOEXEC, options)
 			if err != nil {
-				sylog.Verbosef("Couldn't mount devpts filesystem, continuing with PTY functionality disabled")
+				engineLog.Verbosef("Couldn't mount devpts filesystem, continuing with PTY functionality disabled")
 			} else {
 				if err := c.addSessionDev("/dev/tty", system); err != nil {
 					return err
@@ -1037,20 +1271,20 @@ func (c *container) addDevMount(system *mount.System) error {
 			return err
 		}
 	} else if c.engine.EngineConfig.File.MountDev == "yes" {
-		sylog.Debugf("Adding dev to mount list\n")
+		engineLog.Debugf("Adding dev to mount list\n")
 		err := system.Points.AddBind(mount.DevTag, "/dev", "/dev", syscall.MS_BIND|syscall.MS_REC)
 		if err != nil {
 			return fmt.Errorf("unable to add dev to mount list: %s", err)
 		}
 	} else if c.engine.EngineConfig.File.MountDev == "no" {
-		sylog.Verbosef("Not mounting /dev inside the container, disallowed by configuration")
+		engineLog.Verbosef("Not mounting /dev inside the container, disallowed by configuration")
 	}
 	return nil
 }
 
 func (c *container) addHostMount(system *mount.System) error {
 	if !c.engine.EngineConfig.File.MountHostfs {
-		sylog.Debugf("Not mounting host file systems per configuration")
+		engineLog.Debugf("Not mounting host file systems per configuration")
 		return nil
 	}
 
@@ -1061,25 +1295,25 @@ func (c *container) addHostMount(system *mount.System) error {
 	flags := uintptr(syscall.MS_BIND | c.suidFlag | syscall.MS_NODEV | syscall.MS_REC)
 	for _, child := range info["/"] {
 		if strings.HasPrefix(child, "/proc") {
-			sylog.Debugf("Skipping /proc based file system")
+			engineLog.Debugf("Skipping /proc based file system")
 			continue
 		} else if strings.HasPrefix(child, "/sys") {
-			sylog.Debugf("Skipping /sys based file system")
+			engineLog.Debugf("Skipping /sys based file system")
 			continue
 		} else if strings.HasPrefix(child, "/dev") {
-			sylog.Debugf("Skipping /dev based file system")
+			engineLog.Debugf("Skipping /dev based file system")
 			continue
 		} else if strings.HasPrefix(child, "/run") {
-			sylog.Debugf("Skipping /run based file system")
+			engineLog.Debugf("Skipping /run based file system")
 			continue
 		} else if strings.HasPrefix(child, "/boot") {
-			sylog.Debugf("Skipping /boot based file system")
+			engineLog.Debugf("Skipping /boot based file system")
 			continue
 		} else if strings.HasPrefix(child, "/var") {
-			sylog.Debugf("Skipping /var based file system")
+			engineLog.Debugf("Skipping /var based file system")
 			continue
 		}
-		sylog.Debugf("Adding %s to mount list\n", child)
+		engineLog.Debugf("Adding %s to mount list\n", child)
 		if err := system.Points.AddBind(mount.HostfsTag, child, child, flags); err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", child, err)
 		}
@@ -1092,7 +1326,7 @@ func (c *container) addBindsMount(system *mount.System) error {
 	flags := uintptr(syscall.MS_BIND | c.suidFlag | syscall.MS_NODEV | syscall.MS_REC)
 
 	if c.engine.EngineConfig.GetContain() {
-		sylog.Debugf("Skipping bind mounts as contain was requested")
+		engineLog.Debugf("Skipping bind mounts as contain was requested")
 		return nil
 	}
 
@@ -1106,7 +1340,7 @@ func (c *container) addBindsMount(system *mount.System) error {
 			dst = src
 		}
 
-		sylog.Verbosef("Found 'bind path' = %s, %s", src, dst)
+		engineLog.Verbosef("Found 'bind path' = %s, %s", src, dst)
 		err := system.Points.AddBind(mount.BindsTag, src, dst, flags)
 		if err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", src, err)
@@ -1144,14 +1378,14 @@ func (c *container) addHomeStagingDir(system *mount.System, source string, dest
 	homeStage, _ = c.session.GetPath(dest)
 
 	if !c.engine.EngineConfig.GetContain() || c.engine.EngineConfig.GetCustomHome() {
-		sylog.Debugf("Staging home directory (%v) at %v\n", source, homeStage)
+		engineLog.Debugf("Staging home directory (%v) at %v\n", source, homeStage)
 
 		if err := system.Points.AddBind(mount.HomeTag, source, homeStage, flags); err != nil {
 			return "", fmt.Errorf("unable to add %s to mount list: %s", source, err)
 		}
 		system.Points.AddRemount(mount.HomeTag, homeStage, flags)
 	} else {
-		sylog.Debugf("Using session directory for home directory")
+		engineLog.Debugf("Using session directory for home directory")
 	}
 
 	return homeStage, nil
@@ -1179,7 +1413,7 @@ func (c *container) addHomeNoLayer(system *mount.System, source, dest string) er
 	}
 
 	homeStageBase, _ := c.session.GetPath(homeBase)
-	sylog.Verbosef("Mounting staged home directory base (%v) into container at %v\n", homeStageBase, filepath.Join(c.session.FinalPath(), homeBase))
+	engineLog.Verbosef("Mounting staged home directory base (%v) into container at %v\n", homeStageBase, filepath.Join(c.session.FinalPath(), homeBase))
 	if err := system.Points.AddBind(mount.FinalTag, homeStageBase, homeBase, flags); err != nil {
 		return fmt.Errorf("unable to add %s to mount list: %s", homeStageBase, err)
 	}
@@ -1190,12 +1424,12 @@ func (c *container) addHomeNoLayer(system *mount.System, source, dest string) er
 // addHomeMount is responsible for adding the home directory mount using the proper method
 func (c *container) addHomeMount(system *mount.System) error {
 	if c.engine.EngineConfig.GetNoHome() {
-		sylog.Debugf("Skipping home directory mount by user request.")
+		engineLog.Debugf("Skipping home directory mount by user request.")
 		return nil
 	}
 
 	if !c.engine.EngineConfig.File.MountHome {
-		sylog.Debugf("Skipping home dir mounting (per config)")
+		engineLog.Debugf("Skipping home dir mounting (per config)")
 		return nil
 	}
 
@@ -1214,7 +1448,7 @@ func (c *container) addHomeMount(system *mount.System) error {
 		return err
 	}
 
-	sylog.Debugf("Adding home directory mount [%v:%v] to list using layer: %v\n", stagingDir, dest, c.sessionLayerType)
+	engineLog.Debugf("Adding home directory mount [%v:%v] to list using layer: %v\n", stagingDir, dest, c.sessionLayerType)
 	if !c.isLayerEnabled() {
 		return c.addHomeNoLayer(system, stagingDir, dest)
 	}
@@ -1236,7 +1470,7 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 
 		src, err := filepath.Abs(splitted[0])
 		if err != nil {
-			sylog.Warningf("Can't determine absolute path of %s bind point", splitted[0])
+			engineLog.Warningf("Can't determine absolute path of %s bind point", splitted[0])
 			continue
 		}
 		dst := src
@@ -1247,7 +1481,7 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 			if splitted[2] == "ro" {
 				flags |= syscall.MS_RDONLY
 			} else if splitted[2] != "rw" {
-				sylog.Warningf("Not mounting requested %s bind point, invalid mount option %s", src, splitted[2])
+				engineLog.Warningf("Not mounting requested %s bind point, invalid mount option %s", src, splitted[2])
 			}
 		}
 
@@ -1256,30 +1490,30 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 		if strings.HasPrefix(src, devPrefix) {
 			if c.engine.EngineConfig.File.MountDev == "minimal" || c.engine.EngineConfig.GetContain() {
 				if strings.HasPrefix(src, "/dev/shm/") || strings.HasPrefix(src, "/dev/mqueue/") {
-					sylog.Warningf("Skipping %s bind mount: not allowed", src)
+					engineLog.Warningf("Skipping %s bind mount: not allowed", src)
 				} else {
 					if src != devPrefix {
 						if err := c.addSessionDev(src, system); err != nil {
-							sylog.Warningf("Skipping %s bind mount: %s", src, err)
+							engineLog.Warningf("Skipping %s bind mount: %s", src, err)
 						}
 					} else {
 						system.Points.RemoveByTag(mount.DevTag)
 						c.devSourcePath = devPrefix
 					}
-					sylog.Debugf("Adding device %s to mount list\n", src)
+					engineLog.Debugf("Adding device %s to mount list\n", src)
 				}
 				devicesMounted++
 			} else if c.engine.EngineConfig.File.MountDev == "yes" {
-				sylog.Warningf("Skipping %s bind mount: /dev is already mounted", src)
+				engineLog.Warningf("Skipping %s bind mount: /dev is already mounted", src)
 			} else {
-				sylog.Warningf("Skipping %s bind mount: disallowed by configuration", src)
+				engineLog.Warningf("Skipping %s bind mount: disallowed by configuration", src)
 			}
 			continue
 		} else if !userBindControl {
 			continue
 		}
 
-		sylog.Debugf("Adding %s to mount list\n", src)
+		engineLog.Debugf("Adding %s to mount list\n", src)
 
 		if err := system.Points.AddBind(mount.UserbindsTag, src, dst, flags); err != nil {
 			return fmt.Errorf("unabled to %s to mount list: %s", src, err)
@@ -1288,18 +1522,18 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 		flags &^= syscall.MS_RDONLY
 	}
 
-	sylog.Debugf("Checking for 'user bind control' in configuration file")
+	engineLog.Debugf("Checking for 'user bind control' in configuration file")
 	if !userBindControl && devicesMounted == 0 {
-		sylog.Warningf("Ignoring user bind request: user bind control disabled by system administrator")
+		engineLog.Warningf("Ignoring user bind request: user bind control disabled by system administrator")
 	}
 
 	return nil
 }
 
 func (c *container) addTmpMount(system *mount.System) error {
-	sylog.Debugf("Checking for 'mount tmp' in configuration file")
+	engineLog.Debugf("Checking for 'mount tmp' in configuration file")
 	if !c.engine.EngineConfig.File.MountTmp {
-		sylog.Verbosef("Skipping tmp dir mounting (per config)")
+		engineLog.Verbosef("Skipping tmp dir mounting (per config)")
 		return nil
 	}
 	tmpSource := "/tmp"
@@ -1309,7 +1543,7 @@ func (c *container) addTmpMount(system *mount.System) error {
 		workdir := c.engine.EngineConfig.GetWorkdir()
 		if workdir != "" {
 			if !c.engine.EngineConfig.File.UserBindControl {
-				sylog.Warningf("User bind control is disabled by system administrator")
+				engineLog.Warningf("User bind control is disabled by system administrator")
 				return nil
 			}
 
@@ -1317,7 +1551,7 @@ func (c *container) addTmpMount(system *mount.System) error {
 
 			workdir, err := filepath.Abs(filepath.Clean(workdir))
 			if err != nil {
-				sylog.Warningf("Can't determine absolute path of workdir %s", workdir)
+				engineLog.Warningf("Can't determine absolute path of workdir %s", workdir)
 			}
 
 			tmpSource = workdir + tmpSource
@@ -1364,13 +1598,13 @@ func (c *container) addScratchMount(system *mount.System) error {
 
 	scratchdir := c.engine.EngineConfig.GetScratchDir()
 	if len(scratchdir) == 0 {
-		sylog.Debugf("Not mounting scratch directory: Not requested")
+		engineLog.Debugf("Not mounting scratch directory: Not requested")
 		return nil
 	} else if len(scratchdir) == 1 {
 		scratchdir = strings.Split(filepath.Clean(scratchdir[0]), ",")
 	}
 	if !c.engine.EngineConfig.File.UserBindControl {
-		sylog.Verbosef("Not mounting scratch: user bind control disabled by system administrator")
+		engineLog.Verbosef("Not mounting scratch: user bind control disabled by system administrator")
 		return nil
 	}
 	workdir := c.engine.EngineConfig.GetWorkdir()
@@ -1414,11 +1648,11 @@ func (c *container) addCwdMount(system *mount.System) error {
 	cwd := ""
 
 	if c.engine.EngineConfig.GetContain() {
-		sylog.Verbosef("Not mounting current directory: container was requested")
+		engineLog.Verbosef("Not mounting current directory: container was requested")
 		return nil
 	}
 	if !c.engine.EngineConfig.File.UserBindControl {
-		sylog.Warningf("Not mounting current directory: user bind control is disabled by system administrator")
+		engineLog.Warningf("Not mounting current directory: user bind control is disabled by system administrator")
 		return nil
 	}
 	if c.engine.EngineConfig.OciConfig.Process == nil {
@@ -1426,7 +1660,7 @@ func (c *container) addCwdMount(system *mount.System) error {
 	}
 	cwd = c.engine.EngineConfig.OciConfig.Process.Cwd
 	if err := os.Chdir(cwd); err != nil {
-		sylog.Warningf("Could not set container working directory %s: %s", cwd, err)
+		engineLog.Warningf("Could not set container working directory %s: %s", cwd, err)
 		return nil
 	}
 	current, err := os.Getwd()
@@ -1435,26 +1669,26 @@ func (c *container) addCwdMount(system *mount.System) error {
 	}
 	switch current {
 	case "/", "/etc", "/bin", "/mnt", "/usr", "/var", "/opt", "/sbin":
-		sylog.Verbosef("Not mounting CWD within operating system directory: %s", current)
+		engineLog.Verbosef("Not mounting CWD within operating system directory: %s", current)
 		return nil
 	}
 	if strings.HasPrefix(current, "/sys") || strings.HasPrefix(current, "/proc") || strings.HasPrefix(current, "/dev") {
-		sylog.Verbosef("Not mounting CWD within virtual directory: %s", current)
+		engineLog.Verbosef("Not mounting CWD within virtual directory: %s", current)
 		return nil
 	}
 	flags := uintptr(syscall.MS_BIND | c.suidFlag | syscall.MS_NODEV | syscall.MS_REC)
 	if err := system.Points.AddBind(mount.CwdTag, current, cwd, flags); err == nil {
 		system.Points.AddRemount(mount.CwdTag, cwd, flags)
 	} else {
-		sylog.Warningf("Could not bind CWD to container %s: %s", current, err)
+		engineLog.Warningf("Could not bind CWD to container %s: %s", current, err)
 	}
 	return nil
 }
 
 func (c *container) addLibsMount(system *mount.System) error {
-	sylog.Debugf("Checking for 'user bind control' in configuration file")
+	engineLog.Debugf("Checking for 'user bind control' in configuration file")
 	if !c.engine.EngineConfig.File.UserBindControl {
-		sylog.Warningf("Ignoring libraries bind request: user bind control disabled by system administrator")
+		engineLog.Warningf("Ignoring libraries bind request: user bind control disabled by system administrator")
 		return nil
 	}
 
@@ -1470,7 +1704,7 @@ func (c *container) addLibsMount(system *mount.System) error {
 	libraries := c.engine.EngineConfig.GetLibrariesPath()
 
 	for _, lib := range libraries {
-		sylog.Debugf("Add library %s to mount list", lib)
+		engineLog.Debugf("Add library %s to mount list", lib)
 
 		file := filepath.Base(lib)
 		sessionFile := filepath.Join(sessionDir, file)
@@ -1505,7 +1739,7 @@ func (c *container) addLibsMount(system *mount.System) error {
 
 func (c *container) addIdentityMount(system *mount.System) error {
 	if os.Geteuid() == 0 && c.engine.EngineConfig.GetTargetUID() == 0 {
-		sylog.Verbosef("Not updating passwd/group files, running as root!")
+		engineLog.Verbosef("Not updating passwd/group files, running as root!")
 		return nil
 	}
 
@@ -1521,18 +1755,18 @@ func (c *container) addIdentityMount(system *mount.System) error {
 		passwd := filepath.Join(rootfs, "/etc/passwd")
 		_, home, err := c.getHomePaths()
 		if err != nil {
-			sylog.Warningf("%s", err)
+			engineLog.Warningf("%s", err)
 		} else {
 			content, err := files.Passwd(passwd, home, uid)
 			if err != nil {
-				sylog.Warningf("%s", err)
+				engineLog.Warningf("%s", err)
 			} else {
 				if err := c.session.AddFile("/etc/passwd", content); err != nil {
-					sylog.Warningf("failed to add passwd session file: %s", err)
+					engineLog.Warningf("failed to add passwd session file: %s", err)
 				}
 				passwd, _ = c.session.GetPath("/etc/passwd")
 
-				sylog.Debugf("Adding /etc/passwd to mount list\n")
+				engineLog.Debugf("Adding /etc/passwd to mount list\n")
 				err = system.Points.AddBind(mount.FilesTag, passwd, "/etc/passwd", syscall.MS_BIND)
 				if err != nil {
 					return fmt.Errorf("unable to add /etc/passwd to mount list: %s", err)
@@ -1540,28 +1774,28 @@ func (c *container) addIdentityMount(system *mount.System) error {
 			}
 		}
 	} else {
-		sylog.Verbosef("Skipping bind of the host's /etc/passwd")
+		engineLog.Verbosef("Skipping bind of the host's /etc/passwd")
 	}
 
 	if c.engine.EngineConfig.File.ConfigGroup {
 		group := filepath.Join(rootfs, "/etc/group")
 		content, err := files.Group(group, uid, c.engine.EngineConfig.GetTargetGID())
 		if err != nil {
-			sylog.Warningf("%s", err)
+			engineLog.Warningf("%s", err)
 		} else {
 			if err := c.session.AddFile("/etc/group", content); err != nil {
-				sylog.Warningf("failed to add group session file: %s", err)
+				engineLog.Warningf("failed to add group session file: %s", err)
 			}
 			group, _ = c.session.GetPath("/etc/group")
 
-			sylog.Debugf("Adding /etc/group to mount list\n")
+			engineLog.Debugf("Adding /etc/group to mount list\n")
 			err = system.Points.AddBind(mount.FilesTag, group, "/etc/group", syscall.MS_BIND)
 			if err != nil {
 				return fmt.Errorf("unable to add /etc/group to mount list: %s", err)
 			}
 		}
 	} else {
-		sylog.Verbosef("Skipping bind of the host's /etc/group")
+		engineLog.Verbosef("Skipping bind of the host's /etc/group")
 	}
 
 	return nil
@@ -1593,17 +1827,17 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 			}
 		}
 		if err := c.session.AddFile(resolvConf, content); err != nil {
-			sylog.Warningf("failed to add resolv.conf session file: %s", err)
+			engineLog.Warningf("failed to add resolv.conf session file: %s", err)
 		}
 		sessionFile, _ := c.session.GetPath(resolvConf)
 
-		sylog.Debugf("Adding %s to mount list\n", resolvConf)
+		engineLog.Debugf("Adding %s to mount list\n", resolvConf)
 		err = system.Points.AddBind(mount.FilesTag, sessionFile, resolvConf, syscall.MS_BIND)
 		if err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", resolvConf, err)
 		}
 	} else {
-		sylog.Verbosef("Skipping bind of the host's %s", resolvConf)
+		engineLog.Verbosef("Skipping bind of the host's %s", resolvConf)
 	}
 	return nil
 }
@@ -1614,7 +1848,7 @@ func (c *container) addHostnameMount(system *mount.System) error {
 	if c.utsNS {
 		hostname := c.engine.EngineConfig.GetHostname()
 		if hostname != "" {
-			sylog.Debugf("Set container hostname %s", hostname)
+			engineLog.Debugf("Set container hostname %s", hostname)
 
 			content, err := files.Hostname(hostname)
 			if err != nil {
@@ -1625,7 +1859,7 @@ func (c *container) addHostnameMount(system *mount.System) error {
 			}
 			sessionFile, _ := c.session.GetPath(hostnameFile)
 
-			sylog.Debugf("Adding %s to mount list\n", hostnameFile)
+			engineLog.Debugf("Adding %s to mount list\n", hostnameFile)
 			err = system.Points.AddBind(mount.FilesTag, sessionFile, hostnameFile, syscall.MS_BIND)
 			if err != nil {
 				return fmt.Errorf("unable to add %s to mount list: %s", hostnameFile, err)
@@ -1635,7 +1869,7 @@ func (c *container) addHostnameMount(system *mount.System) error {
 			}
 		}
 	} else {
-		sylog.Debugf("Skipping hostname mount, not virtualizing UTS namespace on user request")
+		engineLog.Debugf("Skipping hostname mount, not virtualizing UTS namespace on user request")
 	}
 	return nil
 }
@@ -1647,7 +1881,7 @@ func (c *container) addActionsMount(system *mount.System) error {
 
 	actionsDir := filepath.Join(c.session.RootFsPath(), containerDir)
 	if !fs.IsDir(actionsDir) {
-		sylog.Debugf("Ignoring actions mount, %s doesn't exist", actionsDir)
+		engineLog.Debugf("Ignoring actions mount, %s doesn't exist", actionsDir)
 		return nil
 	}
 
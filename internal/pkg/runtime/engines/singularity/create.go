@@ -11,9 +11,12 @@ import (
 	"net/rpc"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc/client"
 )
@@ -25,7 +28,7 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 	}
 
 	if engine.EngineConfig.GetInstanceJoin() {
-		return nil
+		return joinInstanceCgroup(engine, pid)
 	}
 
 	configurationFile := buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
@@ -33,8 +36,13 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 		return fmt.Errorf("Unable to parse singularity.conf file: %s", err)
 	}
 
+	conn, err := client.NewFileConn(rpcConn)
+	if err != nil {
+		return err
+	}
 	rpcOps := &client.RPC{
-		Client: rpc.NewClient(rpcConn),
+		Client: rpc.NewClient(conn),
+		Conn:   conn,
 		Name:   engine.CommonConfig.EngineName,
 	}
 	if rpcOps.Client == nil {
@@ -85,3 +93,23 @@ func (engine *EngineOperations) CreateContainer(pid int, rpcConn net.Conn) error
 
 	return create(engine, rpcOps, pid)
 }
+
+// joinInstanceCgroup places the process joining an instance in the cgroup
+// of the instance, if any, so that it is subject to the same ressources
+// restriction
+func joinInstanceCgroup(engine *EngineOperations, pid int) error {
+	if engine.EngineConfig.GetCgroupsPath() == "" || os.Geteuid() != 0 {
+		return nil
+	}
+
+	file, err := instance.Get(instance.ExtractName(engine.EngineConfig.GetImage()))
+	if err != nil {
+		return err
+	}
+
+	manager := &cgroups.Manager{Pid: pid, Name: strconv.Itoa(file.Pid)}
+	if err := manager.Join(); err != nil {
+		return fmt.Errorf("failed to join instance cgroup: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// systemDirs are the directories searched for cryptsetup when its path is
+// not set in singularity.conf
+var systemDirs = []string{"/bin", "/usr/bin", "/usr/local/bin", "/sbin", "/usr/sbin", "/usr/local/sbin"}
+
+// CryptsetupPath returns the absolute path of cryptsetup, set by the
+// cryptsetup path directive of singularity.conf or searched in the system
+// directories. The environment of privileged processes is cleared by the
+// starter and the PATH of users must not be trusted anyway.
+func CryptsetupPath(c *FileConfig) (string, error) {
+	if c.CryptsetupPath != "" {
+		p := c.CryptsetupPath
+		if !strings.HasSuffix(p, "cryptsetup") {
+			p = filepath.Join(p, "cryptsetup")
+		}
+		if !filepath.IsAbs(p) {
+			return "", fmt.Errorf("cryptsetup path %s is not absolute", p)
+		}
+		return exec.LookPath(p)
+	}
+	for _, dir := range systemDirs {
+		if p, err := exec.LookPath(filepath.Join(dir, "cryptsetup")); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("cryptsetup not found in %s", strings.Join(systemDirs, ":"))
+}
+
+// luksMagic starts the header of LUKS encrypted partitions
+var luksMagic = []byte{'L', 'U', 'K', 'S', 0xba, 0xbe}
+
+// isEncrypted returns whether the partition at offset in the image file
+// path is LUKS encrypted
+func isEncrypted(path string, offset uint64) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("while opening image %s: %s", path, err)
+	}
+	defer f.Close()
+
+	b := make([]byte, len(luksMagic))
+	if _, err := f.ReadAt(b, int64(offset)); err != nil && err != io.EOF {
+		return false, fmt.Errorf("while reading partition header of %s: %s", path, err)
+	}
+	return bytes.Equal(b, luksMagic), nil
+}
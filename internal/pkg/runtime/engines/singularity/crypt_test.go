@@ -0,0 +1,86 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCryptsetupPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptsetup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cryptsetup := filepath.Join(dir, "cryptsetup")
+	if err := ioutil.WriteFile(cryptsetup, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(dirs []string) { systemDirs = dirs }(systemDirs)
+	systemDirs = []string{filepath.Join(dir, "none")}
+
+	tests := []struct {
+		directive string
+		path      string
+	}{
+		{"", ""},
+		{cryptsetup, cryptsetup},
+		{dir, cryptsetup},
+		{"cryptsetup", ""},
+	}
+	for _, tt := range tests {
+		p, err := CryptsetupPath(&FileConfig{CryptsetupPath: tt.directive})
+		if tt.path == "" && err == nil {
+			t.Errorf("unexpected success with %q: %s", tt.directive, p)
+		} else if p != tt.path {
+			t.Errorf("unexpected path %q with %q, expected %q: %v", p, tt.directive, tt.path, err)
+		}
+	}
+
+	systemDirs = []string{dir}
+	if p, err := CryptsetupPath(&FileConfig{}); p != cryptsetup {
+		t.Errorf("cryptsetup not found in system directories: %v", err)
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	f, err := ioutil.TempFile("", "image-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Write(append([]byte("hsqs"), luksMagic...)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset    uint64
+		encrypted bool
+	}{
+		{0, false},
+		{4, true},
+		{8, false},
+		{64, false},
+	}
+	for _, tt := range tests {
+		encrypted, err := isEncrypted(f.Name(), tt.offset)
+		if err != nil {
+			t.Errorf("unexpected error at offset %d: %v", tt.offset, err)
+		} else if encrypted != tt.encrypted {
+			t.Errorf("unexpected result %v at offset %d", encrypted, tt.offset)
+		}
+	}
+	if _, err := isEncrypted(filepath.Join(os.TempDir(), "missing-image"), 0); err == nil {
+		t.Errorf("unexpected success with a missing image")
+	}
+}
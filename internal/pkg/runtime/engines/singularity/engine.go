@@ -7,8 +7,11 @@ package singularity
 
 import (
 	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
+var engineLog = sylog.Subsystem("engine")
+
 // EngineOperations describes a runtime engine
 type EngineOperations struct {
 	CommonConfig *config.Common `json:"-"`
@@ -21,7 +21,6 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/security"
 	"github.com/sylabs/singularity/internal/pkg/security/seccomp"
 	"github.com/sylabs/singularity/internal/pkg/syecl"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/capabilities"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
@@ -55,7 +54,7 @@ func (e *EngineOperations) prepareUserCaps() error {
 	authorizedCaps, _ := file.CheckUserCaps(pw.Name, caps)
 
 	if len(authorizedCaps) > 0 {
-		sylog.Debugf("User capabilities %v added", authorizedCaps)
+		engineLog.Debugf("User capabilities %v added", authorizedCaps)
 		commonCaps = authorizedCaps
 	}
 
@@ -63,12 +62,12 @@ func (e *EngineOperations) prepareUserCaps() error {
 	for _, g := range groups {
 		gr, err := user.GetGrGID(uint32(g))
 		if err != nil {
-			sylog.Debugf("Ignoring group %d: %s", g, err)
+			engineLog.Debugf("Ignoring group %d: %s", g, err)
 			continue
 		}
 		authorizedCaps, _ := file.CheckGroupCaps(gr.Name, caps)
 		if len(authorizedCaps) > 0 {
-			sylog.Debugf("%s group capabilities %v added", gr.Name, authorizedCaps)
+			engineLog.Debugf("%s group capabilities %v added", gr.Name, authorizedCaps)
 			commonCaps = append(commonCaps, authorizedCaps...)
 		}
 	}
@@ -79,7 +78,7 @@ func (e *EngineOperations) prepareUserCaps() error {
 	for _, cap := range caps {
 		for i, c := range commonCaps {
 			if c == cap {
-				sylog.Debugf("Capability %s dropped", cap)
+				engineLog.Debugf("Capability %s dropped", cap)
 				commonCaps = append(commonCaps[:i], commonCaps[i+1:]...)
 				break
 			}
@@ -110,14 +109,14 @@ func (e *EngineOperations) prepareRootCaps() error {
 
 	// is no-privs/keep-privs set on command line
 	if e.EngineConfig.GetNoPrivs() {
-		sylog.Debugf("--no-privs requested, no new privileges enabled")
+		engineLog.Debugf("--no-privs requested, no new privileges enabled")
 		defaultCapabilities = "no"
 	} else if e.EngineConfig.GetKeepPrivs() {
-		sylog.Debugf("--keep-privs requested")
+		engineLog.Debugf("--keep-privs requested")
 		defaultCapabilities = "full"
 	}
 
-	sylog.Debugf("Root %s capabilities", defaultCapabilities)
+	engineLog.Debugf("Root %s capabilities", defaultCapabilities)
 
 	// set default capabilities based on configuration file directive
 	switch defaultCapabilities {
@@ -136,12 +135,12 @@ func (e *EngineOperations) prepareRootCaps() error {
 		for _, g := range groups {
 			gr, err := user.GetGrGID(uint32(g))
 			if err != nil {
-				sylog.Debugf("Ignoring group %d: %s", g, err)
+				engineLog.Debugf("Ignoring group %d: %s", g, err)
 				continue
 			}
 			caps := file.ListGroupCaps(gr.Name)
 			commonCaps = append(commonCaps, caps...)
-			sylog.Debugf("%s group capabilities %v added", gr.Name, caps)
+			engineLog.Debugf("%s group capabilities %v added", gr.Name, caps)
 		}
 	default:
 		e.EngineConfig.OciConfig.SetProcessNoNewPrivileges(true)
@@ -157,7 +156,7 @@ func (e *EngineOperations) prepareRootCaps() error {
 			}
 		}
 		if !found {
-			sylog.Debugf("Root capability %s added", cap)
+			engineLog.Debugf("Root capability %s added", cap)
 			commonCaps = append(commonCaps, cap)
 		}
 	}
@@ -168,7 +167,7 @@ func (e *EngineOperations) prepareRootCaps() error {
 	for _, cap := range caps {
 		for i, c := range commonCaps {
 			if c == cap {
-				sylog.Debugf("Root capability %s dropped", cap)
+				engineLog.Debugf("Root capability %s dropped", cap)
 				commonCaps = append(commonCaps[:i], commonCaps[i+1:]...)
 				break
 			}
@@ -200,7 +199,7 @@ func (e *EngineOperations) prepareFd() {
 				continue
 			}
 
-			sylog.Debugf("Open file descriptor for %s", src)
+			engineLog.Debugf("Open file descriptor for %s", src)
 			f, err := os.Open(src)
 			if err != nil {
 				continue
@@ -218,7 +217,7 @@ func (e *EngineOperations) prepareFd() {
 				continue
 			}
 
-			sylog.Debugf("Open file descriptor for %s", src)
+			engineLog.Debugf("Open file descriptor for %s", src)
 			f, err := os.Open(src)
 			if err != nil {
 				continue
@@ -232,7 +231,7 @@ func (e *EngineOperations) prepareFd() {
 			continue
 		}
 
-		sylog.Debugf("Open file descriptor for %s", path)
+		engineLog.Debugf("Open file descriptor for %s", path)
 		f, err := os.Open(path)
 		if err != nil {
 			continue
@@ -254,7 +253,7 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 		namespaces := e.EngineConfig.OciConfig.Linux.Namespaces
 		for i, ns := range namespaces {
 			if ns.Type == specs.PIDNamespace {
-				sylog.Debugf("Not virtualizing PID namespace by configuration")
+				engineLog.Debugf("Not virtualizing PID namespace by configuration")
 				e.EngineConfig.OciConfig.Linux.Namespaces = append(namespaces[:i], namespaces[i+1:]...)
 				break
 			}
@@ -293,17 +292,17 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 
 	param := security.GetParam(e.EngineConfig.GetSecurity(), "selinux")
 	if param != "" {
-		sylog.Debugf("Applying SELinux context %s", param)
+		engineLog.Debugf("Applying SELinux context %s", param)
 		e.EngineConfig.OciConfig.SetProcessSelinuxLabel(param)
 	}
 	param = security.GetParam(e.EngineConfig.GetSecurity(), "apparmor")
 	if param != "" {
-		sylog.Debugf("Applying Apparmor profile %s", param)
+		engineLog.Debugf("Applying Apparmor profile %s", param)
 		e.EngineConfig.OciConfig.SetProcessApparmorProfile(param)
 	}
 	param = security.GetParam(e.EngineConfig.GetSecurity(), "seccomp")
 	if param != "" {
-		sylog.Debugf("Applying seccomp rule from %s", param)
+		engineLog.Debugf("Applying seccomp rule from %s", param)
 		generator := &e.EngineConfig.OciConfig.Generator
 		if err := seccomp.LoadProfileFromFile(param, generator); err != nil {
 			return err
@@ -343,6 +342,12 @@ func (e *EngineOperations) prepareInstanceJoinConfig(starterConfig *starter.Conf
 	// set namespaces to join
 	starterConfig.SetNsPathFromSpec(instanceEngineConfig.OciConfig.Linux.Namespaces)
 
+	// the process joins the instance cgroup, if any
+	if e.EngineConfig.GetCgroupsPath() != "" {
+		engineLog.Warningf("Ignoring cgroups profile, process joins the instance cgroup")
+	}
+	e.EngineConfig.SetCgroupsPath(instanceEngineConfig.GetCgroupsPath())
+
 	if e.EngineConfig.OciConfig.Process == nil {
 		e.EngineConfig.OciConfig.Process = &specs.Process{}
 	}
@@ -372,7 +377,7 @@ func (e *EngineOperations) prepareInstanceJoinConfig(starterConfig *starter.Conf
 	// restore apparmor profile
 	param := security.GetParam(e.EngineConfig.GetSecurity(), "apparmor")
 	if param != "" {
-		sylog.Debugf("Applying Apparmor profile %s", param)
+		engineLog.Debugf("Applying Apparmor profile %s", param)
 		e.EngineConfig.OciConfig.SetProcessApparmorProfile(param)
 	} else {
 		e.EngineConfig.OciConfig.SetProcessApparmorProfile(instanceEngineConfig.OciConfig.Process.ApparmorProfile)
@@ -381,7 +386,7 @@ func (e *EngineOperations) prepareInstanceJoinConfig(starterConfig *starter.Conf
 	// restore selinux context
 	param = security.GetParam(e.EngineConfig.GetSecurity(), "selinux")
 	if param != "" {
-		sylog.Debugf("Applying SELinux context %s", param)
+		engineLog.Debugf("Applying SELinux context %s", param)
 		e.EngineConfig.OciConfig.SetProcessSelinuxLabel(param)
 	} else {
 		e.EngineConfig.OciConfig.SetProcessSelinuxLabel(instanceEngineConfig.OciConfig.Process.SelinuxLabel)
@@ -390,7 +395,7 @@ func (e *EngineOperations) prepareInstanceJoinConfig(starterConfig *starter.Conf
 	// restore security features
 	param = security.GetParam(e.EngineConfig.GetSecurity(), "seccomp")
 	if param != "" {
-		sylog.Debugf("Applying seccomp rule from %s", param)
+		engineLog.Debugf("Applying seccomp rule from %s", param)
 		generator := &e.EngineConfig.OciConfig.Generator
 		if err := seccomp.LoadProfileFromFile(param, generator); err != nil {
 			return err
@@ -429,7 +434,7 @@ func (e *EngineOperations) PrepareConfig(masterConn net.Conn, starterConfig *sta
 	if pwd, err := os.Getwd(); err == nil {
 		e.EngineConfig.SetCwd(pwd)
 	} else {
-		sylog.Warningf("can't determine current working directory")
+		engineLog.Warningf("can't determine current working directory")
 		e.EngineConfig.SetCwd("/")
 	}
 
@@ -486,7 +491,7 @@ func (e *EngineOperations) loadImages() error {
 	}
 
 	if writable && !img.Writable {
-		sylog.Warningf("Can't set writable flag on image, no write permissions")
+		engineLog.Warningf("Can't set writable flag on image, no write permissions")
 		e.EngineConfig.SetWritableImage(false)
 	}
 
@@ -551,9 +556,13 @@ func (e *EngineOperations) loadImage(path string, writable bool) (*image.Image,
 		return nil, err
 	}
 
+	opened := imgObject.Path
+	if imgObject.Blob != "" {
+		opened = imgObject.Blob
+	}
 	link, err := mainthread.Readlink(imgObject.Source)
-	if link != imgObject.Path {
-		return nil, fmt.Errorf("resolved path %s doesn't match with opened path %s", imgObject.Path, link)
+	if link != opened {
+		return nil, fmt.Errorf("resolved path %s doesn't match with opened path %s", opened, link)
 	}
 
 	if len(e.EngineConfig.File.LimitContainerPaths) != 0 {
@@ -24,7 +24,6 @@ import (
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/internal/pkg/instance"
-	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
 
 func (engine *EngineOperations) checkExec() error {
@@ -67,7 +66,7 @@ func (engine *EngineOperations) checkExec() error {
 			return nil
 		}
 		if p, err := exec.LookPath(args[1]); err == nil {
-			sylog.Warningf("container does not have %s, calling %s directly", args[0], args[1])
+			engineLog.Warningf("container does not have %s, calling %s directly", args[0], args[1])
 			args[1] = p
 			args = args[1:]
 			return nil
@@ -79,7 +78,7 @@ func (engine *EngineOperations) checkExec() error {
 			return nil
 		}
 		if p, err := exec.LookPath(shell); err == nil {
-			sylog.Warningf("container does not have %s, calling %s directly", args[0], shell)
+			engineLog.Warningf("container does not have %s, calling %s directly", args[0], shell)
 			args[0] = p
 			return nil
 		}
@@ -209,7 +208,7 @@ func (engine *EngineOperations) StartProcess(masterConn net.Conn) error {
 	for {
 		select {
 		case s := <-signals:
-			sylog.Debugf("Received signal %s", s.String())
+			engineLog.Debugf("Received signal %s", s.String())
 			switch s {
 			case syscall.SIGCHLD:
 				for {
@@ -248,7 +247,7 @@ func (engine *EngineOperations) StartProcess(masterConn net.Conn) error {
 // PostStartProcess will execute code in smaster context after execution of container
 // process, typically to write instance state/config files or execute post start OCI hook
 func (engine *EngineOperations) PostStartProcess(pid int) error {
-	sylog.Debugf("Post start process")
+	engineLog.Debugf("Post start process")
 
 	if engine.EngineConfig.GetInstance() {
 		uid := os.Getuid()
@@ -23,6 +23,8 @@ type LoopArgs struct {
 	Mode       int
 	Info       loop.Info64
 	MaxDevices int
+	Range      *loop.Range
+	Tuning     loop.Tuning
 }
 
 // MountArgs defines the arguments to mount.
@@ -34,6 +36,78 @@ type MountArgs struct {
 	Data       string
 }
 
+// OverlayArgs defines the arguments to mount an overlay.
+type OverlayArgs struct {
+	Target    string
+	LowerDirs []string
+	// UpperDir and WorkDir are empty for a read-only overlay
+	UpperDir   string
+	WorkDir    string
+	Mountflags uintptr
+}
+
+// BindMountArgs defines the arguments to bind mount.
+type BindMountArgs struct {
+	Source    string
+	Target    string
+	Recursive bool
+	ReadOnly  bool
+	// Propagation is the propagation type applied to the mount point, one
+	// of MS_SHARED, MS_SLAVE, MS_PRIVATE or MS_UNBINDABLE optionally with
+	// MS_REC, the propagation is left unchanged if 0
+	Propagation uintptr
+}
+
+// UmountArgs defines the arguments to umount.
+type UmountArgs struct {
+	Target string
+	Flags  int
+}
+
+// RemountArgs defines the arguments to remount read-only.
+type RemountArgs struct {
+	Target string
+}
+
+// ListMountsArgs defines the arguments to list mounts.
+type ListMountsArgs struct{}
+
+// MountEntry describes a mount performed by the RPC server.
+type MountEntry struct {
+	Source     string
+	Target     string
+	Filesystem string
+	Flags      uintptr
+	Data       string
+}
+
+// CryptOpenArgs defines the arguments to open a dm-crypt mapping. The key
+// isn't part of the arguments, it is read from a file descriptor sent along
+// with the request.
+type CryptOpenArgs struct {
+	// Loop is the number of the loop device attached to the encrypted
+	// partition by the LoopDevice RPC
+	Loop int
+
+	keyFile *os.File
+}
+
+// SetKeyFile sets the file the key is read from, the server sets it to the
+// file descriptor received with the request.
+func (a *CryptOpenArgs) SetKeyFile(f *os.File) {
+	a.keyFile = f
+}
+
+// KeyFile returns the file the key is read from.
+func (a *CryptOpenArgs) KeyFile() *os.File {
+	return a.keyFile
+}
+
+// CryptCloseArgs defines the arguments to close a dm-crypt mapping.
+type CryptCloseArgs struct {
+	Name string
+}
+
 // ChrootArgs defines the arguments to chroot.
 type ChrootArgs struct {
 	Root     string
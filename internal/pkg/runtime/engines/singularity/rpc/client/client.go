@@ -6,19 +6,73 @@
 package client
 
 import (
+	"fmt"
+	"net"
 	"net/rpc"
 	"os"
+	"sync"
+	"syscall"
 
 	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
-// RPC holds the state necessary for remote procedure calls.
+// RPC holds the state necessary for remote procedure calls. Conn is only
+// required by the calls passing file descriptors.
 type RPC struct {
 	Client *rpc.Client
+	Conn   *FileConn
 	Name   string
 }
 
+// FileConn is a unix socket connection sending the files queued by
+// SendFile along with the next write, so they are received by the server
+// with the next request.
+type FileConn struct {
+	*net.UnixConn
+	mutex sync.Mutex
+	files []*os.File
+}
+
+// NewFileConn returns a FileConn writing to the unix socket conn.
+func NewFileConn(conn net.Conn) (*FileConn, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("RPC connection is not a unix socket")
+	}
+	return &FileConn{UnixConn: unixConn}, nil
+}
+
+// SendFile queues f to be sent with the next write, f can be closed once
+// the write returned.
+func (c *FileConn) SendFile(f *os.File) {
+	c.mutex.Lock()
+	c.files = append(c.files, f)
+	c.mutex.Unlock()
+}
+
+// Write writes b, with the queued files if any.
+func (c *FileConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	files := c.files
+	c.files = nil
+	c.mutex.Unlock()
+
+	if len(files) == 0 || len(b) == 0 {
+		return c.UnixConn.Write(b)
+	}
+	fds := make([]int, len(files))
+	for i, f := range files {
+		fds[i] = int(f.Fd())
+	}
+	n, _, err := c.WriteMsgUnix(b, syscall.UnixRights(fds...), nil)
+	if err != nil || n == len(b) {
+		return n, err
+	}
+	m, err := c.UnixConn.Write(b[n:])
+	return n + m, err
+}
+
 // Mount calls tme mount RPC using the supplied arguments.
 func (t *RPC) Mount(source string, target string, filesystem string, flags uintptr, data string) (int, error) {
 	arguments := &args.MountArgs{
@@ -33,6 +87,71 @@ func (t *RPC) Mount(source string, target string, filesystem string, flags uintp
 	return reply, err
 }
 
+// MountOverlay calls the overlay mount RPC using the supplied arguments.
+func (t *RPC) MountOverlay(target string, lowerDirs []string, upperDir string, workDir string, flags uintptr) (int, error) {
+	arguments := &args.OverlayArgs{
+		Target:     target,
+		LowerDirs:  lowerDirs,
+		UpperDir:   upperDir,
+		WorkDir:    workDir,
+		Mountflags: flags,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".MountOverlay", arguments, &reply)
+	return reply, err
+}
+
+// BindMount calls the bind mount RPC using the supplied arguments.
+func (t *RPC) BindMount(source string, target string, recursive bool, readOnly bool, propagation uintptr) (int, error) {
+	arguments := &args.BindMountArgs{
+		Source:      source,
+		Target:      target,
+		Recursive:   recursive,
+		ReadOnly:    readOnly,
+		Propagation: propagation,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".BindMount", arguments, &reply)
+	return reply, err
+}
+
+// Umount calls the umount RPC using the supplied arguments.
+func (t *RPC) Umount(target string, flags int) (int, error) {
+	arguments := &args.UmountArgs{
+		Target: target,
+		Flags:  flags,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".Umount", arguments, &reply)
+	return reply, err
+}
+
+// Remount calls the read-only remount RPC using the supplied arguments.
+func (t *RPC) Remount(target string) (int, error) {
+	arguments := &args.RemountArgs{
+		Target: target,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".Remount", arguments, &reply)
+	return reply, err
+}
+
+// ListMounts calls the list mounts RPC and returns the mounts performed
+// for the container.
+func (t *RPC) ListMounts() ([]args.MountEntry, error) {
+	var reply []args.MountEntry
+	err := t.Client.Call(t.Name+".ListMounts", &args.ListMountsArgs{}, &reply)
+	return reply, err
+}
+
+// UnwindMounts calls the unwind mounts RPC and returns the number of mounts
+// unmounted.
+func (t *RPC) UnwindMounts() (int, error) {
+	var reply int
+	err := t.Client.Call(t.Name+".UnwindMounts", &args.ListMountsArgs{}, &reply)
+	return reply, err
+}
+
 // Mkdir calls the mkdir RPC using the supplied arguments.
 func (t *RPC) Mkdir(path string, perm os.FileMode) (int, error) {
 	arguments := &args.MkdirArgs{
@@ -44,6 +163,32 @@ func (t *RPC) Mkdir(path string, perm os.FileMode) (int, error) {
 	return reply, err
 }
 
+// CryptOpen calls the dm-crypt open RPC using the supplied arguments and
+// returns the path of the decrypted device. The key is read by the server
+// from the file descriptor of key.
+func (t *RPC) CryptOpen(loop int, key *os.File) (string, error) {
+	if t.Conn == nil {
+		return "", fmt.Errorf("RPC connection can't pass file descriptors")
+	}
+	arguments := &args.CryptOpenArgs{
+		Loop: loop,
+	}
+	var reply string
+	t.Conn.SendFile(key)
+	err := t.Client.Call(t.Name+".CryptOpen", arguments, &reply)
+	return reply, err
+}
+
+// CryptClose calls the dm-crypt close RPC using the supplied arguments.
+func (t *RPC) CryptClose(name string) (int, error) {
+	arguments := &args.CryptCloseArgs{
+		Name: name,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".CryptClose", arguments, &reply)
+	return reply, err
+}
+
 // Chroot calls the chroot RPC using the supplied arguments.
 func (t *RPC) Chroot(root string, usePivot bool) (int, error) {
 	arguments := &args.ChrootArgs{
@@ -56,12 +201,14 @@ func (t *RPC) Chroot(root string, usePivot bool) (int, error) {
 }
 
 // LoopDevice calls the loop device RPC using the supplied arguments.
-func (t *RPC) LoopDevice(image string, mode int, info loop.Info64, maxDevices int) (int, error) {
+func (t *RPC) LoopDevice(image string, mode int, info loop.Info64, maxDevices int, loopRange *loop.Range, tuning loop.Tuning) (int, error) {
 	arguments := &args.LoopArgs{
 		Image:      image,
 		Mode:       mode,
 		Info:       info,
 		MaxDevices: maxDevices,
+		Range:      loopRange,
+		Tuning:     tuning,
 	}
 	var reply int
 	err := t.Client.Call(t.Name+".LoopDevice", arguments, &reply)
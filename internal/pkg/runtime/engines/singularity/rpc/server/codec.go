@@ -0,0 +1,294 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/rpc"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// maxFiles is the maximum number of file descriptors received with a
+// message, extra ones are closed by the kernel
+const maxFiles = 4
+
+// credConn reads from a unix socket with SO_PASSCRED set, the kernel attaches
+// the credentials of the sender to every message. All messages must come
+// from the process which sent the first one, so only the paired engine
+// process can send requests. Its user may change between requests when it
+// drops privileges. File descriptors sent with messages are queued until
+// requests take them.
+type credConn struct {
+	*net.UnixConn
+	oob []byte
+	// cred holds the credentials of the last message
+	cred  *syscall.Ucred
+	pid   int32
+	files []*os.File
+}
+
+func newCredConn(conn *net.UnixConn) (*credConn, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable credentials passing: %s", err)
+	}
+	oobSize := syscall.CmsgSpace(syscall.SizeofUcred) + syscall.CmsgSpace(maxFiles*4)
+	return &credConn{UnixConn: conn, oob: make([]byte, oobSize)}, nil
+}
+
+// Read reads data and checks the credentials of its sender
+func (c *credConn) Read(b []byte) (int, error) {
+	n, oobn, _, _, err := c.ReadMsgUnix(b, c.oob)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	cred, files, err := parseControlMessages(c.oob[:oobn])
+	if err != nil {
+		return 0, err
+	}
+	if c.cred == nil {
+		c.pid = cred.Pid
+	} else if cred.Pid != c.pid {
+		closeFiles(files)
+		return 0, fmt.Errorf("request from pid %d rejected, connection is paired with pid %d", cred.Pid, c.pid)
+	}
+	c.cred = cred
+	c.files = append(c.files, files...)
+	return n, nil
+}
+
+// takeFile returns the oldest file descriptor received and not taken yet,
+// or nil if there is none
+func (c *credConn) takeFile() *os.File {
+	if len(c.files) == 0 {
+		return nil
+	}
+	f := c.files[0]
+	c.files = c.files[1:]
+	return f
+}
+
+func (c *credConn) Close() error {
+	closeFiles(c.files)
+	c.files = nil
+	return c.UnixConn.Close()
+}
+
+// parseControlMessages returns the credentials and the file descriptors
+// found in the control messages oob
+func parseControlMessages(oob []byte) (*syscall.Ucred, []*os.File, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cred *syscall.Ucred
+	var files []*os.File
+	for i := range msgs {
+		if msgs[i].Header.Level != syscall.SOL_SOCKET {
+			continue
+		}
+		switch msgs[i].Header.Type {
+		case syscall.SCM_CREDENTIALS:
+			cred, err = syscall.ParseUnixCredentials(&msgs[i])
+		case syscall.SCM_RIGHTS:
+			var fds []int
+			fds, err = syscall.ParseUnixRights(&msgs[i])
+			for _, fd := range fds {
+				syscall.CloseOnExec(fd)
+				files = append(files, os.NewFile(uintptr(fd), "received file"))
+			}
+		}
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, err
+		}
+	}
+	if cred == nil {
+		closeFiles(files)
+		return nil, nil, fmt.Errorf("no credentials received with request")
+	}
+	return cred, files, nil
+}
+
+// closeFiles closes files
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// keyFileReceiver is implemented by the arguments of requests reading a
+// key from a file descriptor sent with the request
+type keyFileReceiver interface {
+	SetKeyFile(*os.File)
+}
+
+// auditCodec is a gob server codec authenticating requests through the
+// credentials of their sender and recording every call, with its arguments
+// and result, in the debug log and in syslog when available
+type auditCodec struct {
+	conn   *credConn
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	syslog *syslog.Writer
+	closed bool
+
+	// calls holds the pending calls by sequence number, responses are
+	// written concurrently with the next requests being read
+	mutex sync.Mutex
+	seq   uint64
+	calls map[uint64]auditCall
+}
+
+// auditCall holds the formatted arguments of a call and the credentials
+// of its sender
+type auditCall struct {
+	args string
+	uid  uint32
+	pid  int32
+}
+
+// NewServerCodec returns a codec serving RPC requests on the unix socket
+// conn. Requests are only accepted from the process which sent the first
+// one, and privileged operations are written to an audit trail.
+func NewServerCodec(conn net.Conn) (rpc.ServerCodec, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("RPC connection is not a unix socket")
+	}
+	cc, err := newCredConn(unixConn)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(cc)
+	c := &auditCodec{
+		conn:   cc,
+		dec:    gob.NewDecoder(bufio.NewReader(cc)),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+		calls:  make(map[uint64]auditCall),
+	}
+	// the audit trail still goes to the debug log without syslog
+	if w, err := syslog.New(syslog.LOG_AUTHPRIV|syslog.LOG_INFO, "singularity-rpc"); err == nil {
+		c.syslog = w
+	}
+	return c, nil
+}
+
+func (c *auditCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.dec.Decode(r)
+	c.seq = r.Seq
+	return err
+}
+
+func (c *auditCodec) ReadRequestBody(body interface{}) error {
+	err := c.dec.Decode(body)
+	if r, ok := body.(keyFileReceiver); ok && err == nil {
+		r.SetKeyFile(c.conn.takeFile())
+	}
+	if body != nil {
+		call := auditCall{args: auditArgs(body)}
+		if cred := c.conn.cred; cred != nil {
+			call.uid, call.pid = cred.Uid, cred.Pid
+		}
+		c.mutex.Lock()
+		c.calls[c.seq] = call
+		c.mutex.Unlock()
+	}
+	return err
+}
+
+func (c *auditCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	c.mutex.Lock()
+	call := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.mutex.Unlock()
+	c.audit(r.ServiceMethod, call, r.Error)
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *auditCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.syslog != nil {
+		c.syslog.Close()
+	}
+	return c.conn.Close()
+}
+
+// audit records a call of method and its result, an empty string on
+// success
+func (c *auditCodec) audit(method string, call auditCall, result string) {
+	if result == "" {
+		result = "success"
+	}
+	rpcLog.WithFields(sylog.Fields{
+		"uid":    call.uid,
+		"pid":    call.pid,
+		"args":   call.args,
+		"result": result,
+	}).Debugf("RPC call %s", method)
+	if c.syslog != nil {
+		c.syslog.Info(fmt.Sprintf("method=%s uid=%d pid=%d args={%s} result=%q", method, call.uid, call.pid, call.args, result))
+	}
+}
+
+// auditArgs formats the exported fields of the arguments of a call, byte
+// slices are replaced by their length
+func auditArgs(body interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(body))
+	if v.Kind() != reflect.Struct {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+	var fields []string
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		f := v.Field(i)
+		value := fmt.Sprintf("%v", f.Interface())
+		if b, ok := f.Interface().([]byte); ok {
+			value = fmt.Sprintf("<%d bytes>", len(b))
+		}
+		fields = append(fields, v.Type().Field(i).Name+"="+value)
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"syscall"
+	"testing"
+
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc/client"
+)
+
+type echo int
+
+func (e *echo) Hostname(arguments *args.HostnameArgs, reply *string) error {
+	*reply = arguments.Hostname
+	return nil
+}
+
+func (e *echo) CryptOpen(arguments *args.CryptOpenArgs, reply *string) error {
+	f := arguments.KeyFile()
+	if f == nil {
+		return fmt.Errorf("no key file")
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	*reply = string(b)
+	return err
+}
+
+// socketPair returns the two ends of a unix socket pair
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("failed to create socket pair: %v", err)
+	}
+	var conns [2]net.Conn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socket")
+		conns[i], err = net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("failed to create connection: %v", err)
+		}
+	}
+	return conns[0], conns[1]
+}
+
+func TestServerCodec(t *testing.T) {
+	serverConn, clientConn := socketPair(t)
+
+	codec, err := NewServerCodec(serverConn)
+	if err != nil {
+		t.Fatalf("unexpected error creating codec: %v", err)
+	}
+	s := rpc.NewServer()
+	s.RegisterName("test", new(echo))
+	go s.ServeCodec(codec)
+
+	client := rpc.NewClient(clientConn)
+	defer client.Close()
+
+	var reply string
+	if err := client.Call("test.Hostname", &args.HostnameArgs{Hostname: "container"}, &reply); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "container" {
+		t.Errorf("unexpected reply %q", reply)
+	}
+}
+
+func TestServerCodecFiles(t *testing.T) {
+	serverConn, clientConn := socketPair(t)
+
+	codec, err := NewServerCodec(serverConn)
+	if err != nil {
+		t.Fatalf("unexpected error creating codec: %v", err)
+	}
+	s := rpc.NewServer()
+	s.RegisterName("test", new(echo))
+	go s.ServeCodec(codec)
+
+	conn, err := client.NewFileConn(clientConn)
+	if err != nil {
+		t.Fatalf("unexpected error creating connection: %v", err)
+	}
+	rpcOps := &client.RPC{Client: rpc.NewClient(conn), Conn: conn, Name: "test"}
+	defer rpcOps.Client.Close()
+
+	key, err := ioutil.TempFile("", "key-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(key.Name())
+	defer key.Close()
+	if _, err := key.WriteString("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := rpcOps.CryptOpen(0, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "secret" {
+		t.Errorf("unexpected key %q read from the file descriptor", reply)
+	}
+
+	// requests are still decoded after the file descriptor
+	var hostname string
+	if err := rpcOps.Client.Call("test.Hostname", &args.HostnameArgs{Hostname: "container"}, &hostname); err != nil || hostname != "container" {
+		t.Errorf("unexpected reply %q: %v", hostname, err)
+	}
+	if err := rpcOps.Client.Call("test.CryptOpen", &args.CryptOpenArgs{}, &reply); err == nil {
+		t.Errorf("unexpected success without file descriptor")
+	}
+}
+
+func TestAuditArgs(t *testing.T) {
+	arguments := &args.CryptOpenArgs{Loop: 3}
+	arguments.SetKeyFile(os.Stdin)
+	if s := auditArgs(arguments); s != "Loop=3" {
+		t.Errorf("unexpected audit arguments %q", s)
+	}
+	if s := auditArgs(&struct{ Data []byte }{[]byte("secret")}); s != "Data=<6 bytes>" {
+		t.Errorf("unexpected audit arguments %q", s)
+	}
+}
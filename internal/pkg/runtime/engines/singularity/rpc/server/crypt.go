@@ -0,0 +1,163 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+)
+
+// mapperDir is the directory of the device mapper devices
+const mapperDir = "/dev/mapper"
+
+// cryptTable tracks the dm-crypt mappings opened for the container, and
+// the loop devices attached for it which are the only devices they may be
+// opened on
+type cryptTable struct {
+	sync.Mutex
+	names []string
+	count int
+	loops map[int]bool
+}
+
+// attach records a loop device attached for the container
+func (c *cryptTable) attach(loop int) {
+	c.Lock()
+	defer c.Unlock()
+	if c.loops == nil {
+		c.loops = make(map[int]bool)
+	}
+	c.loops[loop] = true
+}
+
+// attached returns whether the loop device was attached for the container
+func (c *cryptTable) attached(loop int) bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.loops[loop]
+}
+
+// next returns a new mapping name, unique on the host
+func (c *cryptTable) next() string {
+	c.Lock()
+	defer c.Unlock()
+	c.count++
+	return fmt.Sprintf("singularity_crypt_%d_%d", os.Getpid(), c.count)
+}
+
+// add records an opened mapping
+func (c *cryptTable) add(name string) {
+	c.Lock()
+	defer c.Unlock()
+	c.names = append(c.names, name)
+}
+
+// remove forgets a closed mapping
+func (c *cryptTable) remove(name string) {
+	c.Lock()
+	defer c.Unlock()
+	for i, n := range c.names {
+		if n == name {
+			c.names = append(c.names[:i], c.names[i+1:]...)
+			return
+		}
+	}
+}
+
+// has returns whether the mapping name was opened for the container
+func (c *cryptTable) has(name string) bool {
+	c.Lock()
+	defer c.Unlock()
+	for _, n := range c.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns a copy of the opened mappings in order
+func (c *cryptTable) list() []string {
+	c.Lock()
+	defer c.Unlock()
+	return append([]string(nil), c.names...)
+}
+
+// cryptsetupPath returns the path of cryptsetup set in the configuration
+// file or found in the system directories
+func cryptsetupPath(configFile string) (string, error) {
+	c := &singularity.FileConfig{}
+	if err := config.Parser(configFile, c); err != nil {
+		return "", fmt.Errorf("unable to parse singularity.conf file: %s", err)
+	}
+	return singularity.CryptsetupPath(c)
+}
+
+// cryptsetup runs cryptsetup with args, files are passed to it from file
+// descriptor 3
+func cryptsetup(files []*os.File, args ...string) error {
+	path, err := cryptsetupPath(buildcfg.SYSCONFDIR + "/singularity/singularity.conf")
+	if err != nil {
+		return fmt.Errorf("cryptsetup is required for encrypted partitions: %s", err)
+	}
+	cmd := exec.Command(path, args...)
+	cmd.ExtraFiles = files
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cryptsetup failed: %s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// CryptOpen opens a dm-crypt mapping of the encrypted partition attached to
+// a loop device by LoopDevice, with the key read from the file descriptor
+// sent with the request, and replies with the path of the decrypted device.
+// The mapping is global to the host, it is closed by CryptClose, when
+// mounts are unwound after a failed container setup, or by the engine when
+// the container is cleaned up.
+func (t *Methods) CryptOpen(arguments *args.CryptOpenArgs, reply *string) error {
+	key := arguments.KeyFile()
+	if key == nil {
+		return fmt.Errorf("no key file descriptor received with the request")
+	}
+	defer key.Close()
+
+	if !t.crypt.attached(arguments.Loop) {
+		return fmt.Errorf("loop device %d was not attached for the container", arguments.Loop)
+	}
+	device := fmt.Sprintf("/dev/loop%d", arguments.Loop)
+
+	name := t.crypt.next()
+	if err := cryptsetup([]*os.File{key}, "open", "--key-file=/proc/self/fd/3", device, name); err != nil {
+		return fmt.Errorf("failed to open encrypted device %s: %s", device, err)
+	}
+	t.crypt.add(name)
+	*reply = filepath.Join(mapperDir, name)
+	return nil
+}
+
+// CryptClose closes the dm-crypt mapping with the specified name, the
+// path of its device is also accepted. Only mappings opened by CryptOpen
+// can be closed.
+func (t *Methods) CryptClose(arguments *args.CryptCloseArgs, reply *int) error {
+	name := filepath.Base(arguments.Name)
+	if !t.crypt.has(name) || arguments.Name != name && arguments.Name != filepath.Join(mapperDir, name) {
+		return fmt.Errorf("encrypted device %s was not opened for the container", arguments.Name)
+	}
+	if err := cryptsetup(nil, "close", name); err != nil {
+		return fmt.Errorf("failed to close encrypted device %s: %s", name, err)
+	}
+	t.crypt.remove(name)
+	return nil
+}
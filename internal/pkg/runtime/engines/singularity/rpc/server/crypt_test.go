@@ -0,0 +1,83 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+)
+
+func TestCryptTable(t *testing.T) {
+	var c cryptTable
+
+	a, b := c.next(), c.next()
+	if a == b {
+		t.Errorf("mapping names are not unique: %s", a)
+	}
+	c.add(a)
+	c.add(b)
+	c.remove(a)
+	if names := c.list(); !reflect.DeepEqual(names, []string{b}) {
+		t.Errorf("unexpected mappings %v, expected %v", names, []string{b})
+	}
+	if c.has(a) || !c.has(b) {
+		t.Errorf("unexpected mappings %v", c.list())
+	}
+}
+
+func TestCryptCloseUnknown(t *testing.T) {
+	m := new(Methods)
+	m.crypt.add("singularity_crypt_1_1")
+
+	for _, name := range []string{"root", "/dev/mapper/root", "/tmp/singularity_crypt_1_1", "../singularity_crypt_1_1"} {
+		if err := m.CryptClose(&args.CryptCloseArgs{Name: name}, new(int)); err == nil {
+			t.Errorf("unexpected success closing mapping %s", name)
+		}
+	}
+	if !m.crypt.has("singularity_crypt_1_1") {
+		t.Errorf("mapping forgotten after refused closes")
+	}
+}
+
+func TestCryptOpenRefused(t *testing.T) {
+	m := new(Methods)
+	m.crypt.attach(7)
+
+	key, err := ioutil.TempFile("", "key-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(key.Name())
+
+	tests := []struct {
+		name string
+		loop int
+		key  *os.File
+		err  string
+	}{
+		{"no key", 7, nil, "no key file descriptor"},
+		{"loop not attached", 0, key, "was not attached"},
+	}
+	for _, tt := range tests {
+		arguments := &args.CryptOpenArgs{Loop: tt.loop}
+		arguments.SetKeyFile(tt.key)
+		err := m.CryptOpen(arguments, new(string))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: unexpected error %v, expected %q", tt.name, err, tt.err)
+		}
+	}
+	if _, err := key.Stat(); err == nil {
+		t.Errorf("key file not closed after a refused request")
+	}
+	if names := m.crypt.list(); len(names) != 0 {
+		t.Errorf("unexpected mappings %v", names)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"sync"
+
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+)
+
+// mountTable tracks the mounts performed for the container in order, so
+// they can be unmounted in reverse order at teardown
+type mountTable struct {
+	sync.Mutex
+	entries []args.MountEntry
+}
+
+// add records a mount
+func (m *mountTable) add(e args.MountEntry) {
+	m.Lock()
+	defer m.Unlock()
+	m.entries = append(m.entries, e)
+}
+
+// remove forgets the last mount on target, as unmounting target removes
+// the mount stacked on top of it
+func (m *mountTable) remove(target string) {
+	m.Lock()
+	defer m.Unlock()
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.entries[i].Target == target {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// last returns the last mount on target, ok is false if target wasn't
+// mounted by the server
+func (m *mountTable) last(target string) (e args.MountEntry, ok bool) {
+	m.Lock()
+	defer m.Unlock()
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.entries[i].Target == target {
+			return m.entries[i], true
+		}
+	}
+	return e, false
+}
+
+// update replaces the flags of the last mount on target, and its options if
+// data is set, the options given at mount time are kept otherwise
+func (m *mountTable) update(target string, flags uintptr, data string) {
+	m.Lock()
+	defer m.Unlock()
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.entries[i].Target == target {
+			m.entries[i].Flags = flags
+			if data != "" {
+				m.entries[i].Data = data
+			}
+			return
+		}
+	}
+}
+
+// list returns a copy of the mounts in order
+func (m *mountTable) list() []args.MountEntry {
+	m.Lock()
+	defer m.Unlock()
+	return append([]args.MountEntry(nil), m.entries...)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
+)
+
+func TestMountTable(t *testing.T) {
+	var m mountTable
+
+	m.add(args.MountEntry{Source: "/tmp/rootfs", Target: "/mnt", Flags: syscall.MS_BIND})
+	m.add(args.MountEntry{Source: "tmpfs", Target: "/mnt/tmp", Filesystem: "tmpfs"})
+	m.add(args.MountEntry{Source: "overlay", Target: "/mnt", Filesystem: "overlay"})
+
+	e, ok := m.last("/mnt")
+	if !ok || e.Source != "overlay" {
+		t.Errorf("unexpected last mount %+v on /mnt", e)
+	}
+	if _, ok := m.last("/proc"); ok {
+		t.Errorf("unexpected mount found on /proc")
+	}
+
+	m.update("/mnt/tmp", syscall.MS_RDONLY, "size=1m")
+	m.remove("/mnt")
+	expected := []args.MountEntry{
+		{Source: "/tmp/rootfs", Target: "/mnt", Flags: syscall.MS_BIND},
+		{Source: "tmpfs", Target: "/mnt/tmp", Filesystem: "tmpfs", Flags: syscall.MS_RDONLY, Data: "size=1m"},
+	}
+	if entries := m.list(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("unexpected mounts %+v, expected %+v", entries, expected)
+	}
+}
+
+// runMainThread executes the functions sent to the main thread by the
+// methods until stop is closed
+func runMainThread(stop chan struct{}) {
+	for {
+		select {
+		case f := <-mainthread.FuncChannel:
+			f()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// mountOptions returns the mount and superblock options of target
+func mountOptions(t *testing.T, target string) string {
+	b, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		t.Fatalf("failed to read mount information: %v", err)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == target {
+			return fields[5] + " " + fields[len(fields)-1]
+		}
+	}
+	return ""
+}
+
+func TestRemount(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting requires root")
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go runMainThread(stop)
+
+	dir, err := ioutil.TempDir("", "remount-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	tmp := filepath.Join(dir, "tmp")
+	bind := filepath.Join(dir, "bind")
+	for _, d := range []string{tmp, bind} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("failed to create mount point: %v", err)
+		}
+	}
+
+	m := &Methods{}
+	defer m.UnwindMounts(&args.ListMountsArgs{}, new(int))
+
+	mount := &args.MountArgs{Source: "tmpfs", Target: tmp, Filesystem: "tmpfs", Mountflags: syscall.MS_NOSUID, Data: "mode=0700,size=1m"}
+	if err := m.Mount(mount, new(int)); err != nil {
+		t.Fatalf("failed to mount tmpfs: %v", err)
+	}
+	if err := m.BindMount(&args.BindMountArgs{Source: tmp, Target: bind, ReadOnly: true}, new(int)); err != nil {
+		t.Fatalf("failed to bind mount: %v", err)
+	}
+	if err := m.Remount(&args.RemountArgs{Target: tmp}, new(int)); err != nil {
+		t.Fatalf("failed to remount: %v", err)
+	}
+
+	options := mountOptions(t, tmp)
+	for _, o := range []string{"ro", "nosuid", "size=1024k", "mode=700"} {
+		if !strings.Contains(options, o) {
+			t.Errorf("option %s of %s lost: %s", o, tmp, options)
+		}
+	}
+	if options := mountOptions(t, bind); !strings.HasPrefix(options, "ro") {
+		t.Errorf("bind mount %s is writable: %s", bind, options)
+	}
+
+	var entries []args.MountEntry
+	if err := m.ListMounts(&args.ListMountsArgs{}, &entries); err != nil {
+		t.Fatalf("failed to list mounts: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Target != tmp || entries[1].Target != bind {
+		t.Fatalf("unexpected mounts %+v", entries)
+	}
+	if e := entries[0]; e.Data != mount.Data || e.Flags&syscall.MS_RDONLY == 0 {
+		t.Errorf("remount not tracked: %+v", e)
+	}
+
+	var n int
+	if err := m.UnwindMounts(&args.ListMountsArgs{}, &n); err != nil || n != 2 {
+		t.Errorf("unwound %d mounts: %v", n, err)
+	}
+}
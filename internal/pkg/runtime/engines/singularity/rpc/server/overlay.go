@@ -0,0 +1,88 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/layout"
+	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
+)
+
+// overlayOptions returns the mount options of the overlay
+func overlayOptions(arguments *args.OverlayArgs) string {
+	options := "lowerdir=" + strings.Join(arguments.LowerDirs, ":")
+	if arguments.UpperDir != "" {
+		options += fmt.Sprintf(",upperdir=%s,workdir=%s", arguments.UpperDir, arguments.WorkDir)
+	}
+	return options
+}
+
+// MountOverlay mounts an overlay with the specified arguments in one call.
+// All directories must be within the session directory, a missing work
+// directory is created. Upper and work directories are owned by root, so
+// the overlay is mounted with root filesystem IDs.
+func (t *Methods) MountOverlay(arguments *args.OverlayArgs, reply *int) (err error) {
+	if len(arguments.LowerDirs) == 0 {
+		return fmt.Errorf("no lower directory given for overlay on %s", arguments.Target)
+	}
+	if (arguments.UpperDir == "") != (arguments.WorkDir == "") {
+		return fmt.Errorf("upper and work directories must be given together for overlay on %s", arguments.Target)
+	}
+
+	sessionDir, err := filepath.EvalSymlinks(buildcfg.SESSIONDIR)
+	if err != nil {
+		return fmt.Errorf("failed to resolve session directory %s: %s", buildcfg.SESSIONDIR, err)
+	}
+	paths := append([]string{arguments.Target}, arguments.LowerDirs...)
+	if arguments.UpperDir != "" {
+		paths = append(paths, arguments.UpperDir, arguments.WorkDir)
+	}
+	for _, path := range paths {
+		if err := layout.CheckSessionPath(sessionDir, path); err != nil {
+			return fmt.Errorf("refusing overlay on %s: %s", arguments.Target, err)
+		}
+	}
+
+	options := overlayOptions(arguments)
+	mainthread.Execute(func() {
+		syscall.Setfsuid(0)
+		syscall.Setfsgid(0)
+		defer syscall.Setfsuid(os.Getuid())
+		defer syscall.Setfsgid(os.Getgid())
+
+		if arguments.WorkDir != "" {
+			if err = os.MkdirAll(arguments.WorkDir, 0755); err != nil {
+				return
+			}
+		}
+		err = syscall.Mount("overlay", arguments.Target, "overlay", arguments.Mountflags, options)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mount overlay on %s: %s", arguments.Target, err)
+	}
+
+	rpcLog.WithFields(sylog.Fields{
+		"uid":     os.Getuid(),
+		"target":  arguments.Target,
+		"options": options,
+	}).Debugf("Mounted overlay")
+	t.mounts.add(args.MountEntry{
+		Source:     "overlay",
+		Target:     arguments.Target,
+		Filesystem: "overlay",
+		Flags:      arguments.Mountflags,
+		Data:       options,
+	})
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	args "github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity/rpc"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/layout"
+)
+
+func TestCheckSessionPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	session := filepath.Join(dir, "session")
+	if err := os.MkdirAll(filepath.Join(session, "upper"), 0755); err != nil {
+		t.Fatalf("failed to create session directory: %v", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(session, "escape")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{filepath.Join(session, "upper"), true},
+		{filepath.Join(session, "work"), true},
+		{session, true},
+		{filepath.Join(session, "escape"), false},
+		{filepath.Join(session, "escape", "work"), false},
+		{filepath.Join(session, "..", "other"), false},
+		{session + "-other", false},
+		{"upper", false},
+	}
+	for _, tt := range tests {
+		if err := layout.CheckSessionPath(session, tt.path); (err == nil) != tt.ok {
+			t.Errorf("unexpected result for %s: %v", tt.path, err)
+		}
+	}
+}
+
+func TestOverlayOptions(t *testing.T) {
+	arguments := &args.OverlayArgs{LowerDirs: []string{"/a", "/b"}}
+	if o := overlayOptions(arguments); o != "lowerdir=/a:/b" {
+		t.Errorf("unexpected read-only options %s", o)
+	}
+	arguments.UpperDir, arguments.WorkDir = "/upper", "/work"
+	if o := overlayOptions(arguments); o != "lowerdir=/a:/b,upperdir=/upper,workdir=/work" {
+		t.Errorf("unexpected writable options %s", o)
+	}
+}
@@ -20,17 +20,156 @@ import (
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
 
+var rpcLog = sylog.Subsystem("rpc")
+
 var diskGID = -1
 
-// Methods is a receiver type.
-type Methods int
+// Methods is a receiver type, it tracks the mounts performed and the
+// encrypted devices opened for the container.
+type Methods struct {
+	mounts mountTable
+	crypt  cryptTable
+}
+
+// propagationFlags are the mount flags changing the propagation type of a
+// mount point rather than creating a mount
+const propagationFlags = syscall.MS_SHARED | syscall.MS_SLAVE | syscall.MS_PRIVATE | syscall.MS_UNBINDABLE
 
 // Mount performs a mount with the specified arguments.
 func (t *Methods) Mount(arguments *args.MountArgs, reply *int) (err error) {
 	mainthread.Execute(func() {
 		err = syscall.Mount(arguments.Source, arguments.Target, arguments.Filesystem, arguments.Mountflags, arguments.Data)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	flags := arguments.Mountflags
+	switch {
+	case flags&syscall.MS_REMOUNT != 0:
+		t.mounts.update(arguments.Target, flags&^syscall.MS_REMOUNT, arguments.Data)
+	case flags&(propagationFlags|syscall.MS_MOVE) == 0:
+		t.mounts.add(args.MountEntry{
+			Source:     arguments.Source,
+			Target:     arguments.Target,
+			Filesystem: arguments.Filesystem,
+			Flags:      flags,
+			Data:       arguments.Data,
+		})
+	}
+	return nil
+}
+
+// BindMount performs a bind mount with the specified arguments, remounting
+// it read-only and applying its propagation type if requested.
+func (t *Methods) BindMount(arguments *args.BindMountArgs, reply *int) (err error) {
+	flags := uintptr(syscall.MS_BIND)
+	if arguments.Recursive {
+		flags |= syscall.MS_REC
+	}
+	mainthread.Execute(func() {
+		if err = syscall.Mount(arguments.Source, arguments.Target, "", flags, ""); err != nil {
+			return
+		}
+		if arguments.ReadOnly {
+			// the read-only flag is ignored by the initial bind mount
+			flags |= syscall.MS_RDONLY
+			if err = syscall.Mount("", arguments.Target, "", flags|syscall.MS_REMOUNT, ""); err != nil {
+				syscall.Unmount(arguments.Target, syscall.MNT_DETACH)
+				return
+			}
+		}
+		if arguments.Propagation != 0 {
+			if err = syscall.Mount("", arguments.Target, "", arguments.Propagation, ""); err != nil {
+				syscall.Unmount(arguments.Target, syscall.MNT_DETACH)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bind mount %s on %s: %s", arguments.Source, arguments.Target, err)
+	}
+	t.mounts.add(args.MountEntry{
+		Source: arguments.Source,
+		Target: arguments.Target,
+		Flags:  flags,
+	})
+	return nil
+}
+
+// Umount unmounts the target with the specified flags.
+func (t *Methods) Umount(arguments *args.UmountArgs, reply *int) (err error) {
+	mainthread.Execute(func() {
+		err = syscall.Unmount(arguments.Target, arguments.Flags)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unmount %s: %s", arguments.Target, err)
+	}
+	t.mounts.remove(arguments.Target)
+	return nil
+}
+
+// Remount remounts the target read-only, keeping the flags and options it
+// was mounted with if it was mounted by the server.
+func (t *Methods) Remount(arguments *args.RemountArgs, reply *int) (err error) {
+	// untracked targets are remounted as bind mounts, so only the mount
+	// point becomes read-only rather than its whole filesystem
+	flags := uintptr(syscall.MS_BIND)
+	data := ""
+	if e, ok := t.mounts.last(arguments.Target); ok {
+		// options like size= of tmpfs are reset if not given again
+		flags, data = e.Flags&^syscall.MS_REC, e.Data
+	}
+	flags |= syscall.MS_RDONLY
+	mainthread.Execute(func() {
+		err = syscall.Mount("", arguments.Target, "", flags|syscall.MS_REMOUNT, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remount %s read-only: %s", arguments.Target, err)
+	}
+	t.mounts.update(arguments.Target, flags, data)
+	return nil
+}
+
+// ListMounts replies with the mounts performed for the container, in
+// order.
+func (t *Methods) ListMounts(arguments *args.ListMountsArgs, reply *[]args.MountEntry) error {
+	*reply = t.mounts.list()
+	return nil
+}
+
+// UnwindMounts unmounts the mounts performed for the container in reverse
+// order, closes its encrypted devices, and sets reply to the number of
+// mounts unmounted. Targets are resolved in the current root directory, so
+// mounts made before a chroot can't be unwound after it. All mounts and
+// devices are attempted, the first error is returned.
+func (t *Methods) UnwindMounts(arguments *args.ListMountsArgs, reply *int) error {
+	var first error
+	entries := t.mounts.list()
+	for i := len(entries) - 1; i >= 0; i-- {
+		target := entries[i].Target
+		var err error
+		mainthread.Execute(func() {
+			err = syscall.Unmount(target, 0)
+		})
+		if err != nil {
+			rpcLog.Debugf("Failed to unmount %s: %s", target, err)
+			if first == nil {
+				first = fmt.Errorf("failed to unmount %s: %s", target, err)
+			}
+			continue
+		}
+		t.mounts.remove(target)
+		*reply++
+	}
+
+	names := t.crypt.list()
+	for i := len(names) - 1; i >= 0; i-- {
+		err := t.CryptClose(&args.CryptCloseArgs{Name: names[i]}, new(int))
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
 }
 
 // Mkdir performs a mkdir with the specified arguments.
@@ -47,7 +186,7 @@ func (t *Methods) Mkdir(arguments *args.MkdirArgs, reply *int) (err error) {
 func (t *Methods) Chroot(arguments *args.ChrootArgs, reply *int) error {
 	root := arguments.Root
 
-	sylog.Debugf("Change current directory to %s", root)
+	rpcLog.Debugf("Change current directory to %s", root)
 	if err := syscall.Chdir(root); err != nil {
 		return fmt.Errorf("failed to change directory to %s", root)
 	}
@@ -57,45 +196,45 @@ func (t *Methods) Chroot(arguments *args.ChrootArgs, reply *int) error {
 		// creation of temporary directory or use of existing directory
 		// for pivot_root.
 
-		sylog.Debugf("Hold reference to host / directory")
+		rpcLog.Debugf("Hold reference to host / directory")
 		oldroot, err := os.Open("/")
 		if err != nil {
 			return fmt.Errorf("failed to open host root directory: %s", err)
 		}
 		defer oldroot.Close()
 
-		sylog.Debugf("Called pivot_root on %s\n", root)
+		rpcLog.Debugf("Called pivot_root on %s\n", root)
 		if err := syscall.PivotRoot(".", "."); err != nil {
 			return fmt.Errorf("pivot_root %s: %s", root, err)
 		}
 
-		sylog.Debugf("Change current directory to host / directory")
+		rpcLog.Debugf("Change current directory to host / directory")
 		if err := syscall.Fchdir(int(oldroot.Fd())); err != nil {
 			return fmt.Errorf("failed to change directory to old root: %s", err)
 		}
 
-		sylog.Debugf("Apply slave mount propagation for host / directory")
+		rpcLog.Debugf("Apply slave mount propagation for host / directory")
 		if err := syscall.Mount("", ".", "", syscall.MS_SLAVE|syscall.MS_REC, ""); err != nil {
 			return fmt.Errorf("failed to apply slave mount propagation for host / directory: %s", err)
 		}
 
-		sylog.Debugf("Called unmount(/, syscall.MNT_DETACH)\n")
+		rpcLog.Debugf("Called unmount(/, syscall.MNT_DETACH)\n")
 		if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
 			return fmt.Errorf("unmount pivot_root dir %s", err)
 		}
 	} else {
-		sylog.Debugf("Move %s as / directory", root)
+		rpcLog.Debugf("Move %s as / directory", root)
 		if err := syscall.Mount(".", "/", "", syscall.MS_MOVE, ""); err != nil {
 			return fmt.Errorf("failed to move %s as / directory: %s", root, err)
 		}
 
-		sylog.Debugf("Chroot to %s", root)
+		rpcLog.Debugf("Chroot to %s", root)
 		if err := syscall.Chroot("."); err != nil {
 			return fmt.Errorf("chroot failed: %s", err)
 		}
 	}
 
-	sylog.Debugf("Changing directory to / to avoid getpwd issues\n")
+	rpcLog.Debugf("Changing directory to / to avoid getpwd issues\n")
 	if err := syscall.Chdir("/"); err != nil {
 		return fmt.Errorf("chdir / %s", err)
 	}
@@ -108,6 +247,7 @@ func (t *Methods) LoopDevice(arguments *args.LoopArgs, reply *int) error {
 
 	loopdev := new(loop.Device)
 	loopdev.MaxLoopDevices = arguments.MaxDevices
+	loopdev.Range = arguments.Range
 
 	if strings.HasPrefix(arguments.Image, "/proc/self/fd/") {
 		strFd := strings.TrimPrefix(arguments.Image, "/proc/self/fd/")
@@ -143,7 +283,17 @@ func (t *Methods) LoopDevice(arguments *args.LoopArgs, reply *int) error {
 	if err != nil {
 		return fmt.Errorf("could not attach image file too loop device: %v", err)
 	}
-	return loopdev.SetStatus(&arguments.Info)
+	if err := loopdev.SetStatus(&arguments.Info); err != nil {
+		// release the device, without auto clear flag it would leak
+		loopdev.Detach()
+		return err
+	}
+	// images are still usable with the default settings
+	if err := loopdev.Tune(arguments.Tuning); err != nil {
+		rpcLog.Warningf("Loop device settings not applied: %s", err)
+	}
+	t.crypt.attach(*reply)
+	return nil
 }
 
 // SetHostname sets hostname with the specified arguments.
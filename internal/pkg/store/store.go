@@ -0,0 +1,307 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package store implements a local content-addressed store for SIF images.
+// Data objects of a SIF image are kept as blobs named after their SHA256
+// digest, and the image file itself is replaced by a thin manifest listing
+// the blobs required to rebuild it. Images sharing identical partitions, like
+// a common base root filesystem, share the same blob on disk.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"syscall"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+// MediaType identifies a SIF manifest file
+const MediaType = "application/vnd.sylabs.sif.manifest.v1+json"
+
+// maxManifestSize is the maximum size of a manifest file
+const maxManifestSize = 1 << 20
+
+// digestRegexp matches the hex encoded SHA256 digests naming blobs
+var digestRegexp = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// Segment describes a contiguous part of a SIF image stored as a blob
+type Segment struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+	// Fstype is set to squashfs or ext3 for the primary system partition
+	Fstype string `json:"fstype,omitempty"`
+}
+
+// Manifest describes how to rebuild a SIF image from the blobs of a store
+type Manifest struct {
+	MediaType string    `json:"mediaType"`
+	Store     string    `json:"store"`
+	Size      int64     `json:"size"`
+	Segments  []Segment `json:"segments"`
+}
+
+// Store is a local content-addressed blob store
+type Store struct {
+	Root string
+}
+
+// New returns a store rooted at dir, creating it if necessary
+func New(dir string) (*Store, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %s", err)
+	}
+	if err := fs.MkdirAll(filepath.Join(root, "blobs", "sha256"), 0755); err != nil {
+		return nil, fmt.Errorf("while creating store %s: %s", root, err)
+	}
+	return &Store{Root: root}, nil
+}
+
+// BlobPath returns the path of the blob with the given digest
+func BlobPath(root, digest string) string {
+	return filepath.Join(root, "blobs", "sha256", digest)
+}
+
+// BlobPath returns the path of the blob with the given digest
+func (s *Store) BlobPath(digest string) string {
+	return BlobPath(s.Root, digest)
+}
+
+// putBlob stores the next size bytes of r as a blob and returns its digest.
+// Blobs already present in the store are not written twice.
+func (s *Store) putBlob(r io.Reader, size int64) (string, error) {
+	tmp, err := ioutil.TempFile(s.Root, ".blob-")
+	if err != nil {
+		return "", fmt.Errorf("while creating temporary blob: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(tmp, h), r, size); err != nil {
+		return "", fmt.Errorf("while writing blob: %s", err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	path := s.BlobPath(digest)
+	if _, err := os.Stat(path); err == nil {
+		sylog.Debugf("Blob %s already present in store", digest)
+		return digest, nil
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return "", fmt.Errorf("while setting blob permissions: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("while adding blob %s: %s", digest, err)
+	}
+	sylog.Debugf("Added blob %s (%d bytes) to store", digest, size)
+	return digest, nil
+}
+
+// Add splits the SIF image at path into blobs and returns the manifest
+// describing the image. The image file itself is left untouched.
+func (s *Store) Add(path string) (*Manifest, error) {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF image %s: %s", path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	primary := int64(-1)
+	fstype := ""
+	if part, _, err := fimg.GetPartPrimSys(); err == nil {
+		primary = part.Fileoff
+		t, err := part.GetFsType()
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case sif.FsSquash:
+			fstype = "squashfs"
+		case sif.FsExt3:
+			fstype = "ext3"
+		}
+	}
+
+	// split the file on data object boundaries, anything between data
+	// objects (header, descriptors, padding) goes to its own segment
+	var bounds []int64
+	for _, desc := range fimg.DescrArr {
+		if desc.Used && desc.Filelen > 0 {
+			bounds = append(bounds, desc.Fileoff, desc.Fileoff+desc.Filelen)
+		}
+	}
+	bounds = append(bounds, 0, fimg.Filesize)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	m := &Manifest{
+		MediaType: MediaType,
+		Store:     s.Root,
+		Size:      fimg.Filesize,
+	}
+
+	if _, err := fimg.Fp.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("while seeking %s: %s", path, err)
+	}
+	for i := 1; i < len(bounds); i++ {
+		size := bounds[i] - bounds[i-1]
+		if size == 0 {
+			continue
+		}
+		digest, err := s.putBlob(fimg.Fp, size)
+		if err != nil {
+			return nil, err
+		}
+		seg := Segment{Digest: digest, Size: size}
+		if bounds[i-1] == primary {
+			seg.Fstype = fstype
+		}
+		m.Segments = append(m.Segments, seg)
+	}
+
+	return m, nil
+}
+
+// Export rebuilds the SIF image described by manifest m into dest
+func Export(m *Manifest, dest string) error {
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %s", dest, err)
+	}
+	defer f.Close()
+
+	for _, seg := range m.Segments {
+		if err := copyBlob(f, BlobPath(m.Store, seg.Digest), seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlob copies the blob content into w and verifies its digest
+func copyBlob(w io.Writer, path string, seg Segment) error {
+	b, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("while opening blob: %s", err)
+	}
+	defer b.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), b)
+	if err != nil {
+		return fmt.Errorf("while copying blob %s: %s", seg.Digest, err)
+	}
+	if n != seg.Size || hex.EncodeToString(h.Sum(nil)) != seg.Digest {
+		return fmt.Errorf("blob %s is corrupted", seg.Digest)
+	}
+	return nil
+}
+
+// WriteManifest atomically replaces the file at path by manifest m
+func WriteManifest(m *Manifest, path string) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return fmt.Errorf("while encoding manifest: %s", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".manifest-")
+	if err != nil {
+		return fmt.Errorf("while creating manifest: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(b); err != nil {
+		return fmt.Errorf("while writing manifest: %s", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return fmt.Errorf("while setting manifest permissions: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("while replacing %s by manifest: %s", path, err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest found at path
+func ReadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return DecodeManifest(f)
+}
+
+// DecodeManifest decodes a manifest from r, an error is returned if the
+// content is not a SIF manifest
+func DecodeManifest(r io.Reader) (*Manifest, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil || m.MediaType != MediaType {
+		return nil, fmt.Errorf("not a SIF manifest")
+	}
+	if !filepath.IsAbs(m.Store) {
+		return nil, fmt.Errorf("manifest store %q is not an absolute path", m.Store)
+	}
+	for _, seg := range m.Segments {
+		if !digestRegexp.MatchString(seg.Digest) {
+			return nil, fmt.Errorf("invalid blob digest %q in manifest", seg.Digest)
+		}
+		if seg.Size <= 0 {
+			return nil, fmt.Errorf("invalid size %d for blob %s in manifest", seg.Size, seg.Digest)
+		}
+	}
+	return m, nil
+}
+
+// CheckTrusted returns an error if the store rooted at root is not trusted
+// by the user uid. The store root and its blob directories must be owned by
+// root or uid and must not be writable by group or others, so that nobody
+// else can substitute the blobs referenced by a manifest.
+func CheckTrusted(root string, uid uint32) error {
+	dirs := []string{root, filepath.Join(root, "blobs"), filepath.Join(root, "blobs", "sha256")}
+	for _, dir := range dirs {
+		fi, err := os.Lstat(dir)
+		if err != nil {
+			return err
+		}
+		st := fi.Sys().(*syscall.Stat_t)
+		switch {
+		case !fi.IsDir():
+			return fmt.Errorf("%s is not a directory", dir)
+		case st.Uid != 0 && st.Uid != uid:
+			return fmt.Errorf("%s is not owned by root or by user %d", dir, uid)
+		case fi.Mode().Perm()&0022 != 0:
+			return fmt.Errorf("%s is writable by group or others", dir)
+		}
+	}
+	return nil
+}
+
+// Primary returns the path of the blob holding the primary system partition
+// along with its segment description
+func (m *Manifest) Primary() (string, *Segment, error) {
+	for i, seg := range m.Segments {
+		if seg.Fstype != "" {
+			return BlobPath(m.Store, seg.Digest), &m.Segments[i], nil
+		}
+	}
+	return "", nil, fmt.Errorf("no primary system partition found in manifest")
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// createSIF creates a SIF image with a fake squashfs primary partition
+func createSIF(t *testing.T, path string, data []byte) {
+	part := filepath.Join(filepath.Dir(path), "part")
+	if err := ioutil.WriteFile(part, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(part)
+
+	fp, err := os.Open(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Size:     int64(len(data)),
+		Fname:    part,
+		Fp:       fp,
+	}
+	if err := input.SetPartExtra(sif.FsSquash, sif.PartPrimSys, sif.HdrArchAMD64); err != nil {
+		t.Fatal(err)
+	}
+
+	fimg, err := sif.CreateContainer(sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{input},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fimg.UnloadContainer()
+}
+
+func TestAddExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootfs := bytes.Repeat([]byte("rootfs"), 4096)
+	images := []string{filepath.Join(dir, "a.sif"), filepath.Join(dir, "b.sif")}
+
+	var primary []string
+	for _, img := range images {
+		createSIF(t, img, rootfs)
+		orig, err := ioutil.ReadFile(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := s.Add(img)
+		if err != nil {
+			t.Fatalf("unexpected error while adding %s: %s", img, err)
+		}
+		if m.Size != int64(len(orig)) {
+			t.Errorf("manifest size %d doesn't match image size %d", m.Size, len(orig))
+		}
+		if err := WriteManifest(m, img); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err = ReadManifest(img)
+		if err != nil {
+			t.Fatalf("unexpected error while reading manifest: %s", err)
+		}
+		path, seg, err := m.Primary()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if seg.Fstype != "squashfs" || seg.Size != int64(len(rootfs)) {
+			t.Errorf("unexpected primary partition segment %+v", seg)
+		}
+		primary = append(primary, path)
+
+		exported := img + ".export"
+		if err := Export(m, exported); err != nil {
+			t.Fatalf("unexpected error while exporting: %s", err)
+		}
+		b, err := ioutil.ReadFile(exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, orig) {
+			t.Errorf("exported image doesn't match original image")
+		}
+	}
+
+	if primary[0] != primary[1] {
+		t.Errorf("identical partitions were not deduplicated")
+	}
+
+	if _, err := ReadManifest(filepath.Join(dir, "a.sif.export")); err == nil {
+		t.Errorf("unexpected success while reading a SIF image as manifest")
+	}
+}
+
+func TestDecodeManifest(t *testing.T) {
+	digest := strings.Repeat("a", 64)
+	tests := []struct {
+		name     string
+		manifest string
+		ok       bool
+	}{
+		{"valid", `{"mediaType":"` + MediaType + `","store":"/store","segments":[{"digest":"` + digest + `","size":1}]}`, true},
+		{"wrong media type", `{"mediaType":"application/json","store":"/store"}`, false},
+		{"relative store", `{"mediaType":"` + MediaType + `","store":"store"}`, false},
+		{"traversal digest", `{"mediaType":"` + MediaType + `","store":"/store","segments":[{"digest":"../../../etc/passwd","size":1}]}`, false},
+		{"short digest", `{"mediaType":"` + MediaType + `","store":"/store","segments":[{"digest":"abcd","size":1}]}`, false},
+		{"uppercase digest", `{"mediaType":"` + MediaType + `","store":"/store","segments":[{"digest":"` + strings.ToUpper(digest) + `","size":1}]}`, false},
+		{"zero size", `{"mediaType":"` + MediaType + `","store":"/store","segments":[{"digest":"` + digest + `","size":0}]}`, false},
+	}
+	for _, tt := range tests {
+		_, err := DecodeManifest(strings.NewReader(tt.manifest))
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		}
+	}
+}
+
+func TestCheckTrusted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := New(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := uint32(os.Getuid())
+
+	if err := CheckTrusted(s.Root, uid); err != nil {
+		t.Errorf("unexpected error for store %s: %s", s.Root, err)
+	}
+	if err := CheckTrusted(filepath.Join(dir, "missing"), uid); err == nil {
+		t.Errorf("unexpected success for missing store")
+	}
+	blobs := filepath.Join(s.Root, "blobs", "sha256")
+	if err := os.Chmod(blobs, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckTrusted(s.Root, uid); err == nil {
+		t.Errorf("unexpected success for world writable store")
+	}
+	if err := os.Chmod(blobs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getuid() == 0 {
+		if err := os.Chown(s.Root, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckTrusted(s.Root, 0); err == nil {
+			t.Errorf("unexpected success for store owned by another user")
+		}
+	}
+}
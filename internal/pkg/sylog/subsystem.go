@@ -0,0 +1,82 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sylog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SubsystemEnv is the environment variable setting per subsystem log levels,
+// e.g. SINGULARITY_LOG=build=debug,engine=info
+const SubsystemEnv = "SINGULARITY_LOG"
+
+// subsystemLevels holds log levels overriding the global level for tagged
+// messages
+var subsystemLevels = map[string]messageLevel{}
+
+var levelNames = map[string]messageLevel{
+	"fatal":    fatal,
+	"error":    errorLevel,
+	"warn":     warn,
+	"warning":  warn,
+	"log":      log,
+	"info":     info,
+	"verbose":  verbose,
+	"verbose2": verbose2,
+	"verbose3": verbose3,
+	"debug":    debug,
+	"trace":    debug,
+}
+
+// Subsystem returns an Entry tagging messages with the given subsystem name,
+// their level is filtered by the subsystem level if one was set
+func Subsystem(name string) *Entry {
+	return &Entry{subsystem: name}
+}
+
+// SetSubsystemLevels sets per subsystem log levels from a comma separated
+// list of subsystem=level pairs, level is either a name or a number
+func SetSubsystemLevels(spec string) error {
+	levels := make(map[string]messageLevel)
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid subsystem level %q", pair)
+		}
+		level, ok := levelNames[strings.ToLower(kv[1])]
+		if !ok {
+			n, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return fmt.Errorf("invalid level %q for subsystem %s", kv[1], kv[0])
+			}
+			level = messageLevel(n)
+		}
+		levels[kv[0]] = level
+	}
+
+	for name, level := range levels {
+		subsystemLevels[name] = level
+	}
+	return nil
+}
+
+// subsystemNames returns the sorted names of subsystems with a level set
+func subsystemNames() []string {
+	names := make([]string, 0, len(subsystemLevels))
+	for name := range subsystemLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -8,28 +8,31 @@
 package sylog
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type messageLevel int
 
 const (
-	fatal    messageLevel = iota - 4 // fatal    : -4
-	error                            // error    : -3
-	warn                             // warn     : -2
-	log                              // log      : -1
-	_                                // SKIP     : 0
-	info                             // info     : 1
-	verbose                          // verbose  : 2
-	verbose2                         // verbose2 : 3
-	verbose3                         // verbose3 : 4
-	debug                            // debug    : 5
+	fatal      messageLevel = iota - 4 // fatal    : -4
+	errorLevel                         // error    : -3
+	warn                               // warn     : -2
+	log                                // log      : -1
+	_                                  // SKIP     : 0
+	info                               // info     : 1
+	verbose                            // verbose  : 2
+	verbose2                           // verbose2 : 3
+	verbose3                           // verbose3 : 4
+	debug                              // debug    : 5
 )
 
 func (l messageLevel) String() string {
@@ -43,62 +46,106 @@ func (l messageLevel) String() string {
 }
 
 var messageLabels = map[messageLevel]string{
-	fatal:    "FATAL",
-	error:    "ERROR",
-	warn:     "WARNING",
-	log:      "LOG",
-	info:     "INFO",
-	verbose:  "VERBOSE",
-	verbose2: "VERBOSE",
-	verbose3: "VERBOSE",
-	debug:    "DEBUG",
+	fatal:      "FATAL",
+	errorLevel: "ERROR",
+	warn:       "WARNING",
+	log:        "LOG",
+	info:       "INFO",
+	verbose:    "VERBOSE",
+	verbose2:   "VERBOSE",
+	verbose3:   "VERBOSE",
+	debug:      "DEBUG",
 }
 
 var messageColors = map[messageLevel]string{
-	fatal: "\x1b[31m",
-	error: "\x1b[31m",
-	warn:  "\x1b[33m",
-	info:  "\x1b[34m",
+	fatal:      "\x1b[31m",
+	errorLevel: "\x1b[31m",
+	warn:       "\x1b[33m",
+	info:       "\x1b[34m",
 }
 
 const colorReset string = "\x1b[0m"
 
+// Supported log output formats
+const (
+	// FormatText is the default human readable format
+	FormatText = "text"
+	// FormatJSON outputs one JSON object per message
+	FormatJSON = "json"
+)
+
 var loggerLevel messageLevel
 
+var loggerFormat = FormatText
+
+// fatalHook is called with the first error passed to Fatalf before exiting
+var fatalHook func(err error)
+
+// warningHook is called with each warning message, even when not displayed
+var warningHook func(msg string)
+
+// Fields holds structured data attached to a log message
+type Fields map[string]interface{}
+
+// Entry is a logger carrying fields added to each message it writes
+type Entry struct {
+	fields    Fields
+	subsystem string
+}
+
 func init() {
+	// SINGULARITY_MESSAGELEVEL holds the level optionally followed by the
+	// output format and subsystem levels, e.g. "5,json,build=1", C code
+	// only interprets the level
 	_level, ok := os.LookupEnv("SINGULARITY_MESSAGELEVEL")
 	if !ok {
 		loggerLevel = debug
 	} else {
-		_levelint, err := strconv.Atoi(_level)
+		split := strings.Split(_level, ",")
+		_levelint, err := strconv.Atoi(split[0])
 		if err != nil {
 			loggerLevel = debug
 		} else {
 			loggerLevel = messageLevel(_levelint)
 		}
-	}
-}
-
-func prefix(level messageLevel) string {
-	messageColor, ok := messageColors[level]
-	if !ok {
-		messageColor = "\x1b[0m"
+		for _, opt := range split[1:] {
+			if strings.Contains(opt, "=") {
+				SetSubsystemLevels(opt)
+			} else {
+				SetFormat(opt)
+			}
+		}
 	}
 
-	if loggerLevel < debug {
-		return fmt.Sprintf("%s%-8s%s ", messageColor, level.String()+":", colorReset)
+	if spec, ok := os.LookupEnv(SubsystemEnv); ok {
+		if err := SetSubsystemLevels(spec); err != nil {
+			Warningf("ignoring %s: %s", SubsystemEnv, err)
+		}
 	}
+}
 
+// caller returns the name of the function which called the public logging
+// function, it must be called from writef
+func caller() string {
 	pc, _, _, ok := runtime.Caller(3)
 	details := runtime.FuncForPC(pc)
 
-	var funcName string
 	if ok && details == nil {
 		fmt.Printf("Unable to get details of calling function\n")
-		funcName = "UNKNOWN CALLING FUNC"
-	} else {
-		funcNameSplit := strings.Split(details.Name(), ".")
-		funcName = funcNameSplit[len(funcNameSplit)-1] + "()"
+		return "UNKNOWN CALLING FUNC"
+	}
+	funcNameSplit := strings.Split(details.Name(), ".")
+	return funcNameSplit[len(funcNameSplit)-1] + "()"
+}
+
+func prefix(level messageLevel, funcName string) string {
+	messageColor, ok := messageColors[level]
+	if !ok {
+		messageColor = "\x1b[0m"
+	}
+
+	if funcName == "" {
+		return fmt.Sprintf("%s%-8s%s ", messageColor, level.String()+":", colorReset)
 	}
 
 	uid := os.Geteuid()
@@ -108,50 +155,211 @@ func prefix(level messageLevel) string {
 	return fmt.Sprintf("%s%-8s%s%-19s%-30s", messageColor, level, colorReset, uidStr, funcName)
 }
 
-func writef(level messageLevel, format string, a ...interface{}) {
-	if loggerLevel < level {
+func writef(level messageLevel, e *Entry, format string, a ...interface{}) {
+	var fields Fields
+
+	if level == warn && warningHook != nil {
+		warningHook(strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"))
+	}
+
+	maxLevel := loggerLevel
+	if e != nil {
+		fields = e.fields
+		if l, ok := subsystemLevels[e.subsystem]; ok {
+			maxLevel = l
+		}
+	}
+	if maxLevel < level {
 		return
 	}
 
 	message := fmt.Sprintf(format, a...)
 	message = strings.TrimSuffix(message, "\n")
 
-	fmt.Fprintf(os.Stderr, "%s%s\n", prefix(level), message)
+	if loggerFormat == FormatJSON {
+		if e != nil && e.subsystem != "" {
+			fields = e.WithFields(Fields{"subsystem": e.subsystem}).fields
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", jsonMessage(level, caller(), message, fields))
+		return
+	}
+
+	funcName := ""
+	if maxLevel >= debug {
+		funcName = caller()
+		if e != nil && e.subsystem != "" {
+			funcName = e.subsystem + ":" + funcName
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", prefix(level, funcName), message, textFields(fields))
+}
+
+// jsonMessage formats a message as a JSON object, fields are added at the
+// top level except those clashing with the standard keys
+func jsonMessage(level messageLevel, funcName, message string, fields Fields) []byte {
+	record := make(map[string]interface{}, len(fields)+6)
+	for k, v := range fields {
+		if err, ok := v.(interface{ Error() string }); ok {
+			v = err.Error()
+		}
+		record[k] = v
+	}
+	record["level"] = strings.ToLower(level.String())
+	record["time"] = time.Now().Format(time.RFC3339Nano)
+	record["caller"] = funcName
+	record["uid"] = os.Geteuid()
+	record["pid"] = os.Getpid()
+	record["msg"] = message
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"level":"error","msg":"failed to encode log message: %s"}`, err))
+	}
+	return b
+}
+
+// textFields formats fields as sorted key=value pairs
+func textFields(fields Fields) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	return b.String()
 }
 
 // Fatalf is equivalent to a call to Errorf followed by os.Exit(255). Code that
 // may be imported by other projects should NOT use Fatalf.
 func Fatalf(format string, a ...interface{}) {
-	writef(fatal, format, a...)
+	writef(fatal, nil, format, a...)
+	runFatalHook(a)
 	os.Exit(255)
 }
 
 // Errorf writes an ERROR level message to the log but does not exit. This
 // should be called when an error is being returned to the calling thread
 func Errorf(format string, a ...interface{}) {
-	writef(error, format, a...)
+	writef(errorLevel, nil, format, a...)
 }
 
 // Warningf writes a WARNING level message to the log.
 func Warningf(format string, a ...interface{}) {
-	writef(warn, format, a...)
+	writef(warn, nil, format, a...)
 }
 
 // Infof writes an INFO level message to the log. By default, INFO level messages
 // will always be output (unless running in silent)
 func Infof(format string, a ...interface{}) {
-	writef(info, format, a...)
+	writef(info, nil, format, a...)
 }
 
 // Verbosef writes a VERBOSE level message to the log. This should probably be
 // deprecated since the granularity is often too fine to be useful.
 func Verbosef(format string, a ...interface{}) {
-	writef(verbose, format, a...)
+	writef(verbose, nil, format, a...)
 }
 
 // Debugf writes a DEBUG level message to the log.
 func Debugf(format string, a ...interface{}) {
-	writef(debug, format, a...)
+	writef(debug, nil, format, a...)
+}
+
+// WithFields returns an Entry adding fields to each message it writes
+func WithFields(fields Fields) *Entry {
+	return (&Entry{}).WithFields(fields)
+}
+
+// WithFields returns a new Entry with fields added to the entry fields
+func (e *Entry) WithFields(fields Fields) *Entry {
+	f := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		f[k] = v
+	}
+	for k, v := range fields {
+		f[k] = v
+	}
+	return &Entry{fields: f, subsystem: e.subsystem}
+}
+
+// Fatalf is equivalent to a call to Errorf followed by os.Exit(255).
+func (e *Entry) Fatalf(format string, a ...interface{}) {
+	writef(fatal, e, format, a...)
+	runFatalHook(a)
+	os.Exit(255)
+}
+
+// Errorf writes an ERROR level message with the entry fields.
+func (e *Entry) Errorf(format string, a ...interface{}) {
+	writef(errorLevel, e, format, a...)
+}
+
+// Warningf writes a WARNING level message with the entry fields.
+func (e *Entry) Warningf(format string, a ...interface{}) {
+	writef(warn, e, format, a...)
+}
+
+// Infof writes an INFO level message with the entry fields.
+func (e *Entry) Infof(format string, a ...interface{}) {
+	writef(info, e, format, a...)
+}
+
+// Verbosef writes a VERBOSE level message with the entry fields.
+func (e *Entry) Verbosef(format string, a ...interface{}) {
+	writef(verbose, e, format, a...)
+}
+
+// Debugf writes a DEBUG level message with the entry fields.
+func (e *Entry) Debugf(format string, a ...interface{}) {
+	writef(debug, e, format, a...)
+}
+
+// SetFormat sets the log output format, either FormatText or FormatJSON
+func SetFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+		loggerFormat = format
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q", format)
+}
+
+// SetFatalHook sets a function called before Fatalf exits, it receives the
+// first error found in the message arguments or nil
+func SetFatalHook(fn func(err error)) {
+	fatalHook = fn
+}
+
+// SetWarningHook sets a function receiving every warning message, including
+// the ones hidden by the current log level
+func SetWarningHook(fn func(msg string)) {
+	warningHook = fn
+}
+
+func runFatalHook(a []interface{}) {
+	if fatalHook == nil {
+		return
+	}
+	for _, arg := range a {
+		if err, ok := arg.(interface{ Error() string }); ok {
+			fatalHook(err)
+			return
+		}
+	}
+	fatalHook(nil)
+}
+
+// GetFormat returns the current log output format
+func GetFormat() string {
+	return loggerFormat
 }
 
 // SetLevel explicitly sets the loggerLevel
@@ -167,7 +375,14 @@ func GetLevel() int {
 // GetEnvVar returns a formatted environment variable string which
 // can later be interpreted by init() in a child proc
 func GetEnvVar() string {
-	return fmt.Sprintf("SINGULARITY_MESSAGELEVEL=%d", loggerLevel)
+	env := fmt.Sprintf("SINGULARITY_MESSAGELEVEL=%d", loggerLevel)
+	if loggerFormat != FormatText {
+		env += "," + loggerFormat
+	}
+	for _, name := range subsystemNames() {
+		env += fmt.Sprintf(",%s=%d", name, subsystemLevels[name])
+	}
+	return env
 }
 
 // Writer returns an io.Writer to pass to an external packages logging utility.
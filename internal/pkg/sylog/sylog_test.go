@@ -0,0 +1,126 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sylog
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSetFormat(t *testing.T) {
+	defer SetFormat(FormatText)
+
+	if err := SetFormat("xml"); err == nil {
+		t.Errorf("unexpected success with unknown format")
+	}
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if GetFormat() != FormatJSON {
+		t.Errorf("unexpected format %s", GetFormat())
+	}
+
+	SetLevel(2)
+	if env := GetEnvVar(); env != "SINGULARITY_MESSAGELEVEL=2,json" {
+		t.Errorf("unexpected environment variable %s", env)
+	}
+	SetFormat(FormatText)
+	if env := GetEnvVar(); env != "SINGULARITY_MESSAGELEVEL=2" {
+		t.Errorf("unexpected environment variable %s", env)
+	}
+}
+
+func TestJSONMessage(t *testing.T) {
+	fields := Fields{
+		"image": "test.sif",
+		"err":   fmt.Errorf("failure"),
+		"msg":   "overridden",
+	}
+
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(jsonMessage(warn, "test()", "hello", fields), &record); err != nil {
+		t.Fatalf("unexpected error while decoding message: %s", err)
+	}
+
+	expected := map[string]string{
+		"level":  "warning",
+		"caller": "test()",
+		"msg":    "hello",
+		"image":  "test.sif",
+		"err":    "failure",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("unexpected value %v for key %s (expected %s)", record[k], k, v)
+		}
+	}
+	if _, ok := record["time"]; !ok {
+		t.Errorf("missing time key")
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	e := WithFields(Fields{"a": 1}).WithFields(Fields{"b": 2})
+	if s := textFields(e.fields); s != " a=1 b=2" {
+		t.Errorf("unexpected fields %q", s)
+	}
+	if s := textFields(nil); s != "" {
+		t.Errorf("unexpected fields %q", s)
+	}
+}
+
+func TestSetSubsystemLevels(t *testing.T) {
+	defer func() { subsystemLevels = map[string]messageLevel{} }()
+
+	if err := SetSubsystemLevels("build=debug,engine=1,rpc=trace"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]messageLevel{
+		"build":  debug,
+		"engine": info,
+		"rpc":    debug,
+	}
+	for name, level := range expected {
+		if subsystemLevels[name] != level {
+			t.Errorf("unexpected level %d for subsystem %s", subsystemLevels[name], name)
+		}
+	}
+
+	for _, spec := range []string{"build", "=debug", "build=loud"} {
+		if err := SetSubsystemLevels(spec); err == nil {
+			t.Errorf("unexpected success with %q", spec)
+		}
+	}
+
+	SetLevel(2)
+	if env := GetEnvVar(); env != "SINGULARITY_MESSAGELEVEL=2,build=5,engine=1,rpc=5" {
+		t.Errorf("unexpected environment variable %s", env)
+	}
+	if e := Subsystem("build").WithFields(Fields{"a": 1}); e.subsystem != "build" {
+		t.Errorf("subsystem lost while adding fields")
+	}
+}
+
+func TestSetWarningHook(t *testing.T) {
+	var warnings []string
+
+	SetWarningHook(func(msg string) {
+		warnings = append(warnings, msg)
+	})
+	defer SetWarningHook(nil)
+
+	SetLevel(int(fatal))
+	defer SetLevel(int(info))
+
+	Warningf("first %d\n", 1)
+	Infof("not a warning")
+	Subsystem("build").Warningf("second")
+
+	if len(warnings) != 2 || warnings[0] != "first 1" || warnings[1] != "second" {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
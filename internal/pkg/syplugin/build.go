@@ -7,6 +7,7 @@ package syplugin
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/sylabs/singularity/internal/pkg/build/types"
@@ -14,11 +15,15 @@ import (
 )
 
 var registeredBuildPlugins BuildPluginRegistry
+var registeredSectionPlugins SectionPluginRegistry
 
 func init() {
 	registeredBuildPlugins = BuildPluginRegistry{
 		Plugins: make(map[string]BuildPlugin),
 	}
+	registeredSectionPlugins = SectionPluginRegistry{
+		Plugins: make(map[string]SectionPlugin),
+	}
 }
 
 // BasePluginRegistry ...
@@ -32,6 +37,13 @@ type BuildPluginRegistry struct {
 	Plugins map[string]BuildPlugin
 }
 
+// SectionPluginRegistry ...
+type SectionPluginRegistry struct {
+	BasePluginRegistry
+	// Plugins maps custom definition sections to the plugin handling them
+	Plugins map[string]SectionPlugin
+}
+
 // RegisterBuildPlugin adds the plugin to the known plugins
 func RegisterBuildPlugin(_pl interface{}) error {
 	pl, ok := _pl.(BuildPlugin)
@@ -110,3 +122,75 @@ type BuildPlugin interface {
 	HandleBundle(*types.Bundle)
 	HandlePost() string
 }
+
+// RegisterSectionPlugin adds the plugin as the handler of its custom
+// definition sections
+func RegisterSectionPlugin(_pl interface{}) error {
+	pl, ok := _pl.(SectionPlugin)
+	if !ok {
+		return nil
+	}
+
+	registeredSectionPlugins.Lock()
+	defer registeredSectionPlugins.Unlock()
+
+	for _, section := range pl.Sections() {
+		if other, ok := registeredSectionPlugins.Plugins[section]; ok {
+			return fmt.Errorf("section %%%s already handled by plugin %s", section, other.Name())
+		}
+	}
+	for _, section := range pl.Sections() {
+		registeredSectionPlugins.Plugins[section] = pl
+	}
+	return nil
+}
+
+// IsBuildSection returns whether section is a custom definition section
+// handled by a plugin
+func IsBuildSection(section string) bool {
+	registeredSectionPlugins.Lock()
+	defer registeredSectionPlugins.Unlock()
+
+	_, ok := registeredSectionPlugins.Plugins[section]
+	return ok
+}
+
+// BuildHandleCustomSections runs the HandleBuildSection() hook of the plugins
+// for every custom section of the bundle definition. Sections are handled
+// one at a time in the order of their names, the first error stops the build.
+func BuildHandleCustomSections(b *types.Bundle) error {
+	sections := b.Recipe.BuildData.Sections
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		registeredSectionPlugins.Lock()
+		pl, ok := registeredSectionPlugins.Plugins[name]
+		registeredSectionPlugins.Unlock()
+		if !ok {
+			return fmt.Errorf("no plugin handles section %%%s", name)
+		}
+
+		sylog.Debugf("Running %s plugin: HandleBuildSection() hook for %%%s", pl.Name(), name)
+		if err := pl.HandleBuildSection(name, sections[name], b); err != nil {
+			return fmt.Errorf("while handling section %%%s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// SectionPlugin is the interface for plugins providing custom definition
+// sections, e.g. %spack or %conda. Sections() returns the names of the
+// sections without the % prefix, core sections can't be overridden.
+// HandleBuildSection() is called during the build with the body of each
+// section found in the definition and the bundle being built.
+type SectionPlugin interface {
+	Name() string
+	Sections() []string
+	HandleBuildSection(section string, body string, b *types.Bundle) error
+}
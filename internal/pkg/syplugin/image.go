@@ -0,0 +1,97 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the URIs of this project regarding your
+// rights to use or distribute this software.
+
+package syplugin
+
+import (
+	"fmt"
+)
+
+// ImageFeatures are the filesystems an image driver can mount
+type ImageFeatures uint
+
+// Image driver features
+const (
+	ImageFeatureSquashfs ImageFeatures = 1 << iota
+	ImageFeatureExt3
+	ImageFeatureOverlay
+)
+
+// imageFeatures maps mount types to the feature required to mount them
+var imageFeatures = map[string]ImageFeatures{
+	"squashfs": ImageFeatureSquashfs,
+	"ext3":     ImageFeatureExt3,
+	"overlay":  ImageFeatureOverlay,
+}
+
+// Supports returns whether the filesystem fstype is part of the features
+func (f ImageFeatures) Supports(fstype string) bool {
+	feature, ok := imageFeatures[fstype]
+	return ok && f&feature != 0
+}
+
+// ImageMountParams describes a filesystem to mount with an image driver
+type ImageMountParams struct {
+	// Source is the image file, Offset and Size locate the filesystem in
+	// it. Source is empty for overlays.
+	Source string
+	Offset uint64
+	Size   uint64
+	Target string
+	// Filesystem is squashfs, ext3 or overlay
+	Filesystem string
+	Readonly   bool
+	// Data holds the mount options, like the lowerdir, upperdir and workdir
+	// of overlays
+	Data string
+}
+
+// ImageDriver is the interface for plugins mounting the filesystems of
+// container images instead of loop devices, e.g. with FUSE or network
+// backed filesystems. Drivers run in the engine process, without the
+// privileges of the RPC server.
+type ImageDriver interface {
+	Name() string
+	// Features returns the filesystems the driver mounts, others are
+	// still mounted with loop devices
+	Features() ImageFeatures
+	Mount(params *ImageMountParams) error
+	Unmount(target string) error
+}
+
+var registeredImageDrivers = struct {
+	BasePluginRegistry
+	Drivers map[string]ImageDriver
+}{Drivers: make(map[string]ImageDriver)}
+
+// RegisterImageDriver adds the plugin to the known image drivers
+func RegisterImageDriver(_pl interface{}) error {
+	pl, ok := _pl.(ImageDriver)
+	if !ok {
+		return nil
+	}
+
+	registeredImageDrivers.Lock()
+	defer registeredImageDrivers.Unlock()
+
+	if _, ok := registeredImageDrivers.Drivers[pl.Name()]; ok {
+		return fmt.Errorf("image driver already registered: %s", pl.Name())
+	}
+
+	registeredImageDrivers.Drivers[pl.Name()] = pl
+	return nil
+}
+
+// GetImageDriver returns the image driver registered as name
+func GetImageDriver(name string) (ImageDriver, error) {
+	registeredImageDrivers.Lock()
+	defer registeredImageDrivers.Unlock()
+
+	d, ok := registeredImageDrivers.Drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("image driver %s is not registered", name)
+	}
+	return d, nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the URIs of this project regarding your
+// rights to use or distribute this software.
+
+package syplugin
+
+import (
+	"testing"
+)
+
+type testImageDriver struct{}
+
+func (testImageDriver) Name() string                         { return "test" }
+func (testImageDriver) Features() ImageFeatures              { return ImageFeatureSquashfs | ImageFeatureOverlay }
+func (testImageDriver) Mount(params *ImageMountParams) error { return nil }
+func (testImageDriver) Unmount(target string) error          { return nil }
+
+func TestRegisterImageDriver(t *testing.T) {
+	if _, err := GetImageDriver("test"); err == nil {
+		t.Errorf("unexpected success getting an unregistered image driver")
+	}
+	if err := RegisterImageDriver(struct{}{}); err != nil {
+		t.Errorf("unexpected error registering a plugin which isn't an image driver: %v", err)
+	}
+	if err := RegisterImageDriver(testImageDriver{}); err != nil {
+		t.Fatalf("unexpected error registering image driver: %v", err)
+	}
+	if err := RegisterImageDriver(testImageDriver{}); err == nil {
+		t.Errorf("unexpected success registering an image driver twice")
+	}
+
+	d, err := GetImageDriver("test")
+	if err != nil {
+		t.Fatalf("unexpected error getting image driver: %v", err)
+	}
+	for fstype, supported := range map[string]bool{"squashfs": true, "overlay": true, "ext3": false, "xfs": false} {
+		if d.Features().Supports(fstype) != supported {
+			t.Errorf("unexpected support of %s filesystems", fstype)
+		}
+	}
+}
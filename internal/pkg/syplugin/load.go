@@ -19,7 +19,9 @@ import (
 type pluginRegisterFn func(interface{}) error
 
 var pluginRegisterFuncs = map[string]pluginRegisterFn{
-	"BuildPlugin": RegisterBuildPlugin,
+	"BuildPlugin":   RegisterBuildPlugin,
+	"SectionPlugin": RegisterSectionPlugin,
+	"ImageDriver":   RegisterImageDriver,
 }
 
 func loadPlugins(pattern string) (pls []*plugin.Plugin, err error) {
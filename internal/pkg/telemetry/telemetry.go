@@ -0,0 +1,176 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package telemetry implements strictly opt-in anonymous usage reporting.
+// Nothing is sent unless the SINGULARITY_TELEMETRY_URL environment variable
+// is set. Events only hold the command name, the image source type and an
+// error class, never paths, image names or error messages.
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/uri"
+)
+
+// EndpointEnv is the environment variable enabling telemetry and holding
+// the URL events are posted to
+const EndpointEnv = "SINGULARITY_TELEMETRY_URL"
+
+// sendTimeout is the maximum time spent sending an event
+const sendTimeout = 2 * time.Second
+
+// flushTimeout is the maximum time Flush waits for the event to be sent,
+// the event is dropped past it
+const flushTimeout = 500 * time.Millisecond
+
+// Error classes
+const (
+	ErrorNotFound   = "not-found"
+	ErrorPermission = "permission"
+	ErrorNetwork    = "network"
+	ErrorOther      = "other"
+)
+
+// sourceTypes are the image source types reported as is, any other
+// transport is reported as other
+var sourceTypes = map[string]bool{
+	"library":        true,
+	"shub":           true,
+	"docker":         true,
+	"docker-archive": true,
+	"docker-daemon":  true,
+	"oci":            true,
+	"oci-archive":    true,
+	"http":           true,
+	"https":          true,
+	"instance":       true,
+}
+
+// Event is an anonymous usage record
+type Event struct {
+	Version    string `json:"version"`
+	Command    string `json:"command"`
+	Source     string `json:"source,omitempty"`
+	ErrorClass string `json:"error,omitempty"`
+}
+
+// Endpoint returns the URL events are sent to, telemetry is disabled when
+// it is empty
+func Endpoint() string {
+	return os.Getenv(EndpointEnv)
+}
+
+// SourceType returns the source type of the first image reference found
+// in args, references without transport are reported as local
+func SourceType(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	for _, arg := range args {
+		transport, _ := uri.Split(arg)
+		if transport == "" {
+			continue
+		}
+		if sourceTypes[transport] {
+			return transport
+		}
+		return "other"
+	}
+	return "local"
+}
+
+// ErrorClass returns the class of err
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ErrorOther
+	case os.IsNotExist(err):
+		return ErrorNotFound
+	case os.IsPermission(err):
+		return ErrorPermission
+	}
+	if _, ok := err.(net.Error); ok {
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// run holds the event of the current run, sent once by Flush
+var run struct {
+	sync.Mutex
+	event   *Event
+	flushed bool
+}
+
+// Record records e as the event of the current run if telemetry is
+// enabled, it is sent by Flush
+func Record(e Event) {
+	if Endpoint() == "" {
+		return
+	}
+	run.Lock()
+	defer run.Unlock()
+	e.Version = buildcfg.PACKAGE_VERSION
+	run.event = &e
+}
+
+// Flush sends the event of the current run with the class of err, if not
+// nil, in the background and waits at most flushTimeout for it. It must be
+// called before the process exits or is replaced, the event is only sent
+// by the first call. Failures are only reported at debug level.
+func Flush(err error) {
+	run.Lock()
+	if run.event == nil || run.flushed {
+		run.Unlock()
+		return
+	}
+	run.flushed = true
+	e := *run.event
+	run.Unlock()
+
+	if err != nil {
+		e.ErrorClass = ErrorClass(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- post(Endpoint(), e)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			sylog.Debugf("Could not send telemetry event: %s", err)
+		}
+	case <-time.After(flushTimeout):
+		sylog.Debugf("Telemetry event not sent after %s, dropping it", flushTimeout)
+	}
+}
+
+func post(endpoint string, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: sendTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
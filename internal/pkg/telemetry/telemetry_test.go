@@ -0,0 +1,98 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSourceType(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"/home/user/image.sif"}, "local"},
+		{[]string{"image.sif", "docker://ubuntu"}, "docker"},
+		{[]string{"library://user/collection/image:tag"}, "library"},
+		{[]string{"secret://whatever"}, "local"},
+	}
+	for _, tt := range tests {
+		if s := SourceType(tt.args); s != tt.expected {
+			t.Errorf("unexpected source type %q for %v (expected %q)", s, tt.args, tt.expected)
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	_, err := os.Open("/nonexistent/path")
+	if c := ErrorClass(err); c != ErrorNotFound {
+		t.Errorf("unexpected class %s", c)
+	}
+	if c := ErrorClass(fmt.Errorf("failure")); c != ErrorOther {
+		t.Errorf("unexpected class %s", c)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	events := make(chan Event, 2)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("unexpected error while decoding event: %s", err)
+		}
+		events <- e
+	}))
+	defer ts.Close()
+
+	defer os.Unsetenv(EndpointEnv)
+	reset := func() {
+		run.event = nil
+		run.flushed = false
+	}
+	defer reset()
+
+	Record(Event{Command: "disabled"})
+	Flush(nil)
+	if run.event != nil || len(events) != 0 {
+		t.Errorf("event sent while telemetry is disabled")
+	}
+
+	os.Setenv(EndpointEnv, ts.URL)
+	Record(Event{Command: "pull", Source: "docker"})
+	Flush(os.ErrNotExist)
+	Flush(nil)
+	if len(events) != 1 {
+		t.Fatalf("unexpected number of events sent: %d", len(events))
+	}
+	if e := <-events; e.Command != "pull" || e.Source != "docker" || e.ErrorClass != ErrorNotFound || e.Version == "" {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	// an unresponsive endpoint doesn't delay the exit past flushTimeout
+	block := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer slow.Close()
+	defer close(block)
+
+	reset()
+	os.Setenv(EndpointEnv, slow.URL)
+	Record(Event{Command: "exec"})
+	start := time.Now()
+	Flush(nil)
+	if d := time.Since(start); d > 2*flushTimeout {
+		t.Errorf("flush took %s with an unresponsive endpoint", d)
+	}
+}
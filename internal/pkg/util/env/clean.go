@@ -6,7 +6,9 @@
 package env
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/opencontainers/runtime-tools/generate"
@@ -26,8 +28,36 @@ var alwaysPassKeys = map[string]bool{
 	"ftp_proxy":   true,
 }
 
+// Filter holds shell patterns selecting the host environment variables
+// passed to the container. When Include is not empty only matching
+// variables are passed, variables matching Exclude are never passed.
+// SINGULARITYENV_ variables are not filtered.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate checks that filter patterns are well formed
+func (f Filter) Validate() error {
+	for _, pattern := range append(f.Include, f.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad environment filter pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // SetContainerEnv cleans environment variables before running the container
-func SetContainerEnv(g *generate.Generator, env []string, cleanEnv bool, homeDest string) {
+func SetContainerEnv(g *generate.Generator, env []string, cleanEnv bool, filter Filter, homeDest string) {
 	// first deal with special variables that allow user to control $PATH at
 	// runtime (meh... special cases)
 	if prependPath := os.Getenv("SINGULARITYENV_PREPEND_PATH"); prependPath != "" {
@@ -57,7 +87,7 @@ func SetContainerEnv(g *generate.Generator, env []string, cleanEnv bool, homeDes
 		}
 
 		// Transpose host env variables into config
-		if addKey, ok := addIfReq(e[0], cleanEnv); ok {
+		if addKey, ok := addIfReq(e[0], cleanEnv, filter); ok {
 			g.AddProcessEnv(addKey, e[1])
 		}
 	}
@@ -71,10 +101,16 @@ func SetContainerEnv(g *generate.Generator, env []string, cleanEnv bool, homeDes
 	}
 }
 
-func addIfReq(key string, cleanEnv bool) (string, bool) {
+func addIfReq(key string, cleanEnv bool, filter Filter) (string, bool) {
 	if strings.HasPrefix(key, envPrefix) {
 		return strings.TrimPrefix(key, envPrefix), true
-	} else if _, ok := alwaysPassKeys[key]; cleanEnv && !ok {
+	} else if matchAny(filter.Exclude, key) {
+		return "", false
+	} else if _, ok := alwaysPassKeys[key]; ok {
+		return key, true
+	} else if len(filter.Include) > 0 {
+		return key, matchAny(filter.Include, key)
+	} else if cleanEnv {
 		return "", false
 	}
 
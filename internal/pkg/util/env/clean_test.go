@@ -25,6 +25,7 @@ func TestSetContainerEnv(t *testing.T) {
 	type args struct {
 		env       []string
 		cleanEnv  bool
+		filter    Filter
 		homeDest  string
 		resultEnv []string
 	}
@@ -36,7 +37,7 @@ func TestSetContainerEnv(t *testing.T) {
 			args: args{[]string{"LD_LIBRARY_PATH=/.singularity.d/libs", "HOME=/home/tester",
 				"PS1=test", "TERM=xterm-256color", "PATH=/usr/games:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
 				"LANG=C", "SINGULARITY_CONTAINER=/tmp/lolcow.sif", "PWD=/tmp", "LC_ALL=C",
-				"SINGULARITY_NAME=lolcow.sif"}, false, "/home/tester",
+				"SINGULARITY_NAME=lolcow.sif"}, false, Filter{}, "/home/tester",
 				[]string{"LD_LIBRARY_PATH=/.singularity.d/libs", "HOME=/home/tester", "PS1=test",
 					"TERM=xterm-256color", "PATH=/bin:/sbin:/usr/bin:/usr/sbin:/usr/local/bin:/usr/local/sbin",
 					"LANG=C", "SINGULARITY_CONTAINER=/tmp/lolcow.sif", "PWD=/tmp", "LC_ALL=C",
@@ -46,7 +47,7 @@ func TestSetContainerEnv(t *testing.T) {
 			args: args{[]string{"LD_LIBRARY_PATH=/.singularity.d/libs", "HOME=/home/tester",
 				"PS1=test", "TERM=xterm-256color", "PATH=/usr/games:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
 				"LANG=C", "SINGULARITY_CONTAINER=/tmp/lolcow.sif", "PWD=/tmp", "LC_ALL=C",
-				"SINGULARITY_NAME=lolcow.sif", "SINGULARITYENV_FOO=VAR", "CLEANENV=TRUE"}, true, "/home/tester",
+				"SINGULARITY_NAME=lolcow.sif", "SINGULARITYENV_FOO=VAR", "CLEANENV=TRUE"}, true, Filter{}, "/home/tester",
 				[]string{"LD_LIBRARY_PATH=/.singularity.d/libs", "HOME=/home/tester", "PS1=test",
 					"TERM=xterm-256color", "PATH=/bin:/sbin:/usr/bin:/usr/sbin:/usr/local/bin:/usr/local/sbin",
 					"LANG=C", "SINGULARITY_CONTAINER=/tmp/lolcow.sif", "PWD=/tmp", "LC_ALL=C",
@@ -56,14 +57,14 @@ func TestSetContainerEnv(t *testing.T) {
 			args: args{[]string{"LD_LIBRARY_PATH=/.singularity.d/libs", "HOME=/home/tester",
 				"PS1=test", "TERM=xterm-256color", "PATH=/usr/games:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
 				"LANG=C", "SINGULARITY_CONTAINER=/tmp/lolcow.sif", "PWD=/tmp", "LC_ALL=C", "http_proxy=test_proxy", "no_proxy=noproxy",
-				"ftp_proxy=ftpProxy", "SINGULARITY_NAME=lolcow.sif", "SINGULARITYENV_FOO=VAR", "CLEANENV=TRUE"}, true, "/home/tester",
+				"ftp_proxy=ftpProxy", "SINGULARITY_NAME=lolcow.sif", "SINGULARITYENV_FOO=VAR", "CLEANENV=TRUE"}, true, Filter{}, "/home/tester",
 				[]string{"LD_LIBRARY_PATH=/.singularity.d/libs", "HOME=/home/tester", "PS1=test", "TERM=xterm-256color", "PATH=/bin:/sbin:/usr/bin:/usr/sbin:/usr/local/bin:/usr/local/sbin",
 					"LANG=C", "SINGULARITY_CONTAINER=/tmp/lolcow.sif", "PWD=/tmp", "LC_ALL=C", "SINGULARITY_NAME=lolcow.sif", "FOO=VAR", "http_proxy=test_proxy", "no_proxy=noproxy", "ftp_proxy=ftpProxy"},
 			}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			SetContainerEnv(&generator, tt.args.env, tt.args.cleanEnv, tt.args.homeDest)
+			SetContainerEnv(&generator, tt.args.env, tt.args.cleanEnv, tt.args.filter, tt.args.homeDest)
 			if !equal(ociConfig.Process.Env, tt.args.resultEnv) {
 				fmt.Println(ociConfig.Process.Env)
 				t.Fail()
@@ -72,6 +73,44 @@ func TestSetContainerEnv(t *testing.T) {
 	}
 }
 
+func TestAddIfReq(t *testing.T) {
+	filter := Filter{
+		Include: []string{"SLURM_*", "PMI_*"},
+		Exclude: []string{"SLURM_JOB_ID", "no_proxy"},
+	}
+
+	tests := []struct {
+		key      string
+		cleanEnv bool
+		filter   Filter
+		pass     bool
+	}{
+		{"PS1", false, Filter{}, true},
+		{"PS1", true, Filter{}, false},
+		{"PS1", false, filter, false},
+		{"SLURM_NODELIST", false, filter, true},
+		{"SLURM_NODELIST", true, filter, true},
+		{"PMI_RANK", true, filter, true},
+		{"SLURM_JOB_ID", false, filter, false},
+		{"TERM", true, filter, true},
+		{"no_proxy", false, filter, false},
+		{"SINGULARITYENV_SLURM_JOB_ID", false, filter, true},
+		{"PS1", false, Filter{Exclude: []string{"PS*"}}, false},
+	}
+	for _, tt := range tests {
+		if _, pass := addIfReq(tt.key, tt.cleanEnv, tt.filter); pass != tt.pass {
+			t.Errorf("unexpected result %v for %s with cleanenv %v and filter %v", pass, tt.key, tt.cleanEnv, tt.filter)
+		}
+	}
+
+	if err := (Filter{Include: []string{"["}}).Validate(); err == nil {
+		t.Errorf("unexpected success with bad pattern")
+	}
+	if err := filter.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
 // equal tells whether a and b contain the same elements.
 // A nil argument is equivalent to an empty slice.
 func equal(a, b []string) bool {
@@ -0,0 +1,215 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// CopyOptions holds the settings of a copy
+type CopyOptions struct {
+	// Exclude holds the patterns of the files not to copy, see Excluded
+	Exclude []string
+	// PreserveOwner keeps the ownership of the copied files
+	PreserveOwner bool
+	// PreserveXattrs keeps the extended attributes of the copied files
+	PreserveXattrs bool
+}
+
+// Copy copies the files matching src, see Glob, to dst like cp -fLr does.
+// dst is created as a directory if src matches several files. Excluded files,
+// and the content of excluded directories, are not copied.
+func Copy(src, dst string, opts CopyOptions) error {
+	matches, err := Glob(src)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no such file or directory: %s", src)
+	}
+	if len(matches) > 1 {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	}
+
+	var sources []string
+	for _, m := range matches {
+		if !Excluded(opts.Exclude, m) {
+			sources = append(sources, m)
+		}
+	}
+
+	if len(opts.Exclude) == 0 {
+		args := []string{"-fLr"}
+		var preserve []string
+		if opts.PreserveOwner {
+			preserve = append(preserve, "ownership")
+		}
+		if opts.PreserveXattrs {
+			preserve = append(preserve, "xattr")
+		}
+		if len(preserve) > 0 {
+			args = append(args, "--preserve="+strings.Join(preserve, ","))
+		}
+		args = append(args, sources...)
+		args = append(args, dst)
+		if out, err := exec.Command("/bin/cp", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+
+	c := &copier{opts: opts}
+	for _, s := range sources {
+		target := dst
+		if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+			target = filepath.Join(dst, filepath.Base(s))
+		}
+		if err := c.copy(s, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileID identifies a file by device and inode number
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// copier copies directory trees skipping excluded files, it follows
+// symbolic links like cp -L
+type copier struct {
+	opts CopyOptions
+	// ancestors holds the directories being copied, a link to one of
+	// them would be followed forever
+	ancestors map[fileID]bool
+}
+
+func (c *copier) copy(src, dst string) error {
+	if Excluded(c.opts.Exclude, src) {
+		return nil
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fi.IsDir():
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			id := fileID{uint64(st.Dev), st.Ino}
+			if c.ancestors[id] {
+				return fmt.Errorf("%s: symbolic link cycle, it links to a parent directory", src)
+			}
+			if c.ancestors == nil {
+				c.ancestors = make(map[fileID]bool)
+			}
+			c.ancestors[id] = true
+			defer delete(c.ancestors, id)
+		}
+		if err := os.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		d, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		names, err := d.Readdirnames(-1)
+		d.Close()
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := c.copy(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+				return err
+			}
+		}
+	case fi.Mode().IsRegular():
+		if err := copyFile(src, dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: unsupported file type %s", src, fi.Mode().String())
+	}
+
+	return c.preserve(src, dst, fi)
+}
+
+// preserve applies the ownership and extended attributes of src to dst if
+// requested
+func (c *copier) preserve(src, dst string, fi os.FileInfo) error {
+	if c.opts.PreserveOwner {
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+	if !c.opts.PreserveXattrs {
+		return nil
+	}
+
+	size, err := unix.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		return err
+	}
+	list := make([]byte, size)
+	if size, err = unix.Listxattr(src, list); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(strings.TrimRight(string(list[:size]), "\x00"), "\x00") {
+		size, err := unix.Getxattr(src, name, nil)
+		if err != nil {
+			return fmt.Errorf("while reading xattr %s of %s: %v", name, src, err)
+		}
+		value := make([]byte, size)
+		if size, err = unix.Getxattr(src, name, value); err != nil {
+			return fmt.Errorf("while reading xattr %s of %s: %v", name, src, err)
+		}
+		if err := unix.Lsetxattr(dst, name, value[:size], 0); err != nil {
+			return fmt.Errorf("while setting xattr %s of %s: %v", name, dst, err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies the content of the regular file src to dst, replacing dst
+// if it can't be opened
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		os.Remove(dst)
+		if out, err = os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"src/*.c", "src/main.c", true},
+		{"src/*.c", "src/lib/main.c", false},
+		{"src/**", "src/lib/main.c", true},
+		{"src/**", "src", false},
+		{"**/*.o", "main.o", true},
+		{"**/*.o", "src/lib/main.o", true},
+		{"**/*.o", "src/lib/main.c", false},
+		{"src/**/test", "src/test", true},
+		{"src/**/test", "src/a/b/test", true},
+		{"/opt/**/*.so", "/opt/lib/libc.so", true},
+	}
+
+	for _, tt := range tests {
+		match, err := Match(tt.pattern, tt.path)
+		if err != nil {
+			t.Errorf("unexpected error matching %s with %s: %v", tt.path, tt.pattern, err)
+		}
+		if match != tt.match {
+			t.Errorf("Match(%q, %q) returned %v, expected %v", tt.pattern, tt.path, match, tt.match)
+		}
+	}
+}
+
+// makeTree creates files in dir
+func makeTree(t *testing.T, dir string, files ...string) {
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+}
+
+// listTree returns the files in dir
+func listTree(t *testing.T, dir string) (files []string) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	makeTree(t, dir, "src/main.c", "src/main.o", "src/lib/lib.c", "src/lib/lib.o", "file[1]")
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"src/*.c", []string{"src/main.c"}},
+		{"src/**", []string{"src/lib", "src/main.c", "src/main.o"}},
+		{"src/**/*.o", []string{"src/lib/lib.o", "src/main.o"}},
+		{"file[1]", []string{"file[1]"}},
+		{"missing/**", nil},
+	}
+
+	for _, tt := range tests {
+		matches, err := Glob(filepath.Join(dir, tt.pattern))
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", tt.pattern, err)
+		}
+		var rel []string
+		for _, m := range matches {
+			r, _ := filepath.Rel(dir, m)
+			rel = append(rel, r)
+		}
+		if !reflect.DeepEqual(rel, tt.expected) {
+			t.Errorf("Glob(%q) returned %v, expected %v", tt.pattern, rel, tt.expected)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	makeTree(t, src, "main.c", "main.o", "lib/lib.c", "lib/lib.o", "build/out")
+
+	tests := []struct {
+		name     string
+		src      string
+		opts     CopyOptions
+		expected []string
+	}{
+		{
+			name:     "Directory",
+			src:      src,
+			expected: []string{"build/out", "lib/lib.c", "lib/lib.o", "main.c", "main.o"},
+		},
+		{
+			name:     "Glob",
+			src:      filepath.Join(src, "**/*.c"),
+			expected: []string{"lib.c", "main.c"},
+		},
+		{
+			name:     "Exclude",
+			src:      filepath.Join(src, "**"),
+			opts:     CopyOptions{Exclude: []string{"**/*.o", "build"}},
+			expected: []string{"lib/lib.c", "main.c"},
+		},
+		{
+			name:     "ExcludeDirectory",
+			src:      src,
+			opts:     CopyOptions{Exclude: []string{"*.o"}, PreserveOwner: true},
+			expected: []string{"build/out", "lib/lib.c", "main.c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(dir, "dst-"+tt.name)
+			if err := Copy(tt.src, dst, tt.opts); err != nil {
+				t.Fatalf("failed to copy: %v", err)
+			}
+			if files := listTree(t, dst); !reflect.DeepEqual(files, tt.expected) {
+				t.Errorf("copied %v, expected %v", files, tt.expected)
+			}
+		})
+	}
+
+	if err := Copy(filepath.Join(src, "*.h"), filepath.Join(dir, "none"), CopyOptions{}); err == nil {
+		t.Errorf("unexpected success copying no file")
+	}
+}
+
+func TestCopyXattrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy-xattrs-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	makeTree(t, dir, "src/file", "src/file.o")
+
+	if err := unix.Setxattr(filepath.Join(dir, "src/file"), "user.test", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	opts := CopyOptions{Exclude: []string{"*.o"}, PreserveXattrs: true}
+	if err := Copy(filepath.Join(dir, "src"), dst, opts); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+
+	value := make([]byte, 16)
+	n, err := unix.Getxattr(filepath.Join(dst, "file"), "user.test", value)
+	if err != nil || string(value[:n]) != "value" {
+		t.Errorf("extended attribute not preserved: %q, %v", value[:n], err)
+	}
+}
+
+func TestCopySymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	makeTree(t, src, "main.c", "main.o", "lib/lib.c")
+	// links to the same directory in two places are both followed
+	if err := os.Symlink("lib", filepath.Join(src, "lib2")); err != nil {
+		t.Fatalf("failed to create link: %v", err)
+	}
+
+	opts := CopyOptions{Exclude: []string{"*.o"}}
+	dst := filepath.Join(dir, "dst")
+	if err := Copy(src, dst, opts); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	expected := []string{"lib/lib.c", "lib2/lib.c", "main.c"}
+	if files := listTree(t, dst); !reflect.DeepEqual(files, expected) {
+		t.Errorf("copied %v, expected %v", files, expected)
+	}
+
+	tests := []struct {
+		name   string
+		link   string
+		target string
+	}{
+		{"Parent", filepath.Join(src, "lib", "parent"), ".."},
+		{"Self", filepath.Join(src, "lib", "self"), "."},
+		{"Root", filepath.Join(src, "lib", "root"), src},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.Symlink(tt.target, tt.link); err != nil {
+				t.Fatalf("failed to create link: %v", err)
+			}
+			defer os.Remove(tt.link)
+
+			dst := filepath.Join(dir, "dst-"+tt.name)
+			// without detection the copy fails once paths are too long
+			err := Copy(src, dst, opts)
+			if err == nil || !strings.Contains(err.Error(), "symbolic link cycle") {
+				t.Errorf("unexpected error copying a symbolic link cycle: %v", err)
+			}
+		})
+	}
+}
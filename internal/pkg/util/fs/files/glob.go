@@ -0,0 +1,108 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Match reports whether path matches the shell pattern, as filepath.Match
+// does, with ** matching any number of directories. A trailing /** matches
+// everything inside a directory but not the directory itself.
+func Match(pattern, path string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, path []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(path) > 0, nil
+			}
+			for i := 0; i <= len(path); i++ {
+				if ok, err := matchSegments(pattern[1:], path[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(path) == 0 {
+			return false, nil
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0, nil
+}
+
+// Excluded reports whether path matches one of the exclusion patterns.
+// Patterns without a slash are also matched against the base name of path.
+func Excluded(patterns []string, path string) bool {
+	for _, p := range patterns {
+		p = filepath.Clean(p)
+		if ok, _ := Match(p, path); ok {
+			return true
+		}
+		if !strings.Contains(p, "/") {
+			if ok, _ := filepath.Match(p, filepath.Base(path)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Glob returns the paths matching pattern, see Match. A path which exists
+// is returned as is even if it contains wildcard characters. Matches inside
+// a matched directory are omitted since they are copied with it.
+func Glob(pattern string) ([]string, error) {
+	if _, err := os.Lstat(pattern); err == nil {
+		return []string{pattern}, nil
+	}
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+	pattern = filepath.Clean(pattern)
+
+	// walk from the last directory before any wildcard
+	segments := strings.Split(pattern, "/")
+	base := "."
+	for i, s := range segments {
+		if strings.ContainsAny(s, `*?[\`) {
+			if i > 0 {
+				base = strings.Join(segments[:i], "/")
+			}
+			if base == "" {
+				base = "/"
+			}
+			break
+		}
+	}
+
+	var matches []string
+	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if n := len(matches); n > 0 && strings.HasPrefix(path, matches[n-1]+"/") {
+			return nil
+		}
+		if ok, err := Match(pattern, path); err != nil {
+			return err
+		} else if ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return matches, err
+}
@@ -0,0 +1,363 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package imgmount mounts the root filesystem of container images on the
+// host so their content can be browsed with regular tools. Loop devices are
+// used when running as root, FUSE helpers (squashfuse, fuse2fs) otherwise.
+package imgmount
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/image"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/config"
+	"github.com/sylabs/singularity/internal/pkg/runtime/engines/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/loop"
+)
+
+// configFile is the configuration file setting the loop devices used
+var configFile = buildcfg.SYSCONFDIR + "/singularity/singularity.conf"
+
+// LoopOptions restrict the loop devices attached to mount images as root,
+// unset options are taken from the max loop devices and loop device range
+// directives of singularity.conf
+type LoopOptions struct {
+	MaxDevices int
+	Range      *loop.Range
+	// Attached is called once the image is attached to the loop device,
+	// or failed to be, after the time d
+	Attached func(device string, d time.Duration, err error)
+}
+
+// Options are the options of image mounts
+type Options struct {
+	// Loop restricts the loop devices attached to mount images as root,
+	// singularity.conf is used if nil
+	Loop *LoopOptions
+	// Context is the SELinux context given to the files of the image with
+	// the context mount option
+	Context string
+	// Suid and Dev allow setuid programs and device files in images
+	// mounted by root, images are mounted nosuid and nodev otherwise.
+	// FUSE mounts of unprivileged users are always nosuid and nodev.
+	Suid bool
+	Dev  bool
+	// MountOptions are mount options of the image like the options of
+	// fstab, such as noatime or ro, and options of its filesystem, which
+	// replace the default errors=remount-ro of ext3. Images are mounted
+	// read-only unless writable.
+	MountOptions []string
+}
+
+// mountFlags are the mount options set as mount flags, they set or clear
+// flags, other options are passed to the filesystem
+var mountFlags = map[string]struct {
+	set   uintptr
+	clear uintptr
+}{
+	"ro":          {syscall.MS_RDONLY, 0},
+	"rw":          {0, syscall.MS_RDONLY},
+	"noatime":     {syscall.MS_NOATIME, 0},
+	"atime":       {0, syscall.MS_NOATIME},
+	"nodiratime":  {syscall.MS_NODIRATIME, 0},
+	"diratime":    {0, syscall.MS_NODIRATIME},
+	"relatime":    {syscall.MS_RELATIME, 0},
+	"norelatime":  {0, syscall.MS_RELATIME},
+	"strictatime": {syscall.MS_STRICTATIME, 0},
+	"noexec":      {syscall.MS_NOEXEC, 0},
+	"exec":        {0, syscall.MS_NOEXEC},
+	"sync":        {syscall.MS_SYNCHRONOUS, 0},
+	"async":       {0, syscall.MS_SYNCHRONOUS},
+	"dirsync":     {syscall.MS_DIRSYNC, 0},
+}
+
+// ParseOptions applies the mount options to the mount flags, options which
+// aren't mount flags are returned as options of the filesystem
+func ParseOptions(flags uintptr, options []string) (uintptr, []string) {
+	var data []string
+	for _, o := range options {
+		o = strings.TrimSpace(o)
+		if f, ok := mountFlags[o]; ok {
+			flags = flags&^f.clear | f.set
+		} else if o != "" {
+			data = append(data, o)
+		}
+	}
+	return flags, data
+}
+
+// ContextOption returns the mount option setting the SELinux context of
+// the files of a mount, quoted as contexts with several categories contain
+// commas
+func ContextOption(context string) string {
+	return fmt.Sprintf(`context="%s"`, context)
+}
+
+// FuseContextOption returns the context mount option for FUSE helpers,
+// which split their options on unescaped commas
+func FuseContextOption(context string) string {
+	return strings.Replace(ContextOption(context), ",", `\,`, -1)
+}
+
+// partition describes the filesystem to mount from an image file
+type partition struct {
+	fstype string
+	offset uint64
+	size   uint64
+}
+
+// Mount mounts the root filesystem of the image found at path on dest with
+// the options opts, or the default ones if nil
+func Mount(path, dest string, writable bool, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	img, err := image.Init(path, writable)
+	if err != nil {
+		return fmt.Errorf("could not open image %s: %s", path, err)
+	}
+	defer img.File.Close()
+
+	if writable && !img.Writable {
+		return fmt.Errorf("image %s can't be mounted writable", path)
+	}
+
+	if img.Type == image.SANDBOX {
+		if os.Geteuid() != 0 {
+			return fmt.Errorf("sandbox images are directories and can be browsed directly")
+		}
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REC)
+		return syscall.Mount(img.Path, dest, "", flags, "")
+	}
+
+	part, err := getPartition(img)
+	if err != nil {
+		return err
+	}
+	if writable && part.fstype == "squashfs" {
+		return fmt.Errorf("squashfs is not a writable filesystem")
+	}
+	if flags, _ := ParseOptions(syscall.MS_RDONLY, opts.MountOptions); !writable && flags&syscall.MS_RDONLY == 0 {
+		return fmt.Errorf("image %s is not mounted writable, it can't be mounted rw", path)
+	}
+
+	if os.Geteuid() == 0 {
+		return loopMount(img, part, dest, writable, opts)
+	}
+	return fuseMount(img, part, dest, writable, opts)
+}
+
+// Umount unmounts an image previously mounted on dest
+func Umount(dest string) error {
+	return umount(dest, false)
+}
+
+// UmountLazy detaches an image previously mounted on dest even if it is
+// busy, it is unmounted once no longer used
+func UmountLazy(dest string) error {
+	return umount(dest, true)
+}
+
+func umount(dest string, lazy bool) error {
+	if os.Geteuid() == 0 {
+		flags := 0
+		if lazy {
+			flags = syscall.MNT_DETACH
+		}
+		return syscall.Unmount(dest, flags)
+	}
+
+	args := []string{"-u", dest}
+	if lazy {
+		args = []string{"-u", "-z", dest}
+	}
+	for _, name := range []string{"fusermount", "fusermount3"} {
+		if p, err := exec.LookPath(name); err == nil {
+			return run(p, args...)
+		}
+	}
+	return fmt.Errorf("fusermount not found in PATH")
+}
+
+func getPartition(img *image.Image) (*partition, error) {
+	switch img.Type {
+	case image.SQUASHFS:
+		return &partition{"squashfs", img.Offset, img.Size}, nil
+	case image.EXT3:
+		return &partition{"ext3", img.Offset, img.Size}, nil
+	case image.SIF:
+		fimg, err := sif.LoadContainer(img.Path, true)
+		if err != nil {
+			return nil, fmt.Errorf("while loading SIF image: %s", err)
+		}
+		defer fimg.UnloadContainer()
+
+		part, _, err := fimg.GetPartPrimSys()
+		if err != nil {
+			return nil, err
+		}
+		fstype, err := part.GetFsType()
+		if err != nil {
+			return nil, err
+		}
+		p := &partition{offset: uint64(part.Fileoff), size: uint64(part.Filelen)}
+		switch fstype {
+		case sif.FsSquash:
+			p.fstype = "squashfs"
+		case sif.FsExt3:
+			p.fstype = "ext3"
+		default:
+			return nil, fmt.Errorf("unknown file system type: %v", fstype)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("image format not supported")
+}
+
+// loopDevice returns a loop device restricted by opts, unset options are
+// taken from singularity.conf or from its defaults if it can't be parsed
+func loopDevice(opts *LoopOptions) (*loop.Device, error) {
+	c := &singularity.FileConfig{}
+	if err := config.Parser(configFile, c); err != nil {
+		sylog.Warningf("Unable to parse singularity.conf file, using defaults: %s", err)
+		config.Parser("", c)
+	}
+	loopdev := &loop.Device{MaxLoopDevices: int(c.MaxLoopDevices)}
+	if c.LoopDeviceRange != "" {
+		r, err := loop.ParseRange(c.LoopDeviceRange)
+		if err != nil {
+			return nil, err
+		}
+		loopdev.Range = r
+	}
+	if opts != nil {
+		if opts.MaxDevices > 0 {
+			loopdev.MaxLoopDevices = opts.MaxDevices
+		}
+		if opts.Range != nil {
+			loopdev.Range = opts.Range
+		}
+	}
+	return loopdev, nil
+}
+
+func loopMount(img *image.Image, part *partition, dest string, writable bool, opts *Options) error {
+	mode := os.O_RDONLY
+	loopFlags := uint32(loop.FlagsAutoClear)
+	flags := uintptr(0)
+	if !opts.Suid {
+		flags |= syscall.MS_NOSUID
+	}
+	if !opts.Dev {
+		flags |= syscall.MS_NODEV
+	}
+	if writable {
+		mode = os.O_RDWR
+	} else {
+		loopFlags |= loop.FlagsReadOnly
+		flags |= syscall.MS_RDONLY
+	}
+
+	// squashfs has no errors option to remount read-only
+	flags, data := ParseOptions(flags, opts.MountOptions)
+	if part.fstype == "ext3" && len(data) == 0 {
+		data = append(data, "errors=remount-ro")
+	}
+	if opts.Context != "" {
+		data = append(data, ContextOption(opts.Context))
+	}
+
+	loopdev, err := loopDevice(opts.Loop)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	number, err := attach(loopdev, img.DataPath(), mode, &loop.Info64{
+		Offset:    part.offset,
+		SizeLimit: part.size,
+		Flags:     loopFlags,
+	})
+	path := ""
+	if err == nil {
+		path = fmt.Sprintf("/dev/loop%d", number)
+	}
+	if opts.Loop != nil && opts.Loop.Attached != nil {
+		opts.Loop.Attached(path, time.Since(start), err)
+	}
+	if err != nil {
+		return err
+	}
+
+	sylog.Debugf("Mounting loop device %s to %s", path, dest)
+	if err := syscall.Mount(path, dest, part.fstype, flags, strings.Join(data, ",")); err != nil {
+		loopdev.Detach()
+		return fmt.Errorf("failed to mount %s filesystem: %s", part.fstype, err)
+	}
+
+	// the device is released by auto clear once unmounted
+	return loopdev.Close()
+}
+
+// attach attaches the image file at path to loopdev with info
+func attach(loopdev *loop.Device, path string, mode int, info *loop.Info64) (int, error) {
+	number := 0
+	if err := loopdev.AttachFromPath(path, mode, &number); err != nil {
+		return number, fmt.Errorf("failed to attach loop device: %s", err)
+	}
+	if err := loopdev.SetStatus(info); err != nil {
+		loopdev.Detach()
+		return number, err
+	}
+	return number, nil
+}
+
+func fuseMount(img *image.Image, part *partition, dest string, writable bool, opts *Options) error {
+	name, args := fuseCommand(img.DataPath(), part, dest, writable, opts)
+
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s is required to mount %s images as an unprivileged user: %s", name, part.fstype, err)
+	}
+
+	sylog.Debugf("Mounting %s to %s with %s", img.DataPath(), dest, name)
+	return run(p, args...)
+}
+
+// fuseCommand returns the FUSE helper and its arguments to mount the
+// partition part of the image file at path on dest, with the SELinux
+// context and the mount options of opts
+func fuseCommand(path string, part *partition, dest string, writable bool, opts *Options) (string, []string) {
+	name := "squashfuse"
+	fuseOpts := []string{fmt.Sprintf("offset=%d", part.offset)}
+
+	if part.fstype == "ext3" {
+		name = "fuse2fs"
+		if !writable {
+			fuseOpts = append(fuseOpts, "ro")
+		}
+	}
+	fuseOpts = append(fuseOpts, opts.MountOptions...)
+	if opts.Context != "" {
+		fuseOpts = append(fuseOpts, FuseContextOption(opts.Context))
+	}
+
+	return name, []string{"-o", strings.Join(fuseOpts, ","), path, dest}
+}
+
+func run(path string, args ...string) error {
+	out, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imgmount
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/image"
+	"github.com/sylabs/singularity/pkg/util/loop"
+)
+
+// createSIF creates a SIF image at path holding a fake partition of type
+// fstype, the partition is the primary system partition if primary is set
+func createSIF(t *testing.T, path string, fstype sif.Fstype, primary bool) {
+	part := path + ".part"
+	if err := ioutil.WriteFile(part, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(part)
+
+	fp, err := os.Open(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Size:     4096,
+		Fname:    part,
+		Fp:       fp,
+	}
+	parttype := sif.PartData
+	if primary {
+		parttype = sif.PartPrimSys
+	}
+	if err := input.SetPartExtra(fstype, parttype, sif.HdrArchAMD64); err != nil {
+		t.Fatal(err)
+	}
+
+	fimg, err := sif.CreateContainer(sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{input},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fimg.UnloadContainer()
+}
+
+func TestGetPartition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imgmount-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	squashSIF := filepath.Join(dir, "squashfs.sif")
+	createSIF(t, squashSIF, sif.FsSquash, true)
+	ext3SIF := filepath.Join(dir, "ext3.sif")
+	createSIF(t, ext3SIF, sif.FsExt3, true)
+	rawSIF := filepath.Join(dir, "raw.sif")
+	createSIF(t, rawSIF, sif.FsRaw, true)
+	dataSIF := filepath.Join(dir, "data.sif")
+	createSIF(t, dataSIF, sif.FsSquash, false)
+	notSIF := filepath.Join(dir, "not.sif")
+	if err := ioutil.WriteFile(notSIF, []byte("not a SIF image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		img    *image.Image
+		fstype string
+		size   uint64
+		ok     bool
+	}{
+		{"squashfs", &image.Image{Type: image.SQUASHFS, Offset: 31, Size: 4096}, "squashfs", 4096, true},
+		{"ext3", &image.Image{Type: image.EXT3, Offset: 31, Size: 4096}, "ext3", 4096, true},
+		{"SIF squashfs", &image.Image{Type: image.SIF, Path: squashSIF}, "squashfs", 4096, true},
+		{"SIF ext3", &image.Image{Type: image.SIF, Path: ext3SIF}, "ext3", 4096, true},
+		{"SIF raw partition", &image.Image{Type: image.SIF, Path: rawSIF}, "", 0, false},
+		{"SIF without primary partition", &image.Image{Type: image.SIF, Path: dataSIF}, "", 0, false},
+		{"corrupted SIF", &image.Image{Type: image.SIF, Path: notSIF}, "", 0, false},
+		{"missing SIF", &image.Image{Type: image.SIF, Path: filepath.Join(dir, "missing")}, "", 0, false},
+		{"sandbox", &image.Image{Type: image.SANDBOX, Path: dir}, "", 0, false},
+	}
+	for _, tt := range tests {
+		part, err := getPartition(tt.img)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: unexpected success", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if part.fstype != tt.fstype || part.size != tt.size {
+			t.Errorf("%s: got partition %+v, want %s of %d bytes", tt.name, part, tt.fstype, tt.size)
+		}
+		if tt.img.Type != image.SIF && part.offset != tt.img.Offset {
+			t.Errorf("%s: got offset %d, want %d", tt.name, part.offset, tt.img.Offset)
+		}
+		if tt.img.Type == image.SIF && part.offset == 0 {
+			t.Errorf("%s: partition offset not set", tt.name)
+		}
+	}
+}
+
+func TestFuseCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		part     *partition
+		writable bool
+		context  string
+		options  []string
+		helper   string
+		args     []string
+	}{
+		{"squashfs", &partition{"squashfs", 0, 4096}, false, "", nil, "squashfuse", []string{"-o", "offset=0", "/image", "/mnt"}},
+		{"squashfs in SIF", &partition{"squashfs", 32768, 4096}, false, "", nil, "squashfuse", []string{"-o", "offset=32768", "/image", "/mnt"}},
+		{"ext3 read-only", &partition{"ext3", 31, 4096}, false, "", nil, "fuse2fs", []string{"-o", "offset=31,ro", "/image", "/mnt"}},
+		{"ext3 writable", &partition{"ext3", 31, 4096}, true, "", nil, "fuse2fs", []string{"-o", "offset=31", "/image", "/mnt"}},
+		{"context", &partition{"squashfs", 0, 4096}, false, "system_u:object_r:container_file_t:s0", nil, "squashfuse", []string{"-o", `offset=0,context="system_u:object_r:container_file_t:s0"`, "/image", "/mnt"}},
+		{"mount options", &partition{"ext3", 31, 4096}, true, "", []string{"noatime", "errors=continue"}, "fuse2fs", []string{"-o", "offset=31,noatime,errors=continue", "/image", "/mnt"}},
+		{"context with categories", &partition{"ext3", 31, 4096}, false, "system_u:object_r:container_file_t:s0:c1,c2", nil, "fuse2fs", []string{"-o", `offset=31,ro,context="system_u:object_r:container_file_t:s0:c1\,c2"`, "/image", "/mnt"}},
+	}
+	for _, tt := range tests {
+		helper, args := fuseCommand("/image", tt.part, "/mnt", tt.writable, &Options{Context: tt.context, MountOptions: tt.options})
+		if helper != tt.helper {
+			t.Errorf("%s: got helper %s, want %s", tt.name, helper, tt.helper)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: got arguments %v, want %v", tt.name, args, tt.args)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   uintptr
+		options []string
+		want    uintptr
+		data    []string
+	}{
+		{"none", syscall.MS_RDONLY, nil, syscall.MS_RDONLY, nil},
+		{"rw", syscall.MS_RDONLY | syscall.MS_NOSUID, []string{"rw"}, syscall.MS_NOSUID, nil},
+		{"ro", 0, []string{"ro"}, syscall.MS_RDONLY, nil},
+		{"atime", syscall.MS_RDONLY, []string{" noatime", "nodiratime ", "atime"}, syscall.MS_RDONLY | syscall.MS_NODIRATIME, nil},
+		{"filesystem options", 0, []string{"noexec", "errors=continue", "", "data=ordered"}, syscall.MS_NOEXEC, []string{"errors=continue", "data=ordered"}},
+	}
+	for _, tt := range tests {
+		flags, data := ParseOptions(tt.flags, tt.options)
+		if flags != tt.want || !reflect.DeepEqual(data, tt.data) {
+			t.Errorf("%s: got flags %#x and options %v, want %#x and %v", tt.name, flags, data, tt.want, tt.data)
+		}
+	}
+}
+
+func TestMountErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imgmount-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	squashSIF := filepath.Join(dir, "squashfs.sif")
+	createSIF(t, squashSIF, sif.FsSquash, true)
+	if err := os.Chmod(squashSIF, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		writable bool
+		opts     *Options
+		err      string
+	}{
+		{"missing image", filepath.Join(dir, "missing"), false, nil, "could not open image"},
+		{"writable squashfs", squashSIF, true, nil, "squashfs"},
+		{"rw read-only image", squashSIF, false, &Options{MountOptions: []string{"noatime", "rw"}}, "can't be mounted rw"},
+	}
+	for _, tt := range tests {
+		err := Mount(tt.path, dir, tt.writable, tt.opts)
+		if err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		} else if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %q, want error containing %q", tt.name, err, tt.err)
+		}
+	}
+
+	// FUSE helpers are looked up in PATH
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir)
+
+	img := &image.Image{Path: squashSIF}
+	err = fuseMount(img, &partition{"squashfs", 0, 4096}, dir, false, &Options{})
+	if err == nil || !strings.Contains(err.Error(), "squashfuse is required") {
+		t.Errorf("got error %v while mounting without squashfuse", err)
+	}
+}
+
+func TestLoopDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imgmount-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(f string) { configFile = f }(configFile)
+	configFile = filepath.Join(dir, "singularity.conf")
+
+	tests := []struct {
+		name   string
+		conf   string
+		opts   *LoopOptions
+		max    int
+		first  int
+		last   int
+		hasErr bool
+	}{
+		{"defaults", "", nil, 256, -1, -1, false},
+		{"config", "max loop devices = 64\nloop device range = 8-15\n", nil, 64, 8, 15, false},
+		{"options", "max loop devices = 64\nloop device range = 8-15\n", &LoopOptions{MaxDevices: 32, Range: &loop.Range{First: 100, Last: 101}}, 32, 100, 101, false},
+		{"unset options", "max loop devices = 64\n", &LoopOptions{}, 64, -1, -1, false},
+		{"bad range", "loop device range = 15-8\n", nil, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(configFile, []byte(tt.conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+		loopdev, err := loopDevice(tt.opts)
+		if tt.hasErr {
+			if err == nil {
+				t.Errorf("%s: unexpected success", tt.name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if loopdev.MaxLoopDevices != tt.max {
+			t.Errorf("%s: got %d max loop devices, want %d", tt.name, loopdev.MaxLoopDevices, tt.max)
+		}
+		first, last := -1, -1
+		if loopdev.Range != nil {
+			first, last = loopdev.Range.First, loopdev.Range.Last
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("%s: got loop device range %d-%d, want %d-%d", tt.name, first, last, tt.first, tt.last)
+		}
+	}
+}
@@ -7,6 +7,9 @@ package layout
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/sylabs/singularity/internal/pkg/util/fs/mount"
@@ -84,3 +87,27 @@ func (s *Session) RootFsPath() string {
 func (s *Session) createLayout(system *mount.System) error {
 	return s.Create()
 }
+
+// CheckSessionPath returns an error if path, once its symbolic links are
+// resolved, is not within the session directory dir. A path which doesn't
+// exist yet is checked through its parent directory.
+func CheckSessionPath(dir, path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("%s is not an absolute path", path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		parent, perr := filepath.EvalSymlinks(filepath.Dir(path))
+		if perr != nil {
+			return perr
+		}
+		resolved, err = filepath.Join(parent, filepath.Base(path)), nil
+	}
+	if err != nil {
+		return err
+	}
+	if resolved != dir && !strings.HasPrefix(resolved, dir+"/") {
+		return fmt.Errorf("%s is not within session directory %s", path, dir)
+	}
+	return nil
+}
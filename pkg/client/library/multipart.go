@@ -0,0 +1,305 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/user-agent"
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+const (
+	// DefaultUploadWorkers is the default number of parts uploaded concurrently
+	DefaultUploadWorkers = 4
+	// defaultPartSize is the part size used when the server doesn't set one
+	defaultPartSize = 64 * 1024 * 1024
+	// uploadRetries is the number of times a failed request is retried
+	uploadRetries = 5
+)
+
+// retryDelay is the delay before the first retry of a failed request, it
+// doubles with each retry
+var retryDelay = time.Second
+
+// errMultipartUnsupported is returned when the library doesn't provide the
+// multi-part upload API
+var errMultipartUnsupported = fmt.Errorf("multi-part upload not supported by the library")
+
+// uploadError is an upload failure, temporary ones are retried
+type uploadError struct {
+	err       error
+	code      int
+	temporary bool
+}
+
+func (e *uploadError) Error() string {
+	return e.err.Error()
+}
+
+// isTemporary returns whether the request failed because of a transient
+// network or server error
+func isTemporary(err error) bool {
+	if e, ok := err.(*uploadError); ok {
+		return e.temporary
+	}
+	return false
+}
+
+// temporaryStatus returns whether an HTTP status code indicates a transient
+// failure of the request
+func temporaryStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// withRetry calls f until it succeeds, fails with a permanent error or
+// uploadRetries is exceeded
+func withRetry(ctx context.Context, what string, f func() error) error {
+	delay := retryDelay
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || !isTemporary(err) || i == uploadRetries {
+			return err
+		}
+		sylog.Debugf("%s failed, retrying in %v: %v", what, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// uploadRequest sends an upload API request and decodes the JSON response
+// in v if it is not nil
+func uploadRequest(ctx context.Context, method, url, authToken string, body io.Reader, size int64, v interface{}) error {
+	sylog.Debugf("%s %s\n", method, url)
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("error creating request to server:\n\t%v", err)
+	}
+	req = req.WithContext(ctx)
+	if size >= 0 {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.ContentLength = size
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+authToken)
+	}
+	req.Header.Set("User-Agent", useragent.Value())
+
+	client := &http.Client{
+		Timeout: pushTimeout * time.Second,
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return &uploadError{
+			err:       fmt.Errorf("error making request to server:\n\t%v", err),
+			temporary: ctx.Err() == nil,
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		jRes, err := ParseErrorBody(res.Body)
+		if err != nil {
+			jRes = ParseErrorResponse(res)
+		}
+		return &uploadError{
+			err: fmt.Errorf("request did not succeed: %d %s\n\t%v",
+				jRes.Error.Code, jRes.Error.Status, jRes.Error.Message),
+			code:      res.StatusCode,
+			temporary: temporaryStatus(res.StatusCode),
+		}
+	}
+	if v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			return fmt.Errorf("error decoding response: %v", err)
+		}
+	}
+	return nil
+}
+
+// startMultipart starts, or resumes if one is in progress, the multi-part
+// upload of the image file of imageID
+func startMultipart(ctx context.Context, baseURL, authToken, imageID string, fileSize int64) (upload MultipartUpload, err error) {
+	url := baseURL + "/v1/imagefile/" + imageID + "/_multipart"
+	s, err := json.Marshal(MultipartUploadStart{Size: fileSize})
+	if err != nil {
+		return upload, fmt.Errorf("error encoding object to JSON:\n\t%v", err)
+	}
+
+	var res MultipartUploadResponse
+	err = withRetry(ctx, "Starting upload", func() error {
+		return uploadRequest(ctx, "POST", url, authToken, bytes.NewReader(s), -1, &res)
+	})
+	if e, ok := err.(*uploadError); ok {
+		switch e.code {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return upload, errMultipartUnsupported
+		}
+	}
+	if err != nil {
+		return upload, err
+	}
+
+	upload = res.Data
+	if upload.PartSize <= 0 {
+		upload.PartSize = defaultPartSize
+	}
+	upload.TotalParts = int((fileSize + upload.PartSize - 1) / upload.PartSize)
+	if upload.TotalParts == 0 {
+		upload.TotalParts = 1
+	}
+	return upload, nil
+}
+
+// postFileMultipart uploads the image file at filePath in parts, workers
+// parts being uploaded concurrently. Failed parts are retried, and the parts
+// already received by the library are skipped when resuming an interrupted
+// upload.
+func postFileMultipart(baseURL string, authToken string, filePath string, imageID string, workers int) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("Could not open the image file to upload: %v", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Could not find size of the image file to upload: %v", err)
+	}
+	fileSize := fi.Size()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	upload, err := startMultipart(ctx, baseURL, authToken, imageID, fileSize)
+	if err != nil {
+		return err
+	}
+
+	done := make(map[int]bool)
+	for _, n := range upload.UploadedParts {
+		if n >= 1 && n <= upload.TotalParts {
+			done[n] = true
+		}
+	}
+	if len(done) > 0 {
+		sylog.Infof("Resuming upload, %d of %d parts already uploaded\n", len(done), upload.TotalParts)
+	}
+
+	bar := pb.New64(fileSize).SetUnits(pb.U_BYTES)
+	bar.ShowTimeLeft = true
+	bar.ShowSpeed = true
+	bar.Start()
+	for n := range done {
+		bar.Add64(partLength(fileSize, upload.PartSize, n))
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	parts := make(chan int)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+
+	partURL := baseURL + "/v1/imagefile/" + imageID + "/_multipart/" + upload.UploadID + "/"
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range parts {
+				offset := int64(n-1) * upload.PartSize
+				length := partLength(fileSize, upload.PartSize, n)
+				err := withRetry(ctx, fmt.Sprintf("Upload of part %d", n), func() error {
+					r := &progressReader{r: io.NewSectionReader(f, offset, length), bar: bar}
+					err := uploadRequest(ctx, "PUT", partURL+strconv.Itoa(n), authToken, r, length, nil)
+					if err != nil {
+						r.rewind()
+					}
+					return err
+				})
+				if err != nil {
+					errs <- fmt.Errorf("Error uploading part %d of %d: %v", n, upload.TotalParts, err)
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for n := 1; n <= upload.TotalParts; n++ {
+		if done[n] {
+			continue
+		}
+		select {
+		case parts <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(parts)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		bar.Finish()
+		sylog.Infof("Run the same push command again to resume the upload\n")
+		return err
+	}
+
+	err = withRetry(ctx, "Completing upload", func() error {
+		return uploadRequest(ctx, "POST", partURL+"_complete", authToken, nil, -1, nil)
+	})
+	bar.Finish()
+	if err != nil {
+		return fmt.Errorf("Error completing upload: %v", err)
+	}
+	return nil
+}
+
+// partLength returns the length of the part n of a file of fileSize bytes
+func partLength(fileSize, partSize int64, n int) int64 {
+	offset := int64(n-1) * partSize
+	if fileSize-offset < partSize {
+		return fileSize - offset
+	}
+	return partSize
+}
+
+// progressReader reports the bytes read to a progress bar, they are
+// removed from it when a failed part is rewound for a retry
+type progressReader struct {
+	r   io.Reader
+	bar *pb.ProgressBar
+	n   int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	p.bar.Add64(int64(n))
+	return n, err
+}
+
+func (p *progressReader) rewind() {
+	p.bar.Add64(-p.n)
+	p.n = 0
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+// mockMultipartService is a library serving the multi-part upload API
+type mockMultipartService struct {
+	t        *testing.T
+	imageID  string
+	partSize int64
+	// parts already uploaded when the upload starts
+	uploaded []int
+	// number of times uploads of a part fail with a server error
+	failures map[int]int
+	// status code of a part upload which fails permanently
+	failCode int
+
+	mu        sync.Mutex
+	parts     map[int][]byte
+	completed bool
+}
+
+func (m *mockMultipartService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := "/v1/imagefile/" + m.imageID + "/_multipart"
+	switch {
+	case r.URL.Path == prefix && r.Method == "POST":
+		var start MultipartUploadStart
+		if err := json.NewDecoder(r.Body).Decode(&start); err != nil {
+			m.t.Errorf("Error decoding upload start request: %v", err)
+		}
+		json.NewEncoder(w).Encode(MultipartUploadResponse{
+			Data: MultipartUpload{UploadID: "upload", PartSize: m.partSize, UploadedParts: m.uploaded},
+		})
+	case r.URL.Path == prefix+"/upload/_complete" && r.Method == "POST":
+		m.completed = true
+	case strings.HasPrefix(r.URL.Path, prefix+"/upload/") && r.Method == "PUT":
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, prefix+"/upload/"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		if m.failCode != 0 {
+			w.WriteHeader(m.failCode)
+			return
+		}
+		if m.failures[n] > 0 {
+			m.failures[n]--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		m.parts[n] = data
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_postFileMultipart(t *testing.T) {
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	content, err := ioutil.ReadFile("test_data/test_sha256")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	partSize := int64(len(content)/3 + 1)
+
+	tests := []struct {
+		description string
+		uploaded    []int
+		failures    map[int]int
+		failCode    int
+		expectParts []int
+		expectError bool
+	}{
+		{
+			description: "Upload all parts",
+			failures:    map[int]int{},
+			expectParts: []int{1, 2, 3},
+		},
+		{
+			description: "Retry transient failures",
+			failures:    map[int]int{2: 2},
+			expectParts: []int{1, 2, 3},
+		},
+		{
+			description: "Resume upload",
+			uploaded:    []int{1, 3},
+			failures:    map[int]int{},
+			expectParts: []int{2},
+		},
+		{
+			description: "Too many failures",
+			failures:    map[int]int{1: uploadRetries + 1},
+			expectError: true,
+		},
+		{
+			description: "Unauthorized response",
+			failures:    map[int]int{},
+			failCode:    http.StatusUnauthorized,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, test.WithoutPrivilege(func(t *testing.T) {
+			m := &mockMultipartService{
+				t:        t,
+				imageID:  bson.NewObjectId().Hex(),
+				partSize: partSize,
+				uploaded: tt.uploaded,
+				failures: tt.failures,
+				failCode: tt.failCode,
+				parts:    make(map[int][]byte),
+			}
+			s := httptest.NewServer(m)
+			defer s.Close()
+
+			err := postFileMultipart(s.URL, testToken, "test_data/test_sha256", m.imageID, 2)
+			if err != nil && !tt.expectError {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if err == nil && tt.expectError {
+				t.Errorf("Unexpected success. Expected error.")
+			}
+			if tt.expectError {
+				if m.completed {
+					t.Errorf("Failed upload completed")
+				}
+				return
+			}
+
+			if !m.completed {
+				t.Errorf("Upload not completed")
+			}
+			if len(m.parts) != len(tt.expectParts) {
+				t.Errorf("Uploaded %d parts, expected %d", len(m.parts), len(tt.expectParts))
+			}
+			for _, n := range tt.expectParts {
+				offset := int64(n-1) * partSize
+				expected := content[offset : offset+partLength(int64(len(content)), partSize, n)]
+				if !bytes.Equal(m.parts[n], expected) {
+					t.Errorf("Unexpected content for part %d", n)
+				}
+			}
+		}))
+	}
+}
+
+func Test_postFileMultipartUnsupported(t *testing.T) {
+	m := mockService{
+		t:        t,
+		code:     http.StatusNotFound,
+		httpPath: "/v1/imagefile/",
+	}
+	m.Run()
+	defer m.Stop()
+
+	err := postFileMultipart(m.baseURI, testToken, "test_data/test_sha256", bson.NewObjectId().Hex(), 2)
+	if err != errMultipartUnsupported {
+		t.Errorf("Unexpected error %v, expected %v", err, errMultipartUnsupported)
+	}
+}
@@ -6,6 +6,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -24,6 +25,12 @@ const pullTimeout = 1800
 // DownloadImage will retrieve an image from the Container Library,
 // saving it into the specified file
 func DownloadImage(filePath string, libraryRef string, libraryURL string, Force bool, authToken string) error {
+	return DownloadImageContext(context.Background(), filePath, libraryRef, libraryURL, Force, authToken)
+}
+
+// DownloadImageContext is DownloadImage with a context, the download is
+// aborted when ctx is canceled
+func DownloadImageContext(ctx context.Context, filePath string, libraryRef string, libraryURL string, Force bool, authToken string) error {
 
 	if !IsLibraryPullRef(libraryRef) {
 		return fmt.Errorf("Not a valid library reference: %s", libraryRef)
@@ -59,6 +66,7 @@ func DownloadImage(filePath string, libraryRef string, libraryURL string, Force
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 
 	if authToken != "" {
 		req.Header.Set("Authorization", "Bearer "+authToken)
@@ -21,7 +21,8 @@ import (
 const pushTimeout = 1800
 
 // UploadImage will push a specified image up to the Container Library,
-func UploadImage(filePath string, libraryRef string, libraryURL string, authToken string, description string) error {
+// uploading up to workers parts of the image file concurrently
+func UploadImage(filePath string, libraryRef string, libraryURL string, authToken string, description string, workers int) error {
 
 	if !IsLibraryPushRef(libraryRef) {
 		return fmt.Errorf("Not a valid library reference: %s", libraryRef)
@@ -89,7 +90,11 @@ func UploadImage(filePath string, libraryRef string, libraryURL string, authToke
 
 	if !image.Uploaded {
 		sylog.Infof("Now uploading %s to the library\n", filePath)
-		err = postFile(libraryURL, authToken, filePath, image.GetID().Hex())
+		err = postFileMultipart(libraryURL, authToken, filePath, image.GetID().Hex(), workers)
+		if err == errMultipartUnsupported {
+			sylog.Debugf("Library doesn't support multi-part uploads, uploading in a single request\n")
+			err = postFile(libraryURL, authToken, filePath, image.GetID().Hex())
+		}
 		if err != nil {
 			return err
 		}
@@ -126,7 +131,7 @@ func postFile(baseURL string, authToken string, filePath string, imageID string)
 	b := bufio.NewReader(f)
 
 	// create and start bar
-	bar := pb.New(int(fileSize)).SetUnits(pb.U_BYTES)
+	bar := pb.New64(fileSize).SetUnits(pb.U_BYTES)
 	bar.ShowTimeLeft = true
 	bar.ShowSpeed = true
 	bar.Start()
@@ -60,3 +60,23 @@ type SearchResponse struct {
 	Data  SearchResults `json:"data"`
 	Error JSONError     `json:"error,omitempty"`
 }
+
+// MultipartUploadStart - Request to start a multi-part upload of an image file
+type MultipartUploadStart struct {
+	Size int64 `json:"filesize"`
+}
+
+// MultipartUpload - State of a multi-part upload of an image file
+type MultipartUpload struct {
+	UploadID      string `json:"uploadID"`
+	PartSize      int64  `json:"partSize"`
+	TotalParts    int    `json:"totalParts"`
+	UploadedParts []int  `json:"uploadedParts"`
+}
+
+// MultipartUploadResponse - Response from the API for a multi-part upload
+// request
+type MultipartUploadResponse struct {
+	Data  MultipartUpload `json:"data"`
+	Error JSONError       `json:"error,omitempty"`
+}
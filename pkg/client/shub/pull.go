@@ -6,6 +6,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -24,6 +25,12 @@ const pullTimeout = 7200
 // DownloadImage will retrieve an image from the Container Singularityhub,
 // saving it into the specified file
 func DownloadImage(filePath string, shubRef string, force, noHTTPS bool) (err error) {
+	return DownloadImageContext(context.Background(), filePath, shubRef, force, noHTTPS)
+}
+
+// DownloadImageContext is DownloadImage with a context, the image download
+// is aborted when ctx is canceled
+func DownloadImageContext(ctx context.Context, filePath string, shubRef string, force, noHTTPS bool) (err error) {
 	sylog.Debugf("Downloading container from Shub")
 
 	// use custom parser to make sure we have a valid shub URI
@@ -62,6 +69,7 @@ func DownloadImage(filePath string, shubRef string, force, noHTTPS bool) (err er
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("User-Agent", useragent.Value())
 
 	if noHTTPS {
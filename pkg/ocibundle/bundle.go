@@ -0,0 +1,40 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package ocibundle creates OCI runtime bundles, a root filesystem and its
+// config.json, from container images so they can be run by an OCI runtime
+// like runc or crun. Implementations for each image format are found in
+// sub-packages.
+package ocibundle
+
+import (
+	"context"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const (
+	// RootFs is the directory of the root filesystem in the bundle
+	RootFs = "rootfs"
+	// Config is the runtime configuration file of the bundle
+	Config = "config.json"
+)
+
+// Bundle is an OCI runtime bundle created from a container image
+type Bundle interface {
+	// Create creates the bundle. The process arguments, environment and
+	// working directory of the image are applied to ociConfig, or to a
+	// default configuration if it is nil, to write the config.json file.
+	Create(ctx context.Context, ociConfig *specs.Spec) error
+	// Update writes the config.json file of a created bundle again, from
+	// ociConfig like Create, without recreating its root filesystem
+	Update(ociConfig *specs.Spec) error
+	// Delete removes the bundle. With force, busy mounts are detached
+	// lazily and resources left by a process which died before deleting
+	// the bundle are released, so orphaned bundles can be cleaned up.
+	Delete(force bool) error
+	// Path returns the path of the bundle directory
+	Path() string
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import "time"
+
+// Phase is a phase of the creation of a bundle
+type Phase string
+
+const (
+	// PhaseLoadImage reads the image and its primary partition
+	PhaseLoadImage Phase = "load image"
+	// PhaseLoopAttach attaches the image to a loop device, as root
+	PhaseLoopAttach Phase = "loop attach"
+	// PhaseMount mounts the image on the root filesystem, loop attach
+	// included
+	PhaseMount Phase = "mount"
+	// PhaseExtract extracts the image in the root filesystem
+	PhaseExtract Phase = "extract"
+	// PhaseOverlayCreate mounts the overlay on the root filesystem
+	PhaseOverlayCreate Phase = "overlay create"
+)
+
+// Event reports the end of a phase of the creation of a bundle
+type Event struct {
+	Phase Phase
+	// Bundle is the path of the bundle directory
+	Bundle string
+	// Duration is the time spent in the phase
+	Duration time.Duration
+	// Detail is the object of the phase, like the image or the loop
+	// device
+	Detail string
+	// Err is the error of the phase, if it failed
+	Err error
+}
+
+// EventHandler receives the events of bundles, like progress reporting of
+// callers embedding the package. It is called by the goroutine creating the
+// bundle, which waits for it to return.
+type EventHandler interface {
+	HandleEvent(e Event)
+}
+
+// EventHandlerFunc is a function used as an EventHandler
+type EventHandlerFunc func(e Event)
+
+// HandleEvent calls f(e)
+func (f EventHandlerFunc) HandleEvent(e Event) {
+	f(e)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-tools/generate"
+)
+
+// Generator returns a generator of the runtime configuration ociConfig,
+// or of a default one if nil, with the process of the image configuration
+// imgConfig. Values already set in ociConfig take precedence.
+func Generator(imgConfig imgspecv1.ImageConfig, ociConfig *specs.Spec) (*generate.Generator, error) {
+	var g generate.Generator
+	if ociConfig != nil {
+		g = generate.NewFromSpec(ociConfig)
+	} else {
+		var err error
+		if g, err = generate.New("linux"); err != nil {
+			return nil, err
+		}
+		g.SetHostname("")
+		// the default process of the generator is replaced by the image
+		// one
+		g.SetProcessArgs(nil)
+		g.SetProcessCwd("")
+		g.ClearProcessEnv()
+	}
+	g.SetRootPath(RootFs)
+
+	if g.Config.Process == nil || len(g.Config.Process.Args) == 0 {
+		args := append(append([]string{}, imgConfig.Entrypoint...), imgConfig.Cmd...)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no process arguments given and none found in the image")
+		}
+		g.SetProcessArgs(args)
+	}
+	if g.Config.Process.Cwd == "" {
+		cwd := imgConfig.WorkingDir
+		if cwd == "" {
+			cwd = "/"
+		}
+		g.SetProcessCwd(cwd)
+	}
+
+	set := make(map[string]bool)
+	for _, env := range g.Config.Process.Env {
+		set[strings.SplitN(env, "=", 2)[0]] = true
+	}
+	for _, env := range imgConfig.Env {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) == 2 && !set[kv[0]] {
+			g.AddProcessEnv(kv[0], kv[1])
+		}
+	}
+
+	// only numeric users are applied, names would require reading the
+	// passwd file of the image
+	if ociConfig == nil && imgConfig.User != "" {
+		ids := strings.SplitN(imgConfig.User, ":", 2)
+		uid, err := strconv.ParseUint(ids[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %s of the image is not numeric, set it in the runtime configuration", imgConfig.User)
+		}
+		g.SetProcessUID(uint32(uid))
+		if len(ids) == 2 {
+			gid, err := strconv.ParseUint(ids[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("group %s of the image is not numeric, set it in the runtime configuration", ids[1])
+			}
+			g.SetProcessGID(uint32(gid))
+		}
+	}
+	return &g, nil
+}
+
+// SaveConfig writes the runtime configuration of g in the bundle at
+// bundlePath. The configuration file is replaced atomically, so runtimes
+// never read a partial file while it is updated.
+func SaveConfig(g *generate.Generator, bundlePath string) error {
+	f, err := ioutil.TempFile(bundlePath, Config+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = g.Save(f, generate.ExportOptions{})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("while writing runtime configuration: %s", err)
+	}
+	// temporary files are only readable by their owner
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(bundlePath, Config))
+}
+
+// CheckCreated returns an error if the bundle at bundlePath wasn't created
+func CheckCreated(bundlePath string) error {
+	if _, err := os.Stat(filepath.Join(bundlePath, RootFs)); err != nil {
+		return fmt.Errorf("bundle %s is not created: %s", bundlePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockSuffix is the suffix of the lock file of a bundle
+const lockSuffix = ".lock"
+
+// ErrBundleBusy is returned when a bundle is being created, updated or
+// deleted by another process
+var ErrBundleBusy = errors.New("bundle is busy")
+
+// BundleLock is an exclusive lock on a bundle. It is a flock lock on a file
+// next to the bundle directory, as the directory is removed with the
+// bundle, and it is released by the kernel if the process dies. As a flock
+// lock it is held by the open file, a process taking it twice gets
+// ErrBundleBusy.
+type BundleLock struct {
+	f *os.File
+}
+
+// Lock takes the lock of the bundle at bundlePath without waiting, so
+// processes creating, updating or deleting the same bundle are serialized
+// and ErrBundleBusy is returned if another one holds it. The parent
+// directory of the bundle must exist.
+func Lock(bundlePath string) (*BundleLock, error) {
+	f, err := os.OpenFile(filepath.Clean(bundlePath)+lockSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err == syscall.EWOULDBLOCK {
+		f.Close()
+		return nil, ErrBundleBusy
+	} else if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not lock bundle %s: %s", bundlePath, err)
+	}
+	return &BundleLock{f: f}, nil
+}
+
+// Unlock releases the lock, the lock file is kept as removing it would
+// race with processes taking it
+func (l *BundleLock) Unlock() error {
+	return l.f.Close()
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundles-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bundle := filepath.Join(dir, "bundle")
+	l, err := Lock(bundle)
+	if err != nil {
+		t.Fatalf("unexpected error taking lock: %v", err)
+	}
+	if _, err := Lock(bundle + "/"); err != ErrBundleBusy {
+		t.Errorf("unexpected error taking a held lock: %v", err)
+	}
+	if other, err := Lock(filepath.Join(dir, "other")); err != nil {
+		t.Errorf("unexpected error taking lock of another bundle: %v", err)
+	} else {
+		other.Unlock()
+	}
+	if err := l.Unlock(); err != nil {
+		t.Errorf("unexpected error releasing lock: %v", err)
+	}
+	if l, err = Lock(bundle); err != nil {
+		t.Errorf("unexpected error taking a released lock: %v", err)
+	} else {
+		l.Unlock()
+	}
+
+	if _, err := Lock(filepath.Join(dir, "missing", "bundle")); !os.IsNotExist(err) {
+		t.Errorf("unexpected error without parent directory: %v", err)
+	}
+}
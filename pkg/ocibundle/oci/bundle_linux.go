@@ -0,0 +1,159 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package oci creates OCI runtime bundles from OCI and docker images, their
+// layers are pulled through the image cache and flattened in the root
+// filesystem of the bundle.
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/internal/pkg/build/sources"
+	sytypes "github.com/sylabs/singularity/internal/pkg/build/types"
+	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+type ociBundle struct {
+	bundlePath string
+	imageRef   string
+	sysCtx     *types.SystemContext
+}
+
+// FromImageRef returns a bundle at bundlePath for the image imageRef, a
+// transport:reference pair like docker://alpine:3.8 or oci:/path/to/layout:tag.
+// Layers are unpacked without changing their ownership, so bundles can be
+// created by unprivileged users for rootless runtimes.
+func FromImageRef(imageRef, bundlePath string, sysCtx *types.SystemContext) (ocibundle.Bundle, error) {
+	if !strings.Contains(imageRef, ":") {
+		return nil, fmt.Errorf("image reference %s is not a transport:reference pair", imageRef)
+	}
+	abs, err := filepath.Abs(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ociBundle{
+		bundlePath: abs,
+		imageRef:   imageRef,
+		sysCtx:     sysCtx,
+	}, nil
+}
+
+// Create pulls the image layers to the cache, flattens them in the root
+// filesystem of the bundle and writes its runtime configuration.
+// ErrBundleBusy is returned if another process is creating or deleting the
+// bundle.
+func (b *ociBundle) Create(ctx context.Context, ociConfig *specs.Spec) error {
+	ref, err := ociclient.ParseImageName(b.imageRef, b.sysCtx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.bundlePath), 0755); err != nil {
+		return err
+	}
+	l, err := ocibundle.Lock(b.bundlePath)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	// an existing bundle would be removed when failing to create it again
+	if err := ocibundle.CheckCreated(b.bundlePath); err == nil {
+		return fmt.Errorf("bundle %s is already created", b.bundlePath)
+	}
+	if err := os.MkdirAll(b.bundlePath, 0755); err != nil {
+		return err
+	}
+	rootfs := filepath.Join(b.bundlePath, ocibundle.RootFs)
+	if err := sources.UnpackImage(ctx, ref, b.sysCtx, rootfs, sytypes.WhiteoutRemove); err != nil {
+		b.delete(false)
+		return fmt.Errorf("while unpacking %s: %s", b.imageRef, err)
+	}
+
+	// the image is in the cache once unpacked
+	if err := b.writeConfig(ctx, ref, ociConfig); err != nil {
+		b.delete(false)
+		return err
+	}
+	return nil
+}
+
+// Update writes the runtime configuration of the bundle again, the image
+// configuration is read from the cache
+func (b *ociBundle) Update(ociConfig *specs.Spec) error {
+	if err := ocibundle.CheckCreated(b.bundlePath); err != nil {
+		return err
+	}
+	l, err := ocibundle.Lock(b.bundlePath)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	ref, err := ociclient.ParseImageName(b.imageRef, b.sysCtx)
+	if err != nil {
+		return err
+	}
+	return b.writeConfig(context.Background(), ref, ociConfig)
+}
+
+// writeConfig writes the runtime configuration of the bundle from the
+// configuration of the image ref, which must be in the cache
+func (b *ociBundle) writeConfig(ctx context.Context, ref types.ImageReference, ociConfig *specs.Spec) error {
+	img, err := ref.NewImage(ctx, b.sysCtx)
+	if err != nil {
+		return fmt.Errorf("while reading image %s: %s", b.imageRef, err)
+	}
+	imgConfig, err := img.OCIConfig(ctx)
+	img.Close()
+	if err != nil {
+		return fmt.Errorf("while reading configuration of %s: %s", b.imageRef, err)
+	}
+
+	g, err := ocibundle.Generator(imgConfig.Config, ociConfig)
+	if err != nil {
+		return err
+	}
+	return ocibundle.SaveConfig(g, b.bundlePath)
+}
+
+// Delete removes the bundle directory, after unmounting its overlay.
+// ErrBundleBusy is returned if another process is creating or deleting the
+// bundle.
+func (b *ociBundle) Delete(force bool) error {
+	l, err := ocibundle.Lock(b.bundlePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	return b.delete(force)
+}
+
+// delete removes the bundle with its lock held
+func (b *ociBundle) delete(force bool) error {
+	if err := ocibundle.DeleteOverlay(b.bundlePath, force); err != nil {
+		return err
+	}
+	if err := ocibundle.CheckUnmounted(b.bundlePath); err != nil {
+		return err
+	}
+	return os.RemoveAll(b.bundlePath)
+}
+
+// Path returns the bundle directory
+func (b *ociBundle) Path() string {
+	return b.bundlePath
+}
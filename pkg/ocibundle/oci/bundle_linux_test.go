@@ -0,0 +1,189 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-tools/generate"
+	"github.com/sylabs/singularity/internal/pkg/client/cache"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+// writeLayout writes an OCI image layout in dir, with a single layer
+// holding the files, tagged latest
+func writeLayout(t *testing.T, dir string, files map[string]string, config string) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		t.Fatalf("failed to create blobs directory: %v", err)
+	}
+	blob := func(data []byte) (string, string) {
+		digest := fmt.Sprintf("%x", sha256.Sum256(data))
+		if err := ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", digest), data, 0644); err != nil {
+			t.Fatalf("failed to write blob: %v", err)
+		}
+		return digest, fmt.Sprintf(`"digest": "sha256:%s", "size": %d`, digest, len(data))
+	}
+
+	var layer, diff bytes.Buffer
+	gz := gzip.NewWriter(&layer)
+	tw := tar.NewWriter(io.MultiWriter(gz, &diff))
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write layer: %v", err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+
+	diffID := fmt.Sprintf("%x", sha256.Sum256(diff.Bytes()))
+	_, layerDesc := blob(layer.Bytes())
+	_, configDesc := blob([]byte(`{"architecture": "amd64", "os": "linux", "config": ` + config + `, "rootfs": {"type": "layers", "diff_ids": ["sha256:` + diffID + `"]}}`))
+	_, manifestDesc := blob([]byte(`{"schemaVersion": 2, "config": {"mediaType": "application/vnd.oci.image.config.v1+json", ` + configDesc + `}, "layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", ` + layerDesc + `}]}`))
+	index := `{"schemaVersion": 2, "manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", ` + manifestDesc + `, "annotations": {"org.opencontainers.image.ref.name": "latest"}}]}`
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0644); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644); err != nil {
+		t.Fatalf("failed to write layout: %v", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocibundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv(cache.DirEnv, filepath.Join(dir, "cache"))
+	defer os.Unsetenv(cache.DirEnv)
+
+	layout := filepath.Join(dir, "layout")
+	writeLayout(t, layout, map[string]string{"etc/hostname": "bundle\n"},
+		`{"Env": ["PATH=/bin", "FOO=image"], "Entrypoint": ["/bin/sh"], "Cmd": ["-c", "true"], "WorkingDir": "/tmp", "User": "1000:100"}`)
+
+	tests := []struct {
+		name      string
+		ociConfig *specs.Spec
+		args      []string
+		env       []string
+		uid       uint32
+	}{
+		{
+			name: "image",
+			args: []string{"/bin/sh", "-c", "true"},
+			env:  []string{"PATH=/bin", "FOO=image"},
+			uid:  1000,
+		},
+		{
+			name: "config",
+			ociConfig: &specs.Spec{
+				Process: &specs.Process{
+					Args: []string{"/bin/true"},
+					Env:  []string{"FOO=config"},
+				},
+			},
+			args: []string{"/bin/true"},
+			env:  []string{"FOO=config", "PATH=/bin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := FromImageRef("oci:"+layout+":latest", filepath.Join(dir, tt.name), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := b.Create(context.Background(), tt.ociConfig); err != nil {
+				t.Fatalf("unexpected error creating bundle: %v", err)
+			}
+			defer b.Delete(false)
+
+			content, err := ioutil.ReadFile(filepath.Join(b.Path(), ocibundle.RootFs, "etc", "hostname"))
+			if err != nil || string(content) != "bundle\n" {
+				t.Errorf("unexpected root filesystem content %q: %v", content, err)
+			}
+
+			g, err := generate.NewFromFile(filepath.Join(b.Path(), ocibundle.Config))
+			if err != nil {
+				t.Fatalf("failed to read runtime configuration: %v", err)
+			}
+			p := g.Config.Process
+			if !reflect.DeepEqual(p.Args, tt.args) {
+				t.Errorf("unexpected arguments %v, expected %v", p.Args, tt.args)
+			}
+			if !reflect.DeepEqual(p.Env, tt.env) {
+				t.Errorf("unexpected environment %v, expected %v", p.Env, tt.env)
+			}
+			if p.Cwd != "/tmp" || p.User.UID != tt.uid {
+				t.Errorf("unexpected working directory %s or user %d", p.Cwd, p.User.UID)
+			}
+			if g.Config.Root.Path != ocibundle.RootFs {
+				t.Errorf("unexpected root path %s", g.Config.Root.Path)
+			}
+		})
+	}
+
+	if _, err := FromImageRef("alpine", dir, nil); err == nil {
+		t.Errorf("unexpected success with a reference without transport")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocibundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv(cache.DirEnv, filepath.Join(dir, "cache"))
+	defer os.Unsetenv(cache.DirEnv)
+
+	layout := filepath.Join(dir, "layout")
+	writeLayout(t, layout, map[string]string{"etc/hostname": "bundle\n"}, `{"Cmd": ["/bin/sh"]}`)
+
+	b, err := FromImageRef("oci:"+layout+":latest", filepath.Join(dir, "bundle"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Update(nil); err == nil {
+		t.Errorf("unexpected success updating a bundle not created")
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+
+	ociConfig := &specs.Spec{Process: &specs.Process{Args: []string{"/bin/true"}}}
+	if err := b.Update(ociConfig); err != nil {
+		t.Fatalf("unexpected error updating bundle: %v", err)
+	}
+	g, err := generate.NewFromFile(filepath.Join(b.Path(), ocibundle.Config))
+	if err != nil {
+		t.Fatalf("failed to read runtime configuration: %v", err)
+	}
+	if args := g.Config.Process.Args; !reflect.DeepEqual(args, ociConfig.Process.Args) {
+		t.Errorf("unexpected arguments %v after update, expected %v", args, ociConfig.Process.Args)
+	}
+	fi, err := os.Stat(filepath.Join(b.Path(), ocibundle.Config))
+	if err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("unexpected runtime configuration mode: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/imgmount"
+)
+
+const (
+	// lowerDir holds the root filesystem of the image while the overlay
+	// is mounted on the root filesystem of the bundle
+	lowerDir = "lower"
+	// overlayDir holds the upper and work directories of the overlay
+	overlayDir = "overlay"
+)
+
+// MountOptions are the options of the mounts of the root filesystem of a
+// bundle
+type MountOptions struct {
+	// Context is the SELinux context given to the files of the root
+	// filesystem, like a container_file_t context for container_t
+	// processes on enforcing hosts
+	Context string
+	// Suid and Dev allow setuid programs and device files in the root
+	// filesystem, it is mounted nosuid and nodev otherwise
+	Suid bool
+	Dev  bool
+	// Options are mount options of the overlay, like index=on,
+	// metacopy=on, xino=auto or noatime
+	Options []string
+}
+
+// CreateOverlay makes the root filesystem of the bundle at bundlePath
+// writable through an overlay, leaving the files of the image untouched.
+// If image is empty, the writable layer is a directory of the bundle which
+// is discarded by DeleteOverlay. Otherwise it is the directory or the ext3
+// image file at image, attached by loop, so changes survive the deletion of
+// the bundle and are applied again when image is given to another bundle,
+// like the persistent overlays of singularity. The overlay is mounted with
+// the options opts, or the default ones if nil. Unprivileged users get the
+// overlay mounted with fuse-overlayfs.
+//
+// A root filesystem mounted from the image can't be moved, the overlay is
+// then stacked on its mount point, which is its own lower directory.
+func CreateOverlay(bundlePath, image string, opts *MountOptions) (err error) {
+	if opts == nil {
+		opts = &MountOptions{}
+	}
+	rootfs := filepath.Join(bundlePath, RootFs)
+	lower := filepath.Join(bundlePath, lowerDir)
+	overlay := filepath.Join(bundlePath, overlayDir)
+
+	for _, dir := range []string{lower, overlay} {
+		if _, err := os.Stat(dir); err == nil {
+			return fmt.Errorf("bundle %s already has an overlay", bundlePath)
+		}
+	}
+	mounted, err := isMountPoint(rootfs)
+	if err != nil {
+		return fmt.Errorf("while checking root filesystem: %s", err)
+	}
+	lowerdir := rootfs
+	if !mounted {
+		if err := os.Rename(rootfs, lower); err != nil {
+			return fmt.Errorf("while moving root filesystem: %s", err)
+		}
+		lowerdir = lower
+	}
+	defer func() {
+		if err != nil {
+			if derr := DeleteOverlay(bundlePath, false); derr != nil {
+				sylog.Warningf("Could not remove overlay of bundle %s: %s", bundlePath, derr)
+			}
+		}
+	}()
+	if !mounted {
+		if err := os.Mkdir(rootfs, 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(overlay, 0755); err != nil {
+		return err
+	}
+
+	// upper and work directories are kept in a persistent directory, the
+	// overlay directory of the bundle is left empty
+	layer := overlay
+	if fi, err := os.Stat(image); err == nil && fi.IsDir() {
+		layer = image
+	} else if image != "" {
+		if err := imgmount.Mount(image, overlay, true, nil); err != nil {
+			return fmt.Errorf("while mounting overlay image %s: %s", image, err)
+		}
+	}
+	upper := filepath.Join(layer, "upper")
+	work := filepath.Join(layer, "work")
+	for _, dir := range []string{upper, work} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lowerdir, upper, work)
+	sylog.Debugf("Mounting overlay on %s with %s", rootfs, data)
+	if os.Geteuid() != 0 {
+		if len(opts.Options) > 0 {
+			data += "," + strings.Join(opts.Options, ",")
+		}
+		if opts.Context != "" {
+			data += "," + imgmount.FuseContextOption(opts.Context)
+		}
+		return mountFuseOverlay(rootfs, data)
+	}
+	flags := uintptr(0)
+	if !opts.Suid {
+		flags |= syscall.MS_NOSUID
+	}
+	if !opts.Dev {
+		flags |= syscall.MS_NODEV
+	}
+	flags, fsOpts := imgmount.ParseOptions(flags, opts.Options)
+	if len(fsOpts) > 0 {
+		data += "," + strings.Join(fsOpts, ",")
+	}
+	if opts.Context != "" {
+		data += "," + imgmount.ContextOption(opts.Context)
+	}
+	if err := syscall.Mount("overlay", rootfs, "overlay", flags, data); err != nil {
+		return fmt.Errorf("while mounting overlay: %s", err)
+	}
+	return nil
+}
+
+// OverlayLayer returns the directory holding the upper and work directories
+// of the overlay of the bundle at bundlePath, created by CreateOverlay with
+// image
+func OverlayLayer(bundlePath, image string) (string, error) {
+	layer := filepath.Join(bundlePath, overlayDir)
+	if fi, err := os.Stat(image); err == nil && fi.IsDir() {
+		layer = image
+	}
+	if _, err := os.Stat(filepath.Join(layer, "upper")); err != nil {
+		return "", fmt.Errorf("bundle %s has no overlay: %s", bundlePath, err)
+	}
+	return layer, nil
+}
+
+// DeleteOverlay unmounts the overlay of the bundle at bundlePath, if any,
+// and restores its root filesystem. The content of a persistent directory
+// or overlay image is kept, an image is detached once unmounted. With
+// force, busy mounts are detached lazily.
+func DeleteOverlay(bundlePath string, force bool) error {
+	rootfs := filepath.Join(bundlePath, RootFs)
+	lower := filepath.Join(bundlePath, lowerDir)
+	overlay := filepath.Join(bundlePath, overlayDir)
+
+	_, lerr := os.Stat(lower)
+	_, oerr := os.Stat(overlay)
+	if os.IsNotExist(lerr) && os.IsNotExist(oerr) {
+		return nil
+	}
+
+	// only the overlay is unmounted from a root filesystem mounted from
+	// the image
+	if mounted, err := isMountPoint(rootfs); err == nil && mounted && isOverlay(rootfs) {
+		if err := unmount(rootfs, force); err != nil {
+			return err
+		}
+	}
+	if mounted, err := isMountPoint(overlay); err == nil && mounted {
+		if err := unmount(overlay, force); err != nil {
+			return err
+		}
+	}
+
+	// the overlay image is unmounted, only a directory overlay is removed
+	if err := os.RemoveAll(overlay); err != nil {
+		return err
+	}
+	if os.IsNotExist(lerr) {
+		return nil
+	}
+	if err := os.Remove(rootfs); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(lower, rootfs)
+}
+
+// mountFuseOverlay mounts an overlay with the options opts on rootfs with
+// fuse-overlayfs, for unprivileged users
+func mountFuseOverlay(rootfs, opts string) error {
+	p, err := exec.LookPath("fuse-overlayfs")
+	if err != nil {
+		return fmt.Errorf("fuse-overlayfs is required to mount an overlay as an unprivileged user: %s", err)
+	}
+	sylog.Debugf("Mounting overlay on %s with %s", rootfs, p)
+	if out, err := exec.Command(p, "-o", opts, rootfs).CombinedOutput(); err != nil {
+		return fmt.Errorf("while mounting overlay: %s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting an overlay requires privileges")
+	}
+
+	bundle, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(bundle)
+
+	rootfs := filepath.Join(bundle, RootFs)
+	if err := os.Mkdir(rootfs, 0755); err != nil {
+		t.Fatalf("failed to create root filesystem: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "image"), []byte("image"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := CreateOverlay(bundle, "", nil); err != nil {
+		t.Fatalf("unexpected error creating overlay: %v", err)
+	}
+	if err := CreateOverlay(bundle, "", nil); err == nil {
+		t.Errorf("unexpected success creating a second overlay")
+	}
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "image"), []byte("changed"), 0644); err != nil {
+		DeleteOverlay(bundle, false)
+		t.Fatalf("failed to write file in overlay: %v", err)
+	}
+	if err := DeleteOverlay(bundle, false); err != nil {
+		t.Fatalf("unexpected error deleting overlay: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(rootfs, "image"))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != "image" {
+		t.Errorf("file of the image was modified through the overlay: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(bundle, overlayDir)); !os.IsNotExist(err) {
+		t.Errorf("overlay directory was not removed: %v", err)
+	}
+}
+
+func TestOverlayMountedRootfs(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting an overlay requires privileges")
+	}
+
+	bundle, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(bundle)
+
+	// a tmpfs stands for a root filesystem mounted from the image
+	rootfs := filepath.Join(bundle, RootFs)
+	if err := os.Mkdir(rootfs, 0755); err != nil {
+		t.Fatalf("failed to create root filesystem: %v", err)
+	}
+	if err := syscall.Mount("tmpfs", rootfs, "tmpfs", 0, ""); err != nil {
+		t.Fatalf("failed to mount root filesystem: %v", err)
+	}
+	defer syscall.Unmount(rootfs, syscall.MNT_DETACH)
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "image"), []byte("image"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := CreateOverlay(bundle, "", nil); err != nil {
+		t.Fatalf("unexpected error creating overlay: %v", err)
+	}
+	if !isOverlay(rootfs) {
+		t.Errorf("overlay not mounted on root filesystem")
+	}
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "image"), []byte("changed"), 0644); err != nil {
+		DeleteOverlay(bundle, false)
+		t.Fatalf("failed to write file in overlay: %v", err)
+	}
+	if err := DeleteOverlay(bundle, false); err != nil {
+		t.Fatalf("unexpected error deleting overlay: %v", err)
+	}
+
+	if mounted, err := isMountPoint(rootfs); err != nil || !mounted || isOverlay(rootfs) {
+		t.Errorf("root filesystem of the image not kept mounted: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(rootfs, "image"))
+	if err != nil || string(data) != "image" {
+		t.Errorf("file of the image was modified through the overlay: %q: %v", data, err)
+	}
+	for _, dir := range []string{lowerDir, overlayDir} {
+		if _, err := os.Stat(filepath.Join(bundle, dir)); !os.IsNotExist(err) {
+			t.Errorf("%s directory was not removed: %v", dir, err)
+		}
+	}
+}
+
+func TestPersistentOverlay(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting an overlay requires privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	layers := []string{filepath.Join(dir, "overlay")}
+	if err := os.Mkdir(layers[0], 0755); err != nil {
+		t.Fatalf("failed to create overlay directory: %v", err)
+	}
+	if mkfs, err := exec.LookPath("mkfs.ext3"); err == nil {
+		image := filepath.Join(dir, "overlay.img")
+		if out, err := exec.Command(mkfs, "-q", image, "8M").CombinedOutput(); err != nil {
+			t.Fatalf("failed to create overlay image: %v: %s", err, out)
+		}
+		layers = append(layers, image)
+	} else {
+		t.Logf("mkfs.ext3 not found, not testing overlay images")
+	}
+
+	// changes made in a first bundle are found in a second one
+	for _, layer := range layers {
+		for i, content := range []string{"", "changed"} {
+			bundle := filepath.Join(dir, fmt.Sprintf("%s-bundle%d", filepath.Base(layer), i))
+			rootfs := filepath.Join(bundle, RootFs)
+			if err := os.MkdirAll(rootfs, 0755); err != nil {
+				t.Fatalf("failed to create root filesystem: %v", err)
+			}
+			if err := CreateOverlay(bundle, layer, nil); err != nil {
+				t.Fatalf("unexpected error creating overlay with %s: %v", layer, err)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(rootfs, "file"))
+			if content == "" {
+				err = ioutil.WriteFile(filepath.Join(rootfs, "file"), []byte("changed"), 0644)
+			} else if string(data) != content {
+				t.Errorf("unexpected content %q in overlay %s, expected %q: %v", data, layer, content, err)
+			}
+			if derr := DeleteOverlay(bundle, false); derr != nil {
+				t.Fatalf("unexpected error deleting overlay: %v", derr)
+			}
+			if err != nil {
+				t.Fatalf("failed to write file in overlay: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(rootfs, "file")); !os.IsNotExist(err) {
+				t.Errorf("file written in overlay found in root filesystem: %v", err)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(layers[0], "upper", "file")); err != nil {
+		t.Errorf("file not kept in persistent directory: %v", err)
+	}
+}
+
+func TestOverlayMountOptions(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting an overlay requires privileges")
+	}
+
+	tests := []struct {
+		name    string
+		opts    *MountOptions
+		options []string
+		absent  []string
+	}{
+		{"default", nil, []string{"nosuid", "nodev"}, nil},
+		{"suid", &MountOptions{Suid: true}, []string{"nodev"}, []string{"nosuid"}},
+		{"suid and dev", &MountOptions{Suid: true, Dev: true}, nil, []string{"nosuid", "nodev"}},
+	}
+	for _, tt := range tests {
+		bundle, err := ioutil.TempDir("", "bundle-")
+		if err != nil {
+			t.Fatalf("failed to create temporary directory: %v", err)
+		}
+		defer os.RemoveAll(bundle)
+		if err := os.Mkdir(filepath.Join(bundle, RootFs), 0755); err != nil {
+			t.Fatalf("failed to create root filesystem: %v", err)
+		}
+
+		if err := CreateOverlay(bundle, "", tt.opts); err != nil {
+			t.Fatalf("%s: unexpected error creating overlay: %v", tt.name, err)
+		}
+		options := mountOptions(t, filepath.Join(bundle, RootFs))
+		if err := DeleteOverlay(bundle, false); err != nil {
+			t.Fatalf("%s: unexpected error deleting overlay: %v", tt.name, err)
+		}
+		for _, o := range tt.options {
+			if !options[o] {
+				t.Errorf("%s: overlay not mounted %s", tt.name, o)
+			}
+		}
+		for _, o := range tt.absent {
+			if options[o] {
+				t.Errorf("%s: overlay mounted %s", tt.name, o)
+			}
+		}
+	}
+}
+
+// mountOptions returns the options of the last mount on path
+func mountOptions(t *testing.T, path string) map[string]bool {
+	data, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[4] != path {
+			continue
+		}
+		options = map[string]bool{}
+		for _, o := range strings.Split(fields[5], ",") {
+			options[o] = true
+		}
+	}
+	return options
+}
+
+func TestParseMountInfo(t *testing.T) {
+	mountinfo := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+40 22 7:0 / /bundle/rootfs ro,nosuid,nodev - squashfs /dev/loop0 ro
+41 40 0:45 / /bundle/rootfs rw,nosuid,nodev,relatime shared:20 - fuse.fuse-overlayfs fuse-overlayfs rw,user_id=1000
+42 22 0:46 / /bundle/overlay rw master:3 - overlay overlay rw,lowerdir=/a
+`
+	tests := []struct {
+		path   string
+		fstype string
+		source string
+	}{
+		{"/", "ext4", "/dev/sda1"},
+		{"/bundle/rootfs", "fuse.fuse-overlayfs", "fuse-overlayfs"},
+		{"/bundle/overlay", "overlay", "overlay"},
+		{"/bundle", "", ""},
+	}
+	for _, tt := range tests {
+		fstype, source := parseMountInfo(strings.NewReader(mountinfo), tt.path)
+		if fstype != tt.fstype || source != tt.source {
+			t.Errorf("unexpected mount %s %s of %s, expected %s %s", fstype, source, tt.path, tt.fstype, tt.source)
+		}
+	}
+}
+
+func TestMountFuseOverlay(t *testing.T) {
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", "")
+
+	err := mountFuseOverlay(os.TempDir(), "lowerdir=/")
+	if err == nil || !strings.Contains(err.Error(), "fuse-overlayfs is required") {
+		t.Errorf("unexpected error without fuse-overlayfs: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocibundle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/imgmount"
+)
+
+// UnmountRootfs unmounts the root filesystem of the bundle at bundlePath if
+// it was mounted from the image with a loop device or a FUSE helper. The
+// overlay of the bundle must be deleted first. With force, a busy root
+// filesystem is detached lazily.
+func UnmountRootfs(bundlePath string, force bool) error {
+	rootfs := filepath.Join(bundlePath, RootFs)
+	if mounted, err := isMountPoint(rootfs); err != nil || !mounted {
+		return nil
+	}
+	return unmount(rootfs, force)
+}
+
+// propagationFlags are the mount flags of the propagation types
+var propagationFlags = map[string]uintptr{
+	"private":     syscall.MS_PRIVATE,
+	"rprivate":    syscall.MS_PRIVATE | syscall.MS_REC,
+	"slave":       syscall.MS_SLAVE,
+	"rslave":      syscall.MS_SLAVE | syscall.MS_REC,
+	"shared":      syscall.MS_SHARED,
+	"rshared":     syscall.MS_SHARED | syscall.MS_REC,
+	"unbindable":  syscall.MS_UNBINDABLE,
+	"runbindable": syscall.MS_UNBINDABLE | syscall.MS_REC,
+}
+
+// SetPropagation sets the propagation type of the root filesystem of the
+// bundle at bundlePath, like private, rslave or shared as in the OCI
+// runtime specification. The root filesystem must be a mount point, and
+// changing it requires privileges.
+func SetPropagation(bundlePath, propagation string) error {
+	flags, ok := propagationFlags[propagation]
+	if !ok {
+		return fmt.Errorf("unknown propagation type %s", propagation)
+	}
+	rootfs := filepath.Join(bundlePath, RootFs)
+	if mounted, err := isMountPoint(rootfs); err != nil {
+		return err
+	} else if !mounted {
+		return fmt.Errorf("root filesystem %s is not a mount point, its propagation can't be set", rootfs)
+	}
+	if err := syscall.Mount("", rootfs, "", flags, ""); err != nil {
+		return fmt.Errorf("while setting propagation of %s to %s: %s", rootfs, propagation, err)
+	}
+	return nil
+}
+
+// CheckUnmounted returns an error if a filesystem is still mounted in the
+// bundle at bundlePath, removing the bundle would then remove the files of
+// the image or of a persistent overlay
+func CheckUnmounted(bundlePath string) error {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		if fields[4] == bundlePath || strings.HasPrefix(fields[4], bundlePath+"/") {
+			return fmt.Errorf("%s is still mounted, bundle %s not removed", fields[4], bundlePath)
+		}
+	}
+	return scanner.Err()
+}
+
+// unmount unmounts path, or detaches it lazily with force if it is busy
+func unmount(path string, force bool) error {
+	err := imgmount.Umount(path)
+	if err != nil && force {
+		sylog.Warningf("Detaching %s lazily: %s", path, err)
+		err = imgmount.UmountLazy(path)
+	}
+	if err != nil {
+		return fmt.Errorf("while unmounting %s: %s", path, err)
+	}
+	return nil
+}
+
+// isMountPoint returns whether path is on another device than its parent
+// directory
+func isMountPoint(path string) (bool, error) {
+	var st, parent syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return false, err
+	}
+	if err := syscall.Lstat(filepath.Dir(path), &parent); err != nil {
+		return false, err
+	}
+	return st.Dev != parent.Dev, nil
+}
+
+// RootfsSource returns the source of the last filesystem mounted on the
+// root filesystem of the bundle at bundlePath, like the loop device of the
+// image, or an empty string if none is mounted
+func RootfsSource(bundlePath string) string {
+	_, source := lastMount(filepath.Join(bundlePath, RootFs))
+	return source
+}
+
+// isOverlay returns whether the last filesystem mounted on path is an
+// overlay, mounted by the kernel or by fuse-overlayfs
+func isOverlay(path string) bool {
+	fstype, _ := lastMount(path)
+	return fstype == "overlay" || fstype == "fuse.fuse-overlayfs"
+}
+
+// lastMount returns the type and the source of the last filesystem mounted
+// on path, or empty strings if none is mounted
+func lastMount(path string) (string, string) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	return parseMountInfo(f, path)
+}
+
+// parseMountInfo returns the type and the source of the last filesystem
+// mounted on path found in the mountinfo content r
+func parseMountInfo(r io.Reader, path string) (fstype, source string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// the optional fields end with a separator before the type
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[4] != path {
+			continue
+		}
+		for i := 5; i+2 < len(fields); i++ {
+			if fields[i] == "-" {
+				fstype, source = fields[i+1], fields[i+2]
+				break
+			}
+		}
+	}
+	return fstype, source
+}
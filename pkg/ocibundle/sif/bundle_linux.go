@@ -0,0 +1,429 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package sif creates OCI runtime bundles from SIF images, their primary
+// partition is mounted or extracted in the root filesystem of the bundle
+// and their runscript, environment and labels are translated in its
+// runtime configuration.
+package sif
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-tools/generate"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/internal/pkg/image"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/imgmount"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+	"github.com/sylabs/singularity/pkg/util/loop"
+)
+
+// defaultPath is the PATH of the process when the image doesn't set it
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// dockerEnvironment is the environment script of images built from OCI and
+// docker images
+const dockerEnvironment = "env/10-docker2singularity.sh"
+
+// Options are the options of bundles created from SIF images
+type Options struct {
+	// Instance runs the startscript of the image, like singularity
+	// instance start, rather than its runscript
+	Instance bool
+	// Overlay makes the root filesystem writable through an overlay
+	// whose upper and work directories are kept in the directory or the
+	// ext3 image file Overlay, so changes survive the deletion of the
+	// bundle and are found again by the next bundle given the same
+	// Overlay. Unprivileged users need fuse-overlayfs.
+	Overlay string
+	// Writable mounts an ext3 primary partition read-write, changes are
+	// written to the image and Overlay is ignored. Squashfs partitions
+	// can only be made writable with Overlay.
+	Writable bool
+	// Extract extracts a squashfs primary partition in the root
+	// filesystem rather than mounting it, for environments where neither
+	// loop devices nor FUSE can be used. It needs unsquashfs and the disk
+	// space of the partition.
+	Extract bool
+	// MaxLoopDevices and LoopRange restrict the loop devices attached to
+	// mount the image as root, the max loop devices and loop device range
+	// directives of singularity.conf are used when unset
+	MaxLoopDevices int
+	LoopRange      *loop.Range
+	// Context is the SELinux context given to the files of the root
+	// filesystem by its mounts, like system_u:object_r:container_file_t:s0
+	// for container_t processes on enforcing hosts
+	Context string
+	// Suid and Dev allow setuid programs and device files in the root
+	// filesystem, it is mounted nosuid and nodev otherwise. Mounts of
+	// unprivileged users are always nosuid and nodev.
+	Suid bool
+	Dev  bool
+	// RootfsOptions are mount options of the root filesystem mounted
+	// from the image, like noatime or ro, and options of its filesystem
+	// replacing the default errors=remount-ro of ext3 partitions
+	RootfsOptions []string
+	// OverlayOptions are mount options of the overlay, like index=on,
+	// metacopy=on or xino=auto
+	OverlayOptions []string
+	// Propagation is the propagation type set on the root filesystem once
+	// mounted, like private, rslave or shared, as root
+	Propagation string
+	// Events receives the events of the phases of Create, with their
+	// duration and error, to report progress or diagnose slow hosts
+	Events ocibundle.EventHandler
+}
+
+type sifBundle struct {
+	bundlePath string
+	image      string
+	opts       Options
+}
+
+// FromSif returns a bundle at bundlePath for the SIF image at path, with
+// the options opts or the default ones if nil. The primary partition is
+// mounted with a loop device as root, with squashfuse or fuse2fs otherwise,
+// or extracted when they can't be used or when requested, so bundles can be
+// created by unprivileged users for rootless runtimes.
+func FromSif(path, bundlePath string, opts *Options) (ocibundle.Bundle, error) {
+	img, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	b := &sifBundle{
+		bundlePath: abs,
+		image:      img,
+	}
+	if opts != nil {
+		b.opts = *opts
+	}
+	return b, nil
+}
+
+// Create mounts or extracts the image in the root filesystem of the bundle
+// and writes its state and its runtime configuration. ErrBundleBusy is
+// returned if another process is creating or deleting the bundle.
+func (b *sifBundle) Create(ctx context.Context, ociConfig *specs.Spec) error {
+	if err := os.MkdirAll(filepath.Dir(b.bundlePath), 0755); err != nil {
+		return err
+	}
+	l, err := ocibundle.Lock(b.bundlePath)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	// an existing bundle would be removed when failing to create it again
+	if err := ocibundle.CheckCreated(b.bundlePath); err == nil {
+		return fmt.Errorf("bundle %s is already created", b.bundlePath)
+	}
+	if err := os.MkdirAll(b.bundlePath, 0755); err != nil {
+		return err
+	}
+	rootfs := filepath.Join(b.bundlePath, ocibundle.RootFs)
+	if err := b.mountRootfs(rootfs); err != nil {
+		b.delete(false)
+		return err
+	}
+	if err := b.writeState(); err != nil {
+		b.delete(false)
+		return err
+	}
+	if b.opts.Overlay != "" && b.opts.Writable {
+		sylog.Warningf("Overlay %s not used, the partition of %s is mounted writable", b.opts.Overlay, b.image)
+	} else if b.opts.Overlay != "" {
+		start := time.Now()
+		err := ocibundle.CreateOverlay(b.bundlePath, b.opts.Overlay, &ocibundle.MountOptions{
+			Context: b.opts.Context,
+			Suid:    b.opts.Suid,
+			Dev:     b.opts.Dev,
+			Options: b.opts.OverlayOptions,
+		})
+		b.event(ocibundle.PhaseOverlayCreate, start, b.opts.Overlay, err)
+		if err != nil {
+			b.delete(false)
+			return err
+		}
+	}
+	if b.opts.Propagation != "" {
+		if err := ocibundle.SetPropagation(b.bundlePath, b.opts.Propagation); err != nil {
+			b.delete(false)
+			return err
+		}
+	}
+
+	g, err := b.generator(rootfs, ociConfig)
+	if err != nil {
+		b.delete(false)
+		return err
+	}
+	if err := ocibundle.SaveConfig(g, b.bundlePath); err != nil {
+		b.delete(false)
+		return err
+	}
+	return nil
+}
+
+// Update writes the runtime configuration of the bundle again, with the
+// metadata of the image read from its extracted root filesystem if they
+// are not stored in the image
+func (b *sifBundle) Update(ociConfig *specs.Spec) error {
+	if err := ocibundle.CheckCreated(b.bundlePath); err != nil {
+		return err
+	}
+	l, err := ocibundle.Lock(b.bundlePath)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	g, err := b.generator(filepath.Join(b.bundlePath, ocibundle.RootFs), ociConfig)
+	if err != nil {
+		return err
+	}
+	return ocibundle.SaveConfig(g, b.bundlePath)
+}
+
+// Delete removes the bundle directory, after unmounting its overlay and
+// its root filesystem. The directory is kept if anything is still mounted
+// in it, so a writable image or a persistent overlay is never removed.
+// With force, the loop device recorded in the state of the bundle is
+// detached if it is still attached to the image, as left by a process
+// killed while the bundle was created. ErrBundleBusy is returned if
+// another process is creating or deleting the bundle.
+func (b *sifBundle) Delete(force bool) error {
+	l, err := ocibundle.Lock(b.bundlePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	return b.delete(force)
+}
+
+// delete removes the bundle with its lock held
+func (b *sifBundle) delete(force bool) error {
+	if err := ocibundle.DeleteOverlay(b.bundlePath, force); err != nil {
+		return err
+	}
+	if err := ocibundle.UnmountRootfs(b.bundlePath, force); err != nil {
+		return err
+	}
+	if force {
+		b.detachLoopDevice()
+	}
+	if err := ocibundle.CheckUnmounted(b.bundlePath); err != nil {
+		return err
+	}
+	return os.RemoveAll(b.bundlePath)
+}
+
+// mountRootfs mounts the primary partition of the image on rootfs,
+// read-only unless a writable ext3 partition was requested. The partition
+// is extracted instead when requested, or when it can't be mounted, like
+// for unprivileged users without the FUSE helpers or without access to
+// FUSE, and for root without loop devices.
+func (b *sifBundle) mountRootfs(rootfs string) error {
+	if b.opts.Writable && b.opts.Extract {
+		return fmt.Errorf("writable partitions can't be extracted")
+	}
+	start := time.Now()
+	fstype, err := primaryFsType(b.image)
+	b.event(ocibundle.PhaseLoadImage, start, b.image, err)
+	if err != nil {
+		return err
+	}
+	if b.opts.Writable && fstype != "ext3" {
+		return fmt.Errorf("primary partition of %s is %s, only ext3 partitions can be mounted writable", b.image, fstype)
+	}
+	if b.opts.Extract {
+		return b.extractRootfs(rootfs)
+	}
+	if err := os.Mkdir(rootfs, 0755); err != nil {
+		return err
+	}
+	mountOpts := &imgmount.Options{
+		Loop: &imgmount.LoopOptions{
+			MaxDevices: b.opts.MaxLoopDevices,
+			Range:      b.opts.LoopRange,
+			Attached: func(device string, d time.Duration, err error) {
+				b.emit(ocibundle.Event{Phase: ocibundle.PhaseLoopAttach, Duration: d, Detail: device, Err: err})
+			},
+		},
+		Context:      b.opts.Context,
+		Suid:         b.opts.Suid,
+		Dev:          b.opts.Dev,
+		MountOptions: b.opts.RootfsOptions,
+	}
+	start = time.Now()
+	err = imgmount.Mount(b.image, rootfs, b.opts.Writable, mountOpts)
+	b.event(ocibundle.PhaseMount, start, b.image, err)
+	if err == nil {
+		return nil
+	} else if b.opts.Writable {
+		return fmt.Errorf("while mounting %s: %s", b.image, err)
+	}
+
+	// root is expected to mount images, extracting them may hide a
+	// misconfiguration and uses disk space
+	if os.Geteuid() == 0 {
+		sylog.Warningf("Could not mount %s, extracting it: %s", b.image, err)
+	} else {
+		sylog.Debugf("Could not mount %s, extracting it: %s", b.image, err)
+	}
+	if err := os.Remove(rootfs); err != nil {
+		return err
+	}
+	return b.extractRootfs(rootfs)
+}
+
+// extractRootfs extracts the squashfs primary partition of the image in
+// rootfs
+func (b *sifBundle) extractRootfs(rootfs string) error {
+	start := time.Now()
+	err := image.ExtractSIFRootfs(b.image, rootfs)
+	b.event(ocibundle.PhaseExtract, start, b.image, err)
+	if err != nil {
+		return fmt.Errorf("while extracting %s: %s", b.image, err)
+	}
+	return nil
+}
+
+// primaryFsType returns the filesystem type of the primary partition of the
+// SIF image at path
+func primaryFsType(path string) (string, error) {
+	objects, err := image.SIFObjects(path)
+	if err != nil {
+		return "", fmt.Errorf("while reading %s: %s", path, err)
+	}
+	for _, o := range objects {
+		if o.Type == "partition" && o.PartType == "primary system" {
+			return o.FsType, nil
+		}
+	}
+	return "", fmt.Errorf("no primary partition found in %s", path)
+}
+
+// event reports the phase started at start to the event handler of the
+// bundle, with the object of the phase detail and its error
+func (b *sifBundle) event(phase ocibundle.Phase, start time.Time, detail string, err error) {
+	b.emit(ocibundle.Event{Phase: phase, Duration: time.Since(start), Detail: detail, Err: err})
+}
+
+// emit sends e to the event handler of the bundle, if any
+func (b *sifBundle) emit(e ocibundle.Event) {
+	if b.opts.Events == nil {
+		return
+	}
+	e.Bundle = b.bundlePath
+	b.opts.Events.HandleEvent(e)
+}
+
+// Path returns the bundle directory
+func (b *sifBundle) Path() string {
+	return b.bundlePath
+}
+
+// generator returns a generator of the runtime configuration ociConfig, or
+// of a default one if nil, running the image like singularity run, or like
+// singularity instance start for instance bundles, with its environment and
+// with its labels as annotations. The metadata stored in the image are used,
+// or read from its extracted root filesystem rootfs for images built
+// without them.
+func (b *sifBundle) generator(rootfs string, ociConfig *specs.Spec) (*generate.Generator, error) {
+	md, err := metadata.SIFMetadata(b.image)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		if md, _, err = metadata.Read(rootfs); err != nil {
+			return nil, fmt.Errorf("while reading metadata of %s: %s", b.image, err)
+		}
+	}
+
+	imgConfig := imgspecv1.ImageConfig{
+		Cmd: processArgs(rootfs, md, b.opts.Instance),
+	}
+	env := []string{"PATH=" + defaultPath}
+	data, err := image.ReadSandboxFile(rootfs, image.MetadataPath(dockerEnvironment, ""))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	env = parseEnvironment(string(data), env)
+	imgConfig.Env = parseEnvironment(md.Environment, env)
+
+	g, err := ocibundle.Generator(imgConfig, ociConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := b.labels(rootfs)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range labels {
+		if _, ok := g.Config.Annotations[k]; !ok {
+			g.AddAnnotation(k, v)
+		}
+	}
+	return g, nil
+}
+
+// processArgs returns the arguments running the image like singularity run:
+// the run action sourcing the environment scripts before executing the
+// runscript, or the runscript or a shell for images without actions. The
+// start action or the startscript are run first for instances.
+func processArgs(rootfs string, md *metadata.Metadata, instance bool) []string {
+	if instance {
+		for _, name := range []string{"actions/start", "startscript"} {
+			start := image.MetadataPath(name, "")
+			if _, err := os.Stat(filepath.Join(rootfs, start)); err == nil {
+				return []string{start}
+			}
+		}
+	}
+	run := image.MetadataPath("actions/run", "")
+	if _, err := os.Stat(filepath.Join(rootfs, run)); err == nil {
+		return []string{run}
+	}
+	if md.Runscript != "" {
+		return []string{image.MetadataPath("runscript", "")}
+	}
+	return []string{"/bin/sh"}
+}
+
+// labels returns the labels stored in the image, or read from the
+// labels.json file of its extracted root filesystem rootfs
+func (b *sifBundle) labels(rootfs string) (map[string]string, error) {
+	labels, found, err := metadata.LabelPartition(b.image)
+	if err != nil || found {
+		return labels, err
+	}
+
+	data, err := image.ReadSandboxFile(rootfs, image.MetadataPath("labels.json", ""))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("while decoding labels of %s: %s", b.image, err)
+	}
+	return labels, nil
+}
@@ -0,0 +1,571 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-tools/generate"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/build/metadata"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+const testImage = "../../../internal/pkg/syecl/testdata/container1.sif"
+
+// writeRootfs writes the metadata files of an image in rootfs
+func writeRootfs(t *testing.T, rootfs string) {
+	files := map[string]string{
+		".singularity.d/actions/run":                  "#!/bin/sh\n",
+		".singularity.d/runscript":                    "#!/bin/sh\nexec cowsay \"$@\"\n",
+		".singularity.d/env/10-docker2singularity.sh": "#!/bin/sh\nexport PATH=\"/usr/games:/usr/bin\"\n",
+		".singularity.d/env/90-environment.sh":        "#!/bin/sh\nexport LC_ALL=C\n",
+		".singularity.d/labels.json":                  `{"maintainer": "site"}`,
+	}
+	for path, content := range files {
+		path = filepath.Join(rootfs, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory of %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(rootfs)
+	writeRootfs(t, rootfs)
+
+	b, err := FromSif(testImage, filepath.Dir(rootfs), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sb := b.(*sifBundle)
+
+	tests := []struct {
+		name        string
+		ociConfig   *specs.Spec
+		args        []string
+		env         []string
+		annotations map[string]string
+	}{
+		{
+			name:        "default",
+			args:        []string{"/.singularity.d/actions/run"},
+			env:         []string{"PATH=/usr/games:/usr/bin", "LC_ALL=C"},
+			annotations: map[string]string{"maintainer": "site"},
+		},
+		{
+			name: "config",
+			ociConfig: &specs.Spec{
+				Process: &specs.Process{
+					Args: []string{"/bin/true"},
+					Env:  []string{"LC_ALL=en_US.UTF-8"},
+					Cwd:  "/",
+				},
+				Annotations: map[string]string{"maintainer": "me"},
+			},
+			args:        []string{"/bin/true"},
+			env:         []string{"LC_ALL=en_US.UTF-8", "PATH=/usr/games:/usr/bin"},
+			annotations: map[string]string{"maintainer": "me"},
+		},
+	}
+	for _, tt := range tests {
+		g, err := sb.generator(rootfs, tt.ociConfig)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(g.Config.Process.Args, tt.args) {
+			t.Errorf("%s: unexpected args %v, expected %v", tt.name, g.Config.Process.Args, tt.args)
+		}
+		if !reflect.DeepEqual(g.Config.Process.Env, tt.env) {
+			t.Errorf("%s: unexpected env %v, expected %v", tt.name, g.Config.Process.Env, tt.env)
+		}
+		if !reflect.DeepEqual(g.Config.Annotations, tt.annotations) {
+			t.Errorf("%s: unexpected annotations %v, expected %v", tt.name, g.Config.Annotations, tt.annotations)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := FromSif(testImage, dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Update(nil); err == nil {
+		t.Errorf("unexpected success updating a bundle not created")
+	}
+
+	// the root filesystem of a created bundle is left untouched
+	rootfs := filepath.Join(dir, ocibundle.RootFs)
+	writeRootfs(t, rootfs)
+	before, err := os.Stat(filepath.Join(rootfs, ".singularity.d", "runscript"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ociConfig := range []*specs.Spec{
+		nil,
+		{
+			Process: &specs.Process{Args: []string{"/bin/true"}, Env: []string{"LC_ALL=C.UTF-8"}},
+			Mounts:  []specs.Mount{{Destination: "/data", Type: "bind", Source: "/srv/data", Options: []string{"rbind", "ro"}}},
+		},
+	} {
+		if err := b.Update(ociConfig); err != nil {
+			t.Fatalf("unexpected error updating bundle: %v", err)
+		}
+		g, err := generate.NewFromFile(filepath.Join(dir, ocibundle.Config))
+		if err != nil {
+			t.Fatalf("failed to read runtime configuration: %v", err)
+		}
+		if ociConfig == nil {
+			if args := g.Config.Process.Args; !reflect.DeepEqual(args, []string{"/.singularity.d/actions/run"}) {
+				t.Errorf("unexpected default arguments %v", args)
+			}
+			continue
+		}
+		if args := g.Config.Process.Args; !reflect.DeepEqual(args, ociConfig.Process.Args) {
+			t.Errorf("unexpected arguments %v after update, expected %v", args, ociConfig.Process.Args)
+		}
+		if env := g.Config.Process.Env; len(env) == 0 || env[0] != "LC_ALL=C.UTF-8" {
+			t.Errorf("unexpected environment %v after update", env)
+		}
+		if mounts := g.Config.Mounts; !reflect.DeepEqual(mounts, ociConfig.Mounts) {
+			t.Errorf("unexpected mounts %v after update, expected %v", mounts, ociConfig.Mounts)
+		}
+	}
+
+	after, err := os.Stat(filepath.Join(rootfs, ".singularity.d", "runscript"))
+	if err != nil || !os.SameFile(before, after) || !before.ModTime().Equal(after.ModTime()) {
+		t.Errorf("root filesystem modified by update: %v", err)
+	}
+}
+
+func TestProcessArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		md       metadata.Metadata
+		instance bool
+		args     []string
+	}{
+		{"run action", []string{"actions/run", "actions/start"}, metadata.Metadata{}, false, []string{"/.singularity.d/actions/run"}},
+		{"start action", []string{"actions/run", "actions/start"}, metadata.Metadata{}, true, []string{"/.singularity.d/actions/start"}},
+		{"startscript", []string{"startscript"}, metadata.Metadata{}, true, []string{"/.singularity.d/startscript"}},
+		{"runscript", nil, metadata.Metadata{Scripts: metadata.Scripts{Runscript: "#!/bin/sh\n"}}, false, []string{"/.singularity.d/runscript"}},
+		{"instance without startscript", nil, metadata.Metadata{Scripts: metadata.Scripts{Runscript: "#!/bin/sh\n"}}, true, []string{"/.singularity.d/runscript"}},
+		{"shell", nil, metadata.Metadata{}, false, []string{"/bin/sh"}},
+	}
+	for _, tt := range tests {
+		rootfs, err := ioutil.TempDir("", "bundle-")
+		if err != nil {
+			t.Fatalf("failed to create temporary directory: %v", err)
+		}
+		for _, f := range tt.files {
+			path := filepath.Join(rootfs, ".singularity.d", f)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create directory of %s: %v", path, err)
+			}
+			if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+				t.Fatalf("failed to write %s: %v", path, err)
+			}
+		}
+		if args := processArgs(rootfs, &tt.md, tt.instance); !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: unexpected args %v, expected %v", tt.name, args, tt.args)
+		}
+		os.RemoveAll(rootfs)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := FromSif(testImage, filepath.Join(dir, "bundle"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ociConfig := &specs.Spec{Process: &specs.Process{Args: []string{"/bin/true"}}}
+	if err := b.Create(context.Background(), ociConfig); err != nil {
+		t.Skipf("could not create bundle: %v", err)
+	}
+
+	rootfs := filepath.Join(b.Path(), ocibundle.RootFs)
+	var st, parent syscall.Stat_t
+	if err := syscall.Stat(rootfs, &st); err != nil {
+		t.Errorf("failed to stat root filesystem: %v", err)
+	} else if err := syscall.Stat(b.Path(), &parent); err != nil || st.Dev == parent.Dev {
+		t.Errorf("root filesystem is not mounted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(b.Path(), ocibundle.Config)); err != nil {
+		t.Errorf("runtime configuration not written: %v", err)
+	}
+
+	if err := b.Delete(false); err != nil {
+		t.Fatalf("unexpected error deleting bundle: %v", err)
+	}
+	if _, err := os.Stat(b.Path()); !os.IsNotExist(err) {
+		t.Errorf("bundle directory not removed: %v", err)
+	}
+}
+
+// createExt3SIF creates a SIF image at path whose primary partition is an
+// ext3 filesystem holding the metadata files written by writeRootfs
+func createExt3SIF(t *testing.T, path string) {
+	mkfs, err := exec.LookPath("mkfs.ext3")
+	if err != nil {
+		t.Skip("mkfs.ext3 not found")
+	}
+	content := path + ".rootfs"
+	writeRootfs(t, content)
+	defer os.RemoveAll(content)
+
+	part := path + ".ext3"
+	if out, err := exec.Command(mkfs, "-q", "-d", content, part, "8M").CombinedOutput(); err != nil {
+		t.Skipf("failed to create ext3 filesystem: %v: %s", err, out)
+	}
+	defer os.Remove(part)
+
+	fp, err := os.Open(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	fi, err := fp.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Size:     fi.Size(),
+		Fname:    part,
+		Fp:       fp,
+	}
+	if err := input.SetPartExtra(sif.FsExt3, sif.PartPrimSys, sif.HdrArchAMD64); err != nil {
+		t.Fatal(err)
+	}
+	fimg, err := sif.CreateContainer(sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{input},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fimg.UnloadContainer()
+}
+
+func TestCreateWritable(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+
+	// squashfs partitions can't be mounted writable
+	b, err := FromSif(testImage, filepath.Join(dir, "squashfs"), &Options{Writable: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "only ext3") {
+		b.Delete(false)
+		t.Errorf("unexpected error creating writable bundle of a squashfs image: %v", err)
+	}
+
+	// changes of a writable bundle are written to the image, and only
+	// found in a read-only bundle once it is deleted
+	overlay := filepath.Join(dir, "overlay")
+	if err := os.Mkdir(overlay, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, opts := range []*Options{{Writable: true, Overlay: overlay}, nil} {
+		b, err := FromSif(image, filepath.Join(dir, "bundle"), opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := b.Create(context.Background(), nil); err != nil {
+			t.Fatalf("unexpected error creating bundle: %v", err)
+		}
+		file := filepath.Join(b.Path(), ocibundle.RootFs, "file")
+		if i == 0 {
+			err = ioutil.WriteFile(file, []byte("changed"), 0644)
+			if _, serr := os.Stat(filepath.Join(overlay, "upper")); !os.IsNotExist(serr) {
+				t.Errorf("overlay used with a writable partition: %v", serr)
+			}
+		} else {
+			if data, rerr := ioutil.ReadFile(file); rerr != nil || string(data) != "changed" {
+				t.Errorf("change not written to the image: %q: %v", data, rerr)
+			}
+			if werr := ioutil.WriteFile(file, nil, 0644); werr == nil {
+				t.Errorf("read-only bundle is writable")
+			}
+		}
+		if derr := b.Delete(false); derr != nil {
+			t.Fatalf("unexpected error deleting bundle: %v", derr)
+		}
+		if err != nil {
+			t.Fatalf("failed to write file in writable bundle: %v", err)
+		}
+	}
+}
+
+func TestCreateExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		opts *Options
+		path string
+		err  string
+	}{
+		{"writable", &Options{Extract: true, Writable: true}, "", "can't be extracted"},
+		{"no unsquashfs", &Options{Extract: true}, dir, "while extracting"},
+	}
+	for _, tt := range tests {
+		path := os.Getenv("PATH")
+		if tt.path != "" {
+			os.Setenv("PATH", tt.path)
+		}
+		b, err := FromSif(testImage, filepath.Join(dir, "bundle"), tt.opts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		err = b.Create(context.Background(), nil)
+		os.Setenv("PATH", path)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: unexpected error %v, expected %q", tt.name, err, tt.err)
+		}
+		if _, err := os.Stat(b.Path()); !os.IsNotExist(err) {
+			t.Errorf("%s: bundle not removed after failure: %v", tt.name, err)
+		}
+	}
+}
+
+func TestCreateBusy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+
+	b, err := FromSif(image, filepath.Join(dir, "bundle"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// another process creating or deleting the bundle holds its lock
+	l, err := ocibundle.Lock(b.Path())
+	if err != nil {
+		t.Fatalf("unexpected error taking lock: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != ocibundle.ErrBundleBusy {
+		t.Errorf("unexpected error creating a busy bundle: %v", err)
+	}
+	if _, err := os.Stat(b.Path()); !os.IsNotExist(err) {
+		t.Errorf("busy bundle was created: %v", err)
+	}
+	if err := b.Delete(false); err != ocibundle.ErrBundleBusy {
+		t.Errorf("unexpected error deleting a busy bundle: %v", err)
+	}
+	l.Unlock()
+
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+	// a retried creation keeps the bundle
+	if err := b.Create(context.Background(), nil); err == nil {
+		t.Errorf("unexpected success creating a created bundle")
+	}
+	if err := b.Update(nil); err != nil {
+		t.Errorf("unexpected error updating bundle created again: %v", err)
+	}
+}
+
+func TestCreateEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var events []ocibundle.Event
+	handler := ocibundle.EventHandlerFunc(func(e ocibundle.Event) {
+		events = append(events, e)
+	})
+
+	// images which can't be loaded are reported with their error
+	invalid := filepath.Join(dir, "invalid.sif")
+	if err := ioutil.WriteFile(invalid, []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := FromSif(invalid, filepath.Join(dir, "bundle"), &Options{Events: handler})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err == nil {
+		t.Fatalf("unexpected success creating bundle of an invalid image")
+	}
+	if len(events) != 1 || events[0].Phase != ocibundle.PhaseLoadImage || events[0].Err == nil || events[0].Bundle != b.Path() {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+	overlay := filepath.Join(dir, "overlay")
+	if err := os.Mkdir(overlay, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	events = nil
+	b, err = FromSif(image, filepath.Join(dir, "bundle"), &Options{Overlay: overlay, Events: handler})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+
+	phases := []ocibundle.Phase{
+		ocibundle.PhaseLoadImage,
+		ocibundle.PhaseLoopAttach,
+		ocibundle.PhaseMount,
+		ocibundle.PhaseOverlayCreate,
+	}
+	if len(events) != len(phases) {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	for i, e := range events {
+		if e.Phase != phases[i] || e.Err != nil || e.Duration <= 0 || e.Bundle != b.Path() {
+			t.Errorf("unexpected event %+v, expected phase %s", e, phases[i])
+		}
+	}
+	if !strings.HasPrefix(events[1].Detail, "/dev/loop") || events[3].Detail != overlay {
+		t.Errorf("unexpected event details %+v", events)
+	}
+}
+
+func TestCreateMountOptions(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+	overlay := filepath.Join(dir, "overlay")
+	if err := os.Mkdir(overlay, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := FromSif(image, filepath.Join(dir, "bundle"), &Options{
+		Overlay:        overlay,
+		RootfsOptions:  []string{"noatime", "commit=30"},
+		OverlayOptions: []string{"nodiratime"},
+		Propagation:    "shared",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+
+	// the image and the overlay stacked on it are both mounted on rootfs
+	data, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootfs := filepath.Join(b.Path(), ocibundle.RootFs)
+	var mounts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 4 && fields[4] == rootfs {
+			mounts = append(mounts, line)
+		}
+	}
+	if len(mounts) != 2 {
+		t.Fatalf("unexpected mounts on root filesystem: %q", mounts)
+	}
+	if !strings.Contains(mounts[0], "ro,nosuid,nodev,noatime") || !strings.Contains(mounts[0], "commit=30") {
+		t.Errorf("image not mounted with the root filesystem options: %s", mounts[0])
+	}
+	if !strings.Contains(mounts[1], "nodiratime") || !strings.Contains(mounts[1], " shared:") {
+		t.Errorf("overlay not mounted with its options and propagation: %s", mounts[1])
+	}
+
+	// propagation is only set on mounted root filesystems
+	b, err = FromSif(image, filepath.Join(dir, "other"), &Options{Propagation: "invalid"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "unknown propagation") {
+		t.Errorf("unexpected error with an invalid propagation: %v", err)
+	}
+	if _, err := os.Stat(b.Path()); !os.IsNotExist(err) {
+		t.Errorf("bundle not removed after failure: %v", err)
+	}
+}
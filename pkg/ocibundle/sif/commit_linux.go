@@ -0,0 +1,57 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/overlay"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+// Commit moves the changes of the overlay of the bundle at bundlePath to an
+// EXT3 overlay partition appended to its SIF image, like the partitions of
+// singularity overlay create, so they are found by singularity and by the
+// bundles created from the image afterwards. The overlay partition is
+// sized for the changes with as much room left if size is 0. The image
+// must not have an overlay partition already, and no container should run
+// in the bundle while its changes are committed.
+func Commit(bundlePath string, size int64) error {
+	l, err := ocibundle.Lock(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	state, err := ReadState(bundlePath)
+	if err != nil {
+		return err
+	}
+	if state.Writable {
+		return fmt.Errorf("changes of bundle %s are written to %s", bundlePath, state.Image)
+	} else if state.Overlay == "" {
+		return fmt.Errorf("bundle %s has no overlay", bundlePath)
+	}
+	layer, err := ocibundle.OverlayLayer(bundlePath, state.Overlay)
+	if err != nil {
+		return err
+	}
+
+	tmpdir, err := ioutil.TempDir(filepath.Dir(state.Image), ".overlay-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	img := filepath.Join(tmpdir, "overlay.img")
+	if err := overlay.CreateFrom(img, size, layer); err != nil {
+		return err
+	}
+	return overlay.AddImageToSIF(state.Image, img)
+}
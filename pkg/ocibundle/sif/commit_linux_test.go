@@ -0,0 +1,105 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+// overlayPartition returns the offset of the overlay partition of the SIF
+// image at path
+func overlayPartition(t *testing.T, path string) int64 {
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	for _, desc := range fimg.DescrArr {
+		if !desc.Used || desc.Datatype != sif.DataPartition {
+			continue
+		}
+		if ptype, err := desc.GetPartType(); err == nil && ptype == sif.PartOverlay {
+			return desc.Fileoff
+		}
+	}
+	t.Fatalf("overlay partition not found in %s", path)
+	return 0
+}
+
+func TestCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := Commit(filepath.Join(dir, "missing"), 0); err == nil {
+		t.Errorf("unexpected success committing a bundle which isn't created")
+	}
+
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+	debugfs, err := exec.LookPath("debugfs")
+	if err != nil {
+		t.Skip("debugfs not found")
+	}
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+
+	b, err := FromSif(image, filepath.Join(dir, "nooverlay"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	if err := Commit(b.Path(), 0); err == nil {
+		t.Errorf("unexpected success committing a bundle without overlay")
+	}
+	if err := b.Delete(false); err != nil {
+		t.Fatalf("unexpected error deleting bundle: %v", err)
+	}
+
+	overlay := filepath.Join(dir, "overlay")
+	if err := os.Mkdir(overlay, 0755); err != nil {
+		t.Fatal(err)
+	}
+	b, err = FromSif(image, filepath.Join(dir, "bundle"), &Options{Overlay: overlay})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+	if err := ioutil.WriteFile(filepath.Join(b.Path(), ocibundle.RootFs, "changed"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to write file in overlay: %v", err)
+	}
+
+	if err := Commit(b.Path(), 0); err != nil {
+		t.Fatalf("unexpected error committing bundle: %v", err)
+	}
+	if err := Commit(b.Path(), 0); err == nil {
+		t.Errorf("unexpected success committing to an image with an overlay partition")
+	}
+
+	path := fmt.Sprintf("%s?offset=%d", image, overlayPartition(t, image))
+	out, err := exec.Command(debugfs, "-R", "cat /upper/changed", path).Output()
+	if err != nil || string(out) != "changed" {
+		t.Errorf("unexpected content of committed file: %q (%v)", out, err)
+	}
+}
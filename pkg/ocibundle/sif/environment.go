@@ -0,0 +1,124 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"regexp"
+	"strings"
+)
+
+var assignment = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// parseEnvironment applies the variable assignments of the environment
+// script to env, a list of KEY=value pairs. Only plain assignments with
+// quoting and variable expansion are evaluated, other lines are skipped as
+// the run action still sources the script when the container starts.
+func parseEnvironment(script string, env []string) []string {
+	vars := make(map[string]string)
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			vars[kv[0]] = kv[1]
+		}
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		m := assignment.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		value, ok := shellValue(m[2], vars)
+		if !ok {
+			continue
+		}
+		if _, set := vars[m[1]]; set {
+			for i, e := range env {
+				if strings.HasPrefix(e, m[1]+"=") {
+					env[i] = m[1] + "=" + value
+				}
+			}
+		} else {
+			env = append(env, m[1]+"="+value)
+		}
+		vars[m[1]] = value
+	}
+	return env
+}
+
+// shellValue returns the value of the shell word s with variables expanded
+// from vars, ok is false if s uses any other shell feature
+func shellValue(s string, vars map[string]string) (value string, ok bool) {
+	var b strings.Builder
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				b.WriteByte(c)
+			}
+		case c == '\\':
+			i++
+			if i == len(s) {
+				return "", false
+			}
+			if quote == '"' && !strings.ContainsRune("\\\"`$", rune(s[i])) {
+				b.WriteByte(c)
+			}
+			b.WriteByte(s[i])
+		case c == '$':
+			name, n := expansion(s[i+1:])
+			if n == 0 {
+				return "", false
+			}
+			b.WriteString(vars[name])
+			i += n
+		case c == '"':
+			if quote == '"' {
+				quote = 0
+			} else {
+				quote = c
+			}
+		case quote == '"':
+			if c == '`' {
+				return "", false
+			}
+			b.WriteByte(c)
+		case c == '\'':
+			quote = c
+		case c == ' ' || c == '\t':
+			// only a comment may follow the value
+			if rest := strings.TrimSpace(s[i:]); rest != "" && rest[0] != '#' {
+				return "", false
+			}
+			return b.String(), true
+		case strings.IndexByte("`;&|<>()", c) >= 0:
+			return "", false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), quote == 0
+}
+
+// expansion returns the name of the variable expanded by s, which follows
+// a $, and the number of bytes of s it spans, 0 if it is not a plain $NAME or
+// ${NAME} expansion
+func expansion(s string) (name string, n int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 || varName.FindString(s[1:end]) != s[1:end] || end == 1 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+	name = varName.FindString(s)
+	return name, len(name)
+}
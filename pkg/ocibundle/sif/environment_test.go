@@ -0,0 +1,39 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvironment(t *testing.T) {
+	script := `#!/bin/sh
+# comment
+export LANG=C
+FOO="a \"quoted\" \$value"
+export PATH="/opt/bin:$PATH"
+export BAR='$FOO' # literal
+export BAZ=${LANG}.UTF-8
+if [ -z "$DEBUG" ]; then
+    DEBUG=0
+fi
+export DATE=$(date)
+export LANG=en_US
+`
+	env := parseEnvironment(script, []string{"PATH=/bin"})
+	expected := []string{
+		"PATH=/opt/bin:/bin",
+		"LANG=en_US",
+		`FOO=a "quoted" $value`,
+		"BAR=$FOO",
+		"BAZ=C.UTF-8",
+		"DEBUG=0",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("unexpected environment %q, expected %q", env, expected)
+	}
+}
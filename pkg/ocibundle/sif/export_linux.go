@@ -0,0 +1,51 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/build/ocilayout"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+// ExportOCI writes the root filesystem of the bundle at bundlePath as an
+// OCI image layout in the directory dir, so images which only exist as SIF
+// can be given to OCI tools without being built again. The root filesystem
+// is written as a single layer, with the changes of its overlay if any, and
+// the run action of the image is its entrypoint like for images built with
+// the oci target. The image is tagged latest, dir must not exist.
+func ExportOCI(ctx context.Context, bundlePath, dir string) error {
+	if err := ocibundle.CheckCreated(bundlePath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// the bundle is kept mounted while exported
+	l, err := ocibundle.Lock(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	createdBy := "export of bundle " + bundlePath
+	if state, err := ReadState(bundlePath); err == nil {
+		createdBy = "export of " + state.Image
+	}
+	rootfs := filepath.Join(bundlePath, ocibundle.RootFs)
+	if err := ocilayout.Write(ctx, rootfs, dir, createdBy); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("while exporting %s: %s", bundlePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+)
+
+// readBlob decodes the JSON blob of desc from the layout at dir in v
+func readBlob(t *testing.T, dir string, desc imagespec.Descriptor, v interface{}) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "blobs", "sha256", desc.Digest.Hex()))
+	if err != nil {
+		t.Fatalf("failed to read blob %s: %v", desc.Digest, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to decode blob %s: %v", desc.Digest, err)
+	}
+}
+
+func TestExportOCI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ExportOCI(context.Background(), filepath.Join(dir, "missing"), filepath.Join(dir, "oci")); err == nil {
+		t.Errorf("unexpected success exporting a bundle which isn't created")
+	}
+
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+	overlay := filepath.Join(dir, "overlay")
+	if err := os.Mkdir(overlay, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := FromSif(image, filepath.Join(dir, "bundle"), &Options{Overlay: overlay})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+	// changes in the overlay are exported with the image
+	if err := ioutil.WriteFile(filepath.Join(b.Path(), ocibundle.RootFs, "changed"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("failed to write file in overlay: %v", err)
+	}
+
+	layout := filepath.Join(dir, "oci")
+	if err := ExportOCI(context.Background(), b.Path(), layout); err != nil {
+		t.Fatalf("unexpected error exporting bundle: %v", err)
+	}
+	if err := ExportOCI(context.Background(), b.Path(), layout); err == nil {
+		t.Errorf("unexpected success exporting to an existing directory")
+	}
+
+	var index imagespec.Index
+	data, err := ioutil.ReadFile(filepath.Join(layout, "index.json"))
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil || len(index.Manifests) != 1 {
+		t.Fatalf("unexpected index %s: %v", data, err)
+	}
+	var manifest imagespec.Manifest
+	readBlob(t, layout, index.Manifests[0], &manifest)
+	var config imagespec.Image
+	readBlob(t, layout, manifest.Config, &config)
+	if !reflect.DeepEqual(config.Config.Entrypoint, []string{"/.singularity.d/actions/run"}) {
+		t.Errorf("unexpected entrypoint %v", config.Config.Entrypoint)
+	}
+	if config.Config.Labels["maintainer"] != "site" {
+		t.Errorf("labels of the image not exported: %v", config.Config.Labels)
+	}
+	if len(config.History) != 1 || config.History[0].CreatedBy != "export of "+image {
+		t.Errorf("unexpected history %+v", config.History)
+	}
+
+	if len(manifest.Layers) != 1 {
+		t.Fatalf("unexpected layers %+v", manifest.Layers)
+	}
+	f, err := os.Open(filepath.Join(layout, "blobs", "sha256", manifest.Layers[0].Digest.Hex()))
+	if err != nil {
+		t.Fatalf("failed to open layer: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to decompress layer: %v", err)
+	}
+	files := map[string]bool{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read layer: %v", err)
+		}
+		files[filepath.Clean(hdr.Name)] = true
+	}
+	for _, name := range []string{".singularity.d/runscript", "changed"} {
+		if !files[name] {
+			t.Errorf("%s not found in layer", name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/ocibundle"
+	"github.com/sylabs/singularity/pkg/util/loop"
+)
+
+// StateFile is the file of the bundle recording how it was created
+const StateFile = "state.json"
+
+// State records how a bundle was created from a SIF image, so it can be
+// loaded again by another process
+type State struct {
+	// Image is the absolute path of the SIF image
+	Image string `json:"image"`
+	// Digest is the sha256 digest of the image when the bundle was
+	// created, images mounted writable change afterwards
+	Digest string `json:"digest"`
+	// Instance, Writable and Extract are the options of the bundle
+	Instance bool `json:"instance,omitempty"`
+	Writable bool `json:"writable,omitempty"`
+	Extract  bool `json:"extract,omitempty"`
+	// LoopDevice is the loop device the image is attached to, if it was
+	// mounted by root
+	LoopDevice string `json:"loopDevice,omitempty"`
+	// Overlay is the persistent directory or image of the overlay, if any
+	Overlay string `json:"overlay,omitempty"`
+	// Created is the creation time of the bundle
+	Created time.Time `json:"created"`
+	// Pid and PidStart identify the process which created the bundle,
+	// PidStart is its start time in clock ticks after boot so a reused
+	// pid is not mistaken for it
+	Pid      int    `json:"pid"`
+	PidStart uint64 `json:"pidStart"`
+}
+
+// LoadBundle returns the bundle created at bundlePath from a SIF image, as
+// recorded in its state file, so it can be updated or deleted by another
+// process than the one which created it.
+func LoadBundle(bundlePath string) (ocibundle.Bundle, error) {
+	state, err := ReadState(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	return FromSif(state.Image, bundlePath, &Options{
+		Instance: state.Instance,
+		Overlay:  state.Overlay,
+		Writable: state.Writable,
+		Extract:  state.Extract,
+	})
+}
+
+// ReadState returns the state of the bundle created at bundlePath from a
+// SIF image
+func ReadState(bundlePath string) (*State, error) {
+	data, err := ioutil.ReadFile(filepath.Join(bundlePath, StateFile))
+	if err != nil {
+		return nil, fmt.Errorf("while reading state of bundle %s: %s", bundlePath, err)
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("while decoding state of bundle %s: %s", bundlePath, err)
+	}
+	return state, nil
+}
+
+// writeState records the state of the bundle, once its root filesystem is
+// mounted or extracted and before its overlay is mounted on it
+func (b *sifBundle) writeState() error {
+	digest, err := fileDigest(b.image)
+	if err != nil {
+		return err
+	}
+	state := &State{
+		Image:    b.image,
+		Digest:   digest,
+		Instance: b.opts.Instance,
+		Writable: b.opts.Writable,
+		Extract:  b.opts.Extract,
+		Created:  time.Now().UTC(),
+		Pid:      os.Getpid(),
+	}
+	start, err := processStart(state.Pid)
+	if err != nil {
+		return err
+	}
+	state.PidStart = start
+	if source := ocibundle.RootfsSource(b.bundlePath); strings.HasPrefix(source, "/dev/loop") {
+		state.LoopDevice = source
+	}
+	if !b.opts.Writable {
+		state.Overlay = b.opts.Overlay
+	}
+
+	data, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	// the state is replaced atomically like the runtime configuration
+	f, err := ioutil.TempFile(b.bundlePath, StateFile+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("while writing state of bundle %s: %s", b.bundlePath, err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(b.bundlePath, StateFile))
+}
+
+// DetectOrphans returns the bundles created from SIF images in the
+// directory dir whose creating process is gone, as left by processes
+// killed before deleting them. They can be removed with a forced Delete
+// of the bundle returned by LoadBundle. Bundles are meant to be deleted by
+// the process which created them, their runtime may still be running a
+// container in an orphaned bundle.
+func DetectOrphans(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		bundlePath := filepath.Join(dir, entry.Name())
+		state, err := ReadState(bundlePath)
+		if err != nil {
+			// not a bundle created from a SIF image
+			continue
+		}
+		if start, err := processStart(state.Pid); err == nil && start == state.PidStart {
+			continue
+		}
+		orphans = append(orphans, bundlePath)
+	}
+	return orphans, nil
+}
+
+// processStart returns the start time of the process pid, in clock ticks
+// after boot
+func processStart(pid int) (uint64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// the command name in parentheses may contain spaces, the start time
+	// is the 20th field after it
+	var fields []string
+	if i := strings.LastIndexByte(string(data), ')'); i >= 0 {
+		fields = strings.Fields(string(data[i+1:]))
+	}
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// detachLoopDevice detaches the loop device recorded in the state of the
+// bundle if it is still attached to the image
+func (b *sifBundle) detachLoopDevice() {
+	state, err := ReadState(b.bundlePath)
+	if err != nil || state.LoopDevice == "" {
+		return
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(state.LoopDevice, "/dev/loop"))
+	if err != nil {
+		return
+	}
+	if backing, err := loop.BackingFile(number); err != nil || backing != b.image {
+		return
+	}
+	if err := loop.DetachDevice(number); err != nil {
+		sylog.Warningf("Could not detach %s: %s", state.LoopDevice, err)
+	}
+}
+
+// fileDigest returns the sha256 digest of the file at path
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("while computing digest of %s: %s", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
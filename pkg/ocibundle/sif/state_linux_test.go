@@ -0,0 +1,214 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sif
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/ocibundle"
+	"github.com/sylabs/singularity/pkg/util/loop"
+)
+
+func TestReadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadBundle(dir); err == nil {
+		t.Errorf("unexpected success loading a bundle without state")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, StateFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadState(dir); err == nil {
+		t.Errorf("unexpected success reading an invalid state")
+	}
+
+	state := `{"image": "/images/test.sif", "instance": true, "overlay": "/overlays/test"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, StateFile), []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBundle(dir)
+	if err != nil {
+		t.Fatalf("unexpected error loading bundle: %v", err)
+	}
+	sb := b.(*sifBundle)
+	opts := Options{Instance: true, Overlay: "/overlays/test"}
+	if sb.image != "/images/test.sif" || sb.bundlePath != dir || !reflect.DeepEqual(sb.opts, opts) {
+		t.Errorf("unexpected bundle %+v", sb)
+	}
+}
+
+func TestWriteState(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+
+	b, err := FromSif(image, filepath.Join(dir, "bundle"), &Options{Instance: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	defer b.Delete(false)
+
+	state, err := ReadState(b.Path())
+	if err != nil {
+		t.Fatalf("unexpected error reading state: %v", err)
+	}
+	digest, err := fileDigest(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Image != image || state.Digest != digest || !state.Instance || state.Created.IsZero() {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if !strings.HasPrefix(state.LoopDevice, "/dev/loop") {
+		t.Errorf("loop device not recorded: %q", state.LoopDevice)
+	}
+
+	// another process deletes the bundle from its state
+	loaded, err := LoadBundle(b.Path())
+	if err != nil {
+		t.Fatalf("unexpected error loading bundle: %v", err)
+	}
+	if err := loaded.Update(nil); err != nil {
+		t.Errorf("unexpected error updating loaded bundle: %v", err)
+	}
+	if err := loaded.Delete(false); err != nil {
+		t.Fatalf("unexpected error deleting loaded bundle: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(b.Path(), ocibundle.RootFs)); !os.IsNotExist(err) {
+		t.Errorf("bundle not deleted: %v", err)
+	}
+}
+
+func TestDetectOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundles-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	start, err := processStart(os.Getpid())
+	if err != nil {
+		t.Fatalf("unexpected error reading start time: %v", err)
+	}
+	// a process which is gone once waited for
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	dead := cmd.ProcessState.Pid()
+
+	states := map[string]string{
+		"running":  fmt.Sprintf(`{"pid": %d, "pidStart": %d}`, os.Getpid(), start),
+		"reused":   fmt.Sprintf(`{"pid": %d, "pidStart": %d}`, os.Getpid(), start+1),
+		"dead":     fmt.Sprintf(`{"pid": %d}`, dead),
+		"invalid":  "{",
+		"no-state": "",
+	}
+	for name, state := range states {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if state == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name, StateFile), []byte(state), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orphans, err := DetectOrphans(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "dead"), filepath.Join(dir, "reused")}
+	if !reflect.DeepEqual(orphans, expected) {
+		t.Errorf("unexpected orphans %v, expected %v", orphans, expected)
+	}
+	if _, err := DetectOrphans(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("unexpected success with a missing directory")
+	}
+}
+
+func TestDeleteForce(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting the image with a loop device requires privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "ext3.sif")
+	createExt3SIF(t, image)
+
+	b, err := FromSif(image, filepath.Join(dir, "bundle"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Create(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error creating bundle: %v", err)
+	}
+	state, err := ReadState(b.Path())
+	if err != nil {
+		b.Delete(true)
+		t.Fatalf("unexpected error reading state: %v", err)
+	}
+
+	// a file left open keeps the root filesystem busy
+	busy, err := os.Open(filepath.Join(b.Path(), ocibundle.RootFs, ".singularity.d/runscript"))
+	if err != nil {
+		b.Delete(true)
+		t.Fatalf("failed to open file of the image: %v", err)
+	}
+	defer busy.Close()
+
+	if err := b.Delete(false); err == nil {
+		t.Fatalf("unexpected success deleting a busy bundle")
+	}
+	if _, err := os.Stat(filepath.Join(b.Path(), StateFile)); err != nil {
+		t.Errorf("busy bundle was removed: %v", err)
+	}
+	if err := b.Delete(true); err != nil {
+		t.Fatalf("unexpected error forcing deletion: %v", err)
+	}
+	if _, err := os.Stat(b.Path()); !os.IsNotExist(err) {
+		t.Errorf("bundle not deleted: %v", err)
+	}
+
+	// the loop device is released once the last file is closed
+	busy.Close()
+	number, _ := strconv.Atoi(strings.TrimPrefix(state.LoopDevice, "/dev/loop"))
+	if backing, err := loop.BackingFile(number); err != nil || backing == image {
+		t.Errorf("loop device %s still attached to the image: %v", state.LoopDevice, err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -145,6 +146,64 @@ func Sign(cpath, url string, id uint32, isGroup bool, keyIdx int, authToken stri
 		return fmt.Errorf("could not decrypt private key, wrong password?")
 	}
 
+	return SignWithEntity(cpath, id, isGroup, entity)
+}
+
+// PrivKey returns the decrypted private key of the local keyring whose
+// fingerprint ends with fingerprint, which may be a full fingerprint or a key
+// ID. If fingerprint is empty the keyring must hold a single key. Unlike Sign
+// it never generates keys nor prompts for a key selection, only for the key
+// passphrase.
+func PrivKey(fingerprint string) (*openpgp.Entity, error) {
+	elist, err := sypgp.LoadPrivKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("could not load private keyring: %s", err)
+	}
+
+	entity, err := findPrivKey(elist, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = sypgp.DecryptKey(entity); err != nil {
+		return nil, fmt.Errorf("could not decrypt private key, wrong password?")
+	}
+	return entity, nil
+}
+
+// findPrivKey returns the key of elist whose fingerprint ends with
+// fingerprint, or the only key of elist if fingerprint is empty
+func findPrivKey(elist openpgp.EntityList, fingerprint string) (*openpgp.Entity, error) {
+	if len(elist) == 0 {
+		return nil, fmt.Errorf("no private key found in %s", sypgp.SecretPath())
+	}
+	if fingerprint == "" {
+		if len(elist) > 1 {
+			return nil, fmt.Errorf("%d private keys found, select one with its fingerprint", len(elist))
+		}
+		return elist[0], nil
+	}
+
+	fingerprint = strings.ToUpper(strings.TrimPrefix(fingerprint, "0x"))
+	var entity *openpgp.Entity
+	for _, e := range elist {
+		if !strings.HasSuffix(fmt.Sprintf("%X", e.PrimaryKey.Fingerprint), fingerprint) {
+			continue
+		}
+		if entity != nil {
+			return nil, fmt.Errorf("several private keys match fingerprint %s", fingerprint)
+		}
+		entity = e
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("no private key matches fingerprint %s", fingerprint)
+	}
+	return entity, nil
+}
+
+// SignWithEntity adds a signature block created with the private key of
+// entity to the container at cpath
+func SignWithEntity(cpath string, id uint32, isGroup bool, entity *openpgp.Entity) error {
 	// load the container
 	fimg, err := sif.LoadContainer(cpath, false)
 	if err != nil {
@@ -202,7 +261,8 @@ func getSigsPrimPart(fimg *sif.FileImage) (sigs []*sif.Descriptor, descr []*sif.
 	}
 
 	sigs, _, err = fimg.GetFromLinkedDescr(descr[0].ID)
-	if err != nil {
+	sigs = signatureDescrs(sigs)
+	if err != nil || len(sigs) == 0 {
 		return nil, nil, fmt.Errorf("no signatures found for system partition")
 	}
 
@@ -219,13 +279,26 @@ func getSigsDescr(fimg *sif.FileImage, id uint32) (sigs []*sif.Descriptor, descr
 	}
 
 	sigs, _, err = fimg.GetFromLinkedDescr(id)
-	if err != nil {
+	sigs = signatureDescrs(sigs)
+	if err != nil || len(sigs) == 0 {
 		return nil, nil, fmt.Errorf("no signatures found for id %v", id)
 	}
 
 	return
 }
 
+// signatureDescrs returns the OpenPGP signature descriptors of descrs,
+// other data objects like sigstore signatures may be linked too
+func signatureDescrs(descrs []*sif.Descriptor) []*sif.Descriptor {
+	var sigs []*sif.Descriptor
+	for _, d := range descrs {
+		if d.Datatype == sif.DataSignature {
+			sigs = append(sigs, d)
+		}
+	}
+	return sigs
+}
+
 // return all signatures for specified group
 func getSigsGroup(fimg *sif.FileImage, id uint32) (sigs []*sif.Descriptor, descr []*sif.Descriptor, err error) {
 	// find descriptors that are part of a signing group
@@ -266,6 +339,19 @@ func getSigsForSelection(fimg *sif.FileImage, id uint32, isGroup bool) (sigs []*
 // for OpenPGP keys in the default local store or looks it up from a key server
 // if access is enabled.
 func Verify(cpath, url string, id uint32, isGroup bool, authToken string) error {
+	// load the public keys available locally from the cache
+	elist, err := sypgp.LoadPubKeyring()
+	if err != nil {
+		return fmt.Errorf("could not load public keyring: %s", err)
+	}
+
+	return verifyWithKeyring(cpath, url, id, isGroup, authToken, elist)
+}
+
+// verifyWithKeyring checks the signature blocks of the container at cpath
+// against the keys of elist, missing keys are fetched from the key server
+// at url unless it's empty
+func verifyWithKeyring(cpath, url string, id uint32, isGroup bool, authToken string, elist openpgp.EntityList) error {
 	fimg, err := sif.LoadContainer(cpath, true)
 	if err != nil {
 		return fmt.Errorf("failed to load SIF container file: %s", err)
@@ -281,12 +367,6 @@ func Verify(cpath, url string, id uint32, isGroup bool, authToken string) error
 	// the selected data object is hashed for comparison against signature block's
 	sifhash := computeHashStr(&fimg, descr)
 
-	// load the public keys available locally from the cache
-	elist, err := sypgp.LoadPubKeyring()
-	if err != nil {
-		return fmt.Errorf("could not load public keyring: %s", err)
-	}
-
 	// compare freshly computed hash with hashes stored in signatures block(s)
 	var authok string
 	for _, v := range signatures {
@@ -313,7 +393,9 @@ func Verify(cpath, url string, id uint32, isGroup bool, authToken string) error
 
 		// try to verify with local OpenPGP store first
 		signer, err := openpgp.CheckDetachedSignature(elist, bytes.NewBuffer(block.Bytes), block.ArmoredSignature.Body)
-		if err != nil {
+		if err != nil && url == "" {
+			return fmt.Errorf("signature verification failed: %s", err)
+		} else if err != nil {
 			// verification with local keyring failed, try to fetch from key server
 			sylog.Infof("key missing, searching key server for KeyID: %s...", fingerprint[24:])
 			netlist, err := sypgp.FetchPubkey(fingerprint, url, authToken)
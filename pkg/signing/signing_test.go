@@ -0,0 +1,59 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestFindPrivKey(t *testing.T) {
+	var elist openpgp.EntityList
+	for _, name := range []string{"first", "second"} {
+		e, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+		if err != nil {
+			t.Fatalf("failed to create entity: %v", err)
+		}
+		elist = append(elist, e)
+	}
+	fp := fmt.Sprintf("%X", elist[1].PrimaryKey.Fingerprint)
+
+	if _, err := findPrivKey(nil, ""); err == nil {
+		t.Errorf("unexpected success with an empty keyring")
+	}
+	if _, err := findPrivKey(elist, ""); err == nil {
+		t.Errorf("unexpected success without fingerprint and several keys")
+	}
+	if e, err := findPrivKey(elist[:1], ""); err != nil || e != elist[0] {
+		t.Errorf("unexpected key without fingerprint and a single key: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		fingerprint string
+	}{
+		{"full fingerprint", fp},
+		{"key ID", fp[len(fp)-16:]},
+		{"lowercase prefixed key ID", "0x" + fmt.Sprintf("%x", elist[1].PrimaryKey.Fingerprint[12:])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := findPrivKey(elist, tt.fingerprint)
+			if err != nil {
+				t.Fatalf("failed to find key: %v", err)
+			}
+			if e != elist[1] {
+				t.Errorf("unexpected key %X", e.PrimaryKey.Fingerprint)
+			}
+		})
+	}
+
+	if _, err := findPrivKey(elist, "0000000000000000"); err == nil {
+		t.Errorf("unexpected success with an unknown fingerprint")
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// SigstoreObject is the name of the generic JSON data objects holding
+// sigstore signatures
+const SigstoreObject = "sigstore-signature"
+
+// sigstoreType is the type of cosign simple signing payloads
+const sigstoreType = "cosign container image signature"
+
+// sigstoreSignature is a cosign-compatible signature stored in a SIF data
+// object. The signature is made over the payload bytes, both are base64
+// encoded like cosign does.
+type sigstoreSignature struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// simpleSigning is the cosign simple signing payload format, the SIF image
+// ID is used as identity and the content digest as manifest digest
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// computeDigest returns the sha256 digest of data object(s) as
+// sha256:<hex> string
+func computeDigest(fimg *sif.FileImage, descr []*sif.Descriptor) string {
+	hash := sha256.New()
+	for _, v := range descr {
+		hash.Write(v.GetData(fimg))
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// loadSigstorePrivateKey reads a PEM encoded PKCS #8, EC or PKCS #1 private
+// key. Keyless (OIDC) signing and encrypted cosign keys are not supported.
+func loadSigstorePrivateKey(path string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type in %s", path)
+		}
+		return signer, nil
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "ENCRYPTED COSIGN PRIVATE KEY", "ENCRYPTED SIGSTORE PRIVATE KEY":
+		return nil, fmt.Errorf("encrypted cosign keys are not supported, use an unencrypted PKCS #8 key")
+	}
+	return nil, fmt.Errorf("unsupported PEM block %s in %s", block.Type, path)
+}
+
+// loadSigstorePublicKey reads a PEM encoded PKIX public key like cosign.pub
+func loadSigstorePublicKey(path string) (crypto.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no PEM encoded public key found in %s", path)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// sigstoreSign signs the sha256 digest of payload like cosign, ECDSA
+// signatures are ASN.1 encoded and RSA signatures use PKCS #1 v1.5
+func sigstoreSign(key crypto.Signer, payload []byte) ([]byte, error) {
+	digest := sha256.Sum256(payload)
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// sigstoreVerify checks signature of payload against pub
+func sigstoreVerify(pub crypto.PublicKey, payload, signature []byte) error {
+	digest := sha256.Sum256(payload)
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return fmt.Errorf("malformed ECDSA signature: %s", err)
+		}
+		if !ecdsa.Verify(pub, digest[:], sig.R, sig.S) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+	}
+	return fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// SignSigstore adds a cosign-compatible signature of the selected data
+// objects to the container at cpath, made with the PEM private key at keyPath
+func SignSigstore(cpath, keyPath string, id uint32, isGroup bool) error {
+	key, err := loadSigstorePrivateKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("could not load private key: %s", err)
+	}
+
+	fimg, err := sif.LoadContainer(cpath, false)
+	if err != nil {
+		return fmt.Errorf("failed to load SIF container file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	descr, err := descrToSign(&fimg, id, isGroup)
+	if err != nil {
+		return fmt.Errorf("signing requires a primary partition: %s", err)
+	}
+
+	var groupid, link uint32
+	if isGroup {
+		groupid = sif.DescrUnusedGroup
+		link = descr[0].Groupid
+	} else {
+		groupid = descr[0].Groupid
+		link = descr[0].ID
+	}
+
+	var s simpleSigning
+	s.Critical.Identity.DockerReference = "sif:" + fimg.Header.ID.String()
+	s.Critical.Image.DockerManifestDigest = computeDigest(&fimg, descr)
+	s.Critical.Type = sigstoreType
+	s.Optional = map[string]interface{}{"sif-link": link}
+
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	signature, err := sigstoreSign(key, payload)
+	if err != nil {
+		return fmt.Errorf("could not sign payload: %s", err)
+	}
+	data, err := json.Marshal(sigstoreSignature{Payload: payload, Signature: signature})
+	if err != nil {
+		return err
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataGenericJSON,
+		Groupid:  groupid,
+		Link:     link,
+		Fname:    SigstoreObject,
+		Data:     data,
+	}
+	input.Size = int64(len(data))
+
+	if err := fimg.AddObject(input); err != nil {
+		return fmt.Errorf("failed adding signature to SIF container file: %s", err)
+	}
+	return nil
+}
+
+// VerifySigstore checks the cosign-compatible signatures of the selected
+// data objects of the container at cpath against the PEM public key at
+// keyPath
+func VerifySigstore(cpath, keyPath string, id uint32, isGroup bool) error {
+	pub, err := loadSigstorePublicKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("could not load public key: %s", err)
+	}
+
+	fimg, err := sif.LoadContainer(cpath, true)
+	if err != nil {
+		return fmt.Errorf("failed to load SIF container file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	descr, err := descrToSign(&fimg, id, isGroup)
+	if err != nil {
+		return fmt.Errorf("error while searching for signed data: %s", err)
+	}
+	link := descr[0].ID
+	if isGroup {
+		link = descr[0].Groupid
+	}
+
+	var signatures []*sif.Descriptor
+	for i, d := range fimg.DescrArr {
+		if d.Used && d.Datatype == sif.DataGenericJSON && d.GetName() == SigstoreObject && d.Link == link {
+			signatures = append(signatures, &fimg.DescrArr[i])
+		}
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("no sigstore signatures found")
+	}
+
+	// like cosign, at least one signature must have been made with the key
+	digest := computeDigest(&fimg, descr)
+	verified := 0
+	for _, v := range signatures {
+		var sig sigstoreSignature
+		if err := json.Unmarshal(v.GetData(&fimg), &sig); err != nil {
+			return fmt.Errorf("failed to parse sigstore signature: %s", err)
+		}
+		if err := sigstoreVerify(pub, sig.Payload, sig.Signature); err != nil {
+			sylog.Debugf("Ignoring signature in descriptor %d: %s", v.ID, err)
+			continue
+		}
+
+		var s simpleSigning
+		if err := json.Unmarshal(sig.Payload, &s); err != nil {
+			return fmt.Errorf("failed to parse signature payload: %s", err)
+		}
+		if s.Critical.Type != sigstoreType {
+			return fmt.Errorf("unexpected signature payload type %q", s.Critical.Type)
+		}
+		if s.Critical.Image.DockerManifestDigest != digest {
+			return fmt.Errorf("digests differ, data may be corrupted")
+		}
+		verified++
+	}
+	if verified == 0 {
+		return fmt.Errorf("signature verification failed: no signature made with key %s", keyPath)
+	}
+
+	fmt.Printf("Data integrity checked, authentic and signed by:\n")
+	fmt.Printf("\tkey %s (%d sigstore signature(s))\n", keyPath, verified)
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// createSIF creates a SIF image at path holding a primary partition
+func createSIF(t *testing.T, path string) {
+	part := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    "rootfs",
+		Data:     []byte("squashfs partition content"),
+	}
+	part.Size = int64(len(part.Data))
+	if err := part.SetPartExtra(sif.FsSquash, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		t.Fatalf("failed to set partition extra data: %s", err)
+	}
+
+	cinfo := sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{part},
+	}
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		t.Fatalf("failed to create SIF image: %s", err)
+	}
+}
+
+// writeKeyPair writes a PEM private key and its PEM public key in dir
+func writeKeyPair(t *testing.T, dir, name string, key interface{}, pub interface{}) (string, string) {
+	privDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %s", err)
+	}
+	pubDer, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %s", err)
+	}
+
+	privPath := filepath.Join(dir, name+".key")
+	pubPath := filepath.Join(dir, name+".pub")
+	if err := ioutil.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDer}), 0600); err != nil {
+		t.Fatalf("failed to write private key: %s", err)
+	}
+	if err := ioutil.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}), 0644); err != nil {
+		t.Fatalf("failed to write public key: %s", err)
+	}
+	return privPath, pubPath
+}
+
+func TestSigstore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigstore-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	ecPriv, ecPub := writeKeyPair(t, dir, "ec", ecKey, &ecKey.PublicKey)
+	rsaPriv, rsaPub := writeKeyPair(t, dir, "rsa", rsaKey, &rsaKey.PublicKey)
+	_, otherPub := writeKeyPair(t, dir, "other", otherKey, &otherKey.PublicKey)
+
+	image := filepath.Join(dir, "image.sif")
+	createSIF(t, image)
+
+	if err := VerifySigstore(image, ecPub, 0, false); err == nil {
+		t.Errorf("unexpected success verifying unsigned image")
+	}
+
+	for _, priv := range []string{ecPriv, rsaPriv} {
+		if err := SignSigstore(image, priv, 0, false); err != nil {
+			t.Fatalf("failed to sign image with %s: %s", priv, err)
+		}
+	}
+	for _, pub := range []string{ecPub, rsaPub} {
+		if err := VerifySigstore(image, pub, 0, false); err != nil {
+			t.Errorf("failed to verify image with %s: %s", pub, err)
+		}
+	}
+	if err := VerifySigstore(image, otherPub, 0, false); err == nil {
+		t.Errorf("unexpected success verifying with another key")
+	}
+
+	// sigstore signatures must not be taken as OpenPGP signatures
+	if entities, err := GetSignEntities(image); err == nil {
+		t.Errorf("unexpected OpenPGP signing entities %v", entities)
+	}
+
+	// corrupt the partition content
+	fimg, err := sif.LoadContainer(image, true)
+	if err != nil {
+		t.Fatalf("failed to load image: %s", err)
+	}
+	part, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		t.Fatalf("failed to get primary partition: %s", err)
+	}
+	offset := part.Fileoff
+	fimg.UnloadContainer()
+
+	f, err := os.OpenFile(image, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open image: %s", err)
+	}
+	f.WriteAt([]byte("corrupted"), offset)
+	f.Close()
+
+	if err := VerifySigstore(image, ecPub, 0, false); err == nil {
+		t.Errorf("unexpected success verifying corrupted image")
+	}
+}
+
+func TestLoadSigstorePrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigstore-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name          string
+		content       []byte
+		expectSuccess bool
+	}{
+		{"not PEM", []byte("not a key"), false},
+		{"encrypted cosign key", pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED COSIGN PRIVATE KEY", Bytes: []byte("{}")}), false},
+		{"public key", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("key")}), false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "key")
+		if err := ioutil.WriteFile(path, tt.content, 0600); err != nil {
+			t.Fatalf("failed to write key: %s", err)
+		}
+		_, err := loadSigstorePrivateKey(path)
+		if tt.expectSuccess && err != nil {
+			t.Errorf("%s: unexpected failure: %s", tt.name, err)
+		} else if !tt.expectSuccess && err == nil {
+			t.Errorf("%s: unexpected success", tt.name)
+		}
+	}
+}
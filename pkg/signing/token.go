@@ -0,0 +1,120 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"crypto"
+	"fmt"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/hwtoken"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// tokenKeyTime is the creation time of the OpenPGP keys wrapping token keys,
+// it is fixed so that their fingerprint is the same at each invocation
+var tokenKeyTime = time.Unix(0, 0)
+
+// tokenEntity returns an OpenPGP entity wrapping the token key selected by
+// u. Its self-signature is not computed as the entity is only used in memory.
+func tokenEntity(u *hwtoken.URI, key crypto.Signer) (*openpgp.Entity, error) {
+	if key.Public() == nil {
+		return nil, fmt.Errorf("unsupported token key type")
+	}
+	priv := packet.NewSignerPrivateKey(tokenKeyTime, key)
+
+	name := u.Object
+	if name == "" {
+		name = fmt.Sprintf("%X", u.ID)
+	}
+	uid := packet.NewUserId(name, "PKCS#11 token "+u.Token, "")
+	if uid == nil {
+		uid = packet.NewUserId("PKCS#11 token key", "", "")
+	}
+
+	isPrimaryID := true
+	entity := &openpgp.Entity{
+		PrimaryKey: &priv.PublicKey,
+		PrivateKey: priv,
+		Identities: make(map[string]*openpgp.Identity),
+	}
+	entity.Identities[uid.Id] = &openpgp.Identity{
+		Name:   uid.Id,
+		UserId: uid,
+		SelfSignature: &packet.Signature{
+			SigType:      packet.SigTypePositiveCert,
+			PubKeyAlgo:   priv.PubKeyAlgo,
+			Hash:         crypto.SHA256,
+			CreationTime: tokenKeyTime,
+			IssuerKeyId:  &priv.KeyId,
+			IsPrimaryId:  &isPrimaryID,
+			FlagsValid:   true,
+			FlagSign:     true,
+		},
+	}
+	return entity, nil
+}
+
+// openToken opens the token key selected by tokenURI, the PIN is asked
+// interactively if needed and not part of the URI
+func openToken(tokenURI string, needPIN bool) (*hwtoken.URI, *hwtoken.Key, error) {
+	u, err := hwtoken.ParseURI(tokenURI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pin string
+	if needPIN {
+		if pin, err = u.PIN(); err != nil {
+			return nil, nil, err
+		}
+		if pin == "" {
+			if pin, err = sypgp.AskQuestionNoEcho("Enter token PIN: "); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	key, err := hwtoken.Open(u, pin)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open token key: %s", err)
+	}
+	return u, key, nil
+}
+
+// SignWithToken is like Sign but uses the private key held on the PKCS#11
+// token selected by tokenURI, the private key never leaves the token
+func SignWithToken(cpath, tokenURI string, id uint32, isGroup bool) error {
+	u, key, err := openToken(tokenURI, true)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	entity, err := tokenEntity(u, key)
+	if err != nil {
+		return err
+	}
+	return SignWithEntity(cpath, id, isGroup, entity)
+}
+
+// VerifyWithToken is like Verify but checks signatures against the public
+// key held on the PKCS#11 token selected by tokenURI
+func VerifyWithToken(cpath, tokenURI string, id uint32, isGroup bool) error {
+	u, key, err := openToken(tokenURI, false)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	entity, err := tokenEntity(u, key)
+	if err != nil {
+		return err
+	}
+	return verifyWithKeyring(cpath, "", id, isGroup, "", openpgp.EntityList{entity})
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/hwtoken"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// softSigner behaves like hwtoken.Key with a software key
+type softSigner struct {
+	crypto.Signer
+}
+
+func (s softSigner) Public() crypto.PublicKey {
+	switch pub := s.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		return *pub
+	case *ecdsa.PublicKey:
+		return *pub
+	}
+	return nil
+}
+
+func (s softSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil {
+		// openpgp passes no options to ECDSA signers, like PKCS#11 tokens
+		// the digest is signed as is
+		opts = crypto.SHA256
+	}
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+func TestTokenEntity(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	u := &hwtoken.URI{Token: "mytoken", Object: "mykey"}
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		signer := softSigner{key}
+		entity, err := tokenEntity(u, signer)
+		if err != nil {
+			t.Fatalf("unexpected failure: %s", err)
+		}
+
+		// the fingerprint must not change between invocations
+		again, err := tokenEntity(u, signer)
+		if err != nil {
+			t.Fatalf("unexpected failure: %s", err)
+		}
+		if entity.PrimaryKey.Fingerprint != again.PrimaryKey.Fingerprint {
+			t.Errorf("fingerprint changed between invocations")
+		}
+
+		var signed bytes.Buffer
+		plaintext, err := clearsign.Encode(&signed, entity.PrivateKey, nil)
+		if err != nil {
+			t.Fatalf("failed to create signature block: %s", err)
+		}
+		plaintext.Write([]byte("SIFHASH:\n0123"))
+		if err := plaintext.Close(); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+
+		block, _ := clearsign.Decode(signed.Bytes())
+		if block == nil {
+			t.Fatalf("failed to parse signature block")
+		}
+		signer2, err := openpgp.CheckDetachedSignature(openpgp.EntityList{again}, bytes.NewBuffer(block.Bytes), block.ArmoredSignature.Body)
+		if err != nil {
+			t.Fatalf("signature verification failed: %s", err)
+		}
+		if _, ok := signer2.Identities["mykey (PKCS#11 token mytoken)"]; !ok {
+			t.Errorf("unexpected identities %v", signer2.Identities)
+		}
+	}
+}
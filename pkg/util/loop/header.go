@@ -30,15 +30,30 @@ const (
 
 // Loop device IOCTL commands
 const (
-	CmdSetFd       = 0x4C00
-	CmdClrFd       = 0x4C01
-	CmdSetStatus   = 0x4C02
-	CmdGetStatus   = 0x4C03
-	CmdSetStatus64 = 0x4C04
-	CmdGetStatus64 = 0x4C05
-	CmdChangeFd    = 0x4C06
-	CmdSetCapacity = 0x4C07
-	CmdSetDirectIO = 0x4C08
+	CmdSetFd        = 0x4C00
+	CmdClrFd        = 0x4C01
+	CmdSetStatus    = 0x4C02
+	CmdGetStatus    = 0x4C03
+	CmdSetStatus64  = 0x4C04
+	CmdGetStatus64  = 0x4C05
+	CmdChangeFd     = 0x4C06
+	CmdSetCapacity  = 0x4C07
+	CmdSetDirectIO  = 0x4C08
+	CmdSetBlockSize = 0x4C09
+)
+
+// Block device IOCTL commands getting and setting the read-ahead, in 512
+// bytes sectors
+const (
+	CmdBlkRASet = 0x1262
+	CmdBlkRAGet = 0x1263
+)
+
+// Loop control device IOCTL commands
+const (
+	CmdCtlAdd     = 0x4C80
+	CmdCtlRemove  = 0x4C81
+	CmdCtlGetFree = 0x4C82
 )
 
 // Info64 contains information about a loop device.
@@ -8,34 +8,114 @@ package loop
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // Device describes a loop device
 type Device struct {
 	MaxLoopDevices int
-	file           *os.File
+	// Range restricts the loop devices used to a reserved range, devices
+	// up to MaxLoopDevices are used if nil
+	Range *Range
+	file  *os.File
+}
+
+// maxDeviceNumber is the highest loop device number, device numbers are
+// minor numbers limited to 20 bits by the kernel
+const maxDeviceNumber = 1<<20 - 1
+
+// Range is an inclusive range of loop device numbers
+type Range struct {
+	First int
+	Last  int
+}
+
+// ParseRange parses a range of loop device numbers formatted as FIRST-LAST
+func ParseRange(s string) (*Range, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("loop device range %s is not formatted as FIRST-LAST", s)
+	}
+	first, err := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid first loop device of range %s: %s", s, err)
+	}
+	last, err := strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid last loop device of range %s: %s", s, err)
+	}
+	if last < first {
+		return nil, fmt.Errorf("loop device range %s is empty", s)
+	}
+	if last > maxDeviceNumber {
+		return nil, fmt.Errorf("loop device range %s exceeds the highest loop device %d", s, maxDeviceNumber)
+	}
+	return &Range{First: int(first), Last: int(last)}, nil
 }
 
+// controlPath is the loop control device, used to find free devices
+const controlPath = "/dev/loop-control"
+
+// attachRetries is the number of times a loop device scan is retried when
+// all candidate devices were busy
+const attachRetries = 5
+
+// attachRetryDelay is the delay between two loop device scans
+var attachRetryDelay = 100 * time.Millisecond
+
 // AttachFromFile finds a free loop device, opens it, and stores file descriptor
 // provided by image file pointer
 func (loop *Device) AttachFromFile(image *os.File, mode int, number *int) error {
-	var path string
+	if loop.Range == nil && loop.MaxLoopDevices <= 0 {
+		return fmt.Errorf("invalid maximum number of loop devices: %d", loop.MaxLoopDevices)
+	}
+
+	for retry := 0; retry < attachRetries; retry++ {
+		busy, err := loop.attach(image, mode, number)
+		if err != nil || !busy {
+			return err
+		}
+		// devices may be released concurrently, e.g. by auto clear of
+		// containers exiting, give them a chance before failing
+		time.Sleep(attachRetryDelay)
+	}
+
+	return errors.New("No loop devices available")
+}
+
+// attach scans loop devices and attaches image to the first free one, it
+// returns true if no device was available because they were all busy.
+// Scans are serialized between processes by a lock of the loop control
+// device, other tools may still take a device concurrently.
+func (loop *Device) attach(image *os.File, mode int, number *int) (bool, error) {
+	busy := false
+
+	control := lockControl()
+	if control != nil {
+		defer control.Close()
+	}
 
-	for device := 0; device < loop.MaxLoopDevices; device++ {
-		path = fmt.Sprintf("/dev/loop%d", device)
+	devices := loop.candidates(control)
+	for device, ok := devices.Next(); ok; device, ok = devices.Next() {
+		path := fmt.Sprintf("/dev/loop%d", device)
 		if fi, err := os.Stat(path); err != nil {
-			dev := int((7 << 8) | device)
+			dev := int(unix.Mkdev(7, uint32(device)))
 			esys := syscall.Mknod(path, syscall.S_IFBLK|0660, dev)
 			if errno, ok := esys.(syscall.Errno); ok {
 				if errno != syscall.EEXIST {
-					return esys
+					return false, esys
 				}
 			}
 		} else if fi.Mode()&os.ModeDevice == 0 {
-			return fmt.Errorf("%s is not a block device", path)
+			return false, fmt.Errorf("%s is not a block device", path)
 		}
 
 		loopDev, err := os.OpenFile(path, mode, 0600)
@@ -45,22 +125,135 @@ func (loop *Device) AttachFromFile(image *os.File, mode int, number *int) error
 		_, _, esys := syscall.Syscall(syscall.SYS_IOCTL, loopDev.Fd(), CmdSetFd, image.Fd())
 		if esys != 0 {
 			loopDev.Close()
+			if esys == syscall.EBUSY {
+				busy = true
+			}
 			continue
 		}
-		if device == loop.MaxLoopDevices {
-			break
-		}
 		loop.file = loopDev
 		*number = device
 
 		if _, _, err := syscall.Syscall(syscall.SYS_FCNTL, loopDev.Fd(), syscall.F_SETFD, syscall.FD_CLOEXEC); err != 0 {
-			return fmt.Errorf("failed to set close-on-exec on loop device %s: %s", path, err.Error())
+			loop.Detach()
+			return false, fmt.Errorf("failed to set close-on-exec on loop device %s: %s", path, err.Error())
 		}
 
+		return false, nil
+	}
+
+	if busy {
+		return true, nil
+	}
+	return false, errors.New("No loop devices available")
+}
+
+// lockControl opens and locks the loop control device, the lock is released
+// when the returned file is closed. It returns nil if the device can't be
+// opened or locked, devices are then scanned without serialization.
+func lockControl() *os.File {
+	f, err := os.OpenFile(controlPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
 		return nil
 	}
+	return f
+}
 
-	return errors.New("No loop devices available")
+// candidates iterates over the numbers of the loop devices to try in order
+type candidates struct {
+	free      int
+	freeTried bool
+	next      int
+	last      int
+}
+
+// candidates returns the loop devices to try: the reserved range if set,
+// holding at most MaxLoopDevices devices, otherwise the free device reported
+// by the loop control device, if any, followed by the devices up to
+// MaxLoopDevices
+func (loop *Device) candidates(control *os.File) *candidates {
+	if loop.Range != nil {
+		last := loop.Range.Last
+		if loop.MaxLoopDevices > 0 && last-loop.Range.First >= loop.MaxLoopDevices {
+			last = loop.Range.First + loop.MaxLoopDevices - 1
+		}
+		return &candidates{free: -1, next: loop.Range.First, last: last}
+	}
+
+	c := &candidates{free: -1, next: 0, last: loop.MaxLoopDevices - 1}
+	if control != nil {
+		n, _, esys := syscall.Syscall(syscall.SYS_IOCTL, control.Fd(), CmdCtlGetFree, 0)
+		if esys == 0 && int(n) < loop.MaxLoopDevices {
+			c.free = int(n)
+		}
+	}
+	return c
+}
+
+// Next returns the next loop device number, or false once all devices were
+// returned
+func (c *candidates) Next() (int, bool) {
+	if c.free >= 0 && !c.freeTried {
+		c.freeTried = true
+		return c.free, true
+	}
+	for c.next <= c.last {
+		device := c.next
+		c.next++
+		if device != c.free {
+			return device, true
+		}
+	}
+	return 0, false
+}
+
+// Tuning holds performance settings of a loop device, zero values keep the
+// kernel defaults
+type Tuning struct {
+	// DirectIO bypasses the page cache of the backing file, avoiding
+	// double caching of images
+	DirectIO bool
+	// BlockSize is the logical block size of the device in bytes
+	BlockSize uint32
+	// ReadAhead is the read-ahead of the device in KiB
+	ReadAhead uint32
+}
+
+// Tune applies the settings of t to the attached loop device. Direct I/O
+// is rejected by kernels when the backing filesystem doesn't support it or
+// the offset isn't aligned with the block size.
+func (loop *Device) Tune(t Tuning) error {
+	if loop.file == nil {
+		return errors.New("loop device is not attached")
+	}
+	if t.BlockSize != 0 {
+		if err := loop.ioctl(CmdSetBlockSize, uintptr(t.BlockSize)); err != nil {
+			return fmt.Errorf("failed to set block size %d on loop device: %s", t.BlockSize, err)
+		}
+	}
+	if t.DirectIO {
+		if err := loop.ioctl(CmdSetDirectIO, 1); err != nil {
+			return fmt.Errorf("failed to enable direct I/O on loop device: %s", err)
+		}
+	}
+	if t.ReadAhead != 0 {
+		if err := loop.ioctl(CmdBlkRASet, uintptr(t.ReadAhead)*2); err != nil {
+			return fmt.Errorf("failed to set read-ahead on loop device: %s", err)
+		}
+	}
+	return nil
+}
+
+// ioctl runs the IOCTL command cmd with arg on the loop device
+func (loop *Device) ioctl(cmd, arg uintptr) error {
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, loop.file.Fd(), cmd, arg)
+	if err != 0 {
+		return syscall.Errno(err)
+	}
+	return nil
 }
 
 // AttachFromPath finds a free loop device, opens it, and stores file descriptor
@@ -81,3 +274,52 @@ func (loop *Device) SetStatus(info *Info64) error {
 	}
 	return nil
 }
+
+// Detach releases the loop device from its backing image and closes it,
+// it must be called when the loop device is not used after an error
+func (loop *Device) Detach() error {
+	if loop.file == nil {
+		return nil
+	}
+	defer loop.Close()
+
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, loop.file.Fd(), CmdClrFd, 0)
+	if err != 0 {
+		return fmt.Errorf("Failed to detach loop device: %s", syscall.Errno(err))
+	}
+	return nil
+}
+
+// BackingFile returns the path of the file attached to the loop device
+// number, or an empty string if the device is free
+func BackingFile(number int) (string, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/sys/block/loop%d/loop/backing_file", number))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSpace(string(data)), " (deleted)"), nil
+}
+
+// DetachDevice releases the loop device number from its backing file. A
+// device still in use, like a mounted one, is released once unused.
+func DetachDevice(number int) error {
+	file, err := os.OpenFile(fmt.Sprintf("/dev/loop%d", number), os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	loop := &Device{file: file}
+	return loop.Detach()
+}
+
+// Close closes the loop device file descriptor, a loop device set with the
+// auto clear flag is released once unmounted
+func (loop *Device) Close() error {
+	if loop.file == nil {
+		return nil
+	}
+	err := loop.file.Close()
+	loop.file = nil
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package loop
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestAttachInvalidMax(t *testing.T) {
+	f, err := ioutil.TempFile("", "loop-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	number := -1
+	loopdev := &Device{MaxLoopDevices: 0}
+	if err := loopdev.AttachFromFile(f, os.O_RDONLY, &number); err == nil {
+		t.Errorf("unexpected success with a maximum of 0 loop devices")
+	}
+	if number != -1 {
+		t.Errorf("unexpected loop device number %d", number)
+	}
+}
+
+func TestDetachUnattached(t *testing.T) {
+	loopdev := &Device{MaxLoopDevices: 1}
+	if err := loopdev.Detach(); err != nil {
+		t.Errorf("unexpected error while detaching unattached device: %s", err)
+	}
+	if err := loopdev.Close(); err != nil {
+		t.Errorf("unexpected error while closing unattached device: %s", err)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected *Range
+	}{
+		{"64-127", &Range{First: 64, Last: 127}},
+		{"8 - 8", &Range{First: 8, Last: 8}},
+		{"64", nil},
+		{"127-64", nil},
+		{"-1-8", nil},
+		{"a-b", nil},
+		{"0-4294967295", nil},
+		{"0-1048575", &Range{First: 0, Last: 1048575}},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.value)
+		if tt.expected == nil {
+			if err == nil {
+				t.Errorf("unexpected success parsing %q", tt.value)
+			}
+		} else if err != nil || *r != *tt.expected {
+			t.Errorf("unexpected range %v parsing %q, expected %v: %v", r, tt.value, tt.expected, err)
+		}
+	}
+}
+
+func collect(c *candidates) []int {
+	var devices []int
+	for device, ok := c.Next(); ok; device, ok = c.Next() {
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		loopdev  *Device
+		free     int
+		expected []int
+	}{
+		{"range", &Device{MaxLoopDevices: 4, Range: &Range{First: 8, Last: 10}}, -1, []int{8, 9, 10}},
+		{"capped range", &Device{MaxLoopDevices: 2, Range: &Range{First: 8, Last: maxDeviceNumber}}, -1, []int{8, 9}},
+		{"no control", &Device{MaxLoopDevices: 4}, -1, []int{0, 1, 2, 3}},
+		{"free", &Device{MaxLoopDevices: 4}, 2, []int{2, 0, 1, 3}},
+	}
+	for _, tt := range tests {
+		c := tt.loopdev.candidates(nil)
+		c.free = tt.free
+		if devices := collect(c); !reflect.DeepEqual(devices, tt.expected) {
+			t.Errorf("%s: unexpected devices %v, expected %v", tt.name, devices, tt.expected)
+		}
+	}
+}
+
+func TestTune(t *testing.T) {
+	loopdev := &Device{MaxLoopDevices: 256}
+	if err := loopdev.Tune(Tuning{DirectIO: true}); err == nil {
+		t.Errorf("unexpected success tuning an unattached device")
+	}
+	if os.Getuid() != 0 {
+		t.Skip("attaching loop devices requires privileges")
+	}
+
+	f, err := ioutil.TempFile("", "loop-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := f.Truncate(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	number := -1
+	if err := loopdev.AttachFromFile(f, os.O_RDWR, &number); err != nil {
+		t.Skipf("no loop device available: %v", err)
+	}
+	defer loopdev.Detach()
+
+	if err := loopdev.Tune(Tuning{BlockSize: 4096, ReadAhead: 256}); err != nil {
+		t.Fatalf("unexpected error tuning loop device: %v", err)
+	}
+	var sectors uint64
+	_, _, esys := syscall.Syscall(syscall.SYS_IOCTL, loopdev.file.Fd(), CmdBlkRAGet, uintptr(unsafe.Pointer(&sectors)))
+	if esys != 0 {
+		t.Fatalf("failed to read read-ahead: %v", esys)
+	}
+	if sectors != 512 {
+		t.Errorf("unexpected read-ahead of %d sectors, expected 512", sectors)
+	}
+}
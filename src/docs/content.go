@@ -84,11 +84,28 @@ Enterprise Performance Computing (EPC)`
           OSVersion: 7
           MirrorURL: http://mirror.centos.org/centos-%{OSVERSION}/%{OSVERSION}/os/x86_64/
           Include: yum
+          GPGKey: https://www.centos.org/keys/RPM-GPG-KEY-CentOS-7
+
+      Zypper/openSUSE:
+          Bootstrap: zypper
+          OSVersion: 15.0
+          MirrorURL: http://download.opensuse.org/distribution/leap/%{OSVERSION}/repo/oss/
+          UpdateURL: http://download.opensuse.org/update/leap/%{OSVERSION}/oss/
+          Include: zypper
+          GPGKey: https://download.opensuse.org/distribution/leap/15.0/repo/oss/repodata/repomd.xml.key
 
       Debian/Ubuntu:
           Bootstrap: debootstrap
           OSVersion: trusty
           MirrorURL: http://us.archive.ubuntu.com/ubuntu/
+          GPGKey: /usr/share/keyrings/ubuntu-archive-keyring.gpg
+
+      Arch Linux:
+          Bootstrap: arch
+          MirrorURL: https://archive.archlinux.org/repos/2018/11/01/$repo/os/$arch
+          SigLevel: Required DatabaseOptional
+          Packages: base-devel
+          Include: vim
 
       Local Image:
           Bootstrap: localimage
@@ -96,6 +113,11 @@ Enterprise Performance Computing (EPC)`
 
   DEFFILE SECTIONS:
 
+      %include base.def
+          Merge the definition file base.def, relative to this file. Its header
+          and sections are overridden by the ones of this file, except %files,
+          %labels, %environment and %arguments which are appended to.
+
       %pre
           echo "This is a scriptlet that will be executed on the host, as root before"
           echo "the container has been bootstrapped. This section is not commonly used."
@@ -109,6 +131,15 @@ Enterprise Performance Computing (EPC)`
           echo "This scriptlet section will be executed from within the container after"
           echo "the bootstrap/base has been created and setup."
 
+      %pre-assemble
+          echo "This is a scriptlet that will be executed on the host, before the image"
+          echo "$SINGULARITY_IMAGE is assembled from the container at $SINGULARITY_ROOTFS."
+
+      %post-assemble
+          echo "This is a scriptlet that will be executed on the host, after the image"
+          echo "$SINGULARITY_IMAGE of format $SINGULARITY_IMAGE_FORMAT has been assembled,"
+          echo "e.g. to push or register it."
+
       %test
           echo "Define any test commands that should be executed after container has been"
           echo "built. This scriptlet will be executed from within the running container"
@@ -116,6 +147,11 @@ Enterprise Performance Computing (EPC)`
           echo "as any non-zero exit code will be assumed as failure."
           exit 0
 
+      %test smoke
+          echo "Named test blocks are run after the %test section, in the order they are"
+          echo "defined. Each test is run on its own and reported separately, a failing"
+          echo "test does not prevent the others from running."
+
       %runscript
           echo "Define actions for the container to be executed with the run command or"
           echo "when container is executed."
@@ -130,6 +166,7 @@ Enterprise Performance Computing (EPC)`
       %files
           /path/on/host/file.txt /path/on/container/file.txt
           relative_file.txt /path/on/container/relative_file.txt
+          src/** /opt/src !**/*.o --preserve=owner,xattrs
 
       %environment
           LUKE=goodguy
@@ -151,7 +188,77 @@ Enterprise Performance Computing (EPC)`
       Build a base sandbox from DockerHub, make changes to it, then build sif
           $ singularity build --sandbox /tmp/debian docker://debian:latest
           $ singularity exec --writable /tmp/debian apt-get install python
-          $ singularity build /tmp/debian2.sif /tmp/debian`
+          $ singularity build /tmp/debian2.sif /tmp/debian
+
+      Build with a checkpoint of the bootstrapped container, running the same
+      command again after a failure skips the bootstrap
+          $ sudo singularity build --resume /tmp/debian3.sif /path/to/debian.def
+
+      Build an OCI image layout directory, or an oci-archive tarball, which can
+      be pushed to a Docker registry
+          $ sudo singularity build --format oci /tmp/debian-oci /path/to/debian.def
+          $ sudo singularity build --format oci-archive /tmp/debian.tar /path/to/debian.def
+
+      Build a docker-archive tarball tagged debian:custom, or load the image
+      directly in the local docker daemon
+          $ sudo singularity build --format docker-archive /tmp/debian-docker.tar:debian:custom /path/to/debian.def
+          $ sudo singularity build --format docker-daemon debian:custom /path/to/debian.def
+
+      Stream the build progress events as JSON lines on stdout, the output of
+      remote builds is sent as "output" events
+          $ sudo singularity build --json-progress /tmp/debian.sif /path/to/debian.def
+          $ singularity build --remote --json-progress /tmp/debian.sif /path/to/debian.def
+
+      Print the duration of each build phase, from the bootstrap to the SIF
+      image creation, once the build is complete. The timings are also stored
+      in SIF images and in the JSON build report
+          $ sudo singularity build --timings /tmp/debian.sif /path/to/debian.def
+
+      Fail the build, and remove its temporary directory, if it grows over
+      20GiB, e.g. to keep a runaway %post from filling /tmp on a shared host
+          $ sudo singularity build --tmpdir-max-size 20G /tmp/debian.sif /path/to/debian.def
+
+      Run the %test blocks of the definition, and write their results to a
+      JUnit XML file, even if some of them fail
+          $ sudo singularity build --test-report junit.xml /tmp/debian.sif /path/to/debian.def
+
+      Check a definition file, reporting problems with their line numbers,
+      without building it
+          $ singularity build --dry-run /tmp/debian.sif /path/to/debian.def
+
+      Run %post and %test without network access, or in an isolated network
+      namespace attached to the CNI bridge (%setup always uses the host network)
+          $ sudo singularity build --network none /tmp/debian.sif /path/to/debian.def
+          $ sudo singularity build --network bridge /tmp/debian.sif /path/to/debian.def
+
+      Set the {{ .TAG }} build argument of a definition file, overriding the
+      default given in its %arguments section (e.g. TAG=18.04)
+          $ sudo singularity build --build-arg TAG=16.04 /tmp/ubuntu.sif /path/to/ubuntu.def
+
+      Build a smaller SIF image with xz compression, which must be supported
+      by the local mksquashfs and by the kernel of the hosts running the image
+          $ sudo singularity build --compression xz /tmp/debian.sif /path/to/debian.def
+
+      Rebuilds of an unchanged definition restore the container filesystem
+      after %post from the build cache, skipping bootstrap and scripts. The
+      cache is keyed on the base image digest, the scripts and the %files
+      content, use --no-cache to always build from scratch
+          $ sudo singularity build --no-cache /tmp/debian.sif /path/to/debian.def
+
+      Scan the installed packages for known vulnerabilities before creating
+      the image, failing the build on critical ones and warning about the
+      others. The scan report is stored in the SIF image
+          $ sudo singularity build --scan --scan-url https://cve.example.com --scan-severity critical /tmp/debian.sif /path/to/debian.def
+
+      Sign the SIF image with a key of the local keyring as soon as it is
+      created, the key passphrase is asked before the build starts
+          $ sudo singularity build --sign-key 8883491F4268F173C6E5DC49EDECE4F3F38D871E /tmp/debian.sif /path/to/debian.def
+
+      Add org.opencontainers.image.* annotations to the image labels, and to
+      the manifest of OCI formats. Authors, source, revision and licenses come
+      from the labels of the same name, the source and revision default to the
+      git repository holding the definition file
+          $ sudo singularity build --oci-annotations --format oci /tmp/debian-oci /path/to/debian.def`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// keys
@@ -418,6 +525,39 @@ Enterprise Performance Computing (EPC)`
   $ singularity help instance start
   $ singularity instance start --help`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance exec
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceExecUse   string = `exec [exec options...] <instance name> <command>`
+	InstanceExecShort string = `Execute a command within a running instance`
+	InstanceExecLong  string = `
+  The instance exec command runs a command within a running named instance,
+  joining its namespaces and cgroup. It is equivalent to running the exec
+  command with an instance://<instance name> URI, only the options applying
+  to a process joining an instance are accepted, the others being set when
+  the instance is started.`
+	InstanceExecExample string = `
+  $ singularity instance start /tmp/my-sql.sif mysql
+  $ singularity instance exec mysql ps
+  PID TTY          TIME CMD
+    1 ?        00:00:00 sinit
+    2 ?        00:00:00 ps`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance shell
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceShellUse   string = `shell [shell options...] <instance name>`
+	InstanceShellShort string = `Run a shell within a running instance`
+	InstanceShellLong  string = `
+  The instance shell command spawns an interactive shell within a running
+  named instance, joining its namespaces and cgroup. It is equivalent to
+  running the shell command with an instance://<instance name> URI.`
+	InstanceShellExample string = `
+  $ singularity instance start /tmp/my-sql.sif mysql
+  $ singularity instance shell mysql
+  Singularity my-sql.sif> pwd
+  /home/mibauer/mysql`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance list
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -451,7 +591,7 @@ Enterprise Performance Computing (EPC)`
 	InstanceStartExample string = `
   $ singularity instance start /tmp/my-sql.sif mysql
 
-  $ singularity shell instance://mysql
+  $ singularity instance shell mysql
   Singularity my-sql.sif> pwd
   /home/mibauer/mysql
   Singularity my-sql.sif> ps
@@ -513,6 +653,9 @@ Enterprise Performance Computing (EPC)`
   From Docker
   $ singularity pull tensorflow.sif docker://tensorflow/tensorflow:latest
 
+  From Docker, for another platform than the host
+  $ singularity pull --platform linux/arm/v7 alpine.sif docker://alpine:latest
+
   From Shub
   $ singularity pull singularity-images.sif shub://vsoch/singularity-images`
 
@@ -523,9 +666,16 @@ Enterprise Performance Computing (EPC)`
 	PushShort string = `Push a container to a Library URI`
 	PushLong  string = `
   The Singularity push command allows you to upload your sif image to a library
-  of your choosing`
+  of your choosing
+
+  Large images are uploaded in parts, several parts being sent concurrently.
+  Parts which fail to upload are retried, and running the same push command
+  again after a failure resumes the upload where it stopped.`
 	PushExample string = `
-  $ singularity push /home/user/my.sif library://user/collection/my.sif:latest`
+  $ singularity push /home/user/my.sif library://user/collection/my.sif:latest
+
+  Upload 8 parts concurrently:
+  $ singularity push --parallel 8 /home/user/my.sif library://user/collection/my.sif:latest`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// search
@@ -608,9 +758,23 @@ Enterprise Performance Computing (EPC)`
   The sign command allows a user to create a cryptographic signature on either a 
   single data object or a list of data objects within the same SIF group. By 
   default without parameters, the command searches for the primary partition and 
-  creates a verification block that is then added to the SIF container file.`
+  creates a verification block that is then added to the SIF container file.
+
+  With --pkcs11-uri, the signature is created by a RSA or ECDSA key held on a
+  PKCS#11 device (Yubikey, HSM...) selected by a RFC 7512 URI, the private key
+  never leaves the device. The URI requires a module-path query attribute, the
+  token PIN is read from the pin-value or pin-source attributes or asked
+  interactively.
+
+  With --sigstore, a cosign-compatible signature of the data object digest is
+  stored instead, using the PEM private key given by --sigstore-key. Keyless
+  (OIDC) signing and encrypted cosign keys are not supported.`
 	SignExample string = `
-  $ singularity sign container.sif`
+  $ singularity sign container.sif
+
+  $ singularity sign --pkcs11-uri 'pkcs11:token=mytoken;object=signkey?module-path=/usr/lib/libykcs11.so' container.sif
+
+  $ singularity sign --sigstore --sigstore-key sign.key container.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// verify
@@ -623,9 +787,28 @@ Enterprise Performance Computing (EPC)`
   multiple data objects signed. By default the command searches for the primary 
   partition signature. If found, a list of all verification blocks applied on 
   the primary partition is gathered so that data integrity (hashing) and 
-  signature verification is done for all those blocks.`
+  signature verification is done for all those blocks.
+
+  With --pkcs11-uri, signatures are verified against the public key held on a
+  PKCS#11 device instead of the local keyring and key server, no PIN is
+  required.
+
+  With --sigstore, the cosign-compatible signatures are checked against the
+  PEM public key given by --sigstore-key, like a cosign.pub file.
+
+  With --content, the image is mounted and the size and sha256 hash of each
+  file are checked against the content manifest recorded at build time, to
+  report which files were added, removed or modified. The manifest is a data
+  object of the image, verify its signatures to make sure it was not
+  altered.`
 	VerifyExample string = `
-  $ singularity verify container.sif`
+  $ singularity verify container.sif
+
+  $ singularity verify --pkcs11-uri 'pkcs11:token=mytoken;object=signkey?module-path=/usr/lib/libykcs11.so' container.sif
+
+  $ singularity verify --sigstore --sigstore-key cosign.pub container.sif
+
+  $ singularity verify --content container.sif`
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Run-help
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -657,9 +840,61 @@ Enterprise Performance Computing (EPC)`
 	InspectShort string = `Display metadata for container if available`
 	InspectLong  string = `
   Inspect will show you labels, environment variables, and scripts associated 
-  with the image determined by the flags you pass.`
+  with the image determined by the flags you pass.
+
+  Metadata of sandbox images are read directly from the directory, without
+  starting a container. SIF images store their labels, scripts and definition
+  file as data objects at build time, which are read the same way.`
 	InspectExample string = `
-  $ singularity inspect ubuntu.sif`
+  $ singularity inspect ubuntu.sif
+
+  To show whether and when the %test blocks passed at build time:
+
+  $ singularity inspect --test-results ubuntu.sif
+
+  To show the labels of a docker image from its registry configuration,
+  without downloading its layers:
+
+  $ singularity inspect docker://alpine:3.8
+
+  Other attributes, and images from the library or Singularity Hub, are
+  inspected once pulled to the cache:
+
+  $ singularity inspect --runscript library://alpine:3.8
+
+  To show all the metadata of the image and of its SCIF apps as a JSON
+  document with "type", "attributes" and "apps" keys, apps being keyed by
+  name:
+
+  $ singularity inspect --all ubuntu.sif
+
+  To show the labels, runscript, helpfile, environment and test of every
+  SCIF app, keyed by app name under "apps":
+
+  $ singularity inspect --list-apps ubuntu.sif
+
+  To print a single label, or the names of the SCIF apps, with a Go template.
+  Templates get the Labels, Deffile, Runscript, Test, Environment, Helpfile,
+  TestResults, Attributes and Apps fields, JSON documents printed by inspect
+  carry the version of their format as "apiVersion":
+
+  $ singularity inspect --format '{{ .Labels.MAINTAINER }}' ubuntu.sif
+  $ singularity inspect --format '{{ range $name, $app := .Apps }}{{ $name }} {{ end }}' ubuntu.sif
+
+  To correct the labels of a SIF image without rebuilding it. Edited labels
+  are stored in a SIF data object, which is not covered by the signatures of
+  the image:
+
+  $ singularity inspect --set-label MAINTAINER=site --delete-label TEMP ubuntu.sif
+
+  To list the data objects of a SIF image, with their type, size and whether
+  a signature covers them:
+
+  $ singularity inspect --list-data ubuntu.sif
+
+  To show the runscript of the foo SCIF app of a sandbox:
+
+  $ singularity inspect --app foo --runscript ubuntu/`
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Test
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -688,4 +923,285 @@ Enterprise Performance Computing (EPC)`
   found at:
 
       https://www.sylabs.io/docs/`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayUse   string = `overlay <subcommand>`
+	OverlayShort string = `Manage persistent overlay images`
+	OverlayLong  string = `
+  The overlay command allows you to manage EXT3 persistent overlays, either
+  standalone overlay images or overlay partitions embedded in a SIF image.`
+	OverlayExample string = `
+  All group commands have their own help output:
+
+  $ singularity help overlay create
+  $ singularity overlay create --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay create
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayCreateUse   string = `create [create options...] <image path>`
+	OverlayCreateShort string = `Create a persistent overlay image`
+	OverlayCreateLong  string = `
+  The overlay create command creates a sparse EXT3 image holding the upper and
+  work directories of a persistent overlay, owned by the calling user. The
+  directories given with --dirs are created in the overlay, so they are
+  available in containers using it. The size accepts K, M, G and T suffixes.
+
+  With --sif, the overlay is added as a partition of an existing SIF image
+  instead. The overlay partition is mounted with the image, writable when the
+  container is run with --writable and read-only otherwise.`
+	OverlayCreateExample string = `
+  $ singularity overlay create --size 1G overlay.img
+  $ singularity overlay create --size 1G --dirs /data,/scratch overlay.img
+  $ singularity shell --overlay overlay.img image.sif
+  $ singularity overlay create --size 1G --sif image.sif
+  $ singularity shell --writable image.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay resize
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayResizeUse   string = `resize [resize options...] <image path>`
+	OverlayResizeShort string = `Grow a persistent overlay image`
+	OverlayResizeLong  string = `
+  The overlay resize command grows the EXT3 filesystem of a standalone overlay
+  image or of the overlay partition embedded in a SIF image. The size accepts
+  K, M, G and T suffixes, and can only be increased. For SIF images, the
+  overlay partition must be the last data object of the image.`
+	OverlayResizeExample string = `
+  $ singularity overlay resize --size 4G image.sif
+  $ singularity overlay resize --size 512M overlay.img`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// store
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	StoreUse   string = `store <subcommand>`
+	StoreShort string = `Manage the local content-addressed image store`
+	StoreLong  string = `
+  The image store keeps the data objects of SIF images as blobs named after
+  their SHA256 digest. Images added to the store are replaced by thin manifests
+  referencing those blobs, so images sharing identical partitions, like a
+  common base root filesystem, only use the disk space once.
+
+  Manifests can be used directly with the action commands. The image is then
+  read from the store, which must be owned by root or by the user running the
+  container and must not be writable by group or others. Limits set in
+  singularity.conf, like "limit container paths", apply to the manifest.`
+	StoreExample string = `
+  All group commands have their own help output:
+
+  $ singularity help store add
+  $ singularity store add --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// store add
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	StoreAddUse   string = `add [add options...] <image path>...`
+	StoreAddShort string = `Move SIF images into the image store`
+	StoreAddLong  string = `
+  The store add command moves the data of the given SIF images into the image
+  store and replaces each image file by a manifest. By default, the store is
+  located in the cache directory.`
+	StoreAddExample string = `
+  $ singularity store add ubuntu.sif
+  $ singularity store add --dir /shared/store *.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// store export
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	StoreExportUse   string = `export <manifest path> <image path>`
+	StoreExportShort string = `Rebuild a standalone SIF image from a manifest`
+	StoreExportLong  string = `
+  The store export command rebuilds the original SIF image described by a
+  manifest, verifying the digest of each blob read from the store.`
+	StoreExportExample string = `
+  $ singularity store export ubuntu.sif /tmp/ubuntu.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// image
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImageUse   string = `image <subcommand>`
+	ImageShort string = `Manage container images on the host`
+	ImageLong  string = `
+  The image command allows you to access the content of container images
+  directly from the host, without starting a container.`
+	ImageExample string = `
+  All group commands have their own help output:
+
+  $ singularity help image mount
+  $ singularity image mount --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// image mount
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImageMountUse   string = `mount [mount options...] <image path> <mount point>`
+	ImageMountShort string = `Mount the root filesystem of an image on the host`
+	ImageMountLong  string = `
+  The image mount command mounts the root filesystem of a SIF, squashfs or
+  ext3 image on the given directory so its content can be browsed and copied
+  with regular tools. Images are mounted with a loop device when running as
+  root. Unprivileged users need squashfuse for squashfs based images and
+  fuse2fs for ext3 based images.`
+	ImageMountExample string = `
+  $ singularity image mount ubuntu.sif /mnt
+  $ ls /mnt/etc
+  $ singularity image umount /mnt`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// image umount
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImageUmountUse   string = `umount <mount point>`
+	ImageUmountShort string = `Unmount an image mounted with image mount`
+	ImageUmountLong  string = `
+  The image umount command unmounts an image previously mounted with the
+  image mount command.`
+	ImageUmountExample string = `
+  $ singularity image umount /mnt`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// completion
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	CompletionUse   string = `completion <bash|zsh>`
+	CompletionShort string = `Generate shell completion scripts`
+	CompletionLong  string = `
+  The completion command outputs a completion script for the given shell,
+  generated from the singularity commands and flags. The bash completion
+  also completes running instance names and images found in the cache.`
+	CompletionExample string = `
+  $ singularity completion bash > /etc/bash_completion.d/singularity
+  $ source <(singularity completion bash)`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// debug-report
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DebugReportUse   string = `debug-report [report path]`
+	DebugReportShort string = `Gather host and installation information for bug reports`
+	DebugReportLong  string = `
+  The debug-report command gathers the singularity version and build
+  configuration, the effective singularity.conf values, kernel capabilities
+  (user namespaces, overlay and squashfs support), loop device status, cgroup
+  mode and recent instance logs into a single gzipped tarball which can be
+  attached to bug reports. Review the report content before sharing it.`
+	DebugReportExample string = `
+  $ singularity debug-report
+  $ singularity debug-report /tmp/report.tar.gz`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// verify-host
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	VerifyHostUse   string = `verify-host`
+	VerifyHostShort string = `Check that the host is able to run containers`
+	VerifyHostLong  string = `
+  The verify-host command checks the kernel version, user namespace
+  availability, overlay and squashfs support, loop device limits, cgroup
+  version and setuid installation status. Each check is reported as PASS,
+  WARN or FAIL with a remediation hint, the command exits with a non zero
+  status if any check failed.`
+	VerifyHostExample string = `
+  $ singularity verify-host`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// diff
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DiffUse   string = `diff [diff options...] <image path> <image path>`
+	DiffShort string = `Show the differences between two SIF images`
+	DiffLong  string = `
+  The diff command compares the labels, definition files and data objects
+  of two SIF images, without starting a container. With --files the squashfs
+  partitions are extracted in a temporary directory to also compare the name,
+  mode, size and sha256 hash of their files. Labels of images built without a
+  labels data object are only compared with --files.
+
+  Added entries are prefixed by +, removed ones by - and modified ones by ~.
+  The command exits with status 1 if the images differ, like diff(1).`
+	DiffExample string = `
+  $ singularity diff ubuntu-1.0.sif ubuntu-1.1.sif
+
+  To also compare the files of the images, in JSON format:
+
+  $ singularity diff --files --json ubuntu-1.0.sif ubuntu-1.1.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// build jobs
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	BuildJobsUse   string = `jobs <subcommand>`
+	BuildJobsShort string = `Manage remote builds`
+	BuildJobsLong  string = `
+  The build jobs commands list the builds submitted with build --remote from
+  this host, stream their logs and cancel them. The Build Service is selected
+  with the --builder option. To build an image named jobs in the current
+  directory, use ./jobs as the image path.`
+	BuildJobsExample string = `
+  All group commands have their own help output:
+
+  $ singularity help build jobs logs
+  $ singularity build jobs logs --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// build jobs list
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	BuildJobsListUse   string = `list`
+	BuildJobsListShort string = `List remote builds submitted from this host`
+	BuildJobsListLong  string = `
+  The build jobs list command shows the last remote builds submitted from this
+  host along with their current status (queued, running or complete).`
+	BuildJobsListExample string = `
+  $ singularity build jobs list
+  ID                        SUBMITTED            STATUS    IMAGE
+  5bd1c0d0b3f2c80001f0b0a5  2018-10-25 14:02:11  complete  alpine.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// build jobs logs
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	BuildJobsLogsUse   string = `logs [logs options...] <build ID>`
+	BuildJobsLogsShort string = `Stream the output of a remote build`
+	BuildJobsLogsLong  string = `
+  The build jobs logs command streams the output of a remote build. With
+  --follow, a lost connection to the Build Service is reestablished until the
+  build completes.`
+	BuildJobsLogsExample string = `
+  $ singularity build --remote --detached alpine.sif alpine.def
+  $ singularity build jobs logs --follow 5bd1c0d0b3f2c80001f0b0a5`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// build jobs cancel
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	BuildJobsCancelUse   string = `cancel <build ID>`
+	BuildJobsCancelShort string = `Cancel a remote build`
+	BuildJobsCancelLong  string = `
+  The build jobs cancel command cancels a queued or running remote build.`
+	BuildJobsCancelExample string = `
+  $ singularity build jobs cancel 5bd1c0d0b3f2c80001f0b0a5`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// cache
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	CacheUse   string = `cache <subcommand>`
+	CacheShort string = `Manage the local image cache`
+	CacheLong  string = `
+  The cache command allows you to manage the cache of pulled images and OCI
+  blobs, located in ~/.singularity/cache or in SINGULARITY_CACHEDIR. Cache
+  entries are locked while they are fetched, so that the cache can be shared
+  by simultaneous builds and pulls on the same node or over NFS.`
+	CacheExample string = `
+  All group commands have their own help output:
+
+  $ singularity help cache verify
+  $ singularity cache verify --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// cache verify
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	CacheVerifyUse   string = `verify [verify options...]`
+	CacheVerifyShort string = `Detect and remove damaged cache entries`
+	CacheVerifyLong  string = `
+  The cache verify command checks cached images and OCI blobs against their
+  checksum and SIF format, and looks for temporary files left by interrupted
+  downloads. Damaged entries are removed so that they are downloaded again
+  next time, entries in use by another process are skipped. The command exits
+  with an error if damaged entries were found but not removed.`
+	CacheVerifyExample string = `
+  $ singularity cache verify
+  $ singularity cache verify --dry-run`
 )